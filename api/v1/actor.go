@@ -0,0 +1,33 @@
+package api_v1
+
+import (
+	"net/http"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+// identify records the changes of requests that write as made by the holder
+// of their credential: the admin with the admin token, or the customer of a
+// session. Requests without a valid one are anonymous, the routes that need
+// a credential refuse them on their own.
+func identify(adminController *adminController, customerController *customerController) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			token, ok := bearerToken(req)
+			if !ok || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			if adminController != nil && adminController.IsAdmin(token) {
+				req = req.WithContext(audit.WithActor(req.Context(), AdminActor))
+			} else if customerController != nil {
+				if customer, err := customerController.customerService.Authenticate(token); err == nil {
+					req = req.WithContext(audit.WithActor(req.Context(), customerActor(customer.Id)))
+				}
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package api_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+func TestIdentify_1(t *testing.T) {
+	var actor string
+	handler := identify(NewAdminController(testAdminToken), NewCustomerController(&mockCustomerService{}))(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		actor = audit.ActorFrom(req.Context())
+	}))
+
+	tests := []struct {
+		method        string
+		authorization string
+		expected      string
+	}{
+		{method: "POST", authorization: "Bearer " + testAdminToken, expected: AdminActor},
+		{method: "DELETE", authorization: "Bearer token", expected: customerActor(testCustomer.Id)},
+		{method: "PUT", authorization: "Bearer expired", expected: audit.Anonymous},
+		{method: "PUT", authorization: "", expected: audit.Anonymous},
+		// Reads change nothing, so their credential is not looked up
+		{method: "GET", authorization: "Bearer " + testAdminToken, expected: audit.Anonymous},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/trip/1", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if actor != test.expected {
+			t.Fatalf("expected %v %q to be made by %v, got %v", test.method, test.authorization, test.expected, actor)
+		}
+	}
+}
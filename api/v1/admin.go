@@ -0,0 +1,49 @@
+package api_v1
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+// Actor of the changes made with the admin token
+const AdminActor = "admin"
+
+type adminController struct {
+	token string
+}
+
+// NewAdminController returns the controller that guards operator endpoints
+// with token. They are refused to everyone if token is empty.
+func NewAdminController(token string) *adminController {
+	return &adminController{token}
+}
+
+// RequireAdmin only lets through requests with the admin token in an
+// Authorization: Bearer header, and records their changes as made by the admin
+func (adminController *adminController) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if adminController.token == "" {
+			http.Error(w, "Forbidden - admin endpoints are disabled, start the server with -admin_token", http.StatusForbidden)
+			return
+		}
+
+		token, ok := bearerToken(req)
+		if !ok {
+			writeUnauthorized(w, "the admin token is required")
+			return
+		}
+		if !adminController.IsAdmin(token) {
+			writeUnauthorized(w, "invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(audit.WithActor(req.Context(), AdminActor)))
+	})
+}
+
+// IsAdmin tells whether token is the admin token, taking the same time whatever it is
+func (adminController *adminController) IsAdmin(token string) bool {
+	return adminController.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminController.token)) == 1
+}
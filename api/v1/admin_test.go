@@ -0,0 +1,54 @@
+package api_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+// Admin token of the routers of the tests
+const testAdminToken = "test-admin-token"
+
+func TestRequireAdmin_1(t *testing.T) {
+	var actor string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		actor = audit.ActorFrom(req.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		token         string
+		authorization string
+		expected      int
+	}{
+		{token: "secret", authorization: "Bearer secret", expected: http.StatusNoContent},
+		{token: "secret", authorization: "bearer secret", expected: http.StatusNoContent},
+		{token: "secret", authorization: "Bearer other", expected: http.StatusUnauthorized},
+		{token: "secret", authorization: "", expected: http.StatusUnauthorized},
+		{token: "", authorization: "Bearer ", expected: http.StatusForbidden},
+		{token: "", authorization: "", expected: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		actor = ""
+		req := httptest.NewRequest("GET", "/backup", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		responseRecorder := httptest.NewRecorder()
+
+		NewAdminController(test.token).RequireAdmin(handler).ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %q, got %v", test.expected, test.authorization, responseRecorder.Code)
+		}
+		if test.expected == http.StatusNoContent && actor != AdminActor {
+			t.Fatalf("expected changes to be made by %v, got %v", AdminActor, actor)
+		}
+		if test.expected == http.StatusUnauthorized && responseRecorder.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected a WWW-Authenticate header for %q", test.authorization)
+		}
+	}
+}
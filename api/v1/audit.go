@@ -0,0 +1,35 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+type auditLog interface {
+	Query(audit.Filter) []audit.Record
+}
+
+type auditController struct {
+	auditLog
+}
+
+func NewAuditController(auditLog auditLog) *auditController {
+	return &auditController{auditLog}
+}
+
+// GetAuditRecords lists the recorded changes, oldest first, optionally of a
+// single entity with ?entity=trip&id=3 or made by a single ?actor=
+func (auditController *auditController) GetAuditRecords(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	records := auditController.auditLog.Query(audit.Filter{
+		Entity:   query.Get("entity"),
+		EntityId: query.Get("id"),
+		Actor:    query.Get("actor"),
+	})
+
+	body, _ := json.Marshal(records)
+	writeJSON(w, http.StatusOK, body)
+}
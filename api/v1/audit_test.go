@@ -0,0 +1,64 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+type mockAuditLog struct {
+	filter audit.Filter
+}
+
+func (mockAuditLog *mockAuditLog) Query(filter audit.Filter) []audit.Record {
+	mockAuditLog.filter = filter
+	return []audit.Record{{Seq: 1, Entity: filter.Entity, EntityId: filter.EntityId, Action: audit.ActionCreate}}
+}
+
+func TestGetAuditRecords_1(t *testing.T) {
+	auditLog := &mockAuditLog{}
+	auditController := NewAuditController(auditLog)
+
+	req := httptest.NewRequest("GET", "/audit?entity=trip&id=3&actor=customer:1", nil)
+	responseRecorder := httptest.NewRecorder()
+	auditController.GetAuditRecords(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	expected := audit.Filter{Entity: "trip", EntityId: "3", Actor: "customer:1"}
+	if auditLog.filter != expected {
+		t.Fatalf("expected %v, got %v", expected, auditLog.filter)
+	}
+
+	var records []audit.Record
+	json.Unmarshal(responseRecorder.Body.Bytes(), &records)
+	if len(records) != 1 || records[0].EntityId != "3" {
+		t.Fatalf("expected the record of trip 3, got %v", records)
+	}
+}
+
+func TestGetAuditRecords_2(t *testing.T) {
+	handler := newTestRouter(Controllers{})
+
+	// Records hold the names of passengers, so they are only shown to operators
+	req := httptest.NewRequest("GET", "/audit", nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnauthorized, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
@@ -0,0 +1,70 @@
+package api_v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gbandres98/pack-and-go/backup"
+)
+
+// Largest archive accepted by Restore
+const maxArchiveSize = 256 << 20
+
+type backupManager interface {
+	Backup() (backup.Archive, error)
+	Restore(context.Context, backup.Archive) error
+}
+
+type backupController struct {
+	backupManager
+}
+
+func NewBackupController(backupManager backupManager) *backupController {
+	return &backupController{backupManager}
+}
+
+// GetBackup responds with an archive of the stores as they are now
+func (backupController *backupController) GetBackup(w http.ResponseWriter, req *http.Request) {
+	archive, err := backupController.backupManager.Backup()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	err = backup.Write(&body, archive)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="packandgo-%v.backup"`, archive.CreatedAt.Format("20060102T150405Z")))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// Restore replaces the content of the stores with that of the archive in the
+// body, once its checksum and content have been verified
+func (backupController *backupController) Restore(w http.ResponseWriter, req *http.Request) {
+	archive, err := backup.Read(http.MaxBytesReader(w, req.Body, maxArchiveSize))
+	if errors.Is(err, backup.ErrorNewerVersion) || errors.Is(err, backup.ErrorOlderVersion) {
+		http.Error(w, fmt.Sprintf("Unprocessable Entity - %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid archive: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err = backupController.backupManager.Restore(req.Context(), archive)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
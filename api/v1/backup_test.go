@@ -0,0 +1,81 @@
+package api_v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/backup"
+)
+
+type mockBackupManager struct {
+	restored   *backup.Archive
+	restoreErr error
+}
+
+func (mockBackupManager *mockBackupManager) Backup() (backup.Archive, error) {
+	return backup.Archive{Version: backup.Version, CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)}, nil
+}
+
+func (mockBackupManager *mockBackupManager) Restore(ctx context.Context, archive backup.Archive) error {
+	mockBackupManager.restored = &archive
+	return mockBackupManager.restoreErr
+}
+
+func TestGetBackup_1(t *testing.T) {
+	backupController := NewBackupController(&mockBackupManager{})
+
+	req := httptest.NewRequest("GET", "/backup", nil)
+	responseRecorder := httptest.NewRecorder()
+	backupController.GetBackup(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if disposition := responseRecorder.Header().Get("Content-Disposition"); !strings.Contains(disposition, "packandgo-20260301T100000Z.backup") {
+		t.Fatalf("expected the archive to be named after its time, got %v", disposition)
+	}
+	if _, err := backup.Read(responseRecorder.Body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRestore_1(t *testing.T) {
+	var body bytes.Buffer
+	backup.Write(&body, backup.Archive{Version: backup.Version})
+	var newer bytes.Buffer
+	backup.Write(&newer, backup.Archive{Version: backup.Version + 1})
+
+	tests := []struct {
+		body       string
+		restoreErr error
+		code       int
+	}{
+		{body: body.String(), code: http.StatusNoContent},
+		{body: body.String(), restoreErr: errors.New("disk full"), code: http.StatusInternalServerError},
+		{body: body.String()[:body.Len()-1], code: http.StatusBadRequest},
+		{body: "not an archive\n", code: http.StatusBadRequest},
+		{body: newer.String(), code: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		backupManager := &mockBackupManager{restoreErr: test.restoreErr}
+		backupController := NewBackupController(backupManager)
+
+		req := httptest.NewRequest("POST", "/restore", strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+		backupController.Restore(responseRecorder, req)
+
+		if responseRecorder.Code != test.code {
+			t.Fatalf("expected response code to be %v, got %v: %v", test.code, responseRecorder.Code, responseRecorder.Body.String())
+		}
+		if test.code == http.StatusBadRequest && backupManager.restored != nil {
+			t.Fatalf("expected an invalid archive not to be restored")
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package api_v1
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/pricing"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/ticket"
+	"github.com/gorilla/mux"
+)
+
+type bookingService interface {
+	Book(context.Context, model.Customer, service.BookingRequest) (model.Booking, error)
+	GetBooking(int32, int32) (model.Booking, error)
+	GetBookings(int32) []model.Booking
+	RefundQuote(int32, int32) (pricing.Refund, error)
+	Cancel(context.Context, int32, int32) (model.Booking, model.LedgerEntry, error)
+	GetLedger(int32) []model.LedgerEntry
+	GetDepartureBookings(int32, time.Time) ([]service.AffectedBooking, error)
+	VerifyTicket(string) (ticket.Ticket, error)
+	PublicKey() ed25519.PublicKey
+}
+
+type bookingController struct {
+	bookingService
+	now func() time.Time
+}
+
+type bookingRequest struct {
+	TripId      int32  `json:"tripId"`
+	Departure   string `json:"departure"`
+	PassengerId int32  `json:"passengerId"`
+	PromoCode   string `json:"promoCode"`
+}
+
+type cancellationResponse struct {
+	Booking model.Booking     `json:"booking"`
+	Refund  model.LedgerEntry `json:"refund"`
+}
+
+type verifyTicketRequest struct {
+	Ticket string `json:"ticket"`
+}
+
+type verifyTicketResponse struct {
+	Valid  bool           `json:"valid"`
+	Error  string         `json:"error,omitempty"`
+	Ticket *ticket.Ticket `json:"ticket,omitempty"`
+}
+
+type ticketKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+func NewBookingController(bookingService bookingService) *bookingController {
+	return &bookingController{bookingService, time.Now}
+}
+
+// Book takes a seat on a departure for one of the passengers of the logged in customer and issues its ticket
+func (bookingController *bookingController) Book(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var request bookingRequest
+	err := json.Unmarshal(requestBody, &request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid booking json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	departure, err := parseDeparture(request.Departure, bookingController.now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid departure: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	booking, err := bookingController.bookingService.Book(req.Context(), customerFromContext(req), service.BookingRequest{
+		TripId:      request.TripId,
+		Departure:   departure,
+		PassengerId: request.PassengerId,
+		PromoCode:   request.PromoCode,
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, db.ErrorTripNotFound), errors.Is(err, db.ErrorPassengerNotFound), errors.Is(err, service.ErrorDepartureNotAvailable):
+		http.Error(w, fmt.Sprintf("Bad Request - invalid booking: %v", err), http.StatusBadRequest)
+		return
+	case errors.Is(err, db.ErrorDepartureFull):
+		http.Error(w, fmt.Sprintf("Conflict - %v", err), http.StatusConflict)
+		return
+	default:
+		writePromoCodeError(w, request.PromoCode, err)
+		return
+	}
+
+	body, _ := json.Marshal(booking)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+func (bookingController *bookingController) GetBookings(w http.ResponseWriter, req *http.Request) {
+	bookings := bookingController.bookingService.GetBookings(customerFromContext(req).Id)
+
+	body, _ := json.Marshal(bookings)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (bookingController *bookingController) GetBooking(w http.ResponseWriter, req *http.Request) {
+	id, err := parseBookingId(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid booking id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	booking, err := bookingController.bookingService.GetBooking(customerFromContext(req).Id, id)
+	if err == db.ErrorBookingNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no booking found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(booking)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GetRefundQuote responds with the refund of cancelling a booking now, without cancelling it
+func (bookingController *bookingController) GetRefundQuote(w http.ResponseWriter, req *http.Request) {
+	id, err := parseBookingId(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid booking id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	refund, err := bookingController.bookingService.RefundQuote(customerFromContext(req).Id, id)
+	if err != nil {
+		writeCancellationError(w, id, err)
+		return
+	}
+
+	body, _ := json.Marshal(refund)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// CancelBooking cancels a booking of the logged in customer and responds with its refund
+func (bookingController *bookingController) CancelBooking(w http.ResponseWriter, req *http.Request) {
+	id, err := parseBookingId(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid booking id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	booking, refund, err := bookingController.bookingService.Cancel(req.Context(), customerFromContext(req).Id, id)
+	if err != nil {
+		writeCancellationError(w, id, err)
+		return
+	}
+
+	body, _ := json.Marshal(cancellationResponse{Booking: booking, Refund: refund})
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GetLedger lists the refunds of the logged in customer
+func (bookingController *bookingController) GetLedger(w http.ResponseWriter, req *http.Request) {
+	ledger := bookingController.bookingService.GetLedger(customerFromContext(req).Id)
+
+	body, _ := json.Marshal(ledger)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GetDepartureBookings lists the confirmed bookings of a departure with how
+// to contact their customers, for customer service to tell them about disruptions
+func (bookingController *bookingController) GetDepartureBookings(w http.ResponseWriter, req *http.Request) {
+	id, departure, ok := parseDepartureVars(w, req)
+	if !ok {
+		return
+	}
+
+	bookings, err := bookingController.bookingService.GetDepartureBookings(id, departure)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(bookings)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// VerifyTicket checks a ticket code, responding 200 if it is valid and 422 with the reason if it is not
+func (bookingController *bookingController) VerifyTicket(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var request verifyTicketRequest
+	err := json.Unmarshal(requestBody, &request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid ticket json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	verified, err := bookingController.bookingService.VerifyTicket(request.Ticket)
+	switch {
+	case err == nil:
+		body, _ := json.Marshal(verifyTicketResponse{Valid: true, Ticket: &verified})
+		writeJSON(w, http.StatusOK, body)
+	case err == ticket.ErrorMalformed, err == ticket.ErrorSignature:
+		body, _ := json.Marshal(verifyTicketResponse{Valid: false, Error: err.Error()})
+		writeJSON(w, http.StatusUnprocessableEntity, body)
+	case err == ticket.ErrorExpired, err == service.ErrorTicketRevoked:
+		body, _ := json.Marshal(verifyTicketResponse{Valid: false, Error: err.Error(), Ticket: &verified})
+		writeJSON(w, http.StatusUnprocessableEntity, body)
+	default:
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+	}
+}
+
+// GetTicketKey responds with the public key that verifies ticket signatures, for offline verifiers
+func (bookingController *bookingController) GetTicketKey(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(ticketKeyResponse{
+		Algorithm: "Ed25519",
+		PublicKey: ticket.EncodePublicKey(bookingController.bookingService.PublicKey()),
+	})
+	writeJSON(w, http.StatusOK, body)
+}
+
+func parseBookingId(req *http.Request) (int32, error) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 32)
+	return int32(id), err
+}
+
+func writeCancellationError(w http.ResponseWriter, id int32, err error) {
+	switch err {
+	case db.ErrorBookingNotFound:
+		http.Error(w, fmt.Sprintf("Not Found - no booking found with id: %v", id), http.StatusNotFound)
+	case db.ErrorBookingCancelled:
+		http.Error(w, fmt.Sprintf("Conflict - %v", err), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+	}
+}
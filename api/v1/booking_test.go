@@ -0,0 +1,304 @@
+package api_v1
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/pricing"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/ticket"
+	"github.com/gorilla/mux"
+)
+
+var testTicketKey = ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+
+var testBooking = model.Booking{Id: 1, CustomerId: 1, TripId: 2, PassengerId: 1, Seat: 1, Price: 40.55, Status: model.BookingConfirmed, Ticket: "PG1:VALID"}
+
+type mockBookingService struct {
+	request   service.BookingRequest
+	cancelled bool
+}
+
+func (mockBookingService *mockBookingService) Book(ctx context.Context, customer model.Customer, request service.BookingRequest) (model.Booking, error) {
+	mockBookingService.request = request
+
+	switch {
+	case request.TripId != 2:
+		return model.Booking{}, db.ErrorTripNotFound
+	case request.PassengerId != 1:
+		return model.Booking{}, db.ErrorPassengerNotFound
+	case request.PromoCode == "EXPIRED":
+		return model.Booking{}, service.ErrorPromoCodeNotApplicable
+	case request.Departure.Day() == 14:
+		return model.Booking{}, db.ErrorDepartureFull
+	}
+
+	booking := testBooking
+	booking.CustomerId = customer.Id
+	booking.Departure = request.Departure
+	return booking, nil
+}
+
+func (mockBookingService *mockBookingService) GetBooking(customerId int32, id int32) (model.Booking, error) {
+	if id != testBooking.Id || customerId != testBooking.CustomerId {
+		return model.Booking{}, db.ErrorBookingNotFound
+	}
+
+	return testBooking, nil
+}
+
+func (mockBookingService *mockBookingService) GetBookings(customerId int32) []model.Booking {
+	return []model.Booking{testBooking}
+}
+
+func (mockBookingService *mockBookingService) RefundQuote(customerId int32, id int32) (pricing.Refund, error) {
+	_, err := mockBookingService.GetBooking(customerId, id)
+	if err != nil {
+		return pricing.Refund{}, err
+	}
+
+	return pricing.Refund{Policy: "default", HoursBefore: 24, Percent: 50, Amount: 20.28}, nil
+}
+
+func (mockBookingService *mockBookingService) Cancel(ctx context.Context, customerId int32, id int32) (model.Booking, model.LedgerEntry, error) {
+	booking, err := mockBookingService.GetBooking(customerId, id)
+	if err != nil {
+		return model.Booking{}, model.LedgerEntry{}, err
+	}
+	if mockBookingService.cancelled {
+		return model.Booking{}, model.LedgerEntry{}, db.ErrorBookingCancelled
+	}
+
+	mockBookingService.cancelled = true
+	booking.Status = model.BookingCancelled
+	return booking, model.LedgerEntry{Id: 1, BookingId: id, CustomerId: customerId, Type: model.LedgerRefund, Amount: 20.28}, nil
+}
+
+func (mockBookingService *mockBookingService) GetLedger(customerId int32) []model.LedgerEntry {
+	return []model.LedgerEntry{}
+}
+
+func (mockBookingService *mockBookingService) GetDepartureBookings(tripId int32, departure time.Time) ([]service.AffectedBooking, error) {
+	if tripId != testBooking.TripId {
+		return []service.AffectedBooking{}, nil
+	}
+
+	return []service.AffectedBooking{{Booking: testBooking, CustomerName: testCustomer.Name, CustomerEmail: testCustomer.Email}}, nil
+}
+
+func (mockBookingService *mockBookingService) VerifyTicket(code string) (ticket.Ticket, error) {
+	switch code {
+	case "PG1:VALID":
+		return ticket.Ticket{BookingId: 1}, nil
+	case "PG1:CANCELLED":
+		return ticket.Ticket{BookingId: 1}, service.ErrorTicketRevoked
+	default:
+		return ticket.Ticket{}, ticket.ErrorSignature
+	}
+}
+
+func (mockBookingService *mockBookingService) PublicKey() ed25519.PublicKey {
+	return testTicketKey.Public().(ed25519.PublicKey)
+}
+
+func (mockBookingService *mockBookingService) LoadFactor(trip model.Trip, departure time.Time) float64 {
+	return 0
+}
+
+func newTestBookingController(bookingService bookingService) http.Handler {
+	bookingController := NewBookingController(bookingService)
+	bookingController.now = func() time.Time { return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) }
+
+	router := mux.NewRouter()
+	customerController := NewCustomerController(&mockCustomerService{})
+	router.Handle("/customer/me/booking", customerController.RequireSession(http.HandlerFunc(bookingController.Book))).Methods(http.MethodPost)
+	router.Handle("/customer/me/booking/{id}", customerController.RequireSession(http.HandlerFunc(bookingController.GetBooking))).Methods(http.MethodGet)
+	router.Handle("/customer/me/booking/{id}/refund", customerController.RequireSession(http.HandlerFunc(bookingController.GetRefundQuote))).Methods(http.MethodGet)
+	router.Handle("/customer/me/booking/{id}/cancel", customerController.RequireSession(http.HandlerFunc(bookingController.CancelBooking))).Methods(http.MethodPost)
+	return router
+}
+
+func TestBook_1(t *testing.T) {
+	bookingService := &mockBookingService{}
+	handler := newTestBookingController(bookingService)
+
+	req := httptest.NewRequest("POST", "/customer/me/booking", strings.NewReader(`{"tripId": 2, "departure": "2026-03-07", "passengerId": 1}`))
+	req.Header.Set("Authorization", "Bearer token")
+	responseRecorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+	if bookingService.request.Departure.Hour() != 8 || bookingService.request.Departure.Location().String() != "Europe/Madrid" {
+		t.Fatalf("expected departure at 08:00 Europe/Madrid, got %v", bookingService.request.Departure)
+	}
+
+	var booking model.Booking
+	json.Unmarshal(responseRecorder.Body.Bytes(), &booking)
+	if booking.Ticket == "" || booking.CustomerId != testCustomer.Id {
+		t.Fatalf("expected booking with a ticket for customer %v, got %v", testCustomer.Id, booking)
+	}
+}
+
+func TestBook_2(t *testing.T) {
+	handler := newTestBookingController(&mockBookingService{})
+
+	tests := []struct {
+		body     string
+		token    string
+		expected int
+	}{
+		{body: `{"tripId": 2, "departure": "2026-03-07", "passengerId": 1}`, token: "other", expected: http.StatusUnauthorized},
+		{body: `{"tripId": 3, "departure": "2026-03-07", "passengerId": 1}`, token: "token", expected: http.StatusBadRequest},
+		{body: `{"tripId": 2, "departure": "2026-03-07", "passengerId": 2}`, token: "token", expected: http.StatusBadRequest},
+		{body: `{"tripId": 2, "departure": "07/03/2026", "passengerId": 1}`, token: "token", expected: http.StatusBadRequest},
+		{body: `{"tripId": 2, "departure": "2026-03-14", "passengerId": 1}`, token: "token", expected: http.StatusConflict},
+		{body: `{"tripId": 2, "departure": "2026-03-07", "passengerId": 1, "promoCode": "EXPIRED"}`, token: "token", expected: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/customer/me/booking", strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.body, responseRecorder.Code)
+		}
+	}
+}
+
+func TestGetBooking_1(t *testing.T) {
+	handler := newTestBookingController(&mockBookingService{})
+
+	for id, expected := range map[string]int{"1": http.StatusOK, "2": http.StatusNotFound, "x": http.StatusBadRequest} {
+		req := httptest.NewRequest("GET", "/customer/me/booking/"+id, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != expected {
+			t.Fatalf("expected response code to be %v for booking %v, got %v", expected, id, responseRecorder.Code)
+		}
+	}
+}
+
+func TestVerifyTicket_1(t *testing.T) {
+	bookingController := NewBookingController(&mockBookingService{})
+
+	tests := []struct {
+		code     string
+		expected int
+		valid    bool
+	}{
+		{code: "PG1:VALID", expected: http.StatusOK, valid: true},
+		{code: "PG1:CANCELLED", expected: http.StatusUnprocessableEntity, valid: false},
+		{code: "PG1:TAMPERED", expected: http.StatusUnprocessableEntity, valid: false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/ticket/verify", strings.NewReader(`{"ticket": "`+test.code+`"}`))
+		responseRecorder := httptest.NewRecorder()
+
+		bookingController.VerifyTicket(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.code, responseRecorder.Code)
+		}
+
+		var response verifyTicketResponse
+		json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+		if response.Valid != test.valid {
+			t.Fatalf("expected valid to be %v for %v, got %v", test.valid, test.code, response.Valid)
+		}
+	}
+}
+
+func TestGetTicketKey_1(t *testing.T) {
+	bookingController := NewBookingController(&mockBookingService{})
+
+	req := httptest.NewRequest("GET", "/ticket/key", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	bookingController.GetTicketKey(responseRecorder, req)
+
+	var response ticketKeyResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	publicKey, err := ticket.DecodePublicKey(response.PublicKey)
+	if err != nil || !publicKey.Equal(testTicketKey.Public()) {
+		t.Fatalf("expected the ticket public key, got %v %v", response.PublicKey, err)
+	}
+}
+
+func TestCancelBooking_1(t *testing.T) {
+	handler := newTestBookingController(&mockBookingService{})
+
+	req := httptest.NewRequest("GET", "/customer/me/booking/1/refund", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	responseRecorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var refund pricing.Refund
+	json.Unmarshal(responseRecorder.Body.Bytes(), &refund)
+	if refund.Percent != 50 || refund.Amount != 20.28 {
+		t.Fatalf("expected 50%% refund of %v, got %v", 20.28, refund)
+	}
+
+	req = httptest.NewRequest("POST", "/customer/me/booking/1/cancel", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	responseRecorder = httptest.NewRecorder()
+
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var response cancellationResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if response.Booking.Status != model.BookingCancelled || response.Refund.Amount != 20.28 {
+		t.Fatalf("expected cancelled booking refunded %v, got %v", 20.28, response)
+	}
+}
+
+func TestCancelBooking_2(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/customer/me/booking/x/cancel", expected: http.StatusBadRequest},
+		{path: "/customer/me/booking/2/cancel", expected: http.StatusNotFound},
+		{path: "/customer/me/booking/1/cancel", expected: http.StatusConflict},
+	}
+
+	handler := newTestBookingController(&mockBookingService{cancelled: true})
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v: expected response code to be %v, got %v", test.path, test.expected, responseRecorder.Code)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package api_v1
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/ical"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gorilla/mux"
+)
+
+// GetTripCalendar responds with the schedule of a trip as an iCalendar feed
+func (tripController *tripController) GetTripCalendar(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return
+	}
+
+	trip, err := tripController.tripService.GetTripById(id)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	tripController.writeCalendar(w, fmt.Sprintf("trip-%v.ics", trip.Id), []model.Trip{trip})
+}
+
+// GetRouteCalendar responds with the schedule of every trip between two cities as an iCalendar feed
+func (tripController *tripController) GetRouteCalendar(w http.ResponseWriter, req *http.Request) {
+	originId, err := strconv.ParseInt(mux.Vars(req)["originId"], 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid origin id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	destinationId, err := strconv.ParseInt(mux.Vars(req)["destinationId"], 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid destination id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	allTrips, err := tripController.tripService.GetAllTrips()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	trips := []model.Trip{}
+	for _, trip := range allTrips {
+		if trip.OriginId == int32(originId) && trip.DestinationId == int32(destinationId) {
+			trips = append(trips, trip)
+		}
+	}
+
+	if len(trips) == 0 {
+		http.Error(w, fmt.Sprintf("Not Found - no trips found from city %v to city %v", originId, destinationId), http.StatusNotFound)
+		return
+	}
+
+	tripController.writeCalendar(w, fmt.Sprintf("route-%v-%v.ics", originId, destinationId), trips)
+}
+
+func (tripController *tripController) writeCalendar(w http.ResponseWriter, fileName string, trips []model.Trip) {
+	calendar := ical.Calendar{}
+
+	for _, trip := range trips {
+		tripPretty, err := tripController.tripService.GetTripPretty(trip)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		weekdays, err := model.ParseDates(trip.Dates)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - trip %v: %v", trip.Id, err), http.StatusInternalServerError)
+			return
+		}
+
+		calendar.Name = fmt.Sprintf("%v - %v", tripPretty.Origin, tripPretty.Destination)
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         fmt.Sprintf("trip-%v@packandgo", trip.Id),
+			Summary:     fmt.Sprintf("%v - %v", tripPretty.Origin, tripPretty.Destination),
+			Location:    tripPretty.Origin,
+			Description: fmt.Sprintf("Trip %v from %v to %v, price %v", trip.Id, tripPretty.Origin, tripPretty.Destination, tripPretty.Price),
+			Weekdays:    weekdays,
+			StartTime:   model.DefaultDepartureTime,
+			Duration:    model.DefaultTravelTime,
+		})
+	}
+
+	var body bytes.Buffer
+	err := ical.Write(&body, calendar, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, fileName))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,109 @@
+package api_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetTripCalendar_1(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/2/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetTripCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if !strings.HasPrefix(responseRecorder.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("expected Content-Type to be %v, got %v", "text/calendar", responseRecorder.Header().Get("Content-Type"))
+	}
+
+	result := responseRecorder.Body.String()
+	for _, expected := range []string{"UID:trip-2@packandgo", "RRULE:FREQ=WEEKLY;BYDAY=SA,SU", "SUMMARY:Sevilla - Madrid", "BEGIN:VTIMEZONE"} {
+		if !strings.Contains(result, expected) {
+			t.Fatalf("expected %v to include %v", result, expected)
+		}
+	}
+}
+
+func TestGetTripCalendar_2(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/3/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetTripCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestGetTripCalendar_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{failGetTripPretty: true})
+
+	req := httptest.NewRequest("GET", "/trip/1/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetTripCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusInternalServerError, responseRecorder.Code)
+	}
+}
+
+func TestGetRouteCalendar_1(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/route/1/2/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"originId": "1", "destinationId": "2"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetRouteCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	result := responseRecorder.Body.String()
+	if strings.Count(result, "BEGIN:VEVENT") != 1 || !strings.Contains(result, "UID:trip-1@packandgo") {
+		t.Fatalf("expected calendar to only include trip 1, got %v", result)
+	}
+}
+
+func TestGetRouteCalendar_2(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/route/1/5/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"originId": "1", "destinationId": "5"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetRouteCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestGetRouteCalendar_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/route/a/5/calendar.ics", nil)
+	req = mux.SetURLVars(req, map[string]string{"originId": "a", "destinationId": "5"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetRouteCalendar(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
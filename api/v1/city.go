@@ -0,0 +1,179 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+// Radius used by near lookups that do not set one
+const defaultNearRadiusKm = 50
+
+type cityController struct {
+	cityService
+}
+
+func NewCityController(cityService cityService) *cityController {
+	return &cityController{cityService}
+}
+
+// GetCities lists every city, or with near=lat,lon the cities within radius
+// km of that point, closest first
+func (cityController *cityController) GetCities(w http.ResponseWriter, req *http.Request) {
+	near := req.URL.Query().Get("near")
+	if near == "" {
+		cities, err := cityController.cityService.GetAllCities()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := json.Marshal(cities)
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+
+	latitude, longitude, err := parseCoordinates(near)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid near: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	radius := float64(defaultNearRadiusKm)
+	if radiusParam := req.URL.Query().Get("radius"); radiusParam != "" {
+		radius, err = strconv.ParseFloat(radiusParam, 64)
+		if err != nil || radius < 0 {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid radius: %v", radiusParam), http.StatusBadRequest)
+			return
+		}
+	}
+
+	cities, err := cityController.cityService.GetCitiesNear(latitude, longitude, radius)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(cities)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (cityController *cityController) GetCityById(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseCityId(w, req)
+	if !ok {
+		return
+	}
+
+	city, err := cityController.cityService.GetCityById(id)
+	if err == db.ErrorCityNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no city found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(city)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// AddCity adds a city, which gets the next id
+func (cityController *cityController) AddCity(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var newCity model.City
+	err := json.Unmarshal(requestBody, &newCity)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid city json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedCity, err := cityController.cityService.AddCity(req.Context(), newCity)
+	if errors.Is(err, service.ErrorCityExists) {
+		http.Error(w, fmt.Sprintf("Conflict - %v", err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid city: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedCity)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// UpdateCity replaces a city, such as to rename it. Cities keep their id, so
+// their trips are kept too.
+func (cityController *cityController) UpdateCity(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseCityId(w, req)
+	if !ok {
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var city model.City
+	err := json.Unmarshal(requestBody, &city)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid city json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	city.Id = id
+	savedCity, err := cityController.cityService.UpdateCity(req.Context(), city)
+	if err == db.ErrorCityNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no city found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrorCityExists) {
+		http.Error(w, fmt.Sprintf("Conflict - %v", err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid city: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedCity)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func parseCityId(w http.ResponseWriter, req *http.Request) (int32, bool) {
+	idVar := mux.Vars(req)["id"]
+	id, err := strconv.ParseInt(idVar, 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid city id: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return int32(id), true
+}
+
+func parseCoordinates(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected lat,lon, got %v", value)
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, fmt.Errorf("invalid latitude: %v", parts[0])
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, fmt.Errorf("invalid longitude: %v", parts[1])
+	}
+
+	return latitude, longitude, nil
+}
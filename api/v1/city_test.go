@@ -0,0 +1,176 @@
+package api_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetCities_1(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	req := httptest.NewRequest("GET", "/city", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCities(responseRecorder, req)
+
+	expected := `[{"id":1,"name":"Sevilla"},{"id":2,"name":"Madrid"}]`
+	result := strings.TrimSpace(responseRecorder.Body.String())
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if result != expected {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestGetCities_2(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	req := httptest.NewRequest("GET", "/city?near=40.4,-3.7&radius=150", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCities(responseRecorder, req)
+
+	expected := `[{"id":2,"name":"Madrid","distanceKm":10.5}]`
+	result := strings.TrimSpace(responseRecorder.Body.String())
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if result != expected {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestGetCities_3(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	req := httptest.NewRequest("GET", "/city?near=40.4,-3.7", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCities(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if strings.TrimSpace(responseRecorder.Body.String()) != "[]" {
+		t.Fatalf("expected %v, got %v", "[]", responseRecorder.Body.String())
+	}
+}
+
+func TestGetCities_4(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	for _, query := range []string{"near=40.4", "near=north,-3.7", "near=95,-3.7", "near=40.4,-3.7&radius=-1"} {
+		req := httptest.NewRequest("GET", "/city?"+query, nil)
+		responseRecorder := httptest.NewRecorder()
+
+		cityController.GetCities(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected response code for %v to be %v, got %v", query, http.StatusBadRequest, responseRecorder.Code)
+		}
+	}
+}
+
+func TestGetCities_5(t *testing.T) {
+	cityController := NewCityController(&mockCityService{failGetAllCities: true})
+
+	req := httptest.NewRequest("GET", "/city", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCities(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusInternalServerError, responseRecorder.Code)
+	}
+}
+
+func TestGetCityById_1(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	req := httptest.NewRequest("GET", "/city/2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCityById(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if strings.TrimSpace(responseRecorder.Body.String()) != `{"id":2,"name":"Madrid"}` {
+		t.Fatalf("expected %v, got %v", `{"id":2,"name":"Madrid"}`, responseRecorder.Body.String())
+	}
+}
+
+func TestGetCityById_2(t *testing.T) {
+	cityController := NewCityController(&mockCityService{})
+
+	req := httptest.NewRequest("GET", "/city/7", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+	responseRecorder := httptest.NewRecorder()
+
+	cityController.GetCityById(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestAddCity_1(t *testing.T) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{body: `{"name":"Valencia","latitude":39.47,"longitude":-0.38}`, code: http.StatusCreated},
+		{body: `{"name":"Madrid"}`, code: http.StatusConflict},
+		{body: `{"name":`, code: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		cityController := NewCityController(&mockCityService{})
+
+		req := httptest.NewRequest("POST", "/city", strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+		cityController.AddCity(responseRecorder, req)
+
+		if responseRecorder.Code != test.code {
+			t.Fatalf("expected response code to be %v, got %v", test.code, responseRecorder.Code)
+		}
+	}
+}
+
+func TestUpdateCity_1(t *testing.T) {
+	tests := []struct {
+		id       string
+		body     string
+		code     int
+		expected string
+	}{
+		{id: "2", body: `{"id":5,"name":"Madrid de los Austrias"}`, code: http.StatusOK, expected: `{"id":2,"name":"Madrid de los Austrias"}`},
+		{id: "3", body: `{"name":"Valencia"}`, code: http.StatusNotFound},
+		{id: "2", body: `{"name":""}`, code: http.StatusBadRequest},
+		{id: "two", body: `{"name":"Madrid"}`, code: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		cityController := NewCityController(&mockCityService{})
+
+		req := httptest.NewRequest("PUT", "/city/"+test.id, strings.NewReader(test.body))
+		req = mux.SetURLVars(req, map[string]string{"id": test.id})
+		responseRecorder := httptest.NewRecorder()
+		cityController.UpdateCity(responseRecorder, req)
+
+		if responseRecorder.Code != test.code {
+			t.Fatalf("expected response code to be %v, got %v", test.code, responseRecorder.Code)
+		}
+		if result := strings.TrimSpace(responseRecorder.Body.String()); test.expected != "" && result != test.expected {
+			t.Fatalf("expected %v, got %v", test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package api_v1
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// tripETag identifies a version of a trip, and is what clients have to send
+// back in If-Match to update it. Disruptions are shown with trips but are not
+// part of their version, so trips that have any get a tag that also covers them.
+func tripETag(trip model.Trip, disruptions []model.Disruption) string {
+	if len(disruptions) == 0 {
+		return fmt.Sprintf(`"%v-%v"`, trip.Id, trip.Version)
+	}
+
+	body, _ := json.Marshal(disruptions)
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%v-%v-%x"`, trip.Id, trip.Version, sum[:4])
+}
+
+// tripLastModified is the last time a trip or its disruptions changed
+func tripLastModified(trip model.Trip, disruptions []model.Disruption) time.Time {
+	lastModified := trip.UpdatedAt
+	for _, disruption := range disruptions {
+		if disruption.CreatedAt.After(lastModified) {
+			lastModified = disruption.CreatedAt
+		}
+	}
+
+	return lastModified
+}
+
+// bodyETag identifies a response body that is not tied to a single versioned entity
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+func setValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// isNotModified evaluates If-None-Match and, when it is not present,
+// If-Modified-Since, as described in RFC 7232
+func isNotModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchesETag(ifNoneMatch, etag, true)
+	}
+
+	ifModifiedSince := req.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// matchesETag reports whether etag is included in a list of entity tags as
+// sent in If-Match or If-None-Match headers
+func matchesETag(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter, etag string, lastModified time.Time) {
+	setValidators(w, etag, lastModified)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
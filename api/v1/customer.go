@@ -0,0 +1,287 @@
+package api_v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+type customerService interface {
+	Register(model.Customer, string) (model.Customer, error)
+	Login(string, string) (string, model.Session, error)
+	Logout(string) error
+	Authenticate(string) (model.Customer, error)
+	GetPassengers(int32) []model.Passenger
+	GetPassenger(int32, int32) (model.Passenger, error)
+	AddPassenger(int32, model.Passenger) (model.Passenger, error)
+	UpdatePassenger(int32, int32, model.Passenger) (model.Passenger, error)
+	DeletePassenger(int32, int32) error
+}
+
+type customerContextKey struct{}
+
+type customerController struct {
+	customerService
+}
+
+type registrationRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type sessionResponse struct {
+	Token     string         `json:"token"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+	Customer  model.Customer `json:"customer"`
+}
+
+func NewCustomerController(customerService customerService) *customerController {
+	return &customerController{customerService}
+}
+
+// RequireSession only lets through requests with the token of a session in an
+// Authorization: Bearer header, making its customer available to the handler
+func (customerController *customerController) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := bearerToken(req)
+		if !ok {
+			writeUnauthorized(w, "a session token is required")
+			return
+		}
+
+		customer, err := customerController.customerService.Authenticate(token)
+		if err == db.ErrorSessionNotFound || err == db.ErrorCustomerNotFound {
+			writeUnauthorized(w, "invalid or expired session token")
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(req.Context(), customerContextKey{}, customer)
+		ctx = audit.WithActor(ctx, customerActor(customer.Id))
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func (customerController *customerController) Register(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var registration registrationRequest
+	err := json.Unmarshal(requestBody, &registration)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid customer json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	customer, err := customerController.customerService.Register(model.Customer{Email: registration.Email, Name: registration.Name}, registration.Password)
+	if err == db.ErrorCustomerExists {
+		http.Error(w, "Conflict - a customer with this email already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid customer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(customer)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// Login starts a session, responding with the token to send in the Authorization header of later requests
+func (customerController *customerController) Login(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var login loginRequest
+	err := json.Unmarshal(requestBody, &login)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid login json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	token, session, err := customerController.customerService.Login(login.Email, login.Password)
+	if err == service.ErrorInvalidCredentials {
+		writeUnauthorized(w, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	customer, err := customerController.customerService.Authenticate(token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(sessionResponse{Token: token, ExpiresAt: session.ExpiresAt, Customer: customer})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, body)
+}
+
+func (customerController *customerController) Logout(w http.ResponseWriter, req *http.Request) {
+	token, _ := bearerToken(req)
+
+	err := customerController.customerService.Logout(token)
+	if err != nil && err != db.ErrorSessionNotFound {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (customerController *customerController) GetCurrentCustomer(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(customerFromContext(req))
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (customerController *customerController) GetPassengers(w http.ResponseWriter, req *http.Request) {
+	passengers := customerController.customerService.GetPassengers(customerFromContext(req).Id)
+
+	body, _ := json.Marshal(passengers)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (customerController *customerController) GetPassenger(w http.ResponseWriter, req *http.Request) {
+	id, ok := parsePassengerId(w, req)
+	if !ok {
+		return
+	}
+
+	passenger, err := customerController.customerService.GetPassenger(customerFromContext(req).Id, id)
+	if err == db.ErrorPassengerNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no passenger found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(passenger)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (customerController *customerController) AddPassenger(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var passenger model.Passenger
+	err := json.Unmarshal(requestBody, &passenger)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid passenger json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedPassenger, err := customerController.customerService.AddPassenger(customerFromContext(req).Id, passenger)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid passenger: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedPassenger)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+func (customerController *customerController) UpdatePassenger(w http.ResponseWriter, req *http.Request) {
+	id, ok := parsePassengerId(w, req)
+	if !ok {
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var passenger model.Passenger
+	err := json.Unmarshal(requestBody, &passenger)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid passenger json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedPassenger, err := customerController.customerService.UpdatePassenger(customerFromContext(req).Id, id, passenger)
+	if err == db.ErrorPassengerNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no passenger found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid passenger: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedPassenger)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (customerController *customerController) DeletePassenger(w http.ResponseWriter, req *http.Request) {
+	id, ok := parsePassengerId(w, req)
+	if !ok {
+		return
+	}
+
+	err := customerController.customerService.DeletePassenger(customerFromContext(req).Id, id)
+	if err == db.ErrorPassengerNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no passenger found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// customerFromContext returns the customer authenticated by RequireSession
+func customerFromContext(req *http.Request) model.Customer {
+	customer, _ := req.Context().Value(customerContextKey{}).(model.Customer)
+	return customer
+}
+
+// customerActor returns the actor of the changes made by a customer
+func customerActor(id int32) string {
+	return fmt.Sprintf("customer:%v", id)
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(authorization[7:])
+	return token, token != ""
+}
+
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="packandgo"`)
+	http.Error(w, fmt.Sprintf("Unauthorized - %v", message), http.StatusUnauthorized)
+}
+
+func parsePassengerId(w http.ResponseWriter, req *http.Request) (int32, bool) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid passenger id: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return int32(id), true
+}
@@ -0,0 +1,215 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+var testCustomer = model.Customer{Id: 1, Email: "alice@example.com", Name: "Alice", PasswordHash: "$2a$10$c2FsdGVkaGFzaG9mYWxpY2UuLi4uLi4uLi4uLi4uLi4uLi4u"}
+
+var testPassengers = []model.Passenger{
+	{Id: 1, CustomerId: 1, FirstName: "Alice", LastName: "Smith"},
+	{Id: 2, CustomerId: 2, FirstName: "Bob", LastName: "Jones"},
+}
+
+type mockCustomerService struct{}
+
+func (mockCustomerService *mockCustomerService) Register(customer model.Customer, password string) (model.Customer, error) {
+	if customer.Email == testCustomer.Email {
+		return model.Customer{}, db.ErrorCustomerExists
+	}
+	if len(password) < 8 {
+		return model.Customer{}, service.ErrorInvalidCredentials
+	}
+
+	customer.Id = 2
+	customer.PasswordHash = "hash"
+	return customer, nil
+}
+
+func (mockCustomerService *mockCustomerService) Login(email string, password string) (string, model.Session, error) {
+	if email != testCustomer.Email || password != "correct horse" {
+		return "", model.Session{}, service.ErrorInvalidCredentials
+	}
+
+	return "token", model.Session{CustomerId: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (mockCustomerService *mockCustomerService) Logout(token string) error {
+	return nil
+}
+
+func (mockCustomerService *mockCustomerService) Authenticate(token string) (model.Customer, error) {
+	if token != "token" {
+		return model.Customer{}, db.ErrorSessionNotFound
+	}
+
+	return testCustomer, nil
+}
+
+func (mockCustomerService *mockCustomerService) GetPassengers(customerId int32) []model.Passenger {
+	return []model.Passenger{testPassengers[customerId-1]}
+}
+
+func (mockCustomerService *mockCustomerService) GetPassenger(customerId int32, id int32) (model.Passenger, error) {
+	for _, passenger := range testPassengers {
+		if passenger.Id == id && passenger.CustomerId == customerId {
+			return passenger, nil
+		}
+	}
+
+	return model.Passenger{}, db.ErrorPassengerNotFound
+}
+
+func (mockCustomerService *mockCustomerService) AddPassenger(customerId int32, passenger model.Passenger) (model.Passenger, error) {
+	passenger.Id = 3
+	passenger.CustomerId = customerId
+	return passenger, nil
+}
+
+func (mockCustomerService *mockCustomerService) UpdatePassenger(customerId int32, id int32, passenger model.Passenger) (model.Passenger, error) {
+	_, err := mockCustomerService.GetPassenger(customerId, id)
+	return passenger, err
+}
+
+func (mockCustomerService *mockCustomerService) DeletePassenger(customerId int32, id int32) error {
+	_, err := mockCustomerService.GetPassenger(customerId, id)
+	return err
+}
+
+func TestRegister_1(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{body: `{"email": "bob@example.com", "name": "Bob", "password": "correct horse"}`, expected: http.StatusCreated},
+		{body: `{"email": "alice@example.com", "name": "Alice", "password": "correct horse"}`, expected: http.StatusConflict},
+		{body: `{"email": "bob@example.com", "password": "short"}`, expected: http.StatusBadRequest},
+		{body: `{"email": `, expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/customer", strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+
+		customerController.Register(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.body, responseRecorder.Code)
+		}
+		if strings.Contains(responseRecorder.Body.String(), "hash") {
+			t.Fatalf("expected password hash not to be included, got %v", responseRecorder.Body.String())
+		}
+	}
+}
+
+func TestLogin_1(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+
+	req := httptest.NewRequest("POST", "/session", strings.NewReader(`{"email": "alice@example.com", "password": "correct horse"}`))
+	responseRecorder := httptest.NewRecorder()
+
+	customerController.Login(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+
+	var session sessionResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &session)
+	if session.Token != "token" || session.Customer.Id != 1 {
+		t.Fatalf("expected token for customer 1, got %v", session)
+	}
+}
+
+func TestLogin_2(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+
+	req := httptest.NewRequest("POST", "/session", strings.NewReader(`{"email": "alice@example.com", "password": "wrong horse"}`))
+	responseRecorder := httptest.NewRecorder()
+
+	customerController.Login(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnauthorized, responseRecorder.Code)
+	}
+}
+
+func TestRequireSession_1(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+	handler := customerController.RequireSession(http.HandlerFunc(customerController.GetCurrentCustomer))
+
+	for _, authorization := range []string{"", "token", "Basic token", "Bearer ", "Bearer other"} {
+		req := httptest.NewRequest("GET", "/customer/me", nil)
+		req.Header.Set("Authorization", authorization)
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusUnauthorized {
+			t.Fatalf("expected response code to be %v for %q, got %v", http.StatusUnauthorized, authorization, responseRecorder.Code)
+		}
+		if responseRecorder.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected WWW-Authenticate header")
+		}
+	}
+}
+
+func TestRequireSession_2(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+	handler := customerController.RequireSession(http.HandlerFunc(customerController.GetCurrentCustomer))
+
+	req := httptest.NewRequest("GET", "/customer/me", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	responseRecorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var customer model.Customer
+	json.Unmarshal(responseRecorder.Body.Bytes(), &customer)
+	if customer.Id != 1 {
+		t.Fatalf("expected customer 1, got %v", customer)
+	}
+}
+
+func TestGetPassenger_1(t *testing.T) {
+	customerController := NewCustomerController(&mockCustomerService{})
+	handler := customerController.RequireSession(http.HandlerFunc(customerController.GetPassenger))
+
+	tests := []struct {
+		id       string
+		expected int
+	}{
+		{id: "1", expected: http.StatusOK},
+		{id: "2", expected: http.StatusNotFound},
+		{id: "x", expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/customer/me/passenger/"+test.id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": test.id})
+		req.Header.Set("Authorization", "Bearer token")
+		responseRecorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for passenger %v, got %v", test.expected, test.id, responseRecorder.Code)
+		}
+	}
+}
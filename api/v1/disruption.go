@@ -0,0 +1,169 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+// Days listed by departure lookups that do not set them, and the most they can ask for
+const defaultDepartureDays = 14
+const maxDepartureDays = 366
+
+type disruptionRequest struct {
+	Status       string `json:"status"`
+	DelayMinutes int    `json:"delayMinutes"`
+	Reason       string `json:"reason"`
+}
+
+// GetAllDisruptions lists the disruptions of every trip that have not expired
+func (tripController *tripController) GetAllDisruptions(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(tripController.tripService.GetAllDisruptions())
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GetDepartures lists the departures of a trip for days days from a date,
+// today by default, with their disruptions
+func (tripController *tripController) GetDepartures(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return
+	}
+
+	from := req.URL.Query().Get("from")
+	if from == "" {
+		from = scheduleDate(tripController.now())
+	}
+	departure, err := parseDepartureDay(from)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid from: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	days := defaultDepartureDays
+	if daysParam := req.URL.Query().Get("days"); daysParam != "" {
+		days, err = strconv.Atoi(daysParam)
+		if err != nil || days < 1 || days > maxDepartureDays {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid days, expected 1 to %v: %v", maxDepartureDays, daysParam), http.StatusBadRequest)
+			return
+		}
+	}
+
+	departures, err := tripController.tripService.GetDepartures(id, departure, days)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(departures)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (tripController *tripController) GetDeparture(w http.ResponseWriter, req *http.Request) {
+	id, departure, ok := parseDepartureVars(w, req)
+	if !ok {
+		return
+	}
+
+	result, err := tripController.tripService.GetDeparture(id, departure)
+	if err != nil {
+		writeDepartureError(w, id, err)
+		return
+	}
+
+	body, _ := json.Marshal(result)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// PostDisruption marks a departure as cancelled or delayed, replacing its previous disruption
+func (tripController *tripController) PostDisruption(w http.ResponseWriter, req *http.Request) {
+	id, departure, ok := parseDepartureVars(w, req)
+	if !ok {
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var request disruptionRequest
+	err := json.Unmarshal(requestBody, &request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid disruption json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	disruption, err := tripController.tripService.PostDisruption(id, departure, model.Disruption{
+		Status:       request.Status,
+		DelayMinutes: request.DelayMinutes,
+		Reason:       request.Reason,
+	})
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid disruption: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(disruption)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// ClearDisruption puts a disrupted departure back on schedule
+func (tripController *tripController) ClearDisruption(w http.ResponseWriter, req *http.Request) {
+	id, departure, ok := parseDepartureVars(w, req)
+	if !ok {
+		return
+	}
+
+	err := tripController.tripService.ClearDisruption(id, departure)
+	if err == db.ErrorDisruptionNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - departure of trip %v on %v is not disrupted", id, mux.Vars(req)["date"]), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseDepartureVars(w http.ResponseWriter, req *http.Request) (int32, time.Time, bool) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+
+	departure, err := parseDepartureDay(mux.Vars(req)["date"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid departure date: %v", err), http.StatusBadRequest)
+		return 0, time.Time{}, false
+	}
+
+	return id, departure, true
+}
+
+func writeDepartureError(w http.ResponseWriter, id int32, err error) {
+	switch {
+	case err == db.ErrorTripNotFound:
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+	case errors.Is(err, service.ErrorDepartureNotAvailable):
+		http.Error(w, fmt.Sprintf("Not Found - %v", err), http.StatusNotFound)
+	default:
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,257 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+func (mockTripService *mockTripService) GetDisruptions(tripId int32) []model.Disruption {
+	result := []model.Disruption{}
+	for _, disruption := range mockTripService.disruptions {
+		if disruption.TripId == tripId {
+			result = append(result, disruption)
+		}
+	}
+
+	return result
+}
+
+func (mockTripService *mockTripService) GetAllDisruptions() []model.Disruption {
+	return append([]model.Disruption{}, mockTripService.disruptions...)
+}
+
+func (mockTripService *mockTripService) PostDisruption(tripId int32, departure time.Time, disruption model.Disruption) (model.Disruption, error) {
+	if tripId > 2 {
+		return model.Disruption{}, db.ErrorTripNotFound
+	}
+	if disruption.Reason == "" {
+		return model.Disruption{}, errors.New("missing reason")
+	}
+
+	disruption.TripId = tripId
+	disruption.Date = departure.Format("2006-01-02")
+	disruption.CreatedAt = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	mockTripService.disruptions = append(mockTripService.disruptions, disruption)
+	return disruption, nil
+}
+
+func (mockTripService *mockTripService) ClearDisruption(tripId int32, departure time.Time) error {
+	for i, disruption := range mockTripService.disruptions {
+		if disruption.TripId == tripId && disruption.Date == departure.Format("2006-01-02") {
+			mockTripService.disruptions = append(mockTripService.disruptions[:i], mockTripService.disruptions[i+1:]...)
+			return nil
+		}
+	}
+
+	return db.ErrorDisruptionNotFound
+}
+
+func (mockTripService *mockTripService) GetDeparture(tripId int32, departure time.Time) (model.Departure, error) {
+	trip, err := mockTripService.GetTripById(tripId)
+	if err != nil {
+		return model.Departure{}, err
+	}
+	if !trip.RunsOn(departure.Weekday()) {
+		return model.Departure{}, fmt.Errorf("%w: trip %v does not run on %v", service.ErrorDepartureNotAvailable, tripId, departure.Weekday())
+	}
+
+	return model.Departure{TripId: tripId, Date: departure.Format("2006-01-02"), Departure: departure, Status: model.DepartureScheduled}, nil
+}
+
+func (mockTripService *mockTripService) GetDepartures(tripId int32, from time.Time, days int) ([]model.Departure, error) {
+	departures := []model.Departure{}
+	for i := 0; i < days; i++ {
+		departure, err := mockTripService.GetDeparture(tripId, from.AddDate(0, 0, i))
+		if err == db.ErrorTripNotFound {
+			return nil, err
+		}
+		if err == nil {
+			departures = append(departures, departure)
+		}
+	}
+
+	return departures, nil
+}
+
+func newTestDisruptionRouter(tripService tripService) http.Handler {
+	tripController := NewTripController(tripService)
+	tripController.now = func() time.Time { return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) }
+
+	return newTestRouter(Controllers{Trip: tripController})
+}
+
+func TestPostDisruption_1(t *testing.T) {
+	handler := newTestDisruptionRouter(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/1", nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+	scheduledETag := responseRecorder.Header().Get("ETag")
+
+	// Customers could otherwise cancel any departure
+	req = httptest.NewRequest("PUT", "/trip/1/departure/2026-03-03/disruption", strings.NewReader(`{"status": "cancelled", "reason": "Strike"}`))
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnauthorized, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/trip/1/departure/2026-03-03/disruption", strings.NewReader(`{"status": "cancelled", "reason": "Strike"}`))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/trip/1", nil)
+	req.Header.Set("If-None-Match", scheduledETag)
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if etag := responseRecorder.Header().Get("ETag"); etag == scheduledETag {
+		t.Fatalf("expected ETag to change with the disruption, got %v", etag)
+	}
+
+	var tripPretty model.TripPretty
+	json.Unmarshal(responseRecorder.Body.Bytes(), &tripPretty)
+	if len(tripPretty.Disruptions) != 1 || tripPretty.Disruptions[0].Reason != "Strike" {
+		t.Fatalf("expected the disruption to be shown with the trip, got %v", tripPretty.Disruptions)
+	}
+
+	req = httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId": 1, "destinationId": 2, "dates": "Mon", "price": 10}`))
+	req.Header.Set("If-Match", scheduledETag)
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected update with the version ETag to succeed, got %v", responseRecorder.Code)
+	}
+}
+
+func TestPostDisruption_2(t *testing.T) {
+	tests := []struct {
+		path     string
+		body     string
+		expected int
+	}{
+		{path: "/trip/3/departure/2026-03-03/disruption", body: `{"status": "cancelled", "reason": "Strike"}`, expected: http.StatusNotFound},
+		{path: "/trip/1/departure/03-03-2026/disruption", body: `{"status": "cancelled", "reason": "Strike"}`, expected: http.StatusBadRequest},
+		{path: "/trip/1/departure/2026-03-03/disruption", body: `{"status": "cancelled"}`, expected: http.StatusBadRequest},
+		{path: "/trip/1/departure/2026-03-03/disruption", body: `{"status": `, expected: http.StatusBadRequest},
+	}
+
+	handler := newTestDisruptionRouter(&mockTripService{})
+
+	for _, test := range tests {
+		req := httptest.NewRequest("PUT", test.path, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v %v: expected response code to be %v, got %v", test.path, test.body, test.expected, responseRecorder.Code)
+		}
+	}
+}
+
+func TestClearDisruption_1(t *testing.T) {
+	handler := newTestDisruptionRouter(&mockTripService{disruptions: []model.Disruption{{TripId: 1, Date: "2026-03-03", Status: model.DisruptionCancelled}}})
+
+	for _, expected := range []int{http.StatusUnauthorized, http.StatusNoContent, http.StatusNotFound} {
+		req := httptest.NewRequest("DELETE", "/trip/1/departure/2026-03-03/disruption", nil)
+		if expected != http.StatusUnauthorized {
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		}
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != expected {
+			t.Fatalf("expected response code to be %v, got %v", expected, responseRecorder.Code)
+		}
+	}
+}
+
+func TestGetDepartures_1(t *testing.T) {
+	handler := newTestDisruptionRouter(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/2/departure", nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var departures []model.Departure
+	json.Unmarshal(responseRecorder.Body.Bytes(), &departures)
+	if len(departures) != 4 || departures[0].Date != "2026-03-07" {
+		t.Fatalf("expected the 4 weekend departures from 2026-03-07, got %v", departures)
+	}
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/trip/2/departure?from=2026-03-07&days=1", expected: http.StatusOK},
+		{path: "/trip/2/departure?days=0", expected: http.StatusBadRequest},
+		{path: "/trip/2/departure?days=1000", expected: http.StatusBadRequest},
+		{path: "/trip/2/departure?from=tomorrow", expected: http.StatusBadRequest},
+		{path: "/trip/3/departure", expected: http.StatusNotFound},
+		{path: "/trip/2/departure/2026-03-07", expected: http.StatusOK},
+		{path: "/trip/2/departure/2026-03-06", expected: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v: expected response code to be %v, got %v", test.path, test.expected, responseRecorder.Code)
+		}
+	}
+}
+
+func TestGetDepartureBookings_1(t *testing.T) {
+	handler := newTestDisruptionRouter(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/2/departure/2026-03-07/booking", nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	// The contact details of customers are only shown to operators
+	if responseRecorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnauthorized, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/trip/2/departure/2026-03-07/booking", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	responseRecorder = httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var bookings []service.AffectedBooking
+	json.Unmarshal(responseRecorder.Body.Bytes(), &bookings)
+	if len(bookings) != 1 || bookings[0].Id != testBooking.Id || bookings[0].CustomerEmail != testCustomer.Email {
+		t.Fatalf("expected booking %v of %v, got %v", testBooking.Id, testCustomer.Email, bookings)
+	}
+}
@@ -0,0 +1,132 @@
+package api_v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+)
+
+// How long clients wait before reconnecting once a stream ends, and how often
+// a comment is sent on idle streams so proxies do not close them. Streams
+// shorter than twice the keep-alive send it twice as often as they last.
+const eventsRetry = time.Second
+const eventsKeepAlive = 15 * time.Second
+
+// Sent instead of the replay when the events after Last-Event-ID are no
+// longer buffered, so clients know to reload what they show
+const eventReset = "reset"
+
+type eventHub interface {
+	Subscribe(int64) *events.Subscription
+}
+
+type eventsController struct {
+	eventHub
+	// Streams end after this long, 0 for never. Clients reconnect and resume
+	// from the last event they got, so none are lost.
+	maxDuration time.Duration
+	keepAlive   time.Duration
+}
+
+func NewEventsController(eventHub eventHub, maxDuration time.Duration) *eventsController {
+	keepAlive := eventsKeepAlive
+	if maxDuration > 0 && maxDuration/2 < keepAlive {
+		keepAlive = maxDuration / 2
+	}
+
+	return &eventsController{eventHub, maxDuration, keepAlive}
+}
+
+// GetEvents streams domain events as server-sent events. Clients resume
+// from the event in the Last-Event-ID header, or the lastEventId query
+// parameter, and can keep to some event types with types=a,b.
+func (eventsController *eventsController) GetEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Internal Server Error - streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventIdParam := req.Header.Get("Last-Event-ID")
+	if lastEventIdParam == "" {
+		lastEventIdParam = req.URL.Query().Get("lastEventId")
+	}
+
+	var lastEventId int64
+	if lastEventIdParam != "" {
+		var err error
+		lastEventId, err = strconv.ParseInt(lastEventIdParam, 10, 64)
+		if err != nil || lastEventId < 0 {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid last event id: %v", lastEventIdParam), http.StatusBadRequest)
+			return
+		}
+	}
+
+	types := map[string]bool{}
+	if typesParam := req.URL.Query().Get("types"); typesParam != "" {
+		for _, eventType := range strings.Split(typesParam, ",") {
+			types[strings.TrimSpace(eventType)] = true
+		}
+	}
+	wanted := func(event events.Event) bool {
+		return len(types) == 0 || types[event.Type]
+	}
+
+	subscription := eventsController.eventHub.Subscribe(lastEventId)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %v\n\n", eventsRetry.Milliseconds())
+
+	if subscription.Complete {
+		for _, event := range subscription.Replay {
+			if wanted(event) {
+				writeEvent(w, event)
+			}
+		}
+	} else {
+		fmt.Fprintf(w, "id: %v\nevent: %v\ndata: {}\n\n", subscription.LastId, eventReset)
+	}
+	flusher.Flush()
+
+	var deadline <-chan time.Time
+	if eventsController.maxDuration > 0 {
+		timer := time.NewTimer(eventsController.maxDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	keepAlive := time.NewTicker(eventsController.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if wanted(event) {
+				writeEvent(w, event)
+				flusher.Flush()
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-deadline:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) {
+	fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.Id, event.Type, event.Data)
+}
@@ -0,0 +1,123 @@
+package api_v1
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+)
+
+func newTestEventHub() eventHub {
+	hub := events.NewHub(2)
+	hub.Publish(events.TripCreated, map[string]int{"id": 1})
+	hub.Publish(events.TripUpdated, map[string]int{"id": 1})
+	hub.Publish(events.DisruptionPosted, map[string]int{"tripId": 1})
+	return hub
+}
+
+func TestGetEvents_1(t *testing.T) {
+	tests := []struct {
+		path        string
+		lastEventId string
+		expected    string
+		unexpected  string
+	}{
+		{path: "/events", lastEventId: "2", expected: "id: 3\nevent: disruption.posted\ndata: {\"tripId\":1}\n\n", unexpected: "id: 2\n"},
+		{path: "/events?lastEventId=1", expected: "id: 2\nevent: trip.updated\n", unexpected: "event: reset"},
+		{path: "/events?types=trip.created,trip.updated", lastEventId: "1", expected: "event: trip.updated", unexpected: "disruption.posted"},
+		{path: "/events", lastEventId: "0", expected: "retry: 1000\n\n", unexpected: "id: "},
+		{path: "/events", lastEventId: "9", expected: "id: 3\nevent: reset\ndata: {}\n\n", unexpected: "trip.updated"},
+	}
+
+	eventsController := NewEventsController(newTestEventHub(), time.Millisecond)
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		if test.lastEventId != "" {
+			req.Header.Set("Last-Event-ID", test.lastEventId)
+		}
+		responseRecorder := httptest.NewRecorder()
+
+		eventsController.GetEvents(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusOK {
+			t.Fatalf("%v: expected response code to be %v, got %v", test.path, http.StatusOK, responseRecorder.Code)
+		}
+		if contentType := responseRecorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Fatalf("%v: expected Content-Type to be %v, got %v", test.path, "text/event-stream", contentType)
+		}
+		body := responseRecorder.Body.String()
+		if !strings.Contains(body, test.expected) || strings.Contains(body, test.unexpected) {
+			t.Fatalf("%v %v: expected %q without %q, got %q", test.path, test.lastEventId, test.expected, test.unexpected, body)
+		}
+	}
+}
+
+func TestGetEvents_2(t *testing.T) {
+	eventsController := NewEventsController(newTestEventHub(), time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "three")
+	responseRecorder := httptest.NewRecorder()
+
+	eventsController.GetEvents(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
+
+func TestGetEvents_3(t *testing.T) {
+	hub := events.NewHub(10)
+	server := httptest.NewServer(newTestRouter(Controllers{Events: NewEventsController(hub, 0)}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer response.Body.Close()
+
+	reader := bufio.NewReader(response.Body)
+	// The retry line is flushed once subscribed, so the event is not missed
+	reader.ReadString('\n')
+
+	hub.Publish(events.TripDeleted, map[string]int{"id": 2})
+
+	for _, expected := range []string{"\n", "id: 1\n", "event: trip.deleted\n", "data: {\"id\":2}\n", "\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil || line != expected {
+			t.Fatalf("expected %q, got %q (%v)", expected, line, err)
+		}
+	}
+
+	hub.Close()
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Fatalf("expected the stream to end when the hub is closed")
+	}
+}
+
+func TestGetEvents_4(t *testing.T) {
+	// Streams end before the write timeout of the server, and idle ones are
+	// kept alive in the meantime
+	server := httptest.NewUnstartedServer(newTestRouter(Controllers{Events: NewEventsController(events.NewHub(10), 200*time.Millisecond)}))
+	server.Config.WriteTimeout = 300 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil || !strings.Contains(string(body), ": keep-alive\n\n") {
+		t.Fatalf("expected a keep-alive before the stream ended, got %q (%v)", body, err)
+	}
+}
@@ -0,0 +1,82 @@
+package api_v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/gtfs"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Largest GTFS zip file accepted by ImportFeed
+const maxFeedSize = 64 << 20
+
+type cityService interface {
+	GetAllCities() ([]model.City, error)
+	GetCityById(int32) (model.City, error)
+	GetCityByName(string) (model.City, bool, error)
+	AddCity(context.Context, model.City) (model.City, error)
+	UpdateCity(context.Context, model.City) (model.City, error)
+	GetCitiesNear(float64, float64, float64) ([]model.CityNearby, error)
+}
+
+type gtfsController struct {
+	cityService
+	tripService
+}
+
+func NewGTFSController(cityService cityService, tripService tripService) *gtfsController {
+	return &gtfsController{cityService, tripService}
+}
+
+// GetFeed responds with a static GTFS feed of the whole timetable
+func (gtfsController *gtfsController) GetFeed(w http.ResponseWriter, req *http.Request) {
+	cities, err := gtfsController.cityService.GetAllCities()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	trips, err := gtfsController.tripService.GetAllTrips()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var feed bytes.Buffer
+	err = gtfs.Export(&feed, cities, trips, gtfs.DefaultOptions(time.Now()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="gtfs.zip"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(feed.Bytes())
+}
+
+// ImportFeed creates cities and trips from a static GTFS zip file in the request body
+func (gtfsController *gtfsController) ImportFeed(w http.ResponseWriter, req *http.Request) {
+	feed, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxFeedSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - could not read gtfs feed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	importer := gtfs.NewImporter(gtfsController.cityService, gtfsController.tripService)
+
+	report, err := importer.Import(req.Context(), bytes.NewReader(feed), int64(len(feed)))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(report)
+	writeJSON(w, http.StatusOK, body)
+}
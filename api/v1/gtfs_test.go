@@ -0,0 +1,158 @@
+package api_v1
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/gtfs"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+var testCities = []model.City{
+	{Id: 1, Name: "Sevilla"},
+	{Id: 2, Name: "Madrid"},
+}
+
+type mockCityService struct {
+	failGetAllCities bool
+}
+
+func (mockCityService *mockCityService) GetAllCities() ([]model.City, error) {
+	if mockCityService.failGetAllCities {
+		return nil, errors.New("test error")
+	}
+
+	return testCities, nil
+}
+
+func (mockCityService *mockCityService) GetCityById(id int32) (model.City, error) {
+	if id > 0 && id < 3 {
+		return testCities[id-1], nil
+	}
+
+	return model.City{}, db.ErrorCityNotFound
+}
+
+func (mockCityService *mockCityService) GetCityByName(name string) (model.City, bool, error) {
+	for _, city := range testCities {
+		if city.Name == name {
+			return city, true, nil
+		}
+	}
+
+	return model.City{}, false, nil
+}
+
+func (mockCityService *mockCityService) AddCity(ctx context.Context, city model.City) (model.City, error) {
+	if city.Name == "Madrid" {
+		return model.City{}, service.ErrorCityExists
+	}
+
+	city.Id = 3
+	return city, nil
+}
+
+func (mockCityService *mockCityService) UpdateCity(ctx context.Context, city model.City) (model.City, error) {
+	if city.Id < 1 || city.Id > 2 {
+		return model.City{}, db.ErrorCityNotFound
+	}
+	if city.Name == "" {
+		return model.City{}, errors.New("city name can not be empty")
+	}
+
+	return city, nil
+}
+
+func (mockCityService *mockCityService) GetCitiesNear(latitude float64, longitude float64, radiusKm float64) ([]model.CityNearby, error) {
+	if radiusKm < 100 {
+		return []model.CityNearby{}, nil
+	}
+
+	return []model.CityNearby{{City: testCities[1], DistanceKm: 10.5}}, nil
+}
+
+func TestGetFeed_1(t *testing.T) {
+	gtfsController := NewGTFSController(&mockCityService{}, &mockTripService{})
+
+	req := httptest.NewRequest("GET", "/gtfs.zip", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	gtfsController.GetFeed(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if responseRecorder.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("expected Content-Type to be %v, got %v", "application/zip", responseRecorder.Header().Get("Content-Type"))
+	}
+
+	body := responseRecorder.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(archive.File) != 6 {
+		t.Fatalf("expected feed to have %v files, got %v", 6, len(archive.File))
+	}
+}
+
+func TestGetFeed_2(t *testing.T) {
+	gtfsController := NewGTFSController(&mockCityService{failGetAllCities: true}, &mockTripService{})
+
+	req := httptest.NewRequest("GET", "/gtfs.zip", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	gtfsController.GetFeed(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusInternalServerError, responseRecorder.Code)
+	}
+}
+
+func TestImportFeed_1(t *testing.T) {
+	gtfsController := NewGTFSController(&mockCityService{}, &mockTripService{})
+
+	req := httptest.NewRequest("GET", "/gtfs.zip", nil)
+	responseRecorder := httptest.NewRecorder()
+	gtfsController.GetFeed(responseRecorder, req)
+
+	req = httptest.NewRequest("POST", "/gtfs/import", bytes.NewReader(responseRecorder.Body.Bytes()))
+	responseRecorder = httptest.NewRecorder()
+
+	gtfsController.ImportFeed(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var report gtfs.ImportReport
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.CitiesMatched != 2 || report.TripsCreated != 2 {
+		t.Fatalf("expected 2 cities matched and 2 trips created, got %v", report)
+	}
+}
+
+func TestImportFeed_2(t *testing.T) {
+	gtfsController := NewGTFSController(&mockCityService{}, &mockTripService{})
+
+	req := httptest.NewRequest("POST", "/gtfs/import", strings.NewReader("not a zip file"))
+	responseRecorder := httptest.NewRecorder()
+
+	gtfsController.ImportFeed(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/ical"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/pricing"
+)
+
+type pricingEngine interface {
+	Quote(request pricing.Request) pricing.Quote
+}
+
+type seatInventory interface {
+	LoadFactor(model.Trip, time.Time) float64
+}
+
+type pricingController struct {
+	tripService
+	pricingEngine
+	promoService
+	seatInventory
+	now func() time.Time
+}
+
+type quoteResponse struct {
+	TripId    int32          `json:"tripId"`
+	Departure time.Time      `json:"departure"`
+	BasePrice float64        `json:"basePrice"`
+	Price     float64        `json:"price"`
+	PromoCode string         `json:"promoCode,omitempty"`
+	Discount  float64        `json:"discount,omitempty"`
+	Steps     []pricing.Step `json:"steps,omitempty"`
+}
+
+func NewPricingController(tripService tripService, pricingEngine pricingEngine, promoService promoService, seatInventory seatInventory) *pricingController {
+	return &pricingController{tripService, pricingEngine, promoService, seatInventory, time.Now}
+}
+
+// GetQuote responds with the fare of a trip for the departure date and passenger
+// age in the query, with the discount of a promo code if there is one
+func (pricingController *pricingController) GetQuote(w http.ResponseWriter, req *http.Request) {
+	pricingController.writeQuote(w, req, false)
+}
+
+// ExplainQuote responds with the fare of a trip along with every rule evaluated to compute it
+func (pricingController *pricingController) ExplainQuote(w http.ResponseWriter, req *http.Request) {
+	pricingController.writeQuote(w, req, true)
+}
+
+func (pricingController *pricingController) writeQuote(w http.ResponseWriter, req *http.Request, explain bool) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return
+	}
+
+	trip, err := pricingController.tripService.GetTripById(id)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	now := pricingController.now()
+	departure, err := parseDeparture(req.URL.Query().Get("departure"), now)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid departure: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !trip.RunsOn(departure.Weekday()) {
+		http.Error(w, fmt.Sprintf("Bad Request - trip %v does not run on %v", id, departure.Weekday()), http.StatusBadRequest)
+		return
+	}
+
+	age := 0
+	if ageParam := req.URL.Query().Get("age"); ageParam != "" {
+		age, err = strconv.Atoi(ageParam)
+		if err != nil || age < 0 || age > 150 {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid age: %v", ageParam), http.StatusBadRequest)
+			return
+		}
+	}
+
+	quote := pricingController.pricingEngine.Quote(pricing.Request{
+		Trip:         trip,
+		Departure:    departure,
+		PurchaseTime: now,
+		LoadFactor:   pricingController.seatInventory.LoadFactor(trip, departure),
+		PassengerAge: age,
+	})
+
+	response := quoteResponse{
+		TripId:    trip.Id,
+		Departure: departure,
+		BasePrice: quote.BasePrice,
+		Price:     quote.Price,
+	}
+
+	if code := req.URL.Query().Get("promo"); code != "" {
+		promoCode, err := pricingController.promoService.CheckPromoCode(code, req.URL.Query().Get("customer"), trip, departure)
+		if err != nil {
+			writePromoCodeError(w, code, err)
+			return
+		}
+
+		response.PromoCode = promoCode.Code
+		response.Discount = promoCode.Discount(quote.Price)
+		response.Price = math.Round((quote.Price-response.Discount)*100) / 100
+		quote.Steps = append(quote.Steps, pricing.Step{
+			Rule:        "promo code " + promoCode.Code,
+			Fired:       true,
+			Reason:      fmt.Sprintf("%v off", response.Discount),
+			PriceBefore: quote.Price,
+			PriceAfter:  response.Price,
+		})
+	}
+	if explain {
+		response.Steps = quote.Steps
+	}
+
+	body, _ := json.Marshal(response)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// parseDeparture returns the departure time of a YYYY-MM-DD date in the schedule
+// time zone. Departures already gone cannot be quoted.
+func parseDeparture(date string, now time.Time) (time.Time, error) {
+	departure, err := parseDepartureDay(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if departure.Before(now) {
+		return time.Time{}, fmt.Errorf("departure %v is in the past", date)
+	}
+
+	return departure, nil
+}
+
+// parseDepartureDay returns the departure time of trips on a YYYY-MM-DD date
+// of the schedule time zone, which may be in the past
+func parseDepartureDay(date string) (time.Time, error) {
+	location, err := time.LoadLocation(ical.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", date, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a YYYY-MM-DD date, got %q", date)
+	}
+
+	// Built from the wall clock, as days when the clocks change are not 24 hours long
+	clock := model.DefaultDepartureTime
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, location), nil
+}
+
+// scheduleDate returns the YYYY-MM-DD date of t in the schedule time zone
+func scheduleDate(t time.Time) string {
+	if location, err := time.LoadLocation(ical.Timezone); err == nil {
+		t = t.In(location)
+	}
+
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,167 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/pricing"
+	"github.com/gorilla/mux"
+)
+
+var testPricingConfig = pricing.Config{
+	Rules: []pricing.Rule{
+		{Name: "weekend", Type: pricing.RuleWeekday, Weekdays: "Sat Sun", Adjustment: pricing.Adjustment{Percent: 20}},
+	},
+}
+
+type mockPricingEngine struct {
+	request pricing.Request
+}
+
+func (mockPricingEngine *mockPricingEngine) Quote(request pricing.Request) pricing.Quote {
+	mockPricingEngine.request = request
+	return pricing.Evaluate(testPricingConfig, request)
+}
+
+func newTestPricingController(pricingEngine pricingEngine) *pricingController {
+	pricingController := NewPricingController(&mockTripService{}, pricingEngine, &mockPromoService{}, &mockBookingService{})
+	// Monday
+	pricingController.now = func() time.Time { return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) }
+	return pricingController
+}
+
+func quoteRequest(id string, query string) *http.Request {
+	req := httptest.NewRequest("GET", "/trip/"+id+"/quote?"+query, nil)
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestGetQuote_1(t *testing.T) {
+	pricingEngine := &mockPricingEngine{}
+	pricingController := newTestPricingController(pricingEngine)
+	responseRecorder := httptest.NewRecorder()
+
+	pricingController.GetQuote(responseRecorder, quoteRequest("2", "departure=2026-03-07&age=30"))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var response quoteResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if response.Price != 48.66 || response.BasePrice != 40.55 {
+		t.Fatalf("expected price %v from %v, got %v from %v", 48.66, 40.55, response.Price, response.BasePrice)
+	}
+	if response.Steps != nil {
+		t.Fatalf("expected no steps, got %v", response.Steps)
+	}
+	if pricingEngine.request.PassengerAge != 30 {
+		t.Fatalf("expected passenger age %v, got %v", 30, pricingEngine.request.PassengerAge)
+	}
+	if pricingEngine.request.Departure.Hour() != 8 || pricingEngine.request.Departure.Location().String() != "Europe/Madrid" {
+		t.Fatalf("expected departure at 08:00 Europe/Madrid, got %v", pricingEngine.request.Departure)
+	}
+}
+
+func TestGetQuote_2(t *testing.T) {
+	pricingController := newTestPricingController(&mockPricingEngine{})
+
+	tests := []struct {
+		id       string
+		query    string
+		expected int
+	}{
+		{id: "3", query: "departure=2026-03-07", expected: http.StatusNotFound},
+		{id: "2", query: "", expected: http.StatusBadRequest},
+		{id: "2", query: "departure=07/03/2026", expected: http.StatusBadRequest},
+		{id: "2", query: "departure=2026-02-28", expected: http.StatusBadRequest},
+		{id: "2", query: "departure=2026-03-06", expected: http.StatusBadRequest},
+		{id: "2", query: "departure=2026-03-07&age=old", expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		responseRecorder := httptest.NewRecorder()
+		pricingController.GetQuote(responseRecorder, quoteRequest(test.id, test.query))
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.query, responseRecorder.Code)
+		}
+	}
+}
+
+func TestExplainQuote_1(t *testing.T) {
+	pricingController := newTestPricingController(&mockPricingEngine{})
+	responseRecorder := httptest.NewRecorder()
+
+	pricingController.ExplainQuote(responseRecorder, quoteRequest("1", "departure=2026-03-03"))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var response quoteResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if response.Price != 40.55 {
+		t.Fatalf("expected price %v, got %v", 40.55, response.Price)
+	}
+	if len(response.Steps) != 1 || response.Steps[0].Rule != "weekend" || response.Steps[0].Fired {
+		t.Fatalf("expected weekend rule not to fire, got %v", response.Steps)
+	}
+}
+
+func TestGetQuote_3(t *testing.T) {
+	pricingController := newTestPricingController(&mockPricingEngine{})
+	responseRecorder := httptest.NewRecorder()
+
+	pricingController.ExplainQuote(responseRecorder, quoteRequest("2", "departure=2026-03-07&promo=SUMMER10"))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var response quoteResponse
+	json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if response.PromoCode != "SUMMER10" || response.Discount != 4.87 || response.Price != 43.79 {
+		t.Fatalf("expected %v off to %v with %v, got %v off to %v with %v", 4.87, 43.79, "SUMMER10", response.Discount, response.Price, response.PromoCode)
+	}
+	if len(response.Steps) != 2 || response.Steps[1].Rule != "promo code SUMMER10" {
+		t.Fatalf("expected promo code step, got %v", response.Steps)
+	}
+}
+
+func TestGetQuote_4(t *testing.T) {
+	pricingController := newTestPricingController(&mockPricingEngine{})
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{query: "departure=2026-03-07&promo=EXPIRED", expected: http.StatusUnprocessableEntity},
+		{query: "departure=2026-03-07&promo=WINTER", expected: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		responseRecorder := httptest.NewRecorder()
+		pricingController.GetQuote(responseRecorder, quoteRequest("2", test.query))
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.query, responseRecorder.Code)
+		}
+	}
+}
+
+func TestParseDepartureDay_1(t *testing.T) {
+	// Clocks change in the schedule time zone on the last Sundays of March and October
+	for _, date := range []string{"2026-03-07", "2026-03-29", "2026-10-25"} {
+		departure, err := parseDepartureDay(date)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if departure.Format("2006-01-02 15:04") != date+" 08:00" {
+			t.Fatalf("expected departure at %v 08:00, got %v", date, departure)
+		}
+	}
+}
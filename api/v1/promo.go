@@ -0,0 +1,133 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+type promoService interface {
+	GetAllPromoCodes() []model.PromoCode
+	GetPromoCode(string) (model.PromoCode, error)
+	AddPromoCode(model.PromoCode) (model.PromoCode, error)
+	UpdatePromoCode(string, model.PromoCode) (model.PromoCode, error)
+	DeletePromoCode(string) error
+	CheckPromoCode(string, string, model.Trip, time.Time) (model.PromoCode, error)
+}
+
+type promoController struct {
+	promoService
+	tripService
+	now func() time.Time
+}
+
+func NewPromoController(promoService promoService, tripService tripService) *promoController {
+	return &promoController{promoService, tripService, time.Now}
+}
+
+func (promoController *promoController) GetAllPromoCodes(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(promoController.promoService.GetAllPromoCodes())
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (promoController *promoController) GetPromoCode(w http.ResponseWriter, req *http.Request) {
+	code := mux.Vars(req)["code"]
+
+	promoCode, err := promoController.promoService.GetPromoCode(code)
+	if err == db.ErrorPromoCodeNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no promo code found: %v", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(promoCode)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (promoController *promoController) AddPromoCode(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var newPromoCode model.PromoCode
+	err := json.Unmarshal(requestBody, &newPromoCode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid promo code json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedPromoCode, err := promoController.promoService.AddPromoCode(newPromoCode)
+	if err == db.ErrorPromoCodeExists {
+		http.Error(w, fmt.Sprintf("Conflict - promo code already exists: %v", newPromoCode.Code), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid promo code: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedPromoCode)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// UpdatePromoCode replaces the settings of a promo code, keeping the times it has been redeemed
+func (promoController *promoController) UpdatePromoCode(w http.ResponseWriter, req *http.Request) {
+	code := mux.Vars(req)["code"]
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var promoCode model.PromoCode
+	err := json.Unmarshal(requestBody, &promoCode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid promo code json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedPromoCode, err := promoController.promoService.UpdatePromoCode(code, promoCode)
+	if err == db.ErrorPromoCodeNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no promo code found: %v", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid promo code: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedPromoCode)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (promoController *promoController) DeletePromoCode(w http.ResponseWriter, req *http.Request) {
+	code := mux.Vars(req)["code"]
+
+	err := promoController.promoService.DeletePromoCode(code)
+	if err == db.ErrorPromoCodeNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no promo code found: %v", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writePromoCodeError(w http.ResponseWriter, code string, err error) {
+	switch {
+	case errors.Is(err, db.ErrorPromoCodeNotFound):
+		http.Error(w, fmt.Sprintf("Not Found - no promo code found: %v", code), http.StatusNotFound)
+	case errors.Is(err, service.ErrorPromoCodeNotApplicable), errors.Is(err, db.ErrorPromoCodeExhausted), errors.Is(err, db.ErrorPromoCodeCustomerLimit):
+		http.Error(w, fmt.Sprintf("Unprocessable Entity - %v", err), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+	}
+}
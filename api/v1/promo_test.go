@@ -0,0 +1,193 @@
+package api_v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gorilla/mux"
+)
+
+var testPromoCodes = []model.PromoCode{
+	{Code: "SUMMER10", Percent: 10},
+	{Code: "EXPIRED", Amount: 5},
+}
+
+type mockPromoService struct{}
+
+func (mockPromoService *mockPromoService) GetAllPromoCodes() []model.PromoCode {
+	return testPromoCodes
+}
+
+func (mockPromoService *mockPromoService) GetPromoCode(code string) (model.PromoCode, error) {
+	for _, promoCode := range testPromoCodes {
+		if promoCode.Code == code {
+			return promoCode, nil
+		}
+	}
+
+	return model.PromoCode{}, db.ErrorPromoCodeNotFound
+}
+
+func (mockPromoService *mockPromoService) AddPromoCode(promoCode model.PromoCode) (model.PromoCode, error) {
+	if promoCode.Code == "SUMMER10" {
+		return model.PromoCode{}, db.ErrorPromoCodeExists
+	}
+	if promoCode.Percent == 0 && promoCode.Amount == 0 {
+		return model.PromoCode{}, fmt.Errorf("invalid discount")
+	}
+
+	return promoCode, nil
+}
+
+func (mockPromoService *mockPromoService) UpdatePromoCode(code string, promoCode model.PromoCode) (model.PromoCode, error) {
+	current, err := mockPromoService.GetPromoCode(code)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+
+	promoCode.Code = current.Code
+	return promoCode, nil
+}
+
+func (mockPromoService *mockPromoService) DeletePromoCode(code string) error {
+	_, err := mockPromoService.GetPromoCode(code)
+	return err
+}
+
+func (mockPromoService *mockPromoService) CheckPromoCode(code string, customer string, trip model.Trip, departure time.Time) (model.PromoCode, error) {
+	if code == "EXPIRED" {
+		return model.PromoCode{}, fmt.Errorf("%w: expired", service.ErrorPromoCodeNotApplicable)
+	}
+
+	return mockPromoService.GetPromoCode(code)
+}
+
+func newTestPromoController(promoService promoService) *promoController {
+	promoController := NewPromoController(promoService, &mockTripService{})
+	promoController.now = func() time.Time { return time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC) }
+	return promoController
+}
+
+func TestGetPromoCode_1(t *testing.T) {
+	promoController := newTestPromoController(&mockPromoService{})
+
+	req := httptest.NewRequest("GET", "/promo/SUMMER10", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "SUMMER10"})
+	responseRecorder := httptest.NewRecorder()
+
+	promoController.GetPromoCode(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
+
+func TestGetPromoCode_2(t *testing.T) {
+	promoController := newTestPromoController(&mockPromoService{})
+
+	req := httptest.NewRequest("GET", "/promo/WINTER", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "WINTER"})
+	responseRecorder := httptest.NewRecorder()
+
+	promoController.GetPromoCode(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestAddPromoCode_1(t *testing.T) {
+	promoController := newTestPromoController(&mockPromoService{})
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{body: `{"code": "WINTER", "amount": 5}`, expected: http.StatusCreated},
+		{body: `{"code": "SUMMER10", "percent": 10}`, expected: http.StatusConflict},
+		{body: `{"code": "WINTER"}`, expected: http.StatusBadRequest},
+		{body: `{"code": `, expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/promo", strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+
+		promoController.AddPromoCode(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.expected, test.body, responseRecorder.Code)
+		}
+	}
+}
+
+func TestUpdatePromoCode_1(t *testing.T) {
+	promoController := newTestPromoController(&mockPromoService{})
+
+	req := httptest.NewRequest("PUT", "/promo/WINTER", strings.NewReader(`{"amount": 5}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "WINTER"})
+	responseRecorder := httptest.NewRecorder()
+
+	promoController.UpdatePromoCode(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestDeletePromoCode_1(t *testing.T) {
+	promoController := newTestPromoController(&mockPromoService{})
+
+	req := httptest.NewRequest("DELETE", "/promo/SUMMER10", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "SUMMER10"})
+	responseRecorder := httptest.NewRecorder()
+
+	promoController.DeletePromoCode(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNoContent {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNoContent, responseRecorder.Code)
+	}
+}
+
+func TestPromoRoutes_1(t *testing.T) {
+	handler := newTestRouter(Controllers{Promo: newTestPromoController(&mockPromoService{})})
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "GET", path: "/promo"},
+		{method: "POST", path: "/promo", body: `{"code": "WINTER", "amount": 5}`},
+		{method: "GET", path: "/promo/SUMMER10"},
+		{method: "PUT", path: "/promo/SUMMER10", body: `{"amount": 5}`},
+		{method: "DELETE", path: "/promo/SUMMER10"},
+	}
+
+	// Customers could otherwise mint their own discounts
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusUnauthorized {
+			t.Fatalf("expected response code to be %v for %v %v, got %v", http.StatusUnauthorized, test.method, test.path, responseRecorder.Code)
+		}
+
+		req = httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		responseRecorder = httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code == http.StatusUnauthorized || responseRecorder.Code >= http.StatusInternalServerError {
+			t.Fatalf("expected %v %v to be served with the admin token, got %v", test.method, test.path, responseRecorder.Code)
+		}
+	}
+}
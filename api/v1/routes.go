@@ -3,15 +3,121 @@ package api_v1
 import (
 	"net/http"
 
+	"github.com/gbandres98/pack-and-go/audit"
 	"github.com/gorilla/mux"
 )
 
-func SetRoutes(router *mux.Router, tripController tripController) *mux.Router {
+type middleware func(http.Handler) http.Handler
+
+type Controllers struct {
+	Trip     *tripController
+	City     *cityController
+	GTFS     *gtfsController
+	Pricing  *pricingController
+	Promo    *promoController
+	Customer *customerController
+	Booking  *bookingController
+	Events   *eventsController
+	Webhook  *webhookController
+	Audit    *auditController
+	Backup   *backupController
+	Admin    *adminController
+	// Idempotent wraps the handlers of non-idempotent requests that can be safely retried
+	Idempotent middleware
+	// Barrier wraps every handler but restores, so backups are taken between writes
+	Barrier middleware
+}
+
+func SetRoutes(router *mux.Router, controllers Controllers) *mux.Router {
 	router.StrictSlash(true)
+	router.Use(audit.Middleware)
+	router.Use(mux.MiddlewareFunc(identify(controllers.Admin, controllers.Customer)))
+
+	// Restores wait for the writes in progress to finish, so they are routed
+	// apart from the requests that hold the barrier
+	admin := controllers.Admin.RequireAdmin
+	root := router
+	root.Handle("/restore", admin(http.HandlerFunc(controllers.Backup.Restore))).Methods(http.MethodPost)
+	router = root.NewRoute().Subrouter()
+	router.Use(mux.MiddlewareFunc(controllers.Barrier))
+
+	tripController := controllers.Trip
+	idempotent := controllers.Idempotent
 
 	router.HandleFunc("/trip", tripController.GetAllTrips).Methods(http.MethodGet)
-	router.HandleFunc("/trip", tripController.AddTrip).Methods(http.MethodPost)
+	router.Handle("/trip", idempotent(http.HandlerFunc(tripController.AddTrip))).Methods(http.MethodPost)
+	router.Handle("/trip/import", idempotent(http.HandlerFunc(tripController.ImportTrips))).Methods(http.MethodPost)
+	router.HandleFunc("/trip/export", tripController.ExportTrips).Methods(http.MethodGet)
 	router.HandleFunc("/trip/{id}", tripController.GetTripById).Methods(http.MethodGet)
+	router.HandleFunc("/trip/{id}", tripController.UpdateTrip).Methods(http.MethodPut)
+	router.HandleFunc("/trip/{id}", tripController.DeleteTrip).Methods(http.MethodDelete)
+	router.HandleFunc("/trip/{id}/calendar.ics", tripController.GetTripCalendar).Methods(http.MethodGet)
+	router.HandleFunc("/trip/{id}/quote", controllers.Pricing.GetQuote).Methods(http.MethodGet)
+	router.HandleFunc("/trip/{id}/quote/explain", controllers.Pricing.ExplainQuote).Methods(http.MethodGet)
+	router.HandleFunc("/trip/{id}/departure", tripController.GetDepartures).Methods(http.MethodGet)
+	router.HandleFunc("/trip/{id}/departure/{date}", tripController.GetDeparture).Methods(http.MethodGet)
+	router.Handle("/trip/{id}/departure/{date}/disruption", admin(http.HandlerFunc(tripController.PostDisruption))).Methods(http.MethodPut)
+	router.Handle("/trip/{id}/departure/{date}/disruption", admin(http.HandlerFunc(tripController.ClearDisruption))).Methods(http.MethodDelete)
+	router.Handle("/trip/{id}/departure/{date}/booking", admin(http.HandlerFunc(controllers.Booking.GetDepartureBookings))).Methods(http.MethodGet)
+	router.HandleFunc("/disruption", tripController.GetAllDisruptions).Methods(http.MethodGet)
+	router.HandleFunc("/route/{originId}/{destinationId}/calendar.ics", tripController.GetRouteCalendar).Methods(http.MethodGet)
+
+	router.HandleFunc("/city", controllers.City.GetCities).Methods(http.MethodGet)
+	router.Handle("/city", idempotent(http.HandlerFunc(controllers.City.AddCity))).Methods(http.MethodPost)
+	router.HandleFunc("/city/{id}", controllers.City.GetCityById).Methods(http.MethodGet)
+	router.HandleFunc("/city/{id}", controllers.City.UpdateCity).Methods(http.MethodPut)
+
+	promoController := controllers.Promo
+
+	router.Handle("/promo", admin(http.HandlerFunc(promoController.GetAllPromoCodes))).Methods(http.MethodGet)
+	router.Handle("/promo", admin(idempotent(http.HandlerFunc(promoController.AddPromoCode)))).Methods(http.MethodPost)
+	router.Handle("/promo/{code}", admin(http.HandlerFunc(promoController.GetPromoCode))).Methods(http.MethodGet)
+	router.Handle("/promo/{code}", admin(http.HandlerFunc(promoController.UpdatePromoCode))).Methods(http.MethodPut)
+	router.Handle("/promo/{code}", admin(http.HandlerFunc(promoController.DeletePromoCode))).Methods(http.MethodDelete)
+
+	customerController := controllers.Customer
+	authenticated := customerController.RequireSession
+
+	router.Handle("/customer", idempotent(http.HandlerFunc(customerController.Register))).Methods(http.MethodPost)
+	router.HandleFunc("/session", customerController.Login).Methods(http.MethodPost)
+	router.Handle("/session", authenticated(http.HandlerFunc(customerController.Logout))).Methods(http.MethodDelete)
+	router.Handle("/customer/me", authenticated(http.HandlerFunc(customerController.GetCurrentCustomer))).Methods(http.MethodGet)
+	router.Handle("/customer/me/passenger", authenticated(http.HandlerFunc(customerController.GetPassengers))).Methods(http.MethodGet)
+	router.Handle("/customer/me/passenger", authenticated(idempotent(http.HandlerFunc(customerController.AddPassenger)))).Methods(http.MethodPost)
+	router.Handle("/customer/me/passenger/{id}", authenticated(http.HandlerFunc(customerController.GetPassenger))).Methods(http.MethodGet)
+	router.Handle("/customer/me/passenger/{id}", authenticated(http.HandlerFunc(customerController.UpdatePassenger))).Methods(http.MethodPut)
+	router.Handle("/customer/me/passenger/{id}", authenticated(http.HandlerFunc(customerController.DeletePassenger))).Methods(http.MethodDelete)
+
+	bookingController := controllers.Booking
+
+	router.Handle("/customer/me/booking", authenticated(http.HandlerFunc(bookingController.GetBookings))).Methods(http.MethodGet)
+	router.Handle("/customer/me/booking", authenticated(idempotent(http.HandlerFunc(bookingController.Book)))).Methods(http.MethodPost)
+	router.Handle("/customer/me/booking/{id}", authenticated(http.HandlerFunc(bookingController.GetBooking))).Methods(http.MethodGet)
+	router.Handle("/customer/me/booking/{id}/refund", authenticated(http.HandlerFunc(bookingController.GetRefundQuote))).Methods(http.MethodGet)
+	router.Handle("/customer/me/booking/{id}/cancel", authenticated(http.HandlerFunc(bookingController.CancelBooking))).Methods(http.MethodPost)
+	router.Handle("/customer/me/ledger", authenticated(http.HandlerFunc(bookingController.GetLedger))).Methods(http.MethodGet)
+	router.HandleFunc("/ticket/verify", bookingController.VerifyTicket).Methods(http.MethodPost)
+	router.HandleFunc("/ticket/key", bookingController.GetTicketKey).Methods(http.MethodGet)
+
+	router.HandleFunc("/events", controllers.Events.GetEvents).Methods(http.MethodGet)
+
+	webhookController := controllers.Webhook
+
+	router.Handle("/webhook", admin(http.HandlerFunc(webhookController.GetWebhooks))).Methods(http.MethodGet)
+	router.Handle("/webhook", admin(idempotent(http.HandlerFunc(webhookController.AddWebhook)))).Methods(http.MethodPost)
+	router.Handle("/webhook/dead-letter", admin(http.HandlerFunc(webhookController.GetDeadLetters))).Methods(http.MethodGet)
+	router.Handle("/webhook/dead-letter/{id}/retry", admin(http.HandlerFunc(webhookController.RetryDelivery))).Methods(http.MethodPost)
+	router.Handle("/webhook/{id}", admin(http.HandlerFunc(webhookController.GetWebhook))).Methods(http.MethodGet)
+	router.Handle("/webhook/{id}", admin(http.HandlerFunc(webhookController.UpdateWebhook))).Methods(http.MethodPut)
+	router.Handle("/webhook/{id}", admin(http.HandlerFunc(webhookController.DeleteWebhook))).Methods(http.MethodDelete)
+	router.Handle("/webhook/{id}/delivery", admin(http.HandlerFunc(webhookController.GetDeliveries))).Methods(http.MethodGet)
+
+	router.Handle("/audit", admin(http.HandlerFunc(controllers.Audit.GetAuditRecords))).Methods(http.MethodGet)
+
+	router.Handle("/backup", admin(http.HandlerFunc(controllers.Backup.GetBackup))).Methods(http.MethodGet)
+
+	router.HandleFunc("/gtfs.zip", controllers.GTFS.GetFeed).Methods(http.MethodGet)
+	router.Handle("/gtfs/import", idempotent(http.HandlerFunc(controllers.GTFS.ImportFeed))).Methods(http.MethodPost)
 
-	return router
-}
\ No newline at end of file
+	return root
+}
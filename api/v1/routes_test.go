@@ -0,0 +1,60 @@
+package api_v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gorilla/mux"
+)
+
+// newTestRouter returns the routes of the API over the controllers given,
+// and over controllers of mock services for those left out
+func newTestRouter(controllers Controllers) *mux.Router {
+	if controllers.Trip == nil {
+		controllers.Trip = NewTripController(&mockTripService{})
+	}
+	if controllers.City == nil {
+		controllers.City = NewCityController(&mockCityService{})
+	}
+	if controllers.GTFS == nil {
+		controllers.GTFS = NewGTFSController(&mockCityService{}, &mockTripService{})
+	}
+	if controllers.Pricing == nil {
+		controllers.Pricing = NewPricingController(&mockTripService{}, &mockPricingEngine{}, &mockPromoService{}, &mockBookingService{})
+	}
+	if controllers.Promo == nil {
+		controllers.Promo = NewPromoController(&mockPromoService{}, &mockTripService{})
+	}
+	if controllers.Customer == nil {
+		controllers.Customer = NewCustomerController(&mockCustomerService{})
+	}
+	if controllers.Booking == nil {
+		controllers.Booking = NewBookingController(&mockBookingService{})
+	}
+	if controllers.Events == nil {
+		controllers.Events = NewEventsController(events.NewHub(1), time.Millisecond)
+	}
+	if controllers.Webhook == nil {
+		controllers.Webhook = NewWebhookController(&mockWebhookService{})
+	}
+	if controllers.Audit == nil {
+		controllers.Audit = NewAuditController(&mockAuditLog{})
+	}
+	if controllers.Backup == nil {
+		controllers.Backup = NewBackupController(&mockBackupManager{})
+	}
+	if controllers.Admin == nil {
+		controllers.Admin = NewAdminController(testAdminToken)
+	}
+
+	passThrough := func(next http.Handler) http.Handler { return next }
+	if controllers.Idempotent == nil {
+		controllers.Idempotent = passThrough
+	}
+	if controllers.Barrier == nil {
+		controllers.Barrier = passThrough
+	}
+
+	return SetRoutes(mux.NewRouter(), controllers)
+}
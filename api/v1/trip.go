@@ -1,35 +1,83 @@
 package api_v1
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gbandres98/pack-and-go/db"
 	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
 	"github.com/gorilla/mux"
 )
 
 type tripService interface {
-	GetAllTrips() []model.Trip
+	GetAllTrips() ([]model.Trip, error)
 	GetTripById(int32) (model.Trip, error)
-	AddTrip(model.Trip) (model.Trip, error)
+	FindTrips(model.TripQuery) ([]model.Trip, error)
+	GetAllTripsAsOf(time.Time) ([]model.Trip, error)
+	GetTripByIdAsOf(int32, time.Time) (model.Trip, error)
+	AddTrip(context.Context, model.Trip) (model.Trip, error)
+	UpdateTrip(context.Context, int32, model.Trip, int32) (model.Trip, error)
+	DeleteTrip(context.Context, int32, int32) error
 	GetTripPretty(model.Trip) (model.TripPretty, error)
+	GetDisruptions(int32) []model.Disruption
+	GetAllDisruptions() []model.Disruption
+	PostDisruption(int32, time.Time, model.Disruption) (model.Disruption, error)
+	ClearDisruption(int32, time.Time) error
+	GetDeparture(int32, time.Time) (model.Departure, error)
+	GetDepartures(int32, time.Time, int) ([]model.Departure, error)
+	ImportTrips(context.Context, []service.ImportRow, bool) ([]service.ImportResult, bool)
 }
 
 type tripController struct {
 	tripService
+	now func() time.Time
 }
 
 func NewTripController(tripService tripService) *tripController {
-	return &tripController{tripService}
+	return &tripController{tripService, time.Now}
 }
 
+// GetAllTrips lists every trip, or the trips as they were at the time in ?asOf=.
+// Trips can be filtered by city with ?originId= and ?destinationId=, and paged
+// with ?limit= and ?after=, the id of the last trip of the previous page. Full
+// pages link to the next one in a Link header.
 func (tripController *tripController) GetAllTrips(w http.ResponseWriter, req *http.Request) {
-	trips := tripController.tripService.GetAllTrips()
+	query, ok := parseTripQuery(w, req)
+	if !ok {
+		return
+	}
+
+	if value := req.URL.Query().Get("asOf"); value != "" {
+		asOf, err := parseAsOf(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid asOf: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		tripController.getAllTripsAsOf(w, req, asOf, query)
+		return
+	}
+
+	var trips []model.Trip
+	var err error
+	if query == (model.TripQuery{}) {
+		trips, err = tripController.tripService.GetAllTrips()
+	} else {
+		trips, err = tripController.tripService.FindTrips(query)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
 	tripsPretty := []model.TripPretty{}
+	lastModified := time.Time{}
 
 	for _, trip := range trips {
 		tripPretty, err := tripController.tripService.GetTripPretty(trip)
@@ -39,23 +87,42 @@ func (tripController *tripController) GetAllTrips(w http.ResponseWriter, req *ht
 		}
 
 		tripsPretty = append(tripsPretty, tripPretty)
+		if tripModified := tripLastModified(trip, tripPretty.Disruptions); tripModified.After(lastModified) {
+			lastModified = tripModified
+		}
 	}
 
 	body, _ := json.Marshal(tripsPretty)
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(body)
+	etag := bodyETag(body)
+	if isNotModified(req, etag, lastModified) {
+		writeNotModified(w, etag, lastModified)
+		return
+	}
+
+	setValidators(w, etag, lastModified)
+	setNextLink(w, req, query, trips)
+	writeJSON(w, http.StatusOK, body)
 }
 
+// GetTripById returns a trip, or the trip as it was at the time in ?asOf=
 func (tripController *tripController) GetTripById(w http.ResponseWriter, req *http.Request) {
-	idVar := mux.Vars(req)["id"]
-	id, err := strconv.ParseInt(idVar, 10, 32)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Bad Request - invalid trip id: %v", err), http.StatusBadRequest)
+	id, ok := parseTripId(w, req)
+	if !ok {
 		return
 	}
 
-	trip, err := tripController.tripService.GetTripById(int32(id))
+	if value := req.URL.Query().Get("asOf"); value != "" {
+		asOf, err := parseAsOf(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid asOf: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		tripController.getTripByIdAsOf(w, id, asOf)
+		return
+	}
+
+	trip, err := tripController.tripService.GetTripById(id)
 	if err == db.ErrorTripNotFound {
 		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
 		return
@@ -71,10 +138,14 @@ func (tripController *tripController) GetTripById(w http.ResponseWriter, req *ht
 		return
 	}
 
-	body, _ := json.Marshal(tripPretty)
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(body)
+	etag := tripETag(trip, tripPretty.Disruptions)
+	lastModified := tripLastModified(trip, tripPretty.Disruptions)
+	if isNotModified(req, etag, lastModified) {
+		writeNotModified(w, etag, lastModified)
+		return
+	}
+
+	tripController.writeTripPretty(w, http.StatusOK, trip, tripPretty)
 }
 
 func (tripController *tripController) AddTrip(w http.ResponseWriter, req *http.Request) {
@@ -87,20 +158,200 @@ func (tripController *tripController) AddTrip(w http.ResponseWriter, req *http.R
 		return
 	}
 
-	savedTrip, err := tripController.tripService.AddTrip(newTrip)
+	savedTrip, err := tripController.tripService.AddTrip(req.Context(), newTrip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid trip: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tripController.writeTrip(w, http.StatusCreated, savedTrip)
+}
+
+// UpdateTrip replaces a trip. Clients have to send the ETag of the version
+// they are editing in If-Match, so concurrent edits are not silently lost.
+func (tripController *tripController) UpdateTrip(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return
+	}
+
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "Precondition Required - updates require an If-Match header", http.StatusPreconditionRequired)
+		return
+	}
+
+	currentTrip, err := tripController.tripService.GetTripById(id)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if etag, ok := tripController.matchesCurrentTrip(ifMatch, currentTrip); !ok {
+		http.Error(w, fmt.Sprintf("Precondition Failed - trip has been modified, current version is %v", etag), http.StatusPreconditionFailed)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var trip model.Trip
+	err = json.Unmarshal(requestBody, &trip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid trip json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedTrip, err := tripController.tripService.UpdateTrip(req.Context(), id, trip, currentTrip.Version)
+	if errors.Is(err, db.ErrorVersionMismatch) {
+		http.Error(w, "Precondition Failed - trip has been modified", http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, db.ErrorTripNotFound) {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Bad Request - invalid trip: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	tripPretty, err := tripController.tripService.GetTripPretty(savedTrip)
+	tripController.writeTrip(w, http.StatusOK, savedTrip)
+}
+
+// DeleteTrip removes a trip. Like updates, it requires the ETag of the current version in If-Match.
+func (tripController *tripController) DeleteTrip(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseTripId(w, req)
+	if !ok {
+		return
+	}
+
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "Precondition Required - deletes require an If-Match header", http.StatusPreconditionRequired)
+		return
+	}
+
+	currentTrip, err := tripController.tripService.GetTripById(id)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if etag, ok := tripController.matchesCurrentTrip(ifMatch, currentTrip); !ok {
+		http.Error(w, fmt.Sprintf("Precondition Failed - trip has been modified, current version is %v", etag), http.StatusPreconditionFailed)
+		return
+	}
+
+	err = tripController.tripService.DeleteTrip(req.Context(), id, currentTrip.Version)
+	if errors.Is(err, db.ErrorVersionMismatch) {
+		http.Error(w, "Precondition Failed - trip has been modified", http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, db.ErrorTripNotFound) {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// matchesCurrentTrip reports whether If-Match holds the ETag of the current
+// version of a trip, with or without its disruptions, and returns its ETag
+func (tripController *tripController) matchesCurrentTrip(ifMatch string, trip model.Trip) (string, bool) {
+	etag := tripETag(trip, tripController.tripService.GetDisruptions(trip.Id))
+	ok := matchesETag(ifMatch, etag, false) || matchesETag(ifMatch, tripETag(trip, nil), false)
+
+	return etag, ok
+}
+
+func (tripController *tripController) writeTrip(w http.ResponseWriter, status int, trip model.Trip) {
+	tripPretty, err := tripController.tripService.GetTripPretty(trip)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	tripController.writeTripPretty(w, status, trip, tripPretty)
+}
+
+func (tripController *tripController) writeTripPretty(w http.ResponseWriter, status int, trip model.Trip, tripPretty model.TripPretty) {
 	body, _ := json.Marshal(tripPretty)
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(body)
-}
\ No newline at end of file
+	setValidators(w, tripETag(trip, tripPretty.Disruptions), tripLastModified(trip, tripPretty.Disruptions))
+	writeJSON(w, status, body)
+}
+
+func parseTripId(w http.ResponseWriter, req *http.Request) (int32, bool) {
+	idVar := mux.Vars(req)["id"]
+	id, err := strconv.ParseInt(idVar, 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid trip id: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return int32(id), true
+}
+
+// maxTripLimit is the largest page of trips that can be asked for with ?limit=
+const maxTripLimit = 1000
+
+// parseTripQuery reads the city ids in ?originId= and ?destinationId= and the
+// page in ?after= and ?limit=, 0 if not given
+func parseTripQuery(w http.ResponseWriter, req *http.Request) (model.TripQuery, bool) {
+	values := []int64{}
+
+	for _, name := range []string{"originId", "destinationId", "after", "limit"} {
+		value := req.URL.Query().Get(name)
+		if value == "" {
+			values = append(values, 0)
+			continue
+		}
+
+		number, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request - invalid %v: %v", name, err), http.StatusBadRequest)
+			return model.TripQuery{}, false
+		}
+		values = append(values, number)
+	}
+
+	if values[2] < 0 {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid after: %v is negative", values[2]), http.StatusBadRequest)
+		return model.TripQuery{}, false
+	}
+	if req.URL.Query().Get("limit") != "" && (values[3] < 1 || values[3] > maxTripLimit) {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid limit: %v is not between 1 and %v", values[3], maxTripLimit), http.StatusBadRequest)
+		return model.TripQuery{}, false
+	}
+
+	return model.TripQuery{
+		OriginId:      int32(values[0]),
+		DestinationId: int32(values[1]),
+		AfterId:       int32(values[2]),
+		Limit:         int(values[3]),
+	}, true
+}
+
+// setNextLink links a full page of trips to the next one, which starts after its last trip
+func setNextLink(w http.ResponseWriter, req *http.Request, query model.TripQuery, trips []model.Trip) {
+	if query.Limit == 0 || len(trips) < query.Limit {
+		return
+	}
+
+	next := *req.URL
+	values := next.Query()
+	values.Set("after", strconv.Itoa(int(trips[len(trips)-1].Id)))
+	next.RawQuery = values.Encode()
+	w.Header().Set("Link", fmt.Sprintf("<%v>; rel=\"next\"", next.RequestURI()))
+}
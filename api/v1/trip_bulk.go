@@ -0,0 +1,279 @@
+package api_v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+var csvImportColumns = []string{"originId", "destinationId", "dates", "price"}
+var csvExportColumns = []string{"id", "origin", "destination", "dates", "price"}
+
+type importRowReport struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Trip   *model.TripPretty `json:"trip,omitempty"`
+}
+
+type importReport struct {
+	Partial   bool              `json:"partial"`
+	Committed bool              `json:"committed"`
+	Created   int               `json:"created"`
+	Failed    int               `json:"failed"`
+	Rows      []importRowReport `json:"rows"`
+}
+
+// ImportTrips creates every trip in a CSV or JSON array request body and
+// responds with a per-row report. Unless partial=true is set, nothing is
+// saved if any row is invalid or fails to be saved.
+func (tripController *tripController) ImportTrips(w http.ResponseWriter, req *http.Request) {
+	partial := req.URL.Query().Get("partial") == "true"
+
+	var rows []service.ImportRow
+	var err error
+
+	switch requestFormat(req) {
+	case "csv":
+		rows, err = parseCSVTrips(req.Body)
+	case "json":
+		rows, err = parseJSONTrips(req.Body)
+	default:
+		http.Error(w, "Unsupported Media Type - trips can be imported as text/csv or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid import file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	results, committed := tripController.tripService.ImportTrips(req.Context(), rows, partial)
+
+	report := importReport{Partial: partial, Committed: committed, Rows: []importRowReport{}}
+	for i, result := range results {
+		rowReport := importRowReport{Row: i + 1}
+
+		switch {
+		case result.Error != nil:
+			rowReport.Status = "invalid"
+			rowReport.Error = result.Error.Error()
+			report.Failed++
+		case result.Saved:
+			rowReport.Status = "created"
+			tripPretty, err := tripController.tripService.GetTripPretty(result.Trip)
+			if err == nil {
+				rowReport.Trip = &tripPretty
+			}
+			report.Created++
+		default:
+			rowReport.Status = "skipped"
+		}
+
+		report.Rows = append(report.Rows, rowReport)
+	}
+
+	status := http.StatusOK
+	if !committed {
+		status = http.StatusUnprocessableEntity
+	} else if report.Failed == 0 {
+		status = http.StatusCreated
+	}
+
+	body, _ := json.Marshal(report)
+	writeJSON(w, status, body)
+}
+
+// ExportTrips streams every trip with its city names, as JSON or as CSV when format=csv is set
+func (tripController *tripController) ExportTrips(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, fmt.Sprintf("Bad Request - unsupported export format: %v", format), http.StatusBadRequest)
+		return
+	}
+
+	trips, err := tripController.tripService.GetAllTrips()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// City names are resolved up front, so a failure is reported before any row is streamed
+	tripsPretty := make([]model.TripPretty, 0, len(trips))
+	for _, trip := range trips {
+		tripPretty, err := tripController.tripService.GetTripPretty(trip)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+			return
+		}
+		tripsPretty = append(tripsPretty, tripPretty)
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="trips.%v"`, format))
+
+	if format == "csv" {
+		writeCSVTrips(w, tripsPretty)
+		return
+	}
+
+	writeJSONTrips(w, tripsPretty)
+}
+
+func requestFormat(req *http.Request) string {
+	if format := req.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return "json"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+
+	return ""
+}
+
+func parseCSVTrips(body io.Reader) ([]service.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range csvImportColumns {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("missing csv column: %v", name)
+		}
+	}
+
+	rows := []service.ImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, parseCSVTrip(record, columns))
+	}
+
+	return rows, nil
+}
+
+func parseCSVTrip(record []string, columns map[string]int) service.ImportRow {
+	field := func(name string) string {
+		return strings.TrimSpace(record[columns[strings.ToLower(name)]])
+	}
+
+	originId, err := strconv.ParseInt(field("originId"), 10, 32)
+	if err != nil {
+		return service.ImportRow{Error: fmt.Errorf("invalid originId: %v", field("originId"))}
+	}
+
+	destinationId, err := strconv.ParseInt(field("destinationId"), 10, 32)
+	if err != nil {
+		return service.ImportRow{Error: fmt.Errorf("invalid destinationId: %v", field("destinationId"))}
+	}
+
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil {
+		return service.ImportRow{Error: fmt.Errorf("invalid price: %v", field("price"))}
+	}
+
+	return service.ImportRow{Trip: model.Trip{
+		OriginId:      int32(originId),
+		DestinationId: int32(destinationId),
+		Dates:         field("dates"),
+		Price:         price,
+	}}
+}
+
+func parseJSONTrips(body io.Reader) ([]service.ImportRow, error) {
+	var rawTrips []json.RawMessage
+	err := json.NewDecoder(body).Decode(&rawTrips)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []service.ImportRow{}
+	for _, rawTrip := range rawTrips {
+		var trip model.Trip
+		err := json.Unmarshal(rawTrip, &trip)
+		if err != nil {
+			rows = append(rows, service.ImportRow{Error: fmt.Errorf("invalid trip json: %v", err)})
+			continue
+		}
+
+		rows = append(rows, service.ImportRow{Trip: trip})
+	}
+
+	return rows, nil
+}
+
+func writeCSVTrips(w http.ResponseWriter, tripsPretty []model.TripPretty) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(csvExportColumns)
+
+	for _, tripPretty := range tripsPretty {
+		writer.Write([]string{
+			strconv.Itoa(int(tripPretty.Id)),
+			tripPretty.Origin,
+			tripPretty.Destination,
+			tripPretty.Dates,
+			strconv.FormatFloat(tripPretty.Price, 'f', -1, 64),
+		})
+	}
+
+	writer.Flush()
+}
+
+func writeJSONTrips(w http.ResponseWriter, tripsPretty []model.TripPretty) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write([]byte("["))
+	for i, tripPretty := range tripsPretty {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		body, _ := json.Marshal(tripPretty)
+		w.Write(body)
+	}
+	w.Write([]byte("]"))
+}
@@ -0,0 +1,183 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportTrips_1(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("POST", "/trip/import", strings.NewReader(
+		"originId,destinationId,dates,price\n1,2,Mon Tue,40.55\n2,1,Sat Sun,12\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ImportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+
+	var report importReport
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !report.Committed || report.Created != 2 || report.Rows[1].Status != "created" || report.Rows[1].Trip == nil {
+		t.Fatalf("expected both rows to be created, got %v", report)
+	}
+}
+
+func TestImportTrips_2(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("POST", "/trip/import", strings.NewReader(
+		`[{"originId":1,"destinationId":2,"dates":"Mon Tue","price":40.55},{"originId":1,"destinationId":3,"dates":"Sat","price":12}]`))
+	req.Header.Set("Content-Type", "application/json")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ImportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnprocessableEntity, responseRecorder.Code)
+	}
+
+	var report importReport
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Committed || report.Created != 0 || report.Failed != 1 {
+		t.Fatalf("expected nothing to be created, got %v", report)
+	}
+	if report.Rows[0].Status != "skipped" || report.Rows[1].Status != "invalid" || report.Rows[1].Row != 2 {
+		t.Fatalf("expected first row skipped and second row invalid, got %v", report.Rows)
+	}
+}
+
+func TestImportTrips_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("POST", "/trip/import?partial=true", strings.NewReader(
+		"dates,price,originId,destinationId\nMon Tue,40.55,1,2\nSat,12,one,2\n"))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ImportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var report importReport
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !report.Committed || report.Created != 1 || report.Failed != 1 {
+		t.Fatalf("expected one row created and one failed, got %v", report)
+	}
+	if !strings.Contains(report.Rows[1].Error, "originId") {
+		t.Fatalf("expected row error to mention originId, got %v", report.Rows[1].Error)
+	}
+}
+
+func TestImportTrips_4(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("POST", "/trip/import", strings.NewReader("originId,dates\n1,Mon\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ImportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
+
+func TestImportTrips_5(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("POST", "/trip/import", strings.NewReader("<trips/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ImportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnsupportedMediaType, responseRecorder.Code)
+	}
+}
+
+func TestExportTrips_1(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/export?format=csv", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ExportTrips(responseRecorder, req)
+
+	expected := "id,origin,destination,dates,price\n" +
+		"1,Sevilla,Madrid,Mon Tue,40.55\n" +
+		"2,Sevilla,Madrid,Mon Tue,40.55\n"
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if responseRecorder.Body.String() != expected {
+		t.Fatalf("expected %v, got %v", expected, responseRecorder.Body.String())
+	}
+	if responseRecorder.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected Content-Type to be %v, got %v", "text/csv", responseRecorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestExportTrips_2(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/export", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ExportTrips(responseRecorder, req)
+
+	expected := `[{"id":1,"origin":"Sevilla","destination":"Madrid","dates":"Mon Tue","price":40.55},` +
+		`{"id":2,"origin":"Sevilla","destination":"Madrid","dates":"Mon Tue","price":40.55}]`
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if responseRecorder.Body.String() != expected {
+		t.Fatalf("expected %v, got %v", expected, responseRecorder.Body.String())
+	}
+}
+
+func TestExportTrips_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{failGetTripPretty: true})
+
+	req := httptest.NewRequest("GET", "/trip/export?format=csv", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ExportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusInternalServerError, responseRecorder.Code)
+	}
+}
+
+func TestExportTrips_4(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/export?format=xml", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.ExportTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/ical"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+// getAllTripsAsOf responds to GET /trip?asOf= with the trips selected by the
+// query as they were at that time. Their cities and disruptions are the current ones.
+func (tripController *tripController) getAllTripsAsOf(w http.ResponseWriter, req *http.Request, asOf time.Time, query model.TripQuery) {
+	trips, err := tripController.tripService.GetAllTripsAsOf(asOf)
+	if err == service.ErrorHistoryNotKept {
+		http.Error(w, fmt.Sprintf("Not Implemented - %v", err), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	trips = query.Apply(trips)
+	tripsPretty := []model.TripPretty{}
+	for _, trip := range trips {
+		tripPretty, err := tripController.tripService.GetTripPretty(trip)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+			return
+		}
+		tripsPretty = append(tripsPretty, tripPretty)
+	}
+
+	body, _ := json.Marshal(tripsPretty)
+	setNextLink(w, req, query, trips)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// getTripByIdAsOf responds to GET /trip/{id}?asOf= with the trip as it was at that time
+func (tripController *tripController) getTripByIdAsOf(w http.ResponseWriter, id int32, asOf time.Time) {
+	trip, err := tripController.tripService.GetTripByIdAsOf(id, asOf)
+	if err == db.ErrorTripNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no trip found with id %v as of %v", id, asOf.Format(time.RFC3339)), http.StatusNotFound)
+		return
+	}
+	if err == service.ErrorHistoryNotKept {
+		http.Error(w, fmt.Sprintf("Not Implemented - %v", err), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	tripPretty, err := tripController.tripService.GetTripPretty(trip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(tripPretty)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// parseAsOf reads an RFC 3339 time, or a YYYY-MM-DD date that stands for the
+// end of that day in the schedule time zone
+func parseAsOf(value string) (time.Time, error) {
+	// The + of time zone offsets arrives as a space if it is not escaped
+	asOf, err := time.Parse(time.RFC3339, strings.Replace(value, " ", "+", 1))
+	if err == nil {
+		return asOf, nil
+	}
+
+	location, err := time.LoadLocation(ical.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or a YYYY-MM-DD date, got %q", value)
+	}
+
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
@@ -0,0 +1,64 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func newTestTripHistoryRouter(tripService tripService) http.Handler {
+	return newTestRouter(Controllers{Trip: NewTripController(tripService)})
+}
+
+func TestGetTripsAsOf_1(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int
+		trips    int
+	}{
+		{path: "/trip?asOf=2026-03-01T11:00:00Z", expected: http.StatusOK, trips: 1},
+		{path: "/trip?asOf=2026-03-01", expected: http.StatusOK, trips: 2},
+		{path: "/trip?asOf=2026-02-28", expected: http.StatusOK, trips: 1},
+		{path: "/trip?asOf=yesterday", expected: http.StatusBadRequest},
+		{path: "/trip/2?asOf=2026-03-01T11:00:00Z", expected: http.StatusNotFound},
+		{path: "/trip/2?asOf=2026-03-01T12:30:00%2B01:00", expected: http.StatusNotFound},
+		{path: "/trip/2?asOf=2026-03-01T13:30:00+01:00", expected: http.StatusOK},
+		{path: "/trip/2?asOf=2026-03-01T13:00:00Z", expected: http.StatusOK},
+	}
+
+	handler := newTestTripHistoryRouter(&mockTripService{})
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.path, nil)
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v: expected response code to be %v, got %v", test.path, test.expected, responseRecorder.Code)
+		}
+
+		var trips []model.TripPretty
+		if test.trips > 0 {
+			json.Unmarshal(responseRecorder.Body.Bytes(), &trips)
+			if len(trips) != test.trips {
+				t.Fatalf("%v: expected %v trips, got %v", test.path, test.trips, trips)
+			}
+		}
+	}
+}
+
+func TestGetTripsAsOf_2(t *testing.T) {
+	handler := newTestTripHistoryRouter(&mockTripService{noHistory: true})
+
+	req := httptest.NewRequest("GET", "/trip?asOf="+time.Now().Format(time.RFC3339), nil)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotImplemented {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotImplemented, responseRecorder.Code)
+	}
+}
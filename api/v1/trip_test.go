@@ -1,15 +1,20 @@
 package api_v1
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gbandres98/pack-and-go/db"
 	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
 	"github.com/gorilla/mux"
 )
 
@@ -23,10 +28,21 @@ var testTripPretty = model.TripPretty{Id: 1, Origin: "Sevilla", Destination: "Ma
 type mockTripService struct{
 	failGetTripPretty bool
 	failGetTripById bool
+	failGetAllTrips bool
+	concurrentUpdate bool
+	disruptions []model.Disruption
+	noHistory bool
 }
 
-func (mockTripService *mockTripService) GetAllTrips() []model.Trip {
-	return testTrips
+// Trip 2 was created at this time
+var testTripHistoryTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func (mockTripService *mockTripService) GetAllTrips() ([]model.Trip, error) {
+	if (mockTripService.failGetAllTrips) {
+		return nil, fmt.Errorf("test error")
+	}
+
+	return testTrips, nil
 }
 
 func (mockTripService *mockTripService) GetTripById(id int32) (model.Trip, error) {
@@ -41,7 +57,34 @@ func (mockTripService *mockTripService) GetTripById(id int32) (model.Trip, error
 	return model.Trip{}, db.ErrorTripNotFound
 }
 
-func (mockTripService *mockTripService) AddTrip(trip model.Trip) (model.Trip, error) {
+func (mockTripService *mockTripService) FindTrips(query model.TripQuery) ([]model.Trip, error) {
+	return query.Apply(testTrips), nil
+}
+
+func (mockTripService *mockTripService) GetAllTripsAsOf(asOf time.Time) ([]model.Trip, error) {
+	if mockTripService.noHistory {
+		return nil, service.ErrorHistoryNotKept
+	}
+	if asOf.Before(testTripHistoryTime) {
+		return testTrips[:1], nil
+	}
+	return testTrips, nil
+}
+
+func (mockTripService *mockTripService) GetTripByIdAsOf(id int32, asOf time.Time) (model.Trip, error) {
+	trips, err := mockTripService.GetAllTripsAsOf(asOf)
+	if err != nil {
+		return model.Trip{}, err
+	}
+	for _, trip := range trips {
+		if trip.Id == id {
+			return trip, nil
+		}
+	}
+	return model.Trip{}, db.ErrorTripNotFound
+}
+
+func (mockTripService *mockTripService) AddTrip(ctx context.Context, trip model.Trip) (model.Trip, error) {
 	if trip.OriginId > 2 || trip.DestinationId > 2 || trip.OriginId < 1 || trip.DestinationId < 1 {
 		return model.Trip{}, errors.New("invalid originId or destinationId")
 	}
@@ -49,12 +92,65 @@ func (mockTripService *mockTripService) AddTrip(trip model.Trip) (model.Trip, er
 	return trip, nil
 }
 
+func (mockTripService *mockTripService) UpdateTrip(ctx context.Context, id int32, trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	if mockTripService.concurrentUpdate {
+		return model.Trip{}, db.ErrorVersionMismatch
+	}
+	if trip.OriginId > 2 || trip.DestinationId > 2 || trip.OriginId < 1 || trip.DestinationId < 1 {
+		return model.Trip{}, errors.New("invalid originId or destinationId")
+	}
+	trip.Id = id
+	trip.Version = expectedVersion + 1
+	return trip, nil
+}
+
+func (mockTripService *mockTripService) DeleteTrip(ctx context.Context, id int32, expectedVersion int32) error {
+	if mockTripService.concurrentUpdate {
+		return db.ErrorVersionMismatch
+	}
+	if id > 2 {
+		return db.ErrorTripNotFound
+	}
+	return nil
+}
+
+func (mockTripService *mockTripService) ImportTrips(ctx context.Context, rows []service.ImportRow, partial bool) ([]service.ImportResult, bool) {
+	results := make([]service.ImportResult, len(rows))
+	valid := true
+
+	for i, row := range rows {
+		results[i] = service.ImportResult{Trip: row.Trip, Error: row.Error}
+		if row.Error == nil && (row.Trip.OriginId > 2 || row.Trip.DestinationId > 2) {
+			results[i].Error = errors.New("invalid originId or destinationId")
+		}
+		if results[i].Error != nil {
+			valid = false
+		}
+	}
+
+	if !valid && !partial {
+		return results, false
+	}
+
+	for i := range results {
+		if results[i].Error == nil {
+			results[i].Trip.Id = int32(3 + i)
+			results[i].Saved = true
+		}
+	}
+
+	return results, true
+}
+
 func (mockTripService *mockTripService) GetTripPretty(trip model.Trip) (model.TripPretty, error) {
 	if mockTripService.failGetTripPretty {
 		return model.TripPretty{}, fmt.Errorf("test error")
 	}
 	result := testTripPretty
 	result.Id = trip.Id
+	if disruptions := mockTripService.GetDisruptions(trip.Id); len(disruptions) > 0 {
+		result.Disruptions = disruptions
+	}
 	return result, nil
 }
 
@@ -92,6 +188,33 @@ func TestGetAllTrips_2(t *testing.T) {
 	}
 }
 
+func TestGetAllTrips_6(t *testing.T) {
+	tripController := NewTripController(&mockTripService{ failGetAllTrips: true })
+
+	req := httptest.NewRequest("GET", "/trip", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetAllTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusInternalServerError, responseRecorder.Code)
+	}
+}
+
+func TestGetAllTrips_7(t *testing.T) {
+	tripController := NewTripController(&mockTripService{ failGetAllTrips: true })
+
+	req := httptest.NewRequest("GET", "/trip?originId=1", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetAllTrips(responseRecorder, req)
+
+	// Filtered requests only read the trips they select
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
+
 func TestGetTripById_1(t *testing.T) {
 	tripController := NewTripController(&mockTripService{})
 
@@ -246,4 +369,263 @@ func TestAddTrip_5(t *testing.T) {
 	if responseRecorder.Code != http.StatusBadRequest {
 		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
 	}
-}
\ No newline at end of file
+}
+
+func TestGetAllTrips_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetAllTrips(responseRecorder, req)
+
+	etag := responseRecorder.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header to be set")
+	}
+
+	req = httptest.NewRequest("GET", "/trip", nil)
+	req.Header.Set("If-None-Match", etag)
+	responseRecorder = httptest.NewRecorder()
+
+	tripController.GetAllTrips(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotModified {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotModified, responseRecorder.Code)
+	}
+	if responseRecorder.Body.Len() != 0 {
+		t.Fatalf("expected empty body, got %v", responseRecorder.Body.String())
+	}
+}
+
+func TestGetAllTrips_4(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	tests := []struct {
+		query    string
+		code     int
+		expected []int32
+	}{
+		{query: "?originId=1", code: http.StatusOK, expected: []int32{1}},
+		{query: "?destinationId=1", code: http.StatusOK, expected: []int32{2}},
+		{query: "?originId=1&destinationId=1", code: http.StatusOK, expected: []int32{}},
+		{query: "?originId=2&asOf=2026-03-02", code: http.StatusOK, expected: []int32{2}},
+		{query: "?originId=2&asOf=2026-02-28", code: http.StatusOK, expected: []int32{}},
+		{query: "?limit=1", code: http.StatusOK, expected: []int32{1}},
+		{query: "?limit=1&after=1", code: http.StatusOK, expected: []int32{2}},
+		{query: "?limit=1&after=1&asOf=2026-03-02", code: http.StatusOK, expected: []int32{2}},
+		{query: "?originId=Sevilla", code: http.StatusBadRequest},
+		{query: "?limit=0", code: http.StatusBadRequest},
+		{query: "?limit=1001", code: http.StatusBadRequest},
+		{query: "?after=-1", code: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/trip"+test.query, nil)
+		responseRecorder := httptest.NewRecorder()
+
+		tripController.GetAllTrips(responseRecorder, req)
+
+		if responseRecorder.Code != test.code {
+			t.Fatalf("expected response code to be %v for %v, got %v", test.code, test.query, responseRecorder.Code)
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+
+		var tripsPretty []model.TripPretty
+		json.Unmarshal(responseRecorder.Body.Bytes(), &tripsPretty)
+		ids := []int32{}
+		for _, tripPretty := range tripsPretty {
+			ids = append(ids, tripPretty.Id)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Fatalf("expected trips %v for %v, got %v", test.expected, test.query, ids)
+		}
+	}
+}
+
+func TestGetAllTrips_5(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "?limit=1&originId=0", expected: `</trip?after=1&limit=1&originId=0>; rel="next"`},
+		{query: "?limit=1&after=1", expected: `</trip?after=2&limit=1>; rel="next"`},
+		{query: "?limit=2&after=1", expected: ""},
+		{query: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/trip"+test.query, nil)
+		responseRecorder := httptest.NewRecorder()
+
+		tripController.GetAllTrips(responseRecorder, req)
+
+		if link := responseRecorder.Header().Get("Link"); link != test.expected {
+			t.Fatalf("expected Link %v for %v, got %v", test.expected, test.query, link)
+		}
+	}
+}
+
+func TestGetTripById_6(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-None-Match", `"1-0"`)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetTripById(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotModified {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotModified, responseRecorder.Code)
+	}
+	if responseRecorder.Header().Get("ETag") != `"1-0"` {
+		t.Fatalf("expected ETag to be %v, got %v", `"1-0"`, responseRecorder.Header().Get("ETag"))
+	}
+}
+
+func TestGetTripById_7(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("GET", "/trip/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-None-Match", `"1-5"`)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.GetTripById(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if responseRecorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected Content-Type to be %v, got %v", "application/json", responseRecorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestUpdateTrip_1(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-Match", `"1-0"`)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if responseRecorder.Header().Get("ETag") != `"1-1"` {
+		t.Fatalf("expected ETag to be %v, got %v", `"1-1"`, responseRecorder.Header().Get("ETag"))
+	}
+}
+
+func TestUpdateTrip_2(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusPreconditionRequired, responseRecorder.Code)
+	}
+}
+
+func TestUpdateTrip_3(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-Match", `"1-7"`)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusPreconditionFailed, responseRecorder.Code)
+	}
+}
+
+func TestUpdateTrip_4(t *testing.T) {
+	tripController := NewTripController(&mockTripService{ concurrentUpdate: true })
+
+	req := httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-Match", "*")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusPreconditionFailed, responseRecorder.Code)
+	}
+}
+
+func TestUpdateTrip_5(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("PUT", "/trip/3", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req.Header.Set("If-Match", "*")
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestUpdateTrip_6(t *testing.T) {
+	tripController := NewTripController(&mockTripService{})
+
+	req := httptest.NewRequest("PUT", "/trip/1", strings.NewReader(`{"originId":2,"destinationId":3,"dates":"Sat","price":12.5}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req.Header.Set("If-Match", `"1-0"`)
+	responseRecorder := httptest.NewRecorder()
+
+	tripController.UpdateTrip(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
+func TestDeleteTrip_1(t *testing.T) {
+	tests := []struct {
+		id       string
+		ifMatch  string
+		service  *mockTripService
+		expected int
+	}{
+		{id: "1", ifMatch: `"1-0"`, service: &mockTripService{}, expected: http.StatusNoContent},
+		{id: "1", ifMatch: "*", service: &mockTripService{}, expected: http.StatusNoContent},
+		{id: "1", ifMatch: "", service: &mockTripService{}, expected: http.StatusPreconditionRequired},
+		{id: "1", ifMatch: `"1-7"`, service: &mockTripService{}, expected: http.StatusPreconditionFailed},
+		{id: "1", ifMatch: "*", service: &mockTripService{concurrentUpdate: true}, expected: http.StatusPreconditionFailed},
+		{id: "3", ifMatch: "*", service: &mockTripService{}, expected: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		tripController := NewTripController(test.service)
+
+		req := httptest.NewRequest("DELETE", "/trip/"+test.id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": test.id})
+		if test.ifMatch != "" {
+			req.Header.Set("If-Match", test.ifMatch)
+		}
+		responseRecorder := httptest.NewRecorder()
+
+		tripController.DeleteTrip(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v %v: expected response code to be %v, got %v", test.id, test.ifMatch, test.expected, responseRecorder.Code)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package api_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/webhook"
+	"github.com/gorilla/mux"
+)
+
+type webhookService interface {
+	GetWebhooks() []model.Webhook
+	GetWebhook(int32) (model.Webhook, error)
+	AddWebhook(model.Webhook) (model.Webhook, error)
+	UpdateWebhook(int32, model.Webhook) (model.Webhook, error)
+	DeleteWebhook(int32) error
+	GetDeliveries(int32) ([]model.WebhookDelivery, error)
+	GetDeadLetters() []model.WebhookDelivery
+	RetryDelivery(int64) (model.WebhookDelivery, error)
+}
+
+type webhookController struct {
+	webhookService
+}
+
+func NewWebhookController(webhookService webhookService) *webhookController {
+	return &webhookController{webhookService}
+}
+
+func (webhookController *webhookController) GetWebhooks(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(webhookController.webhookService.GetWebhooks())
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (webhookController *webhookController) GetWebhook(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseWebhookId(w, req)
+	if !ok {
+		return
+	}
+
+	webhook, err := webhookController.webhookService.GetWebhook(id)
+	if err == db.ErrorWebhookNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no webhook found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(webhook)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// AddWebhook subscribes a URL to events. The response is the only one that
+// includes the secret deliveries are signed with.
+func (webhookController *webhookController) AddWebhook(w http.ResponseWriter, req *http.Request) {
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var newWebhook model.Webhook
+	err := json.Unmarshal(requestBody, &newWebhook)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid webhook json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedWebhook, err := webhookController.webhookService.AddWebhook(newWebhook)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedWebhook)
+	writeJSON(w, http.StatusCreated, body)
+}
+
+// UpdateWebhook replaces the URL and event types of a webhook, keeping its
+// secret unless a new one is given
+func (webhookController *webhookController) UpdateWebhook(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseWebhookId(w, req)
+	if !ok {
+		return
+	}
+	requestBody, _ := ioutil.ReadAll(req.Body)
+
+	var webhook model.Webhook
+	err := json.Unmarshal(requestBody, &webhook)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid webhook json: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	savedWebhook, err := webhookController.webhookService.UpdateWebhook(id, webhook)
+	if err == db.ErrorWebhookNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no webhook found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body, _ := json.Marshal(savedWebhook)
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (webhookController *webhookController) DeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseWebhookId(w, req)
+	if !ok {
+		return
+	}
+
+	err := webhookController.webhookService.DeleteWebhook(id)
+	if err == db.ErrorWebhookNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no webhook found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries lists the last deliveries to a webhook, newest first
+func (webhookController *webhookController) GetDeliveries(w http.ResponseWriter, req *http.Request) {
+	id, ok := parseWebhookId(w, req)
+	if !ok {
+		return
+	}
+
+	deliveries, err := webhookController.webhookService.GetDeliveries(id)
+	if err == db.ErrorWebhookNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no webhook found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(deliveries)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GetDeadLetters lists the deliveries that ran out of attempts, newest first
+func (webhookController *webhookController) GetDeadLetters(w http.ResponseWriter, req *http.Request) {
+	body, _ := json.Marshal(webhookController.webhookService.GetDeadLetters())
+	writeJSON(w, http.StatusOK, body)
+}
+
+// RetryDelivery queues a dead-lettered delivery again
+func (webhookController *webhookController) RetryDelivery(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid delivery id: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := webhookController.webhookService.RetryDelivery(id)
+	if err == db.ErrorDeliveryNotFound {
+		http.Error(w, fmt.Sprintf("Not Found - no delivery found with id: %v", id), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrorDeliveryNotDead) {
+		http.Error(w, fmt.Sprintf("Conflict - %v", err), http.StatusConflict)
+		return
+	}
+	if err == webhook.ErrorQueueFull {
+		http.Error(w, fmt.Sprintf("Service Unavailable - %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal Server Error - %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := json.Marshal(delivery)
+	writeJSON(w, http.StatusAccepted, body)
+}
+
+func parseWebhookId(w http.ResponseWriter, req *http.Request) (int32, bool) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request - invalid webhook id: %v", err), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return int32(id), true
+}
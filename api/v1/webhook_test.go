@@ -0,0 +1,143 @@
+package api_v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/webhook"
+)
+
+var testWebhook = model.Webhook{Id: 1, Url: "https://partner.example.com/hook"}
+
+type mockWebhookService struct {
+	queueFull bool
+}
+
+func (mockWebhookService *mockWebhookService) GetWebhooks() []model.Webhook {
+	return []model.Webhook{testWebhook}
+}
+
+func (mockWebhookService *mockWebhookService) GetWebhook(id int32) (model.Webhook, error) {
+	if id != testWebhook.Id {
+		return model.Webhook{}, db.ErrorWebhookNotFound
+	}
+	return testWebhook, nil
+}
+
+func (mockWebhookService *mockWebhookService) AddWebhook(webhook model.Webhook) (model.Webhook, error) {
+	if !strings.HasPrefix(webhook.Url, "https://") {
+		return model.Webhook{}, errors.New("invalid url")
+	}
+	webhook.Id = 2
+	webhook.Secret = "generated"
+	return webhook, nil
+}
+
+func (mockWebhookService *mockWebhookService) UpdateWebhook(id int32, webhook model.Webhook) (model.Webhook, error) {
+	if _, err := mockWebhookService.GetWebhook(id); err != nil {
+		return model.Webhook{}, err
+	}
+	webhook.Id = id
+	return webhook, nil
+}
+
+func (mockWebhookService *mockWebhookService) DeleteWebhook(id int32) error {
+	_, err := mockWebhookService.GetWebhook(id)
+	return err
+}
+
+func (mockWebhookService *mockWebhookService) GetDeliveries(id int32) ([]model.WebhookDelivery, error) {
+	if _, err := mockWebhookService.GetWebhook(id); err != nil {
+		return nil, err
+	}
+	return []model.WebhookDelivery{{Id: 1, WebhookId: id, Status: model.DeliverySucceeded}}, nil
+}
+
+func (mockWebhookService *mockWebhookService) GetDeadLetters() []model.WebhookDelivery {
+	return []model.WebhookDelivery{{Id: 2, WebhookId: testWebhook.Id, Status: model.DeliveryDead}}
+}
+
+func (mockWebhookService *mockWebhookService) RetryDelivery(id int64) (model.WebhookDelivery, error) {
+	switch {
+	case id == 1:
+		return model.WebhookDelivery{}, fmt.Errorf("%w: delivery 1 is succeeded", service.ErrorDeliveryNotDead)
+	case id != 2:
+		return model.WebhookDelivery{}, db.ErrorDeliveryNotFound
+	case mockWebhookService.queueFull:
+		return model.WebhookDelivery{}, webhook.ErrorQueueFull
+	}
+	return model.WebhookDelivery{Id: id, Status: model.DeliveryPending}, nil
+}
+
+func newTestWebhookRouter(webhookService webhookService) http.Handler {
+	return newTestRouter(Controllers{Webhook: NewWebhookController(webhookService)})
+}
+
+func TestWebhook_1(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{method: "GET", path: "/webhook", expected: http.StatusOK},
+		{method: "POST", path: "/webhook", body: `{"url": "https://partner.example.com/hook"}`, expected: http.StatusCreated},
+		{method: "POST", path: "/webhook", body: `{"url": "ftp://partner.example.com"}`, expected: http.StatusBadRequest},
+		{method: "POST", path: "/webhook", body: `{"url": `, expected: http.StatusBadRequest},
+		{method: "GET", path: "/webhook/1", expected: http.StatusOK},
+		{method: "GET", path: "/webhook/2", expected: http.StatusNotFound},
+		{method: "GET", path: "/webhook/one", expected: http.StatusBadRequest},
+		{method: "PUT", path: "/webhook/1", body: `{"url": "https://partner.example.com/other"}`, expected: http.StatusOK},
+		{method: "PUT", path: "/webhook/2", body: `{"url": "https://partner.example.com/other"}`, expected: http.StatusNotFound},
+		{method: "DELETE", path: "/webhook/1", expected: http.StatusNoContent},
+		{method: "DELETE", path: "/webhook/2", expected: http.StatusNotFound},
+		{method: "GET", path: "/webhook/1/delivery", expected: http.StatusOK},
+		{method: "GET", path: "/webhook/2/delivery", expected: http.StatusNotFound},
+		{method: "GET", path: "/webhook/dead-letter", expected: http.StatusOK},
+		{method: "POST", path: "/webhook/dead-letter/2/retry", expected: http.StatusAccepted},
+		{method: "POST", path: "/webhook/dead-letter/1/retry", expected: http.StatusConflict},
+		{method: "POST", path: "/webhook/dead-letter/3/retry", expected: http.StatusNotFound},
+	}
+
+	handler := newTestWebhookRouter(&mockWebhookService{})
+
+	for _, test := range tests {
+		// Webhooks send events to any URL, so they are only managed by operators
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusUnauthorized {
+			t.Fatalf("%v %v: expected response code to be %v without the admin token, got %v", test.method, test.path, http.StatusUnauthorized, responseRecorder.Code)
+		}
+
+		req = httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		responseRecorder = httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("%v %v: expected response code to be %v, got %v", test.method, test.path, test.expected, responseRecorder.Code)
+		}
+	}
+}
+
+func TestRetryDelivery_1(t *testing.T) {
+	handler := newTestWebhookRouter(&mockWebhookService{queueFull: true})
+
+	req := httptest.NewRequest("POST", "/webhook/dead-letter/2/retry", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	responseRecorder := httptest.NewRecorder()
+	handler.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+}
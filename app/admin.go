@@ -0,0 +1,549 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/filelock"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+const adminUsage = `Usage:
+  pack-and-go admin city list [flags]
+  pack-and-go admin city add -name <name> [-latitude <lat> -longitude <lon>] [-country <code>] [-timezone <zone>] [-address <address>] [flags]
+  pack-and-go admin city rename -id <id> -name <name> [flags]
+  pack-and-go admin trip list [-origin <city>] [-destination <city>] [flags]
+  pack-and-go admin trip add -origin <city> -destination <city> -dates <weekdays> -price <price> [flags]
+  pack-and-go admin trip update -id <id> [-origin <city>] [-destination <city>] [-dates <weekdays>] [-price <price>] [flags]
+  pack-and-go admin trip delete -id <id> [flags]
+  pack-and-go admin validate [flags]
+
+Cities are given by id or by name. Flags of every command:
+  -server <url>        API of a running server to run against, instead of the stores
+  -token <token>       Admin token of the server, $PACKANDGO_ADMIN_TOKEN by default
+  -city_store <dsn>    City store to run against, file://cities.txt by default
+  -trip_store <dsn>    Trip store to run against, such as journal:///var/lib/packandgo
+  -audit_file <file>   Audit log of the changes made against the stores, audit.log by default
+  -format table|json   Output format, table by default`
+
+// Exit codes of pack-and-go admin, on top of exitValid, exitInvalid when
+// validate finds problems, exitFailure and exitUsage
+const (
+	exitNotFound = 3
+	// The city name is taken, or the trip was modified while it was being changed
+	exitConflict = 4
+)
+
+// adminFlags are the flags of every admin command, telling what it runs
+// against and how it prints its results
+type adminFlags struct {
+	server        *string
+	token         *string
+	cityStore     *string
+	tripStore     *string
+	auditFilePath *string
+	format        *string
+}
+
+func newAdminFlags(name string, stderr io.Writer) (*flag.FlagSet, *adminFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	return flags, &adminFlags{
+		server:        flags.String("server", "", "URL of a running server to run against, instead of the stores"),
+		token:         flags.String("token", os.Getenv(adminTokenEnv), "Admin token of the server, $"+adminTokenEnv+" by default"),
+		cityStore:     flags.String("city_store", "", "DSN of the city store, "+defaultCityStore+" by default"),
+		tripStore:     flags.String("trip_store", "", "DSN of the trip store, such as journal:///var/lib/packandgo"),
+		auditFilePath: flags.String("audit_file", "audit.log", "Path to the audit log of changes made against the stores"),
+		format:        flags.String("format", "table", "Output format, table or json"),
+	}
+}
+
+// open returns the backend the flags point to. trips tells whether the
+// command needs the trip store, which can not be a memory:// store outside
+// the server.
+func (adminFlags *adminFlags) open(trips bool) (adminBackend, int, error) {
+	if *adminFlags.format != "table" && *adminFlags.format != "json" {
+		return nil, exitUsage, fmt.Errorf("unsupported format: %v", *adminFlags.format)
+	}
+
+	if *adminFlags.server != "" {
+		if *adminFlags.cityStore != "" || *adminFlags.tripStore != "" {
+			return nil, exitUsage, fmt.Errorf("-server can not be used with -city_store or -trip_store")
+		}
+		return newRemoteAdminBackend(*adminFlags.server, *adminFlags.token), exitValid, nil
+	}
+
+	tripDSN := ""
+	if trips {
+		tripDSN = *adminFlags.tripStore
+		dsn, err := url.Parse(tripDSN)
+		if tripDSN == "" || (err == nil && dsn.Scheme == "memory") {
+			return nil, exitUsage, fmt.Errorf("trips of a memory:// store only live in the server, use -trip_store with a persistent store or -server")
+		}
+	}
+
+	backend, err := openLocalAdminBackend(*adminFlags.cityStore, tripDSN, *adminFlags.auditFilePath)
+	if errors.Is(err, filelock.ErrorLocked) {
+		return nil, exitFailure, fmt.Errorf("%w, stop the server or run the command against it with -server", err)
+	}
+	if err != nil {
+		return nil, exitFailure, err
+	}
+
+	return backend, exitValid, nil
+}
+
+// print writes value as JSON, or with table as a table
+func (adminFlags *adminFlags) print(w io.Writer, value interface{}, table func(w io.Writer)) {
+	if *adminFlags.format == "json" {
+		body, _ := json.MarshalIndent(value, "", "  ")
+		fmt.Fprintln(w, string(body))
+		return
+	}
+
+	table(w)
+}
+
+// runAdminCommand changes cities and trips for operators, against the stores
+// when the server is stopped, or against the API of a running server
+func runAdminCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "validate" {
+		return runAdminValidate(args[1:], stdout, stderr)
+	}
+	if len(args) < 2 {
+		fmt.Fprintln(stderr, adminUsage)
+		return exitUsage
+	}
+
+	run, ok := map[string]func([]string, io.Writer, io.Writer) int{
+		"city list":   runAdminCityList,
+		"city add":    runAdminCityAdd,
+		"city rename": runAdminCityRename,
+		"trip list":   runAdminTripList,
+		"trip add":    runAdminTripAdd,
+		"trip update": runAdminTripUpdate,
+		"trip delete": runAdminTripDelete,
+	}[args[0]+" "+args[1]]
+	if !ok {
+		fmt.Fprintln(stderr, adminUsage)
+		return exitUsage
+	}
+
+	return run(args[2:], stdout, stderr)
+}
+
+// adminError reports an error of a command, returning its exit code
+func adminError(stderr io.Writer, err error) int {
+	fmt.Fprintln(stderr, err)
+
+	switch {
+	case errors.Is(err, db.ErrorCityNotFound), errors.Is(err, db.ErrorTripNotFound):
+		return exitNotFound
+	case errors.Is(err, service.ErrorCityExists), errors.Is(err, db.ErrorVersionMismatch):
+		return exitConflict
+	default:
+		return exitFailure
+	}
+}
+
+func adminUsageError(stderr io.Writer, err error) int {
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+	fmt.Fprintln(stderr, adminUsage)
+	return exitUsage
+}
+
+// adminUsageOrFailure reports an error opening the backend of a command
+func adminUsageOrFailure(stderr io.Writer, exitCode int, err error) int {
+	if exitCode == exitUsage {
+		return adminUsageError(stderr, err)
+	}
+
+	fmt.Fprintln(stderr, err)
+	return exitCode
+}
+
+func runAdminCityList(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin city list", stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(false)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	cities, err := backend.GetAllCities()
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, cities, func(w io.Writer) { printCities(w, cities) })
+	return exitValid
+}
+
+func runAdminCityAdd(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin city add", stderr)
+	name := flags.String("name", "", "Name of the city")
+	latitude := flags.Float64("latitude", 0, "Latitude of the city")
+	longitude := flags.Float64("longitude", 0, "Longitude of the city")
+	country := flags.String("country", "", "ISO 3166-1 alpha-2 country code of the city")
+	timezone := flags.String("timezone", "", "IANA time zone of the city, such as Europe/Madrid")
+	address := flags.String("address", "", "Address of the station of the city")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *name == "" || flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(false)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	city, err := backend.AddCity(model.City{
+		Name:      *name,
+		Latitude:  *latitude,
+		Longitude: *longitude,
+		Country:   *country,
+		Timezone:  *timezone,
+		Address:   *address,
+	})
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, city, func(w io.Writer) { printCities(w, []model.City{city}) })
+	return exitValid
+}
+
+// runAdminCityRename renames a city, which keeps its id and so its trips
+func runAdminCityRename(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin city rename", stderr)
+	id := flags.Int("id", 0, "Id of the city to rename")
+	name := flags.String("name", "", "New name of the city")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *id <= 0 || *name == "" || flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(false)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	city, err := backend.GetCityById(int32(*id))
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	city.Name = *name
+	city, err = backend.UpdateCity(city)
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, city, func(w io.Writer) { printCities(w, []model.City{city}) })
+	return exitValid
+}
+
+func runAdminTripList(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin trip list", stderr)
+	origin := flags.String("origin", "", "Only list trips from this city")
+	destination := flags.String("destination", "", "Only list trips to this city")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(true)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	query := model.TripQuery{}
+	query.OriginId, err = resolveCity(backend, *origin)
+	if err == nil {
+		query.DestinationId, err = resolveCity(backend, *destination)
+	}
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	trips, err := backend.FindTrips(query)
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, trips, func(w io.Writer) { printTrips(w, trips) })
+	return exitValid
+}
+
+func runAdminTripAdd(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin trip add", stderr)
+	origin := flags.String("origin", "", "City the trip leaves from")
+	destination := flags.String("destination", "", "City the trip goes to")
+	dates := flags.String("dates", "", "Weekdays the trip runs on, such as \"Mon Wed Fri\"")
+	price := flags.Float64("price", 0, "Price of the trip")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *origin == "" || *destination == "" || *dates == "" || flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(true)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	trip := model.Trip{Dates: *dates, Price: *price}
+	trip.OriginId, err = resolveCity(backend, *origin)
+	if err == nil {
+		trip.DestinationId, err = resolveCity(backend, *destination)
+	}
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	tripPretty, err := backend.AddTrip(trip)
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, tripPretty, func(w io.Writer) { printTrips(w, []model.TripPretty{tripPretty}) })
+	return exitValid
+}
+
+// runAdminTripUpdate changes the given fields of a trip. It fails with
+// exitConflict if the trip is changed by someone else in the meantime.
+func runAdminTripUpdate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin trip update", stderr)
+	id := flags.Int("id", 0, "Id of the trip to update")
+	origin := flags.String("origin", "", "City the trip leaves from")
+	destination := flags.String("destination", "", "City the trip goes to")
+	dates := flags.String("dates", "", "Weekdays the trip runs on, such as \"Mon Wed Fri\"")
+	price := flags.Float64("price", 0, "Price of the trip")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *id <= 0 || flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(true)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	trip, _, err := backend.GetTripById(int32(*id))
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	flags.Visit(func(set *flag.Flag) {
+		switch set.Name {
+		case "origin":
+			trip.OriginId, err = resolveCity(backend, *origin)
+		case "destination":
+			trip.DestinationId, err = resolveCity(backend, *destination)
+		case "dates":
+			trip.Dates = *dates
+		case "price":
+			trip.Price = *price
+		}
+	})
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	tripPretty, err := backend.UpdateTrip(trip)
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, tripPretty, func(w io.Writer) { printTrips(w, []model.TripPretty{tripPretty}) })
+	return exitValid
+}
+
+// runAdminTripDelete deletes a trip and prints it. Its bookings are kept.
+func runAdminTripDelete(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin trip delete", stderr)
+	id := flags.Int("id", 0, "Id of the trip to delete")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *id <= 0 || flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(true)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	trip, tripPretty, err := backend.GetTripById(int32(*id))
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	err = backend.DeleteTrip(trip)
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	adminFlags.print(stdout, tripPretty, func(w io.Writer) { printTrips(w, []model.TripPretty{tripPretty}) })
+	return exitValid
+}
+
+// validationReport is the result of admin validate
+type validationReport struct {
+	Cities   int      `json:"cities"`
+	Trips    int      `json:"trips"`
+	Problems []string `json:"problems"`
+}
+
+// runAdminValidate checks the cities and trips as stored, such as after
+// cities.txt has been edited by hand, exiting with exitInvalid if any is wrong
+func runAdminValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, adminFlags := newAdminFlags("admin validate", stderr)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		return adminUsageError(stderr, nil)
+	}
+
+	backend, exitCode, err := adminFlags.open(true)
+	if err != nil {
+		return adminUsageOrFailure(stderr, exitCode, err)
+	}
+	defer backend.Close()
+
+	cities, trips, err := backend.Snapshot()
+	if err != nil {
+		return adminError(stderr, err)
+	}
+
+	report := validationReport{Cities: len(cities), Trips: len(trips), Problems: validateCatalog(cities, trips)}
+	adminFlags.print(stdout, report, func(w io.Writer) {
+		for _, problem := range report.Problems {
+			fmt.Fprintln(w, problem)
+		}
+		fmt.Fprintf(w, "%v cities and %v trips checked, %v problems\n", report.Cities, report.Trips, len(report.Problems))
+	})
+
+	if len(report.Problems) > 0 {
+		return exitInvalid
+	}
+	return exitValid
+}
+
+// validateCatalog returns what is wrong with cities and trips that the API
+// would have refused: cities without a name or with the name of another city,
+// and trips between missing cities or with invalid dates
+func validateCatalog(cities []model.City, trips []model.Trip) []string {
+	problems := []string{}
+
+	ids := map[int32]bool{}
+	names := map[string]int32{}
+	for _, city := range cities {
+		ids[city.Id] = true
+		name := strings.ToLower(strings.TrimSpace(city.Name))
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("city %v has no name", city.Id))
+			continue
+		}
+		if other, taken := names[name]; taken {
+			problems = append(problems, fmt.Sprintf("cities %v and %v are both named %v", other, city.Id, city.Name))
+			continue
+		}
+		names[name] = city.Id
+	}
+
+	for _, trip := range trips {
+		if !ids[trip.OriginId] {
+			problems = append(problems, fmt.Sprintf("trip %v leaves from city %v, which does not exist", trip.Id, trip.OriginId))
+		}
+		if !ids[trip.DestinationId] {
+			problems = append(problems, fmt.Sprintf("trip %v goes to city %v, which does not exist", trip.Id, trip.DestinationId))
+		}
+		if err := service.ValidateDates(trip.Dates); err != nil {
+			problems = append(problems, fmt.Sprintf("trip %v has %v", trip.Id, err))
+		}
+	}
+
+	return problems
+}
+
+// resolveCity returns the id of a city given by id or by name, or 0 if it is empty
+func resolveCity(backend adminBackend, value string) (int32, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if id, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return int32(id), nil
+	}
+
+	cities, err := backend.GetAllCities()
+	if err != nil {
+		return 0, err
+	}
+	for _, city := range cities {
+		if strings.EqualFold(strings.TrimSpace(city.Name), strings.TrimSpace(value)) {
+			return city.Id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: no city named %v", db.ErrorCityNotFound, value)
+}
+
+func printCities(w io.Writer, cities []model.City) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tCOUNTRY\tTIMEZONE\tCOORDINATES")
+	for _, city := range cities {
+		coordinates := ""
+		if city.HasCoordinates() {
+			coordinates = fmt.Sprintf("%v,%v", city.Latitude, city.Longitude)
+		}
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", city.Id, city.Name, city.Country, city.Timezone, coordinates)
+	}
+	table.Flush()
+}
+
+func printTrips(w io.Writer, trips []model.TripPretty) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tORIGIN\tDESTINATION\tDATES\tPRICE")
+	for _, trip := range trips {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%.2f\n", trip.Id, trip.Origin, trip.Destination, trip.Dates, trip.Price)
+	}
+	table.Flush()
+}
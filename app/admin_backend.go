@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/backup"
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+)
+
+// Actor of the changes made by admin commands against the stores
+const adminActor = "admin"
+
+// Time a request of an admin command to the server can take
+const adminTimeout = 30 * time.Second
+
+// adminBackend is where admin commands read and write cities and trips: the
+// stores themselves, or the API of a running server. Both fail with the
+// errors of the db and service packages, so commands exit with the same code
+// whichever they run against.
+type adminBackend interface {
+	GetAllCities() ([]model.City, error)
+	GetCityById(int32) (model.City, error)
+	AddCity(model.City) (model.City, error)
+	UpdateCity(model.City) (model.City, error)
+	FindTrips(model.TripQuery) ([]model.TripPretty, error)
+	// GetTripById returns the trip with its current version, and as the API shows it
+	GetTripById(int32) (model.Trip, model.TripPretty, error)
+	AddTrip(model.Trip) (model.TripPretty, error)
+	// UpdateTrip and DeleteTrip fail with db.ErrorVersionMismatch if the trip
+	// has been modified since the version of trip
+	UpdateTrip(model.Trip) (model.TripPretty, error)
+	DeleteTrip(model.Trip) error
+	// Snapshot returns every city and trip as stored, even if they are not valid
+	Snapshot() ([]model.City, []model.Trip, error)
+	Close() error
+}
+
+type adminCityService interface {
+	GetAllCities() ([]model.City, error)
+	GetCityById(int32) (model.City, error)
+	AddCity(context.Context, model.City) (model.City, error)
+	UpdateCity(context.Context, model.City) (model.City, error)
+}
+
+type adminTripService interface {
+	GetTripById(int32) (model.Trip, error)
+	FindTrips(model.TripQuery) ([]model.Trip, error)
+	AddTrip(context.Context, model.Trip) (model.Trip, error)
+	UpdateTrip(context.Context, int32, model.Trip, int32) (model.Trip, error)
+	DeleteTrip(context.Context, int32, int32) error
+	GetTripPretty(model.Trip) (model.TripPretty, error)
+}
+
+// localAdminBackend changes the stores through the same services as the
+// server, so changes are validated and audited as if made through the API
+type localAdminBackend struct {
+	ctx         context.Context
+	cityStore   db.CityStore
+	tripStore   db.TripStore
+	cityService adminCityService
+	tripService adminTripService
+	closers     []io.Closer
+}
+
+// openLocalAdminBackend opens the city store, and the trip store unless its
+// DSN is empty. The audit log is opened last, so it is not created for
+// stores that can not be opened.
+func openLocalAdminBackend(cityDSN string, tripDSN string, auditFilePath string) (*localAdminBackend, error) {
+	backend := &localAdminBackend{ctx: audit.WithActor(context.Background(), adminActor)}
+
+	if cityDSN == "" {
+		cityDSN = defaultCityStore
+	}
+	cityStore, err := db.OpenCityStore(cityDSN)
+	if err != nil {
+		return nil, fmt.Errorf("could not open city store: %w", err)
+	}
+	backend.cityStore = cityStore
+	backend.addCloser(cityStore)
+
+	if tripDSN != "" {
+		tripStore, err := db.OpenTripStore(tripDSN)
+		if err != nil {
+			backend.Close()
+			return nil, fmt.Errorf("could not open trip store: %w", err)
+		}
+		backend.tripStore = tripStore
+		backend.addCloser(tripStore)
+	}
+
+	auditLog, err := audit.NewLog(auditFilePath)
+	if err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	// Closed last, after the stores, as the closers are closed in reverse
+	backend.closers = append([]io.Closer{auditLog}, backend.closers...)
+
+	backend.cityService = service.NewCityService(backend.cityStore, auditLog)
+	if backend.tripStore != nil {
+		// Nobody subscribes to the events of admin commands
+		eventHub := events.NewHub(1)
+		backend.closers = append(backend.closers, eventHub)
+		backend.tripService = service.NewTripService(backend.cityStore, backend.tripStore, db.NewDisruptionDB(), eventHub, auditLog)
+	}
+
+	return backend, nil
+}
+
+func (backend *localAdminBackend) addCloser(store interface{}) {
+	if closer, ok := store.(io.Closer); ok {
+		backend.closers = append(backend.closers, closer)
+	}
+}
+
+func (backend *localAdminBackend) GetAllCities() ([]model.City, error) {
+	return backend.cityService.GetAllCities()
+}
+
+func (backend *localAdminBackend) GetCityById(id int32) (model.City, error) {
+	return backend.cityService.GetCityById(id)
+}
+
+func (backend *localAdminBackend) AddCity(city model.City) (model.City, error) {
+	return backend.cityService.AddCity(backend.ctx, city)
+}
+
+func (backend *localAdminBackend) UpdateCity(city model.City) (model.City, error) {
+	return backend.cityService.UpdateCity(backend.ctx, city)
+}
+
+func (backend *localAdminBackend) FindTrips(query model.TripQuery) ([]model.TripPretty, error) {
+	trips, err := backend.tripService.FindTrips(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.prettyTrips(trips)
+}
+
+func (backend *localAdminBackend) GetTripById(id int32) (model.Trip, model.TripPretty, error) {
+	trip, err := backend.tripService.GetTripById(id)
+	if err != nil {
+		return model.Trip{}, model.TripPretty{}, err
+	}
+
+	tripPretty, err := backend.tripService.GetTripPretty(trip)
+	return trip, tripPretty, err
+}
+
+func (backend *localAdminBackend) AddTrip(trip model.Trip) (model.TripPretty, error) {
+	trip, err := backend.tripService.AddTrip(backend.ctx, trip)
+	if err != nil {
+		return model.TripPretty{}, err
+	}
+
+	return backend.tripService.GetTripPretty(trip)
+}
+
+func (backend *localAdminBackend) UpdateTrip(trip model.Trip) (model.TripPretty, error) {
+	trip, err := backend.tripService.UpdateTrip(backend.ctx, trip.Id, trip, trip.Version)
+	if err != nil {
+		return model.TripPretty{}, err
+	}
+
+	return backend.tripService.GetTripPretty(trip)
+}
+
+func (backend *localAdminBackend) DeleteTrip(trip model.Trip) error {
+	return backend.tripService.DeleteTrip(backend.ctx, trip.Id, trip.Version)
+}
+
+func (backend *localAdminBackend) Snapshot() ([]model.City, []model.Trip, error) {
+	cities, err := backend.cityStore.GetAllCities()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trips, err := backend.tripStore.GetAllTrips()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cities, trips, nil
+}
+
+func (backend *localAdminBackend) prettyTrips(trips []model.Trip) ([]model.TripPretty, error) {
+	result := []model.TripPretty{}
+	for _, trip := range trips {
+		tripPretty, err := backend.tripService.GetTripPretty(trip)
+		if err != nil {
+			return nil, fmt.Errorf("trip %v: %w", trip.Id, err)
+		}
+		result = append(result, tripPretty)
+	}
+
+	return result, nil
+}
+
+// Close closes the stores, and the audit log last so it records every change
+func (backend *localAdminBackend) Close() error {
+	var result error
+	for i := len(backend.closers) - 1; i >= 0; i-- {
+		if err := backend.closers[i].Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
+// remoteAdminBackend uses the API of a running server
+type remoteAdminBackend struct {
+	serverURL string
+	// Admin token sent with every request, so changes are recorded as made by the admin
+	token  string
+	client *http.Client
+}
+
+func newRemoteAdminBackend(serverURL string, token string) *remoteAdminBackend {
+	return &remoteAdminBackend{
+		serverURL: strings.TrimRight(serverURL, "/") + "/api/v1",
+		token:     token,
+		client:    &http.Client{Timeout: adminTimeout},
+	}
+}
+
+// remoteError is an error response of the server. It unwraps to the error
+// the server responded to, so it maps to the exit code of the same error of
+// a local store.
+type remoteError struct {
+	message string
+	err     error
+}
+
+func (remoteError remoteError) Error() string {
+	return remoteError.message
+}
+
+func (remoteError remoteError) Unwrap() error {
+	return remoteError.err
+}
+
+// do sends a request with body as JSON, unless it is nil, and decodes the
+// response into result, unless it is nil. Not Found responses fail with notFound.
+func (backend *remoteAdminBackend) do(method string, path string, ifMatch string, body interface{}, result interface{}, notFound error) (http.Header, error) {
+	var requestBody io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = bytes.NewReader(content)
+	}
+
+	req, err := newAdminRequest(method, backend.serverURL+path, requestBody, backend.token)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+
+	res, err := backend.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach the server: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+		remoteErr := remoteError{message: strings.TrimSpace(string(message))}
+		if remoteErr.message == "" {
+			remoteErr.message = res.Status
+		}
+
+		switch res.StatusCode {
+		case http.StatusNotFound:
+			remoteErr.err = notFound
+		case http.StatusConflict:
+			remoteErr.err = service.ErrorCityExists
+		case http.StatusPreconditionFailed:
+			remoteErr.err = db.ErrorVersionMismatch
+		}
+		return nil, remoteErr
+	}
+
+	if result != nil {
+		err = json.NewDecoder(res.Body).Decode(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response of the server: %w", err)
+		}
+	}
+
+	return res.Header, nil
+}
+
+func (backend *remoteAdminBackend) GetAllCities() ([]model.City, error) {
+	var cities []model.City
+	_, err := backend.do(http.MethodGet, "/city", "", nil, &cities, nil)
+	return cities, err
+}
+
+func (backend *remoteAdminBackend) GetCityById(id int32) (model.City, error) {
+	var city model.City
+	_, err := backend.do(http.MethodGet, fmt.Sprintf("/city/%v", id), "", nil, &city, db.ErrorCityNotFound)
+	return city, err
+}
+
+func (backend *remoteAdminBackend) AddCity(city model.City) (model.City, error) {
+	var savedCity model.City
+	_, err := backend.do(http.MethodPost, "/city", "", city, &savedCity, nil)
+	return savedCity, err
+}
+
+func (backend *remoteAdminBackend) UpdateCity(city model.City) (model.City, error) {
+	var savedCity model.City
+	_, err := backend.do(http.MethodPut, fmt.Sprintf("/city/%v", city.Id), "", city, &savedCity, db.ErrorCityNotFound)
+	return savedCity, err
+}
+
+func (backend *remoteAdminBackend) FindTrips(query model.TripQuery) ([]model.TripPretty, error) {
+	params := url.Values{}
+	if query.OriginId != 0 {
+		params.Set("originId", strconv.Itoa(int(query.OriginId)))
+	}
+	if query.DestinationId != 0 {
+		params.Set("destinationId", strconv.Itoa(int(query.DestinationId)))
+	}
+
+	path := "/trip"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var trips []model.TripPretty
+	_, err := backend.do(http.MethodGet, path, "", nil, &trips, nil)
+	return trips, err
+}
+
+// GetTripById takes the version of the trip from its ETag, and the ids of its
+// cities from their names, which the API shows instead
+func (backend *remoteAdminBackend) GetTripById(id int32) (model.Trip, model.TripPretty, error) {
+	var tripPretty model.TripPretty
+	header, err := backend.do(http.MethodGet, fmt.Sprintf("/trip/%v", id), "", nil, &tripPretty, db.ErrorTripNotFound)
+	if err != nil {
+		return model.Trip{}, model.TripPretty{}, err
+	}
+
+	version, err := tripVersionFromETag(header.Get("ETag"))
+	if err != nil {
+		return model.Trip{}, model.TripPretty{}, err
+	}
+
+	cities, err := backend.GetAllCities()
+	if err != nil {
+		return model.Trip{}, model.TripPretty{}, err
+	}
+
+	trip := model.Trip{Id: tripPretty.Id, Dates: tripPretty.Dates, Price: tripPretty.Price, Version: version}
+	for _, city := range cities {
+		if city.Name == tripPretty.Origin {
+			trip.OriginId = city.Id
+		}
+		if city.Name == tripPretty.Destination {
+			trip.DestinationId = city.Id
+		}
+	}
+
+	return trip, tripPretty, nil
+}
+
+func (backend *remoteAdminBackend) AddTrip(trip model.Trip) (model.TripPretty, error) {
+	var tripPretty model.TripPretty
+	_, err := backend.do(http.MethodPost, "/trip", "", trip, &tripPretty, nil)
+	return tripPretty, err
+}
+
+func (backend *remoteAdminBackend) UpdateTrip(trip model.Trip) (model.TripPretty, error) {
+	var tripPretty model.TripPretty
+	_, err := backend.do(http.MethodPut, fmt.Sprintf("/trip/%v", trip.Id), tripETag(trip), trip, &tripPretty, db.ErrorTripNotFound)
+	return tripPretty, err
+}
+
+func (backend *remoteAdminBackend) DeleteTrip(trip model.Trip) error {
+	_, err := backend.do(http.MethodDelete, fmt.Sprintf("/trip/%v", trip.Id), tripETag(trip), nil, nil, db.ErrorTripNotFound)
+	return err
+}
+
+// Snapshot reads the cities and trips of a backup, as the API refuses to
+// show trips between cities that do not exist
+func (backend *remoteAdminBackend) Snapshot() ([]model.City, []model.Trip, error) {
+	req, err := newAdminRequest(http.MethodGet, backend.serverURL+"/backup", nil, backend.token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := backend.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not reach the server: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, responseError(res)
+	}
+
+	archive, err := backup.Decode(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return archive.Cities, archive.Trips, nil
+}
+
+func (backend *remoteAdminBackend) Close() error {
+	return nil
+}
+
+// tripETag is the ETag of a version of a trip, which the server accepts in
+// If-Match whether or not the trip has disruptions
+func tripETag(trip model.Trip) string {
+	return fmt.Sprintf(`"%v-%v"`, trip.Id, trip.Version)
+}
+
+// tripVersionFromETag returns the version in an ETag such as "3-2" or "3-2-9f2c4a1b"
+func tripVersionFromETag(etag string) (int32, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), "-")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid trip ETag: %v", etag)
+	}
+
+	version, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trip ETag: %v", etag)
+	}
+
+	return int32(version), nil
+}
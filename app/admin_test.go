@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/filelock"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// adminTestTargets returns the flags to run admin commands against the
+// stores, and against a server with the same cities and trips
+func adminTestTargets(t *testing.T) map[string][]string {
+	cities, err := ioutil.ReadFile("cities_test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	directory := t.TempDir()
+	citiesPath := filepath.Join(directory, "cities.txt")
+	if err := os.WriteFile(citiesPath, cities, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return map[string][]string{
+		"local": {
+			"-city_store", "file://" + citiesPath,
+			"-trip_store", "journal://" + filepath.Join(directory, "trips.log"),
+			"-audit_file", filepath.Join(directory, "audit.log"),
+		},
+		"remote": {"-server", newTestBackupServer(t).URL, "-token", testAdminToken},
+	}
+}
+
+func runAdmin(args []string, target []string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	exitCode := runAdminCommand(append(args, target...), &stdout, &stderr)
+	return exitCode, stdout.String(), stderr.String()
+}
+
+func TestRunAdminCommand_1(t *testing.T) {
+	for name, target := range adminTestTargets(t) {
+		exitCode, stdout, stderr := runAdmin([]string{"city", "add", "-name", "Bilbao", "-country", "ES"}, target)
+		if exitCode != exitValid || !strings.Contains(stdout, "7   Bilbao") {
+			t.Fatalf("%v: expected Bilbao to be added as city 7, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+		if exitCode, _, _ := runAdmin([]string{"city", "add", "-name", "bilbao"}, target); exitCode != exitConflict {
+			t.Fatalf("%v: expected exit code %v for a taken name, got %v", name, exitConflict, exitCode)
+		}
+
+		exitCode, stdout, stderr = runAdmin([]string{"city", "rename", "-id", "2", "-name", "Sevilla", "-format", "json"}, target)
+		var city model.City
+		json.Unmarshal([]byte(stdout), &city)
+		if exitCode != exitValid || city.Id != 2 || city.Name != "Sevilla" {
+			t.Fatalf("%v: expected city 2 to be renamed, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+		if exitCode, _, _ := runAdmin([]string{"city", "rename", "-id", "20", "-name", "Bilbao"}, target); exitCode != exitNotFound {
+			t.Fatalf("%v: expected exit code %v for a missing city, got %v", name, exitNotFound, exitCode)
+		}
+
+		exitCode, stdout, stderr = runAdmin([]string{"city", "list"}, target)
+		if exitCode != exitValid || strings.Count(stdout, "\n") != 8 || !strings.Contains(stdout, "Sevilla") {
+			t.Fatalf("%v: expected a header and 7 cities, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+	}
+}
+
+func TestRunAdminCommand_2(t *testing.T) {
+	for name, target := range adminTestTargets(t) {
+		exitCode, stdout, stderr := runAdmin([]string{"trip", "add", "-origin", "Madrid", "-destination", "4", "-dates", "Mon Fri", "-price", "25.5", "-format", "json"}, target)
+		var trip model.TripPretty
+		json.Unmarshal([]byte(stdout), &trip)
+		if exitCode != exitValid || trip.Id != 4 || trip.Origin != "Madrid" || trip.Destination != "Valencia" {
+			t.Fatalf("%v: expected trip 4 from Madrid to Valencia, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+		if exitCode, _, _ := runAdmin([]string{"trip", "add", "-origin", "1", "-destination", "2", "-dates", "Mon,Fri"}, target); exitCode != exitFailure {
+			t.Fatalf("%v: expected exit code %v for invalid dates, got %v", name, exitFailure, exitCode)
+		}
+		if exitCode, _, _ := runAdmin([]string{"trip", "add", "-origin", "Bilbao", "-destination", "2", "-dates", "Mon"}, target); exitCode != exitNotFound {
+			t.Fatalf("%v: expected exit code %v for a missing city, got %v", name, exitNotFound, exitCode)
+		}
+
+		exitCode, stdout, stderr = runAdmin([]string{"trip", "update", "-id", "4", "-price", "30"}, target)
+		if exitCode != exitValid || !strings.Contains(stdout, "Mon Fri  30.00") {
+			t.Fatalf("%v: expected the price of trip 4 to change alone, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+
+		exitCode, stdout, stderr = runAdmin([]string{"trip", "list", "-origin", "madrid"}, target)
+		if exitCode != exitValid || strings.Count(stdout, "\n") != 3 {
+			t.Fatalf("%v: expected a header and the 2 trips from Madrid, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+
+		if exitCode, _, stderr := runAdmin([]string{"trip", "delete", "-id", "4"}, target); exitCode != exitValid {
+			t.Fatalf("%v: expected trip 4 to be deleted, got %v: %v", name, exitCode, stderr)
+		}
+		if exitCode, _, _ := runAdmin([]string{"trip", "delete", "-id", "4"}, target); exitCode != exitNotFound {
+			t.Fatalf("%v: expected exit code %v for a deleted trip, got %v", name, exitNotFound, exitCode)
+		}
+
+		exitCode, stdout, stderr = runAdmin([]string{"validate"}, target)
+		if exitCode != exitValid || stdout != "6 cities and 3 trips checked, 0 problems\n" {
+			t.Fatalf("%v: expected no problems, got %v: %v%v", name, exitCode, stdout, stderr)
+		}
+	}
+}
+
+func TestRunAdminCommand_3(t *testing.T) {
+	target := adminTestTargets(t)["local"]
+	citiesPath := strings.TrimPrefix(target[1], "file://")
+
+	// Valencia removed by hand, along with the id of every city after it
+	os.WriteFile(citiesPath, []byte("Barcelona\nSeville\nMadrid\n\nbarcelona\n"), 0600)
+
+	exitCode, stdout, _ := runAdmin([]string{"validate", "-format", "json"}, target)
+	var report validationReport
+	json.Unmarshal([]byte(stdout), &report)
+
+	expected := []string{
+		"city 4 has no name",
+		"cities 1 and 5 are both named barcelona",
+		"trip 3 goes to city 6, which does not exist",
+	}
+	if exitCode != exitInvalid || report.Cities != 5 || report.Trips != 3 || strings.Join(report.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %v, got %v: %+v", expected, exitCode, report)
+	}
+}
+
+func TestRunAdminCommand_4(t *testing.T) {
+	tests := []struct {
+		args     []string
+		exitCode int
+	}{
+		{args: []string{}, exitCode: exitUsage},
+		{args: []string{"city"}, exitCode: exitUsage},
+		{args: []string{"city", "remove"}, exitCode: exitUsage},
+		{args: []string{"city", "add"}, exitCode: exitUsage},
+		{args: []string{"city", "list", "-format", "xml"}, exitCode: exitUsage},
+		{args: []string{"city", "list", "-server", "http://localhost:1", "-city_store", "file://cities.txt"}, exitCode: exitUsage},
+		{args: []string{"trip", "list"}, exitCode: exitUsage},
+		{args: []string{"trip", "list", "-trip_store", "memory://"}, exitCode: exitUsage},
+		{args: []string{"trip", "update", "-price", "10"}, exitCode: exitUsage},
+		{args: []string{"city", "list", "-city_store", "nosuchdriver://"}, exitCode: exitFailure},
+		{args: []string{"city", "list", "-server", "http://localhost:1"}, exitCode: exitFailure},
+	}
+
+	for _, test := range tests {
+		if exitCode, _, _ := runAdmin(test.args, nil); exitCode != test.exitCode {
+			t.Fatalf("expected exit code %v for %v, got %v", test.exitCode, test.args, exitCode)
+		}
+	}
+}
+
+func TestRunAdminCommand_5(t *testing.T) {
+	target := adminTestTargets(t)["local"]
+	auditFilePath := target[5]
+	tripLogPath := strings.TrimPrefix(target[3], "journal://")
+
+	// Stores held by a running server
+	auditLog, err := audit.NewLog(auditFilePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tripStore, err := db.NewTripLogDB(tripLogPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	otherAuditFile := append(append([]string{}, target[:4]...), "-audit_file", filepath.Join(t.TempDir(), "audit.log"))
+	tests := []struct {
+		args   []string
+		target []string
+	}{
+		{args: []string{"city", "add", "-name", "Bilbao"}, target: target},
+		{args: []string{"trip", "list"}, target: target},
+		{args: []string{"trip", "list"}, target: otherAuditFile},
+	}
+
+	for _, test := range tests {
+		exitCode, _, stderr := runAdmin(test.args, test.target)
+		if exitCode != exitFailure || !strings.Contains(stderr, filelock.ErrorLocked.Error()) || !strings.Contains(stderr, "-server") {
+			t.Fatalf("expected %v to be refused while the server runs, got %v: %v", test.args, exitCode, stderr)
+		}
+	}
+
+	auditLog.Close()
+	tripStore.Close()
+	if exitCode, _, stderr := runAdmin([]string{"trip", "list"}, target); exitCode != exitValid {
+		t.Fatalf("expected the command to run once the server stopped, got %v: %v", exitCode, stderr)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+const auditUsage = `Usage:
+  pack-and-go audit verify [-audit_file <file>]`
+
+func runAuditCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(stderr, auditUsage)
+		return exitUsage
+	}
+
+	return runAuditVerify(args[1:], stdout, stderr)
+}
+
+// runAuditVerify checks the hash chain of an audit log, reporting the first
+// record that was modified, removed or reordered
+func runAuditVerify(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	auditFilePath := flags.String("audit_file", "audit.log", "Path to the audit log to verify")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	file, err := os.Open(*auditFilePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not read audit log: %v\n", err)
+		return exitUsage
+	}
+	defer file.Close()
+
+	count, err := audit.Verify(file)
+	if err != nil {
+		fmt.Fprintf(stdout, "INVALID %v valid records before: %v\n", count, err)
+		return exitInvalid
+	}
+
+	fmt.Fprintf(stdout, "VALID %v records\n", count)
+	return exitValid
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/audit"
+)
+
+func TestRunAuditVerify_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.NewLog(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, price := range []string{"10", "20", "30"} {
+		auditLog.Record(context.Background(), audit.EntityTrip, "1", audit.ActionUpdate, nil, map[string]string{"price": price})
+	}
+	auditLog.Close()
+
+	var stdout, stderr bytes.Buffer
+	exitCode := runAuditCommand([]string{"verify", "-audit_file", filePath}, &stdout, &stderr)
+	if exitCode != exitValid || strings.TrimSpace(stdout.String()) != "VALID 3 records" {
+		t.Fatalf("expected 3 valid records, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+
+	content, _ := ioutil.ReadFile(filePath)
+	ioutil.WriteFile(filePath, bytes.Replace(content, []byte(`"20"`), []byte(`"25"`), 1), 0600)
+
+	stdout.Reset()
+	exitCode = runAuditCommand([]string{"verify", "-audit_file", filePath}, &stdout, &stderr)
+	if exitCode != exitInvalid || !strings.HasPrefix(stdout.String(), "INVALID 1 valid records before") || !strings.Contains(stdout.String(), "record 2 has been modified") {
+		t.Fatalf("expected record 2 to be reported as modified, got %v: %v", exitCode, stdout.String())
+	}
+}
+
+func TestRunAuditVerify_2(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if exitCode := runAuditCommand([]string{}, &stdout, &stderr); exitCode != exitUsage {
+		t.Fatalf("expected exit code %v, got %v", exitUsage, exitCode)
+	}
+	if exitCode := runAuditCommand([]string{"verify", "-audit_file", filepath.Join(t.TempDir(), "missing.log")}, &stdout, &stderr); exitCode != exitUsage {
+		t.Fatalf("expected exit code %v, got %v", exitUsage, exitCode)
+	}
+}
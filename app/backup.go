@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/backup"
+)
+
+// Server that the commands talking to a running server use by default
+const defaultServerURL = "http://localhost:8080"
+
+// Environment variable with the admin token of the server, which the
+// commands talking to it send unless -token is given
+const adminTokenEnv = "PACKANDGO_ADMIN_TOKEN"
+
+// Time a backup or restore request to the server can take
+const backupTimeout = 5 * time.Minute
+
+const backupUsage = `Usage:
+  pack-and-go backup -out <file> [-server <url>] [-token <admin token>]`
+
+const restoreUsage = `Usage:
+  pack-and-go restore -in <file> [-server <url>] [-token <admin token>]`
+
+// runBackupCommand downloads an archive of the cities, trips, bookings,
+// customers, promo codes and disruptions of a running server. The archive is verified before it replaces the out file,
+// so a failed backup never overwrites a good one.
+func runBackupCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outPath := flags.String("out", "", "Path of the archive to write")
+	serverURL := flags.String("server", defaultServerURL, "URL of the running server to back up")
+	token := flags.String("token", os.Getenv(adminTokenEnv), "Admin token of the server, $"+adminTokenEnv+" by default")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *outPath == "" || flags.NArg() > 0 {
+		fmt.Fprintln(stderr, backupUsage)
+		return exitUsage
+	}
+
+	req, err := newAdminRequest(http.MethodGet, strings.TrimRight(*serverURL, "/")+"/api/v1/backup", nil, *token)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid server: %v\n", err)
+		return exitUsage
+	}
+
+	client := &http.Client{Timeout: backupTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not reach the server: %v\n", err)
+		return exitFailure
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintf(stderr, "backup failed: %v\n", responseError(res))
+		return exitFailure
+	}
+
+	tmpPath := *outPath + ".tmp"
+	err = writeFile(tmpPath, res.Body)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not write the archive: %v\n", err)
+		return exitFailure
+	}
+
+	archive, err := readArchive(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		fmt.Fprintf(stderr, "the server sent an invalid archive: %v\n", err)
+		return exitFailure
+	}
+	err = os.Rename(tmpPath, *outPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not write the archive: %v\n", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(stdout, "backed up %v as of %v to %v\n", describeArchive(archive), archive.CreatedAt.Format(time.RFC3339), *outPath)
+	return exitValid
+}
+
+// runRestoreCommand replaces the content of the stores of a running server
+// with that of an archive, which is verified before it is sent
+func runRestoreCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	inPath := flags.String("in", "", "Path of the archive to restore")
+	serverURL := flags.String("server", defaultServerURL, "URL of the running server to restore")
+	token := flags.String("token", os.Getenv(adminTokenEnv), "Admin token of the server, $"+adminTokenEnv+" by default")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *inPath == "" || flags.NArg() > 0 {
+		fmt.Fprintln(stderr, restoreUsage)
+		return exitUsage
+	}
+
+	archive, err := readArchive(*inPath)
+	if errors.Is(err, backup.ErrorNewerVersion) || errors.Is(err, backup.ErrorOlderVersion) {
+		fmt.Fprintf(stderr, "refusing to restore: %v\n", err)
+		return exitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid archive: %v\n", err)
+		return exitFailure
+	}
+
+	content, err := ioutil.ReadFile(*inPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not read the archive: %v\n", err)
+		return exitFailure
+	}
+
+	req, err := newAdminRequest(http.MethodPost, strings.TrimRight(*serverURL, "/")+"/api/v1/restore", bytes.NewReader(content), *token)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid server: %v\n", err)
+		return exitUsage
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := &http.Client{Timeout: backupTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not reach the server: %v\n", err)
+		return exitFailure
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		fmt.Fprintf(stderr, "restore failed: %v\n", responseError(res))
+		return exitFailure
+	}
+
+	fmt.Fprintf(stdout, "restored %v as of %v\n", describeArchive(archive), archive.CreatedAt.Format(time.RFC3339))
+	return exitValid
+}
+
+// newAdminRequest returns a request to the server with the admin token, if any
+func newAdminRequest(method string, url string, body io.Reader, token string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// describeArchive returns how many of each entity an archive holds
+func describeArchive(archive backup.Archive) string {
+	return fmt.Sprintf("%v cities, %v trips, %v bookings, %v customers, %v promo codes and %v disruptions",
+		len(archive.Cities), len(archive.Trips), len(archive.Bookings), len(archive.Customers), len(archive.PromoCodes), len(archive.Disruptions))
+}
+
+func readArchive(filePath string) (backup.Archive, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return backup.Archive{}, err
+	}
+	defer file.Close()
+
+	return backup.Read(file)
+}
+
+// writeFile writes the content of reader to filePath, synced to disk
+func writeFile(filePath string, reader io.Reader) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, reader)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// responseError returns the status and message of an error response of the server
+func responseError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("%v: %v", res.Status, strings.TrimSpace(string(body)))
+}
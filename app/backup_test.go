@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/backup"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Admin token of the servers of the tests
+const testAdminToken = "test-admin-token"
+
+func newTestBackupServer(t *testing.T) *httptest.Server {
+	cities, err := ioutil.ReadFile("cities_test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	citiesPath := filepath.Join(t.TempDir(), "cities.txt")
+	if err := os.WriteFile(citiesPath, cities, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := httptest.NewServer(setupApplication(applicationConfig{
+		cityStore:  "file://" + citiesPath,
+		adminToken: testAdminToken,
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func countTrips(t *testing.T, serverURL string) int {
+	res, err := http.Get(serverURL + "/api/v1/trip")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+
+	var trips []model.Trip
+	json.NewDecoder(res.Body).Decode(&trips)
+	return len(trips)
+}
+
+func TestRunBackupCommand_1(t *testing.T) {
+	server := newTestBackupServer(t)
+	archivePath := filepath.Join(t.TempDir(), "packandgo.backup")
+	var stdout, stderr bytes.Buffer
+
+	exitCode := runBackupCommand([]string{"-out", archivePath, "-server", server.URL, "-token", testAdminToken}, &stdout, &stderr)
+	if exitCode != exitValid || !strings.Contains(stdout.String(), "3 trips") {
+		t.Fatalf("expected a backup of 3 trips, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+
+	res, err := http.Post(server.URL+"/api/v1/trip", "application/json", strings.NewReader(`{"originId":1,"destinationId":2,"dates":"Mon Tue","price":40.55}`))
+	if err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the trip to be added, got %v %v", res, err)
+	}
+	res.Body.Close()
+	if trips := countTrips(t, server.URL); trips != 4 {
+		t.Fatalf("expected 4 trips, got %v", trips)
+	}
+
+	stdout.Reset()
+	exitCode = runRestoreCommand([]string{"-in", archivePath, "-server", server.URL, "-token", testAdminToken}, &stdout, &stderr)
+	if exitCode != exitValid || !strings.HasPrefix(stdout.String(), "restored") {
+		t.Fatalf("expected the archive to be restored, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+	if trips := countTrips(t, server.URL); trips != 3 {
+		t.Fatalf("expected the 3 trips of the archive, got %v", trips)
+	}
+}
+
+func TestRunRestoreCommand_1(t *testing.T) {
+	server := newTestBackupServer(t)
+	directory := t.TempDir()
+	archivePath := filepath.Join(directory, "packandgo.backup")
+	runBackupCommand([]string{"-out", archivePath, "-server", server.URL, "-token", testAdminToken}, ioutil.Discard, ioutil.Discard)
+
+	content, _ := ioutil.ReadFile(archivePath)
+	tamperedPath := filepath.Join(directory, "tampered.backup")
+	createdAt := []byte(fmt.Sprintf(`"version":%v,"createdAt"`, backup.Version))
+	os.WriteFile(tamperedPath, bytes.Replace(content, createdAt, append(createdAt, ' '), 1), 0600)
+	header := []byte(fmt.Sprintf("PACKANDGO-BACKUP %v ", backup.Version))
+	newerPath := filepath.Join(directory, "newer.backup")
+	os.WriteFile(newerPath, bytes.Replace(content, header, []byte(fmt.Sprintf("PACKANDGO-BACKUP %v ", backup.Version+1)), 1), 0600)
+	olderPath := filepath.Join(directory, "older.backup")
+	os.WriteFile(olderPath, bytes.Replace(content, header, []byte(fmt.Sprintf("PACKANDGO-BACKUP %v ", backup.Version-1)), 1), 0600)
+
+	tests := []struct {
+		args     []string
+		exitCode int
+		message  string
+	}{
+		{args: []string{"-server", server.URL}, exitCode: exitUsage},
+		{args: []string{"-in", tamperedPath, "-server", server.URL}, exitCode: exitFailure, message: "checksum mismatch"},
+		{args: []string{"-in", newerPath, "-server", server.URL}, exitCode: exitFailure, message: "refusing to restore"},
+		{args: []string{"-in", olderPath, "-server", server.URL}, exitCode: exitFailure, message: "refusing to restore"},
+		{args: []string{"-in", filepath.Join(directory, "missing.backup"), "-server", server.URL}, exitCode: exitFailure},
+		{args: []string{"-in", archivePath, "-server", server.URL}, exitCode: exitFailure, message: "401 Unauthorized"},
+		{args: []string{"-in", archivePath, "-server", server.URL, "-token", "wrong"}, exitCode: exitFailure, message: "invalid admin token"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		exitCode := runRestoreCommand(test.args, &stdout, &stderr)
+		if exitCode != test.exitCode || !strings.Contains(stderr.String(), test.message) {
+			t.Fatalf("expected exit code %v and %q for %v, got %v: %v", test.exitCode, test.message, test.args, exitCode, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/client"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestClient_1(t *testing.T) {
+	server := newTestBackupServer(t)
+	ctx := context.Background()
+
+	apiClient, err := client.NewClient(server.URL, client.Config{PageSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	added, err := apiClient.AddTrip(ctx, model.Trip{OriginId: 1, DestinationId: 3, Dates: "Mon Tue", Price: 25})
+	if err != nil || added.Id == 0 || added.Destination != "Madrid" || added.ETag == "" {
+		t.Fatalf("expected the trip to be added, got %v %v", added, err)
+	}
+
+	trips, err := apiClient.ListTrips(ctx, client.TripFilter{})
+	if err != nil || len(trips) != 4 {
+		t.Fatalf("expected 4 trips over 2 pages, got %v %v", trips, err)
+	}
+
+	trips, err = apiClient.ListTrips(ctx, client.TripFilter{OriginId: 1})
+	if err != nil || len(trips) != 2 {
+		t.Fatalf("expected 2 trips from Barcelona, got %v %v", trips, err)
+	}
+
+	trip, err := apiClient.GetTrip(ctx, added.Id)
+	if err != nil || trip.Price != 25 || trip.ETag == "" {
+		t.Fatalf("expected trip %v with an ETag, got %v %v", added.Id, trip, err)
+	}
+
+	updated, err := apiClient.UpdateTrip(ctx, trip.Id, trip.ETag, model.Trip{OriginId: 1, DestinationId: 3, Dates: "Mon Tue", Price: 30})
+	if err != nil || updated.Price != 30 || updated.ETag == trip.ETag {
+		t.Fatalf("expected the trip to be updated, got %v %v", updated, err)
+	}
+
+	_, err = apiClient.UpdateTrip(ctx, trip.Id, trip.ETag, model.Trip{OriginId: 1, DestinationId: 3, Dates: "Mon", Price: 35})
+	if !errors.Is(err, client.ErrorVersionMismatch) {
+		t.Fatalf("expected error: %v, got error: %v", client.ErrorVersionMismatch, err)
+	}
+
+	err = apiClient.DeleteTrip(ctx, trip.Id, updated.ETag)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = apiClient.GetTrip(ctx, trip.Id)
+	if !errors.Is(err, client.ErrorNotFound) {
+		t.Fatalf("expected error: %v, got error: %v", client.ErrorNotFound, err)
+	}
+}
+
+func TestClient_2(t *testing.T) {
+	server := newTestBackupServer(t)
+
+	apiClient, err := client.NewClient(server.URL, client.Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = apiClient.AddTrip(context.Background(), model.Trip{OriginId: 1, DestinationId: 3, Dates: "Someday", Price: 25})
+	var apiErr *client.Error
+	if !errors.Is(err, client.ErrorBadRequest) || !errors.As(err, &apiErr) || apiErr.RequestId == "" {
+		t.Fatalf("expected error: %v with a request id, got error: %v", client.ErrorBadRequest, err)
+	}
+}
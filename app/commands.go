@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// command is a subcommand of the pack-and-go binary, such as pack-and-go ticket verify.
+// run returns the exit code of the process.
+type command struct {
+	description string
+	run         func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+// exitFailure is the exit code of commands that could not be completed, such as a failed migration
+const exitFailure = 1
+
+var commands = map[string]command{
+	"admin": {
+		description: "List, add and change cities and trips, and validate them",
+		run:         runAdminCommand,
+	},
+	"audit": {
+		description: "Verify the hash chain of the audit log",
+		run:         runAuditCommand,
+	},
+	"backup": {
+		description: "Write an archive of the cities, trips and bookings of a running server",
+		run:         runBackupCommand,
+	},
+	"migrate": {
+		description: "Apply, revert or list the schema migrations of a SQL store",
+		run:         runMigrateCommand,
+	},
+	"restore": {
+		description: "Replace the cities, trips and bookings of a running server with an archive",
+		run:         runRestoreCommand,
+	},
+	"ticket": {
+		description: "Verify tickets offline and print the ticket public key",
+		run:         runTicketCommand,
+	},
+}
+
+// runCommand runs the subcommand named in args[0], returning false if there
+// is none, in which case the server is started
+func runCommand(args []string, stdout io.Writer, stderr io.Writer) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	if args[0] == "help" {
+		printCommands(stdout)
+		return 0, true
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		return 0, false
+	}
+
+	return command.run(args[1:], stdout, stderr), true
+}
+
+func printCommands(w io.Writer) {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: pack-and-go [flags] to start the server, or pack-and-go <command> [arguments]")
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10v %v\n", name, commands[name].description)
+	}
+}
@@ -1,20 +1,32 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	api_v1 "github.com/gbandres98/pack-and-go/api/v1"
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/db"
 	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/webhook"
 )
 
 func TestGetAllTrips(t *testing.T) {
 	app := setupApplication(applicationConfig{
-		fileDBPath: "./cities_test.txt",
+		cityStore: "file://cities_test.txt",
 	})
 
 	req := httptest.NewRequest("GET", "/api/v1/trip", nil)
@@ -29,7 +41,7 @@ func TestGetAllTrips(t *testing.T) {
 
 func TestAddAndGetTrip(t *testing.T) {
 	app := setupApplication(applicationConfig{
-		fileDBPath: "./cities_test.txt",
+		cityStore: "file://cities_test.txt",
 	})
 
 	req := httptest.NewRequest("POST", "/api/v1/trip", strings.NewReader(`{"originId":1,"destinationId":2,"dates":"Mon Tue","price":40.55}`))
@@ -66,7 +78,7 @@ func TestAddAndGetTrip(t *testing.T) {
 
 func TestAddAndGetAllTrips(t *testing.T) {
 	app := setupApplication(applicationConfig{
-		fileDBPath: "./cities_test.txt",
+		cityStore: "file://cities_test.txt",
 	})
 
 	req := httptest.NewRequest("POST", "/api/v1/trip", strings.NewReader(`{"originId":1,"destinationId":2,"dates":"Mon Tue","price":40.55}`))
@@ -99,4 +111,916 @@ func TestAddAndGetAllTrips(t *testing.T) {
 	if !strings.Contains(result, expected) {
 		t.Fatalf("expected %v to include %v", result, expected)
 	}
-}
\ No newline at end of file
+}
+
+func TestHealthz(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
+
+func TestReadyz_1(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if !strings.Contains(responseRecorder.Body.String(), `"name":"cities"`) {
+		t.Fatalf("expected %v to include the cities check", responseRecorder.Body.String())
+	}
+}
+
+func TestReadyz_2(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://wrong-file-path.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+}
+
+func TestReadyz_3(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+	app.beginShutdown()
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+}
+
+func TestAddTripIdempotent(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	body := `{"originId":1,"destinationId":2,"dates":"Mon Tue","price":40.55}`
+	createdTrips := []model.TripPretty{}
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/v1/trip", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "integration-test")
+		responseRecorder := httptest.NewRecorder()
+
+		app.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != http.StatusCreated {
+			t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+		}
+
+		var createdTrip model.TripPretty
+		err := json.Unmarshal(responseRecorder.Body.Bytes(), &createdTrip)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		createdTrips = append(createdTrips, createdTrip)
+	}
+
+	if createdTrips[0].Id != createdTrips[1].Id {
+		t.Fatalf("expected retry to return trip %v, got %v", createdTrips[0].Id, createdTrips[1].Id)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/trip", strings.NewReader(`{"originId":2,"destinationId":1,"dates":"Mon Tue","price":40.55}`))
+	req.Header.Set("Idempotency-Key", "integration-test")
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnprocessableEntity, responseRecorder.Code)
+	}
+}
+
+
+func TestUpdateTripConditional(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/trip/1", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	etag := responseRecorder.Header().Get("ETag")
+	if etag == "" || responseRecorder.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected ETag and Last-Modified headers, got %v", responseRecorder.Header())
+	}
+
+	body := `{"originId":2,"destinationId":3,"dates":"Sat Sun","price":20}`
+
+	req = httptest.NewRequest("PUT", "/api/v1/trip/1", strings.NewReader(body))
+	req.Header.Set("If-Match", etag)
+	responseRecorder = httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	expected := `{"id":1,"origin":"Seville","destination":"Madrid","dates":"Sat Sun","price":20}`
+	result := strings.TrimSpace(responseRecorder.Body.String())
+	if result != expected {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/trip/1", strings.NewReader(body))
+	req.Header.Set("If-Match", etag)
+	responseRecorder = httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusPreconditionFailed, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/trip/1", nil)
+	req.Header.Set("If-None-Match", etag)
+	responseRecorder = httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
+
+func TestImportAndExportTrips(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("POST", "/api/v1/trip/import", strings.NewReader(
+		"originId,destinationId,dates,price\n4,5,Fri Sat,18.5\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/trip/export?format=csv", nil)
+	responseRecorder = httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if !strings.Contains(responseRecorder.Body.String(), ",Valencia,Andorra la Vella,Fri Sat,18.5\n") {
+		t.Fatalf("expected %v to include the imported trip", responseRecorder.Body.String())
+	}
+}
+
+
+func TestGetGTFSFeed(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/gtfs.zip", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	body := responseRecorder.Body.Bytes()
+	_, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+
+func TestGetTripCalendar(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/trip/1/calendar.ics", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	if !strings.Contains(responseRecorder.Body.String(), "SUMMARY:Barcelona - Seville\r\n") {
+		t.Fatalf("expected %v to include the trip summary", responseRecorder.Body.String())
+	}
+}
+
+func TestGetTripQuote(t *testing.T) {
+	pricingFilePath := filepath.Join(t.TempDir(), "pricing.json")
+	err := ioutil.WriteFile(pricingFilePath, []byte(`{"rules": [{"name": "senior", "type": "passenger_age", "minAge": 65, "adjustment": {"percent": -50}}]}`), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	app := setupApplication(applicationConfig{
+		cityStore:       "file://cities_test.txt",
+		pricingFilePath: pricingFilePath,
+	})
+	defer app.Close()
+
+	// Trip 1 runs on Mondays
+	departureDay := time.Now().AddDate(0, 0, 2)
+	for departureDay.Weekday() != time.Monday {
+		departureDay = departureDay.AddDate(0, 0, 1)
+	}
+	departure := departureDay.Format("2006-01-02")
+	req := httptest.NewRequest("GET", "/api/v1/trip/1/quote/explain?age=70&departure="+departure, nil)
+	responseRecorder := httptest.NewRecorder()
+
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var quote struct {
+		BasePrice float64
+		Price     float64
+		Steps     []struct {
+			Rule  string
+			Fired bool
+		}
+	}
+	json.Unmarshal(responseRecorder.Body.Bytes(), &quote)
+	if math.Abs(quote.Price-quote.BasePrice/2) > 0.01 {
+		t.Fatalf("expected price to be half of %v, got %v", quote.BasePrice, quote.Price)
+	}
+	if len(quote.Steps) != 1 || !quote.Steps[0].Fired {
+		t.Fatalf("expected senior rule to fire, got %v", quote.Steps)
+	}
+}
+
+func TestPromoCodes(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore:  "file://cities_test.txt",
+		adminToken: testAdminToken,
+	})
+	defer app.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/promo", strings.NewReader(`{"code": "flash5", "amount": 5, "maxUses": 3}`))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	responseRecorder := httptest.NewRecorder()
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+
+	// Trip 2 runs on Saturdays
+	departureDay := time.Now().AddDate(0, 0, 2)
+	for departureDay.Weekday() != time.Saturday {
+		departureDay = departureDay.AddDate(0, 0, 1)
+	}
+	departure := departureDay.Format("2006-01-02")
+
+	req = httptest.NewRequest("GET", "/api/v1/trip/2/quote?promo=FLASH5&departure="+departure, nil)
+	responseRecorder = httptest.NewRecorder()
+	app.ServeHTTP(responseRecorder, req)
+
+	var quote struct {
+		BasePrice float64
+		Price     float64
+		Discount  float64
+	}
+	json.Unmarshal(responseRecorder.Body.Bytes(), &quote)
+	if responseRecorder.Code != http.StatusOK || quote.Discount != 5 || math.Abs(quote.Price-(quote.BasePrice-5)) > 0.001 {
+		t.Fatalf("expected 5 off, got %v %v", responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	request := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	// Codes are redeemed by booking with them
+	tokens := make([]string, 10)
+	bodies := make([]string, 10)
+	for i := range tokens {
+		credentials := fmt.Sprintf(`{"email": "customer-%v@example.com", "name": "Test", "password": "correct horse"}`, i)
+		request("POST", "/api/v1/customer", "", credentials)
+
+		var session struct{ Token string }
+		json.Unmarshal(request("POST", "/api/v1/session", "", credentials).Body.Bytes(), &session)
+		tokens[i] = session.Token
+
+		var passenger model.Passenger
+		json.Unmarshal(request("POST", "/api/v1/customer/me/passenger", session.Token, `{"firstName": "Test", "lastName": "Smith"}`).Body.Bytes(), &passenger)
+		bodies[i] = fmt.Sprintf(`{"tripId": 2, "departure": "%v", "passengerId": %v, "promoCode": "FLASH5"}`, departure, passenger.Id)
+	}
+
+	var wait sync.WaitGroup
+	codes := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			codes <- request("POST", "/api/v1/customer/me/booking", tokens[i], bodies[i]).Code
+		}(i)
+	}
+	wait.Wait()
+	close(codes)
+
+	redeemed := 0
+	for code := range codes {
+		if code == http.StatusCreated {
+			redeemed++
+		}
+	}
+	if redeemed != 3 {
+		t.Fatalf("expected %v redemptions, got %v", 3, redeemed)
+	}
+}
+
+func TestCustomerPassengers(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore: "file://cities_test.txt",
+	})
+	defer app.Close()
+
+	login := func(email string) string {
+		body := fmt.Sprintf(`{"email": "%v", "name": "Test", "password": "correct horse"}`, email)
+		req := httptest.NewRequest("POST", "/api/v1/customer", strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		if responseRecorder.Code != http.StatusCreated {
+			t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+		}
+
+		req = httptest.NewRequest("POST", "/api/v1/session", strings.NewReader(body))
+		responseRecorder = httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		if responseRecorder.Code != http.StatusCreated {
+			t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+		}
+
+		var session struct{ Token string }
+		json.Unmarshal(responseRecorder.Body.Bytes(), &session)
+		return session.Token
+	}
+
+	aliceToken := login("alice@example.com")
+	bobToken := login("bob@example.com")
+
+	req := httptest.NewRequest("POST", "/api/v1/customer/me/passenger", strings.NewReader(`{"firstName": "Alice", "lastName": "Smith", "documentType": "passport", "documentNumber": "X1234567"}`))
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	responseRecorder := httptest.NewRecorder()
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, responseRecorder.Code)
+	}
+
+	var passenger model.Passenger
+	json.Unmarshal(responseRecorder.Body.Bytes(), &passenger)
+	passengerPath := fmt.Sprintf("/api/v1/customer/me/passenger/%v", passenger.Id)
+
+	for _, test := range []struct {
+		token    string
+		expected int
+	}{
+		{token: aliceToken, expected: http.StatusOK},
+		{token: bobToken, expected: http.StatusNotFound},
+		{token: "", expected: http.StatusUnauthorized},
+	} {
+		req = httptest.NewRequest("GET", passengerPath, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		responseRecorder = httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Code != test.expected {
+			t.Fatalf("expected response code to be %v, got %v", test.expected, responseRecorder.Code)
+		}
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/session", nil)
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	responseRecorder = httptest.NewRecorder()
+	app.ServeHTTP(responseRecorder, req)
+
+	req = httptest.NewRequest("GET", "/api/v1/customer/me", nil)
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	responseRecorder = httptest.NewRecorder()
+	app.ServeHTTP(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected response code after logout to be %v, got %v", http.StatusUnauthorized, responseRecorder.Code)
+	}
+}
+
+func TestBookAndVerifyTicket(t *testing.T) {
+	app := setupApplication(applicationConfig{
+		cityStore:     "file://cities_test.txt",
+		ticketKeyPath: filepath.Join(t.TempDir(), "ticket.key"),
+	})
+	defer app.Close()
+
+	request := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	credentials := `{"email": "alice@example.com", "name": "Alice", "password": "correct horse"}`
+	request("POST", "/api/v1/customer", "", credentials)
+
+	var session struct{ Token string }
+	json.Unmarshal(request("POST", "/api/v1/session", "", credentials).Body.Bytes(), &session)
+
+	var passenger model.Passenger
+	json.Unmarshal(request("POST", "/api/v1/customer/me/passenger", session.Token, `{"firstName": "Alice", "lastName": "Smith"}`).Body.Bytes(), &passenger)
+
+	// Trip 2 runs on Saturdays
+	departureDay := time.Now().AddDate(0, 0, 2)
+	for departureDay.Weekday() != time.Saturday {
+		departureDay = departureDay.AddDate(0, 0, 1)
+	}
+
+	body := fmt.Sprintf(`{"tripId": 2, "departure": "%v", "passengerId": %v}`, departureDay.Format("2006-01-02"), passenger.Id)
+	responseRecorder := request("POST", "/api/v1/customer/me/booking", session.Token, body)
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	var booking model.Booking
+	json.Unmarshal(responseRecorder.Body.Bytes(), &booking)
+
+	responseRecorder = request("POST", "/api/v1/ticket/verify", "", fmt.Sprintf(`{"ticket": "%v"}`, booking.Ticket))
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	var key struct{ PublicKey string }
+	json.Unmarshal(request("GET", "/api/v1/ticket/key", "", "").Body.Bytes(), &key)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := runTicketVerify([]string{"-public_key", key.PublicKey, booking.Ticket}, strings.NewReader(""), &stdout, &stderr)
+	if exitCode != exitValid {
+		t.Fatalf("expected offline verification to succeed, got %v %v", stdout.String(), stderr.String())
+	}
+}
+
+func TestCancelBookingWithRefund(t *testing.T) {
+	app := setupApplication(applicationConfig{cityStore: "file://cities_test.txt"})
+	defer app.Close()
+
+	request := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	credentials := `{"email": "alice@example.com", "name": "Alice", "password": "correct horse"}`
+	request("POST", "/api/v1/customer", "", credentials)
+
+	var session struct{ Token string }
+	json.Unmarshal(request("POST", "/api/v1/session", "", credentials).Body.Bytes(), &session)
+
+	var passenger model.Passenger
+	json.Unmarshal(request("POST", "/api/v1/customer/me/passenger", session.Token, `{"firstName": "Alice", "lastName": "Smith"}`).Body.Bytes(), &passenger)
+
+	// Trip 2 runs on Saturdays, more than 48 hours from now
+	departureDay := time.Now().AddDate(0, 0, 3)
+	for departureDay.Weekday() != time.Saturday {
+		departureDay = departureDay.AddDate(0, 0, 1)
+	}
+
+	body := fmt.Sprintf(`{"tripId": 2, "departure": "%v", "passengerId": %v}`, departureDay.Format("2006-01-02"), passenger.Id)
+	var booking model.Booking
+	json.Unmarshal(request("POST", "/api/v1/customer/me/booking", session.Token, body).Body.Bytes(), &booking)
+
+	responseRecorder := request("POST", fmt.Sprintf("/api/v1/customer/me/booking/%v/cancel", booking.Id), session.Token, "")
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	var cancellation struct {
+		Booking model.Booking
+		Refund  model.LedgerEntry
+	}
+	json.Unmarshal(responseRecorder.Body.Bytes(), &cancellation)
+	if cancellation.Booking.Status != model.BookingCancelled || cancellation.Refund.Amount != booking.Price {
+		t.Fatalf("expected full refund of %v, got %v", booking.Price, cancellation)
+	}
+
+	responseRecorder = request("POST", "/api/v1/ticket/verify", "", fmt.Sprintf(`{"ticket": "%v"}`, booking.Ticket))
+	if responseRecorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnprocessableEntity, responseRecorder.Code)
+	}
+
+	var ledger []model.LedgerEntry
+	json.Unmarshal(request("GET", "/api/v1/customer/me/ledger", session.Token, "").Body.Bytes(), &ledger)
+	if len(ledger) != 1 || ledger[0].BookingId != booking.Id {
+		t.Fatalf("expected refund of booking %v in the ledger, got %v", booking.Id, ledger)
+	}
+
+	var rebooking model.Booking
+	json.Unmarshal(request("POST", "/api/v1/customer/me/booking", session.Token, body).Body.Bytes(), &rebooking)
+	if rebooking.Seat != booking.Seat {
+		t.Fatalf("expected released seat %v to be booked again, got %v", booking.Seat, rebooking.Seat)
+	}
+}
+
+func TestDisruptedDeparture(t *testing.T) {
+	app := setupApplication(applicationConfig{cityStore: "file://cities_test.txt", adminToken: testAdminToken})
+	defer app.Close()
+
+	request := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	credentials := `{"email": "alice@example.com", "name": "Alice", "password": "correct horse"}`
+	request("POST", "/api/v1/customer", "", credentials)
+
+	var session struct{ Token string }
+	json.Unmarshal(request("POST", "/api/v1/session", "", credentials).Body.Bytes(), &session)
+
+	var passenger model.Passenger
+	json.Unmarshal(request("POST", "/api/v1/customer/me/passenger", session.Token, `{"firstName": "Alice", "lastName": "Smith"}`).Body.Bytes(), &passenger)
+
+	// Trip 2 runs on Saturdays and Sundays
+	saturday := time.Now().AddDate(0, 0, 2)
+	for saturday.Weekday() != time.Saturday {
+		saturday = saturday.AddDate(0, 0, 1)
+	}
+	saturdayDate := saturday.Format("2006-01-02")
+	sundayDate := saturday.AddDate(0, 0, 1).Format("2006-01-02")
+
+	body := fmt.Sprintf(`{"tripId": 2, "departure": "%v", "passengerId": %v}`, saturdayDate, passenger.Id)
+	var booking model.Booking
+	json.Unmarshal(request("POST", "/api/v1/customer/me/booking", session.Token, body).Body.Bytes(), &booking)
+
+	responseRecorder := request("PUT", "/api/v1/trip/2/departure/"+saturdayDate+"/disruption", testAdminToken, `{"status": "cancelled", "reason": "Snow"}`)
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+	}
+	request("PUT", "/api/v1/trip/2/departure/"+sundayDate+"/disruption", testAdminToken, `{"status": "delayed", "delayMinutes": 30, "reason": "Roadworks"}`)
+
+	var trip model.TripPretty
+	json.Unmarshal(request("GET", "/api/v1/trip/2", "", "").Body.Bytes(), &trip)
+	if len(trip.Disruptions) != 2 || trip.Disruptions[0].Status != model.DisruptionCancelled || trip.Disruptions[1].Status != model.DisruptionDelayed {
+		t.Fatalf("expected the trip to show the cancellation and the delay, got %v", trip.Disruptions)
+	}
+
+	var departures []model.Departure
+	json.Unmarshal(request("GET", "/api/v1/trip/2/departure?from="+saturdayDate+"&days=7", "", "").Body.Bytes(), &departures)
+	if len(departures) != 2 || departures[0].Status != model.DisruptionCancelled || departures[1].Status != model.DisruptionDelayed {
+		t.Fatalf("expected a cancelled and a delayed departure, got %v", departures)
+	}
+
+	var affected []service.AffectedBooking
+	json.Unmarshal(request("GET", "/api/v1/trip/2/departure/"+saturdayDate+"/booking", testAdminToken, "").Body.Bytes(), &affected)
+	if len(affected) != 1 || affected[0].Id != booking.Id || affected[0].CustomerEmail != "alice@example.com" {
+		t.Fatalf("expected booking %v of alice@example.com to be affected, got %v", booking.Id, affected)
+	}
+
+	responseRecorder = request("POST", "/api/v1/customer/me/booking", session.Token, body)
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected booking a cancelled departure to fail with %v, got %v", http.StatusBadRequest, responseRecorder.Code)
+	}
+}
+
+func TestTripEventStream(t *testing.T) {
+	app := setupApplication(applicationConfig{cityStore: "file://cities_test.txt", eventStreamDuration: 10 * time.Millisecond})
+	defer app.Close()
+
+	request := func(method string, path string, header string, value string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	var trip model.TripPretty
+	json.Unmarshal(request("POST", "/api/v1/trip", "Authorization", "Bearer "+testAdminToken, `{"originId":1,"destinationId":2,"dates":"Mon","price":20}`).Body.Bytes(), &trip)
+
+	path := fmt.Sprintf("/api/v1/trip/%v", trip.Id)
+	responseRecorder := request("PUT", path, "If-Match", "*", `{"originId":1,"destinationId":2,"dates":"Mon Tue","price":25}`)
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+	responseRecorder = request("DELETE", path, "If-Match", responseRecorder.Header().Get("ETag"), "")
+	if responseRecorder.Code != http.StatusNoContent {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusNoContent, responseRecorder.Code)
+	}
+
+	responseRecorder = request("GET", "/api/v1/events", "Last-Event-ID", "1", "")
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	body := responseRecorder.Body.String()
+	updated := strings.Index(body, "id: 2\nevent: trip.updated\n")
+	deleted := strings.Index(body, "id: 3\nevent: trip.deleted\n")
+	if strings.Contains(body, "trip.created") || updated < 0 || deleted < updated {
+		t.Fatalf("expected the update and delete after event 1, got %q", body)
+	}
+
+	responseRecorder = request("GET", path, "", "", "")
+	if responseRecorder.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted trip to respond %v, got %v", http.StatusNotFound, responseRecorder.Code)
+	}
+}
+
+func TestTripWebhook(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	received := make(chan delivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		received <- delivery{req.Header, body}
+	}))
+	defer receiver.Close()
+
+	// The receiver listens on the loopback address
+	app := setupApplication(applicationConfig{
+		cityStore:  "file://cities_test.txt",
+		webhook:    webhook.Config{AllowPrivateAddresses: true},
+		adminToken: testAdminToken,
+	})
+	defer app.Close()
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	responseRecorder := request("POST", "/api/v1/webhook", fmt.Sprintf(`{"url": %q, "eventTypes": ["trip.created"]}`, receiver.URL))
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+	}
+	var subscription model.Webhook
+	json.Unmarshal(responseRecorder.Body.Bytes(), &subscription)
+
+	request("POST", "/api/v1/trip", `{"originId":1,"destinationId":2,"dates":"Mon","price":20}`)
+
+	select {
+	case delivery := <-received:
+		timestamp, _ := strconv.ParseInt(delivery.header.Get(webhook.HeaderTimestamp), 10, 64)
+		if !webhook.Verify(subscription.Secret, timestamp, delivery.body, delivery.header.Get(webhook.HeaderSignature)) {
+			t.Fatalf("expected delivery to be signed with the webhook secret, got %v", delivery.header.Get(webhook.HeaderSignature))
+		}
+		if delivery.header.Get(webhook.HeaderEvent) != "trip.created" {
+			t.Fatalf("expected a trip.created event, got %v", delivery.header.Get(webhook.HeaderEvent))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the new trip to be delivered")
+	}
+
+	path := fmt.Sprintf("/api/v1/webhook/%v/delivery", subscription.Id)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var deliveries []model.WebhookDelivery
+		json.Unmarshal(request("GET", path, "").Body.Bytes(), &deliveries)
+		if len(deliveries) == 1 && deliveries[0].Status == model.DeliverySucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a successful delivery in the log, got %v", deliveries)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTripAuditLog(t *testing.T) {
+	auditFilePath := filepath.Join(t.TempDir(), "audit.log")
+	app := setupApplication(applicationConfig{cityStore: "file://cities_test.txt", auditFilePath: auditFilePath, adminToken: testAdminToken})
+
+	request := func(method string, path string, header string, value string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(audit.HeaderRequestId, "req-"+method)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	var trip model.TripPretty
+	json.Unmarshal(request("POST", "/api/v1/trip", "Authorization", "Bearer "+testAdminToken, `{"originId":1,"destinationId":2,"dates":"Mon","price":20}`).Body.Bytes(), &trip)
+
+	responseRecorder := request("PUT", fmt.Sprintf("/api/v1/trip/%v", trip.Id), "If-Match", "*", `{"originId":1,"destinationId":2,"dates":"Mon","price":25}`)
+	if responseRecorder.Code != http.StatusOK || responseRecorder.Header().Get(audit.HeaderRequestId) != "req-PUT" {
+		t.Fatalf("expected response code to be %v with the request id, got %v %v", http.StatusOK, responseRecorder.Code, responseRecorder.Header())
+	}
+
+	var records []audit.Record
+	json.Unmarshal(request("GET", fmt.Sprintf("/api/v1/audit?entity=trip&id=%v", trip.Id), "Authorization", "Bearer "+testAdminToken, "").Body.Bytes(), &records)
+	if len(records) != 2 || records[0].Action != audit.ActionCreate || records[1].Action != audit.ActionUpdate {
+		t.Fatalf("expected the creation and update of trip %v, got %v", trip.Id, records)
+	}
+
+	if records[0].Actor != api_v1.AdminActor {
+		t.Fatalf("expected the creation with the admin token to be made by %v, got %v", api_v1.AdminActor, records[0].Actor)
+	}
+
+	update := records[1]
+	if update.RequestId != "req-PUT" || update.Actor != audit.Anonymous || update.PrevHash != records[0].Hash {
+		t.Fatalf("expected an anonymous update by req-PUT chained to the creation, got %v", update)
+	}
+	changed := map[string]bool{}
+	for _, change := range update.Diff {
+		changed[change.Field] = true
+	}
+	if !changed["price"] || changed["dates"] {
+		t.Fatalf("expected only the price and version to change, got %v", update.Diff)
+	}
+
+	app.Close()
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := runAuditCommand([]string{"verify", "-audit_file", auditFilePath}, &stdout, &stderr); exitCode != exitValid {
+		t.Fatalf("expected a valid audit log, got %v: %v", exitCode, stdout.String())
+	}
+}
+
+func TestTripHistory(t *testing.T) {
+	tripStore := "journal://" + filepath.Join(t.TempDir(), "trips.log")
+	app := setupApplication(applicationConfig{cityStore: "file://cities_test.txt", tripStore: tripStore})
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("If-Match", "*")
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	var trip model.TripPretty
+	json.Unmarshal(request("POST", "/api/v1/trip", `{"originId":1,"destinationId":2,"dates":"Mon","price":20}`).Body.Bytes(), &trip)
+	asOf := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(time.Millisecond)
+
+	path := fmt.Sprintf("/api/v1/trip/%v", trip.Id)
+	request("PUT", path, `{"originId":1,"destinationId":2,"dates":"Mon","price":25}`)
+
+	var historical model.TripPretty
+	responseRecorder := request("GET", path+"?asOf="+asOf, "")
+	json.Unmarshal(responseRecorder.Body.Bytes(), &historical)
+	if responseRecorder.Code != http.StatusOK || historical.Price != 20 {
+		t.Fatalf("expected the trip at its first price, got %v %v", responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	var trips []model.TripPretty
+	json.Unmarshal(request("GET", "/api/v1/trip?asOf=2000-01-01", "").Body.Bytes(), &trips)
+	if len(trips) != 0 {
+		t.Fatalf("expected no trips before the log started, got %v", trips)
+	}
+
+	app.Close()
+	app = setupApplication(applicationConfig{cityStore: "file://cities_test.txt", tripStore: tripStore})
+	defer app.Close()
+
+	var current model.TripPretty
+	json.Unmarshal(request("GET", path, "").Body.Bytes(), &current)
+	if current.Price != 25 {
+		t.Fatalf("expected the updated trip after a restart, got %v", current)
+	}
+}
+
+func TestKVStore(t *testing.T) {
+	dir := t.TempDir()
+	config := applicationConfig{cityStore: "kv://" + dir + "?cities=cities_test.txt", tripStore: "kv://" + dir}
+	app := setupApplication(config)
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	responseRecorder := request("POST", "/api/v1/trip", `{"originId":2,"destinationId":6,"dates":"Mon","price":20}`)
+	if responseRecorder.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v %v", http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	app.Close()
+	app = setupApplication(config)
+	defer app.Close()
+
+	var trips []model.TripPretty
+	json.Unmarshal(request("GET", "/api/v1/trip?destinationId=6", "").Body.Bytes(), &trips)
+	if len(trips) != 2 || trips[0].Id != 3 || trips[1].Id != 4 {
+		t.Fatalf("expected trips 3 and 4 to city 6 after a restart, got %v", trips)
+	}
+
+	if responseRecorder := request("GET", "/readyz", ""); responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected the kv store to be healthy, got %v %v", responseRecorder.Code, responseRecorder.Body.String())
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	dsn := "sql+sqlmem:" + t.Name()
+	if exitCode := runMigrateCommand([]string{"up", "-dsn", dsn}, ioutil.Discard, ioutil.Discard); exitCode != exitValid {
+		t.Fatalf("expected the migrations to be applied, got exit code %v", exitCode)
+	}
+
+	cityStore, err := db.OpenCityStore(dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"Barcelona", "Seville", "Madrid", "Valencia", "Andorra la Vella", "Malaga"} {
+		cityStore.AddCity(model.City{Name: name})
+	}
+
+	// SQL stores start without trips
+	tripStore, err := db.OpenTripStore(dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, trip := range []model.Trip{{OriginId: 1, DestinationId: 2}, {OriginId: 2, DestinationId: 1}, {OriginId: 3, DestinationId: 6}} {
+		tripStore.AddTrip(trip)
+	}
+
+	app := setupApplication(applicationConfig{cityStore: dsn, tripStore: dsn})
+	defer app.Close()
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		responseRecorder := httptest.NewRecorder()
+		app.ServeHTTP(responseRecorder, req)
+		return responseRecorder
+	}
+
+	responseRecorder := request("/api/v1/trip?limit=2")
+	var trips []model.TripPretty
+	json.Unmarshal(responseRecorder.Body.Bytes(), &trips)
+	if len(trips) != 2 || trips[0].Id != 1 || trips[1].Origin != "Seville" {
+		t.Fatalf("expected the first page of trips, got %v", trips)
+	}
+	if link := responseRecorder.Header().Get("Link"); link != `</api/v1/trip?after=2&limit=2>; rel="next"` {
+		t.Fatalf("expected a link to the next page, got %v", link)
+	}
+
+	json.Unmarshal(request("/api/v1/trip?limit=2&after=2").Body.Bytes(), &trips)
+	if len(trips) != 1 || trips[0].Destination != "Malaga" {
+		t.Fatalf("expected trip 3 on the last page, got %v", trips)
+	}
+}
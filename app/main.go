@@ -1,30 +1,138 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
+
+	"github.com/gbandres98/pack-and-go/webhook"
+
+	// Schedules are published in Europe/Madrid time, even if the host has no time zone database
+	_ "time/tzdata"
 )
 
+const writeTimeout = 10 * time.Second
+
 func main() {
-	ip := *flag.String("ip", "", "IP Address for the application server to listen at")
-	port := *flag.String("port", "8080", "Port for the application server to listen at")
-	fileDBPath := *flag.String("db_file", "cities.txt", "Path to the file to be used as file DB")
+	if exitCode, ok := runCommand(os.Args[1:], os.Stdout, os.Stderr); ok {
+		os.Exit(exitCode)
+	}
+
+	ip := flag.String("ip", "", "IP Address for the application server to listen at")
+	port := flag.String("port", "8080", "Port for the application server to listen at")
+	fileDBPath := flag.String("db_file", "cities.txt", "Path to the file to be used as file DB, unless -city_store is given")
+	cityStore := flag.String("city_store", "", "DSN of the city store, such as file:///var/lib/packandgo")
+	tripStore := flag.String("trip_store", defaultTripStore, "DSN of the trip store, such as memory:// or journal:///var/lib/packandgo")
+	shutdownTimeout := flag.Duration("shutdown_timeout", 15*time.Second, "Maximum time to wait for in-flight requests to finish on shutdown")
+	idempotencyTTL := flag.Duration("idempotency_ttl", defaultIdempotencyTTL, "Time an Idempotency-Key response is kept to be replayed on retries")
+	sessionTTL := flag.Duration("session_ttl", defaultSessionTTL, "Time a customer session lasts since login")
+	pricingFilePath := flag.String("pricing_file", "pricing.json", "Path to the JSON file with the pricing rules")
+	pricingReloadInterval := flag.Duration("pricing_reload_interval", 10*time.Second, "How often the pricing file is checked for changes, 0 to disable reloading")
+	ticketKeyPath := flag.String("ticket_key", "ticket.key", "Path to the Ed25519 key that signs tickets, generated if it does not exist")
+	eventBufferSize := flag.Int("event_buffer", defaultEventBufferSize, "Number of events kept for clients resuming an event stream with Last-Event-ID")
+	webhookWorkers := flag.Int("webhook_workers", webhook.DefaultConfig.Workers, "Number of webhook deliveries attempted at the same time")
+	webhookAttempts := flag.Int("webhook_attempts", webhook.DefaultConfig.MaxAttempts, "Attempts of a webhook delivery before it is dead-lettered")
+	webhookPrivateAddresses := flag.Bool("webhook_private_addresses", false, "Allow webhooks to hosts on loopback, private or link-local addresses, such as partners on the same network")
+	auditFilePath := flag.String("audit_file", "audit.log", "Path to the append-only audit log of changes to trips, cities and bookings")
+	adminToken := flag.String("admin_token", os.Getenv(adminTokenEnv), "Bearer token of the operator endpoints, such as backups, which are disabled without one. Defaults to $"+adminTokenEnv)
+	flag.Parse()
+
+	address := fmt.Sprintf("%v:%v", *ip, *port)
+	if *cityStore == "" {
+		*cityStore = "file://" + *fileDBPath
+	}
 
-	address := fmt.Sprintf("%v:%v", ip, port)
+	app := setupApplication(applicationConfig{
+		tripStore:             *tripStore,
+		cityStore:             *cityStore,
+		idempotencyTTL:        *idempotencyTTL,
+		sessionTTL:            *sessionTTL,
+		pricingFilePath:       *pricingFilePath,
+		pricingReloadInterval: *pricingReloadInterval,
+		ticketKeyPath:         *ticketKeyPath,
+		eventBufferSize:       *eventBufferSize,
+		webhook:               webhook.Config{Workers: *webhookWorkers, MaxAttempts: *webhookAttempts, AllowPrivateAddresses: *webhookPrivateAddresses},
+		auditFilePath:         *auditFilePath,
+		adminToken:            *adminToken,
+		// Streams end just before the write timeout and clients reconnect
+		eventStreamDuration: writeTimeout - time.Second,
+	})
 
-	server := http.Server{
-		Addr: address,
-		Handler: setupApplication(applicationConfig{
-			fileDBPath: fileDBPath,
-		}),
-		ReadTimeout: 5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+	server := &http.Server{
+		Addr:         address,
+		Handler:      app,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: writeTimeout,
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Printf("could not listen at %v: %v", address, err)
+		os.Exit(1)
 	}
 
 	log.Printf("PackAndGo Server listening on %v", address)
 
-	log.Panic(server.ListenAndServe())
-}
\ No newline at end of file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := serve(ctx, server, listener, app, *shutdownTimeout); err != nil {
+		log.Printf("PackAndGo Server stopped with error: %v", err)
+		os.Exit(1)
+	}
+
+	log.Printf("PackAndGo Server stopped")
+}
+
+// serve runs the server until ctx is cancelled, then stops accepting new
+// connections, waits up to shutdownTimeout for in-flight requests to finish
+// and closes the application stores.
+func serve(ctx context.Context, server *http.Server, listener net.Listener, app *application, shutdownTimeout time.Duration) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if closeErr := app.Close(); closeErr != nil {
+			log.Printf("could not close application: %v", closeErr)
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	app.beginShutdown()
+	log.Printf("Shutting down, draining in-flight requests for up to %v", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := server.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		// Deadline exceeded, drop whatever is still open
+		server.Close()
+	}
+
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		shutdownErr = err
+	}
+
+	if err := app.Close(); err != nil {
+		return fmt.Errorf("could not close application: %w", err)
+	}
+
+	if shutdownErr != nil {
+		return fmt.Errorf("could not drain in-flight requests: %w", shutdownErr)
+	}
+
+	return nil
+}
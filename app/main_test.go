@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type mockCloser struct {
+	closed *[]string
+	name   string
+	err    error
+}
+
+func (mockCloser mockCloser) Close() error {
+	*mockCloser.closed = append(*mockCloser.closed, mockCloser.name)
+	return mockCloser.err
+}
+
+func TestServe_1(t *testing.T) {
+	started := make(chan struct{})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	closed := []string{}
+	app := &application{Router: router}
+	app.registerCloser(mockCloser{closed: &closed, name: "store"})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := &http.Server{Handler: app}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, server, listener, app, 5*time.Second)
+	}()
+
+	responseBody := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responseBody <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		responseBody <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	if body := <-responseBody; body != "done" {
+		t.Fatalf("expected in-flight request to complete with %v, got %v", "done", body)
+	}
+	if err := <-serveErr; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(closed) != 1 {
+		t.Fatalf("expected stores to be closed on shutdown, got %v", closed)
+	}
+}
+
+func TestServe_2(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/stuck", func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	})
+
+	app := &application{Router: router}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := &http.Server{Handler: app}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(ctx, server, listener, app, 50*time.Millisecond)
+	}()
+
+	go http.Get("http://" + listener.Addr().String() + "/stuck")
+
+	<-started
+	cancel()
+
+	if err := <-serveErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error: %v, got error: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestApplicationClose_1(t *testing.T) {
+	closed := []string{}
+	app := &application{}
+	app.registerCloser(mockCloser{closed: &closed, name: "first"})
+	app.registerCloser(mockCloser{closed: &closed, name: "second", err: errors.New("test error")})
+	app.registerCloser(struct{}{})
+
+	err := app.Close()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	if len(closed) != 2 || closed[0] != "second" || closed[1] != "first" {
+		t.Fatalf("expected stores to be closed in reverse order, got %v", closed)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+)
+
+const migrateUsage = `Usage:
+  pack-and-go migrate up -dsn <dsn>
+  pack-and-go migrate down -dsn <dsn> [-steps <n>]
+  pack-and-go migrate status -dsn <dsn>`
+
+// runMigrateCommand upgrades, downgrades or reports the schema of the
+// database of a SQL store, such as sql+postgres://localhost/packandgo
+func runMigrateCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "up" && args[0] != "down" && args[0] != "status") {
+		fmt.Fprintln(stderr, migrateUsage)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dsn := flags.String("dsn", "", "SQL store to migrate, as given to -trip_store and -city_store")
+	steps := flags.Int("steps", 1, "Number of migrations to revert with migrate down")
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if *dsn == "" || *steps < 1 {
+		fmt.Fprintln(stderr, migrateUsage)
+		return exitUsage
+	}
+
+	sqlDB, err := db.OpenSQLDB(*dsn)
+	if err != nil {
+		fmt.Fprintf(stderr, "could not open the SQL store: %v\n", err)
+		return exitFailure
+	}
+	defer sqlDB.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := sqlDB.MigrateUp()
+		for _, migration := range applied {
+			fmt.Fprintf(stdout, "applied %04d_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "migration failed: %v\n", err)
+			return exitFailure
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(stdout, "schema is up to date")
+		}
+	case "down":
+		reverted, err := sqlDB.MigrateDown(*steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(stdout, "reverted %04d_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "migration failed: %v\n", err)
+			return exitFailure
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(stdout, "no migration to revert")
+		}
+	case "status":
+		migrations, err := sqlDB.Migrations()
+		if err != nil {
+			fmt.Fprintf(stderr, "could not read the applied migrations: %v\n", err)
+			return exitFailure
+		}
+		printMigrations(stdout, migrations)
+	}
+
+	return exitValid
+}
+
+func printMigrations(w io.Writer, migrations []db.Migration) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED")
+	for _, migration := range migrations {
+		applied := "pending"
+		if migration.Applied {
+			applied = migration.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(table, "%04d\t%v\t%v\n", migration.Version, migration.Name, applied)
+	}
+	table.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	_ "github.com/gbandres98/pack-and-go/sqlmem"
+)
+
+func TestRunMigrateCommand_1(t *testing.T) {
+	dsn := "sql+sqlmem:" + t.Name()
+	var stdout, stderr bytes.Buffer
+
+	exitCode := runMigrateCommand([]string{"status", "-dsn", dsn}, &stdout, &stderr)
+	if exitCode != exitValid || strings.Count(stdout.String(), "pending") != 1 {
+		t.Fatalf("expected 1 pending migration, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	exitCode = runMigrateCommand([]string{"up", "-dsn", dsn}, &stdout, &stderr)
+	if exitCode != exitValid || stdout.String() != "applied 0001_create_tables\n" {
+		t.Fatalf("expected the migration to be applied, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	exitCode = runMigrateCommand([]string{"down", "-dsn", dsn, "-steps", "1"}, &stdout, &stderr)
+	if exitCode != exitValid || stdout.String() != "reverted 0001_create_tables\n" {
+		t.Fatalf("expected the migration to be reverted, got %v: %v%v", exitCode, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunMigrateCommand_2(t *testing.T) {
+	tests := []struct {
+		args     []string
+		exitCode int
+	}{
+		{args: []string{}, exitCode: exitUsage},
+		{args: []string{"sideways", "-dsn", "sql+sqlmem:test"}, exitCode: exitUsage},
+		{args: []string{"up"}, exitCode: exitUsage},
+		{args: []string{"down", "-dsn", "sql+sqlmem:test", "-steps", "0"}, exitCode: exitUsage},
+		{args: []string{"up", "-dsn", "sql+nosuchdriver:test"}, exitCode: exitFailure},
+		{args: []string{"up", "-dsn", "kv:///tmp"}, exitCode: exitFailure},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if exitCode := runMigrateCommand(test.args, &stdout, &stderr); exitCode != test.exitCode {
+			t.Fatalf("expected exit code %v for %v, got %v", test.exitCode, test.args, exitCode)
+		}
+	}
+}
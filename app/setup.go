@@ -1,47 +1,288 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
 	"log"
+	"time"
 
 	api_v1 "github.com/gbandres98/pack-and-go/api/v1"
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/backup"
 	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/health"
+	"github.com/gbandres98/pack-and-go/idempotency"
+	"github.com/gbandres98/pack-and-go/pricing"
 	"github.com/gbandres98/pack-and-go/service"
+	"github.com/gbandres98/pack-and-go/ticket"
+	"github.com/gbandres98/pack-and-go/webhook"
 	"github.com/gorilla/mux"
 )
 
+const defaultIdempotencyTTL = 24 * time.Hour
+const defaultSessionTTL = 24 * time.Hour
+const defaultEventBufferSize = 1000
+const defaultTripStore = "memory://"
+const defaultCityStore = "file://cities.txt"
+
 type applicationConfig struct {
-	fileDBPath string
+	// DSNs of the trip and city stores, opened with the driver registered for
+	// their scheme, such as memory:// or file:///var/lib/packandgo
+	tripStore      string
+	cityStore      string
+	idempotencyTTL time.Duration
+	sessionTTL     time.Duration
+	// No pricing rules are applied if empty
+	pricingFilePath       string
+	pricingReloadInterval time.Duration
+	// A new key is generated on every start if empty, so tickets can not be verified after a restart
+	ticketKeyPath string
+	// Events kept for clients resuming an event stream
+	eventBufferSize int
+	// Event streams are ended after this long so they are not cut by the
+	// server write timeout, 0 for never
+	eventStreamDuration time.Duration
+	// Zero fields take their default value
+	webhook webhook.Config
+	// Audit records are only kept in memory if empty
+	auditFilePath string
+	// Bearer token of the operator endpoints, such as backups, which are
+	// disabled if empty
+	adminToken string
+}
+
+type drainer interface {
+	SetDraining()
+}
+
+type application struct {
+	*mux.Router
+	closers []io.Closer
+	health  drainer
 }
 
-func setupApplication(applicationConfig applicationConfig) *mux.Router {
+func setupApplication(applicationConfig applicationConfig) *application {
+	app := &application{}
+	healthRegistry := health.NewRegistry()
+	app.health = healthRegistry
+
 	// Databases
-	fileDB := db.NewFileDB(applicationConfig.fileDBPath)
-	memoryDB := db.NewMemoryDB()
+	cityDB := openCityStore(applicationConfig.cityStore)
+	tripDB := openTripStore(applicationConfig.tripStore)
+	promoDB := db.NewPromoDB()
+	bookingDB := openBookingStore(applicationConfig.tripStore)
+	customerDB := openCustomerStore(applicationConfig.tripStore)
+	disruptionDB := db.NewDisruptionDB()
+	app.registerCloser(cityDB)
+	app.registerCloser(tripDB)
+	app.registerCloser(bookingDB)
+	app.registerCloser(customerDB)
+	healthRegistry.Register("cities", cityDB.Check)
+	healthRegistry.Register("trips", tripDB.Check)
+	healthRegistry.Register("bookings", bookingDB.Check)
+	healthRegistry.Register("customers", customerDB.Check)
+	healthRegistry.Register("disruptions", disruptionDB.Check)
+
+	// Events
+	eventBufferSize := applicationConfig.eventBufferSize
+	if eventBufferSize == 0 {
+		eventBufferSize = defaultEventBufferSize
+	}
+	eventHub := events.NewHub(eventBufferSize)
+	app.registerCloser(eventHub)
+	webhookDB := db.NewWebhookDB()
+	webhookDispatcher := webhook.NewDispatcher(webhookDB, eventHub, applicationConfig.webhook)
+	app.registerCloser(webhookDispatcher)
+
+	// Audit
+	auditLog, err := audit.NewLog(applicationConfig.auditFilePath)
+	if err != nil {
+		log.Fatalf("could not open audit log: %v", err)
+	}
+	app.registerCloser(auditLog)
 
 	// Services
-	tripService := service.NewTripService(fileDB, memoryDB)
+	tripService := service.NewTripService(cityDB, tripDB, disruptionDB, eventHub, auditLog)
+	cityService := service.NewCityService(cityDB, auditLog)
+	promoService := service.NewPromoService(promoDB)
+	sessionTTL := applicationConfig.sessionTTL
+	if sessionTTL == 0 {
+		sessionTTL = defaultSessionTTL
+	}
+	customerService := service.NewCustomerService(customerDB, sessionTTL)
+	webhookService := service.NewWebhookService(webhookDB, webhookDispatcher)
+
+	pricingEngine, err := pricing.NewEngine(applicationConfig.pricingFilePath)
+	if err != nil {
+		log.Fatalf("could not load pricing rules: %v", err)
+	}
+	if applicationConfig.pricingReloadInterval > 0 {
+		pricingEngine.Watch(applicationConfig.pricingReloadInterval)
+	}
+	app.registerCloser(pricingEngine)
+
+	ticketKey, err := loadTicketKey(applicationConfig.ticketKeyPath)
+	if err != nil {
+		log.Fatalf("could not load ticket key: %v", err)
+	}
+	bookingService := service.NewBookingService(tripDB, bookingDB, customerDB, disruptionDB, pricingEngine, promoService, ticketKey, auditLog)
 
 	// Controllers
 	tripController := api_v1.NewTripController(tripService)
+	cityController := api_v1.NewCityController(cityService)
+	gtfsController := api_v1.NewGTFSController(cityService, tripService)
+	pricingController := api_v1.NewPricingController(tripService, pricingEngine, promoService, bookingService)
+	promoController := api_v1.NewPromoController(promoService, tripService)
+	customerController := api_v1.NewCustomerController(customerService)
+	bookingController := api_v1.NewBookingController(bookingService)
+	webhookController := api_v1.NewWebhookController(webhookService)
+	eventsController := api_v1.NewEventsController(eventHub, applicationConfig.eventStreamDuration)
+	auditController := api_v1.NewAuditController(auditLog)
+	backupManager := backup.NewManager(cityDB, tripDB, bookingDB, customerDB, promoDB, disruptionDB, eventHub, auditLog)
+	backupController := api_v1.NewBackupController(backupManager)
+	adminController := api_v1.NewAdminController(applicationConfig.adminToken)
+
+	// Middlewares
+	idempotencyTTL := applicationConfig.idempotencyTTL
+	if idempotencyTTL == 0 {
+		idempotencyTTL = defaultIdempotencyTTL
+	}
+	idempotencyStore := idempotency.NewStore(idempotencyTTL)
 
 	// Routes
-	router := mux.NewRouter()	
-	api_v1.SetRoutes(router.PathPrefix("/api/v1").Subrouter(), *tripController)
+	router := mux.NewRouter()
+	health.SetRoutes(router, healthRegistry)
+	api_v1.SetRoutes(router.PathPrefix("/api/v1").Subrouter(), api_v1.Controllers{
+		Trip:       tripController,
+		City:       cityController,
+		GTFS:       gtfsController,
+		Pricing:    pricingController,
+		Promo:      promoController,
+		Customer:   customerController,
+		Booking:    bookingController,
+		Events:     eventsController,
+		Webhook:    webhookController,
+		Audit:      auditController,
+		Backup:     backupController,
+		Admin:      adminController,
+		Idempotent: idempotencyStore.Middleware,
+		Barrier:    backupManager.Middleware,
+	})
 
 	logRoutes(router)
-	
-	return router
+
+	app.Router = router
+	return app
+}
+
+func openTripStore(dsn string) db.TripStore {
+	if dsn == "" {
+		dsn = defaultTripStore
+	}
+
+	tripStore, err := db.OpenTripStore(dsn)
+	if err != nil {
+		log.Fatalf("could not open trip store: %v", err)
+	}
+
+	return tripStore
+}
+
+// openBookingStore opens the bookings kept along with the trip store of dsn
+func openBookingStore(dsn string) db.BookingStore {
+	if dsn == "" {
+		dsn = defaultTripStore
+	}
+
+	bookingStore, err := db.OpenBookingStore(dsn)
+	if err != nil {
+		log.Fatalf("could not open booking store: %v", err)
+	}
+
+	return bookingStore
+}
+
+// openCustomerStore opens the customers kept along with the bookings of dsn
+func openCustomerStore(dsn string) db.CustomerStore {
+	if dsn == "" {
+		dsn = defaultTripStore
+	}
+
+	customerStore, err := db.OpenCustomerStore(dsn)
+	if err != nil {
+		log.Fatalf("could not open customer store: %v", err)
+	}
+
+	return customerStore
+}
+
+func openCityStore(dsn string) db.CityStore {
+	if dsn == "" {
+		dsn = defaultCityStore
+	}
+
+	cityStore, err := db.OpenCityStore(dsn)
+	if err != nil {
+		log.Fatalf("could not open city store: %v", err)
+	}
+
+	return cityStore
+}
+
+func loadTicketKey(filePath string) (ed25519.PrivateKey, error) {
+	if filePath == "" {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+
+	return ticket.LoadOrCreateKey(filePath)
+}
+
+// registerCloser keeps track of stores that hold resources, so they are
+// flushed and closed when the application shuts down
+func (app *application) registerCloser(store interface{}) {
+	if closer, ok := store.(io.Closer); ok {
+		app.closers = append(app.closers, closer)
+	}
+}
+
+// beginShutdown marks the application as not ready, so load balancers stop
+// sending new requests while in-flight ones are drained
+func (app *application) beginShutdown() {
+	if app.health != nil {
+		app.health.SetDraining()
+	}
+}
+
+// Close closes every registered store in reverse order of registration,
+// returning the first error found
+func (app *application) Close() error {
+	var result error
+
+	for i := len(app.closers) - 1; i >= 0; i-- {
+		if err := app.closers[i].Close(); err != nil {
+			log.Printf("could not close store: %v", err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+	app.closers = nil
+
+	return result
 }
 
 func logRoutes(router *mux.Router) {
 	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
-        path, _ := route.GetPathTemplate()
+		path, _ := route.GetPathTemplate()
 		method, _ := route.GetMethods()
 
-		if (len(method) > 0) {
+		if len(method) > 0 {
 			log.Printf("%v %v", path, method)
-		}        
-        return nil
-    })
+		}
+		return nil
+	})
 
-}
\ No newline at end of file
+}
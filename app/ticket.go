@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/ticket"
+)
+
+const ticketUsage = `Usage:
+  pack-and-go ticket verify -public_key <key> [code...]
+  pack-and-go ticket public-key [-ticket_key <file>]`
+
+// Exit codes of pack-and-go ticket verify
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+func runTicketCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, ticketUsage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "verify":
+		return runTicketVerify(args[1:], os.Stdin, stdout, stderr)
+	case "public-key":
+		return runTicketPublicKey(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, ticketUsage)
+		return exitUsage
+	}
+}
+
+// runTicketVerify checks ticket codes with nothing but the public key, so
+// drivers can validate them without a connection to the server. Codes are
+// read from the arguments, or one per line from stdin if there are none.
+func runTicketVerify(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ticket verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	publicKeyFlag := flags.String("public_key", "", "Base64 Ed25519 public key, as printed by pack-and-go ticket public-key")
+	publicKeyFile := flags.String("public_key_file", "", "Path to a file with the public key, instead of -public_key")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	encodedKey := *publicKeyFlag
+	if *publicKeyFile != "" {
+		content, err := ioutil.ReadFile(*publicKeyFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "could not read public key: %v\n", err)
+			return exitUsage
+		}
+		encodedKey = string(content)
+	}
+
+	publicKey, err := ticket.DecodePublicKey(encodedKey)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	codes := flags.Args()
+	if len(codes) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				codes = append(codes, line)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		fmt.Fprintln(stderr, ticketUsage)
+		return exitUsage
+	}
+
+	result := exitValid
+	for _, code := range codes {
+		if !verifyTicketCode(code, publicKey, stdout) {
+			result = exitInvalid
+		}
+	}
+
+	return result
+}
+
+func verifyTicketCode(code string, publicKey ed25519.PublicKey, stdout io.Writer) bool {
+	verified, err := ticket.Verify(code, publicKey, time.Now())
+	if err == ticket.ErrorMalformed || err == ticket.ErrorSignature {
+		fmt.Fprintf(stdout, "INVALID %v\n", err)
+		return false
+	}
+
+	description := fmt.Sprintf("booking %v, trip %v, departure %v, seat %v, passenger %v",
+		verified.BookingId, verified.TripId, verified.Departure.Format(time.RFC3339), verified.Seat, verified.Passenger)
+	if err != nil {
+		fmt.Fprintf(stdout, "INVALID %v: %v\n", err, description)
+		return false
+	}
+
+	fmt.Fprintf(stdout, "VALID %v\n", description)
+	return true
+}
+
+func runTicketPublicKey(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ticket public-key", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	ticketKeyPath := flags.String("ticket_key", "ticket.key", "Path to the Ed25519 key that signs tickets")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if _, err := os.Stat(*ticketKeyPath); err != nil {
+		fmt.Fprintf(stderr, "could not read ticket key: %v\n", err)
+		return exitUsage
+	}
+
+	privateKey, err := ticket.LoadOrCreateKey(*ticketKeyPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	fmt.Fprintln(stdout, ticket.EncodePublicKey(privateKey.Public().(ed25519.PublicKey)))
+	return exitValid
+}
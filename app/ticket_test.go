@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/ticket"
+)
+
+var testTicketKey = ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+
+func issueTestTicket(t *testing.T, expiresAt time.Time) string {
+	code, err := ticket.Issue(ticket.Ticket{BookingId: 1, TripId: 2, Departure: expiresAt.Add(-time.Hour), Seat: 3, Passenger: "Alice Smith", ExpiresAt: expiresAt}, testTicketKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return code
+}
+
+func TestRunTicketVerify_1(t *testing.T) {
+	publicKey := ticket.EncodePublicKey(testTicketKey.Public().(ed25519.PublicKey))
+	code := issueTestTicket(t, time.Now().Add(time.Hour))
+
+	var stdout, stderr bytes.Buffer
+	exitCode := runTicketVerify([]string{"-public_key", publicKey, code}, strings.NewReader(""), &stdout, &stderr)
+
+	if exitCode != exitValid {
+		t.Fatalf("expected exit code %v, got %v: %v", exitValid, exitCode, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "VALID booking 1, trip 2") || !strings.Contains(stdout.String(), "seat 3, passenger Alice Smith") {
+		t.Fatalf("expected ticket details, got %v", stdout.String())
+	}
+}
+
+func TestRunTicketVerify_2(t *testing.T) {
+	publicKey := ticket.EncodePublicKey(testTicketKey.Public().(ed25519.PublicKey))
+	valid := issueTestTicket(t, time.Now().Add(time.Hour))
+	expired := issueTestTicket(t, time.Now().Add(-time.Hour))
+	middle := len(valid) / 2
+	replacement := "A"
+	if valid[middle] == 'A' {
+		replacement = "B"
+	}
+	tampered := valid[:middle] + replacement + valid[middle+1:]
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader(valid + "\n" + expired + "\n\n" + tampered + "\n")
+	exitCode := runTicketVerify([]string{"-public_key", publicKey}, stdin, &stdout, &stderr)
+
+	if exitCode != exitInvalid {
+		t.Fatalf("expected exit code %v, got %v", exitInvalid, exitCode)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "VALID") || !strings.HasPrefix(lines[1], "INVALID ticket has expired") || !strings.HasPrefix(lines[2], "INVALID") {
+		t.Fatalf("expected valid, expired and invalid results, got %v", stdout.String())
+	}
+}
+
+func TestRunTicketVerify_3(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	exitCode := runTicketVerify([]string{"-public_key", "not a key", "PG1:AAAA"}, strings.NewReader(""), &stdout, &stderr)
+	if exitCode != exitUsage {
+		t.Fatalf("expected exit code %v, got %v", exitUsage, exitCode)
+	}
+}
+
+func TestRunTicketPublicKey_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "ticket.key")
+	privateKey, err := ticket.LoadOrCreateKey(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, _ := runCommand([]string{"ticket", "public-key", "-ticket_key", filePath}, &stdout, &stderr)
+
+	expected := ticket.EncodePublicKey(privateKey.Public().(ed25519.PublicKey))
+	if exitCode != exitValid || strings.TrimSpace(stdout.String()) != expected {
+		t.Fatalf("expected %v, got %v %v", expected, exitCode, stdout.String()+stderr.String())
+	}
+}
+
+func TestRunCommand_1(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	_, ok := runCommand([]string{"-port", "8081"}, &stdout, &stderr)
+	if ok {
+		t.Fatalf("expected server flags not to run a command")
+	}
+
+	_, ok = runCommand([]string{}, &stdout, &stderr)
+	if ok {
+		t.Fatalf("expected no arguments not to run a command")
+	}
+}
@@ -0,0 +1,280 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/filelock"
+)
+
+const (
+	EntityTrip    = "trip"
+	EntityCity    = "city"
+	EntityBooking = "booking"
+	EntityBackup  = "backup"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionCancel  = "cancel"
+	ActionRestore = "restore"
+)
+
+// Longest record read from a log file
+const maxRecordSize = 16 << 20
+
+// ErrorBrokenChain is wrapped by the errors of records that do not follow the previous one
+var ErrorBrokenChain = errors.New("audit log chain is broken")
+
+// Record is a change to an entity. Every record holds the hash of the one
+// before it, so changing or removing a record breaks the chain from there on.
+type Record struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	RequestId string    `json:"requestId,omitempty"`
+	Entity    string    `json:"entity"`
+	EntityId  string    `json:"entityId"`
+	Action    string    `json:"action"`
+	// Entity as JSON before and after the change, empty for creations and deletions
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	Diff   []Change        `json:"diff"`
+	// Hash of the previous record, empty for the first one
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// Change is a top level field that changed, empty on the side it is missing from
+type Change struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Filter selects records, empty fields match every record
+type Filter struct {
+	Entity   string
+	EntityId string
+	Actor    string
+}
+
+// ComputeHash returns the hex SHA-256 of the record as JSON without its hash
+func (record Record) ComputeHash() string {
+	record.Hash = ""
+	body, _ := json.Marshal(record)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+type auditLog struct {
+	// Nil if records are only kept in memory
+	file     *os.File
+	records  []Record
+	lastHash string
+	lock     sync.RWMutex
+	now      func() time.Time
+}
+
+// NewLog returns a log that appends records to a JSON Lines file, continuing
+// the chain of the records already in it, or that only keeps them in memory
+// if filePath is empty. It fails if the chain in the file is broken.
+func NewLog(filePath string) (*auditLog, error) {
+	auditLog := &auditLog{records: []Record{}, now: time.Now}
+	if filePath == "" {
+		return auditLog, nil
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two processes appending to the log would break its chain
+	err = filelock.Lock(file)
+	if err == nil {
+		err = scan(file, func(record Record) {
+			auditLog.records = append(auditLog.records, record)
+			auditLog.lastHash = record.Hash
+		})
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%v: %w", filePath, err)
+	}
+
+	auditLog.file = file
+	return auditLog, nil
+}
+
+// Record appends a change to an entity made on behalf of the actor of ctx.
+// before is nil for creations and after is nil for deletions. Records that can
+// not be written are logged, the change they describe has already been made.
+func (auditLog *auditLog) Record(ctx context.Context, entity string, entityId string, action string, before interface{}, after interface{}) {
+	record := Record{
+		Actor:     ActorFrom(ctx),
+		RequestId: RequestIdFrom(ctx),
+		Entity:    entity,
+		EntityId:  entityId,
+		Action:    action,
+	}
+
+	var err error
+	record.Before, err = marshal(before)
+	if err == nil {
+		record.After, err = marshal(after)
+	}
+	if err == nil {
+		record.Diff, err = diff(record.Before, record.After)
+	}
+	if err != nil {
+		log.Printf("could not audit %v of %v %v: %v", action, entity, entityId, err)
+		return
+	}
+
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+
+	record.Seq = int64(len(auditLog.records)) + 1
+	record.Time = auditLog.now().UTC()
+	record.PrevHash = auditLog.lastHash
+	record.Hash = record.ComputeHash()
+
+	if auditLog.file != nil {
+		line, _ := json.Marshal(record)
+		_, err = auditLog.file.Write(append(line, '\n'))
+		if err == nil {
+			err = auditLog.file.Sync()
+		}
+		if err != nil {
+			log.Printf("could not write audit record of %v of %v %v: %v", action, entity, entityId, err)
+			return
+		}
+	}
+
+	auditLog.records = append(auditLog.records, record)
+	auditLog.lastHash = record.Hash
+}
+
+// Query returns the records matching filter, oldest first
+func (auditLog *auditLog) Query(filter Filter) []Record {
+	auditLog.lock.RLock()
+	defer auditLog.lock.RUnlock()
+
+	result := []Record{}
+	for _, record := range auditLog.records {
+		if (filter.Entity == "" || record.Entity == filter.Entity) &&
+			(filter.EntityId == "" || record.EntityId == filter.EntityId) &&
+			(filter.Actor == "" || record.Actor == filter.Actor) {
+			result = append(result, record)
+		}
+	}
+
+	return result
+}
+
+func (auditLog *auditLog) Close() error {
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+
+	if auditLog.file == nil {
+		return nil
+	}
+
+	err := auditLog.file.Close()
+	auditLog.file = nil
+	return err
+}
+
+// Verify checks the chain of the records in a JSON Lines log, returning how
+// many records are valid before the first one that breaks it
+func Verify(reader io.Reader) (int, error) {
+	count := 0
+	err := scan(reader, func(Record) { count++ })
+	return count, err
+}
+
+// scan reads the records of a JSON Lines log, checking each follows the one before
+func scan(reader io.Reader, read func(Record)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64<<10), maxRecordSize)
+
+	var previous Record
+	for scanner.Scan() {
+		var record Record
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return fmt.Errorf("%w: record after %v is not valid JSON: %v", ErrorBrokenChain, previous.Seq, err)
+		}
+
+		if record.Seq != previous.Seq+1 {
+			return fmt.Errorf("%w: record %v follows record %v", ErrorBrokenChain, record.Seq, previous.Seq)
+		}
+		if record.PrevHash != previous.Hash {
+			return fmt.Errorf("%w: record %v does not hold the hash of record %v", ErrorBrokenChain, record.Seq, previous.Seq)
+		}
+		if record.Hash != record.ComputeHash() {
+			return fmt.Errorf("%w: record %v has been modified", ErrorBrokenChain, record.Seq)
+		}
+
+		read(record)
+		previous = record
+	}
+
+	return scanner.Err()
+}
+
+func marshal(entity interface{}) (json.RawMessage, error) {
+	if entity == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(entity)
+}
+
+// diff returns the top level fields that differ between two JSON objects, by name
+func diff(before json.RawMessage, after json.RawMessage) ([]Change, error) {
+	beforeFields := map[string]json.RawMessage{}
+	afterFields := map[string]json.RawMessage{}
+	if before != nil {
+		if err := json.Unmarshal(before, &beforeFields); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &afterFields); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []Change{}
+	for field, value := range beforeFields {
+		if string(afterFields[field]) != string(value) {
+			changes = append(changes, Change{Field: field, Before: value, After: afterFields[field]})
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes = append(changes, Change{Field: field, After: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
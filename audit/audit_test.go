@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testTrip struct {
+	Id    int32   `json:"id"`
+	Dates string  `json:"dates"`
+	Price float64 `json:"price"`
+}
+
+func TestRecord_1(t *testing.T) {
+	auditLog, _ := NewLog("")
+	ctx := WithActor(context.Background(), "customer:7")
+
+	auditLog.Record(ctx, EntityTrip, "3", ActionCreate, nil, testTrip{Id: 3, Dates: "Mon", Price: 10})
+	auditLog.Record(ctx, EntityTrip, "3", ActionUpdate, testTrip{Id: 3, Dates: "Mon", Price: 10}, testTrip{Id: 3, Dates: "Mon", Price: 12.5})
+	auditLog.Record(context.Background(), EntityTrip, "4", ActionCreate, nil, testTrip{Id: 4})
+
+	records := auditLog.Query(Filter{Entity: EntityTrip, EntityId: "3"})
+	if len(records) != 2 || records[0].Action != ActionCreate || records[1].Action != ActionUpdate {
+		t.Fatalf("expected the creation and update of trip 3, got %v", records)
+	}
+
+	update := records[1]
+	if update.Actor != "customer:7" || update.Seq != 2 || update.PrevHash != records[0].Hash {
+		t.Fatalf("expected record 2 by customer:7 chained to record 1, got %v", update)
+	}
+	if len(update.Diff) != 1 || update.Diff[0].Field != "price" || string(update.Diff[0].Before) != "10" || string(update.Diff[0].After) != "12.5" {
+		t.Fatalf("expected a price change from 10 to 12.5, got %v", update.Diff)
+	}
+	if len(records[0].Diff) != 3 || records[0].Before != nil {
+		t.Fatalf("expected every field of the new trip in the diff, got %v", records[0].Diff)
+	}
+
+	anonymous := auditLog.Query(Filter{Actor: Anonymous})
+	if len(anonymous) != 1 || anonymous[0].EntityId != "4" {
+		t.Fatalf("expected the anonymous creation of trip 4, got %v", anonymous)
+	}
+}
+
+func TestRecord_2(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := NewLog(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auditLog.Record(context.Background(), EntityCity, "1", ActionCreate, nil, map[string]string{"name": "<Barcelona>"})
+	auditLog.Record(context.Background(), EntityCity, "2", ActionCreate, nil, map[string]string{"name": "Seville"})
+	auditLog.Close()
+
+	auditLog, err = NewLog(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error reopening the log: %v", err)
+	}
+	auditLog.Record(context.Background(), EntityCity, "2", ActionDelete, map[string]string{"name": "Seville"}, nil)
+	auditLog.Close()
+
+	content, _ := ioutil.ReadFile(filePath)
+	count, err := Verify(bytes.NewReader(content))
+	if err != nil || count != 3 {
+		t.Fatalf("expected 3 valid records, got %v: %v", count, err)
+	}
+}
+
+func TestVerify_1(t *testing.T) {
+	auditLog, _ := NewLog("")
+	for _, price := range []string{"10", "20", "30"} {
+		auditLog.Record(context.Background(), EntityTrip, "1", ActionUpdate, nil, map[string]string{"price": price})
+	}
+
+	var content bytes.Buffer
+	for _, record := range auditLog.Query(Filter{}) {
+		line, _ := json.Marshal(record)
+		content.Write(append(line, '\n'))
+	}
+	lines := strings.SplitAfter(content.String(), "\n")
+
+	tests := []struct {
+		description string
+		content     string
+		valid       int
+	}{
+		{description: "modified record", content: lines[0] + strings.Replace(lines[1], `"20"`, `"25"`, 1) + lines[2], valid: 1},
+		{description: "removed record", content: lines[0] + lines[2], valid: 1},
+		{description: "reordered records", content: lines[1] + lines[0] + lines[2], valid: 0},
+		{description: "truncated record", content: lines[0] + lines[1][:20], valid: 1},
+	}
+
+	for _, test := range tests {
+		count, err := Verify(strings.NewReader(test.content))
+		if !errors.Is(err, ErrorBrokenChain) || count != test.valid {
+			t.Fatalf("%v: expected a broken chain after %v records, got %v: %v", test.description, test.valid, count, err)
+		}
+	}
+}
+
+func TestMiddleware_1(t *testing.T) {
+	var requestIds []string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestIds = append(requestIds, RequestIdFrom(req.Context()))
+	}))
+
+	for _, sent := range []string{"abc-123", "", "not a valid id"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if sent != "" {
+			req.Header.Set(HeaderRequestId, sent)
+		}
+		responseRecorder := httptest.NewRecorder()
+		handler.ServeHTTP(responseRecorder, req)
+
+		if responseRecorder.Header().Get(HeaderRequestId) != requestIds[len(requestIds)-1] {
+			t.Fatalf("expected the request id to be sent back, got %v", responseRecorder.Header().Get(HeaderRequestId))
+		}
+	}
+
+	if requestIds[0] != "abc-123" || len(requestIds[1]) != 32 || len(requestIds[2]) != 32 {
+		t.Fatalf("expected the sent id to be kept and invalid ones replaced, got %v", requestIds)
+	}
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+const HeaderRequestId = "X-Request-Id"
+
+// Actor of the changes made by requests without credentials
+const Anonymous = "anonymous"
+
+// Request ids sent by clients are kept if they look like one
+var requestIdRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type actorContextKey struct{}
+type requestIdContextKey struct{}
+
+// Middleware gives every request an id, the one in its X-Request-Id header
+// if any, and sends it back in the response so changes can be traced to the
+// request that made them
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestId := req.Header.Get(HeaderRequestId)
+		if !requestIdRegexp.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+
+		w.Header().Set(HeaderRequestId, requestId)
+		ctx := context.WithValue(req.Context(), requestIdContextKey{}, requestId)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// WithActor returns a context whose changes are recorded as made by actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, Anonymous if it has none
+func ActorFrom(ctx context.Context) string {
+	actor, ok := ctx.Value(actorContextKey{}).(string)
+	if !ok {
+		return Anonymous
+	}
+
+	return actor
+}
+
+// RequestIdFrom returns the id given to the request of ctx by Middleware, if any
+func RequestIdFrom(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdContextKey{}).(string)
+	return requestId
+}
+
+func newRequestId() string {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+	return hex.EncodeToString(idBytes)
+}
@@ -0,0 +1,285 @@
+// Package backup writes and reads point-in-time archives of the trips, cities,
+// bookings, customers, promo codes and disruptions of a server, and takes
+// them without stopping it.
+//
+// An archive is a header line followed by the archive as JSON:
+//
+//	PACKANDGO-BACKUP <version> <length> <sha256>
+//
+// where length and sha256 are those of the JSON, so a truncated or modified
+// archive is refused before any of it is used.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Version of the archive format written by this binary. Archives of a newer
+// version are refused, as they may hold data this binary would drop, and so
+// are those of version 1, which lack customers, promo codes and disruptions
+// and would leave them out of step with the bookings.
+const Version = 2
+
+const magic = "PACKANDGO-BACKUP"
+
+// Keys of the counters of an archive
+const (
+	NextTripId    = "nextTripId"
+	NextBookingId = "nextBookingId"
+	NextLedgerId  = "nextLedgerId"
+	// Customers and passengers
+	NextCustomerId  = "nextCustomerId"
+	NextPassengerId = "nextPassengerId"
+)
+
+var ErrorInvalidArchive = errors.New("not a backup archive")
+var ErrorChecksumMismatch = errors.New("backup archive checksum mismatch")
+var ErrorNewerVersion = errors.New("backup archive version is newer than this binary")
+var ErrorOlderVersion = errors.New("backup archive version is too old to restore")
+
+// Archive is the state of a server at a point in time
+type Archive struct {
+	Version   int                 `json:"version"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Cities    []model.City        `json:"cities"`
+	Trips     []model.Trip        `json:"trips"`
+	Bookings  []model.Booking     `json:"bookings"`
+	Ledger    []model.LedgerEntry `json:"ledger"`
+	Customers []Customer          `json:"customers"`
+	// Passengers saved by the customers
+	Passengers       []model.Passenger       `json:"passengers"`
+	PromoCodes       []model.PromoCode       `json:"promoCodes"`
+	PromoRedemptions []model.PromoRedemption `json:"promoRedemptions"`
+	Disruptions      []model.Disruption      `json:"disruptions"`
+	// Next ids to be given, which are past the ids of deleted entities
+	Counters map[string]int32 `json:"counters"`
+}
+
+// Customer is a customer with the hash of their password, which is never
+// serialized elsewhere, so they can still log in after a restore
+type Customer struct {
+	model.Customer
+	PasswordHash string `json:"passwordHash"`
+}
+
+// Write writes the archive with its header
+func Write(w io.Writer, archive Archive) error {
+	body, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	_, err = fmt.Fprintf(w, "%v %v %v %v\n", magic, archive.Version, len(body), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(body)
+	return err
+}
+
+// Read reads an archive with Decode, and checks that it is consistent
+func Read(r io.Reader) (Archive, error) {
+	archive, err := Decode(r)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	return archive, archive.Validate()
+}
+
+// Decode reads an archive, checking its version, length and checksum before
+// decoding it, but not whether its content is consistent
+func Decode(r io.Reader) (Archive, error) {
+	reader := bufio.NewReader(r)
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return Archive{}, fmt.Errorf("%w: %v", ErrorInvalidArchive, err)
+	}
+
+	var headerMagic, checksum string
+	var version int
+	var length int64
+	_, err = fmt.Sscanf(header, "%s %d %d %s\n", &headerMagic, &version, &length, &checksum)
+	if err != nil || headerMagic != magic {
+		return Archive{}, fmt.Errorf("%w: invalid header", ErrorInvalidArchive)
+	}
+	if version > Version {
+		return Archive{}, fmt.Errorf("%w: archive version %v, expected up to %v", ErrorNewerVersion, version, Version)
+	}
+	if version < Version {
+		return Archive{}, fmt.Errorf("%w: archive version %v lacks customers, promo codes and disruptions, expected %v", ErrorOlderVersion, version, Version)
+	}
+
+	var body bytes.Buffer
+	copied, err := io.Copy(&body, io.LimitReader(reader, length+1))
+	if err != nil {
+		return Archive{}, err
+	}
+	if copied != length {
+		return Archive{}, fmt.Errorf("%w: expected %v bytes, got %v", ErrorChecksumMismatch, length, copied)
+	}
+	sum := sha256.Sum256(body.Bytes())
+	if hex.EncodeToString(sum[:]) != checksum {
+		return Archive{}, ErrorChecksumMismatch
+	}
+
+	var archive Archive
+	err = json.Unmarshal(body.Bytes(), &archive)
+	if err != nil {
+		return Archive{}, fmt.Errorf("%w: %v", ErrorInvalidArchive, err)
+	}
+	if archive.Version != version {
+		return Archive{}, fmt.Errorf("%w: header version %v does not match archive version %v", ErrorInvalidArchive, version, archive.Version)
+	}
+
+	return archive, nil
+}
+
+// Validate checks that cities have consecutive ids from 1, that trips go
+// between existing cities, that no seat is held by two confirmed bookings,
+// that bookings, passengers and redemptions belong to customers and codes of
+// the archive, and that the counters are past every id. Bookings can outlive
+// their trips and passengers.
+func (archive Archive) Validate() error {
+	for i, city := range archive.Cities {
+		if city.Id != int32(i+1) {
+			return fmt.Errorf("%w: city %v has id %v, expected %v", ErrorInvalidArchive, city.Name, city.Id, i+1)
+		}
+	}
+
+	trips := map[int32]bool{}
+	for _, trip := range archive.Trips {
+		if trip.Id <= 0 || trips[trip.Id] {
+			return fmt.Errorf("%w: invalid or repeated trip id %v", ErrorInvalidArchive, trip.Id)
+		}
+		if !archive.hasCity(trip.OriginId) || !archive.hasCity(trip.DestinationId) {
+			return fmt.Errorf("%w: trip %v goes between missing cities %v and %v", ErrorInvalidArchive, trip.Id, trip.OriginId, trip.DestinationId)
+		}
+		if trip.Id >= archive.Counters[NextTripId] {
+			return fmt.Errorf("%w: trip %v is not below %v %v", ErrorInvalidArchive, trip.Id, NextTripId, archive.Counters[NextTripId])
+		}
+		trips[trip.Id] = true
+	}
+
+	customers := map[int32]bool{}
+	emails := map[string]bool{}
+	for _, customer := range archive.Customers {
+		if customer.Id <= 0 || customers[customer.Id] {
+			return fmt.Errorf("%w: invalid or repeated customer id %v", ErrorInvalidArchive, customer.Id)
+		}
+		if customer.Id >= archive.Counters[NextCustomerId] {
+			return fmt.Errorf("%w: customer %v is not below %v %v", ErrorInvalidArchive, customer.Id, NextCustomerId, archive.Counters[NextCustomerId])
+		}
+		if emails[strings.ToLower(customer.Email)] {
+			return fmt.Errorf("%w: repeated customer email %v", ErrorInvalidArchive, customer.Email)
+		}
+		customers[customer.Id] = true
+		emails[strings.ToLower(customer.Email)] = true
+	}
+
+	passengers := map[int32]bool{}
+	for _, passenger := range archive.Passengers {
+		if passenger.Id <= 0 || passengers[passenger.Id] {
+			return fmt.Errorf("%w: invalid or repeated passenger id %v", ErrorInvalidArchive, passenger.Id)
+		}
+		if passenger.Id >= archive.Counters[NextPassengerId] {
+			return fmt.Errorf("%w: passenger %v is not below %v %v", ErrorInvalidArchive, passenger.Id, NextPassengerId, archive.Counters[NextPassengerId])
+		}
+		if !customers[passenger.CustomerId] {
+			return fmt.Errorf("%w: passenger %v belongs to missing customer %v", ErrorInvalidArchive, passenger.Id, passenger.CustomerId)
+		}
+		passengers[passenger.Id] = true
+	}
+
+	bookings := map[int32]bool{}
+	type seatKey struct {
+		departure model.DepartureKey
+		seat      int
+	}
+	seats := map[seatKey]int32{}
+	for _, booking := range archive.Bookings {
+		if booking.Id <= 0 || bookings[booking.Id] {
+			return fmt.Errorf("%w: invalid or repeated booking id %v", ErrorInvalidArchive, booking.Id)
+		}
+		if booking.Id >= archive.Counters[NextBookingId] {
+			return fmt.Errorf("%w: booking %v is not below %v %v", ErrorInvalidArchive, booking.Id, NextBookingId, archive.Counters[NextBookingId])
+		}
+		if !customers[booking.CustomerId] {
+			return fmt.Errorf("%w: booking %v belongs to missing customer %v", ErrorInvalidArchive, booking.Id, booking.CustomerId)
+		}
+		if booking.Status == model.BookingConfirmed {
+			key := seatKey{booking.DepartureKey(), booking.Seat}
+			if other, taken := seats[key]; taken {
+				return fmt.Errorf("%w: bookings %v and %v hold the same seat", ErrorInvalidArchive, other, booking.Id)
+			}
+			seats[key] = booking.Id
+		}
+		bookings[booking.Id] = true
+	}
+
+	ledger := map[int32]bool{}
+	for _, entry := range archive.Ledger {
+		if entry.Id <= 0 || ledger[entry.Id] {
+			return fmt.Errorf("%w: invalid or repeated ledger entry id %v", ErrorInvalidArchive, entry.Id)
+		}
+		if entry.Id >= archive.Counters[NextLedgerId] {
+			return fmt.Errorf("%w: ledger entry %v is not below %v %v", ErrorInvalidArchive, entry.Id, NextLedgerId, archive.Counters[NextLedgerId])
+		}
+		ledger[entry.Id] = true
+	}
+
+	promoCodes := map[string]bool{}
+	for _, promoCode := range archive.PromoCodes {
+		code := strings.ToUpper(promoCode.Code)
+		if code == "" || promoCodes[code] {
+			return fmt.Errorf("%w: invalid or repeated promo code %q", ErrorInvalidArchive, promoCode.Code)
+		}
+		promoCodes[code] = true
+	}
+	for _, redemption := range archive.PromoRedemptions {
+		if !promoCodes[strings.ToUpper(redemption.Code)] {
+			return fmt.Errorf("%w: redemption of missing promo code %v", ErrorInvalidArchive, redemption.Code)
+		}
+	}
+
+	disruptions := map[model.DepartureKey]bool{}
+	for _, disruption := range archive.Disruptions {
+		if disruptions[disruption.DepartureKey()] {
+			return fmt.Errorf("%w: trip %v has two disruptions on %v", ErrorInvalidArchive, disruption.TripId, disruption.Date)
+		}
+		disruptions[disruption.DepartureKey()] = true
+	}
+
+	return nil
+}
+
+// Summary returns the creation time of the archive and how many of each entity it holds
+func (archive Archive) Summary() Summary {
+	return Summary{
+		CreatedAt:     archive.CreatedAt,
+		Cities:        len(archive.Cities),
+		Trips:         len(archive.Trips),
+		Bookings:      len(archive.Bookings),
+		LedgerEntries: len(archive.Ledger),
+		Customers:     len(archive.Customers),
+		PromoCodes:    len(archive.PromoCodes),
+		Disruptions:   len(archive.Disruptions),
+	}
+}
+
+func (archive Archive) hasCity(id int32) bool {
+	return id >= 1 && int(id) <= len(archive.Cities)
+}
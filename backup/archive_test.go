@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func testArchive() Archive {
+	return Archive{
+		Version:          Version,
+		CreatedAt:        time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Cities:           []model.City{{Id: 1, Name: "Madrid"}, {Id: 2, Name: "Sevilla"}},
+		Trips:            []model.Trip{{Id: 1, OriginId: 1, DestinationId: 2, Dates: "Mon", Price: 10, Version: 1}},
+		Bookings:         []model.Booking{{Id: 1, CustomerId: 1, TripId: 1, Seat: 1, Status: model.BookingConfirmed}},
+		Ledger:           []model.LedgerEntry{},
+		Customers:        []Customer{{Customer: model.Customer{Id: 1, Email: "ana@example.com", Name: "Ana"}, PasswordHash: "hash"}},
+		Passengers:       []model.Passenger{{Id: 1, CustomerId: 1, FirstName: "Ana"}},
+		PromoCodes:       []model.PromoCode{{Code: "SUMMER10", Percent: 10, Uses: 1}},
+		PromoRedemptions: []model.PromoRedemption{{Code: "SUMMER10", Customer: "ana@example.com", Uses: 1}},
+		Disruptions:      []model.Disruption{{TripId: 1, Date: "2026-03-02", Status: model.DisruptionCancelled}},
+		Counters:         map[string]int32{NextTripId: 2, NextBookingId: 2, NextLedgerId: 1, NextCustomerId: 2, NextPassengerId: 2},
+	}
+}
+
+func TestRead_1(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Write(&buffer, testArchive()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	archive, err := Read(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(archive, testArchive()) {
+		t.Fatalf("expected %v, got %v", testArchive(), archive)
+	}
+}
+
+func TestRead_2(t *testing.T) {
+	var buffer bytes.Buffer
+	Write(&buffer, testArchive())
+	content := buffer.String()
+
+	newer := testArchive()
+	newer.Version = Version + 1
+	var newerBuffer bytes.Buffer
+	Write(&newerBuffer, newer)
+
+	older := testArchive()
+	older.Version = Version - 1
+	var olderBuffer bytes.Buffer
+	Write(&olderBuffer, older)
+
+	tests := []struct {
+		content  string
+		expected error
+	}{
+		{content: strings.Replace(content, `"price":10`, `"price":11`, 1), expected: ErrorChecksumMismatch},
+		{content: content[:len(content)-10], expected: ErrorChecksumMismatch},
+		{content: content + "{}", expected: ErrorChecksumMismatch},
+		{content: newerBuffer.String(), expected: ErrorNewerVersion},
+		{content: olderBuffer.String(), expected: ErrorOlderVersion},
+		{content: "PACKANDGO-BACKUP 1\n{}", expected: ErrorInvalidArchive},
+		{content: "", expected: ErrorInvalidArchive},
+	}
+
+	for _, test := range tests {
+		if _, err := Read(strings.NewReader(test.content)); !errors.Is(err, test.expected) {
+			t.Fatalf("expected error: %v, got error: %v", test.expected, err)
+		}
+	}
+}
+
+func TestValidate_1(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(archive *Archive)
+	}{
+		{name: "cities out of order", change: func(archive *Archive) { archive.Cities[0].Id = 3 }},
+		{name: "trip to a missing city", change: func(archive *Archive) { archive.Trips[0].DestinationId = 3 }},
+		{name: "repeated trip", change: func(archive *Archive) { archive.Trips = append(archive.Trips, archive.Trips[0]) }},
+		{name: "trip past the next id", change: func(archive *Archive) { archive.Counters[NextTripId] = 1 }},
+		{name: "booking past the next id", change: func(archive *Archive) { delete(archive.Counters, NextBookingId) }},
+		{name: "seat taken twice", change: func(archive *Archive) {
+			archive.Bookings = append(archive.Bookings, model.Booking{Id: 2, TripId: 1, Seat: 1, Status: model.BookingConfirmed})
+			archive.Counters[NextBookingId] = 3
+		}},
+		{name: "ledger entry past the next id", change: func(archive *Archive) {
+			archive.Ledger = append(archive.Ledger, model.LedgerEntry{Id: 1, BookingId: 1})
+		}},
+		{name: "booking of a missing customer", change: func(archive *Archive) { archive.Bookings[0].CustomerId = 2 }},
+		{name: "customer past the next id", change: func(archive *Archive) { archive.Counters[NextCustomerId] = 1 }},
+		{name: "repeated email", change: func(archive *Archive) {
+			archive.Customers = append(archive.Customers, Customer{Customer: model.Customer{Id: 2, Email: "ANA@example.com"}})
+			archive.Counters[NextCustomerId] = 3
+		}},
+		{name: "passenger of a missing customer", change: func(archive *Archive) { archive.Passengers[0].CustomerId = 2 }},
+		{name: "redemption of a missing code", change: func(archive *Archive) { archive.PromoCodes = nil }},
+		{name: "two disruptions of a departure", change: func(archive *Archive) {
+			archive.Disruptions = append(archive.Disruptions, archive.Disruptions[0])
+		}},
+	}
+
+	if err := testArchive().Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range tests {
+		archive := testArchive()
+		test.change(&archive)
+		if err := archive.Validate(); !errors.Is(err, ErrorInvalidArchive) {
+			t.Fatalf("expected %v to be invalid, got %v", test.name, err)
+		}
+	}
+}
+
+func TestDecode_1(t *testing.T) {
+	archive := testArchive()
+	archive.Trips[0].DestinationId = 3
+	var buffer bytes.Buffer
+	Write(&buffer, archive)
+
+	if _, err := Read(bytes.NewReader(buffer.Bytes())); !errors.Is(err, ErrorInvalidArchive) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorInvalidArchive, err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(buffer.Bytes()))
+	if err != nil || !reflect.DeepEqual(decoded, archive) {
+		t.Fatalf("expected %v, got %v %v", archive, decoded, err)
+	}
+}
@@ -0,0 +1,264 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// TripStore is implemented by trip stores that can be backed up and restored
+type TripStore interface {
+	ExportTrips() ([]model.Trip, int32, error)
+	ImportTrips([]model.Trip, int32) error
+}
+
+// CityStore is implemented by city stores that can be backed up and restored
+type CityStore interface {
+	GetAllCities() ([]model.City, error)
+	ImportCities([]model.City) error
+}
+
+// BookingStore is implemented by booking stores that can be backed up and
+// restored, with their bookings, ledger and next ids of both
+type BookingStore interface {
+	ExportBookings() ([]model.Booking, []model.LedgerEntry, int32, int32, error)
+	ImportBookings([]model.Booking, []model.LedgerEntry, int32, int32) error
+}
+
+// CustomerStore is implemented by customer stores that can be backed up and
+// restored, with their passengers and next ids of both
+type CustomerStore interface {
+	ExportCustomers() ([]model.Customer, []model.Passenger, int32, int32, error)
+	ImportCustomers([]model.Customer, []model.Passenger, int32, int32) error
+}
+
+// PromoStore is implemented by promo code stores that can be backed up and
+// restored, with the redemptions of every customer
+type PromoStore interface {
+	ExportPromoCodes() ([]model.PromoCode, []model.PromoRedemption, error)
+	ImportPromoCodes([]model.PromoCode, []model.PromoRedemption) error
+}
+
+// DisruptionStore is implemented by disruption stores that can be backed up and restored
+type DisruptionStore interface {
+	ExportDisruptions() ([]model.Disruption, error)
+	ImportDisruptions([]model.Disruption) error
+}
+
+// Publisher sends domain events to whoever listens to them
+type Publisher interface {
+	Publish(string, interface{})
+}
+
+// Auditor records who changed an entity and how
+type Auditor interface {
+	Record(ctx context.Context, entity string, entityId string, action string, before interface{}, after interface{})
+}
+
+// Summary is what a restore replaced and with what, as recorded in the audit
+// log and published to event streams
+type Summary struct {
+	CreatedAt     time.Time `json:"createdAt"`
+	Cities        int       `json:"cities"`
+	Trips         int       `json:"trips"`
+	Bookings      int       `json:"bookings"`
+	LedgerEntries int       `json:"ledgerEntries"`
+	Customers     int       `json:"customers"`
+	PromoCodes    int       `json:"promoCodes"`
+	Disruptions   int       `json:"disruptions"`
+}
+
+// Manager takes archives of the stores of a running server, and swaps
+// archives into them. Requests that write hold a read lock while they run,
+// so an archive is taken between writes. The write lock is only held while
+// the stores are copied, not while the archive is encoded and sent.
+type Manager struct {
+	cityStore       CityStore
+	tripStore       TripStore
+	bookingStore    BookingStore
+	customerStore   CustomerStore
+	promoStore      PromoStore
+	disruptionStore DisruptionStore
+	publisher       Publisher
+	auditor         Auditor
+	lock            sync.RWMutex
+	now             func() time.Time
+}
+
+func NewManager(cityStore CityStore, tripStore TripStore, bookingStore BookingStore, customerStore CustomerStore, promoStore PromoStore, disruptionStore DisruptionStore, publisher Publisher, auditor Auditor) *Manager {
+	return &Manager{
+		cityStore:       cityStore,
+		tripStore:       tripStore,
+		bookingStore:    bookingStore,
+		customerStore:   customerStore,
+		promoStore:      promoStore,
+		disruptionStore: disruptionStore,
+		publisher:       publisher,
+		auditor:         auditor,
+		now:             time.Now,
+	}
+}
+
+// Middleware holds the read lock during requests that may write, so archives
+// never see half of a change to several stores
+func (manager *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			manager.lock.RLock()
+			defer manager.lock.RUnlock()
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Backup returns an archive of the stores as they are now
+func (manager *Manager) Backup() (Archive, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	return manager.export()
+}
+
+// export copies the stores into an archive. The write lock must be held.
+func (manager *Manager) export() (Archive, error) {
+	cities, err := manager.cityStore.GetAllCities()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up cities: %w", err)
+	}
+
+	trips, nextTripId, err := manager.tripStore.ExportTrips()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up trips: %w", err)
+	}
+
+	bookings, ledger, nextBookingId, nextLedgerId, err := manager.bookingStore.ExportBookings()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up bookings: %w", err)
+	}
+
+	customers, passengers, nextCustomerId, nextPassengerId, err := manager.customerStore.ExportCustomers()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up customers: %w", err)
+	}
+	archivedCustomers := make([]Customer, len(customers))
+	for i, customer := range customers {
+		archivedCustomers[i] = Customer{Customer: customer, PasswordHash: customer.PasswordHash}
+	}
+
+	promoCodes, redemptions, err := manager.promoStore.ExportPromoCodes()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up promo codes: %w", err)
+	}
+
+	disruptions, err := manager.disruptionStore.ExportDisruptions()
+	if err != nil {
+		return Archive{}, fmt.Errorf("could not back up disruptions: %w", err)
+	}
+
+	return Archive{
+		Version:          Version,
+		CreatedAt:        manager.now().UTC(),
+		Cities:           cities,
+		Trips:            trips,
+		Bookings:         bookings,
+		Ledger:           ledger,
+		Customers:        archivedCustomers,
+		Passengers:       passengers,
+		PromoCodes:       promoCodes,
+		PromoRedemptions: redemptions,
+		Disruptions:      disruptions,
+		Counters: map[string]int32{
+			NextTripId:      nextTripId,
+			NextBookingId:   nextBookingId,
+			NextLedgerId:    nextLedgerId,
+			NextCustomerId:  nextCustomerId,
+			NextPassengerId: nextPassengerId,
+		},
+	}, nil
+}
+
+// Restore replaces the content of the stores with the archive, which is
+// validated first. If a store fails to import it, the stores are put back as
+// they were. A restore is recorded in the audit log and published as a
+// single event, not as a change to every entity.
+func (manager *Manager) Restore(ctx context.Context, archive Archive) error {
+	if archive.Version > Version {
+		return fmt.Errorf("%w: archive version %v, expected up to %v", ErrorNewerVersion, archive.Version, Version)
+	}
+	if archive.Version < Version {
+		return fmt.Errorf("%w: archive version %v lacks customers, promo codes and disruptions, expected %v", ErrorOlderVersion, archive.Version, Version)
+	}
+	if err := archive.Validate(); err != nil {
+		return err
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	previous, err := manager.export()
+	if err != nil {
+		return err
+	}
+
+	err = manager.swap(archive)
+	if err != nil {
+		if rollbackErr := manager.swap(previous); rollbackErr != nil {
+			log.Printf("could not put the stores back after a failed restore: %v", rollbackErr)
+		}
+		return err
+	}
+
+	manager.auditor.Record(ctx, audit.EntityBackup, archive.CreatedAt.UTC().Format(time.RFC3339), audit.ActionRestore, previous.Summary(), archive.Summary())
+	manager.publisher.Publish(events.BackupRestored, archive.Summary())
+	return nil
+}
+
+// swap imports an archive into every store. The write lock must be held.
+func (manager *Manager) swap(archive Archive) error {
+	err := manager.cityStore.ImportCities(archive.Cities)
+	if err != nil {
+		return fmt.Errorf("could not restore cities: %w", err)
+	}
+
+	err = manager.tripStore.ImportTrips(archive.Trips, archive.Counters[NextTripId])
+	if err != nil {
+		return fmt.Errorf("could not restore trips: %w", err)
+	}
+
+	err = manager.bookingStore.ImportBookings(archive.Bookings, archive.Ledger, archive.Counters[NextBookingId], archive.Counters[NextLedgerId])
+	if err != nil {
+		return fmt.Errorf("could not restore bookings: %w", err)
+	}
+
+	customers := make([]model.Customer, len(archive.Customers))
+	for i, customer := range archive.Customers {
+		customers[i] = customer.Customer
+		customers[i].PasswordHash = customer.PasswordHash
+	}
+	err = manager.customerStore.ImportCustomers(customers, archive.Passengers, archive.Counters[NextCustomerId], archive.Counters[NextPassengerId])
+	if err != nil {
+		return fmt.Errorf("could not restore customers: %w", err)
+	}
+
+	err = manager.promoStore.ImportPromoCodes(archive.PromoCodes, archive.PromoRedemptions)
+	if err != nil {
+		return fmt.Errorf("could not restore promo codes: %w", err)
+	}
+
+	err = manager.disruptionStore.ImportDisruptions(archive.Disruptions)
+	if err != nil {
+		return fmt.Errorf("could not restore disruptions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type mockPublisher struct {
+	published []string
+}
+
+func (mockPublisher *mockPublisher) Publish(eventType string, data interface{}) {
+	mockPublisher.published = append(mockPublisher.published, eventType)
+}
+
+type mockAuditor struct {
+	actions []string
+	before  []interface{}
+	after   []interface{}
+}
+
+func (mockAuditor *mockAuditor) Record(ctx context.Context, entity string, entityId string, action string, before interface{}, after interface{}) {
+	mockAuditor.actions = append(mockAuditor.actions, entity+" "+action)
+	mockAuditor.before = append(mockAuditor.before, before)
+	mockAuditor.after = append(mockAuditor.after, after)
+}
+
+func newTestManager(t *testing.T) *Manager {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	if err := os.WriteFile(filePath, []byte("Madrid\nSevilla\nValencia\nBilbao\nMálaga\nZaragoza\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return NewManager(db.NewFileDB(filePath), db.NewMemoryDB(), db.NewBookingDB(), db.NewCustomerDB(), db.NewPromoDB(), db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+}
+
+func TestRestore_1(t *testing.T) {
+	manager := newTestManager(t)
+
+	archive, err := manager.Backup()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(archive.Cities) != 6 || len(archive.Trips) != 3 || archive.Counters[NextTripId] != 4 {
+		t.Fatalf("expected 6 cities and 3 trips, got %v", archive)
+	}
+
+	manager.tripStore.(db.TripStore).AddTrip(model.Trip{OriginId: 1, DestinationId: 2})
+	manager.cityStore.(db.CityStore).AddCity(model.City{Name: "Granada"})
+
+	if err := manager.Restore(context.Background(), archive); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	restored, _ := manager.Backup()
+	if len(restored.Cities) != 6 || len(restored.Trips) != 3 || restored.Counters[NextTripId] != 4 {
+		t.Fatalf("expected the archive to be restored, got %v", restored)
+	}
+
+	// The restore is audited and published once, with what it replaced
+	auditor := manager.auditor.(*mockAuditor)
+	if len(auditor.actions) != 1 || auditor.actions[0] != "backup restore" || auditor.before[0].(Summary).Trips != 4 || auditor.after[0].(Summary).Trips != 3 {
+		t.Fatalf("expected the restore of 4 trips by 3 to be audited, got %v %v %v", auditor.actions, auditor.before, auditor.after)
+	}
+	if published := manager.publisher.(*mockPublisher).published; len(published) != 1 || published[0] != events.BackupRestored {
+		t.Fatalf("expected a %v event, got %v", events.BackupRestored, published)
+	}
+}
+
+func TestRestore_2(t *testing.T) {
+	manager := newTestManager(t)
+	before, _ := manager.Backup()
+
+	archive := before
+	archive.Bookings = []model.Booking{{Id: 1, TripId: 1, Seat: 1, Status: model.BookingConfirmed}}
+	archive.Counters = map[string]int32{NextTripId: 4, NextBookingId: 1, NextLedgerId: 1}
+	if err := manager.Restore(context.Background(), archive); !errors.Is(err, ErrorInvalidArchive) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorInvalidArchive, err)
+	}
+
+	archive.Version = Version + 1
+	if err := manager.Restore(context.Background(), archive); !errors.Is(err, ErrorNewerVersion) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorNewerVersion, err)
+	}
+
+	after, _ := manager.Backup()
+	if len(after.Trips) != len(before.Trips) || len(after.Cities) != len(before.Cities) {
+		t.Fatalf("expected the stores not to change, got %v", after)
+	}
+	if actions := manager.auditor.(*mockAuditor).actions; len(actions) != 0 {
+		t.Fatalf("expected refused restores not to be audited, got %v", actions)
+	}
+}
+
+func TestRestore_3(t *testing.T) {
+	manager := newTestManager(t)
+	customerDB := db.NewCustomerDB()
+	promoDB := db.NewPromoDB()
+	disruptionDB := db.NewDisruptionDB()
+	manager.customerStore, manager.promoStore, manager.disruptionStore = customerDB, promoDB, disruptionDB
+
+	customer, _ := customerDB.AddCustomer(model.Customer{Email: "ana@example.com", PasswordHash: "hash"})
+	customerDB.AddPassenger(model.Passenger{CustomerId: customer.Id, FirstName: "Ana"})
+	promoDB.AddPromoCode(model.PromoCode{Code: "SUMMER10", Percent: 10})
+	promoDB.RedeemPromoCode("SUMMER10", customer.Email, func(model.PromoCode) error { return nil })
+	disruptionDB.SetDisruption(model.Disruption{TripId: 1, Date: "2026-03-02", Status: model.DisruptionCancelled, ExpiresAt: time.Now().Add(time.Hour)})
+
+	archive, err := manager.Backup()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	customerDB = db.NewCustomerDB()
+	promoDB = db.NewPromoDB()
+	disruptionDB = db.NewDisruptionDB()
+	manager.customerStore, manager.promoStore, manager.disruptionStore = customerDB, promoDB, disruptionDB
+	if err := manager.Restore(context.Background(), archive); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if restored, err := customerDB.GetCustomerByEmail("ana@example.com"); err != nil || restored.PasswordHash != "hash" {
+		t.Fatalf("expected the customer to be restored with its password hash, got %v %v", restored, err)
+	}
+	if passengers := customerDB.GetPassengers(customer.Id); len(passengers) != 1 {
+		t.Fatalf("expected the passenger to be restored, got %v", passengers)
+	}
+	if next, _ := customerDB.AddCustomer(model.Customer{Email: "luis@example.com"}); next.Id != customer.Id+1 {
+		t.Fatalf("expected customer %v, got %v", customer.Id+1, next.Id)
+	}
+	if uses := promoDB.GetPromoCodeUses("SUMMER10", customer.Email); uses != 1 {
+		t.Fatalf("expected the redemption to be restored, got %v uses", uses)
+	}
+	if disruptions := disruptionDB.GetDisruptions(); len(disruptions) != 1 {
+		t.Fatalf("expected the disruption to be restored, got %v", disruptions)
+	}
+}
+
+func TestMiddleware_1(t *testing.T) {
+	manager := newTestManager(t)
+
+	writing := make(chan bool)
+	release := make(chan bool)
+	handler := manager.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writing <- true
+		<-release
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/trip", nil))
+	<-writing
+
+	backedUp := make(chan bool)
+	go func() {
+		manager.Backup()
+		backedUp <- true
+	}()
+
+	select {
+	case <-backedUp:
+		t.Fatalf("expected the backup to wait for the write in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- true
+	select {
+	case <-backedUp:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the backup to be taken after the write")
+	}
+}
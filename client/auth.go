@@ -0,0 +1,24 @@
+package client
+
+import "net/http"
+
+// Authenticator adds credentials to the requests of a client, before every
+// attempt, so credentials can be renewed between retries
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adds credentials to requests with a function
+type AuthenticatorFunc func(req *http.Request) error
+
+func (authenticatorFunc AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return authenticatorFunc(req)
+}
+
+// BearerToken sends token in the Authorization header, as the session tokens of customers are sent
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
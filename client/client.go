@@ -0,0 +1,360 @@
+// Package client is a Go client of the trips API of a PackAndGo server.
+//
+// Requests that fail with a 5xx or 429 response, or that can not be sent, are
+// retried with backoff. Trips are added with an Idempotency-Key, so retries
+// never add a trip twice, and updated and deleted with the ETag they were
+// read at, so changes made in the meantime are not overwritten.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Bytes of an error response kept as its message
+const maxErrorBody = 4096
+
+type Config struct {
+	// Client that sends the requests, with a timeout of 30 seconds if nil
+	HTTPClient *http.Client
+	// Adds credentials to every request, none if nil
+	Auth Authenticator
+	// Retries of a request after its first attempt, negative for none
+	MaxRetries int
+	// Wait before the first retry, doubled after every retry up to
+	// MaxBackoff. A longer Retry-After of the server is waited instead.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Trips requested at a time by ListTrips
+	PageSize int
+}
+
+var DefaultConfig = Config{
+	MaxRetries: 3,
+	Backoff:    100 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	PageSize:   100,
+}
+
+// Client of the API of a server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	config     Config
+	httpClient *http.Client
+}
+
+// Trip is a trip as the API shows it, with the ETag of the version it was
+// read at, which UpdateTrip and DeleteTrip need
+type Trip struct {
+	model.TripPretty
+	ETag string `json:"-"`
+}
+
+// TripFilter selects the trips listed by ListTrips. Zero fields select every trip.
+type TripFilter struct {
+	OriginId      int32
+	DestinationId int32
+	// Lists the trips as they were at a past time, if the server keeps trip history
+	AsOf time.Time
+}
+
+// NewClient returns a client of the server at serverURL, such as
+// http://localhost:8080. Zero config fields take their default value.
+func NewClient(serverURL string, config Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimRight(serverURL, "/") + "/api/v1/")
+	if err != nil {
+		return nil, err
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL: %v", serverURL)
+	}
+
+	config = config.withDefaults()
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &Client{baseURL: baseURL, config: config, httpClient: httpClient}, nil
+}
+
+func (config Config) withDefaults() Config {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultConfig.MaxRetries
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultConfig.Backoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = DefaultConfig.PageSize
+	}
+
+	return config
+}
+
+// ListTrips returns every trip selected by filter, following the pages of
+// the server until the last one
+func (client *Client) ListTrips(ctx context.Context, filter TripFilter) ([]model.TripPretty, error) {
+	params := url.Values{}
+	if filter.OriginId != 0 {
+		params.Set("originId", strconv.Itoa(int(filter.OriginId)))
+	}
+	if filter.DestinationId != 0 {
+		params.Set("destinationId", strconv.Itoa(int(filter.DestinationId)))
+	}
+	if !filter.AsOf.IsZero() {
+		params.Set("asOf", filter.AsOf.Format(time.RFC3339))
+	}
+	params.Set("limit", strconv.Itoa(client.config.PageSize))
+
+	result := []model.TripPretty{}
+	next := "trip?" + params.Encode()
+	for next != "" {
+		var page []model.TripPretty
+		header, err := client.do(ctx, http.MethodGet, next, nil, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page...)
+		next = nextLink(header.Get("Link"))
+	}
+
+	return result, nil
+}
+
+func (client *Client) GetTrip(ctx context.Context, id int32) (Trip, error) {
+	var trip Trip
+	header, err := client.do(ctx, http.MethodGet, fmt.Sprintf("trip/%v", id), nil, nil, &trip.TripPretty)
+	if err != nil {
+		return Trip{}, err
+	}
+
+	trip.ETag = header.Get("ETag")
+	return trip, nil
+}
+
+// AddTrip adds a trip between the cities with the origin and destination ids of trip
+func (client *Client) AddTrip(ctx context.Context, trip model.Trip) (Trip, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return Trip{}, err
+	}
+
+	return client.writeTrip(ctx, http.MethodPost, "trip", http.Header{"Idempotency-Key": {key}}, trip)
+}
+
+// UpdateTrip replaces the trip with the given id, failing with
+// ErrorVersionMismatch if it has changed since it was read with etag
+func (client *Client) UpdateTrip(ctx context.Context, id int32, etag string, trip model.Trip) (Trip, error) {
+	if etag == "" {
+		return Trip{}, fmt.Errorf("updating trip %v needs the ETag it was read at", id)
+	}
+
+	return client.writeTrip(ctx, http.MethodPut, fmt.Sprintf("trip/%v", id), http.Header{"If-Match": {etag}}, trip)
+}
+
+// DeleteTrip deletes the trip with the given id, failing with
+// ErrorVersionMismatch if it has changed since it was read with etag
+func (client *Client) DeleteTrip(ctx context.Context, id int32, etag string) error {
+	if etag == "" {
+		return fmt.Errorf("deleting trip %v needs the ETag it was read at", id)
+	}
+
+	_, err := client.do(ctx, http.MethodDelete, fmt.Sprintf("trip/%v", id), http.Header{"If-Match": {etag}}, nil, nil)
+	return err
+}
+
+func (client *Client) writeTrip(ctx context.Context, method string, path string, header http.Header, trip model.Trip) (Trip, error) {
+	body, err := json.Marshal(trip)
+	if err != nil {
+		return Trip{}, err
+	}
+
+	var saved Trip
+	header, err = client.do(ctx, method, path, header, body, &saved.TripPretty)
+	if err != nil {
+		return Trip{}, err
+	}
+
+	saved.ETag = header.Get("ETag")
+	return saved, nil
+}
+
+// do sends a request to path, relative to the API, retrying it while it
+// fails with a retryable error, and decodes the response into result unless
+// it is nil. It returns the headers of the response.
+func (client *Client) do(ctx context.Context, method string, path string, header http.Header, body []byte, result interface{}) (http.Header, error) {
+	target, err := client.baseURL.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.send(ctx, method, target.String(), header, body)
+		if err == nil && !retryable(res.StatusCode) {
+			defer res.Body.Close()
+			return readResponse(res, result)
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		wait := client.backoff(attempt)
+		if err == nil {
+			err = responseError(res)
+			if retryAfter := parseRetryAfter(res.Header.Get("Retry-After")); retryAfter > wait {
+				wait = retryAfter
+			}
+			res.Body.Close()
+		}
+
+		if attempt >= client.config.MaxRetries {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (client *Client) send(ctx context.Context, method string, target string, header http.Header, body []byte) (*http.Response, error) {
+	var requestBody io.Reader
+	if body != nil {
+		requestBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Credentials are added on every attempt, so they can be renewed between retries
+	if client.config.Auth != nil {
+		err = client.config.Auth.Authenticate(req)
+		if err != nil {
+			return nil, fmt.Errorf("could not authenticate request: %w", err)
+		}
+	}
+
+	return client.httpClient.Do(req)
+}
+
+// backoff returns the wait after a number of retries
+func (client *Client) backoff(retries int) time.Duration {
+	backoff := client.config.Backoff
+	for i := 0; i < retries && backoff < client.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > client.config.MaxBackoff {
+		backoff = client.config.MaxBackoff
+	}
+
+	return backoff
+}
+
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+func readResponse(res *http.Response, result interface{}) (http.Header, error) {
+	if res.StatusCode >= 300 {
+		return nil, responseError(res)
+	}
+
+	if result != nil {
+		err := json.NewDecoder(res.Body).Decode(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+	}
+
+	return res.Header, nil
+}
+
+func responseError(res *http.Response) *Error {
+	message, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	return &Error{
+		StatusCode: res.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+		RequestId:  res.Header.Get("X-Request-Id"),
+	}
+}
+
+// parseRetryAfter returns the wait of a Retry-After header in seconds or as a date, 0 if there is none
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+// nextLink returns the URL of a Link header with rel="next", if any
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` || strings.TrimSpace(param) == "rel=next" {
+				return strings.Trim(target, "<>")
+			}
+		}
+	}
+
+	return ""
+}
+
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", errors.New("could not generate an idempotency key")
+	}
+
+	return hex.EncodeToString(key), nil
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, config Config) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config.Backoff = time.Millisecond
+	client, err := NewClient(server.URL, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return client
+}
+
+func TestListTrips_1(t *testing.T) {
+	trips := []string{
+		`{"id":1,"origin":"Madrid","destination":"Sevilla"}`,
+		`{"id":2,"origin":"Madrid","destination":"Valencia"}`,
+		`{"id":5,"origin":"Madrid","destination":"Bilbao"}`,
+	}
+	requests := []string{}
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.URL.RequestURI())
+		switch req.URL.Query().Get("after") {
+		case "":
+			w.Header().Set("Link", `</api/v1/trip?after=2&limit=2&originId=3>; rel="next"`)
+			fmt.Fprintf(w, "[%v,%v]", trips[0], trips[1])
+		default:
+			fmt.Fprintf(w, "[%v]", trips[2])
+		}
+	}, Config{PageSize: 2})
+
+	result, err := client.ListTrips(context.Background(), TripFilter{OriginId: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"/api/v1/trip?limit=2&originId=3", "/api/v1/trip?after=2&limit=2&originId=3"}
+	if strings.Join(requests, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+	if len(result) != 3 || result[2].Id != 5 || result[2].Destination != "Bilbao" {
+		t.Fatalf("expected the 3 trips of both pages, got %v", result)
+	}
+}
+
+func TestAddTrip_1(t *testing.T) {
+	var lock sync.Mutex
+	keys := []string{}
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		switch len(keys) {
+		case 1:
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		default:
+			w.Header().Set("ETag", `"4-1"`)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":4,"origin":"Madrid","destination":"Sevilla","dates":"Mon","price":10}`)
+		}
+	}, Config{})
+
+	trip, err := client.AddTrip(context.Background(), model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon", Price: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if trip.Id != 4 || trip.ETag != `"4-1"` {
+		t.Fatalf("expected trip 4 with its ETag, got %v", trip)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Fatalf("expected 3 attempts with the same idempotency key, got %v", keys)
+	}
+}
+
+func TestGetTrip_1(t *testing.T) {
+	attempts := 0
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.Header().Set("X-Request-Id", "req-1")
+		switch req.URL.Path {
+		case "/api/v1/trip/7":
+			http.Error(w, "Not Found - no trip found with id: 7", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal Server Error - disk full", http.StatusInternalServerError)
+		}
+	}, Config{MaxRetries: 2})
+
+	_, err := client.GetTrip(context.Background(), 7)
+	var apiErr *Error
+	if !errors.Is(err, ErrorNotFound) || !errors.As(err, &apiErr) || apiErr.RequestId != "req-1" || attempts != 1 {
+		t.Fatalf("expected error: %v without retries, got error: %v after %v attempts", ErrorNotFound, err, attempts)
+	}
+	if err.Error() != "Not Found - no trip found with id: 7 (request req-1)" {
+		t.Fatalf("expected the message of the server, got %v", err)
+	}
+
+	attempts = 0
+	_, err = client.GetTrip(context.Background(), 1)
+	if !errors.Is(err, ErrorServer) || attempts != 3 {
+		t.Fatalf("expected error: %v after 3 attempts, got error: %v after %v attempts", ErrorServer, err, attempts)
+	}
+}
+
+func TestGetTrip_2(t *testing.T) {
+	attempts := 0
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	}, Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetTrip(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 1 || time.Since(start) > time.Second {
+		t.Fatalf("expected the context to stop the retries, got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestUpdateTrip_1(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if req.Header.Get("If-Match") != `"1-2"` {
+			http.Error(w, "Precondition Failed - trip has been modified", http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("ETag", `"1-3"`)
+		fmt.Fprint(w, `{"id":1,"price":20}`)
+	}, Config{Auth: BearerToken("secret")})
+
+	tests := []struct {
+		etag     string
+		expected error
+	}{
+		{etag: `"1-2"`},
+		{etag: `"1-1"`, expected: ErrorVersionMismatch},
+	}
+
+	for _, test := range tests {
+		trip, err := client.UpdateTrip(context.Background(), 1, test.etag, model.Trip{Price: 20})
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("expected error: %v, got error: %v", test.expected, err)
+		}
+		if err == nil && trip.ETag != `"1-3"` {
+			t.Fatalf("expected the ETag of the new version, got %v", trip.ETag)
+		}
+	}
+
+	if _, err := client.UpdateTrip(context.Background(), 1, "", model.Trip{}); err == nil {
+		t.Fatalf("expected updates without an ETag to fail")
+	}
+
+	unauthorized, _ := NewClient(client.baseURL.Scheme+"://"+client.baseURL.Host, Config{})
+	if err := unauthorized.DeleteTrip(context.Background(), 1, `"1-2"`); !errors.Is(err, ErrorUnauthorized) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorUnauthorized, err)
+	}
+}
+
+func TestNextLink_1(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: ""},
+		{header: `</api/v1/trip?after=50&limit=50>; rel="next"`, expected: "/api/v1/trip?after=50&limit=50"},
+		{header: `</a>; rel="prev", </b>; rel=next`, expected: "/b"},
+		{header: `</a>; rel="prev"`, expected: ""},
+	}
+
+	for _, test := range tests {
+		if result := nextLink(test.header); result != test.expected {
+			t.Fatalf("expected %v for %v, got %v", test.expected, test.header, result)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors that an *Error of the server is, with errors.Is, by its status
+var ErrorBadRequest = errors.New("request refused by the server")
+var ErrorUnauthorized = errors.New("request not authorized")
+var ErrorNotFound = errors.New("not found")
+var ErrorConflict = errors.New("conflict with the current state")
+var ErrorVersionMismatch = errors.New("trip has been modified since it was read")
+var ErrorRateLimited = errors.New("too many requests")
+var ErrorServer = errors.New("server error")
+
+// Error is an error response of the server
+type Error struct {
+	StatusCode int
+	// Message of the server, such as "Not Found - no trip found with id: 7"
+	Message string
+	// Id of the request in the logs and audit records of the server
+	RequestId string
+}
+
+func (err *Error) Error() string {
+	message := err.Message
+	if message == "" {
+		message = fmt.Sprintf("%v %v", err.StatusCode, http.StatusText(err.StatusCode))
+	}
+	if err.RequestId != "" {
+		message = fmt.Sprintf("%v (request %v)", message, err.RequestId)
+	}
+
+	return message
+}
+
+// Is tells whether the error is one of the errors of its status, so callers
+// can check errors.Is(err, client.ErrorNotFound)
+func (err *Error) Is(target error) bool {
+	switch err.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrorBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return target == ErrorUnauthorized
+	case http.StatusNotFound:
+		return target == ErrorNotFound
+	case http.StatusConflict:
+		return target == ErrorConflict
+	case http.StatusPreconditionFailed:
+		return target == ErrorVersionMismatch
+	case http.StatusTooManyRequests:
+		return target == ErrorRateLimited
+	}
+
+	return err.StatusCode >= 500 && target == ErrorServer
+}
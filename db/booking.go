@@ -0,0 +1,252 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type bookingDB struct {
+	bookings []model.Booking
+	// Booking holding every taken seat of a departure
+	seats  map[model.DepartureKey]map[int]int32
+	ledger []model.LedgerEntry
+	nextId int32
+	// Ledger entries are numbered apart from bookings
+	nextLedgerId int32
+	lock         sync.RWMutex
+}
+
+func NewBookingDB() *bookingDB {
+	return &bookingDB{
+		bookings:     []model.Booking{},
+		seats:        map[model.DepartureKey]map[int]int32{},
+		ledger:       []model.LedgerEntry{},
+		nextId:       1,
+		nextLedgerId: 1,
+	}
+}
+
+// AddBooking saves a booking with the lowest free seat of its departure,
+// failing with ErrorDepartureFull if all capacity seats are taken. Seats are
+// assigned atomically, so concurrent bookings never get the same seat.
+func (bookingDB *bookingDB) AddBooking(booking model.Booking, capacity int) (model.Booking, error) {
+	bookingDB.lock.Lock()
+	defer bookingDB.lock.Unlock()
+
+	key := booking.DepartureKey()
+	seats := bookingDB.seats[key]
+	if seats == nil {
+		seats = map[int]int32{}
+		bookingDB.seats[key] = seats
+	}
+
+	booking.Seat = 0
+	for seat := 1; seat <= capacity; seat++ {
+		if _, taken := seats[seat]; !taken {
+			booking.Seat = seat
+			break
+		}
+	}
+	if booking.Seat == 0 {
+		return model.Booking{}, ErrorDepartureFull
+	}
+
+	booking.Id = bookingDB.nextId
+	booking.Status = model.BookingConfirmed
+	booking.CreatedAt = time.Now().UTC()
+	bookingDB.nextId++
+
+	seats[booking.Seat] = booking.Id
+	bookingDB.bookings = append(bookingDB.bookings, booking)
+	return booking, nil
+}
+
+// UpdateBooking replaces the booking with the same id. Its departure and seat can not change.
+func (bookingDB *bookingDB) UpdateBooking(booking model.Booking) (model.Booking, error) {
+	bookingDB.lock.Lock()
+	defer bookingDB.lock.Unlock()
+
+	for i, current := range bookingDB.bookings {
+		if current.Id == booking.Id {
+			booking.TripId = current.TripId
+			booking.Departure = current.Departure
+			booking.Seat = current.Seat
+			bookingDB.bookings[i] = booking
+			return booking, nil
+		}
+	}
+
+	return model.Booking{}, ErrorBookingNotFound
+}
+
+// DeleteBooking removes a booking and frees its seat
+func (bookingDB *bookingDB) DeleteBooking(id int32) error {
+	bookingDB.lock.Lock()
+	defer bookingDB.lock.Unlock()
+
+	for i, booking := range bookingDB.bookings {
+		if booking.Id == id {
+			bookingDB.releaseSeat(booking)
+			bookingDB.bookings = append(bookingDB.bookings[:i], bookingDB.bookings[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrorBookingNotFound
+}
+
+// CancelBooking cancels a confirmed booking, frees its seat and records the
+// ledger entry returned by refund, all at once so the seat can not be sold
+// again before the refund is recorded
+func (bookingDB *bookingDB) CancelBooking(id int32, cancelledAt time.Time, refund func(model.Booking) model.LedgerEntry) (model.Booking, model.LedgerEntry, error) {
+	bookingDB.lock.Lock()
+	defer bookingDB.lock.Unlock()
+
+	for i, booking := range bookingDB.bookings {
+		if booking.Id != id {
+			continue
+		}
+		if booking.Status == model.BookingCancelled {
+			return model.Booking{}, model.LedgerEntry{}, ErrorBookingCancelled
+		}
+
+		entry := refund(booking)
+		entry.Id = bookingDB.nextLedgerId
+		entry.BookingId = booking.Id
+		entry.CustomerId = booking.CustomerId
+		entry.CreatedAt = cancelledAt
+		bookingDB.nextLedgerId++
+
+		booking.Status = model.BookingCancelled
+		booking.CancelledAt = &cancelledAt
+		bookingDB.releaseSeat(booking)
+		bookingDB.bookings[i] = booking
+		bookingDB.ledger = append(bookingDB.ledger, entry)
+		return booking, entry, nil
+	}
+
+	return model.Booking{}, model.LedgerEntry{}, ErrorBookingNotFound
+}
+
+// GetLedgerEntries returns the ledger entries of a customer, oldest first
+func (bookingDB *bookingDB) GetLedgerEntries(customerId int32) []model.LedgerEntry {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	result := []model.LedgerEntry{}
+	for _, entry := range bookingDB.ledger {
+		if entry.CustomerId == customerId {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+func (bookingDB *bookingDB) GetBookingById(id int32) (model.Booking, error) {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	for _, booking := range bookingDB.bookings {
+		if booking.Id == id {
+			return booking, nil
+		}
+	}
+
+	return model.Booking{}, ErrorBookingNotFound
+}
+
+func (bookingDB *bookingDB) GetBookingsByCustomer(customerId int32) []model.Booking {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	result := []model.Booking{}
+	for _, booking := range bookingDB.bookings {
+		if booking.CustomerId == customerId {
+			result = append(result, booking)
+		}
+	}
+
+	return result
+}
+
+func (bookingDB *bookingDB) GetBookingsByDeparture(key model.DepartureKey) []model.Booking {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	result := []model.Booking{}
+	for _, booking := range bookingDB.bookings {
+		if booking.DepartureKey() == key {
+			result = append(result, booking)
+		}
+	}
+
+	return result
+}
+
+// ExportBookings returns a copy of the bookings and the ledger, with the next
+// booking and ledger entry ids to be given
+func (bookingDB *bookingDB) ExportBookings() ([]model.Booking, []model.LedgerEntry, int32, int32, error) {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	return append([]model.Booking{}, bookingDB.bookings...), append([]model.LedgerEntry{}, bookingDB.ledger...), bookingDB.nextId, bookingDB.nextLedgerId, nil
+}
+
+// ImportBookings replaces the bookings, the ledger and their next ids. The
+// seats of confirmed bookings are taken again.
+func (bookingDB *bookingDB) ImportBookings(bookings []model.Booking, ledger []model.LedgerEntry, nextId int32, nextLedgerId int32) error {
+	bookingDB.lock.Lock()
+	defer bookingDB.lock.Unlock()
+
+	seats := map[model.DepartureKey]map[int]int32{}
+	for _, booking := range bookings {
+		if booking.Status != model.BookingConfirmed {
+			continue
+		}
+
+		key := booking.DepartureKey()
+		if seats[key] == nil {
+			seats[key] = map[int]int32{}
+		}
+		if other, taken := seats[key][booking.Seat]; taken {
+			return fmt.Errorf("bookings %v and %v hold the same seat", other, booking.Id)
+		}
+		seats[key][booking.Seat] = booking.Id
+	}
+
+	bookingDB.bookings = append([]model.Booking{}, bookings...)
+	bookingDB.ledger = append([]model.LedgerEntry{}, ledger...)
+	bookingDB.seats = seats
+	bookingDB.nextId = nextId
+	bookingDB.nextLedgerId = nextLedgerId
+	return nil
+}
+
+// SeatsTaken returns the number of seats booked on a departure
+func (bookingDB *bookingDB) SeatsTaken(key model.DepartureKey) int {
+	bookingDB.lock.RLock()
+	defer bookingDB.lock.RUnlock()
+
+	return len(bookingDB.seats[key])
+}
+
+func (bookingDB *bookingDB) releaseSeat(booking model.Booking) {
+	key := booking.DepartureKey()
+	if bookingDB.seats[key][booking.Seat] == booking.Id {
+		delete(bookingDB.seats[key], booking.Seat)
+	}
+}
+
+// Check verifies that the booking database has been initialized
+func (bookingDB *bookingDB) Check() error {
+	if bookingDB.bookings == nil || bookingDB.seats == nil || bookingDB.ledger == nil {
+		return errors.New("non-initialized booking database")
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var testDeparture = time.Date(2026, 3, 7, 8, 0, 0, 0, time.UTC)
+
+func TestAddBooking_1(t *testing.T) {
+	bookingDB := NewBookingDB()
+
+	for expectedSeat := 1; expectedSeat <= 2; expectedSeat++ {
+		booking, err := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if booking.Seat != expectedSeat || booking.Status != model.BookingConfirmed {
+			t.Fatalf("expected confirmed booking on seat %v, got %v", expectedSeat, booking)
+		}
+	}
+
+	_, err := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	if err != ErrorDepartureFull {
+		t.Fatalf("expected %v, got %v", ErrorDepartureFull, err)
+	}
+
+	_, err = bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture.AddDate(0, 0, 1)}, 2)
+	if err != nil {
+		t.Fatalf("expected another departure to have seats, got %v", err)
+	}
+}
+
+func TestAddBooking_2(t *testing.T) {
+	bookingDB := NewBookingDB()
+
+	var wait sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 50)
+		}()
+	}
+	wait.Wait()
+
+	bookings := bookingDB.GetBookingsByDeparture(model.NewDepartureKey(1, testDeparture))
+	if len(bookings) != 50 {
+		t.Fatalf("expected %v bookings, got %v", 50, len(bookings))
+	}
+
+	seats := map[int]bool{}
+	for _, booking := range bookings {
+		if seats[booking.Seat] {
+			t.Fatalf("expected every seat to be booked once, seat %v was booked twice", booking.Seat)
+		}
+		seats[booking.Seat] = true
+	}
+}
+
+func TestDeleteBooking_1(t *testing.T) {
+	bookingDB := NewBookingDB()
+	first, _ := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+
+	err := bookingDB.DeleteBooking(first.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := model.NewDepartureKey(1, testDeparture)
+	if taken := bookingDB.SeatsTaken(key); taken != 1 {
+		t.Fatalf("expected %v seats taken, got %v", 1, taken)
+	}
+
+	booking, err := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	if err != nil || booking.Seat != first.Seat {
+		t.Fatalf("expected freed seat %v to be booked again, got %v %v", first.Seat, booking, err)
+	}
+}
+
+func TestUpdateBooking_1(t *testing.T) {
+	bookingDB := NewBookingDB()
+	booking, _ := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+
+	booking.Seat = 2
+	booking.Ticket = "PG1:TICKET"
+	updated, err := bookingDB.UpdateBooking(booking)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Seat != 1 || updated.Ticket != "PG1:TICKET" {
+		t.Fatalf("expected ticket to be saved on seat 1, got %v", updated)
+	}
+}
+
+func TestCancelBooking_1(t *testing.T) {
+	bookingDB := NewBookingDB()
+	booking, _ := bookingDB.AddBooking(model.Booking{CustomerId: 7, TripId: 1, Departure: testDeparture, Price: 30}, 1)
+	cancelledAt := testDeparture.AddDate(0, 0, -1)
+
+	cancelled, entry, err := bookingDB.CancelBooking(booking.Id, cancelledAt, func(booking model.Booking) model.LedgerEntry {
+		return model.LedgerEntry{Type: model.LedgerRefund, Amount: booking.Price / 2}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cancelled.Status != model.BookingCancelled || cancelled.CancelledAt == nil || !cancelled.CancelledAt.Equal(cancelledAt) {
+		t.Fatalf("expected booking cancelled at %v, got %v", cancelledAt, cancelled)
+	}
+	if entry.Id != 1 || entry.BookingId != booking.Id || entry.CustomerId != 7 || entry.Amount != 15 {
+		t.Fatalf("expected refund of %v for booking %v, got %v", 15, booking.Id, entry)
+	}
+
+	ledger := bookingDB.GetLedgerEntries(7)
+	if len(ledger) != 1 || ledger[0] != entry {
+		t.Fatalf("expected ledger %v, got %v", []model.LedgerEntry{entry}, ledger)
+	}
+
+	rebooked, err := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 1)
+	if err != nil || rebooked.Seat != booking.Seat {
+		t.Fatalf("expected freed seat %v to be booked again, got %v %v", booking.Seat, rebooked, err)
+	}
+}
+
+func TestCancelBooking_2(t *testing.T) {
+	bookingDB := NewBookingDB()
+	booking, _ := bookingDB.AddBooking(model.Booking{CustomerId: 7, TripId: 1, Departure: testDeparture}, 1)
+	refund := func(booking model.Booking) model.LedgerEntry {
+		return model.LedgerEntry{Type: model.LedgerRefund}
+	}
+
+	bookingDB.CancelBooking(booking.Id, testDeparture, refund)
+	bookingDB.AddBooking(model.Booking{CustomerId: 8, TripId: 1, Departure: testDeparture}, 1)
+
+	_, _, err := bookingDB.CancelBooking(booking.Id, testDeparture, refund)
+	if err != ErrorBookingCancelled {
+		t.Fatalf("expected %v, got %v", ErrorBookingCancelled, err)
+	}
+	if taken := bookingDB.SeatsTaken(booking.DepartureKey()); taken != 1 {
+		t.Fatalf("expected the seat of the new booking to stay taken, got %v taken", taken)
+	}
+	if ledger := bookingDB.GetLedgerEntries(7); len(ledger) != 1 {
+		t.Fatalf("expected %v ledger entry, got %v", 1, ledger)
+	}
+
+	_, _, err = bookingDB.CancelBooking(99, testDeparture, refund)
+	if err != ErrorBookingNotFound {
+		t.Fatalf("expected %v, got %v", ErrorBookingNotFound, err)
+	}
+}
+
+func TestImportBookings_1(t *testing.T) {
+	source := NewBookingDB()
+	source.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	source.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	source.CancelBooking(1, testDeparture, func(booking model.Booking) model.LedgerEntry {
+		return model.LedgerEntry{Type: model.LedgerRefund, Amount: 10}
+	})
+
+	bookings, ledger, nextId, nextLedgerId, _ := source.ExportBookings()
+	if len(bookings) != 2 || len(ledger) != 1 || nextId != 3 || nextLedgerId != 2 {
+		t.Fatalf("expected 2 bookings, 1 ledger entry and next ids 3 and 2, got %v %v %v %v", bookings, ledger, nextId, nextLedgerId)
+	}
+
+	bookingDB := NewBookingDB()
+	if err := bookingDB.ImportBookings(bookings, ledger, nextId, nextLedgerId); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Seat 1 was released by the cancellation, seat 2 is still taken
+	booking, err := bookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	if err != nil || booking.Id != 3 || booking.Seat != 1 {
+		t.Fatalf("expected booking 3 on seat 1, got %v %v", booking, err)
+	}
+
+	bookings[0].Status = model.BookingConfirmed
+	bookings[0].Seat = 2
+	if err := bookingDB.ImportBookings(bookings, ledger, nextId, nextLedgerId); err == nil {
+		t.Fatalf("expected bookings holding the same seat to be refused")
+	}
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+	_ "github.com/gbandres98/pack-and-go/sqlmem"
+)
+
+// Every registered driver has to pass the conformance tests, so a new driver
+// needs a DSN here that opens an empty store of its own for each test
+var testTripStores = map[string]func(t *testing.T) string{
+	"memory": func(t *testing.T) string {
+		return "memory://"
+	},
+	"journal": func(t *testing.T) string {
+		return "journal://" + filepath.Join(t.TempDir(), "trips.log")
+	},
+	"kv": func(t *testing.T) string {
+		return "kv://" + t.TempDir()
+	},
+	"sql": seededTestSQLDSN,
+}
+
+var testCityStores = map[string]func(t *testing.T) string{
+	"file": func(t *testing.T) string {
+		filePath := filepath.Join(t.TempDir(), "cities.txt")
+		if err := os.WriteFile(filePath, nil, 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return "file://" + filePath
+	},
+	"kv": func(t *testing.T) string {
+		return "kv://" + t.TempDir()
+	},
+	"sql": migratedTestSQLDSN,
+}
+
+// migratedTestSQLDSN returns the DSN of an in-memory SQL database of the test,
+// with every migration applied
+func migratedTestSQLDSN(t *testing.T) string {
+	dsn := "sql+sqlmem:" + t.Name()
+
+	sqlDB, err := OpenSQLDB(dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqlDB.Close()
+
+	if _, err := sqlDB.MigrateUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return dsn
+}
+
+// seededTestSQLDSN returns the DSN of an in-memory SQL database of the test
+// with the default trips of the other drivers. SQL stores start without trips,
+// as their migrations create no cities for them to run between.
+func seededTestSQLDSN(t *testing.T) string {
+	dsn := migratedTestSQLDSN(t)
+
+	tripStore, err := OpenTripStore(dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tripStore.(io.Closer).Close()
+
+	defaultTrips := []model.Trip{}
+	for _, trip := range trips {
+		trip.Version = 1
+		trip.UpdatedAt = time.Now().UTC()
+		defaultTrips = append(defaultTrips, trip)
+	}
+	if err := tripStore.ImportTrips(defaultTrips, int32(len(trips)+1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return dsn
+}
+
+func openTestTripStore(t *testing.T, scheme string) TripStore {
+	testDSN, ok := testTripStores[scheme]
+	if !ok {
+		t.Fatalf("expected a test DSN for the trip driver %v", scheme)
+	}
+
+	tripStore, err := OpenTripStore(testDSN(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if closer, ok := tripStore.(io.Closer); ok {
+		t.Cleanup(func() { closer.Close() })
+	}
+
+	return tripStore
+}
+
+func TestTripStoreConformance(t *testing.T) {
+	for _, scheme := range TripDrivers() {
+		scheme := scheme
+		t.Run(scheme, func(t *testing.T) {
+			tripStore := openTestTripStore(t, scheme)
+
+			if err := tripStore.Check(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			trips, err := tripStore.GetAllTrips()
+			if err != nil || len(trips) != 3 {
+				t.Fatalf("expected the 3 initial trips, got %v %v", trips, err)
+			}
+			for i, trip := range trips {
+				if trip.Id != int32(i+1) || trip.Version != 1 {
+					t.Fatalf("expected trip %v at version 1, got %v", i+1, trip)
+				}
+			}
+
+			trips[0].Price = 1000
+			if trip, _ := tripStore.GetTripById(1); trip.Price == 1000 {
+				t.Fatalf("expected GetAllTrips() to return a copy of the trips")
+			}
+
+			trip := newTripWithId
+			trip.Id = 100
+			trip, err = tripStore.AddTrip(trip)
+			if err != nil || trip.Id != 4 || trip.Version != 1 || trip.Price != newTripWithId.Price {
+				t.Fatalf("expected trip 4 at version 1, got %v %v", trip, err)
+			}
+			if saved, err := tripStore.GetTripById(4); err != nil || saved.Id != 4 || saved.Price != trip.Price {
+				t.Fatalf("expected trip 4 to be saved, got %v %v", saved, err)
+			}
+			if _, err := tripStore.GetTripById(100); err != ErrorTripNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+			}
+
+			trip.Price = 20
+			if _, err := tripStore.UpdateTrip(trip, 2); err != ErrorVersionMismatch {
+				t.Fatalf("expected error: %v, got error: %v", ErrorVersionMismatch, err)
+			}
+			if _, err := tripStore.UpdateTrip(model.Trip{Id: 100}, 0); err != ErrorTripNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+			}
+			trip, err = tripStore.UpdateTrip(trip, 1)
+			if err != nil || trip.Version != 2 || trip.Price != 20 {
+				t.Fatalf("expected trip 4 at version 2, got %v %v", trip, err)
+			}
+			trip, err = tripStore.UpdateTrip(trip, 0)
+			if err != nil || trip.Version != 3 {
+				t.Fatalf("expected an update without expected version to succeed, got %v %v", trip, err)
+			}
+
+			if _, err := tripStore.DeleteTrip(4, 1); err != ErrorVersionMismatch {
+				t.Fatalf("expected error: %v, got error: %v", ErrorVersionMismatch, err)
+			}
+			if _, err := tripStore.DeleteTrip(100, 0); err != ErrorTripNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+			}
+			deleted, err := tripStore.DeleteTrip(4, 3)
+			if err != nil || deleted.Id != 4 {
+				t.Fatalf("expected trip 4 to be deleted, got %v %v", deleted, err)
+			}
+			if _, err := tripStore.GetTripById(4); err != ErrorTripNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+			}
+			if trip, _ := tripStore.AddTrip(newTrip); trip.Id != 5 {
+				t.Fatalf("expected ids of deleted trips not to be reused, got %v", trip.Id)
+			}
+		})
+	}
+}
+
+func TestCityStoreConformance(t *testing.T) {
+	for _, scheme := range CityDrivers() {
+		scheme := scheme
+		t.Run(scheme, func(t *testing.T) {
+			testDSN, ok := testCityStores[scheme]
+			if !ok {
+				t.Fatalf("expected a test DSN for the city driver %v", scheme)
+			}
+
+			cityStore, err := OpenCityStore(testDSN(t))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if closer, ok := cityStore.(io.Closer); ok {
+				defer closer.Close()
+			}
+
+			if cities, err := cityStore.GetAllCities(); err != nil || len(cities) != 0 {
+				t.Fatalf("expected no cities, got %v %v", cities, err)
+			}
+
+			for i, name := range []string{"Madrid", "Sevilla", "Valencia"} {
+				city, err := cityStore.AddCity(model.City{Name: name})
+				if err != nil || city.Id != int32(i+1) || city.Name != name {
+					t.Fatalf("expected city %v to get id %v, got %v %v", name, i+1, city, err)
+				}
+			}
+
+			if err := cityStore.Check(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			city, err := cityStore.GetCityById(2)
+			if err != nil || city.Name != "Sevilla" {
+				t.Fatalf("expected Sevilla, got %v %v", city, err)
+			}
+			if _, err := cityStore.GetCityById(4); err != ErrorCityNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorCityNotFound, err)
+			}
+			if cities, err := cityStore.GetAllCities(); err != nil || len(cities) != 3 || cities[2].Name != "Valencia" {
+				t.Fatalf("expected the 3 added cities, got %v %v", cities, err)
+			}
+
+			renamed := model.City{Id: 2, Name: "Seville", Latitude: 37.39, Longitude: -5.98, Country: "ES"}
+			if city, err := cityStore.UpdateCity(renamed); err != nil || city != renamed {
+				t.Fatalf("expected %v, got %v %v", renamed, city, err)
+			}
+			if city, err := cityStore.GetCityById(2); err != nil || city != renamed {
+				t.Fatalf("expected %v, got %v %v", renamed, city, err)
+			}
+			if _, err := cityStore.UpdateCity(model.City{Id: 4, Name: "Bilbao"}); err != ErrorCityNotFound {
+				t.Fatalf("expected error: %v, got error: %v", ErrorCityNotFound, err)
+			}
+			if cities, err := cityStore.GetAllCities(); err != nil || len(cities) != 3 || cities[0].Name != "Madrid" || cities[2].Name != "Valencia" {
+				t.Fatalf("expected the other cities to be kept, got %v %v", cities, err)
+			}
+		})
+	}
+}
+
+func TestTripStoreImportConformance(t *testing.T) {
+	for _, scheme := range TripDrivers() {
+		scheme := scheme
+		t.Run(scheme, func(t *testing.T) {
+			tripStore := openTestTripStore(t, scheme)
+
+			trips, nextId, err := tripStore.ExportTrips()
+			if err != nil || len(trips) != 3 || trips[2].Id != 3 || nextId != 4 {
+				t.Fatalf("expected the 3 initial trips and next id 4, got %v %v %v", trips, nextId, err)
+			}
+
+			imported := []model.Trip{
+				{Id: 2, OriginId: 1, DestinationId: 3, Dates: "Mon", Price: 15, Version: 4},
+				{Id: 7, OriginId: 3, DestinationId: 1, Dates: "Sun", Price: 25, Version: 1},
+			}
+			if err := tripStore.ImportTrips(imported, 10); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			trips, _ = tripStore.GetAllTrips()
+			if len(trips) != 2 || trips[0].Id != 2 || trips[0].Version != 4 || trips[0].Price != 15 || trips[1].Id != 7 {
+				t.Fatalf("expected the imported trips 2 and 7, got %v", trips)
+			}
+			if trip, _ := tripStore.AddTrip(newTrip); trip.Id != 10 {
+				t.Fatalf("expected the imported next id 10, got %v", trip.Id)
+			}
+			if _, nextId, _ := tripStore.ExportTrips(); nextId != 11 {
+				t.Fatalf("expected next id 11, got %v", nextId)
+			}
+		})
+	}
+}
+
+func TestCityStoreImportConformance(t *testing.T) {
+	for _, scheme := range CityDrivers() {
+		scheme := scheme
+		t.Run(scheme, func(t *testing.T) {
+			cityStore, err := OpenCityStore(testCityStores[scheme](t))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if closer, ok := cityStore.(io.Closer); ok {
+				defer closer.Close()
+			}
+
+			cityStore.AddCity(model.City{Name: "Madrid"})
+			imported := []model.City{{Id: 1, Name: "Sevilla", Latitude: 37.39, Longitude: -5.98}, {Id: 2, Name: "Valencia"}}
+			if err := cityStore.ImportCities(imported); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cities, err := cityStore.GetAllCities(); err != nil || !reflect.DeepEqual(cities, imported) {
+				t.Fatalf("expected %v, got %v %v", imported, cities, err)
+			}
+			if city, err := cityStore.AddCity(model.City{Name: "Madrid"}); err != nil || city.Id != 3 {
+				t.Fatalf("expected Madrid to get id 3, got %v %v", city, err)
+			}
+		})
+	}
+}
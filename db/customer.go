@@ -0,0 +1,217 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type customerDB struct {
+	customers       []model.Customer
+	sessions        map[string]model.Session
+	passengers      []model.Passenger
+	nextCustomerId  int32
+	nextPassengerId int32
+	lock            sync.RWMutex
+	now             func() time.Time
+}
+
+func NewCustomerDB() *customerDB {
+	return &customerDB{
+		customers:       []model.Customer{},
+		sessions:        map[string]model.Session{},
+		passengers:      []model.Passenger{},
+		nextCustomerId:  1,
+		nextPassengerId: 1,
+		now:             time.Now,
+	}
+}
+
+// AddCustomer saves a new customer, failing with ErrorCustomerExists if the email is taken
+func (customerDB *customerDB) AddCustomer(customer model.Customer) (model.Customer, error) {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	for _, current := range customerDB.customers {
+		if strings.EqualFold(current.Email, customer.Email) {
+			return model.Customer{}, ErrorCustomerExists
+		}
+	}
+
+	customer.Id = customerDB.nextCustomerId
+	customer.CreatedAt = customerDB.now().UTC()
+	customerDB.nextCustomerId++
+
+	customerDB.customers = append(customerDB.customers, customer)
+	return customer, nil
+}
+
+func (customerDB *customerDB) GetCustomerById(id int32) (model.Customer, error) {
+	customerDB.lock.RLock()
+	defer customerDB.lock.RUnlock()
+
+	for _, customer := range customerDB.customers {
+		if customer.Id == id {
+			return customer, nil
+		}
+	}
+
+	return model.Customer{}, ErrorCustomerNotFound
+}
+
+func (customerDB *customerDB) GetCustomerByEmail(email string) (model.Customer, error) {
+	customerDB.lock.RLock()
+	defer customerDB.lock.RUnlock()
+
+	for _, customer := range customerDB.customers {
+		if strings.EqualFold(customer.Email, email) {
+			return customer, nil
+		}
+	}
+
+	return model.Customer{}, ErrorCustomerNotFound
+}
+
+func (customerDB *customerDB) AddSession(session model.Session) error {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	customerDB.sessions[session.TokenHash] = session
+	return nil
+}
+
+// GetSession returns the session with the given token hash, failing with
+// ErrorSessionNotFound if it does not exist or has expired
+func (customerDB *customerDB) GetSession(tokenHash string) (model.Session, error) {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	session, ok := customerDB.sessions[tokenHash]
+	if !ok {
+		return model.Session{}, ErrorSessionNotFound
+	}
+
+	if !customerDB.now().Before(session.ExpiresAt) {
+		delete(customerDB.sessions, tokenHash)
+		return model.Session{}, ErrorSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (customerDB *customerDB) DeleteSession(tokenHash string) error {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	if _, ok := customerDB.sessions[tokenHash]; !ok {
+		return ErrorSessionNotFound
+	}
+
+	delete(customerDB.sessions, tokenHash)
+	return nil
+}
+
+// GetPassengers returns the passengers saved by a customer
+func (customerDB *customerDB) GetPassengers(customerId int32) []model.Passenger {
+	customerDB.lock.RLock()
+	defer customerDB.lock.RUnlock()
+
+	result := []model.Passenger{}
+	for _, passenger := range customerDB.passengers {
+		if passenger.CustomerId == customerId {
+			result = append(result, passenger)
+		}
+	}
+
+	return result
+}
+
+func (customerDB *customerDB) GetPassengerById(id int32) (model.Passenger, error) {
+	customerDB.lock.RLock()
+	defer customerDB.lock.RUnlock()
+
+	for _, passenger := range customerDB.passengers {
+		if passenger.Id == id {
+			return passenger, nil
+		}
+	}
+
+	return model.Passenger{}, ErrorPassengerNotFound
+}
+
+func (customerDB *customerDB) AddPassenger(passenger model.Passenger) model.Passenger {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	passenger.Id = customerDB.nextPassengerId
+	customerDB.nextPassengerId++
+
+	customerDB.passengers = append(customerDB.passengers, passenger)
+	return passenger
+}
+
+// UpdatePassenger replaces the passenger with the same id, which can not change owner
+func (customerDB *customerDB) UpdatePassenger(passenger model.Passenger) (model.Passenger, error) {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	for i, current := range customerDB.passengers {
+		if current.Id == passenger.Id {
+			passenger.CustomerId = current.CustomerId
+			customerDB.passengers[i] = passenger
+			return passenger, nil
+		}
+	}
+
+	return model.Passenger{}, ErrorPassengerNotFound
+}
+
+func (customerDB *customerDB) DeletePassenger(id int32) error {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	for i, passenger := range customerDB.passengers {
+		if passenger.Id == id {
+			customerDB.passengers = append(customerDB.passengers[:i], customerDB.passengers[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrorPassengerNotFound
+}
+
+// ExportCustomers returns a copy of the customers, with their password
+// hashes, and the passengers, with the next customer and passenger ids to be
+// given. Sessions are not exported.
+func (customerDB *customerDB) ExportCustomers() ([]model.Customer, []model.Passenger, int32, int32, error) {
+	customerDB.lock.RLock()
+	defer customerDB.lock.RUnlock()
+
+	return append([]model.Customer{}, customerDB.customers...), append([]model.Passenger{}, customerDB.passengers...), customerDB.nextCustomerId, customerDB.nextPassengerId, nil
+}
+
+// ImportCustomers replaces the customers, the passengers and their next ids.
+// Every session is ended, as its customer may no longer be the same.
+func (customerDB *customerDB) ImportCustomers(customers []model.Customer, passengers []model.Passenger, nextCustomerId int32, nextPassengerId int32) error {
+	customerDB.lock.Lock()
+	defer customerDB.lock.Unlock()
+
+	customerDB.customers = append([]model.Customer{}, customers...)
+	customerDB.passengers = append([]model.Passenger{}, passengers...)
+	customerDB.sessions = map[string]model.Session{}
+	customerDB.nextCustomerId = nextCustomerId
+	customerDB.nextPassengerId = nextPassengerId
+	return nil
+}
+
+// Check verifies that the customer database has been initialized
+func (customerDB *customerDB) Check() error {
+	if customerDB.customers == nil || customerDB.sessions == nil {
+		return errors.New("non-initialized customer database")
+	}
+
+	return nil
+}
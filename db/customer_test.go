@@ -0,0 +1,49 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestGetSession_1(t *testing.T) {
+	customerDB := NewCustomerDB()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	customerDB.now = func() time.Time { return now }
+
+	customerDB.AddSession(model.Session{TokenHash: "hash", CustomerId: 1, ExpiresAt: now.Add(time.Hour)})
+
+	session, err := customerDB.GetSession("hash")
+	if err != nil || session.CustomerId != 1 {
+		t.Fatalf("expected session of customer 1, got %v %v", session, err)
+	}
+
+	now = now.Add(time.Hour)
+	_, err = customerDB.GetSession("hash")
+	if err != ErrorSessionNotFound {
+		t.Fatalf("expected %v, got %v", ErrorSessionNotFound, err)
+	}
+	if len(customerDB.sessions) != 0 {
+		t.Fatalf("expected expired session to be removed, got %v", customerDB.sessions)
+	}
+}
+
+func TestUpdatePassenger_1(t *testing.T) {
+	customerDB := NewCustomerDB()
+	passenger := customerDB.AddPassenger(model.Passenger{CustomerId: 1, FirstName: "Alice", LastName: "Smith"})
+
+	passenger.CustomerId = 2
+	passenger.LastName = "Jones"
+	updated, err := customerDB.UpdatePassenger(passenger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.CustomerId != 1 || updated.LastName != "Jones" {
+		t.Fatalf("expected passenger of customer 1 to be renamed, got %v", updated)
+	}
+
+	if passengers := customerDB.GetPassengers(2); len(passengers) != 0 {
+		t.Fatalf("expected no passengers for customer 2, got %v", passengers)
+	}
+}
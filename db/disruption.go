@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type disruptionDB struct {
+	disruptions map[model.DepartureKey]model.Disruption
+	lock        sync.RWMutex
+	now         func() time.Time
+}
+
+func NewDisruptionDB() *disruptionDB {
+	return &disruptionDB{
+		disruptions: map[model.DepartureKey]model.Disruption{},
+		now:         time.Now,
+	}
+}
+
+// SetDisruption saves the disruption of a departure, replacing the previous one if any
+func (disruptionDB *disruptionDB) SetDisruption(disruption model.Disruption) model.Disruption {
+	disruptionDB.lock.Lock()
+	defer disruptionDB.lock.Unlock()
+
+	disruptionDB.purgeExpired()
+	disruptionDB.disruptions[disruption.DepartureKey()] = disruption
+	return disruption
+}
+
+// GetDisruption returns the disruption of a departure, failing with
+// ErrorDisruptionNotFound if it has none or it has expired
+func (disruptionDB *disruptionDB) GetDisruption(key model.DepartureKey) (model.Disruption, error) {
+	disruptionDB.lock.RLock()
+	defer disruptionDB.lock.RUnlock()
+
+	disruption, ok := disruptionDB.disruptions[key]
+	if !ok || disruptionDB.expired(disruption) {
+		return model.Disruption{}, ErrorDisruptionNotFound
+	}
+
+	return disruption, nil
+}
+
+// GetDisruptions returns every disruption that has not expired, by date and trip
+func (disruptionDB *disruptionDB) GetDisruptions() []model.Disruption {
+	return disruptionDB.find(func(model.Disruption) bool { return true })
+}
+
+// GetDisruptionsByTrip returns the disruptions of a trip that have not expired, by date
+func (disruptionDB *disruptionDB) GetDisruptionsByTrip(tripId int32) []model.Disruption {
+	return disruptionDB.find(func(disruption model.Disruption) bool { return disruption.TripId == tripId })
+}
+
+func (disruptionDB *disruptionDB) DeleteDisruption(key model.DepartureKey) error {
+	disruptionDB.lock.Lock()
+	defer disruptionDB.lock.Unlock()
+
+	disruptionDB.purgeExpired()
+	if _, ok := disruptionDB.disruptions[key]; !ok {
+		return ErrorDisruptionNotFound
+	}
+
+	delete(disruptionDB.disruptions, key)
+	return nil
+}
+
+func (disruptionDB *disruptionDB) find(matches func(model.Disruption) bool) []model.Disruption {
+	disruptionDB.lock.RLock()
+	defer disruptionDB.lock.RUnlock()
+
+	result := []model.Disruption{}
+	for _, disruption := range disruptionDB.disruptions {
+		if matches(disruption) && !disruptionDB.expired(disruption) {
+			result = append(result, disruption)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].TripId < result[j].TripId
+	})
+
+	return result
+}
+
+func (disruptionDB *disruptionDB) expired(disruption model.Disruption) bool {
+	return !disruptionDB.now().Before(disruption.ExpiresAt)
+}
+
+// purgeExpired deletes expired disruptions, which reads already skip. The write lock must be held.
+func (disruptionDB *disruptionDB) purgeExpired() {
+	for key, disruption := range disruptionDB.disruptions {
+		if disruptionDB.expired(disruption) {
+			delete(disruptionDB.disruptions, key)
+		}
+	}
+}
+
+// ExportDisruptions returns every disruption that has not expired, by date and trip
+func (disruptionDB *disruptionDB) ExportDisruptions() ([]model.Disruption, error) {
+	return disruptionDB.GetDisruptions(), nil
+}
+
+// ImportDisruptions replaces the disruptions, failing if a departure has two
+func (disruptionDB *disruptionDB) ImportDisruptions(disruptions []model.Disruption) error {
+	imported := map[model.DepartureKey]model.Disruption{}
+	for _, disruption := range disruptions {
+		if _, ok := imported[disruption.DepartureKey()]; ok {
+			return fmt.Errorf("trip %v has two disruptions on %v", disruption.TripId, disruption.Date)
+		}
+		imported[disruption.DepartureKey()] = disruption
+	}
+
+	disruptionDB.lock.Lock()
+	defer disruptionDB.lock.Unlock()
+
+	disruptionDB.disruptions = imported
+	return nil
+}
+
+// Check verifies that the disruption database has been initialized
+func (disruptionDB *disruptionDB) Check() error {
+	if disruptionDB.disruptions == nil {
+		return errors.New("non-initialized disruption database")
+	}
+
+	return nil
+}
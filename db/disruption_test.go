@@ -0,0 +1,57 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestSetDisruption_1(t *testing.T) {
+	disruptionDB := NewDisruptionDB()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	disruptionDB.now = func() time.Time { return now }
+
+	disruptionDB.SetDisruption(model.Disruption{TripId: 2, Date: "2026-03-07", Status: model.DisruptionDelayed, DelayMinutes: 30, ExpiresAt: now.AddDate(0, 0, 6)})
+	disruptionDB.SetDisruption(model.Disruption{TripId: 1, Date: "2026-03-03", Status: model.DisruptionDelayed, ExpiresAt: now.AddDate(0, 0, 2)})
+	disruptionDB.SetDisruption(model.Disruption{TripId: 2, Date: "2026-03-07", Status: model.DisruptionCancelled, Reason: "Strike", ExpiresAt: now.AddDate(0, 0, 6)})
+
+	disruption, err := disruptionDB.GetDisruption(model.DepartureKey{TripId: 2, Date: "2026-03-07"})
+	if err != nil || disruption.Status != model.DisruptionCancelled {
+		t.Fatalf("expected the departure to be cancelled, got %v %v", disruption, err)
+	}
+
+	disruptions := disruptionDB.GetDisruptions()
+	if len(disruptions) != 2 || disruptions[0].Date != "2026-03-03" {
+		t.Fatalf("expected 2 disruptions by date, got %v", disruptions)
+	}
+	if disruptions := disruptionDB.GetDisruptionsByTrip(2); len(disruptions) != 1 {
+		t.Fatalf("expected 1 disruption of trip 2, got %v", disruptions)
+	}
+}
+
+func TestSetDisruption_2(t *testing.T) {
+	disruptionDB := NewDisruptionDB()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	disruptionDB.now = func() time.Time { return now }
+	key := model.DepartureKey{TripId: 1, Date: "2026-03-03"}
+
+	disruptionDB.SetDisruption(model.Disruption{TripId: 1, Date: "2026-03-03", Status: model.DisruptionCancelled, ExpiresAt: now.Add(14 * time.Hour)})
+
+	now = now.Add(14 * time.Hour)
+	_, err := disruptionDB.GetDisruption(key)
+	if err != ErrorDisruptionNotFound {
+		t.Fatalf("expected %v, got %v", ErrorDisruptionNotFound, err)
+	}
+	if disruptions := disruptionDB.GetDisruptionsByTrip(1); len(disruptions) != 0 {
+		t.Fatalf("expected expired disruption to be hidden, got %v", disruptions)
+	}
+
+	err = disruptionDB.DeleteDisruption(key)
+	if err != ErrorDisruptionNotFound {
+		t.Fatalf("expected %v, got %v", ErrorDisruptionNotFound, err)
+	}
+	if len(disruptionDB.disruptions) != 0 {
+		t.Fatalf("expected expired disruption to be removed, got %v", disruptionDB.disruptions)
+	}
+}
@@ -3,4 +3,21 @@ package db
 import "errors"
 
 var ErrorTripNotFound = errors.New("trip not found")
-var ErrorCityNotFound = errors.New("city not found")
\ No newline at end of file
+var ErrorCityNotFound = errors.New("city not found")
+var ErrorVersionMismatch = errors.New("trip version mismatch")
+var ErrorPromoCodeNotFound = errors.New("promo code not found")
+var ErrorPromoCodeExists = errors.New("promo code already exists")
+var ErrorPromoCodeExhausted = errors.New("promo code usage limit reached")
+var ErrorPromoCodeCustomerLimit = errors.New("promo code usage limit reached for customer")
+var ErrorCustomerNotFound = errors.New("customer not found")
+var ErrorCustomerExists = errors.New("customer already exists")
+var ErrorSessionNotFound = errors.New("session not found")
+var ErrorPassengerNotFound = errors.New("passenger not found")
+var ErrorBookingNotFound = errors.New("booking not found")
+var ErrorDepartureFull = errors.New("no seats left on departure")
+var ErrorBookingCancelled = errors.New("booking already cancelled")
+var ErrorDisruptionNotFound = errors.New("disruption not found")
+var ErrorWebhookNotFound = errors.New("webhook not found")
+var ErrorDeliveryNotFound = errors.New("webhook delivery not found")
+var ErrorSchemaOutdated = errors.New("database schema is outdated")
+var ErrorSchemaTooNew = errors.New("database schema is newer than this binary")
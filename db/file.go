@@ -1,15 +1,39 @@
 package db
 
 import (
-	"bufio"
-	"log"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gbandres98/pack-and-go/model"
 )
 
+// Every line of the cities file is a city, as comma separated values:
+//
+//	name[,latitude,longitude[,country[,timezone[,address]]]]
+//
+// Only the name is required, so a file with just a city name per line is valid.
 type fileDB struct {
 	filePath string
+	writeLock sync.Mutex
+}
+
+// file:///var/lib/packandgo keeps cities in the file cities.txt of a
+// directory, or in the file of the path if it is not a directory
+func init() {
+	RegisterCityDriver("file", func(dsn *url.URL) (CityStore, error) {
+		filePath := dsnPath(dsn, "cities.txt")
+		if filePath == "" {
+			return nil, fmt.Errorf("file city store needs a path, such as file:///var/lib/packandgo")
+		}
+		return NewFileDB(filePath), nil
+	})
 }
 
 func NewFileDB(filePath string) *fileDB {
@@ -19,20 +43,11 @@ func NewFileDB(filePath string) *fileDB {
 func (fileDB *fileDB) GetAllCities() ([]model.City, error) {
 	file, err := os.Open(fileDB.filePath)
 	if err != nil {
-		log.Panicf("could not open cities db file: %v", err)
+		return nil, fmt.Errorf("could not open cities db file: %w", err)
 	}
 	defer file.Close()
 
-	result := []model.City{}
-	nextId := int32(1)
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		result = append(result, model.City{Id: nextId, Name: scanner.Text()})
-		nextId++
-	}
-
-	return result, scanner.Err()
+	return parseCities(file)
 }
 
 func (fileDB *fileDB) GetCityById(id int32) (model.City, error) {
@@ -48,4 +63,217 @@ func (fileDB *fileDB) GetCityById(id int32) (model.City, error) {
 	}
 
 	return model.City{}, ErrorCityNotFound
-}
\ No newline at end of file
+}
+
+// AddCity appends a city to the end of the file, so it gets the next line number as id
+func (fileDB *fileDB) AddCity(city model.City) (model.City, error) {
+	fileDB.writeLock.Lock()
+	defer fileDB.writeLock.Unlock()
+
+	cities, err := fileDB.GetAllCities()
+	if err != nil {
+		return model.City{}, err
+	}
+
+	file, err := os.OpenFile(fileDB.filePath, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return model.City{}, fmt.Errorf("could not open cities db file: %w", err)
+	}
+	defer file.Close()
+
+	line := formatCity(city)
+	if !endsWithNewline(file) && len(cities) > 0 {
+		line = "\n" + line
+	}
+
+	_, err = file.WriteString(line)
+	if err != nil {
+		return model.City{}, fmt.Errorf("could not write cities db file: %w", err)
+	}
+
+	city.Id = int32(len(cities) + 1)
+	return city, nil
+}
+
+// UpdateCity rewrites the line of the city, so it keeps its id
+func (fileDB *fileDB) UpdateCity(city model.City) (model.City, error) {
+	fileDB.writeLock.Lock()
+	defer fileDB.writeLock.Unlock()
+
+	cities, err := fileDB.GetAllCities()
+	if err != nil {
+		return model.City{}, err
+	}
+
+	if city.Id < 1 || int(city.Id) > len(cities) {
+		return model.City{}, ErrorCityNotFound
+	}
+	cities[city.Id-1] = city
+
+	err = fileDB.writeCities(cities)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	return city, nil
+}
+
+// ImportCities replaces the cities file
+func (fileDB *fileDB) ImportCities(cities []model.City) error {
+	fileDB.writeLock.Lock()
+	defer fileDB.writeLock.Unlock()
+
+	return fileDB.writeCities(cities)
+}
+
+// writeCities writes a new cities file first and renames it over the old one,
+// so readers see either all or none of it. The write lock must be held.
+func (fileDB *fileDB) writeCities(cities []model.City) error {
+	var content strings.Builder
+	for _, city := range cities {
+		content.WriteString(formatCity(city))
+	}
+
+	tmpPath := fileDB.filePath + ".tmp"
+	err := os.WriteFile(tmpPath, []byte(content.String()), 0600)
+	if err != nil {
+		return fmt.Errorf("could not write cities db file: %w", err)
+	}
+
+	return os.Rename(tmpPath, fileDB.filePath)
+}
+
+func endsWithNewline(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+
+	lastByte := make([]byte, 1)
+	_, err = file.ReadAt(lastByte, info.Size()-1)
+	if err != nil && err != io.EOF {
+		return false
+	}
+
+	return lastByte[0] == '\n'
+}
+
+// Check verifies that the cities file can be opened and parsed, and that every line holds a city
+func (fileDB *fileDB) Check() error {
+	file, err := os.Open(fileDB.filePath)
+	if err != nil {
+		return fmt.Errorf("could not open cities db file: %w", err)
+	}
+	defer file.Close()
+
+	cities, err := parseCities(file)
+	if err != nil {
+		return err
+	}
+
+	if len(cities) == 0 {
+		return fmt.Errorf("cities db file is empty")
+	}
+
+	for _, city := range cities {
+		if strings.TrimSpace(city.Name) == "" {
+			return fmt.Errorf("empty city name at line %v of cities db file", city.Id)
+		}
+	}
+
+	return nil
+}
+
+func parseCities(reader io.Reader) ([]model.City, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cities db file: %w", err)
+	}
+
+	result := []model.City{}
+	if len(content) == 0 {
+		return result, nil
+	}
+
+	// Lines are parsed one by one, as the csv reader would skip blank lines and shift ids
+	lines := strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+	for i, line := range lines {
+		city, err := parseCity(strings.TrimRight(line, "\r"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid city at line %v of cities db file: %w", i+1, err)
+		}
+
+		city.Id = int32(i + 1)
+		result = append(result, city)
+	}
+
+	return result, nil
+}
+
+func parseCity(line string) (model.City, error) {
+	if strings.TrimSpace(line) == "" {
+		return model.City{}, nil
+	}
+
+	csvReader := csv.NewReader(strings.NewReader(line))
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	fields, err := csvReader.Read()
+	if err != nil {
+		return model.City{}, err
+	}
+
+	field := func(i int) string {
+		if i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	city := model.City{
+		Name:     fields[0],
+		Country:  field(3),
+		Timezone: field(4),
+		Address:  field(5),
+	}
+
+	if field(1) != "" || field(2) != "" {
+		city.Latitude, err = strconv.ParseFloat(field(1), 64)
+		if err != nil || city.Latitude < -90 || city.Latitude > 90 {
+			return model.City{}, fmt.Errorf("invalid latitude: %v", field(1))
+		}
+
+		city.Longitude, err = strconv.ParseFloat(field(2), 64)
+		if err != nil || city.Longitude < -180 || city.Longitude > 180 {
+			return model.City{}, fmt.Errorf("invalid longitude: %v", field(2))
+		}
+	}
+
+	return city, nil
+}
+
+// formatCity returns the line of the cities file for city, keeping lines of
+// cities without location data as just their name
+func formatCity(city model.City) string {
+	fields := []string{city.Name}
+
+	if city.HasCoordinates() || city.Country != "" || city.Timezone != "" || city.Address != "" {
+		fields = append(fields, "", "")
+		if city.HasCoordinates() {
+			fields[1] = strconv.FormatFloat(city.Latitude, 'f', -1, 64)
+			fields[2] = strconv.FormatFloat(city.Longitude, 'f', -1, 64)
+		}
+		fields = append(fields, city.Country, city.Timezone, city.Address)
+		for len(fields) > 3 && fields[len(fields)-1] == "" {
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(fields)
+	writer.Flush()
+
+	return buffer.String()
+}
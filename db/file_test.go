@@ -1,7 +1,14 @@
 package db
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/gbandres98/pack-and-go/model"
 )
 
 func TestGetAllCities_1(t *testing.T) {
@@ -24,12 +31,12 @@ func TestGetAllCities_1(t *testing.T) {
 }
 
 func TestGetAllCities_2(t *testing.T) {
-	defer func() { recover() }()
-
 	db := NewFileDB("wrong-file-path.txt")
-	db.GetAllCities()
 
-	t.Fatalf("expected GetAllCities() to panic")
+	_, err := db.GetAllCities()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected error: %v, got error: %v", os.ErrNotExist, err)
+	}
 }
 
 func TestGetCityById_1(t *testing.T) {
@@ -52,4 +59,152 @@ func TestGetCityById_2(t *testing.T) {
 	if err != ErrorCityNotFound {
 		t.Fatalf("expected error: %v, got error: %v", ErrorCityNotFound, err)
 	}
-}
\ No newline at end of file
+}
+
+func TestCheck_1(t *testing.T) {
+	db := NewFileDB("./cities_test.txt")
+
+	err := db.Check()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCheck_2(t *testing.T) {
+	db := NewFileDB("wrong-file-path.txt")
+
+	err := db.Check()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestCheck_3(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	err := ioutil.WriteFile(filePath, []byte("Barcelona\n\nMadrid\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := NewFileDB(filePath)
+
+	err = db.Check()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestAddCity_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	err := ioutil.WriteFile(filePath, []byte("Barcelona\nSeville"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := NewFileDB(filePath)
+
+	city, err := db.AddCity(model.City{Name: "Madrid"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if city.Id != 3 {
+		t.Fatalf("expected city to have id: %v, got %v", 3, city)
+	}
+
+	city, err = db.AddCity(model.City{Name: "Valencia"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if city.Id != 4 {
+		t.Fatalf("expected city to have id: %v, got %v", 4, city)
+	}
+
+	savedCity, err := db.GetCityById(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if savedCity.Name != "Madrid" {
+		t.Fatalf("expected city %v, got %v", "Madrid", savedCity)
+	}
+
+	content, _ := ioutil.ReadFile(filePath)
+	if string(content) != "Barcelona\nSeville\nMadrid\nValencia\n" {
+		t.Fatalf("unexpected file content: %q", content)
+	}
+}
+
+func TestGetAllCities_3(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	err := ioutil.WriteFile(filePath, []byte(
+		"Barcelona,41.3874,2.1686,ES,Europe/Madrid,\"Estació del Nord, Carrer d'Alí Bei 80\"\r\n"+
+			"Seville\n"+
+			"Andorra la Vella,42.5063,1.5218,AD\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := NewFileDB(filePath)
+
+	cities, err := db.GetAllCities()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.City{
+		{Id: 1, Name: "Barcelona", Latitude: 41.3874, Longitude: 2.1686, Country: "ES", Timezone: "Europe/Madrid", Address: "Estació del Nord, Carrer d'Alí Bei 80"},
+		{Id: 2, Name: "Seville"},
+		{Id: 3, Name: "Andorra la Vella", Latitude: 42.5063, Longitude: 1.5218, Country: "AD"},
+	}
+	if !reflect.DeepEqual(cities, expected) {
+		t.Fatalf("expected %v, got %v", expected, cities)
+	}
+}
+
+func TestGetAllCities_4(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	err := ioutil.WriteFile(filePath, []byte("Barcelona,41.3874,2.1686\nSeville,north,-5.9845\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := NewFileDB(filePath)
+
+	_, err = db.GetAllCities()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	err = db.Check()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestAddCity_2(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cities.txt")
+	err := ioutil.WriteFile(filePath, []byte("Barcelona\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := NewFileDB(filePath)
+
+	newCity := model.City{Name: "Malaga", Latitude: 36.7213, Longitude: -4.4214, Country: "ES", Address: "Paseo de los Tilos, 21"}
+	city, err := db.AddCity(newCity)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	savedCity, err := db.GetCityById(city.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(savedCity, city) {
+		t.Fatalf("expected %v, got %v", city, savedCity)
+	}
+
+	content, _ := ioutil.ReadFile(filePath)
+	if string(content) != "Barcelona\nMalaga,36.7213,-4.4214,ES,,\"Paseo de los Tilos, 21\"\n" {
+		t.Fatalf("unexpected file content: %q", content)
+	}
+}
@@ -0,0 +1,565 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/kv"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Keys of the kv store. Ids are zero padded so keys sort by id, and the
+// indexes of trips by origin and destination hold the trip id after the city
+// id, with no value.
+const (
+	kvTripPrefix              = "trip/"
+	kvTripByOriginPrefix      = "trip_by_origin/"
+	kvTripByDestinationPrefix = "trip_by_destination/"
+	kvCityPrefix              = "city/"
+	kvNextTripIdKey           = "sequence/trip"
+	kvLastCityIdKey           = "sequence/city"
+)
+
+// kvDB keeps trips and cities in an embedded kv store. The trip and city
+// stores of the same file share it, so a write that touches both can be a
+// single transaction.
+type kvDB struct {
+	store    *kv.DB
+	filePath string
+	closed   bool
+	lock     sync.RWMutex
+	now      func() time.Time
+}
+
+var (
+	kvStoresLock sync.Mutex
+	kvStores     = map[string]*sharedKVStore{}
+)
+
+type sharedKVStore struct {
+	store *kv.DB
+	users int
+}
+
+// kv:///var/lib/packandgo keeps trips and cities in the file packandgo.db of a
+// directory, or in the file of the path if it is not a directory. A new store
+// starts with the default trips, and with the cities of the cities file in
+// ?cities= if given.
+func init() {
+	RegisterTripDriver("kv", func(dsn *url.URL) (TripStore, error) {
+		return openKVDB(dsn)
+	})
+	RegisterCityDriver("kv", func(dsn *url.URL) (CityStore, error) {
+		return openKVDB(dsn)
+	})
+}
+
+func openKVDB(dsn *url.URL) (*kvDB, error) {
+	filePath := dsnPath(dsn, "packandgo.db")
+	if filePath == "" {
+		return nil, fmt.Errorf("kv store needs a path, such as kv:///var/lib/packandgo")
+	}
+
+	kvDB, err := NewKVDB(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if citiesPath := dsn.Query().Get("cities"); citiesPath != "" {
+		err = kvDB.seedCities(citiesPath)
+		if err != nil {
+			kvDB.Close()
+			return nil, err
+		}
+	}
+
+	return kvDB, nil
+}
+
+// NewKVDB returns a trip and city database backed by the kv store in
+// filePath, opening it or sharing it if it is already open
+func NewKVDB(filePath string) (*kvDB, error) {
+	absolutePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	kvStoresLock.Lock()
+	defer kvStoresLock.Unlock()
+
+	shared, ok := kvStores[absolutePath]
+	if !ok {
+		store, err := kv.Open(absolutePath)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedKVStore{store: store}
+		kvStores[absolutePath] = shared
+	}
+	shared.users++
+
+	kvDB := &kvDB{store: shared.store, filePath: absolutePath, now: time.Now}
+
+	err = kvDB.seedTrips()
+	if err != nil {
+		kvDB.release()
+		return nil, err
+	}
+
+	return kvDB, nil
+}
+
+// seedTrips adds the default trips to a store that has never had trips
+func (kvDB *kvDB) seedTrips() error {
+	return kvDB.store.Update(func(tx *kv.Tx) error {
+		if tx.Get(kvNextTripIdKey) != nil {
+			return nil
+		}
+
+		now := kvDB.now().UTC()
+		for _, trip := range trips {
+			trip.Version = 1
+			trip.UpdatedAt = now
+			if err := putTrip(tx, trip, model.Trip{}); err != nil {
+				return err
+			}
+		}
+
+		return putInt(tx, kvNextTripIdKey, int64(len(trips)+1))
+	})
+}
+
+// seedCities adds the cities of a cities file to a store without cities
+func (kvDB *kvDB) seedCities(citiesPath string) error {
+	file, err := os.Open(citiesPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cities, err := parseCities(file)
+	if err != nil {
+		return err
+	}
+
+	return kvDB.store.Update(func(tx *kv.Tx) error {
+		if tx.Get(kvLastCityIdKey) != nil {
+			return nil
+		}
+
+		for _, city := range cities {
+			if err := putJSON(tx, kvKey(kvCityPrefix, city.Id), city); err != nil {
+				return err
+			}
+		}
+
+		return putInt(tx, kvLastCityIdKey, int64(len(cities)))
+	})
+}
+
+func (kvDB *kvDB) GetAllTrips() ([]model.Trip, error) {
+	result := []model.Trip{}
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvTripPrefix, func(key string, value []byte) bool {
+			var trip model.Trip
+			err = json.Unmarshal(value, &trip)
+			result = append(result, trip)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (kvDB *kvDB) GetTripById(id int32) (model.Trip, error) {
+	var trip model.Trip
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		trip, err = getTrip(tx, id)
+		return err
+	})
+
+	return trip, err
+}
+
+// GetTripsByOrigin returns the trips leaving from a city, by id
+func (kvDB *kvDB) GetTripsByOrigin(originId int32) ([]model.Trip, error) {
+	return kvDB.getTripsByIndex(kvKey(kvTripByOriginPrefix, originId) + "/")
+}
+
+// GetTripsByDestination returns the trips arriving at a city, by id
+func (kvDB *kvDB) GetTripsByDestination(destinationId int32) ([]model.Trip, error) {
+	return kvDB.getTripsByIndex(kvKey(kvTripByDestinationPrefix, destinationId) + "/")
+}
+
+func (kvDB *kvDB) getTripsByIndex(prefix string) ([]model.Trip, error) {
+	result := []model.Trip{}
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		ids := []int32{}
+		tx.Scan(prefix, func(key string, value []byte) bool {
+			id, err := strconv.ParseInt(key[len(prefix):], 10, 32)
+			if err == nil {
+				ids = append(ids, int32(id))
+			}
+			return true
+		})
+
+		for _, id := range ids {
+			trip, err := getTrip(tx, id)
+			if err != nil {
+				return fmt.Errorf("index %v points to trip %v: %w", prefix, id, err)
+			}
+			result = append(result, trip)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (kvDB *kvDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	err := kvDB.store.Update(func(tx *kv.Tx) error {
+		nextId, err := getInt(tx, kvNextTripIdKey)
+		if err != nil {
+			return err
+		}
+
+		trip.Id = int32(nextId)
+		trip.Version = 1
+		trip.UpdatedAt = kvDB.now().UTC()
+
+		err = putTrip(tx, trip, model.Trip{})
+		if err != nil {
+			return err
+		}
+
+		return putInt(tx, kvNextTripIdKey, nextId+1)
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// UpdateTrip replaces the trip with the same id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (kvDB *kvDB) UpdateTrip(trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	err := kvDB.store.Update(func(tx *kv.Tx) error {
+		current, err := getTrip(tx, trip.Id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && current.Version != expectedVersion {
+			return ErrorVersionMismatch
+		}
+
+		trip.Version = current.Version + 1
+		trip.UpdatedAt = kvDB.now().UTC()
+
+		return putTrip(tx, trip, current)
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// DeleteTrip removes the trip with the given id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (kvDB *kvDB) DeleteTrip(id int32, expectedVersion int32) (model.Trip, error) {
+	var trip model.Trip
+
+	err := kvDB.store.Update(func(tx *kv.Tx) error {
+		var err error
+		trip, err = getTrip(tx, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && trip.Version != expectedVersion {
+			return ErrorVersionMismatch
+		}
+
+		tx.Delete(kvKey(kvTripByOriginPrefix, trip.OriginId, trip.Id))
+		tx.Delete(kvKey(kvTripByDestinationPrefix, trip.DestinationId, trip.Id))
+		return tx.Delete(kvKey(kvTripPrefix, trip.Id))
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// ExportTrips returns the trips, by id, and the next id to be given, read in one transaction
+func (kvDB *kvDB) ExportTrips() ([]model.Trip, int32, error) {
+	result := []model.Trip{}
+	var nextId int64
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvTripPrefix, func(key string, value []byte) bool {
+			var trip model.Trip
+			err = json.Unmarshal(value, &trip)
+			result = append(result, trip)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		nextId, err = getInt(tx, kvNextTripIdKey)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, int32(nextId), nil
+}
+
+// ImportTrips replaces every trip, their indexes and the next id to be given, in one transaction
+func (kvDB *kvDB) ImportTrips(trips []model.Trip, nextId int32) error {
+	return kvDB.store.Update(func(tx *kv.Tx) error {
+		for _, prefix := range []string{kvTripPrefix, kvTripByOriginPrefix, kvTripByDestinationPrefix} {
+			if err := deletePrefix(tx, prefix); err != nil {
+				return err
+			}
+		}
+
+		for _, trip := range trips {
+			if err := putTrip(tx, trip, model.Trip{}); err != nil {
+				return err
+			}
+		}
+
+		return putInt(tx, kvNextTripIdKey, int64(nextId))
+	})
+}
+
+func (kvDB *kvDB) GetAllCities() ([]model.City, error) {
+	result := []model.City{}
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvCityPrefix, func(key string, value []byte) bool {
+			var city model.City
+			err = json.Unmarshal(value, &city)
+			result = append(result, city)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (kvDB *kvDB) GetCityById(id int32) (model.City, error) {
+	var city model.City
+
+	err := kvDB.store.View(func(tx *kv.Tx) error {
+		value := tx.Get(kvKey(kvCityPrefix, id))
+		if value == nil {
+			return ErrorCityNotFound
+		}
+		return json.Unmarshal(value, &city)
+	})
+
+	return city, err
+}
+
+// AddCity gives the city the next id, as cities are never removed
+func (kvDB *kvDB) AddCity(city model.City) (model.City, error) {
+	err := kvDB.store.Update(func(tx *kv.Tx) error {
+		lastId, err := getInt(tx, kvLastCityIdKey)
+		if err != nil {
+			return err
+		}
+
+		city.Id = int32(lastId + 1)
+		err = putJSON(tx, kvKey(kvCityPrefix, city.Id), city)
+		if err != nil {
+			return err
+		}
+
+		return putInt(tx, kvLastCityIdKey, lastId+1)
+	})
+	if err != nil {
+		return model.City{}, err
+	}
+
+	return city, nil
+}
+
+// UpdateCity replaces the city with the same id
+func (kvDB *kvDB) UpdateCity(city model.City) (model.City, error) {
+	err := kvDB.store.Update(func(tx *kv.Tx) error {
+		if tx.Get(kvKey(kvCityPrefix, city.Id)) == nil {
+			return ErrorCityNotFound
+		}
+		return putJSON(tx, kvKey(kvCityPrefix, city.Id), city)
+	})
+	if err != nil {
+		return model.City{}, err
+	}
+
+	return city, nil
+}
+
+// ImportCities replaces every city, in one transaction
+func (kvDB *kvDB) ImportCities(cities []model.City) error {
+	return kvDB.store.Update(func(tx *kv.Tx) error {
+		if err := deletePrefix(tx, kvCityPrefix); err != nil {
+			return err
+		}
+
+		for _, city := range cities {
+			if err := putJSON(tx, kvKey(kvCityPrefix, city.Id), city); err != nil {
+				return err
+			}
+		}
+
+		return putInt(tx, kvLastCityIdKey, int64(len(cities)))
+	})
+}
+
+// Update runs fn in a single transaction of the kv store, so its writes are
+// kept all together or not at all
+func (kvDB *kvDB) Update(fn func(*kv.Tx) error) error {
+	return kvDB.store.Update(fn)
+}
+
+// Close closes the kv store once the trip and city stores that share it are closed
+func (kvDB *kvDB) Close() error {
+	kvDB.lock.Lock()
+	defer kvDB.lock.Unlock()
+
+	if kvDB.closed {
+		return nil
+	}
+	kvDB.closed = true
+
+	return kvDB.release()
+}
+
+func (kvDB *kvDB) release() error {
+	kvStoresLock.Lock()
+	defer kvStoresLock.Unlock()
+
+	shared := kvStores[kvDB.filePath]
+	shared.users--
+	if shared.users > 0 {
+		return nil
+	}
+
+	delete(kvStores, kvDB.filePath)
+	return shared.store.Close()
+}
+
+// Check verifies that the kv store is open
+func (kvDB *kvDB) Check() error {
+	kvDB.lock.RLock()
+	defer kvDB.lock.RUnlock()
+
+	if kvDB.closed {
+		return kv.ErrorClosed
+	}
+
+	return kvDB.store.Check()
+}
+
+func kvKey(prefix string, ids ...int32) string {
+	key := prefix
+	for i, id := range ids {
+		if i > 0 {
+			key += "/"
+		}
+		key += fmt.Sprintf("%010d", id)
+	}
+
+	return key
+}
+
+func getTrip(tx *kv.Tx, id int32) (model.Trip, error) {
+	value := tx.Get(kvKey(kvTripPrefix, id))
+	if value == nil {
+		return model.Trip{}, ErrorTripNotFound
+	}
+
+	var trip model.Trip
+	err := json.Unmarshal(value, &trip)
+	return trip, err
+}
+
+// putTrip saves a trip and its index entries, removing those of the previous
+// version of the trip that no longer apply
+func putTrip(tx *kv.Tx, trip model.Trip, previous model.Trip) error {
+	if previous.Id != 0 && previous.OriginId != trip.OriginId {
+		tx.Delete(kvKey(kvTripByOriginPrefix, previous.OriginId, previous.Id))
+	}
+	if previous.Id != 0 && previous.DestinationId != trip.DestinationId {
+		tx.Delete(kvKey(kvTripByDestinationPrefix, previous.DestinationId, previous.Id))
+	}
+
+	tx.Put(kvKey(kvTripByOriginPrefix, trip.OriginId, trip.Id), nil)
+	tx.Put(kvKey(kvTripByDestinationPrefix, trip.DestinationId, trip.Id), nil)
+	return putJSON(tx, kvKey(kvTripPrefix, trip.Id), trip)
+}
+
+// deletePrefix deletes every key that starts with prefix
+func deletePrefix(tx *kv.Tx, prefix string) error {
+	keys := []string{}
+	tx.Scan(prefix, func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	for _, key := range keys {
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func putJSON(tx *kv.Tx, key string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return tx.Put(key, body)
+}
+
+func getInt(tx *kv.Tx, key string) (int64, error) {
+	value := tx.Get(key)
+	if value == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+func putInt(tx *kv.Tx, key string, value int64) error {
+	return tx.Put(key, []byte(strconv.FormatInt(value, 10)))
+}
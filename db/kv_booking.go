@@ -0,0 +1,425 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/kv"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Keys of the bookings in the kv store. The seats of a departure hold the id
+// of the booking that took them, and the indexes of bookings and ledger
+// entries hold their id after the customer or departure, with no value.
+const (
+	kvBookingPrefix            = "booking/"
+	kvBookingByCustomerPrefix  = "booking_by_customer/"
+	kvBookingByDeparturePrefix = "booking_by_departure/"
+	kvSeatPrefix               = "seat/"
+	kvLedgerPrefix             = "ledger/"
+	kvLedgerByCustomerPrefix   = "ledger_by_customer/"
+	kvNextBookingIdKey         = "sequence/booking"
+	kvNextLedgerEntryIdKey     = "sequence/ledger"
+)
+
+// kvBookingDB keeps bookings and the ledger in the kv store of the trips and
+// cities, so a booking and its seat are always written in one transaction
+type kvBookingDB struct {
+	kvDB *kvDB
+}
+
+// kv:// trip stores keep bookings in the same kv store
+func init() {
+	RegisterBookingDriver("kv", func(dsn *url.URL) (BookingStore, error) {
+		kvDB, err := openKVDB(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		return &kvBookingDB{kvDB: kvDB}, nil
+	})
+}
+
+// NewKVBookingDB returns a booking database backed by the kv store in
+// filePath, opening it or sharing it if it is already open
+func NewKVBookingDB(filePath string) (*kvBookingDB, error) {
+	kvDB, err := NewKVDB(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvBookingDB{kvDB: kvDB}, nil
+}
+
+// AddBooking saves a booking with the lowest free seat of its departure,
+// failing with ErrorDepartureFull if all capacity seats are taken. The
+// booking, its seat and its indexes are written in one transaction.
+func (kvBookingDB *kvBookingDB) AddBooking(booking model.Booking, capacity int) (model.Booking, error) {
+	err := kvBookingDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		key := booking.DepartureKey()
+
+		booking.Seat = 0
+		for seat := 1; seat <= capacity; seat++ {
+			if tx.Get(kvSeatKey(key, seat)) == nil {
+				booking.Seat = seat
+				break
+			}
+		}
+		if booking.Seat == 0 {
+			return ErrorDepartureFull
+		}
+
+		nextId, err := getNextId(tx, kvNextBookingIdKey)
+		if err != nil {
+			return err
+		}
+
+		booking.Id = nextId
+		booking.Status = model.BookingConfirmed
+		booking.CreatedAt = time.Now().UTC()
+
+		err = tx.Put(kvSeatKey(key, booking.Seat), []byte(strconv.Itoa(int(booking.Id))))
+		if err != nil {
+			return err
+		}
+		tx.Put(kvKey(kvBookingByCustomerPrefix, booking.CustomerId, booking.Id), nil)
+		tx.Put(kvDepartureKey(kvBookingByDeparturePrefix, key)+kvKey("", booking.Id), nil)
+		err = putJSON(tx, kvKey(kvBookingPrefix, booking.Id), booking)
+		if err != nil {
+			return err
+		}
+
+		return putInt(tx, kvNextBookingIdKey, int64(nextId+1))
+	})
+	if err != nil {
+		return model.Booking{}, err
+	}
+
+	return booking, nil
+}
+
+// UpdateBooking replaces the booking with the same id. Its customer,
+// departure and seat can not change.
+func (kvBookingDB *kvBookingDB) UpdateBooking(booking model.Booking) (model.Booking, error) {
+	err := kvBookingDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		current, err := getBooking(tx, booking.Id)
+		if err != nil {
+			return err
+		}
+
+		booking.CustomerId = current.CustomerId
+		booking.TripId = current.TripId
+		booking.Departure = current.Departure
+		booking.Seat = current.Seat
+		return putJSON(tx, kvKey(kvBookingPrefix, booking.Id), booking)
+	})
+	if err != nil {
+		return model.Booking{}, err
+	}
+
+	return booking, nil
+}
+
+// DeleteBooking removes a booking, its indexes and its seat in one transaction
+func (kvBookingDB *kvBookingDB) DeleteBooking(id int32) error {
+	return kvBookingDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		booking, err := getBooking(tx, id)
+		if err != nil {
+			return err
+		}
+
+		releaseSeat(tx, booking)
+		tx.Delete(kvKey(kvBookingByCustomerPrefix, booking.CustomerId, booking.Id))
+		tx.Delete(kvDepartureKey(kvBookingByDeparturePrefix, booking.DepartureKey()) + kvKey("", booking.Id))
+		return tx.Delete(kvKey(kvBookingPrefix, booking.Id))
+	})
+}
+
+// CancelBooking cancels a confirmed booking, frees its seat and records the
+// ledger entry returned by refund, all in one transaction so the seat can not
+// be sold again before the refund is recorded
+func (kvBookingDB *kvBookingDB) CancelBooking(id int32, cancelledAt time.Time, refund func(model.Booking) model.LedgerEntry) (model.Booking, model.LedgerEntry, error) {
+	var booking model.Booking
+	var entry model.LedgerEntry
+
+	err := kvBookingDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		var err error
+		booking, err = getBooking(tx, id)
+		if err != nil {
+			return err
+		}
+		if booking.Status == model.BookingCancelled {
+			return ErrorBookingCancelled
+		}
+
+		nextLedgerId, err := getNextId(tx, kvNextLedgerEntryIdKey)
+		if err != nil {
+			return err
+		}
+
+		entry = refund(booking)
+		entry.Id = nextLedgerId
+		entry.BookingId = booking.Id
+		entry.CustomerId = booking.CustomerId
+		entry.CreatedAt = cancelledAt
+
+		booking.Status = model.BookingCancelled
+		booking.CancelledAt = &cancelledAt
+		releaseSeat(tx, booking)
+		if err := putJSON(tx, kvKey(kvBookingPrefix, booking.Id), booking); err != nil {
+			return err
+		}
+		if err := putLedgerEntry(tx, entry); err != nil {
+			return err
+		}
+
+		return putInt(tx, kvNextLedgerEntryIdKey, int64(nextLedgerId+1))
+	})
+	if err != nil {
+		return model.Booking{}, model.LedgerEntry{}, err
+	}
+
+	return booking, entry, nil
+}
+
+// GetLedgerEntries returns the ledger entries of a customer, oldest first
+func (kvBookingDB *kvBookingDB) GetLedgerEntries(customerId int32) []model.LedgerEntry {
+	result := []model.LedgerEntry{}
+
+	err := kvBookingDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvKey(kvLedgerByCustomerPrefix, customerId)+"/", func(key string, value []byte) bool {
+			var entry model.LedgerEntry
+			err = getJSON(tx, kvLedgerPrefix+key[len(key)-10:], &entry)
+			result = append(result, entry)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		log.Printf("could not read the ledger of customer %v: %v", customerId, err)
+		return []model.LedgerEntry{}
+	}
+
+	return result
+}
+
+func (kvBookingDB *kvBookingDB) GetBookingById(id int32) (model.Booking, error) {
+	var booking model.Booking
+
+	err := kvBookingDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		booking, err = getBooking(tx, id)
+		return err
+	})
+
+	return booking, err
+}
+
+func (kvBookingDB *kvBookingDB) GetBookingsByCustomer(customerId int32) []model.Booking {
+	bookings, err := kvBookingDB.getIndexedBookings(kvKey(kvBookingByCustomerPrefix, customerId) + "/")
+	if err != nil {
+		log.Printf("could not read the bookings of customer %v: %v", customerId, err)
+		return []model.Booking{}
+	}
+
+	return bookings
+}
+
+func (kvBookingDB *kvBookingDB) GetBookingsByDeparture(key model.DepartureKey) []model.Booking {
+	bookings, err := kvBookingDB.getIndexedBookings(kvDepartureKey(kvBookingByDeparturePrefix, key))
+	if err != nil {
+		log.Printf("could not read the bookings of trip %v on %v: %v", key.TripId, key.Date, err)
+		return []model.Booking{}
+	}
+
+	return bookings
+}
+
+// getIndexedBookings returns the bookings whose ids end the keys of an index
+// that start with prefix
+func (kvBookingDB *kvBookingDB) getIndexedBookings(prefix string) ([]model.Booking, error) {
+	result := []model.Booking{}
+
+	err := kvBookingDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(prefix, func(key string, value []byte) bool {
+			var booking model.Booking
+			err = getJSON(tx, kvBookingPrefix+key[len(key)-10:], &booking)
+			result = append(result, booking)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExportBookings returns the bookings and the ledger, by id, with the next
+// booking and ledger entry ids to be given, read in one transaction
+func (kvBookingDB *kvBookingDB) ExportBookings() ([]model.Booking, []model.LedgerEntry, int32, int32, error) {
+	bookings := []model.Booking{}
+	ledger := []model.LedgerEntry{}
+	var nextId, nextLedgerId int32
+
+	err := kvBookingDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvBookingPrefix, func(key string, value []byte) bool {
+			var booking model.Booking
+			err = json.Unmarshal(value, &booking)
+			bookings = append(bookings, booking)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		tx.Scan(kvLedgerPrefix, func(key string, value []byte) bool {
+			var entry model.LedgerEntry
+			err = json.Unmarshal(value, &entry)
+			ledger = append(ledger, entry)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		nextId, err = getNextId(tx, kvNextBookingIdKey)
+		if err != nil {
+			return err
+		}
+		nextLedgerId, err = getNextId(tx, kvNextLedgerEntryIdKey)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	return bookings, ledger, nextId, nextLedgerId, nil
+}
+
+// ImportBookings replaces the bookings, the ledger, their indexes and next
+// ids in one transaction. The seats of confirmed bookings are taken again.
+func (kvBookingDB *kvBookingDB) ImportBookings(bookings []model.Booking, ledger []model.LedgerEntry, nextId int32, nextLedgerId int32) error {
+	return kvBookingDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		prefixes := []string{kvBookingPrefix, kvBookingByCustomerPrefix, kvBookingByDeparturePrefix, kvSeatPrefix, kvLedgerPrefix, kvLedgerByCustomerPrefix}
+		for _, prefix := range prefixes {
+			if err := deletePrefix(tx, prefix); err != nil {
+				return err
+			}
+		}
+
+		for _, booking := range bookings {
+			key := booking.DepartureKey()
+			if booking.Status == model.BookingConfirmed {
+				if other := tx.Get(kvSeatKey(key, booking.Seat)); other != nil {
+					return fmt.Errorf("bookings %s and %v hold the same seat", other, booking.Id)
+				}
+				tx.Put(kvSeatKey(key, booking.Seat), []byte(strconv.Itoa(int(booking.Id))))
+			}
+
+			tx.Put(kvKey(kvBookingByCustomerPrefix, booking.CustomerId, booking.Id), nil)
+			tx.Put(kvDepartureKey(kvBookingByDeparturePrefix, key)+kvKey("", booking.Id), nil)
+			if err := putJSON(tx, kvKey(kvBookingPrefix, booking.Id), booking); err != nil {
+				return err
+			}
+		}
+
+		for _, entry := range ledger {
+			if err := putLedgerEntry(tx, entry); err != nil {
+				return err
+			}
+		}
+
+		if err := putInt(tx, kvNextBookingIdKey, int64(nextId)); err != nil {
+			return err
+		}
+		return putInt(tx, kvNextLedgerEntryIdKey, int64(nextLedgerId))
+	})
+}
+
+// SeatsTaken returns the number of seats booked on a departure
+func (kvBookingDB *kvBookingDB) SeatsTaken(key model.DepartureKey) int {
+	taken := 0
+
+	kvBookingDB.kvDB.store.View(func(tx *kv.Tx) error {
+		tx.Scan(kvDepartureKey(kvSeatPrefix, key), func(key string, value []byte) bool {
+			taken++
+			return true
+		})
+		return nil
+	})
+
+	return taken
+}
+
+// Close closes the kv store once the other stores that share it are closed
+func (kvBookingDB *kvBookingDB) Close() error {
+	return kvBookingDB.kvDB.Close()
+}
+
+// Check verifies that the kv store is open
+func (kvBookingDB *kvBookingDB) Check() error {
+	return kvBookingDB.kvDB.Check()
+}
+
+// kvDepartureKey returns the prefix of the keys of a departure, such as
+// seat/0000000001/2026-03-07/
+func kvDepartureKey(prefix string, key model.DepartureKey) string {
+	return kvKey(prefix, key.TripId) + "/" + key.Date + "/"
+}
+
+func kvSeatKey(key model.DepartureKey, seat int) string {
+	return kvDepartureKey(kvSeatPrefix, key) + fmt.Sprintf("%04d", seat)
+}
+
+func getBooking(tx *kv.Tx, id int32) (model.Booking, error) {
+	var booking model.Booking
+
+	value := tx.Get(kvKey(kvBookingPrefix, id))
+	if value == nil {
+		return model.Booking{}, ErrorBookingNotFound
+	}
+
+	err := json.Unmarshal(value, &booking)
+	return booking, err
+}
+
+// releaseSeat frees the seat of a booking, unless another booking holds it
+func releaseSeat(tx *kv.Tx, booking model.Booking) {
+	key := kvSeatKey(booking.DepartureKey(), booking.Seat)
+	if string(tx.Get(key)) == strconv.Itoa(int(booking.Id)) {
+		tx.Delete(key)
+	}
+}
+
+func putLedgerEntry(tx *kv.Tx, entry model.LedgerEntry) error {
+	tx.Put(kvKey(kvLedgerByCustomerPrefix, entry.CustomerId, entry.Id), nil)
+	return putJSON(tx, kvKey(kvLedgerPrefix, entry.Id), entry)
+}
+
+// getNextId returns the id kept in key, or 1 if none has been given yet
+func getNextId(tx *kv.Tx, key string) (int32, error) {
+	nextId, err := getInt(tx, key)
+	if nextId == 0 {
+		nextId = 1
+	}
+
+	return int32(nextId), err
+}
+
+func getJSON(tx *kv.Tx, key string, value interface{}) error {
+	body := tx.Get(key)
+	if body == nil {
+		return fmt.Errorf("missing key %v", key)
+	}
+
+	return json.Unmarshal(body, value)
+}
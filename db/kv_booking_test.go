@@ -0,0 +1,154 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/kv"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestKVBookingDB_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "packandgo.db")
+
+	kvBookingDB, err := NewKVBookingDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, _ := kvBookingDB.AddBooking(model.Booking{CustomerId: 7, TripId: 1, Departure: testDeparture, Price: 30}, 2)
+	second, _ := kvBookingDB.AddBooking(model.Booking{CustomerId: 8, TripId: 1, Departure: testDeparture}, 2)
+	if first.Id != 1 || first.Seat != 1 || second.Id != 2 || second.Seat != 2 {
+		t.Fatalf("expected bookings 1 and 2 on seats 1 and 2, got %v %v", first, second)
+	}
+	if _, err := kvBookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2); err != ErrorDepartureFull {
+		t.Fatalf("expected error: %v, got error: %v", ErrorDepartureFull, err)
+	}
+
+	_, entry, err := kvBookingDB.CancelBooking(first.Id, testDeparture, func(booking model.Booking) model.LedgerEntry {
+		return model.LedgerEntry{Type: model.LedgerRefund, Amount: booking.Price / 2}
+	})
+	if err != nil || entry.Id != 1 || entry.CustomerId != 7 || entry.Amount != 15 {
+		t.Fatalf("expected refund of %v for booking %v, got %v %v", 15, first.Id, entry, err)
+	}
+	kvBookingDB.Close()
+
+	kvBookingDB, err = NewKVBookingDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer kvBookingDB.Close()
+
+	key := model.NewDepartureKey(1, testDeparture)
+	if taken := kvBookingDB.SeatsTaken(key); taken != 1 {
+		t.Fatalf("expected %v seats taken, got %v", 1, taken)
+	}
+	if bookings := kvBookingDB.GetBookingsByDeparture(key); len(bookings) != 2 || bookings[0].Status != model.BookingCancelled {
+		t.Fatalf("expected the cancelled and the confirmed booking, got %v", bookings)
+	}
+	if bookings := kvBookingDB.GetBookingsByCustomer(8); len(bookings) != 1 || bookings[0].Id != second.Id {
+		t.Fatalf("expected booking %v of customer 8, got %v", second.Id, bookings)
+	}
+	if ledger := kvBookingDB.GetLedgerEntries(7); len(ledger) != 1 || ledger[0] != entry {
+		t.Fatalf("expected ledger %v, got %v", []model.LedgerEntry{entry}, ledger)
+	}
+
+	rebooked, err := kvBookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 2)
+	if err != nil || rebooked.Id != 3 || rebooked.Seat != first.Seat {
+		t.Fatalf("expected booking 3 on freed seat %v, got %v %v", first.Seat, rebooked, err)
+	}
+
+	if err := kvBookingDB.DeleteBooking(rebooked.Id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if taken := kvBookingDB.SeatsTaken(key); taken != 1 {
+		t.Fatalf("expected the seat of the deleted booking to be freed, got %v taken", taken)
+	}
+}
+
+func TestKVBookingDB_2(t *testing.T) {
+	kvBookingDB, _ := NewKVBookingDB(filepath.Join(t.TempDir(), "packandgo.db"))
+	defer kvBookingDB.Close()
+
+	var wait sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			kvBookingDB.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 50)
+		}()
+	}
+	wait.Wait()
+
+	bookings := kvBookingDB.GetBookingsByDeparture(model.NewDepartureKey(1, testDeparture))
+	if len(bookings) != 50 {
+		t.Fatalf("expected %v bookings, got %v", 50, len(bookings))
+	}
+
+	seats := map[int]bool{}
+	for _, booking := range bookings {
+		if seats[booking.Seat] {
+			t.Fatalf("expected every seat to be booked once, seat %v was booked twice", booking.Seat)
+		}
+		seats[booking.Seat] = true
+	}
+}
+
+func TestKVBookingDB_3(t *testing.T) {
+	source := NewBookingDB()
+	source.AddBooking(model.Booking{CustomerId: 7, TripId: 1, Departure: testDeparture}, 2)
+	source.AddBooking(model.Booking{CustomerId: 7, TripId: 1, Departure: testDeparture}, 2)
+	source.CancelBooking(1, testDeparture, func(booking model.Booking) model.LedgerEntry {
+		return model.LedgerEntry{Type: model.LedgerRefund, Amount: 10}
+	})
+	bookings, ledger, nextId, nextLedgerId, _ := source.ExportBookings()
+
+	kvBookingDB, _ := NewKVBookingDB(filepath.Join(t.TempDir(), "packandgo.db"))
+	defer kvBookingDB.Close()
+	if err := kvBookingDB.ImportBookings(bookings, ledger, nextId, nextLedgerId); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exported, exportedLedger, exportedNextId, exportedNextLedgerId, err := kvBookingDB.ExportBookings()
+	if err != nil || !reflect.DeepEqual(exported, bookings) || !reflect.DeepEqual(exportedLedger, ledger) || exportedNextId != 3 || exportedNextLedgerId != 2 {
+		t.Fatalf("expected the imported bookings back, got %v %v %v %v %v", exported, exportedLedger, exportedNextId, exportedNextLedgerId, err)
+	}
+
+	bookings[0].Status = model.BookingConfirmed
+	bookings[0].Seat = 2
+	if err := kvBookingDB.ImportBookings(bookings, ledger, nextId, nextLedgerId); err == nil {
+		t.Fatalf("expected bookings holding the same seat to be refused")
+	}
+	if exported, _, _, _, _ := kvBookingDB.ExportBookings(); len(exported) != 2 || exported[0].Status != model.BookingCancelled {
+		t.Fatalf("expected a refused import to keep the bookings, got %v", exported)
+	}
+}
+
+func TestOpenBookingStore_1(t *testing.T) {
+	dir := t.TempDir()
+
+	if bookingStore, err := OpenBookingStore("memory://"); err != nil || reflect.TypeOf(bookingStore) != reflect.TypeOf(&bookingDB{}) {
+		t.Fatalf("expected memory trip stores to keep bookings in memory, got %T %v", bookingStore, err)
+	}
+
+	tripStore, _ := OpenTripStore("kv://" + dir)
+	defer tripStore.(*kvDB).Close()
+	bookingStore, err := OpenBookingStore("kv://" + dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer bookingStore.(*kvBookingDB).Close()
+
+	// Bookings share the kv store of the trips
+	err = tripStore.(*kvDB).Update(func(tx *kv.Tx) error {
+		return putInt(tx, kvNextBookingIdKey, 10)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if booking, _ := bookingStore.AddBooking(model.Booking{TripId: 1, Departure: testDeparture}, 1); booking.Id != 10 {
+		t.Fatalf("expected booking 10, got %v", booking)
+	}
+}
@@ -0,0 +1,386 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/kv"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Keys of the customers in the kv store. The email index holds the id of the
+// customer with that lowercased email, and the passenger index holds the
+// passenger id after the customer id, with no value.
+const (
+	kvCustomerPrefix            = "customer/"
+	kvCustomerByEmailPrefix     = "customer_by_email/"
+	kvSessionPrefix             = "session/"
+	kvPassengerPrefix           = "passenger/"
+	kvPassengerByCustomerPrefix = "passenger_by_customer/"
+	kvNextCustomerIdKey         = "sequence/customer"
+	kvNextPassengerIdKey        = "sequence/passenger"
+)
+
+// kvCustomerDB keeps customers, their sessions and passengers in the kv store
+// of the bookings, so a booking never outlives the customer that owns it
+type kvCustomerDB struct {
+	kvDB *kvDB
+	now  func() time.Time
+}
+
+// kvCustomer is a customer as stored, with the password hash that is never
+// serialized otherwise
+type kvCustomer struct {
+	model.Customer
+	PasswordHash string `json:"passwordHash"`
+}
+
+// kv:// trip stores keep customers in the same kv store
+func init() {
+	RegisterCustomerDriver("kv", func(dsn *url.URL) (CustomerStore, error) {
+		kvDB, err := openKVDB(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		return &kvCustomerDB{kvDB: kvDB, now: time.Now}, nil
+	})
+}
+
+// NewKVCustomerDB returns a customer database backed by the kv store in
+// filePath, opening it or sharing it if it is already open
+func NewKVCustomerDB(filePath string) (*kvCustomerDB, error) {
+	kvDB, err := NewKVDB(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvCustomerDB{kvDB: kvDB, now: time.Now}, nil
+}
+
+// AddCustomer saves a new customer, failing with ErrorCustomerExists if the email is taken
+func (kvCustomerDB *kvCustomerDB) AddCustomer(customer model.Customer) (model.Customer, error) {
+	err := kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		emailKey := kvCustomerByEmailPrefix + strings.ToLower(customer.Email)
+		if tx.Get(emailKey) != nil {
+			return ErrorCustomerExists
+		}
+
+		nextId, err := getNextId(tx, kvNextCustomerIdKey)
+		if err != nil {
+			return err
+		}
+
+		customer.Id = nextId
+		customer.CreatedAt = kvCustomerDB.now().UTC()
+		if err := putCustomer(tx, customer); err != nil {
+			return err
+		}
+		return putInt(tx, kvNextCustomerIdKey, int64(nextId)+1)
+	})
+	if err != nil {
+		return model.Customer{}, err
+	}
+
+	return customer, nil
+}
+
+func (kvCustomerDB *kvCustomerDB) GetCustomerById(id int32) (model.Customer, error) {
+	var customer model.Customer
+
+	err := kvCustomerDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		customer, err = getCustomer(tx, id)
+		return err
+	})
+
+	return customer, err
+}
+
+func (kvCustomerDB *kvCustomerDB) GetCustomerByEmail(email string) (model.Customer, error) {
+	var customer model.Customer
+
+	err := kvCustomerDB.kvDB.store.View(func(tx *kv.Tx) error {
+		value := tx.Get(kvCustomerByEmailPrefix + strings.ToLower(email))
+		if value == nil {
+			return ErrorCustomerNotFound
+		}
+
+		id, err := strconv.ParseInt(string(value), 10, 32)
+		if err != nil {
+			return err
+		}
+
+		customer, err = getCustomer(tx, int32(id))
+		return err
+	})
+
+	return customer, err
+}
+
+func (kvCustomerDB *kvCustomerDB) AddSession(session model.Session) error {
+	return kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		return putJSON(tx, kvSessionPrefix+session.TokenHash, session)
+	})
+}
+
+// GetSession returns the session with the given token hash, failing with
+// ErrorSessionNotFound if it does not exist or has expired
+func (kvCustomerDB *kvCustomerDB) GetSession(tokenHash string) (model.Session, error) {
+	var session model.Session
+	expired := false
+
+	err := kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		value := tx.Get(kvSessionPrefix + tokenHash)
+		if value == nil {
+			return ErrorSessionNotFound
+		}
+		if err := json.Unmarshal(value, &session); err != nil {
+			return err
+		}
+
+		// Expired sessions are deleted without failing, so the delete is kept
+		if !kvCustomerDB.now().Before(session.ExpiresAt) {
+			expired = true
+			return tx.Delete(kvSessionPrefix + tokenHash)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return model.Session{}, err
+	}
+	if expired {
+		return model.Session{}, ErrorSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (kvCustomerDB *kvCustomerDB) DeleteSession(tokenHash string) error {
+	return kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		if tx.Get(kvSessionPrefix+tokenHash) == nil {
+			return ErrorSessionNotFound
+		}
+
+		return tx.Delete(kvSessionPrefix + tokenHash)
+	})
+}
+
+// GetPassengers returns the passengers saved by a customer
+func (kvCustomerDB *kvCustomerDB) GetPassengers(customerId int32) []model.Passenger {
+	result := []model.Passenger{}
+
+	err := kvCustomerDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvKey(kvPassengerByCustomerPrefix, customerId)+"/", func(key string, value []byte) bool {
+			var passenger model.Passenger
+			err = getJSON(tx, kvPassengerPrefix+key[len(key)-10:], &passenger)
+			result = append(result, passenger)
+			return err == nil
+		})
+		return err
+	})
+	if err != nil {
+		log.Printf("could not read the passengers of customer %v: %v", customerId, err)
+		return []model.Passenger{}
+	}
+
+	return result
+}
+
+func (kvCustomerDB *kvCustomerDB) GetPassengerById(id int32) (model.Passenger, error) {
+	var passenger model.Passenger
+
+	err := kvCustomerDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		passenger, err = getPassenger(tx, id)
+		return err
+	})
+
+	return passenger, err
+}
+
+func (kvCustomerDB *kvCustomerDB) AddPassenger(passenger model.Passenger) model.Passenger {
+	err := kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		nextId, err := getNextId(tx, kvNextPassengerIdKey)
+		if err != nil {
+			return err
+		}
+
+		passenger.Id = nextId
+		if err := putPassenger(tx, passenger); err != nil {
+			return err
+		}
+		return putInt(tx, kvNextPassengerIdKey, int64(nextId)+1)
+	})
+	if err != nil {
+		log.Printf("could not save a passenger of customer %v: %v", passenger.CustomerId, err)
+		return model.Passenger{}
+	}
+
+	return passenger
+}
+
+// UpdatePassenger replaces the passenger with the same id, which can not change owner
+func (kvCustomerDB *kvCustomerDB) UpdatePassenger(passenger model.Passenger) (model.Passenger, error) {
+	err := kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		current, err := getPassenger(tx, passenger.Id)
+		if err != nil {
+			return err
+		}
+
+		passenger.CustomerId = current.CustomerId
+		return putPassenger(tx, passenger)
+	})
+	if err != nil {
+		return model.Passenger{}, err
+	}
+
+	return passenger, nil
+}
+
+func (kvCustomerDB *kvCustomerDB) DeletePassenger(id int32) error {
+	return kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		passenger, err := getPassenger(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(kvKey(kvPassengerByCustomerPrefix, passenger.CustomerId, id)); err != nil {
+			return err
+		}
+		return tx.Delete(kvKey(kvPassengerPrefix, id))
+	})
+}
+
+// ExportCustomers returns the customers, with their password hashes, and the
+// passengers, by id, with the next customer and passenger ids to be given,
+// read in one transaction. Sessions are not exported.
+func (kvCustomerDB *kvCustomerDB) ExportCustomers() ([]model.Customer, []model.Passenger, int32, int32, error) {
+	customers := []model.Customer{}
+	passengers := []model.Passenger{}
+	var nextCustomerId, nextPassengerId int32
+
+	err := kvCustomerDB.kvDB.store.View(func(tx *kv.Tx) error {
+		var err error
+		tx.Scan(kvCustomerPrefix, func(key string, value []byte) bool {
+			var stored kvCustomer
+			err = json.Unmarshal(value, &stored)
+			stored.Customer.PasswordHash = stored.PasswordHash
+			customers = append(customers, stored.Customer)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		tx.Scan(kvPassengerPrefix, func(key string, value []byte) bool {
+			var passenger model.Passenger
+			err = json.Unmarshal(value, &passenger)
+			passengers = append(passengers, passenger)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+
+		nextCustomerId, err = getNextId(tx, kvNextCustomerIdKey)
+		if err != nil {
+			return err
+		}
+		nextPassengerId, err = getNextId(tx, kvNextPassengerIdKey)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	return customers, passengers, nextCustomerId, nextPassengerId, nil
+}
+
+// ImportCustomers replaces the customers, the passengers, their indexes and
+// next ids in one transaction. Every session is ended, as its customer may no
+// longer be the same.
+func (kvCustomerDB *kvCustomerDB) ImportCustomers(customers []model.Customer, passengers []model.Passenger, nextCustomerId int32, nextPassengerId int32) error {
+	return kvCustomerDB.kvDB.store.Update(func(tx *kv.Tx) error {
+		prefixes := []string{kvCustomerPrefix, kvCustomerByEmailPrefix, kvSessionPrefix, kvPassengerPrefix, kvPassengerByCustomerPrefix}
+		for _, prefix := range prefixes {
+			if err := deletePrefix(tx, prefix); err != nil {
+				return err
+			}
+		}
+
+		for _, customer := range customers {
+			if err := putCustomer(tx, customer); err != nil {
+				return err
+			}
+		}
+
+		for _, passenger := range passengers {
+			if err := putPassenger(tx, passenger); err != nil {
+				return err
+			}
+		}
+
+		if err := putInt(tx, kvNextCustomerIdKey, int64(nextCustomerId)); err != nil {
+			return err
+		}
+		return putInt(tx, kvNextPassengerIdKey, int64(nextPassengerId))
+	})
+}
+
+// Close closes the kv store once the other stores that share it are closed
+func (kvCustomerDB *kvCustomerDB) Close() error {
+	return kvCustomerDB.kvDB.Close()
+}
+
+// Check verifies that the kv store is open
+func (kvCustomerDB *kvCustomerDB) Check() error {
+	return kvCustomerDB.kvDB.Check()
+}
+
+func getCustomer(tx *kv.Tx, id int32) (model.Customer, error) {
+	value := tx.Get(kvKey(kvCustomerPrefix, id))
+	if value == nil {
+		return model.Customer{}, ErrorCustomerNotFound
+	}
+
+	var stored kvCustomer
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return model.Customer{}, err
+	}
+
+	stored.Customer.PasswordHash = stored.PasswordHash
+	return stored.Customer, nil
+}
+
+// putCustomer saves a customer with its password hash and email index
+func putCustomer(tx *kv.Tx, customer model.Customer) error {
+	emailKey := kvCustomerByEmailPrefix + strings.ToLower(customer.Email)
+	if err := tx.Put(emailKey, []byte(strconv.Itoa(int(customer.Id)))); err != nil {
+		return err
+	}
+
+	return putJSON(tx, kvKey(kvCustomerPrefix, customer.Id), kvCustomer{Customer: customer, PasswordHash: customer.PasswordHash})
+}
+
+func getPassenger(tx *kv.Tx, id int32) (model.Passenger, error) {
+	value := tx.Get(kvKey(kvPassengerPrefix, id))
+	if value == nil {
+		return model.Passenger{}, ErrorPassengerNotFound
+	}
+
+	var passenger model.Passenger
+	err := json.Unmarshal(value, &passenger)
+	return passenger, err
+}
+
+func putPassenger(tx *kv.Tx, passenger model.Passenger) error {
+	tx.Put(kvKey(kvPassengerByCustomerPrefix, passenger.CustomerId, passenger.Id), nil)
+	return putJSON(tx, kvKey(kvPassengerPrefix, passenger.Id), passenger)
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestKVCustomerDB_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "packandgo.db")
+
+	kvCustomerDB, err := NewKVCustomerDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ana, _ := kvCustomerDB.AddCustomer(model.Customer{Email: "Ana@example.com", Name: "Ana", PasswordHash: "hash"})
+	if ana.Id != 1 {
+		t.Fatalf("expected customer 1, got %v", ana)
+	}
+	if _, err := kvCustomerDB.AddCustomer(model.Customer{Email: "ana@EXAMPLE.com"}); err != ErrorCustomerExists {
+		t.Fatalf("expected error: %v, got error: %v", ErrorCustomerExists, err)
+	}
+	passenger := kvCustomerDB.AddPassenger(model.Passenger{CustomerId: ana.Id, FirstName: "Ana", LastName: "Smith"})
+	kvCustomerDB.AddSession(model.Session{TokenHash: "token", CustomerId: ana.Id, ExpiresAt: time.Now().Add(time.Hour)})
+	kvCustomerDB.Close()
+
+	kvCustomerDB, err = NewKVCustomerDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer kvCustomerDB.Close()
+
+	// Ids continue after a restart, so bookings are not handed to new customers
+	if bea, _ := kvCustomerDB.AddCustomer(model.Customer{Email: "bea@example.com"}); bea.Id != 2 {
+		t.Fatalf("expected customer 2, got %v", bea)
+	}
+	if customer, err := kvCustomerDB.GetCustomerByEmail("ana@example.com"); err != nil || customer.Id != ana.Id || customer.PasswordHash != "hash" {
+		t.Fatalf("expected customer %v with its password hash, got %v %v", ana.Id, customer, err)
+	}
+	if session, err := kvCustomerDB.GetSession("token"); err != nil || session.CustomerId != ana.Id {
+		t.Fatalf("expected the session of customer %v, got %v %v", ana.Id, session, err)
+	}
+	if passengers := kvCustomerDB.GetPassengers(ana.Id); len(passengers) != 1 || passengers[0] != passenger {
+		t.Fatalf("expected passengers %v, got %v", []model.Passenger{passenger}, passengers)
+	}
+	if next := kvCustomerDB.AddPassenger(model.Passenger{CustomerId: ana.Id}); next.Id != 2 {
+		t.Fatalf("expected passenger 2, got %v", next)
+	}
+
+	updated, err := kvCustomerDB.UpdatePassenger(model.Passenger{Id: passenger.Id, CustomerId: 2, FirstName: "Ana María"})
+	if err != nil || updated.CustomerId != ana.Id {
+		t.Fatalf("expected passenger to keep customer %v, got %v %v", ana.Id, updated, err)
+	}
+	if err := kvCustomerDB.DeletePassenger(passenger.Id); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := kvCustomerDB.GetPassengerById(passenger.Id); err != ErrorPassengerNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorPassengerNotFound, err)
+	}
+}
+
+func TestKVCustomerDB_2(t *testing.T) {
+	kvCustomerDB, _ := NewKVCustomerDB(filepath.Join(t.TempDir(), "packandgo.db"))
+	defer kvCustomerDB.Close()
+
+	now := time.Date(2026, 3, 7, 8, 0, 0, 0, time.UTC)
+	kvCustomerDB.now = func() time.Time { return now }
+	kvCustomerDB.AddSession(model.Session{TokenHash: "token", CustomerId: 1, ExpiresAt: now})
+
+	if _, err := kvCustomerDB.GetSession("token"); err != ErrorSessionNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorSessionNotFound, err)
+	}
+	if err := kvCustomerDB.DeleteSession("token"); err != ErrorSessionNotFound {
+		t.Fatalf("expected the expired session to be deleted, got error: %v", err)
+	}
+}
+
+func TestKVCustomerDB_3(t *testing.T) {
+	kvCustomerDB, _ := NewKVCustomerDB(filepath.Join(t.TempDir(), "packandgo.db"))
+	defer kvCustomerDB.Close()
+
+	kvCustomerDB.AddCustomer(model.Customer{Email: "old@example.com"})
+	kvCustomerDB.AddSession(model.Session{TokenHash: "token", CustomerId: 1, ExpiresAt: time.Now().Add(time.Hour)})
+
+	customers := []model.Customer{{Id: 3, Email: "ana@example.com", PasswordHash: "hash"}}
+	passengers := []model.Passenger{{Id: 5, CustomerId: 3, FirstName: "Ana"}}
+	if err := kvCustomerDB.ImportCustomers(customers, passengers, 4, 6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exportedCustomers, exportedPassengers, nextCustomerId, nextPassengerId, err := kvCustomerDB.ExportCustomers()
+	if err != nil || !reflect.DeepEqual(exportedCustomers, customers) || !reflect.DeepEqual(exportedPassengers, passengers) || nextCustomerId != 4 || nextPassengerId != 6 {
+		t.Fatalf("expected %v %v %v %v, got %v %v %v %v %v", customers, passengers, 4, 6, exportedCustomers, exportedPassengers, nextCustomerId, nextPassengerId, err)
+	}
+	if _, err := kvCustomerDB.GetCustomerByEmail("old@example.com"); err != ErrorCustomerNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorCustomerNotFound, err)
+	}
+	if _, err := kvCustomerDB.GetSession("token"); err != ErrorSessionNotFound {
+		t.Fatalf("expected sessions to be ended, got error: %v", err)
+	}
+}
+
+func TestOpenCustomerStore_1(t *testing.T) {
+	dir := t.TempDir()
+
+	if customerStore, err := OpenCustomerStore("memory://"); err != nil || reflect.TypeOf(customerStore) != reflect.TypeOf(&customerDB{}) {
+		t.Fatalf("expected memory trip stores to keep customers in memory, got %T %v", customerStore, err)
+	}
+
+	bookingStore, _ := OpenBookingStore("kv://" + dir)
+	defer bookingStore.(*kvBookingDB).Close()
+	customerStore, err := OpenCustomerStore("kv://" + dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer customerStore.(*kvCustomerDB).Close()
+
+	customer, _ := customerStore.AddCustomer(model.Customer{Email: "ana@example.com"})
+	bookingStore.AddBooking(model.Booking{CustomerId: customer.Id, TripId: 1, Departure: testDeparture}, 1)
+	if bookings := bookingStore.GetBookingsByCustomer(customer.Id); len(bookings) != 1 {
+		t.Fatalf("expected the booking of customer %v, got %v", customer.Id, bookings)
+	}
+}
+
+func TestOpenCustomerStore_2(t *testing.T) {
+	RegisterBookingDriver("test-bookings-only", func(dsn *url.URL) (BookingStore, error) {
+		return NewBookingDB(), nil
+	})
+
+	if _, err := OpenCustomerStore("test-bookings-only://"); !errors.Is(err, ErrorUnknownDriver) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorUnknownDriver, err)
+	}
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/kv"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestKVDB_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "packandgo.db")
+
+	kvDB, err := NewKVDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	trip, _ := kvDB.AddTrip(newTripWithId)
+	trip.OriginId = 2
+	trip, _ = kvDB.UpdateTrip(trip, 0)
+	kvDB.DeleteTrip(2, 0)
+
+	tests := []struct {
+		find     func(int32) ([]model.Trip, error)
+		cityId   int32
+		expected []int32
+	}{
+		{find: kvDB.GetTripsByOrigin, cityId: 1, expected: []int32{1}},
+		{find: kvDB.GetTripsByOrigin, cityId: 2, expected: []int32{4}},
+		{find: kvDB.GetTripsByOrigin, cityId: 3, expected: []int32{3}},
+		{find: kvDB.GetTripsByDestination, cityId: 1, expected: []int32{}},
+		{find: kvDB.GetTripsByDestination, cityId: 6, expected: []int32{3, 4}},
+	}
+
+	for _, test := range tests {
+		trips, err := test.find(test.cityId)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ids := []int32{}
+		for _, trip := range trips {
+			ids = append(ids, trip.Id)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Fatalf("expected trips %v for city %v, got %v", test.expected, test.cityId, ids)
+		}
+	}
+
+	expected, _ := kvDB.GetAllTrips()
+	kvDB.Close()
+
+	kvDB, err = NewKVDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer kvDB.Close()
+	if trips, _ := kvDB.GetAllTrips(); !reflect.DeepEqual(trips, expected) {
+		t.Fatalf("expected %v, got %v", expected, trips)
+	}
+	if trip, _ := kvDB.AddTrip(newTrip); trip.Id != 5 {
+		t.Fatalf("expected next id 5, got %v", trip.Id)
+	}
+}
+
+func TestKVDB_2(t *testing.T) {
+	dir := t.TempDir()
+
+	tripStore, err := OpenTripStore("kv://" + dir + "?cities=cities_test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cityStore, err := OpenCityStore("kv://" + filepath.Join(dir, "packandgo.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, _ := NewFileDB("cities_test.txt").GetAllCities()
+	if cities, err := cityStore.GetAllCities(); err != nil || !reflect.DeepEqual(cities, expected) {
+		t.Fatalf("expected the cities of the cities file, got %v %v", cities, err)
+	}
+
+	// The trip and city stores share the kv store, so a trip and its city can be added at once
+	failure := errors.New("failure")
+	err = tripStore.(*kvDB).Update(func(tx *kv.Tx) error {
+		putJSON(tx, kvKey(kvCityPrefix, 100), model.City{Id: 100, Name: "Lisboa"})
+		putTrip(tx, model.Trip{Id: 100, OriginId: 100, DestinationId: 1}, model.Trip{})
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("expected error: %v, got error: %v", failure, err)
+	}
+	if _, err := cityStore.GetCityById(100); err != ErrorCityNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorCityNotFound, err)
+	}
+	if trips, _ := tripStore.(*kvDB).GetTripsByOrigin(100); len(trips) != 0 {
+		t.Fatalf("expected the trip of the failed transaction to be rolled back, got %v", trips)
+	}
+
+	tripStore.(*kvDB).Close()
+	if err := cityStore.Check(); err != nil {
+		t.Fatalf("expected the kv store to stay open for the city store, got %v", err)
+	}
+	cityStore.(*kvDB).Close()
+	if err := cityStore.Check(); err == nil {
+		t.Fatalf("expected a closed kv store to fail its health check")
+	}
+}
+
+// The benchmarks compare the kv store with the memory database at 100k trips:
+//
+//	go test ./db -run XXX -bench TripStore
+const benchmarkTrips = 100000
+
+func newBenchmarkTripStores(b *testing.B) map[string]TripStore {
+	memoryDB := NewMemoryDB()
+	for i := len(trips); i < benchmarkTrips; i++ {
+		memoryDB.AddTrip(benchmarkTrip(i))
+	}
+
+	kvDB, err := NewKVDB(filepath.Join(b.TempDir(), "packandgo.db"))
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.Cleanup(func() { kvDB.Close() })
+
+	// A single transaction, as one per trip would spend most of the time syncing the log
+	err = kvDB.Update(func(tx *kv.Tx) error {
+		for i := len(trips); i < benchmarkTrips; i++ {
+			trip := benchmarkTrip(i)
+			trip.Id = int32(i + 1)
+			trip.Version = 1
+			putTrip(tx, trip, model.Trip{})
+		}
+		return putInt(tx, kvNextTripIdKey, benchmarkTrips+1)
+	})
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	return map[string]TripStore{"memory": memoryDB, "kv": kvDB}
+}
+
+func benchmarkTrip(i int) model.Trip {
+	return model.Trip{OriginId: int32(i%100 + 1), DestinationId: int32(i%97 + 1), Dates: "Mon Tue Wed Thu Fri", Price: 32.10}
+}
+
+func BenchmarkTripStore(b *testing.B) {
+	tripStores := newBenchmarkTripStores(b)
+
+	for _, name := range []string{"memory", "kv"} {
+		tripStore := tripStores[name]
+
+		b.Run(fmt.Sprintf("list/%v", name), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if trips, _ := tripStore.GetAllTrips(); len(trips) < benchmarkTrips {
+					b.Fatalf("expected %v trips, got %v", benchmarkTrips, len(trips))
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("get/%v", name), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tripStore.GetTripById(int32(i%benchmarkTrips + 1)); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("insert/%v", name), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tripStore.AddTrip(benchmarkTrip(i))
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"errors"
 	"log"
+	"net/url"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/gbandres98/pack-and-go/model"
 )
@@ -16,22 +20,44 @@ var trips = []model.Trip{
 type memoryDB struct {
 	trips []model.Trip
 	nextId int32
-	writeLock sync.Mutex
+	lock sync.RWMutex
+}
+
+// memory:// trip stores start with the default trips and lose their changes on restarts
+func init() {
+	RegisterTripDriver("memory", func(*url.URL) (TripStore, error) {
+		return NewMemoryDB(), nil
+	})
 }
 
 func NewMemoryDB() *memoryDB {
-	return &memoryDB{trips: trips, nextId: 4}
+	now := time.Now().UTC()
+
+	initialTrips := make([]model.Trip, len(trips))
+	for i, trip := range trips {
+		trip.Version = 1
+		trip.UpdatedAt = now
+		initialTrips[i] = trip
+	}
+
+	return &memoryDB{trips: initialTrips, nextId: 4}
 }
 
-func (memoryDB *memoryDB) GetAllTrips() ([]model.Trip) {
+func (memoryDB *memoryDB) GetAllTrips() ([]model.Trip, error) {
+	memoryDB.lock.RLock()
+	defer memoryDB.lock.RUnlock()
+
 	if (memoryDB.trips == nil) {
 		log.Panicln("non-initialized memory database")
 	}
 
-	return memoryDB.trips
+	return append([]model.Trip{}, memoryDB.trips...), nil
 }
 
 func (memoryDB *memoryDB) GetTripById(id int32) (model.Trip, error) {
+	memoryDB.lock.RLock()
+	defer memoryDB.lock.RUnlock()
+
 	if (memoryDB.trips == nil) {
 		log.Panicln("non-initialized memory database")
 	}
@@ -45,13 +71,91 @@ func (memoryDB *memoryDB) GetTripById(id int32) (model.Trip, error) {
 	return model.Trip{}, ErrorTripNotFound
 }
 
-func (memoryDB *memoryDB) AddTrip(trip model.Trip) model.Trip {
-	memoryDB.writeLock.Lock()
-	defer memoryDB.writeLock.Unlock()
+func (memoryDB *memoryDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	memoryDB.lock.Lock()
+	defer memoryDB.lock.Unlock()
 
 	trip.Id = memoryDB.nextId
+	trip.Version = 1
+	trip.UpdatedAt = time.Now().UTC()
 	memoryDB.nextId++
 
 	memoryDB.trips = append(memoryDB.trips, trip)
-	return trip
-}
\ No newline at end of file
+	return trip, nil
+}
+
+// UpdateTrip replaces the trip with the same id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (memoryDB *memoryDB) UpdateTrip(trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	memoryDB.lock.Lock()
+	defer memoryDB.lock.Unlock()
+
+	for i, current := range memoryDB.trips {
+		if current.Id != trip.Id {
+			continue
+		}
+
+		if expectedVersion != 0 && current.Version != expectedVersion {
+			return model.Trip{}, ErrorVersionMismatch
+		}
+
+		trip.Version = current.Version + 1
+		trip.UpdatedAt = time.Now().UTC()
+		memoryDB.trips[i] = trip
+		return trip, nil
+	}
+
+	return model.Trip{}, ErrorTripNotFound
+}
+
+// DeleteTrip removes the trip with the given id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (memoryDB *memoryDB) DeleteTrip(id int32, expectedVersion int32) (model.Trip, error) {
+	memoryDB.lock.Lock()
+	defer memoryDB.lock.Unlock()
+
+	for i, trip := range memoryDB.trips {
+		if trip.Id != id {
+			continue
+		}
+
+		if expectedVersion != 0 && trip.Version != expectedVersion {
+			return model.Trip{}, ErrorVersionMismatch
+		}
+
+		memoryDB.trips = append(memoryDB.trips[:i], memoryDB.trips[i+1:]...)
+		return trip, nil
+	}
+
+	return model.Trip{}, ErrorTripNotFound
+}
+
+// ExportTrips returns a copy of the trips, by id, and the next id to be given
+func (memoryDB *memoryDB) ExportTrips() ([]model.Trip, int32, error) {
+	memoryDB.lock.RLock()
+	defer memoryDB.lock.RUnlock()
+
+	result := append([]model.Trip{}, memoryDB.trips...)
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+
+	return result, memoryDB.nextId, nil
+}
+
+// ImportTrips replaces every trip and the next id to be given
+func (memoryDB *memoryDB) ImportTrips(trips []model.Trip, nextId int32) error {
+	memoryDB.lock.Lock()
+	defer memoryDB.lock.Unlock()
+
+	memoryDB.trips = append([]model.Trip{}, trips...)
+	memoryDB.nextId = nextId
+	return nil
+}
+
+// Check verifies that the memory database has been initialized
+func (memoryDB *memoryDB) Check() error {
+	if (memoryDB.trips == nil) {
+		return errors.New("non-initialized memory database")
+	}
+
+	return nil
+}
@@ -26,7 +26,7 @@ func TestNewMemoryDB(t *testing.T) {
 func TestGetAllTrips_1(t *testing.T) {
 	memoryDB := memoryDB{ trips: testTrips }
 
-	trips := memoryDB.GetAllTrips()
+	trips, _ := memoryDB.GetAllTrips()
 	if !reflect.DeepEqual(trips, testTrips) {
 		t.Fatalf("expected %v, got %v", testTrips, trips)
 	}
@@ -74,12 +74,12 @@ func TestGetTripById_3(t *testing.T) {
 func TestAddTrip_1(t *testing.T) {
 	memoryDB := memoryDB{ trips: testTrips, nextId: 3 }
 
-	savedTrip := memoryDB.AddTrip(newTrip)
+	savedTrip, _ := memoryDB.AddTrip(newTrip)
 	if savedTrip.Id != 3 {
 		t.Fatalf("expected new trip to have id %v, got id %v", 3, savedTrip.Id)
 	}
 
-	trips := memoryDB.GetAllTrips()
+	trips, _ := memoryDB.GetAllTrips()
 	if len(trips) != 3 {
 		t.Fatalf("expected trip list to have length %v, got %v", 3, len(trips))
 	}
@@ -88,13 +88,100 @@ func TestAddTrip_1(t *testing.T) {
 func TestAddTrip_2(t *testing.T) {
 	memoryDB := memoryDB{ trips: testTrips, nextId: 3 }
 
-	savedTrip := memoryDB.AddTrip(newTripWithId)
+	savedTrip, _ := memoryDB.AddTrip(newTripWithId)
 	if savedTrip.Id != 3 {
 		t.Fatalf("expected new trip to have id %v, got id %v", 3, savedTrip.Id)
 	}
 
-	trips := memoryDB.GetAllTrips()
+	trips, _ := memoryDB.GetAllTrips()
 	if len(trips) != 3 {
 		t.Fatalf("expected trip list to have length %v, got %v", 3, len(trips))
 	}
-}
\ No newline at end of file
+}
+
+func TestUpdateTrip_1(t *testing.T) {
+	memoryDB := NewMemoryDB()
+
+	trip := newTrip
+	trip.Id = 1
+
+	updatedTrip, err := memoryDB.UpdateTrip(trip, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updatedTrip.Version != 2 {
+		t.Fatalf("expected updated trip to have version %v, got %v", 2, updatedTrip.Version)
+	}
+
+	savedTrip, _ := memoryDB.GetTripById(1)
+	if !reflect.DeepEqual(savedTrip, updatedTrip) {
+		t.Fatalf("expected %v, got %v", updatedTrip, savedTrip)
+	}
+}
+
+func TestUpdateTrip_2(t *testing.T) {
+	memoryDB := NewMemoryDB()
+
+	trip := newTrip
+	trip.Id = 1
+
+	_, err := memoryDB.UpdateTrip(trip, 2)
+	if err != ErrorVersionMismatch {
+		t.Fatalf("expected error: %v, got error: %v", ErrorVersionMismatch, err)
+	}
+}
+
+func TestUpdateTrip_3(t *testing.T) {
+	memoryDB := NewMemoryDB()
+
+	trip := newTrip
+	trip.Id = 9
+
+	_, err := memoryDB.UpdateTrip(trip, 0)
+	if err != ErrorTripNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+	}
+}
+
+func TestUpdateTrip_4(t *testing.T) {
+	memoryDB := NewMemoryDB()
+	otherMemoryDB := NewMemoryDB()
+
+	trip := newTrip
+	trip.Id = 1
+	memoryDB.UpdateTrip(trip, 0)
+
+	savedTrip, _ := otherMemoryDB.GetTripById(1)
+	if savedTrip.Version != 1 {
+		t.Fatalf("expected databases not to share trips, got version %v", savedTrip.Version)
+	}
+}
+func TestDeleteTrip_1(t *testing.T) {
+	memoryDB := NewMemoryDB()
+
+	_, err := memoryDB.DeleteTrip(2, 5)
+	if err != ErrorVersionMismatch {
+		t.Fatalf("expected error: %v, got error: %v", ErrorVersionMismatch, err)
+	}
+
+	deleted, err := memoryDB.DeleteTrip(2, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted.Id != 2 {
+		t.Fatalf("expected trip 2 to be deleted, got %v", deleted)
+	}
+
+	_, err = memoryDB.GetTripById(2)
+	if err != ErrorTripNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+	}
+	if trips, _ := memoryDB.GetAllTrips(); len(trips) != 2 {
+		t.Fatalf("expected %v trips left, got %v", 2, len(trips))
+	}
+
+	_, err = memoryDB.DeleteTrip(2, 0)
+	if err != ErrorTripNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+	}
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations of the SQL store schema, named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in order and never reused.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a version of the SQL store schema
+type Migration struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	up        string
+	down      string
+}
+
+// migrations returns the embedded migrations, sorted by version
+func migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		direction := path.Ext(strings.TrimSuffix(fileName, ".sql"))
+		base := strings.TrimSuffix(fileName, direction+".sql")
+
+		separator := strings.Index(base, "_")
+		if separator < 0 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("invalid migration file name %v", fileName)
+		}
+		version, err := strconv.Atoi(base[:separator])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %v: %w", fileName, err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: base[separator+1:]}
+			byVersion[version] = migration
+		}
+		if direction == ".up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	result := []Migration{}
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %v needs both an up and a down file", migration.Version)
+		}
+		result = append(result, *migration)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// LatestSchemaVersion returns the version of the schema this binary expects
+func LatestSchemaVersion() int {
+	all, err := migrations()
+	if err != nil || len(all) == 0 {
+		return 0
+	}
+
+	return all[len(all)-1].Version
+}
+
+// splitStatements splits a migration file into its statements, which end with
+// a semicolon. Lines starting with -- are comments.
+func splitStatements(content string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	statements := []string{}
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+// createMigrationsTable creates the table that records the applied migrations
+func (sqlDB *sqlDB) createMigrationsTable() error {
+	_, err := sqlDB.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at VARCHAR(64) NOT NULL
+	)`)
+
+	return err
+}
+
+// Migrations returns every migration of the binary, and whether and when it
+// has been applied to the database
+func (sqlDB *sqlDB) Migrations() ([]Migration, error) {
+	all, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlDB.createMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := sqlDB.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version], err = time.Parse(time.RFC3339Nano, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of migration %v: %w", version, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := map[int]bool{}
+	for i, migration := range all {
+		known[migration.Version] = true
+		if appliedAt, ok := applied[migration.Version]; ok {
+			all[i].Applied = true
+			all[i].AppliedAt = appliedAt
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: migration %v has been applied, but this binary only knows up to %v", ErrorSchemaTooNew, version, LatestSchemaVersion())
+		}
+	}
+
+	return all, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database
+func (sqlDB *sqlDB) SchemaVersion() (int, error) {
+	all, err := sqlDB.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, migration := range all {
+		if migration.Applied {
+			version = migration.Version
+		}
+	}
+
+	return version, nil
+}
+
+// MigrateUp applies the pending migrations in order, each in a transaction of
+// its own, and returns the ones applied
+func (sqlDB *sqlDB) MigrateUp() ([]Migration, error) {
+	all, err := sqlDB.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range all {
+		if migration.Applied {
+			continue
+		}
+
+		appliedAt := sqlDB.now().UTC()
+		err := sqlDB.migrate(migration.up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", migration.Version, migration.Name, appliedAt.Format(time.RFC3339Nano))
+		if err != nil {
+			return applied, fmt.Errorf("migration %v_%v: %w", migration.Version, migration.Name, err)
+		}
+
+		migration.Applied = true
+		migration.AppliedAt = appliedAt
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, latest first, and
+// returns the ones reverted
+func (sqlDB *sqlDB) MigrateDown(steps int) ([]Migration, error) {
+	all, err := sqlDB.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	for i := len(all) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := all[i]
+		if !migration.Applied {
+			continue
+		}
+
+		err := sqlDB.migrate(migration.down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %v_%v: %w", migration.Version, migration.Name, err)
+		}
+
+		migration.Applied = false
+		migration.AppliedAt = time.Time{}
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// migrate runs the statements of a migration file and records it in
+// schema_migrations, all in one transaction
+func (sqlDB *sqlDB) migrate(content string, record string, args ...interface{}) error {
+	tx, err := sqlDB.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(content) {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(sqlDB.rebind(record), args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,9 @@
+DROP TABLE sequences;
+
+DROP TABLE cities;
+
+DROP INDEX trips_destination_id;
+
+DROP INDEX trips_origin_id;
+
+DROP TABLE trips;
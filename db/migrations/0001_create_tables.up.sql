@@ -0,0 +1,32 @@
+-- Trips are looked up by origin and destination when filtered by city
+CREATE TABLE trips (
+	id INTEGER PRIMARY KEY,
+	origin_id INTEGER NOT NULL,
+	destination_id INTEGER NOT NULL,
+	dates VARCHAR(255) NOT NULL,
+	price DOUBLE PRECISION NOT NULL,
+	version INTEGER NOT NULL,
+	updated_at VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX trips_origin_id ON trips (origin_id);
+
+CREATE INDEX trips_destination_id ON trips (destination_id);
+
+CREATE TABLE cities (
+	id INTEGER PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	country VARCHAR(2) NOT NULL,
+	timezone VARCHAR(64) NOT NULL,
+	address VARCHAR(255) NOT NULL
+);
+
+-- Next id of each table, so the ids of deleted trips are never given again
+CREATE TABLE sequences (
+	name VARCHAR(64) PRIMARY KEY,
+	next_id INTEGER NOT NULL
+);
+
+INSERT INTO sequences (name, next_id) VALUES ('trip', 1), ('city', 1);
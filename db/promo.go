@@ -0,0 +1,222 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type promoDB struct {
+	promoCodes map[string]model.PromoCode
+	// Redemptions of every code by customer
+	customerUses map[string]map[string]int
+	lock         sync.Mutex
+}
+
+func NewPromoDB() *promoDB {
+	return &promoDB{
+		promoCodes:   map[string]model.PromoCode{},
+		customerUses: map[string]map[string]int{},
+	}
+}
+
+// Codes are case insensitive
+func promoKey(code string) string {
+	return strings.ToUpper(code)
+}
+
+func (promoDB *promoDB) GetAllPromoCodes() []model.PromoCode {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	result := make([]model.PromoCode, 0, len(promoDB.promoCodes))
+	for _, promoCode := range promoDB.promoCodes {
+		result = append(result, promoCode)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+
+	return result
+}
+
+func (promoDB *promoDB) GetPromoCode(code string) (model.PromoCode, error) {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	promoCode, ok := promoDB.promoCodes[promoKey(code)]
+	if !ok {
+		return model.PromoCode{}, ErrorPromoCodeNotFound
+	}
+
+	return promoCode, nil
+}
+
+func (promoDB *promoDB) AddPromoCode(promoCode model.PromoCode) (model.PromoCode, error) {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	key := promoKey(promoCode.Code)
+	if _, ok := promoDB.promoCodes[key]; ok {
+		return model.PromoCode{}, ErrorPromoCodeExists
+	}
+
+	promoCode.Uses = 0
+	promoDB.promoCodes[key] = promoCode
+	return promoCode, nil
+}
+
+// UpdatePromoCode replaces the code with the same name, keeping its redemptions
+func (promoDB *promoDB) UpdatePromoCode(promoCode model.PromoCode) (model.PromoCode, error) {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	key := promoKey(promoCode.Code)
+	current, ok := promoDB.promoCodes[key]
+	if !ok {
+		return model.PromoCode{}, ErrorPromoCodeNotFound
+	}
+
+	promoCode.Code = current.Code
+	promoCode.Uses = current.Uses
+	promoDB.promoCodes[key] = promoCode
+	return promoCode, nil
+}
+
+func (promoDB *promoDB) DeletePromoCode(code string) error {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	key := promoKey(code)
+	if _, ok := promoDB.promoCodes[key]; !ok {
+		return ErrorPromoCodeNotFound
+	}
+
+	delete(promoDB.promoCodes, key)
+	delete(promoDB.customerUses, key)
+	return nil
+}
+
+// GetPromoCodeUses returns the times a customer has redeemed a code
+func (promoDB *promoDB) GetPromoCodeUses(code string, customer string) int {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	return promoDB.customerUses[promoKey(code)][customer]
+}
+
+// RedeemPromoCode counts a use of a code by customer, as long as check accepts
+// the current code and its usage caps have not been reached. The check, the
+// caps and the count are done atomically, so concurrent redemptions can not
+// go over the caps.
+func (promoDB *promoDB) RedeemPromoCode(code string, customer string, check func(model.PromoCode) error) (model.PromoCode, error) {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	key := promoKey(code)
+	promoCode, ok := promoDB.promoCodes[key]
+	if !ok {
+		return model.PromoCode{}, ErrorPromoCodeNotFound
+	}
+
+	if check != nil {
+		if err := check(promoCode); err != nil {
+			return model.PromoCode{}, err
+		}
+	}
+
+	if promoCode.MaxUses > 0 && promoCode.Uses >= promoCode.MaxUses {
+		return model.PromoCode{}, ErrorPromoCodeExhausted
+	}
+
+	customerUses := promoDB.customerUses[key]
+	if customerUses == nil {
+		customerUses = map[string]int{}
+		promoDB.customerUses[key] = customerUses
+	}
+	if promoCode.MaxUsesPerCustomer > 0 && customerUses[customer] >= promoCode.MaxUsesPerCustomer {
+		return model.PromoCode{}, ErrorPromoCodeCustomerLimit
+	}
+
+	promoCode.Uses++
+	customerUses[customer]++
+	promoDB.promoCodes[key] = promoCode
+	return promoCode, nil
+}
+
+// ReleasePromoCode takes back a use of a code by customer, for redemptions
+// whose booking could not be made
+func (promoDB *promoDB) ReleasePromoCode(code string, customer string) error {
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	key := promoKey(code)
+	promoCode, ok := promoDB.promoCodes[key]
+	if !ok {
+		return ErrorPromoCodeNotFound
+	}
+	if promoDB.customerUses[key][customer] == 0 {
+		return fmt.Errorf("%v has not been redeemed by %v", promoCode.Code, customer)
+	}
+
+	promoCode.Uses--
+	promoDB.customerUses[key][customer]--
+	promoDB.promoCodes[key] = promoCode
+	return nil
+}
+
+// ExportPromoCodes returns the codes, by code, and how many times each
+// customer has redeemed them, by code and customer
+func (promoDB *promoDB) ExportPromoCodes() ([]model.PromoCode, []model.PromoRedemption, error) {
+	promoCodes := promoDB.GetAllPromoCodes()
+
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	redemptions := []model.PromoRedemption{}
+	for _, promoCode := range promoCodes {
+		for customer, uses := range promoDB.customerUses[promoKey(promoCode.Code)] {
+			redemptions = append(redemptions, model.PromoRedemption{Code: promoCode.Code, Customer: customer, Uses: uses})
+		}
+	}
+
+	sort.Slice(redemptions, func(i, j int) bool {
+		if redemptions[i].Code != redemptions[j].Code {
+			return redemptions[i].Code < redemptions[j].Code
+		}
+		return redemptions[i].Customer < redemptions[j].Customer
+	})
+
+	return promoCodes, redemptions, nil
+}
+
+// ImportPromoCodes replaces the codes and their redemptions
+func (promoDB *promoDB) ImportPromoCodes(promoCodes []model.PromoCode, redemptions []model.PromoRedemption) error {
+	codes := map[string]model.PromoCode{}
+	for _, promoCode := range promoCodes {
+		codes[promoKey(promoCode.Code)] = promoCode
+	}
+
+	customerUses := map[string]map[string]int{}
+	for _, redemption := range redemptions {
+		key := promoKey(redemption.Code)
+		if _, ok := codes[key]; !ok {
+			return fmt.Errorf("%w: redemption of %v", ErrorPromoCodeNotFound, redemption.Code)
+		}
+		if customerUses[key] == nil {
+			customerUses[key] = map[string]int{}
+		}
+		customerUses[key][redemption.Customer] = redemption.Uses
+	}
+
+	promoDB.lock.Lock()
+	defer promoDB.lock.Unlock()
+
+	promoDB.promoCodes = codes
+	promoDB.customerUses = customerUses
+	return nil
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var testPromoCode = model.PromoCode{Code: "SUMMER10", Percent: 10, MaxUses: 5, MaxUsesPerCustomer: 2}
+
+func TestAddPromoCode_1(t *testing.T) {
+	promoDB := NewPromoDB()
+
+	_, err := promoDB.AddPromoCode(testPromoCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	promoCode, err := promoDB.GetPromoCode("summer10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if promoCode.Code != "SUMMER10" {
+		t.Fatalf("expected %v, got %v", "SUMMER10", promoCode.Code)
+	}
+
+	_, err = promoDB.AddPromoCode(testPromoCode)
+	if err != ErrorPromoCodeExists {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeExists, err)
+	}
+}
+
+func TestUpdatePromoCode_1(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(testPromoCode)
+	promoDB.RedeemPromoCode("SUMMER10", "alice", nil)
+
+	updated := testPromoCode
+	updated.Percent = 15
+	updated.Uses = 100
+
+	promoCode, err := promoDB.UpdatePromoCode(updated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if promoCode.Percent != 15 || promoCode.Uses != 1 {
+		t.Fatalf("expected percent %v and uses %v, got %v and %v", 15, 1, promoCode.Percent, promoCode.Uses)
+	}
+
+	_, err = promoDB.UpdatePromoCode(model.PromoCode{Code: "WINTER"})
+	if err != ErrorPromoCodeNotFound {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotFound, err)
+	}
+}
+
+func TestDeletePromoCode_1(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(testPromoCode)
+
+	err := promoDB.DeletePromoCode("SUMMER10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = promoDB.DeletePromoCode("SUMMER10")
+	if err != ErrorPromoCodeNotFound {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotFound, err)
+	}
+}
+
+func TestRedeemPromoCode_1(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(testPromoCode)
+
+	for i := 0; i < 2; i++ {
+		_, err := promoDB.RedeemPromoCode("SUMMER10", "alice", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	_, err := promoDB.RedeemPromoCode("SUMMER10", "alice", nil)
+	if err != ErrorPromoCodeCustomerLimit {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeCustomerLimit, err)
+	}
+	if uses := promoDB.GetPromoCodeUses("SUMMER10", "alice"); uses != 2 {
+		t.Fatalf("expected %v uses, got %v", 2, uses)
+	}
+}
+
+func TestRedeemPromoCode_2(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(testPromoCode)
+	checkError := errors.New("test error")
+
+	_, err := promoDB.RedeemPromoCode("SUMMER10", "alice", func(model.PromoCode) error { return checkError })
+	if err != checkError {
+		t.Fatalf("expected %v, got %v", checkError, err)
+	}
+
+	promoCode, _ := promoDB.GetPromoCode("SUMMER10")
+	if promoCode.Uses != 0 {
+		t.Fatalf("expected rejected redemption not to be counted, got %v uses", promoCode.Uses)
+	}
+}
+
+func TestRedeemPromoCode_3(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(model.PromoCode{Code: "FLASH", Amount: 5, MaxUses: 10})
+
+	var wait sync.WaitGroup
+	var lock sync.Mutex
+	redeemed := 0
+	exhausted := 0
+
+	for i := 0; i < 100; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+
+			_, err := promoDB.RedeemPromoCode("FLASH", string(rune('a'+i%26)), nil)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err == nil {
+				redeemed++
+			} else if err == ErrorPromoCodeExhausted {
+				exhausted++
+			}
+		}(i)
+	}
+	wait.Wait()
+
+	if redeemed != 10 || exhausted != 90 {
+		t.Fatalf("expected %v redeemed and %v exhausted, got %v and %v", 10, 90, redeemed, exhausted)
+	}
+
+	promoCode, _ := promoDB.GetPromoCode("FLASH")
+	if promoCode.Uses != 10 {
+		t.Fatalf("expected %v uses, got %v", 10, promoCode.Uses)
+	}
+}
+
+func TestReleasePromoCode_1(t *testing.T) {
+	promoDB := NewPromoDB()
+	promoDB.AddPromoCode(testPromoCode)
+	promoDB.RedeemPromoCode("SUMMER10", "alice", nil)
+
+	if err := promoDB.ReleasePromoCode("summer10", "alice"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	promoCode, _ := promoDB.GetPromoCode("SUMMER10")
+	if promoCode.Uses != 0 || promoDB.GetPromoCodeUses("SUMMER10", "alice") != 0 {
+		t.Fatalf("expected the use to be released, got %v uses", promoCode.Uses)
+	}
+
+	if err := promoDB.ReleasePromoCode("SUMMER10", "alice"); err == nil {
+		t.Fatalf("expected a code that was not redeemed not to be released")
+	}
+	if err := promoDB.ReleasePromoCode("WINTER", "alice"); err != ErrorPromoCodeNotFound {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotFound, err)
+	}
+}
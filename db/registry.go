@@ -0,0 +1,296 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var ErrorUnknownDriver = errors.New("unknown storage driver")
+
+// TripStore is implemented by every trip database, all of which behave like
+// memoryDB, except that SQL stores start without trips. Their trips can be
+// exported and replaced along with the next id, for backups.
+type TripStore interface {
+	GetAllTrips() ([]model.Trip, error)
+	GetTripById(int32) (model.Trip, error)
+	AddTrip(model.Trip) (model.Trip, error)
+	UpdateTrip(model.Trip, int32) (model.Trip, error)
+	DeleteTrip(int32, int32) (model.Trip, error)
+	ExportTrips() ([]model.Trip, int32, error)
+	ImportTrips([]model.Trip, int32) error
+	Check() error
+}
+
+// CityStore is implemented by every city database, all of which give cities
+// consecutive ids from 1 in the order they were added. Their cities can be
+// replaced for restores.
+type CityStore interface {
+	GetAllCities() ([]model.City, error)
+	GetCityById(int32) (model.City, error)
+	AddCity(model.City) (model.City, error)
+	UpdateCity(model.City) (model.City, error)
+	ImportCities([]model.City) error
+	Check() error
+}
+
+// BookingStore is implemented by every booking database. Their bookings and
+// ledger can be exported and replaced along with their next ids, for backups.
+type BookingStore interface {
+	AddBooking(model.Booking, int) (model.Booking, error)
+	UpdateBooking(model.Booking) (model.Booking, error)
+	DeleteBooking(int32) error
+	CancelBooking(int32, time.Time, func(model.Booking) model.LedgerEntry) (model.Booking, model.LedgerEntry, error)
+	GetLedgerEntries(int32) []model.LedgerEntry
+	GetBookingById(int32) (model.Booking, error)
+	GetBookingsByCustomer(int32) []model.Booking
+	GetBookingsByDeparture(model.DepartureKey) []model.Booking
+	SeatsTaken(model.DepartureKey) int
+	ExportBookings() ([]model.Booking, []model.LedgerEntry, int32, int32, error)
+	ImportBookings([]model.Booking, []model.LedgerEntry, int32, int32) error
+	Check() error
+}
+
+// CustomerStore is implemented by every customer database. Their customers and
+// passengers can be exported and replaced along with their next ids, for backups.
+type CustomerStore interface {
+	AddCustomer(model.Customer) (model.Customer, error)
+	GetCustomerById(int32) (model.Customer, error)
+	GetCustomerByEmail(string) (model.Customer, error)
+	AddSession(model.Session) error
+	GetSession(string) (model.Session, error)
+	DeleteSession(string) error
+	GetPassengers(int32) []model.Passenger
+	GetPassengerById(int32) (model.Passenger, error)
+	AddPassenger(model.Passenger) model.Passenger
+	UpdatePassenger(model.Passenger) (model.Passenger, error)
+	DeletePassenger(int32) error
+	ExportCustomers() ([]model.Customer, []model.Passenger, int32, int32, error)
+	ImportCustomers([]model.Customer, []model.Passenger, int32, int32) error
+	Check() error
+}
+
+// TripDriver opens the trip store of a DSN, such as memory:// or journal:///var/lib/packandgo
+type TripDriver func(dsn *url.URL) (TripStore, error)
+
+// CityDriver opens the city store of a DSN, such as file:///var/lib/packandgo
+type CityDriver func(dsn *url.URL) (CityStore, error)
+
+// BookingDriver opens the booking store kept along with the trip store of a DSN
+type BookingDriver func(dsn *url.URL) (BookingStore, error)
+
+// CustomerDriver opens the customer store kept along with the bookings of a DSN
+type CustomerDriver func(dsn *url.URL) (CustomerStore, error)
+
+var (
+	drivers         sync.RWMutex
+	tripDrivers     = map[string]TripDriver{}
+	cityDrivers     = map[string]CityDriver{}
+	bookingDrivers  = map[string]BookingDriver{}
+	customerDrivers = map[string]CustomerDriver{}
+)
+
+// RegisterTripDriver makes a trip store available under a DSN scheme. It
+// panics if the scheme is already taken.
+func RegisterTripDriver(scheme string, driver TripDriver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+
+	if _, ok := tripDrivers[scheme]; ok {
+		panic(fmt.Sprintf("trip driver already registered for scheme %v", scheme))
+	}
+	tripDrivers[scheme] = driver
+}
+
+// RegisterCityDriver makes a city store available under a DSN scheme. It
+// panics if the scheme is already taken.
+func RegisterCityDriver(scheme string, driver CityDriver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+
+	if _, ok := cityDrivers[scheme]; ok {
+		panic(fmt.Sprintf("city driver already registered for scheme %v", scheme))
+	}
+	cityDrivers[scheme] = driver
+}
+
+// RegisterBookingDriver makes trip stores of a DSN scheme keep bookings too.
+// It panics if the scheme is already taken.
+func RegisterBookingDriver(scheme string, driver BookingDriver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+
+	if _, ok := bookingDrivers[scheme]; ok {
+		panic(fmt.Sprintf("booking driver already registered for scheme %v", scheme))
+	}
+	bookingDrivers[scheme] = driver
+}
+
+// RegisterCustomerDriver makes trip stores of a DSN scheme keep customers too.
+// It panics if the scheme is already taken.
+func RegisterCustomerDriver(scheme string, driver CustomerDriver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+
+	if _, ok := customerDrivers[scheme]; ok {
+		panic(fmt.Sprintf("customer driver already registered for scheme %v", scheme))
+	}
+	customerDrivers[scheme] = driver
+}
+
+// baseScheme returns the scheme before a +, so sql+postgres:// DSNs are opened by the sql driver
+func baseScheme(scheme string) string {
+	if i := strings.Index(scheme, "+"); i >= 0 {
+		return scheme[:i]
+	}
+
+	return scheme
+}
+
+// OpenTripStore opens a trip store with the driver registered for the scheme
+// of dsn, or for the part of the scheme before a + if there is none
+func OpenTripStore(dsn string) (TripStore, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trip store %q: %w", dsn, err)
+	}
+
+	drivers.RLock()
+	driver, ok := tripDrivers[parsed.Scheme]
+	if !ok {
+		driver, ok = tripDrivers[baseScheme(parsed.Scheme)]
+	}
+	drivers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for trips: %q, expected one of %v", ErrorUnknownDriver, parsed.Scheme, TripDrivers())
+	}
+
+	return driver(parsed)
+}
+
+// OpenCityStore opens a city store with the driver registered for the scheme
+// of dsn, or for the part of the scheme before a + if there is none
+func OpenCityStore(dsn string) (CityStore, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid city store %q: %w", dsn, err)
+	}
+
+	drivers.RLock()
+	driver, ok := cityDrivers[parsed.Scheme]
+	if !ok {
+		driver, ok = cityDrivers[baseScheme(parsed.Scheme)]
+	}
+	drivers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for cities: %q, expected one of %v", ErrorUnknownDriver, parsed.Scheme, CityDrivers())
+	}
+
+	return driver(parsed)
+}
+
+// OpenBookingStore opens the booking store kept along with the trip store of
+// dsn. Bookings are kept in memory for schemes without a booking driver.
+func OpenBookingStore(dsn string) (BookingStore, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trip store %q: %w", dsn, err)
+	}
+
+	drivers.RLock()
+	driver, ok := bookingDrivers[parsed.Scheme]
+	if !ok {
+		driver, ok = bookingDrivers[baseScheme(parsed.Scheme)]
+	}
+	drivers.RUnlock()
+	if !ok {
+		return NewBookingDB(), nil
+	}
+
+	return driver(parsed)
+}
+
+// OpenCustomerStore opens the customer store kept along with the bookings of
+// dsn. Customers are kept in memory for schemes without a customer driver,
+// unless their bookings are not, as a restart would hand the bookings of a
+// forgotten customer to the next one given the same id.
+func OpenCustomerStore(dsn string) (CustomerStore, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trip store %q: %w", dsn, err)
+	}
+
+	drivers.RLock()
+	driver, ok := customerDrivers[parsed.Scheme]
+	if !ok {
+		driver, ok = customerDrivers[baseScheme(parsed.Scheme)]
+	}
+	_, persistentBookings := bookingDrivers[parsed.Scheme]
+	if !persistentBookings {
+		_, persistentBookings = bookingDrivers[baseScheme(parsed.Scheme)]
+	}
+	drivers.RUnlock()
+	if !ok && persistentBookings {
+		return nil, fmt.Errorf("%w for customers: %q keeps bookings but not their customers", ErrorUnknownDriver, parsed.Scheme)
+	}
+	if !ok {
+		return NewCustomerDB(), nil
+	}
+
+	return driver(parsed)
+}
+
+// TripDrivers returns the schemes of the registered trip drivers, sorted
+func TripDrivers() []string {
+	drivers.RLock()
+	defer drivers.RUnlock()
+
+	schemes := []string{}
+	for scheme := range tripDrivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// CityDrivers returns the schemes of the registered city drivers, sorted
+func CityDrivers() []string {
+	drivers.RLock()
+	defer drivers.RUnlock()
+
+	schemes := []string{}
+	for scheme := range cityDrivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// dsnPath returns the file path of a DSN. Both file:///var/lib/packandgo and
+// relative paths such as file://data/cities.txt or file:cities.txt are
+// accepted. If the path is a directory, fileName inside it is returned.
+func dsnPath(dsn *url.URL, fileName string) string {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return ""
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, fileName)
+	}
+
+	return path
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenTripStore_1(t *testing.T) {
+	tests := []string{"postgres://localhost/trips", "trips.log", "%"}
+
+	for _, dsn := range tests {
+		if _, err := OpenTripStore(dsn); err == nil {
+			t.Fatalf("expected an error opening %q", dsn)
+		}
+	}
+
+	_, err := OpenCityStore("postgres://localhost/cities")
+	if !errors.Is(err, ErrorUnknownDriver) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorUnknownDriver, err)
+	}
+}
+
+func TestRegisterTripDriver_1(t *testing.T) {
+	defer func() { recover() }()
+
+	RegisterTripDriver("memory", nil)
+
+	t.Fatalf("expected RegisterTripDriver() to panic on a taken scheme")
+}
+
+func TestDsnPath_1(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		dsn      string
+		expected string
+	}{
+		{dsn: "file://cities.txt", expected: "cities.txt"},
+		{dsn: "file:cities.txt", expected: "cities.txt"},
+		{dsn: "file://data/cities.txt", expected: "data/cities.txt"},
+		{dsn: "file:///var/lib/cities.txt", expected: "/var/lib/cities.txt"},
+		{dsn: "file://" + dir, expected: filepath.Join(dir, "cities.txt")},
+		{dsn: "journal://", expected: ""},
+	}
+
+	for _, test := range tests {
+		dsn, _ := url.Parse(test.dsn)
+		if path := dsnPath(dsn, "cities.txt"); path != test.expected {
+			t.Fatalf("expected %v for %v, got %v", test.expected, test.dsn, path)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const tripColumns = "id, origin_id, destination_id, dates, price, version, updated_at"
+
+const cityColumns = "id, name, latitude, longitude, country, timezone, address"
+
+// sqlDB keeps trips and cities in a relational database through database/sql.
+// Its schema is created and upgraded by the embedded migrations.
+type sqlDB struct {
+	db         *sql.DB
+	driverName string
+	now        func() time.Time
+}
+
+// sql+<driver>:// DSNs keep trips and cities in a database of a database/sql
+// driver, which has to be imported by the binary. The data source given to the
+// driver is the DSN with sql+ removed, as in sql+postgres://user@host/packandgo,
+// or the part after the colon, as in sql+mysql:user@tcp(host)/packandgo. The
+// schema has to be up to date, see pack-and-go migrate.
+func init() {
+	RegisterTripDriver("sql", func(dsn *url.URL) (TripStore, error) {
+		return openSQLStore(dsn)
+	})
+	RegisterCityDriver("sql", func(dsn *url.URL) (CityStore, error) {
+		return openSQLStore(dsn)
+	})
+}
+
+func openSQLStore(dsn *url.URL) (*sqlDB, error) {
+	sqlDB, err := openSQLDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := sqlDB.SchemaVersion()
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if latest := LatestSchemaVersion(); version < latest {
+		sqlDB.Close()
+		return nil, fmt.Errorf("%w: schema is at version %v, expected %v, run pack-and-go migrate up", ErrorSchemaOutdated, version, latest)
+	}
+
+	return sqlDB, nil
+}
+
+// OpenSQLDB connects to the database of a sql+<driver> DSN, without checking its schema
+func OpenSQLDB(dsn string) (*sqlDB, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQL store %q: %w", dsn, err)
+	}
+
+	return openSQLDB(parsed)
+}
+
+func openSQLDB(dsn *url.URL) (*sqlDB, error) {
+	if !strings.HasPrefix(dsn.Scheme, "sql+") {
+		return nil, fmt.Errorf("SQL store needs a driver, such as sql+postgres://localhost/packandgo")
+	}
+
+	driverName := strings.TrimPrefix(dsn.Scheme, "sql+")
+	dataSource := dsn.Opaque
+	if dataSource == "" {
+		withoutPrefix := *dsn
+		withoutPrefix.Scheme = driverName
+		dataSource = withoutPrefix.String()
+	}
+
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqlDB{db: db, driverName: driverName, now: time.Now}, nil
+}
+
+// rebind replaces the ? placeholders of a query with $1, $2... for the drivers that need them
+func (sqlDB *sqlDB) rebind(query string) string {
+	if sqlDB.driverName != "postgres" && sqlDB.driverName != "pgx" {
+		return query
+	}
+
+	var builder strings.Builder
+	count := 0
+	for _, r := range query {
+		if r != '?' {
+			builder.WriteRune(r)
+			continue
+		}
+		count++
+		builder.WriteString("$" + strconv.Itoa(count))
+	}
+
+	return builder.String()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTrip(row scanner) (model.Trip, error) {
+	var trip model.Trip
+	var updatedAt string
+
+	err := row.Scan(&trip.Id, &trip.OriginId, &trip.DestinationId, &trip.Dates, &trip.Price, &trip.Version, &updatedAt)
+	if err == sql.ErrNoRows {
+		return model.Trip{}, ErrorTripNotFound
+	}
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	trip.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
+	return trip, err
+}
+
+func (sqlDB *sqlDB) queryTrips(query string, args ...interface{}) ([]model.Trip, error) {
+	rows, err := sqlDB.db.Query(sqlDB.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []model.Trip{}
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, trip)
+	}
+
+	return result, rows.Err()
+}
+
+func (sqlDB *sqlDB) GetAllTrips() ([]model.Trip, error) {
+	return sqlDB.queryTrips("SELECT " + tripColumns + " FROM trips ORDER BY id")
+}
+
+func (sqlDB *sqlDB) GetTripById(id int32) (model.Trip, error) {
+	return scanTrip(sqlDB.db.QueryRow(sqlDB.rebind("SELECT "+tripColumns+" FROM trips WHERE id = ?"), id))
+}
+
+// QueryTrips returns a page of the trips selected by the query, filtered,
+// sorted and limited by the database
+func (sqlDB *sqlDB) QueryTrips(query model.TripQuery) ([]model.Trip, error) {
+	statement := "SELECT " + tripColumns + " FROM trips WHERE id > ?"
+	args := []interface{}{query.AfterId}
+
+	if query.OriginId != 0 {
+		statement += " AND origin_id = ?"
+		args = append(args, query.OriginId)
+	}
+	if query.DestinationId != 0 {
+		statement += " AND destination_id = ?"
+		args = append(args, query.DestinationId)
+	}
+	statement += " ORDER BY id"
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	return sqlDB.queryTrips(statement, args...)
+}
+
+func (sqlDB *sqlDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	err := sqlDB.inTx(func(tx *sql.Tx) error {
+		id, err := sqlDB.nextId(tx, "trip")
+		if err != nil {
+			return err
+		}
+
+		trip.Id = id
+		trip.Version = 1
+		trip.UpdatedAt = sqlDB.now().UTC()
+
+		_, err = tx.Exec(sqlDB.rebind("INSERT INTO trips ("+tripColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			trip.Id, trip.OriginId, trip.DestinationId, trip.Dates, trip.Price, trip.Version, trip.UpdatedAt.Format(time.RFC3339Nano))
+		return err
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// UpdateTrip replaces the trip with the same id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (sqlDB *sqlDB) UpdateTrip(trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	err := sqlDB.inTx(func(tx *sql.Tx) error {
+		current, err := scanTrip(tx.QueryRow(sqlDB.rebind("SELECT "+tripColumns+" FROM trips WHERE id = ?"), trip.Id))
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && current.Version != expectedVersion {
+			return ErrorVersionMismatch
+		}
+
+		trip.Version = current.Version + 1
+		trip.UpdatedAt = sqlDB.now().UTC()
+
+		// The version is checked again by the update, in case the trip changed since it was read
+		result, err := tx.Exec(sqlDB.rebind("UPDATE trips SET origin_id = ?, destination_id = ?, dates = ?, price = ?, version = ?, updated_at = ? WHERE id = ? AND version = ?"),
+			trip.OriginId, trip.DestinationId, trip.Dates, trip.Price, trip.Version, trip.UpdatedAt.Format(time.RFC3339Nano), trip.Id, current.Version)
+		if err != nil {
+			return err
+		}
+
+		return checkAffected(result)
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// DeleteTrip removes the trip with the given id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (sqlDB *sqlDB) DeleteTrip(id int32, expectedVersion int32) (model.Trip, error) {
+	var trip model.Trip
+
+	err := sqlDB.inTx(func(tx *sql.Tx) error {
+		var err error
+		trip, err = scanTrip(tx.QueryRow(sqlDB.rebind("SELECT "+tripColumns+" FROM trips WHERE id = ?"), id))
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && trip.Version != expectedVersion {
+			return ErrorVersionMismatch
+		}
+
+		result, err := tx.Exec(sqlDB.rebind("DELETE FROM trips WHERE id = ? AND version = ?"), id, trip.Version)
+		if err != nil {
+			return err
+		}
+
+		return checkAffected(result)
+	})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// checkAffected returns ErrorVersionMismatch if a write of a trip at the
+// version it was read at found no trip, as it was changed in between
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrorVersionMismatch
+	}
+
+	return nil
+}
+
+// ExportTrips returns the trips, by id, and the next id to be given, read in one transaction
+func (sqlDB *sqlDB) ExportTrips() ([]model.Trip, int32, error) {
+	var result []model.Trip
+	var nextId int32
+
+	err := sqlDB.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT " + tripColumns + " FROM trips ORDER BY id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		result = []model.Trip{}
+		for rows.Next() {
+			trip, err := scanTrip(rows)
+			if err != nil {
+				return err
+			}
+			result = append(result, trip)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(sqlDB.rebind("SELECT next_id FROM sequences WHERE name = ?"), "trip").Scan(&nextId)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, nextId, nil
+}
+
+// ImportTrips replaces every trip and the next id to be given, in one transaction
+func (sqlDB *sqlDB) ImportTrips(trips []model.Trip, nextId int32) error {
+	return sqlDB.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM trips"); err != nil {
+			return err
+		}
+
+		for _, trip := range trips {
+			_, err := tx.Exec(sqlDB.rebind("INSERT INTO trips ("+tripColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+				trip.Id, trip.OriginId, trip.DestinationId, trip.Dates, trip.Price, trip.Version, trip.UpdatedAt.UTC().Format(time.RFC3339Nano))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(sqlDB.rebind("UPDATE sequences SET next_id = ? WHERE name = ?"), nextId, "trip")
+		return err
+	})
+}
+
+func scanCity(row scanner) (model.City, error) {
+	var city model.City
+
+	err := row.Scan(&city.Id, &city.Name, &city.Latitude, &city.Longitude, &city.Country, &city.Timezone, &city.Address)
+	if err == sql.ErrNoRows {
+		return model.City{}, ErrorCityNotFound
+	}
+
+	return city, err
+}
+
+func (sqlDB *sqlDB) GetAllCities() ([]model.City, error) {
+	rows, err := sqlDB.db.Query("SELECT " + cityColumns + " FROM cities ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []model.City{}
+	for rows.Next() {
+		city, err := scanCity(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, city)
+	}
+
+	return result, rows.Err()
+}
+
+func (sqlDB *sqlDB) GetCityById(id int32) (model.City, error) {
+	return scanCity(sqlDB.db.QueryRow(sqlDB.rebind("SELECT "+cityColumns+" FROM cities WHERE id = ?"), id))
+}
+
+// AddCity gives the city the next id, as cities are never removed
+func (sqlDB *sqlDB) AddCity(city model.City) (model.City, error) {
+	err := sqlDB.inTx(func(tx *sql.Tx) error {
+		id, err := sqlDB.nextId(tx, "city")
+		if err != nil {
+			return err
+		}
+
+		city.Id = id
+		_, err = tx.Exec(sqlDB.rebind("INSERT INTO cities ("+cityColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			city.Id, city.Name, city.Latitude, city.Longitude, city.Country, city.Timezone, city.Address)
+		return err
+	})
+	if err != nil {
+		return model.City{}, err
+	}
+
+	return city, nil
+}
+
+// UpdateCity replaces the city with the same id
+func (sqlDB *sqlDB) UpdateCity(city model.City) (model.City, error) {
+	result, err := sqlDB.db.Exec(sqlDB.rebind("UPDATE cities SET name = ?, latitude = ?, longitude = ?, country = ?, timezone = ?, address = ? WHERE id = ?"),
+		city.Name, city.Latitude, city.Longitude, city.Country, city.Timezone, city.Address, city.Id)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return model.City{}, err
+	}
+	if affected == 0 {
+		return model.City{}, ErrorCityNotFound
+	}
+
+	return city, nil
+}
+
+// ImportCities replaces every city, in one transaction
+func (sqlDB *sqlDB) ImportCities(cities []model.City) error {
+	return sqlDB.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM cities"); err != nil {
+			return err
+		}
+
+		for _, city := range cities {
+			_, err := tx.Exec(sqlDB.rebind("INSERT INTO cities ("+cityColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+				city.Id, city.Name, city.Latitude, city.Longitude, city.Country, city.Timezone, city.Address)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(sqlDB.rebind("UPDATE sequences SET next_id = ? WHERE name = ?"), len(cities)+1, "city")
+		return err
+	})
+}
+
+// nextId takes the next id of a sequence. The sequence is incremented before
+// it is read, so concurrent transactions wait on its row instead of reading
+// the same id.
+func (sqlDB *sqlDB) nextId(tx *sql.Tx, sequence string) (int32, error) {
+	_, err := tx.Exec(sqlDB.rebind("UPDATE sequences SET next_id = next_id + 1 WHERE name = ?"), sequence)
+	if err != nil {
+		return 0, fmt.Errorf("sequence %v: %w", sequence, err)
+	}
+
+	var nextId int32
+	err = tx.QueryRow(sqlDB.rebind("SELECT next_id FROM sequences WHERE name = ?"), sequence).Scan(&nextId)
+	if err != nil {
+		return 0, fmt.Errorf("sequence %v: %w", sequence, err)
+	}
+
+	return nextId - 1, nil
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back otherwise
+func (sqlDB *sqlDB) inTx(fn func(*sql.Tx) error) error {
+	tx, err := sqlDB.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (sqlDB *sqlDB) Close() error {
+	return sqlDB.db.Close()
+}
+
+// Check verifies that the database can be reached
+func (sqlDB *sqlDB) Check() error {
+	return sqlDB.db.Ping()
+}
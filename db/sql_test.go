@@ -0,0 +1,92 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestMigrate_1(t *testing.T) {
+	dsn := "sql+sqlmem:" + t.Name()
+	sqlDB, err := OpenSQLDB(dsn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sqlDB.Close()
+
+	if _, err := OpenTripStore(dsn); !errors.Is(err, ErrorSchemaOutdated) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorSchemaOutdated, err)
+	}
+
+	applied, err := sqlDB.MigrateUp()
+	if err != nil || len(applied) != LatestSchemaVersion() || applied[0].Name != "create_tables" {
+		t.Fatalf("expected every migration to be applied, got %v %v", applied, err)
+	}
+	if applied, _ := sqlDB.MigrateUp(); len(applied) != 0 {
+		t.Fatalf("expected no migration to be pending, got %v", applied)
+	}
+
+	reverted, err := sqlDB.MigrateDown(1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != LatestSchemaVersion() {
+		t.Fatalf("expected the last migration to be reverted, got %v %v", reverted, err)
+	}
+	if version, _ := sqlDB.SchemaVersion(); version != LatestSchemaVersion()-1 {
+		t.Fatalf("expected schema version %v, got %v", LatestSchemaVersion()-1, version)
+	}
+
+	sqlDB.MigrateUp()
+	sqlDB.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 9999, "from_the_future", "2030-01-01T00:00:00Z")
+	if _, err := OpenTripStore(dsn); !errors.Is(err, ErrorSchemaTooNew) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorSchemaTooNew, err)
+	}
+}
+
+func TestQueryTrips_1(t *testing.T) {
+	tripStore, err := OpenTripStore(seededTestSQLDSN(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sqlDB := tripStore.(*sqlDB)
+	defer sqlDB.Close()
+
+	sqlDB.AddTrip(model.Trip{OriginId: 1, DestinationId: 6})
+
+	tests := []struct {
+		query    model.TripQuery
+		expected []int32
+	}{
+		{query: model.TripQuery{}, expected: []int32{1, 2, 3, 4}},
+		{query: model.TripQuery{OriginId: 1}, expected: []int32{1, 4}},
+		{query: model.TripQuery{DestinationId: 6}, expected: []int32{3, 4}},
+		{query: model.TripQuery{OriginId: 1, DestinationId: 6}, expected: []int32{4}},
+		{query: model.TripQuery{AfterId: 1, Limit: 2}, expected: []int32{2, 3}},
+	}
+
+	for _, test := range tests {
+		trips, err := sqlDB.QueryTrips(test.query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ids := []int32{}
+		for _, trip := range trips {
+			ids = append(ids, trip.Id)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Fatalf("expected trips %v for %+v, got %v", test.expected, test.query, ids)
+		}
+	}
+}
+
+func TestRebind_1(t *testing.T) {
+	query := "SELECT id FROM trips WHERE origin_id = ? AND id > ?"
+
+	if rebound := (&sqlDB{driverName: "postgres"}).rebind(query); rebound != "SELECT id FROM trips WHERE origin_id = $1 AND id > $2" {
+		t.Fatalf("expected $1 and $2 placeholders, got %v", rebound)
+	}
+	if rebound := (&sqlDB{driverName: "mysql"}).rebind(query); rebound != query {
+		t.Fatalf("expected %v, got %v", query, rebound)
+	}
+}
@@ -0,0 +1,700 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/filelock"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Types of the events in a trip log
+const (
+	TripCreated = "TripCreated"
+	TripUpdated = "TripUpdated"
+	TripDeleted = "TripDeleted"
+	// Replaces every trip at once, as restores do
+	TripsImported = "TripsImported"
+)
+
+// Events appended to a trip log between snapshots of its trips
+const defaultSnapshotInterval = 1000
+
+var errStopReading = errors.New("stop reading")
+
+// TripEvent is a change to a trip. The trips at any time are the result of
+// applying, in order, every event up to that time.
+type TripEvent struct {
+	Seq  int64     `json:"seq"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// The trip after the change, or as it was before being deleted
+	Trip model.Trip `json:"trip"`
+	// The trips and the next id of an import
+	Trips  []model.Trip `json:"trips,omitempty"`
+	NextId int32        `json:"nextId,omitempty"`
+}
+
+// tripSnapshot is the result of applying every event of a log up to Seq, so
+// the log does not have to be replayed from the start on every startup or
+// asOf query. The index of snapshots holds them without their trips.
+type tripSnapshot struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Size of the log up to the event Seq, where replaying continues, or
+	// the number of events up to Seq if they are only kept in memory
+	Offset int64        `json:"offset"`
+	NextId int32        `json:"nextId"`
+	Trips  []model.Trip `json:"trips,omitempty"`
+}
+
+// tripProjection is the state of every trip after a sequence of events
+type tripProjection struct {
+	trips  map[int32]model.Trip
+	nextId int32
+	seq    int64
+	time   time.Time
+}
+
+func newTripProjection() *tripProjection {
+	return &tripProjection{trips: map[int32]model.Trip{}, nextId: 1}
+}
+
+// snapshotProjection returns the trips of a snapshot, from which events after it are applied
+func snapshotProjection(snapshot tripSnapshot) *tripProjection {
+	projection := newTripProjection()
+	projection.seq = snapshot.Seq
+	projection.time = snapshot.Time
+	if snapshot.NextId > 0 {
+		projection.nextId = snapshot.NextId
+	}
+	for _, trip := range snapshot.Trips {
+		projection.trips[trip.Id] = trip
+	}
+
+	return projection
+}
+
+func (projection *tripProjection) apply(event TripEvent) {
+	switch event.Type {
+	case TripCreated, TripUpdated:
+		projection.trips[event.Trip.Id] = event.Trip
+	case TripDeleted:
+		delete(projection.trips, event.Trip.Id)
+	case TripsImported:
+		projection.trips = make(map[int32]model.Trip, len(event.Trips))
+		for _, trip := range event.Trips {
+			projection.trips[trip.Id] = trip
+			if trip.Id >= projection.nextId {
+				projection.nextId = trip.Id + 1
+			}
+		}
+		// The next id is only ever moved forward, so ids are never given twice
+		if event.NextId > projection.nextId {
+			projection.nextId = event.NextId
+		}
+	}
+
+	if event.Trip.Id >= projection.nextId {
+		projection.nextId = event.Trip.Id + 1
+	}
+	projection.seq = event.Seq
+	projection.time = event.Time
+}
+
+// list returns every trip, by id
+func (projection *tripProjection) list() []model.Trip {
+	result := make([]model.Trip, 0, len(projection.trips))
+	for _, trip := range projection.trips {
+		result = append(result, trip)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
+
+// Every change to a trip is appended to a JSON Lines log of TripEvent, and
+// the current trips are a projection of the log. The projection is written to
+// a snapshot file next to the log every few events, and listed in an index of
+// snapshots. Startups only replay the events after the last snapshot, and
+// asOf queries the events after the last snapshot before their time.
+type tripLogDB struct {
+	filePath string
+	// Nil if events are only kept in memory
+	file *os.File
+	// Every event, if they are only kept in memory
+	events []TripEvent
+	// Size of the log file
+	offset     int64
+	projection *tripProjection
+	// Every snapshot, oldest first. Their trips are only kept if the events
+	// are only kept in memory, and read from their file otherwise.
+	snapshots        []tripSnapshot
+	sinceSnapshot    int
+	snapshotInterval int
+	lock             sync.RWMutex
+	now              func() time.Time
+}
+
+// journal:///var/lib/packandgo keeps trips in the event log trips.log of a
+// directory, or in the file of the path if it is not a directory. Journals
+// without a path only keep their events in memory.
+func init() {
+	RegisterTripDriver("journal", func(dsn *url.URL) (TripStore, error) {
+		tripLogDB, err := NewTripLogDB(dsnPath(dsn, "trips.log"))
+		if err != nil {
+			return nil, err
+		}
+		return tripLogDB, nil
+	})
+}
+
+// NewTripLogDB returns a trip database backed by the event log in filePath,
+// or one that only keeps its events in memory if filePath is empty. New logs
+// start with the same trips as the memory database.
+func NewTripLogDB(filePath string) (*tripLogDB, error) {
+	tripLogDB := &tripLogDB{
+		filePath:         filePath,
+		events:           []TripEvent{},
+		projection:       newTripProjection(),
+		snapshotInterval: defaultSnapshotInterval,
+		now:              time.Now,
+	}
+
+	if filePath != "" {
+		file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		err = filelock.Lock(file)
+		if err == nil {
+			err = tripLogDB.load(file)
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%v: %w", filePath, err)
+		}
+		tripLogDB.file = file
+	}
+
+	if tripLogDB.projection.seq == 0 {
+		for _, trip := range trips {
+			trip.Version = 1
+			if _, err := tripLogDB.append(TripEvent{Type: TripCreated, Trip: trip}); err != nil {
+				tripLogDB.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return tripLogDB, nil
+}
+
+// load builds the projection from the last valid snapshot and the events
+// after it, or from the whole log if there is none. An event cut short by a
+// crash at the end of the log is removed.
+func (tripLogDB *tripLogDB) load(file *os.File) error {
+	snapshots, err := readTripSnapshotIndex(tripLogDB.snapshotIndexPath())
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("could not read snapshot index of trip log: %v", err)
+	}
+
+	// Snapshots after the one the log is replayed from do not match the log
+	valid := 0
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot, err := readTripSnapshot(tripLogDB.snapshotPath(snapshots[i].Seq))
+		if err == nil {
+			err = tripLogDB.replay(file, snapshot)
+		}
+		if err == nil {
+			valid = i + 1
+			break
+		}
+		log.Printf("could not start from snapshot %v of trip log: %v", snapshots[i].Seq, err)
+	}
+
+	if valid == 0 {
+		err = tripLogDB.replay(file, tripSnapshot{})
+		if err != nil {
+			return err
+		}
+	}
+	if valid < len(snapshots) {
+		err = writeTripSnapshotIndex(tripLogDB.snapshotIndexPath(), snapshots[:valid])
+		if err != nil {
+			return err
+		}
+	}
+	tripLogDB.snapshots = snapshots[:valid]
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > tripLogDB.offset {
+		log.Printf("removing incomplete event at the end of trip log %v", tripLogDB.filePath)
+		return file.Truncate(tripLogDB.offset)
+	}
+
+	return nil
+}
+
+func (tripLogDB *tripLogDB) replay(file *os.File, snapshot tripSnapshot) error {
+	projection := snapshotProjection(snapshot)
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < snapshot.Offset {
+		return fmt.Errorf("snapshot is ahead of the log, at event %v", snapshot.Seq)
+	}
+
+	_, err = file.Seek(snapshot.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	size, err := readTripEvents(file, func(event TripEvent) error {
+		if event.Seq != projection.seq+1 {
+			return fmt.Errorf("event %v follows event %v", event.Seq, projection.seq)
+		}
+		projection.apply(event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tripLogDB.projection = projection
+	tripLogDB.offset = snapshot.Offset + size
+	tripLogDB.sinceSnapshot = int(projection.seq - snapshot.Seq)
+	return nil
+}
+
+func (tripLogDB *tripLogDB) GetAllTrips() ([]model.Trip, error) {
+	tripLogDB.lock.RLock()
+	defer tripLogDB.lock.RUnlock()
+
+	return tripLogDB.projection.list(), nil
+}
+
+func (tripLogDB *tripLogDB) GetTripById(id int32) (model.Trip, error) {
+	tripLogDB.lock.RLock()
+	defer tripLogDB.lock.RUnlock()
+
+	trip, ok := tripLogDB.projection.trips[id]
+	if !ok {
+		return model.Trip{}, ErrorTripNotFound
+	}
+
+	return trip, nil
+}
+
+func (tripLogDB *tripLogDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	tripLogDB.lock.Lock()
+	defer tripLogDB.lock.Unlock()
+
+	trip.Id = tripLogDB.projection.nextId
+	trip.Version = 1
+
+	event, err := tripLogDB.append(TripEvent{Type: TripCreated, Trip: trip})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return event.Trip, nil
+}
+
+// UpdateTrip replaces the trip with the same id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (tripLogDB *tripLogDB) UpdateTrip(trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	tripLogDB.lock.Lock()
+	defer tripLogDB.lock.Unlock()
+
+	current, ok := tripLogDB.projection.trips[trip.Id]
+	if !ok {
+		return model.Trip{}, ErrorTripNotFound
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return model.Trip{}, ErrorVersionMismatch
+	}
+
+	trip.Version = current.Version + 1
+
+	event, err := tripLogDB.append(TripEvent{Type: TripUpdated, Trip: trip})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return event.Trip, nil
+}
+
+// DeleteTrip removes the trip with the given id, as long as its current version
+// matches expectedVersion. An expectedVersion of 0 skips the check.
+func (tripLogDB *tripLogDB) DeleteTrip(id int32, expectedVersion int32) (model.Trip, error) {
+	tripLogDB.lock.Lock()
+	defer tripLogDB.lock.Unlock()
+
+	trip, ok := tripLogDB.projection.trips[id]
+	if !ok {
+		return model.Trip{}, ErrorTripNotFound
+	}
+	if expectedVersion != 0 && trip.Version != expectedVersion {
+		return model.Trip{}, ErrorVersionMismatch
+	}
+
+	_, err := tripLogDB.append(TripEvent{Type: TripDeleted, Trip: trip})
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	return trip, nil
+}
+
+// ExportTrips returns the current trips, by id, and the next id to be given
+func (tripLogDB *tripLogDB) ExportTrips() ([]model.Trip, int32, error) {
+	tripLogDB.lock.RLock()
+	defer tripLogDB.lock.RUnlock()
+
+	return tripLogDB.projection.list(), tripLogDB.projection.nextId, nil
+}
+
+// ImportTrips appends a single event that replaces every trip, so an import
+// is either applied whole or not at all and the history before it is kept.
+// As ids are never given twice, the next id is only ever moved forward.
+func (tripLogDB *tripLogDB) ImportTrips(trips []model.Trip, nextId int32) error {
+	tripLogDB.lock.Lock()
+	defer tripLogDB.lock.Unlock()
+
+	_, err := tripLogDB.append(TripEvent{Type: TripsImported, Trips: append([]model.Trip{}, trips...), NextId: nextId})
+	return err
+}
+
+// GetAllTripsAsOf returns the trips as they were at the given time, by id
+func (tripLogDB *tripLogDB) GetAllTripsAsOf(asOf time.Time) ([]model.Trip, error) {
+	projection, err := tripLogDB.projectAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	return projection.list(), nil
+}
+
+// GetTripByIdAsOf returns a trip as it was at the given time
+func (tripLogDB *tripLogDB) GetTripByIdAsOf(id int32, asOf time.Time) (model.Trip, error) {
+	projection, err := tripLogDB.projectAsOf(asOf)
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	trip, ok := projection.trips[id]
+	if !ok {
+		return model.Trip{}, ErrorTripNotFound
+	}
+
+	return trip, nil
+}
+
+// projectAsOf replays the log from the last snapshot at or before asOf up to
+// the last event at or before asOf
+func (tripLogDB *tripLogDB) projectAsOf(asOf time.Time) (*tripProjection, error) {
+	tripLogDB.lock.RLock()
+	snapshot := tripSnapshot{}
+	for i := len(tripLogDB.snapshots) - 1; i >= 0; i-- {
+		if !tripLogDB.snapshots[i].Time.After(asOf) {
+			snapshot = tripLogDB.snapshots[i]
+			break
+		}
+	}
+
+	apply := func(projection *tripProjection, event TripEvent) error {
+		if event.Time.After(asOf) {
+			return errStopReading
+		}
+		projection.apply(event)
+		return nil
+	}
+
+	if tripLogDB.filePath == "" {
+		defer tripLogDB.lock.RUnlock()
+
+		projection := snapshotProjection(snapshot)
+		for _, event := range tripLogDB.events[snapshot.Offset:] {
+			if err := apply(projection, event); err != nil {
+				return projection, ignoreStop(err)
+			}
+		}
+		return projection, nil
+	}
+	// Events are only appended, so the log can be read up to its current size while it grows
+	offset := tripLogDB.offset
+	tripLogDB.lock.RUnlock()
+
+	if snapshot.Seq > 0 {
+		var err error
+		snapshot, err = readTripSnapshot(tripLogDB.snapshotPath(snapshot.Seq))
+		if err != nil {
+			log.Printf("could not read snapshot of trip log, replaying it from the start: %v", err)
+			snapshot = tripSnapshot{}
+		}
+	}
+
+	file, err := os.Open(tripLogDB.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open trip log: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Seek(snapshot.Offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	projection := snapshotProjection(snapshot)
+	_, err = readTripEvents(io.LimitReader(file, offset-snapshot.Offset), func(event TripEvent) error {
+		return apply(projection, event)
+	})
+
+	return projection, err
+}
+
+// append writes an event to the log and applies it. The lock must be held.
+func (tripLogDB *tripLogDB) append(event TripEvent) (TripEvent, error) {
+	// Event times never go back, so the log can be replayed up to a time
+	now := tripLogDB.now().UTC()
+	if now.Before(tripLogDB.projection.time) {
+		now = tripLogDB.projection.time
+	}
+	if event.Type == TripCreated || event.Type == TripUpdated {
+		event.Trip.UpdatedAt = now
+	}
+
+	event.Seq = tripLogDB.projection.seq + 1
+	event.Time = now
+
+	if tripLogDB.filePath == "" {
+		tripLogDB.events = append(tripLogDB.events, event)
+	} else {
+		if tripLogDB.file == nil {
+			return TripEvent{}, errors.New("trip log is closed")
+		}
+
+		line, _ := json.Marshal(event)
+		line = append(line, '\n')
+		_, err := tripLogDB.file.Write(line)
+		if err == nil {
+			err = tripLogDB.file.Sync()
+		}
+		if err != nil {
+			return TripEvent{}, fmt.Errorf("could not write trip log: %w", err)
+		}
+		tripLogDB.offset += int64(len(line))
+	}
+
+	tripLogDB.projection.apply(event)
+
+	tripLogDB.sinceSnapshot++
+	if tripLogDB.sinceSnapshot >= tripLogDB.snapshotInterval {
+		tripLogDB.snapshot()
+	}
+
+	return event, nil
+}
+
+// snapshot writes the current projection next to the log and adds it to the
+// index of snapshots, or keeps it in memory along with the events. The lock
+// must be held.
+func (tripLogDB *tripLogDB) snapshot() {
+	projection := tripLogDB.projection
+	snapshot := tripSnapshot{
+		Seq:    projection.seq,
+		Time:   projection.time,
+		Offset: tripLogDB.offset,
+		NextId: projection.nextId,
+		Trips:  projection.list(),
+	}
+
+	if tripLogDB.filePath == "" {
+		snapshot.Offset = int64(len(tripLogDB.events))
+		tripLogDB.snapshots = append(tripLogDB.snapshots, snapshot)
+		tripLogDB.sinceSnapshot = 0
+		return
+	}
+
+	// The snapshot is written at once, so a crash never leaves half of one,
+	// and only then indexed
+	body, _ := json.Marshal(snapshot)
+	snapshotPath := tripLogDB.snapshotPath(snapshot.Seq)
+	err := ioutil.WriteFile(snapshotPath+".tmp", body, 0600)
+	if err == nil {
+		err = os.Rename(snapshotPath+".tmp", snapshotPath)
+	}
+	snapshot.Trips = nil
+	if err == nil {
+		err = appendTripSnapshotIndex(tripLogDB.snapshotIndexPath(), snapshot)
+	}
+	if err != nil {
+		log.Printf("could not write snapshot of trip log: %v", err)
+		return
+	}
+
+	tripLogDB.snapshots = append(tripLogDB.snapshots, snapshot)
+	tripLogDB.sinceSnapshot = 0
+}
+
+func (tripLogDB *tripLogDB) snapshotPath(seq int64) string {
+	return fmt.Sprintf("%v.snapshot.%v", tripLogDB.filePath, seq)
+}
+
+func (tripLogDB *tripLogDB) snapshotIndexPath() string {
+	return tripLogDB.filePath + ".snapshots"
+}
+
+// Close writes a snapshot of the trips, so the next startup does not replay the log
+func (tripLogDB *tripLogDB) Close() error {
+	tripLogDB.lock.Lock()
+	defer tripLogDB.lock.Unlock()
+
+	if tripLogDB.file == nil {
+		return nil
+	}
+
+	if tripLogDB.sinceSnapshot > 0 {
+		tripLogDB.snapshot()
+	}
+
+	err := tripLogDB.file.Close()
+	tripLogDB.file = nil
+	return err
+}
+
+// Check verifies that the trip log is open
+func (tripLogDB *tripLogDB) Check() error {
+	tripLogDB.lock.RLock()
+	defer tripLogDB.lock.RUnlock()
+
+	if tripLogDB.filePath != "" && tripLogDB.file == nil {
+		return errors.New("trip log is closed")
+	}
+
+	return nil
+}
+
+func readTripSnapshot(filePath string) (tripSnapshot, error) {
+	body, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return tripSnapshot{}, err
+	}
+
+	var snapshot tripSnapshot
+	err = json.Unmarshal(body, &snapshot)
+	return snapshot, err
+}
+
+// readTripSnapshotIndex reads the JSON Lines index of the snapshots of a log.
+// A last line without a newline is ignored, as its write did not finish.
+func readTripSnapshotIndex(filePath string) ([]tripSnapshot, error) {
+	body, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []tripSnapshot{}
+	lines := bytes.Split(body, []byte("\n"))
+	for _, line := range lines[:len(lines)-1] {
+		var snapshot tripSnapshot
+		err = json.Unmarshal(line, &snapshot)
+		if err != nil {
+			return snapshots, fmt.Errorf("invalid snapshot in index: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+func appendTripSnapshotIndex(filePath string, snapshot tripSnapshot) error {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, _ := json.Marshal(snapshot)
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+
+	return err
+}
+
+// writeTripSnapshotIndex replaces the index of the snapshots of a log at once
+func writeTripSnapshotIndex(filePath string, snapshots []tripSnapshot) error {
+	var body bytes.Buffer
+	for _, snapshot := range snapshots {
+		line, _ := json.Marshal(snapshot)
+		body.Write(append(line, '\n'))
+	}
+
+	err := ioutil.WriteFile(filePath+".tmp", body.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filePath+".tmp", filePath)
+}
+
+// readTripEvents reads the events of a JSON Lines log until read returns an
+// error, returning the size of the complete lines read. A last line without
+// a newline is ignored, as the write of its event did not finish.
+func readTripEvents(reader io.Reader, read func(TripEvent) error) (int64, error) {
+	bufferedReader := bufio.NewReader(reader)
+	size := int64(0)
+
+	for {
+		line, err := bufferedReader.ReadBytes('\n')
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+
+		var event TripEvent
+		err = json.Unmarshal(line, &event)
+		if err != nil {
+			return size, fmt.Errorf("invalid event after %v bytes: %w", size, err)
+		}
+
+		err = read(event)
+		if err != nil {
+			return size, ignoreStop(err)
+		}
+		size += int64(len(line))
+	}
+}
+
+func ignoreStop(err error) error {
+	if err == errStopReading {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestTripLogDB_1(t *testing.T) {
+	tripLogDB, err := NewTripLogDB("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if trips, _ := tripLogDB.GetAllTrips(); len(trips) != 3 || trips[0].Id != 1 || trips[0].Version != 1 {
+		t.Fatalf("expected the 3 initial trips, got %v", trips)
+	}
+
+	trip, _ := tripLogDB.AddTrip(newTrip)
+	if trip.Id != 4 || trip.Version != 1 {
+		t.Fatalf("expected trip 4 at version 1, got %v", trip)
+	}
+
+	trip.Price = 20
+	_, err = tripLogDB.UpdateTrip(trip, 2)
+	if err != ErrorVersionMismatch {
+		t.Fatalf("expected error: %v, got error: %v", ErrorVersionMismatch, err)
+	}
+	trip, err = tripLogDB.UpdateTrip(trip, 1)
+	if err != nil || trip.Version != 2 {
+		t.Fatalf("expected trip 4 at version 2, got %v %v", trip, err)
+	}
+
+	deleted, err := tripLogDB.DeleteTrip(1, 1)
+	if err != nil || deleted.Id != 1 {
+		t.Fatalf("expected trip 1 to be deleted, got %v %v", deleted, err)
+	}
+	if _, err := tripLogDB.GetTripById(1); err != ErrorTripNotFound {
+		t.Fatalf("expected error: %v, got error: %v", ErrorTripNotFound, err)
+	}
+	if trip, _ := tripLogDB.AddTrip(newTrip); trip.Id != 5 {
+		t.Fatalf("expected ids of deleted trips not to be reused, got %v", trip.Id)
+	}
+}
+
+func TestTripLogDB_2(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trips.log")
+
+	tripLogDB, err := NewTripLogDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tripLogDB.snapshotInterval = 4
+
+	trip, _ := tripLogDB.AddTrip(newTrip)
+	trip.Price = 20
+	tripLogDB.UpdateTrip(trip, 0)
+	tripLogDB.DeleteTrip(2, 0)
+	expected, _ := tripLogDB.GetAllTrips()
+
+	// The fourth event wrote a snapshot, the fifth and sixth have to be replayed
+	if tripLogDB.sinceSnapshot != 2 {
+		t.Fatalf("expected 2 events since the last snapshot, got %v", tripLogDB.sinceSnapshot)
+	}
+	// Stop as in a crash, without the snapshot written by Close
+	tripLogDB.file.Close()
+	tripLogDB.file = nil
+
+	tripLogDB, err = NewTripLogDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if trips, _ := tripLogDB.GetAllTrips(); !reflect.DeepEqual(trips, expected) {
+		t.Fatalf("expected %v, got %v", expected, trips)
+	}
+	if tripLogDB.sinceSnapshot != 2 {
+		t.Fatalf("expected to start from the snapshot and replay 2 events, got %v", tripLogDB.sinceSnapshot)
+	}
+
+	tripLogDB.Close()
+	if err := tripLogDB.Check(); err == nil {
+		t.Fatalf("expected a closed trip log to fail its health check")
+	}
+
+	// Without a snapshot the whole log is replayed
+	os.Remove(filePath + ".snapshots")
+	tripLogDB, err = NewTripLogDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tripLogDB.Close()
+	if trips, _ := tripLogDB.GetAllTrips(); !reflect.DeepEqual(trips, expected) {
+		t.Fatalf("expected %v, got %v", expected, trips)
+	}
+	if trip, _ := tripLogDB.AddTrip(newTrip); trip.Id != 5 {
+		t.Fatalf("expected next id 5, got %v", trip.Id)
+	}
+}
+
+func TestTripLogDB_3(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trips.log")
+
+	tripLogDB, _ := NewTripLogDB(filePath)
+	tripLogDB.AddTrip(newTrip)
+	tripLogDB.Close()
+
+	// A crash in the middle of a write leaves half an event at the end
+	file, _ := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"seq":5,"type":"TripCreated","tr`)
+	file.Close()
+	os.Remove(filePath + ".snapshots")
+
+	tripLogDB, err := NewTripLogDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if trip, _ := tripLogDB.AddTrip(newTrip); trip.Id != 5 {
+		t.Fatalf("expected next id 5, got %v", trip.Id)
+	}
+	tripLogDB.Close()
+
+	tripLogDB, err = NewTripLogDB(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error reopening the log: %v", err)
+	}
+	defer tripLogDB.Close()
+	if trips, _ := tripLogDB.GetAllTrips(); len(trips) != 5 {
+		t.Fatalf("expected 5 trips, got %v", trips)
+	}
+}
+
+func TestGetAllTripsAsOf_1(t *testing.T) {
+	for _, filePath := range []string{"", filepath.Join(t.TempDir(), "trips.log")} {
+		tripLogDB, _ := NewTripLogDB(filePath)
+		start := time.Now().UTC().Add(time.Hour)
+		now := start
+		tripLogDB.now = func() time.Time { return now }
+
+		trip, _ := tripLogDB.AddTrip(newTrip)
+		now = start.Add(time.Hour)
+		trip.Price = 20
+		tripLogDB.UpdateTrip(trip, 0)
+		now = start.Add(2 * time.Hour)
+		tripLogDB.DeleteTrip(trip.Id, 0)
+
+		tests := []struct {
+			asOf     time.Time
+			trips    int
+			price    float64
+			expected error
+		}{
+			{asOf: start.Add(-time.Minute), trips: 3, expected: ErrorTripNotFound},
+			{asOf: start, trips: 4, price: newTrip.Price},
+			{asOf: start.Add(90 * time.Minute), trips: 4, price: 20},
+			{asOf: start.Add(3 * time.Hour), trips: 3, expected: ErrorTripNotFound},
+		}
+
+		for _, test := range tests {
+			trips, err := tripLogDB.GetAllTripsAsOf(test.asOf)
+			if err != nil || len(trips) != test.trips {
+				t.Fatalf("expected %v trips as of %v, got %v %v", test.trips, test.asOf, trips, err)
+			}
+
+			historical, err := tripLogDB.GetTripByIdAsOf(trip.Id, test.asOf)
+			if err != test.expected || historical.Price != test.price {
+				t.Fatalf("expected price %v and error %v as of %v, got %v %v", test.price, test.expected, test.asOf, historical, err)
+			}
+		}
+
+		tripLogDB.Close()
+	}
+}
+
+func TestGetAllTripsAsOf_2(t *testing.T) {
+	for _, filePath := range []string{"", filepath.Join(t.TempDir(), "trips.log")} {
+		tripLogDB, _ := NewTripLogDB(filePath)
+		tripLogDB.snapshotInterval = 2
+		start := time.Now().UTC().Add(time.Hour)
+		now := start
+		tripLogDB.now = func() time.Time { return now }
+
+		// The 4th and 6th events are snapshots
+		trip, _ := tripLogDB.AddTrip(newTrip)
+		for i := 1; i <= 2; i++ {
+			now = start.Add(time.Duration(i) * time.Hour)
+			trip.Price = float64(i)
+			trip, _ = tripLogDB.UpdateTrip(trip, 0)
+		}
+		if len(tripLogDB.snapshots) != 2 {
+			t.Fatalf("expected 2 snapshots, got %v", tripLogDB.snapshots)
+		}
+
+		// Queries after a snapshot start from it instead of the start of the log
+		if filePath == "" {
+			tripLogDB.snapshots[0].Trips[3].Price = 100
+		} else {
+			snapshotPath := tripLogDB.snapshotPath(tripLogDB.snapshots[0].Seq)
+			snapshot, _ := readTripSnapshot(snapshotPath)
+			snapshot.Trips[3].Price = 100
+			body, _ := json.Marshal(snapshot)
+			os.WriteFile(snapshotPath, body, 0600)
+		}
+
+		tests := []struct {
+			asOf  time.Time
+			price float64
+		}{
+			{asOf: start.Add(-time.Minute)},
+			{asOf: start.Add(30 * time.Minute), price: 100},
+			{asOf: start.Add(90 * time.Minute), price: 1},
+			{asOf: start.Add(3 * time.Hour), price: 2},
+		}
+
+		for _, test := range tests {
+			historical, _ := tripLogDB.GetTripByIdAsOf(trip.Id, test.asOf)
+			if historical.Price != test.price {
+				t.Fatalf("expected price %v as of %v, got %v", test.price, test.asOf, historical)
+			}
+		}
+
+		tripLogDB.Close()
+	}
+}
+
+func TestImportTrips_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trips.log")
+
+	tripLogDB, _ := NewTripLogDB(filePath)
+	start := time.Now().UTC().Add(time.Hour)
+	tripLogDB.now = func() time.Time { return start }
+
+	imported := []model.Trip{{Id: 2, OriginId: 1, DestinationId: 3, Dates: "Mon", Price: 15, Version: 4}}
+	if err := tripLogDB.ImportTrips(imported, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := []TripEvent{}
+	file, _ := os.Open(filePath)
+	readTripEvents(file, func(event TripEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	file.Close()
+	if len(events) != 4 || events[3].Type != TripsImported || events[3].NextId != 10 {
+		t.Fatalf("expected the import to be a single event after the 3 initial trips, got %v", events)
+	}
+
+	if trips, _ := tripLogDB.GetAllTripsAsOf(start.Add(-time.Minute)); len(trips) != 3 {
+		t.Fatalf("expected the 3 trips before the import, got %v", trips)
+	}
+	tripLogDB.Close()
+
+	tripLogDB, _ = NewTripLogDB(filePath)
+	defer tripLogDB.Close()
+	if trips, _ := tripLogDB.GetAllTrips(); !reflect.DeepEqual(trips, imported) {
+		t.Fatalf("expected %v, got %v", imported, trips)
+	}
+	if trip, _ := tripLogDB.AddTrip(newTrip); trip.Id != 10 {
+		t.Fatalf("expected the imported next id 10, got %v", trip.Id)
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Deliveries kept in the log of every webhook. Dead deliveries are kept until
+// they are retried or their webhook is deleted.
+const maxDeliveryLog = 100
+
+type webhookDB struct {
+	webhooks       map[int32]model.Webhook
+	deliveries     map[int64]model.WebhookDelivery
+	nextWebhookId  int32
+	nextDeliveryId int64
+	lock           sync.RWMutex
+}
+
+func NewWebhookDB() *webhookDB {
+	return &webhookDB{
+		webhooks:       map[int32]model.Webhook{},
+		deliveries:     map[int64]model.WebhookDelivery{},
+		nextWebhookId:  1,
+		nextDeliveryId: 1,
+	}
+}
+
+func (webhookDB *webhookDB) GetWebhooks() []model.Webhook {
+	webhookDB.lock.RLock()
+	defer webhookDB.lock.RUnlock()
+
+	result := make([]model.Webhook, 0, len(webhookDB.webhooks))
+	for _, webhook := range webhookDB.webhooks {
+		result = append(result, webhook)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
+
+func (webhookDB *webhookDB) GetWebhook(id int32) (model.Webhook, error) {
+	webhookDB.lock.RLock()
+	defer webhookDB.lock.RUnlock()
+
+	webhook, ok := webhookDB.webhooks[id]
+	if !ok {
+		return model.Webhook{}, ErrorWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+func (webhookDB *webhookDB) AddWebhook(webhook model.Webhook) model.Webhook {
+	webhookDB.lock.Lock()
+	defer webhookDB.lock.Unlock()
+
+	webhook.Id = webhookDB.nextWebhookId
+	webhookDB.nextWebhookId++
+	webhookDB.webhooks[webhook.Id] = webhook
+
+	return webhook
+}
+
+// UpdateWebhook replaces the webhook with the same id, keeping its creation
+// time, and its secret if the new one is empty
+func (webhookDB *webhookDB) UpdateWebhook(webhook model.Webhook) (model.Webhook, error) {
+	webhookDB.lock.Lock()
+	defer webhookDB.lock.Unlock()
+
+	current, ok := webhookDB.webhooks[webhook.Id]
+	if !ok {
+		return model.Webhook{}, ErrorWebhookNotFound
+	}
+
+	webhook.CreatedAt = current.CreatedAt
+	if webhook.Secret == "" {
+		webhook.Secret = current.Secret
+	}
+	webhookDB.webhooks[webhook.Id] = webhook
+
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook along with its deliveries
+func (webhookDB *webhookDB) DeleteWebhook(id int32) error {
+	webhookDB.lock.Lock()
+	defer webhookDB.lock.Unlock()
+
+	if _, ok := webhookDB.webhooks[id]; !ok {
+		return ErrorWebhookNotFound
+	}
+
+	delete(webhookDB.webhooks, id)
+	for deliveryId, delivery := range webhookDB.deliveries {
+		if delivery.WebhookId == id {
+			delete(webhookDB.deliveries, deliveryId)
+		}
+	}
+
+	return nil
+}
+
+// SaveDelivery adds a delivery if it has no id, or replaces the one with the
+// same id, dropping the oldest finished deliveries from the log of its webhook.
+// Deliveries of deleted webhooks are not saved.
+func (webhookDB *webhookDB) SaveDelivery(delivery model.WebhookDelivery) model.WebhookDelivery {
+	webhookDB.lock.Lock()
+	defer webhookDB.lock.Unlock()
+
+	if _, ok := webhookDB.webhooks[delivery.WebhookId]; !ok {
+		return delivery
+	}
+
+	if delivery.Id == 0 {
+		delivery.Id = webhookDB.nextDeliveryId
+		webhookDB.nextDeliveryId++
+	}
+	webhookDB.deliveries[delivery.Id] = delivery
+
+	log := webhookDB.find(func(saved model.WebhookDelivery) bool { return saved.WebhookId == delivery.WebhookId })
+	for i := maxDeliveryLog; i < len(log); i++ {
+		if log[i].Status == model.DeliverySucceeded {
+			delete(webhookDB.deliveries, log[i].Id)
+		}
+	}
+
+	return delivery
+}
+
+func (webhookDB *webhookDB) GetDelivery(id int64) (model.WebhookDelivery, error) {
+	webhookDB.lock.RLock()
+	defer webhookDB.lock.RUnlock()
+
+	delivery, ok := webhookDB.deliveries[id]
+	if !ok {
+		return model.WebhookDelivery{}, ErrorDeliveryNotFound
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first
+func (webhookDB *webhookDB) GetDeliveries(webhookId int32) []model.WebhookDelivery {
+	webhookDB.lock.RLock()
+	defer webhookDB.lock.RUnlock()
+
+	return webhookDB.find(func(delivery model.WebhookDelivery) bool { return delivery.WebhookId == webhookId })
+}
+
+// GetDeadLetters returns the deliveries of every webhook that ran out of attempts, newest first
+func (webhookDB *webhookDB) GetDeadLetters() []model.WebhookDelivery {
+	webhookDB.lock.RLock()
+	defer webhookDB.lock.RUnlock()
+
+	return webhookDB.find(func(delivery model.WebhookDelivery) bool { return delivery.Status == model.DeliveryDead })
+}
+
+// find must be called with the lock held
+func (webhookDB *webhookDB) find(matches func(model.WebhookDelivery) bool) []model.WebhookDelivery {
+	result := []model.WebhookDelivery{}
+	for _, delivery := range webhookDB.deliveries {
+		if matches(delivery) {
+			result = append(result, delivery)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id > result[j].Id
+	})
+
+	return result
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestUpdateWebhook_1(t *testing.T) {
+	webhookDB := NewWebhookDB()
+	webhook := webhookDB.AddWebhook(model.Webhook{Url: "https://example.com/hook", Secret: "secret"})
+
+	updated, err := webhookDB.UpdateWebhook(model.Webhook{Id: webhook.Id, Url: "https://example.com/other"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Url != "https://example.com/other" || updated.Secret != "secret" {
+		t.Fatalf("expected the new url with the old secret, got %v", updated)
+	}
+
+	_, err = webhookDB.UpdateWebhook(model.Webhook{Id: 9})
+	if err != ErrorWebhookNotFound {
+		t.Fatalf("expected %v, got %v", ErrorWebhookNotFound, err)
+	}
+}
+
+func TestSaveDelivery_1(t *testing.T) {
+	webhookDB := NewWebhookDB()
+	webhook := webhookDB.AddWebhook(model.Webhook{Url: "https://example.com/hook"})
+	other := webhookDB.AddWebhook(model.Webhook{Url: "https://example.com/other"})
+
+	dead := webhookDB.SaveDelivery(model.WebhookDelivery{WebhookId: webhook.Id, Status: model.DeliveryDead})
+	for i := 0; i < maxDeliveryLog+5; i++ {
+		webhookDB.SaveDelivery(model.WebhookDelivery{WebhookId: webhook.Id, Status: model.DeliverySucceeded})
+	}
+	webhookDB.SaveDelivery(model.WebhookDelivery{WebhookId: other.Id, Status: model.DeliveryDead})
+
+	deliveries := webhookDB.GetDeliveries(webhook.Id)
+	if len(deliveries) != maxDeliveryLog+1 || deliveries[0].Id <= deliveries[1].Id {
+		t.Fatalf("expected the newest %v deliveries and the dead one, got %v", maxDeliveryLog, len(deliveries))
+	}
+	if _, err := webhookDB.GetDelivery(dead.Id); err != nil {
+		t.Fatalf("expected dead delivery to be kept, got %v", err)
+	}
+	if deadLetters := webhookDB.GetDeadLetters(); len(deadLetters) != 2 {
+		t.Fatalf("expected %v dead letters, got %v", 2, deadLetters)
+	}
+
+	err := webhookDB.DeleteWebhook(webhook.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := webhookDB.GetDelivery(dead.Id); err != ErrorDeliveryNotFound {
+		t.Fatalf("expected %v, got %v", ErrorDeliveryNotFound, err)
+	}
+	if deadLetters := webhookDB.GetDeadLetters(); len(deadLetters) != 1 || deadLetters[0].WebhookId != other.Id {
+		t.Fatalf("expected the dead letter of webhook %v, got %v", other.Id, deadLetters)
+	}
+}
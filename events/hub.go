@@ -0,0 +1,179 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	TripCreated       = "trip.created"
+	TripUpdated       = "trip.updated"
+	TripDeleted       = "trip.deleted"
+	DisruptionPosted  = "disruption.posted"
+	DisruptionCleared = "disruption.cleared"
+	// Every store was replaced by a backup, so what clients show is stale
+	BackupRestored = "backup.restored"
+)
+
+// Types lists every event type published
+var Types = []string{TripCreated, TripUpdated, TripDeleted, DisruptionPosted, DisruptionCleared, BackupRestored}
+
+// Events a subscriber can fall behind by before it is dropped. Dropped
+// subscribers can resume from the replay buffer.
+const subscriberBuffer = 64
+
+// Event is a change in the domain. Ids increase with every event published.
+type Event struct {
+	Id   int64           `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+type hub struct {
+	// The last bufferSize events, oldest first
+	buffer      []Event
+	bufferSize  int
+	lastId      int64
+	subscribers map[*Subscription]bool
+	closed      bool
+	lock        sync.Mutex
+}
+
+// Subscription receives the events published after it was made, and replays
+// the ones missed since the event it resumes from
+type Subscription struct {
+	// Buffered events after the one the subscription resumes from, oldest first
+	Replay []Event
+	// False if some events after the one the subscription resumes from are no
+	// longer buffered, or were never published by this hub
+	Complete bool
+	// Id of the last event published when subscribing
+	LastId  int64
+	events  chan Event
+	dropped bool
+	hub     *hub
+}
+
+// NewHub returns a hub that keeps the last bufferSize events for subscribers to replay
+func NewHub(bufferSize int) *hub {
+	return &hub{
+		buffer:      []Event{},
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Publish sends an event with data encoded as JSON to every subscriber
+func (hub *hub) Publish(eventType string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("could not publish %v event: %v", eventType, err)
+		return
+	}
+
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if hub.closed {
+		return
+	}
+
+	hub.lastId++
+	event := Event{Id: hub.lastId, Type: eventType, Time: time.Now().UTC(), Data: body}
+
+	hub.buffer = append(hub.buffer, event)
+	if len(hub.buffer) > hub.bufferSize {
+		hub.buffer = hub.buffer[len(hub.buffer)-hub.bufferSize:]
+	}
+
+	for subscription := range hub.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.dropped = true
+			hub.unsubscribe(subscription)
+		}
+	}
+}
+
+// Subscribe starts receiving events. A lastEventId of 0 replays nothing,
+// otherwise the buffered events after it are replayed.
+func (hub *hub) Subscribe(lastEventId int64) *Subscription {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	subscription := &Subscription{
+		Replay:   []Event{},
+		Complete: true,
+		LastId:   hub.lastId,
+		events:   make(chan Event, subscriberBuffer),
+		hub:      hub,
+	}
+
+	if lastEventId > 0 {
+		for _, event := range hub.buffer {
+			if event.Id > lastEventId {
+				subscription.Replay = append(subscription.Replay, event)
+			}
+		}
+
+		oldestId := hub.lastId + 1
+		if len(hub.buffer) > 0 {
+			oldestId = hub.buffer[0].Id
+		}
+		subscription.Complete = lastEventId >= oldestId-1 && lastEventId <= hub.lastId
+	}
+
+	if hub.closed {
+		close(subscription.events)
+		return subscription
+	}
+
+	hub.subscribers[subscription] = true
+	return subscription
+}
+
+// Events is closed when the subscription is closed, the subscriber falls too far behind or the hub is closed
+func (subscription *Subscription) Events() <-chan Event {
+	return subscription.events
+}
+
+// Dropped reports whether the subscription was closed for falling too far
+// behind, in which case the subscriber can resume from the last event it got
+func (subscription *Subscription) Dropped() bool {
+	subscription.hub.lock.Lock()
+	defer subscription.hub.lock.Unlock()
+
+	return subscription.dropped
+}
+
+func (subscription *Subscription) Close() {
+	subscription.hub.lock.Lock()
+	defer subscription.hub.lock.Unlock()
+
+	subscription.hub.unsubscribe(subscription)
+}
+
+// Close ends every subscription, so open streams do not hold up shutdowns
+func (hub *hub) Close() error {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	hub.closed = true
+	for subscription := range hub.subscribers {
+		hub.unsubscribe(subscription)
+	}
+
+	return nil
+}
+
+// unsubscribe must be called with the lock held
+func (hub *hub) unsubscribe(subscription *Subscription) {
+	if hub.subscribers[subscription] {
+		delete(hub.subscribers, subscription)
+		close(subscription.events)
+	}
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestPublish_1(t *testing.T) {
+	hub := NewHub(10)
+	subscription := hub.Subscribe(0)
+	defer subscription.Close()
+
+	hub.Publish(TripCreated, map[string]int{"id": 4})
+	hub.Publish(TripUpdated, map[string]int{"id": 4})
+
+	first := <-subscription.Events()
+	second := <-subscription.Events()
+	if first.Id != 1 || first.Type != TripCreated || string(first.Data) != `{"id":4}` {
+		t.Fatalf("expected event 1 %v, got %v", TripCreated, first)
+	}
+	if second.Id != 2 || second.Type != TripUpdated {
+		t.Fatalf("expected event 2 %v, got %v", TripUpdated, second)
+	}
+}
+
+func TestSubscribe_1(t *testing.T) {
+	hub := NewHub(3)
+	for i := 0; i < 5; i++ {
+		hub.Publish(TripUpdated, i)
+	}
+
+	tests := []struct {
+		lastEventId int64
+		replayed    []int64
+		complete    bool
+	}{
+		{lastEventId: 0, replayed: []int64{}, complete: true},
+		{lastEventId: 3, replayed: []int64{4, 5}, complete: true},
+		{lastEventId: 2, replayed: []int64{3, 4, 5}, complete: true},
+		{lastEventId: 1, replayed: []int64{3, 4, 5}, complete: false},
+		{lastEventId: 5, replayed: []int64{}, complete: true},
+		{lastEventId: 9, replayed: []int64{}, complete: false},
+	}
+
+	for _, test := range tests {
+		subscription := hub.Subscribe(test.lastEventId)
+		subscription.Close()
+
+		if subscription.Complete != test.complete || subscription.LastId != 5 {
+			t.Fatalf("%v: expected complete %v up to 5, got %v up to %v", test.lastEventId, test.complete, subscription.Complete, subscription.LastId)
+		}
+		if len(subscription.Replay) != len(test.replayed) {
+			t.Fatalf("%v: expected %v, got %v", test.lastEventId, test.replayed, subscription.Replay)
+		}
+		for i, event := range subscription.Replay {
+			if event.Id != test.replayed[i] {
+				t.Fatalf("%v: expected %v, got %v", test.lastEventId, test.replayed, subscription.Replay)
+			}
+		}
+	}
+}
+
+func TestSubscribe_2(t *testing.T) {
+	hub := NewHub(10)
+	slow := hub.Subscribe(0)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		hub.Publish(TripUpdated, i)
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != subscriberBuffer || !slow.Dropped() {
+		t.Fatalf("expected slow subscriber to be dropped after %v events, got %v", subscriberBuffer, received)
+	}
+
+	// Closing again is harmless
+	slow.Close()
+}
+
+func TestClose_1(t *testing.T) {
+	hub := NewHub(10)
+	subscription := hub.Subscribe(0)
+
+	hub.Close()
+	if _, ok := <-subscription.Events(); ok || subscription.Dropped() {
+		t.Fatalf("expected subscription to be closed")
+	}
+
+	hub.Publish(TripCreated, 1)
+	if _, ok := <-hub.Subscribe(0).Events(); ok {
+		t.Fatalf("expected subscriptions to a closed hub to be closed")
+	}
+}
@@ -0,0 +1,17 @@
+// Package filelock keeps two processes from writing the same file. The lock
+// is advisory: it only keeps out processes that take it too, and it is
+// released when the file is closed or the process exits.
+package filelock
+
+import (
+	"errors"
+	"os"
+)
+
+var ErrorLocked = errors.New("file is in use by another process")
+
+// Lock takes an exclusive lock on file, failing with ErrorLocked at once if
+// another process holds it
+func Lock(file *os.File) error {
+	return lock(file)
+}
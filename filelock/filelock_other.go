@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package filelock
+
+import "os"
+
+// Files are not locked on systems without flock
+func lock(file *os.File) error {
+	return nil
+}
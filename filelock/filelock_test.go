@@ -0,0 +1,27 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLock_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "audit.log")
+
+	first, _ := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0600)
+	if err := Lock(first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	second, _ := os.OpenFile(filePath, os.O_RDWR, 0600)
+	defer second.Close()
+	if err := Lock(second); err != ErrorLocked {
+		t.Fatalf("expected error: %v, got error: %v", ErrorLocked, err)
+	}
+
+	first.Close()
+	if err := Lock(second); err != nil {
+		t.Fatalf("expected the lock to be released on close, got error: %v", err)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/gbandres98/pack-and-go
 
 go 1.17
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/gorilla/mux v1.8.0
+	golang.org/x/crypto v0.9.0
+)
@@ -0,0 +1,207 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const agencyId = "packandgo"
+
+// GTFS route_type for bus services
+const routeTypeBus = "3"
+
+// Options hold the values required by GTFS that are not stored with trips or cities
+type Options struct {
+	AgencyName string
+	AgencyURL  string
+	Timezone   string
+	// Calendar validity window
+	StartDate time.Time
+	EndDate   time.Time
+	// Offset from midnight of every departure
+	DepartureTime time.Duration
+	// Time between departure and arrival of every trip
+	TravelTime time.Duration
+}
+
+// DefaultOptions returns options for a feed valid for one year from now
+func DefaultOptions(now time.Time) Options {
+	return Options{
+		AgencyName:    "PackAndGo",
+		AgencyURL:     "https://www.packandgo.example.com",
+		Timezone:      "Europe/Madrid",
+		StartDate:     now,
+		EndDate:       now.AddDate(1, 0, 0),
+		DepartureTime: model.DefaultDepartureTime,
+		TravelTime:    model.DefaultTravelTime,
+	}
+}
+
+type feedFile struct {
+	name   string
+	header []string
+	rows   [][]string
+}
+
+// Export writes a static GTFS feed as a zip file. Cities become stops, every
+// origin and destination pair becomes a route, and every distinct set of
+// weekdays becomes a calendar service.
+func Export(w io.Writer, cities []model.City, trips []model.Trip, options Options) error {
+	agency := feedFile{
+		name:   "agency.txt",
+		header: []string{"agency_id", "agency_name", "agency_url", "agency_timezone"},
+		rows:   [][]string{{agencyId, options.AgencyName, options.AgencyURL, options.Timezone}},
+	}
+
+	stops := feedFile{
+		name:   "stops.txt",
+		header: []string{"stop_id", "stop_name", "stop_lat", "stop_lon"},
+	}
+	citiesById := map[int32]model.City{}
+	for _, city := range cities {
+		citiesById[city.Id] = city
+		stops.rows = append(stops.rows, []string{
+			stopId(city.Id),
+			city.Name,
+			strconv.FormatFloat(city.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(city.Longitude, 'f', -1, 64),
+		})
+	}
+
+	routes := feedFile{
+		name:   "routes.txt",
+		header: []string{"route_id", "agency_id", "route_short_name", "route_long_name", "route_type"},
+	}
+	calendar := feedFile{
+		name:   "calendar.txt",
+		header: []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+	}
+	feedTrips := feedFile{
+		name:   "trips.txt",
+		header: []string{"route_id", "service_id", "trip_id"},
+	}
+	stopTimes := feedFile{
+		name:   "stop_times.txt",
+		header: []string{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"},
+	}
+
+	routeIds := map[string]bool{}
+	serviceIds := map[string]bool{}
+
+	sortedTrips := append([]model.Trip{}, trips...)
+	sort.Slice(sortedTrips, func(i, j int) bool { return sortedTrips[i].Id < sortedTrips[j].Id })
+
+	for _, trip := range sortedTrips {
+		origin, ok := citiesById[trip.OriginId]
+		if !ok {
+			return fmt.Errorf("trip %v: could not find origin city with id: %v", trip.Id, trip.OriginId)
+		}
+		destination, ok := citiesById[trip.DestinationId]
+		if !ok {
+			return fmt.Errorf("trip %v: could not find destination city with id: %v", trip.Id, trip.DestinationId)
+		}
+
+		weekdays, err := model.ParseDates(trip.Dates)
+		if err != nil {
+			return fmt.Errorf("trip %v: %w", trip.Id, err)
+		}
+
+		routeId := fmt.Sprintf("%v-%v", trip.OriginId, trip.DestinationId)
+		if !routeIds[routeId] {
+			routeIds[routeId] = true
+			routes.rows = append(routes.rows, []string{
+				routeId,
+				agencyId,
+				routeId,
+				fmt.Sprintf("%v - %v", origin.Name, destination.Name),
+				routeTypeBus,
+			})
+		}
+
+		serviceId, calendarRow := calendarService(weekdays, options)
+		if !serviceIds[serviceId] {
+			serviceIds[serviceId] = true
+			calendar.rows = append(calendar.rows, calendarRow)
+		}
+
+		tripId := strconv.Itoa(int(trip.Id))
+		feedTrips.rows = append(feedTrips.rows, []string{routeId, serviceId, tripId})
+
+		departure := formatTime(options.DepartureTime)
+		arrival := formatTime(options.DepartureTime + options.TravelTime)
+		stopTimes.rows = append(stopTimes.rows,
+			[]string{tripId, departure, departure, stopId(trip.OriginId), "1"},
+			[]string{tripId, arrival, arrival, stopId(trip.DestinationId), "2"},
+		)
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range []feedFile{agency, stops, routes, calendar, feedTrips, stopTimes} {
+		err := writeFile(archive, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// calendarService returns the service id and calendar.txt row for a set of weekdays
+func calendarService(weekdays []time.Weekday, options Options) (string, []string) {
+	days := make([]string, 7)
+	for i := range days {
+		days[i] = "0"
+	}
+
+	for _, weekday := range weekdays {
+		// GTFS weeks start on monday
+		days[(int(weekday)+6)%7] = "1"
+	}
+
+	serviceId := "week-"
+	for _, day := range days {
+		serviceId += day
+	}
+
+	row := append([]string{serviceId}, days...)
+	row = append(row, options.StartDate.Format("20060102"), options.EndDate.Format("20060102"))
+
+	return serviceId, row
+}
+
+func stopId(cityId int32) string {
+	return strconv.Itoa(int(cityId))
+}
+
+// formatTime formats an offset from midnight as GTFS HH:MM:SS, which can go past 24:00:00
+func formatTime(offset time.Duration) string {
+	seconds := int(offset / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func writeFile(archive *zip.Writer, file feedFile) error {
+	fileWriter, err := archive.Create(file.name)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(fileWriter)
+	err = writer.Write(file.header)
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteAll(file.rows)
+	if err != nil {
+		return fmt.Errorf("could not write %v: %w", file.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var testCities = []model.City{
+	{Id: 1, Name: "Barcelona"},
+	{Id: 2, Name: "Seville"},
+	{Id: 3, Name: "Madrid"},
+}
+
+var testTrips = []model.Trip{
+	{Id: 1, OriginId: 1, DestinationId: 2, Dates: "Mon Tue Wed Fri", Price: 40.55},
+	{Id: 2, OriginId: 2, DestinationId: 1, Dates: "Sat Sun", Price: 40.55},
+	{Id: 3, OriginId: 1, DestinationId: 2, Dates: "Sat Sun", Price: 32.10},
+}
+
+var testOptions = DefaultOptions(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+// readFeed unzips a feed and returns the records of every file, indexed by column name
+func readFeed(t *testing.T, feed []byte) map[string][]map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(feed), int64(len(feed)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := map[string][]map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		records, err := csv.NewReader(reader).ReadAll()
+		reader.Close()
+		if err != nil {
+			t.Fatalf("unexpected error reading %v: %v", file.Name, err)
+		}
+
+		rows := []map[string]string{}
+		for _, record := range records[1:] {
+			row := map[string]string{}
+			for i, column := range records[0] {
+				row[column] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		result[file.Name] = rows
+	}
+
+	return result
+}
+
+func ids(rows []map[string]string, column string) map[string]bool {
+	result := map[string]bool{}
+	for _, row := range rows {
+		result[row[column]] = true
+	}
+	return result
+}
+
+func TestExport_1(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Export(&buffer, testCities, testTrips, testOptions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	feed := readFeed(t, buffer.Bytes())
+
+	for _, name := range []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar.txt"} {
+		if len(feed[name]) == 0 {
+			t.Fatalf("expected feed to include records in %v", name)
+		}
+	}
+
+	agencyIds := ids(feed["agency.txt"], "agency_id")
+	stopIds := ids(feed["stops.txt"], "stop_id")
+	routeIds := ids(feed["routes.txt"], "route_id")
+	serviceIds := ids(feed["calendar.txt"], "service_id")
+	tripIds := ids(feed["trips.txt"], "trip_id")
+
+	for _, route := range feed["routes.txt"] {
+		if !agencyIds[route["agency_id"]] {
+			t.Fatalf("route %v references unknown agency %v", route["route_id"], route["agency_id"])
+		}
+	}
+	for _, trip := range feed["trips.txt"] {
+		if !routeIds[trip["route_id"]] {
+			t.Fatalf("trip %v references unknown route %v", trip["trip_id"], trip["route_id"])
+		}
+		if !serviceIds[trip["service_id"]] {
+			t.Fatalf("trip %v references unknown service %v", trip["trip_id"], trip["service_id"])
+		}
+	}
+
+	stopTimesPerTrip := map[string]int{}
+	for _, stopTime := range feed["stop_times.txt"] {
+		if !tripIds[stopTime["trip_id"]] {
+			t.Fatalf("stop time references unknown trip %v", stopTime["trip_id"])
+		}
+		if !stopIds[stopTime["stop_id"]] {
+			t.Fatalf("stop time references unknown stop %v", stopTime["stop_id"])
+		}
+		stopTimesPerTrip[stopTime["trip_id"]]++
+	}
+	for tripId := range tripIds {
+		if stopTimesPerTrip[tripId] < 2 {
+			t.Fatalf("expected trip %v to have at least 2 stop times, got %v", tripId, stopTimesPerTrip[tripId])
+		}
+	}
+
+	if len(feed["routes.txt"]) != 2 {
+		t.Fatalf("expected trips with the same origin and destination to share a route, got %v", feed["routes.txt"])
+	}
+	if len(feed["calendar.txt"]) != 2 {
+		t.Fatalf("expected trips with the same weekdays to share a service, got %v", feed["calendar.txt"])
+	}
+}
+
+func TestExport_2(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Export(&buffer, testCities, testTrips[:1], testOptions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	feed := readFeed(t, buffer.Bytes())
+
+	expected := map[string]string{
+		"service_id": "week-1110100",
+		"monday":     "1",
+		"thursday":   "0",
+		"friday":     "1",
+		"sunday":     "0",
+		"start_date": "20260101",
+		"end_date":   "20270101",
+	}
+	for column, value := range expected {
+		if feed["calendar.txt"][0][column] != value {
+			t.Fatalf("expected calendar %v to be %v, got %v", column, value, feed["calendar.txt"][0][column])
+		}
+	}
+
+	if feed["stop_times.txt"][1]["arrival_time"] != "09:00:00" {
+		t.Fatalf("expected arrival time to be %v, got %v", "09:00:00", feed["stop_times.txt"][1]["arrival_time"])
+	}
+}
+
+func TestExport_3(t *testing.T) {
+	trips := []model.Trip{{Id: 1, OriginId: 1, DestinationId: 9, Dates: "Mon", Price: 10}}
+
+	var buffer bytes.Buffer
+	err := Export(&buffer, testCities, trips, testOptions)
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestFormatTime_1(t *testing.T) {
+	result := formatTime(25*time.Hour + 5*time.Minute)
+	if result != "25:05:00" {
+		t.Fatalf("expected %v, got %v", "25:05:00", result)
+	}
+}
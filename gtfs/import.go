@@ -0,0 +1,389 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// ErrorFileTooLarge is returned for feeds with a file larger than maxFileSize
+// once decompressed, so small zip files can not take up the memory of the server
+var ErrorFileTooLarge = errors.New("gtfs file too large")
+
+// Bytes of a file of the feed read once decompressed
+var maxFileSize int64 = 64 << 20
+
+// Files that the import reads, others are skipped without being decompressed
+var importedFiles = map[string]bool{
+	"stops.txt":           true,
+	"routes.txt":          true,
+	"trips.txt":           true,
+	"stop_times.txt":      true,
+	"calendar.txt":        true,
+	"fare_attributes.txt": true,
+	"fare_rules.txt":      true,
+}
+
+// Files that hold information that can not be represented by cities and trips
+var unsupportedFiles = map[string]string{
+	"calendar_dates.txt": "service exceptions are ignored, trips run on their weekly calendar",
+	"frequencies.txt":    "headway based service is ignored, each trip is imported once",
+	"shapes.txt":         "route shapes are ignored",
+	"transfers.txt":      "transfers are ignored",
+	"pathways.txt":       "station pathways are ignored",
+	"levels.txt":         "station levels are ignored",
+}
+
+var calendarColumns = []struct {
+	name    string
+	weekday time.Weekday
+}{
+	{"monday", time.Monday},
+	{"tuesday", time.Tuesday},
+	{"wednesday", time.Wednesday},
+	{"thursday", time.Thursday},
+	{"friday", time.Friday},
+	{"saturday", time.Saturday},
+	{"sunday", time.Sunday},
+}
+
+type importCityService interface {
+	GetCityByName(string) (model.City, bool, error)
+	AddCity(context.Context, model.City) (model.City, error)
+}
+
+type importTripService interface {
+	AddTrip(context.Context, model.Trip) (model.Trip, error)
+}
+
+type importer struct {
+	cityService importCityService
+	tripService importTripService
+}
+
+type ImportReport struct {
+	CitiesCreated int `json:"citiesCreated"`
+	CitiesMatched int `json:"citiesMatched"`
+	TripsCreated  int `json:"tripsCreated"`
+	// City id every GTFS stop has been mapped to
+	StopCities map[string]int32 `json:"stopCities"`
+	// Parts of the feed that could not be represented
+	Unsupported []string `json:"unsupported"`
+	// Trips that could not be imported
+	Errors []string `json:"errors"`
+}
+
+type record map[string]string
+
+// NewImporter returns an importer that creates cities and trips through the
+// given services, so every trip goes through the same validation as AddTrip
+func NewImporter(cityService importCityService, tripService importTripService) *importer {
+	return &importer{cityService: cityService, tripService: tripService}
+}
+
+// Import reads a static GTFS zip file. Stops become cities, or are matched to
+// existing cities with the same name, and every GTFS trip becomes a trip from
+// its first to its last stop running on the weekdays of its calendar.
+func (importer *importer) Import(ctx context.Context, reader io.ReaderAt, size int64) (ImportReport, error) {
+	report := ImportReport{StopCities: map[string]int32{}, Unsupported: []string{}, Errors: []string{}}
+
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return report, fmt.Errorf("invalid gtfs zip file: %w", err)
+	}
+
+	files := map[string][]record{}
+	unsupportedRows := map[string]int{}
+	for _, file := range archive.File {
+		if _, ok := unsupportedFiles[file.Name]; ok {
+			rows, err := countRows(file)
+			if err != nil {
+				return report, fmt.Errorf("could not read %v: %w", file.Name, err)
+			}
+			unsupportedRows[file.Name] = rows
+			continue
+		}
+		if !importedFiles[file.Name] {
+			continue
+		}
+
+		records, err := readRecords(file)
+		if err != nil {
+			return report, fmt.Errorf("could not read %v: %w", file.Name, err)
+		}
+		files[file.Name] = records
+	}
+
+	for _, name := range []string{"stops.txt", "routes.txt", "trips.txt", "stop_times.txt"} {
+		if _, ok := files[name]; !ok {
+			return report, fmt.Errorf("missing required gtfs file: %v", name)
+		}
+	}
+
+	for name, reason := range unsupportedFiles {
+		if unsupportedRows[name] > 0 {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("%v: %v rows, %v", name, unsupportedRows[name], reason))
+		}
+	}
+
+	err = importer.importStops(ctx, files["stops.txt"], &report)
+	if err != nil {
+		return report, err
+	}
+
+	importer.importTrips(ctx, files, &report)
+
+	sort.Strings(report.Unsupported)
+	return report, nil
+}
+
+// importStops maps every stop to a city. Platforms and entrances are mapped
+// to the city of their parent station.
+func (importer *importer) importStops(ctx context.Context, stops []record, report *ImportReport) error {
+	stopsById := map[string]record{}
+	for _, stop := range stops {
+		stopsById[stop["stop_id"]] = stop
+	}
+
+	childStops := []record{}
+	for _, stop := range stops {
+		if _, ok := stopsById[stop["parent_station"]]; ok && stop["parent_station"] != "" {
+			childStops = append(childStops, stop)
+			continue
+		}
+
+		city, found, err := importer.cityService.GetCityByName(stop["stop_name"])
+		if err != nil {
+			return err
+		}
+
+		if found {
+			report.CitiesMatched++
+		} else {
+			latitude, _ := strconv.ParseFloat(stop["stop_lat"], 64)
+			longitude, _ := strconv.ParseFloat(stop["stop_lon"], 64)
+
+			city, err = importer.cityService.AddCity(ctx, model.City{Name: stop["stop_name"], Latitude: latitude, Longitude: longitude})
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("stop %v: %v", stop["stop_id"], err))
+				continue
+			}
+			report.CitiesCreated++
+		}
+
+		report.StopCities[stop["stop_id"]] = city.Id
+	}
+
+	for _, stop := range childStops {
+		if cityId, ok := report.StopCities[stop["parent_station"]]; ok {
+			report.StopCities[stop["stop_id"]] = cityId
+		}
+	}
+
+	if len(childStops) > 0 {
+		report.Unsupported = append(report.Unsupported, fmt.Sprintf("stops.txt: %v platforms and entrances merged into their parent station", len(childStops)))
+	}
+
+	return nil
+}
+
+func (importer *importer) importTrips(ctx context.Context, files map[string][]record, report *ImportReport) {
+	weekdaysByService := map[string][]time.Weekday{}
+	for _, service := range files["calendar.txt"] {
+		weekdays := []time.Weekday{}
+		for _, column := range calendarColumns {
+			if service[column.name] == "1" {
+				weekdays = append(weekdays, column.weekday)
+			}
+		}
+		weekdaysByService[service["service_id"]] = weekdays
+	}
+
+	priceByRoute := fares(files["fare_attributes.txt"], files["fare_rules.txt"])
+
+	stopTimesByTrip := map[string][]record{}
+	for _, stopTime := range files["stop_times.txt"] {
+		stopTimesByTrip[stopTime["trip_id"]] = append(stopTimesByTrip[stopTime["trip_id"]], stopTime)
+	}
+
+	imported := map[model.Trip]bool{}
+	intermediateStops := 0
+	collapsedTrips := 0
+	routesWithoutFare := map[string]bool{}
+
+	for _, gtfsTrip := range files["trips.txt"] {
+		tripId := gtfsTrip["trip_id"]
+
+		stopTimes := stopTimesByTrip[tripId]
+		sort.SliceStable(stopTimes, func(i, j int) bool {
+			first, _ := strconv.Atoi(stopTimes[i]["stop_sequence"])
+			second, _ := strconv.Atoi(stopTimes[j]["stop_sequence"])
+			return first < second
+		})
+		if len(stopTimes) < 2 {
+			report.Errors = append(report.Errors, fmt.Sprintf("trip %v: less than 2 stop times", tripId))
+			continue
+		}
+
+		originId, ok := report.StopCities[stopTimes[0]["stop_id"]]
+		if !ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("trip %v: unknown stop %v", tripId, stopTimes[0]["stop_id"]))
+			continue
+		}
+		destinationId, ok := report.StopCities[stopTimes[len(stopTimes)-1]["stop_id"]]
+		if !ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("trip %v: unknown stop %v", tripId, stopTimes[len(stopTimes)-1]["stop_id"]))
+			continue
+		}
+
+		weekdays, ok := weekdaysByService[gtfsTrip["service_id"]]
+		if !ok || len(weekdays) == 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf("trip %v: service %v has no weekly calendar", tripId, gtfsTrip["service_id"]))
+			continue
+		}
+
+		price, ok := priceByRoute[gtfsTrip["route_id"]]
+		if !ok {
+			routesWithoutFare[gtfsTrip["route_id"]] = true
+		}
+
+		trip := model.Trip{
+			OriginId:      originId,
+			DestinationId: destinationId,
+			Dates:         model.FormatDates(weekdays),
+			Price:         price,
+		}
+
+		// Departure times are not stored, so trips that only differ in time are the same trip
+		if imported[trip] {
+			collapsedTrips++
+			continue
+		}
+
+		_, err := importer.tripService.AddTrip(ctx, trip)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("trip %v: %v", tripId, err))
+			continue
+		}
+
+		imported[trip] = true
+		intermediateStops += len(stopTimes) - 2
+		report.TripsCreated++
+	}
+
+	if intermediateStops > 0 {
+		report.Unsupported = append(report.Unsupported, fmt.Sprintf("stop_times.txt: %v intermediate stops ignored, trips go from their first to their last stop", intermediateStops))
+	}
+	if collapsedTrips > 0 {
+		report.Unsupported = append(report.Unsupported, fmt.Sprintf("stop_times.txt: %v trips only differing in departure time merged", collapsedTrips))
+	}
+	if len(routesWithoutFare) > 0 {
+		report.Unsupported = append(report.Unsupported, fmt.Sprintf("fare_rules.txt: %v routes without a fare imported with price 0", len(routesWithoutFare)))
+	}
+}
+
+// fares returns the price of every route with a fare rule
+func fares(fareAttributes []record, fareRules []record) map[string]float64 {
+	priceByFare := map[string]float64{}
+	for _, fare := range fareAttributes {
+		price, err := strconv.ParseFloat(fare["price"], 64)
+		if err == nil {
+			priceByFare[fare["fare_id"]] = price
+		}
+	}
+
+	priceByRoute := map[string]float64{}
+	for _, rule := range fareRules {
+		price, ok := priceByFare[rule["fare_id"]]
+		if ok && rule["route_id"] != "" {
+			priceByRoute[rule["route_id"]] = price
+		}
+	}
+
+	return priceByRoute
+}
+
+func readRecords(file *zip.File) ([]record, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxFileSize + 1}
+	csvReader := csv.NewReader(limited)
+	csvReader.FieldsPerRecord = -1
+
+	rows, err := csvReader.ReadAll()
+	if limited.N <= 0 {
+		return nil, fmt.Errorf("%w: over %v bytes", ErrorFileTooLarge, maxFileSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []record{}, nil
+	}
+
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	records := []record{}
+	for _, row := range rows[1:] {
+		current := record{}
+		for i, value := range row {
+			if i < len(header) {
+				current[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		records = append(records, current)
+	}
+
+	return records, nil
+}
+
+// countRows returns the number of rows of a file after its header, reading
+// them one at a time as they are not kept
+func countRows(file *zip.File) (int, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	limited := &io.LimitedReader{R: reader, N: maxFileSize + 1}
+	csvReader := csv.NewReader(limited)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	rows := 0
+	for {
+		_, err := csvReader.Read()
+		if limited.N <= 0 {
+			return 0, fmt.Errorf("%w: over %v bytes", ErrorFileTooLarge, maxFileSize)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		rows++
+	}
+
+	if rows == 0 {
+		return 0, nil
+	}
+	return rows - 1, nil
+}
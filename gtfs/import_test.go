@@ -0,0 +1,227 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type mockCityService struct {
+	cities []model.City
+}
+
+func (mockCityService *mockCityService) GetCityByName(name string) (model.City, bool, error) {
+	for _, city := range mockCityService.cities {
+		if strings.EqualFold(city.Name, name) {
+			return city, true, nil
+		}
+	}
+
+	return model.City{}, false, nil
+}
+
+func (mockCityService *mockCityService) AddCity(ctx context.Context, city model.City) (model.City, error) {
+	city.Id = int32(len(mockCityService.cities) + 1)
+	mockCityService.cities = append(mockCityService.cities, city)
+	return city, nil
+}
+
+type mockTripService struct {
+	trips []model.Trip
+}
+
+func (mockTripService *mockTripService) AddTrip(ctx context.Context, trip model.Trip) (model.Trip, error) {
+	if trip.OriginId == trip.DestinationId {
+		return model.Trip{}, errors.New("test error")
+	}
+
+	trip.Id = int32(len(mockTripService.trips) + 1)
+	mockTripService.trips = append(mockTripService.trips, trip)
+	return trip, nil
+}
+
+func buildFeed(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		writer.Write([]byte(content))
+	}
+
+	err := archive.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+var testFeed = map[string]string{
+	"stops.txt": "stop_id,stop_name,stop_lat,stop_lon,location_type,parent_station\n" +
+		"BCN,Barcelona,41.38,2.17,1,\n" +
+		"BCN-1,Barcelona platform 1,41.38,2.17,0,BCN\n" +
+		"ZGZ,Zaragoza,41.65,-0.88,,\n" +
+		"MAD,Madrid,40.41,-3.70,,\n",
+	"routes.txt": "route_id,agency_id,route_short_name,route_type\n" +
+		"R1,x,R1,3\n",
+	"trips.txt": "route_id,service_id,trip_id,shape_id\n" +
+		"R1,WEEKDAYS,T1,S1\n" +
+		"R1,WEEKDAYS,T2,S1\n" +
+		"R1,HOLIDAYS,T3,S1\n" +
+		"R1,WEEKEND,T4,S1\n",
+	"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+		"T1,12:00:00,12:00:00,MAD,3\n" +
+		"T1,08:00:00,08:00:00,BCN-1,1\n" +
+		"T1,10:00:00,10:05:00,ZGZ,2\n" +
+		"T2,16:00:00,16:00:00,BCN-1,1\n" +
+		"T2,20:00:00,20:00:00,MAD,2\n" +
+		"T3,08:00:00,08:00:00,BCN,1\n" +
+		"T3,12:00:00,12:00:00,MAD,2\n" +
+		"T4,08:00:00,08:00:00,MAD,1\n" +
+		"T4,12:00:00,12:00:00,BCN,2\n",
+	"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+		"WEEKDAYS,1,1,1,1,1,0,0,20260101,20261231\n" +
+		"WEEKEND,0,0,0,0,0,1,1,20260101,20261231\n",
+	"calendar_dates.txt": "service_id,date,exception_type\n" +
+		"HOLIDAYS,20260101,1\n",
+	"fare_attributes.txt": "fare_id,price,currency_type,payment_method,transfers\n" +
+		"F1,25.50,EUR,0,0\n",
+	"fare_rules.txt": "fare_id,route_id\n" +
+		"F1,R1\n",
+	"shapes.txt": "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" +
+		"S1,41.38,2.17,1\n",
+}
+
+func TestImport_1(t *testing.T) {
+	cityService := &mockCityService{cities: []model.City{{Id: 1, Name: "Madrid"}}}
+	tripService := &mockTripService{}
+
+	feed := buildFeed(t, testFeed)
+	report, err := NewImporter(cityService, tripService).Import(context.Background(), bytes.NewReader(feed), int64(len(feed)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.CitiesCreated != 2 || report.CitiesMatched != 1 {
+		t.Fatalf("expected 2 cities created and 1 matched, got %v", report)
+	}
+	if report.StopCities["MAD"] != 1 || report.StopCities["BCN-1"] != report.StopCities["BCN"] {
+		t.Fatalf("expected stops to be mapped to cities, got %v", report.StopCities)
+	}
+	if cityService.cities[1].Latitude != 41.38 || cityService.cities[1].Longitude != 2.17 {
+		t.Fatalf("expected created city to have the stop coordinates, got %v", cityService.cities[1])
+	}
+
+	expected := []model.Trip{
+		{Id: 1, OriginId: 2, DestinationId: 1, Dates: "Mon Tue Wed Thu Fri", Price: 25.5},
+		{Id: 2, OriginId: 1, DestinationId: 2, Dates: "Sat Sun", Price: 25.5},
+	}
+	if report.TripsCreated != 2 || len(tripService.trips) != 2 {
+		t.Fatalf("expected %v trips to be created, got %v", 2, tripService.trips)
+	}
+	for i, trip := range expected {
+		if tripService.trips[i] != trip {
+			t.Fatalf("expected %v, got %v", trip, tripService.trips[i])
+		}
+	}
+
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "T3") {
+		t.Fatalf("expected trip without weekly calendar to be reported, got %v", report.Errors)
+	}
+
+	unsupported := strings.Join(report.Unsupported, "\n")
+	for _, expected := range []string{"shapes.txt: 1 rows", "calendar_dates.txt: 1 rows", "intermediate stops", "merged into their parent station", "only differing in departure time"} {
+		if !strings.Contains(unsupported, expected) {
+			t.Fatalf("expected unsupported report to mention %v, got %v", expected, report.Unsupported)
+		}
+	}
+}
+
+func TestImport_2(t *testing.T) {
+	files := map[string]string{"stops.txt": testFeed["stops.txt"]}
+
+	feed := buildFeed(t, files)
+	_, err := NewImporter(&mockCityService{}, &mockTripService{}).Import(context.Background(), bytes.NewReader(feed), int64(len(feed)))
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestImport_3(t *testing.T) {
+	feed := []byte("not a zip file")
+	_, err := NewImporter(&mockCityService{}, &mockTripService{}).Import(context.Background(), bytes.NewReader(feed), int64(len(feed)))
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestImport_4(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Export(&buffer, testCities, testTrips, testOptions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cityService := &mockCityService{cities: append([]model.City{}, testCities...)}
+	tripService := &mockTripService{}
+
+	report, err := NewImporter(cityService, tripService).Import(context.Background(), bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.CitiesMatched != len(testCities) || report.CitiesCreated != 0 {
+		t.Fatalf("expected exported cities to be matched, got %v", report)
+	}
+	if report.TripsCreated != len(testTrips) {
+		t.Fatalf("expected %v trips to be created, got %v", len(testTrips), report)
+	}
+	for i, trip := range testTrips {
+		imported := tripService.trips[i]
+		if imported.OriginId != trip.OriginId || imported.DestinationId != trip.DestinationId || imported.Dates != trip.Dates {
+			t.Fatalf("expected %v, got %v", trip, imported)
+		}
+	}
+}
+
+func TestImport_5(t *testing.T) {
+	defer func(previous int64) { maxFileSize = previous }(maxFileSize)
+	maxFileSize = 1024
+
+	// Decompressed to more than the limit, from a few bytes in the zip file
+	large := "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" + strings.Repeat("S1,41.38,2.17,1\n", 100)
+
+	for _, name := range []string{"shapes.txt", "stop_times.txt"} {
+		files := map[string]string{}
+		for file, content := range testFeed {
+			files[file] = content
+		}
+		files[name] = large
+
+		feed := buildFeed(t, files)
+		_, err := NewImporter(&mockCityService{}, &mockTripService{}).Import(context.Background(), bytes.NewReader(feed), int64(len(feed)))
+		if !errors.Is(err, ErrorFileTooLarge) {
+			t.Fatalf("%v: expected error: %v, got error: %v", name, ErrorFileTooLarge, err)
+		}
+	}
+
+	// Files the import does not read are not decompressed
+	files := map[string]string{"agency.txt": large}
+	for file, content := range testFeed {
+		files[file] = content
+	}
+
+	feed := buildFeed(t, files)
+	if _, err := NewImporter(&mockCityService{}, &mockTripService{}).Import(context.Background(), bytes.NewReader(feed), int64(len(feed))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrorDraining = errors.New("server is shutting down")
+
+type Check func() error
+
+type checkResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+type report struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type registry struct {
+	checks   []namedCheck
+	draining int32
+	lock     sync.RWMutex
+}
+
+func NewRegistry() *registry {
+	return &registry{}
+}
+
+// Register adds a dependency check that has to pass for the application to be ready
+func (registry *registry) Register(name string, check Check) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.checks = append(registry.checks, namedCheck{name: name, check: check})
+}
+
+// SetDraining marks the application as shutting down, failing every following readiness check
+func (registry *registry) SetDraining() {
+	atomic.StoreInt32(&registry.draining, 1)
+}
+
+func (registry *registry) IsDraining() bool {
+	return atomic.LoadInt32(&registry.draining) == 1
+}
+
+// Run executes every registered check and returns whether all of them passed
+func (registry *registry) Run() (bool, []checkResult) {
+	registry.lock.RLock()
+	checks := append([]namedCheck{{name: "shutdown", check: registry.checkDraining}}, registry.checks...)
+	registry.lock.RUnlock()
+
+	ready := true
+	results := []checkResult{}
+
+	for _, namedCheck := range checks {
+		start := time.Now()
+		err := namedCheck.check()
+		result := checkResult{
+			Name:      namedCheck.name,
+			Status:    "ok",
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+
+		if err != nil {
+			ready = false
+			result.Status = "fail"
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	return ready, results
+}
+
+func (registry *registry) checkDraining() error {
+	if registry.IsDraining() {
+		return ErrorDraining
+	}
+
+	return nil
+}
+
+func (registry *registry) Liveness(w http.ResponseWriter, req *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok", Checks: []checkResult{}})
+}
+
+func (registry *registry) Readiness(w http.ResponseWriter, req *http.Request) {
+	ready, results := registry.Run()
+
+	if !ready {
+		writeReport(w, http.StatusServiceUnavailable, report{Status: "fail", Checks: results})
+		return
+	}
+
+	writeReport(w, http.StatusOK, report{Status: "ok", Checks: results})
+}
+
+func SetRoutes(router *mux.Router, registry *registry) *mux.Router {
+	router.HandleFunc("/healthz", registry.Liveness).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", registry.Readiness).Methods(http.MethodGet)
+
+	return router
+}
+
+func writeReport(w http.ResponseWriter, status int, report report) {
+	body, _ := json.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRun_1(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("ok", func() error { return nil })
+
+	ready, results := registry.Run()
+	if !ready {
+		t.Fatalf("expected registry to be ready, got %v", results)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected %v check results, got %v", 2, len(results))
+	}
+}
+
+func TestRun_2(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("ok", func() error { return nil })
+	registry.Register("broken", func() error { return errors.New("test error") })
+
+	ready, results := registry.Run()
+	if ready {
+		t.Fatalf("expected registry not to be ready, got %v", results)
+	}
+	if results[2].Status != "fail" || results[2].Error != "test error" {
+		t.Fatalf("expected failed check with error %v, got %v", "test error", results[2])
+	}
+}
+
+func TestRun_3(t *testing.T) {
+	registry := NewRegistry()
+	registry.SetDraining()
+
+	ready, results := registry.Run()
+	if ready {
+		t.Fatalf("expected draining registry not to be ready, got %v", results)
+	}
+	if results[0].Error != ErrorDraining.Error() {
+		t.Fatalf("expected error: %v, got error: %v", ErrorDraining, results[0].Error)
+	}
+}
+
+func TestReadiness_1(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("cities", func() error { return nil })
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	registry.Readiness(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+
+	var result report
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != "ok" || len(result.Checks) != 2 || result.Checks[1].Name != "cities" {
+		t.Fatalf("expected ok report including the cities check, got %v", result)
+	}
+}
+
+func TestReadiness_2(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("cities", func() error { return errors.New("test error") })
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	registry.Readiness(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusServiceUnavailable, responseRecorder.Code)
+	}
+}
+
+func TestLiveness_1(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("cities", func() error { return errors.New("test error") })
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	responseRecorder := httptest.NewRecorder()
+
+	registry.Liveness(responseRecorder, req)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusOK, responseRecorder.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const productId = "-//PackAndGo//Timetable//EN"
+
+// Every schedule is published in the time zone the company operates in
+const Timezone = "Europe/Madrid"
+
+const vtimezone = "BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Madrid\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"TZNAME:CEST\r\n" +
+	"DTSTART:19700329T020000\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"TZNAME:CET\r\n" +
+	"DTSTART:19701025T030000\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n"
+
+var byDay = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Event is a weekly recurring event
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Weekdays    []time.Weekday
+	// Offset from midnight of the start of every occurrence, in Timezone
+	StartTime time.Duration
+	Duration  time.Duration
+}
+
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Write writes calendar as an RFC 5545 iCalendar stream. The first occurrence
+// of every event is the first of its weekdays on or after the day of now.
+func Write(w io.Writer, calendar Calendar, now time.Time) error {
+	writer := &lineWriter{w: w}
+
+	writer.line("BEGIN:VCALENDAR")
+	writer.line("VERSION:2.0")
+	writer.line("PRODID:" + productId)
+	writer.line("CALSCALE:GREGORIAN")
+	writer.line("METHOD:PUBLISH")
+	if calendar.Name != "" {
+		writer.line("X-WR-CALNAME:" + escape(calendar.Name))
+	}
+	writer.line("X-WR-TIMEZONE:" + Timezone)
+	writer.raw(vtimezone)
+
+	today := localDate(now)
+	dtstamp := now.UTC().Format("20060102T150405Z")
+
+	for _, event := range calendar.Events {
+		if len(event.Weekdays) == 0 {
+			continue
+		}
+
+		start := firstOccurrence(today, event.Weekdays).Add(event.StartTime)
+		end := start.Add(event.Duration)
+
+		days := []string{}
+		for _, weekday := range event.Weekdays {
+			days = append(days, byDay[weekday])
+		}
+
+		writer.line("BEGIN:VEVENT")
+		writer.line("UID:" + event.UID)
+		writer.line("DTSTAMP:" + dtstamp)
+		writer.line(fmt.Sprintf("DTSTART;TZID=%v:%v", Timezone, start.Format("20060102T150405")))
+		writer.line(fmt.Sprintf("DTEND;TZID=%v:%v", Timezone, end.Format("20060102T150405")))
+		writer.line("RRULE:FREQ=WEEKLY;BYDAY=" + strings.Join(days, ","))
+		writer.line("SUMMARY:" + escape(event.Summary))
+		if event.Location != "" {
+			writer.line("LOCATION:" + escape(event.Location))
+		}
+		if event.Description != "" {
+			writer.line("DESCRIPTION:" + escape(event.Description))
+		}
+		writer.line("END:VEVENT")
+	}
+
+	writer.line("END:VCALENDAR")
+
+	return writer.err
+}
+
+// localDate returns midnight of the day of now in Timezone, as a wall clock time in UTC
+func localDate(now time.Time) time.Time {
+	if location, err := time.LoadLocation(Timezone); err == nil {
+		now = now.In(location)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func firstOccurrence(date time.Time, weekdays []time.Weekday) time.Time {
+	for i := 0; i < 7; i++ {
+		day := date.AddDate(0, 0, i)
+		for _, weekday := range weekdays {
+			if day.Weekday() == weekday {
+				return day
+			}
+		}
+	}
+
+	return date
+}
+
+// escape escapes a TEXT value as described in RFC 5545 section 3.3.11
+func escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+// line writes a content line, folded so no line is longer than 75 octets
+func (lineWriter *lineWriter) line(content string) {
+	folded := strings.Builder{}
+	limit := 75
+
+	for len(content) > limit {
+		cut := limit
+		// Do not split multi-byte UTF-8 characters
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		folded.WriteString(content[:cut])
+		folded.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = 74
+	}
+
+	folded.WriteString(content)
+	folded.WriteString("\r\n")
+
+	lineWriter.raw(folded.String())
+}
+
+func (lineWriter *lineWriter) raw(content string) {
+	if lineWriter.err != nil {
+		return
+	}
+
+	_, lineWriter.err = io.WriteString(lineWriter.w, content)
+}
@@ -0,0 +1,96 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+var testEvent = Event{
+	UID:         "trip-1@packandgo",
+	Summary:     "Barcelona - Seville",
+	Location:    "Barcelona",
+	Description: "Price: 40.55; seats, limited",
+	Weekdays:    []time.Weekday{time.Wednesday, time.Friday},
+	StartTime:   8 * time.Hour,
+	Duration:    90 * time.Minute,
+}
+
+func TestWrite_1(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Write(&buffer, Calendar{Name: "Trip 1", Events: []Event{testEvent}}, testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := buffer.String()
+	expectedLines := []string{
+		"BEGIN:VCALENDAR\r\n",
+		"VERSION:2.0\r\n",
+		"TZID:Europe/Madrid\r\n",
+		"UID:trip-1@packandgo\r\n",
+		"DTSTAMP:20261019T100000Z\r\n",
+		"DTSTART;TZID=Europe/Madrid:20261021T080000\r\n",
+		"DTEND;TZID=Europe/Madrid:20261021T093000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=WE,FR\r\n",
+		"SUMMARY:Barcelona - Seville\r\n",
+		"DESCRIPTION:Price: 40.55\\; seats\\, limited\r\n",
+		"END:VCALENDAR\r\n",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(result, line) {
+			t.Fatalf("expected %q to include %q", result, line)
+		}
+	}
+
+	if strings.Count(result, "BEGIN:VEVENT") != 1 || strings.Count(result, "END:VEVENT") != 1 {
+		t.Fatalf("expected a single event, got %v", result)
+	}
+}
+
+func TestWrite_2(t *testing.T) {
+	event := testEvent
+	event.Summary = strings.Repeat("Andorra la Vella - ", 10)
+
+	var buffer bytes.Buffer
+	err := Write(&buffer, Calendar{Events: []Event{event}}, testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, line := range strings.Split(buffer.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("expected lines to be folded at 75 octets, got %v", line)
+		}
+	}
+
+	unfolded := strings.Replace(buffer.String(), "\r\n ", "", -1)
+	if !strings.Contains(unfolded, "SUMMARY:"+event.Summary+"\r\n") {
+		t.Fatalf("expected unfolded calendar to include the whole summary, got %v", unfolded)
+	}
+}
+
+func TestWrite_3(t *testing.T) {
+	event := testEvent
+	event.Weekdays = []time.Weekday{time.Monday}
+
+	var buffer bytes.Buffer
+	err := Write(&buffer, Calendar{Events: []Event{event}}, testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buffer.String(), "DTSTART;TZID=Europe/Madrid:20261019T080000\r\n") {
+		t.Fatalf("expected first occurrence to be today, got %v", buffer.String())
+	}
+}
+
+func TestEscape_1(t *testing.T) {
+	result := escape("a\\b;c,d\ne")
+	if result != `a\\b\;c\,d\ne` {
+		t.Fatalf("expected %v, got %v", `a\\b\;c\,d\ne`, result)
+	}
+}
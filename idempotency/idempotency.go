@@ -0,0 +1,189 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const HeaderKey = "Idempotency-Key"
+const HeaderReplayed = "Idempotent-Replayed"
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+type entry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	response    *response
+	expiresAt   time.Time
+}
+
+type store struct {
+	ttl     time.Duration
+	entries map[string]*entry
+	lock    sync.Mutex
+	now     func() time.Time
+}
+
+func NewStore(ttl time.Duration) *store {
+	return &store{ttl: ttl, entries: map[string]*entry{}, now: time.Now}
+}
+
+// Middleware makes the wrapped handler safe to retry. The first response for
+// an Idempotency-Key is stored for the configured TTL and replayed for every
+// retry with the same request body, while the same key with a different body
+// is rejected with 422 Unprocessable Entity. Requests without a key are
+// passed through untouched.
+func (store *store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		requestBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request - could not read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+
+		// Keys are scoped to the credentials of the caller, so a customer can
+		// never be replayed the response to somebody else's request
+		credentials := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+		scopedKey := fmt.Sprintf("%v %v %x %v", req.Method, req.URL.Path, credentials, key)
+		fingerprint := sha256.Sum256(requestBody)
+
+		current, owner := store.acquire(scopedKey, fingerprint)
+		if current.fingerprint != fingerprint {
+			http.Error(w, "Unprocessable Entity - idempotency key already used with a different request body", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if !owner {
+			<-current.done
+			if current.response == nil {
+				// The original request failed and was not stored, so this one takes over
+				req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+				store.Middleware(next).ServeHTTP(w, req)
+				return
+			}
+
+			current.response.write(w, true)
+			return
+		}
+
+		var result *response
+		defer func() {
+			store.release(scopedKey, current, result)
+		}()
+
+		recorder := newRecorder()
+		next.ServeHTTP(recorder, req)
+		result = recorder.response()
+
+		result.write(w, false)
+	})
+}
+
+// acquire returns the entry for key, creating it if it is missing or expired.
+// owner is true when the caller created the entry and has to release it.
+func (store *store) acquire(key string, fingerprint [sha256.Size]byte) (*entry, bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.purgeExpired()
+
+	if current, ok := store.entries[key]; ok {
+		return current, false
+	}
+
+	current := &entry{fingerprint: fingerprint, done: make(chan struct{})}
+	store.entries[key] = current
+	return current, true
+}
+
+// release stores the response of a finished request. Server errors and
+// requests that did not finish are not stored, so the client can retry them
+// with the same key.
+func (store *store) release(key string, current *entry, response *response) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if response == nil || response.status >= http.StatusInternalServerError {
+		delete(store.entries, key)
+	} else {
+		current.response = response
+		current.expiresAt = store.now().Add(store.ttl)
+	}
+
+	close(current.done)
+}
+
+func (store *store) purgeExpired() {
+	now := store.now()
+
+	for key, current := range store.entries {
+		if current.response != nil && now.After(current.expiresAt) {
+			delete(store.entries, key)
+		}
+	}
+}
+
+func (response *response) write(w http.ResponseWriter, replayed bool) {
+	for name, values := range response.header {
+		w.Header()[name] = values
+	}
+	if replayed {
+		w.Header().Set(HeaderReplayed, "true")
+	}
+
+	w.WriteHeader(response.status)
+	w.Write(response.body)
+}
+
+type recorder struct {
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}}
+}
+
+func (recorder *recorder) Header() http.Header {
+	return recorder.header
+}
+
+func (recorder *recorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+}
+
+func (recorder *recorder) Write(body []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+
+	return recorder.body.Write(body)
+}
+
+func (recorder *recorder) response() *response {
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &response{status: status, header: recorder.header.Clone(), body: recorder.body.Bytes()}
+}
@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mockHandler struct {
+	calls  int32
+	status int
+	delay  time.Duration
+}
+
+func (mockHandler *mockHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	calls := atomic.AddInt32(&mockHandler.calls, 1)
+	time.Sleep(mockHandler.delay)
+
+	body, _ := ioutil.ReadAll(req.Body)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(mockHandler.status)
+	w.Write([]byte(string(body) + " " + string(rune('0'+calls))))
+}
+
+func newRequest(key string, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/trip", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	return req
+}
+
+func TestMiddleware_1(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	first := httptest.NewRecorder()
+	middleware.ServeHTTP(first, newRequest("key", "body"))
+
+	second := httptest.NewRecorder()
+	middleware.ServeHTTP(second, newRequest("key", "body"))
+
+	if handler.calls != 1 {
+		t.Fatalf("expected handler to be called %v times, got %v", 1, handler.calls)
+	}
+	if second.Code != http.StatusCreated {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusCreated, second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected %v, got %v", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get(HeaderReplayed) != "true" || second.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected replayed response headers, got %v", second.Header())
+	}
+}
+
+func TestMiddleware_2(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("key", "body"))
+
+	responseRecorder := httptest.NewRecorder()
+	middleware.ServeHTTP(responseRecorder, newRequest("key", "other body"))
+
+	if responseRecorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected response code to be %v, got %v", http.StatusUnprocessableEntity, responseRecorder.Code)
+	}
+	if handler.calls != 1 {
+		t.Fatalf("expected handler to be called %v times, got %v", 1, handler.calls)
+	}
+}
+
+func TestMiddleware_3(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("", "body"))
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("", "body"))
+
+	if handler.calls != 2 {
+		t.Fatalf("expected handler to be called %v times, got %v", 2, handler.calls)
+	}
+}
+
+func TestMiddleware_4(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated}
+	store := NewStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	middleware := store.Middleware(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("key", "body"))
+
+	now = now.Add(2 * time.Minute)
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("key", "body"))
+
+	if handler.calls != 2 {
+		t.Fatalf("expected expired key to be processed again, handler called %v times", handler.calls)
+	}
+}
+
+func TestMiddleware_5(t *testing.T) {
+	handler := &mockHandler{status: http.StatusInternalServerError}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("key", "body"))
+	middleware.ServeHTTP(httptest.NewRecorder(), newRequest("key", "body"))
+
+	if handler.calls != 2 {
+		t.Fatalf("expected server errors not to be stored, handler called %v times", handler.calls)
+	}
+}
+
+func TestMiddleware_6(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated, delay: 50 * time.Millisecond}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 10)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(responseRecorder *httptest.ResponseRecorder) {
+			defer wg.Done()
+			middleware.ServeHTTP(responseRecorder, newRequest("key", "body"))
+		}(responses[i])
+	}
+	wg.Wait()
+
+	if handler.calls != 1 {
+		t.Fatalf("expected concurrent duplicates to call handler %v times, got %v", 1, handler.calls)
+	}
+	for _, responseRecorder := range responses {
+		if responseRecorder.Code != http.StatusCreated || responseRecorder.Body.String() != "body 1" {
+			t.Fatalf("expected every response to be %v %v, got %v %v", http.StatusCreated, "body 1", responseRecorder.Code, responseRecorder.Body.String())
+		}
+	}
+}
+
+func TestMiddleware_7(t *testing.T) {
+	handler := &mockHandler{status: http.StatusCreated}
+	middleware := NewStore(time.Hour).Middleware(handler)
+
+	alice := newRequest("key", "body")
+	alice.Header.Set("Authorization", "Bearer alice")
+	middleware.ServeHTTP(httptest.NewRecorder(), alice)
+
+	bob := newRequest("key", "body")
+	bob.Header.Set("Authorization", "Bearer bob")
+	responseRecorder := httptest.NewRecorder()
+	middleware.ServeHTTP(responseRecorder, bob)
+
+	if handler.calls != 2 {
+		t.Fatalf("expected the same key from different callers to call handler %v times, got %v", 2, handler.calls)
+	}
+	if responseRecorder.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("expected response not to be replayed to a different caller")
+	}
+}
@@ -0,0 +1,284 @@
+package kv
+
+import "sort"
+
+// degree is the minimum degree of the B-tree: every node but the root holds
+// between degree-1 and 2*degree-1 items
+const degree = 32
+
+const (
+	maxItems = 2*degree - 1
+	minItems = degree - 1
+)
+
+type item struct {
+	key   string
+	value []byte
+}
+
+type node struct {
+	items    []item
+	children []*node
+}
+
+func newNode() *node {
+	return &node{}
+}
+
+// btree keeps items sorted by key. Nodes that are full are split on the way
+// down an insertion, and nodes at the minimum grow on the way down a removal,
+// so neither has to walk back up the tree.
+type btree struct {
+	root   *node
+	length int
+}
+
+func (btree *btree) get(key string) ([]byte, bool) {
+	node := btree.root
+	for node != nil {
+		i, found := node.find(key)
+		if found {
+			return node.items[i].value, true
+		}
+		if len(node.children) == 0 {
+			return nil, false
+		}
+		node = node.children[i]
+	}
+
+	return nil, false
+}
+
+// set adds or replaces the value of key, returning the replaced value
+func (btree *btree) set(key string, value []byte) ([]byte, bool) {
+	if btree.root == nil {
+		btree.root = &node{items: []item{{key, value}}}
+		btree.length++
+		return nil, false
+	}
+
+	if len(btree.root.items) >= maxItems {
+		middle, second := btree.root.split(maxItems / 2)
+		btree.root = &node{items: []item{middle}, children: []*node{btree.root, second}}
+	}
+
+	previous, replaced := btree.root.insert(item{key, value})
+	if !replaced {
+		btree.length++
+	}
+
+	return previous, replaced
+}
+
+// delete removes key, returning its value
+func (btree *btree) delete(key string) ([]byte, bool) {
+	if btree.root == nil || len(btree.root.items) == 0 {
+		return nil, false
+	}
+
+	removed, ok := btree.root.remove(key)
+	if len(btree.root.items) == 0 && len(btree.root.children) > 0 {
+		btree.root = btree.root.children[0]
+	}
+	if ok {
+		btree.length--
+	}
+
+	return removed.value, ok
+}
+
+// ascend calls fn with every item from the key from onwards, in order, until fn returns false
+func (btree *btree) ascend(from string, fn func(item) bool) {
+	if btree.root != nil {
+		btree.root.ascend(from, fn)
+	}
+}
+
+// find returns the index of key in the node, or the index of the child it would be under
+func (node *node) find(key string) (int, bool) {
+	i := sort.Search(len(node.items), func(i int) bool {
+		return key <= node.items[i].key
+	})
+
+	return i, i < len(node.items) && node.items[i].key == key
+}
+
+// split leaves the items before i in the node and returns the item at i and
+// a new node with the items after it
+func (node *node) split(i int) (item, *node) {
+	middle := node.items[i]
+
+	next := newNode()
+	next.items = append(next.items, node.items[i+1:]...)
+	node.items = append(node.items[:0:0], node.items[:i]...)
+
+	if len(node.children) > 0 {
+		next.children = append(next.children, node.children[i+1:]...)
+		node.children = append(node.children[:0:0], node.children[:i+1]...)
+	}
+
+	return middle, next
+}
+
+func (node *node) insert(newItem item) ([]byte, bool) {
+	i, found := node.find(newItem.key)
+	if found {
+		previous := node.items[i].value
+		node.items[i] = newItem
+		return previous, true
+	}
+
+	if len(node.children) == 0 {
+		node.items = insertItemAt(node.items, i, newItem)
+		return nil, false
+	}
+
+	if len(node.children[i].items) >= maxItems {
+		middle, second := node.children[i].split(maxItems / 2)
+		node.items = insertItemAt(node.items, i, middle)
+		node.children = insertChildAt(node.children, i+1, second)
+
+		switch {
+		case newItem.key == middle.key:
+			previous := node.items[i].value
+			node.items[i] = newItem
+			return previous, true
+		case newItem.key > middle.key:
+			i++
+		}
+	}
+
+	return node.children[i].insert(newItem)
+}
+
+// remove deletes key from the subtree of the node, which has more than the
+// minimum items unless it is the root
+func (node *node) remove(key string) (item, bool) {
+	i, found := node.find(key)
+
+	if len(node.children) == 0 {
+		if !found {
+			return item{}, false
+		}
+		removed := node.items[i]
+		node.items = removeItemAt(node.items, i)
+		return removed, true
+	}
+
+	if len(node.children[i].items) <= minItems {
+		node.growChild(i)
+		return node.remove(key)
+	}
+
+	if found {
+		removed := node.items[i]
+		node.items[i] = node.children[i].removeMax()
+		return removed, true
+	}
+
+	return node.children[i].remove(key)
+}
+
+func (node *node) removeMax() item {
+	if len(node.children) == 0 {
+		last := node.items[len(node.items)-1]
+		node.items = node.items[:len(node.items)-1]
+		return last
+	}
+
+	i := len(node.items)
+	if len(node.children[i].items) <= minItems {
+		node.growChild(i)
+		return node.removeMax()
+	}
+
+	return node.children[i].removeMax()
+}
+
+// growChild gives the child at i one more item, taken from a sibling that can
+// spare one or by merging the child with a sibling
+func (node *node) growChild(i int) {
+	if i > 0 && len(node.children[i-1].items) > minItems {
+		child, left := node.children[i], node.children[i-1]
+
+		child.items = insertItemAt(child.items, 0, node.items[i-1])
+		node.items[i-1] = left.items[len(left.items)-1]
+		left.items = left.items[:len(left.items)-1]
+
+		if len(left.children) > 0 {
+			child.children = insertChildAt(child.children, 0, left.children[len(left.children)-1])
+			left.children = left.children[:len(left.children)-1]
+		}
+		return
+	}
+
+	if i < len(node.items) && len(node.children[i+1].items) > minItems {
+		child, right := node.children[i], node.children[i+1]
+
+		child.items = append(child.items, node.items[i])
+		node.items[i] = right.items[0]
+		right.items = removeItemAt(right.items, 0)
+
+		if len(right.children) > 0 {
+			child.children = append(child.children, right.children[0])
+			right.children = removeChildAt(right.children, 0)
+		}
+		return
+	}
+
+	if i >= len(node.items) {
+		i--
+	}
+	child, right := node.children[i], node.children[i+1]
+
+	child.items = append(child.items, node.items[i])
+	child.items = append(child.items, right.items...)
+	child.children = append(child.children, right.children...)
+	node.items = removeItemAt(node.items, i)
+	node.children = removeChildAt(node.children, i+1)
+}
+
+func (node *node) ascend(from string, fn func(item) bool) bool {
+	i, _ := node.find(from)
+
+	for ; i < len(node.items); i++ {
+		if len(node.children) > 0 && !node.children[i].ascend(from, fn) {
+			return false
+		}
+		if !fn(node.items[i]) {
+			return false
+		}
+	}
+
+	if len(node.children) > 0 {
+		return node.children[len(node.items)].ascend(from, fn)
+	}
+
+	return true
+}
+
+func insertItemAt(items []item, i int, newItem item) []item {
+	items = append(items, item{})
+	copy(items[i+1:], items[i:])
+	items[i] = newItem
+	return items
+}
+
+func removeItemAt(items []item, i int) []item {
+	copy(items[i:], items[i+1:])
+	items[len(items)-1] = item{}
+	return items[:len(items)-1]
+}
+
+func insertChildAt(children []*node, i int, child *node) []*node {
+	children = append(children, nil)
+	copy(children[i+1:], children[i:])
+	children[i] = child
+	return children
+}
+
+func removeChildAt(children []*node, i int) []*node {
+	copy(children[i:], children[i+1:])
+	children[len(children)-1] = nil
+	return children[:len(children)-1]
+}
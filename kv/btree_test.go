@@ -0,0 +1,89 @@
+package kv
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBtree_1(t *testing.T) {
+	btree := btree{}
+	expected := map[string]string{}
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 60000; i++ {
+		key := fmt.Sprintf("%05d", random.Intn(20000))
+
+		if random.Intn(3) == 0 {
+			_, existed := expected[key]
+			_, ok := btree.delete(key)
+			if ok != existed {
+				t.Fatalf("expected delete of %v to return %v, got %v", key, existed, ok)
+			}
+			delete(expected, key)
+			continue
+		}
+
+		value := fmt.Sprint(i)
+		btree.set(key, []byte(value))
+		expected[key] = value
+	}
+
+	if btree.length != len(expected) {
+		t.Fatalf("expected %v items, got %v", len(expected), btree.length)
+	}
+
+	keys := []string{}
+	for key, value := range expected {
+		keys = append(keys, key)
+		if found, ok := btree.get(key); !ok || string(found) != value {
+			t.Fatalf("expected %v for %v, got %v %v", value, key, string(found), ok)
+		}
+	}
+	sort.Strings(keys)
+
+	ascended := []string{}
+	btree.ascend("", func(item item) bool {
+		ascended = append(ascended, item.key)
+		return true
+	})
+	if fmt.Sprint(ascended) != fmt.Sprint(keys) {
+		t.Fatalf("expected keys in order %v, got %v", keys, ascended)
+	}
+
+	for _, key := range keys {
+		btree.delete(key)
+	}
+	if btree.length != 0 || len(btree.root.items) != 0 {
+		t.Fatalf("expected an empty tree, got %v items", btree.length)
+	}
+}
+
+func TestBtreeAscend_1(t *testing.T) {
+	btree := btree{}
+	for i := 0; i < 1000; i += 2 {
+		btree.set(fmt.Sprintf("%04d", i), nil)
+	}
+
+	tests := []struct {
+		from     string
+		expected []string
+	}{
+		{from: "0500", expected: []string{"0500", "0502", "0504"}},
+		{from: "0501", expected: []string{"0502", "0504", "0506"}},
+		{from: "0998", expected: []string{"0998"}},
+		{from: "1000", expected: []string{}},
+	}
+
+	for _, test := range tests {
+		keys := []string{}
+		btree.ascend(test.from, func(item item) bool {
+			keys = append(keys, item.key)
+			return len(keys) < 3
+		})
+		if fmt.Sprint(keys) != fmt.Sprint(test.expected) {
+			t.Fatalf("expected %v from %v, got %v", test.expected, test.from, keys)
+		}
+	}
+}
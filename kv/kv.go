@@ -0,0 +1,474 @@
+// Package kv is an embedded key-value store. Keys are kept sorted in a B-tree
+// in memory, and every committed transaction is appended to a log file as a
+// single checksummed record, so a transaction is either fully replayed on the
+// next open or not at all. Once the log holds mostly overwritten or deleted
+// values it is compacted into a new log with only the current ones.
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gbandres98/pack-and-go/filelock"
+)
+
+var ErrorClosed = errors.New("kv store is closed")
+
+// ErrorReadOnly is returned by writes in a transaction started with View
+var ErrorReadOnly = errors.New("kv transaction is read-only")
+
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+// recordHeaderSize is the size of the length and the checksum of the
+// operations that start every record of the log
+const recordHeaderSize = 8
+
+// minCompactionSize is the smallest log that gets compacted, so small stores
+// are not rewritten over and over
+const minCompactionSize = 4 << 20
+
+// DB is a key-value store kept in a single log file. Transactions run one
+// writer at a time, while any number of readers run when there is no writer.
+type DB struct {
+	filePath string
+	file     *os.File
+	tree     btree
+	// size is the size of the log, and liveSize the size its current values
+	// would take after a compaction
+	size     int64
+	liveSize int64
+	lock     sync.RWMutex
+}
+
+// Open opens the store in filePath, creating it if it does not exist. A
+// transaction cut short by a crash at the end of the log is removed.
+func Open(filePath string) (*DB, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{filePath: filePath, file: file}
+
+	err = filelock.Lock(file)
+	if err == nil {
+		err = db.load()
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%v: %w", filePath, err)
+	}
+
+	return db, nil
+}
+
+func (db *DB) load() error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(db.file)
+
+	for {
+		ops, size, err := readRecord(reader, info.Size()-db.size)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF || (err == errorChecksum && db.size+size == info.Size()) {
+			log.Printf("removing incomplete transaction at the end of kv store %v", db.filePath)
+			if err := db.file.Truncate(db.size); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid transaction after %v bytes: %w", db.size, err)
+		}
+
+		for _, op := range ops {
+			db.apply(op)
+		}
+		db.size += size
+	}
+
+	_, err = db.file.Seek(db.size, io.SeekStart)
+	return err
+}
+
+// apply changes the tree with an operation, keeping track of the size of the current values
+func (db *DB) apply(op operation) (previous []byte, existed bool) {
+	if op.kind == opPut {
+		previous, existed = db.tree.set(op.key, op.value)
+		db.liveSize += operationSize(op.key, op.value)
+	} else {
+		previous, existed = db.tree.delete(op.key)
+	}
+
+	if existed {
+		db.liveSize -= operationSize(op.key, previous)
+	}
+
+	return previous, existed
+}
+
+// View runs fn in a read-only transaction
+func (db *DB) View(fn func(*Tx) error) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.file == nil {
+		return ErrorClosed
+	}
+
+	return fn(&Tx{db: db})
+}
+
+// Update runs fn in a read-write transaction. If fn returns an error none of
+// its writes are kept, otherwise all of them are written to the log at once.
+func (db *DB) Update(fn func(*Tx) error) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.file == nil {
+		return ErrorClosed
+	}
+
+	tx := &Tx{db: db, writable: true}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	err := fn(tx)
+	if err == nil {
+		err = db.commit(tx.ops)
+	}
+	if err != nil {
+		return err
+	}
+	committed = true
+
+	if db.size > minCompactionSize && db.size > 2*db.liveSize {
+		if err := db.compact(); err != nil {
+			log.Printf("could not compact kv store %v: %v", db.filePath, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) commit(ops []operation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	record := encodeRecord(ops)
+	_, err := db.file.Write(record)
+	if err == nil {
+		err = db.file.Sync()
+	}
+	if err != nil {
+		// Leave the log as it was, so it still ends with a whole transaction
+		db.file.Truncate(db.size)
+		db.file.Seek(db.size, io.SeekStart)
+		return fmt.Errorf("could not write kv store %v: %w", db.filePath, err)
+	}
+
+	db.size += int64(len(record))
+	return nil
+}
+
+// compact replaces the log with one that only puts the current values, in key
+// order. The new log is written next to the old one and renamed over it, so a
+// crash leaves one of them whole.
+func (db *DB) compact() error {
+	tmpPath := db.filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	size := int64(0)
+	ops := []operation{}
+	opsSize := int64(0)
+
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		record := encodeRecord(ops)
+		size += int64(len(record))
+		ops = ops[:0]
+		opsSize = 0
+		_, err := writer.Write(record)
+		return err
+	}
+
+	db.tree.ascend("", func(item item) bool {
+		ops = append(ops, operation{kind: opPut, key: item.key, value: item.value})
+		opsSize += operationSize(item.key, item.value)
+		if opsSize >= 1<<20 {
+			err = flush()
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	// Locked before it takes the place of the old log, so no other process
+	// opens it unlocked
+	if err == nil {
+		err = filelock.Lock(file)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, db.filePath)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	db.file.Close()
+	db.file = file
+	db.size = size
+	return nil
+}
+
+// Close compacts the log if it is worth it and closes it
+func (db *DB) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.file == nil {
+		return nil
+	}
+
+	if db.size > 2*db.liveSize {
+		if err := db.compact(); err != nil {
+			log.Printf("could not compact kv store %v: %v", db.filePath, err)
+		}
+	}
+
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
+
+// Check verifies that the store is open
+func (db *DB) Check() error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.file == nil {
+		return ErrorClosed
+	}
+
+	return nil
+}
+
+// Len returns the number of keys in the store
+func (db *DB) Len() int {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.tree.length
+}
+
+// Tx is a transaction, only valid inside the function given to View or Update
+type Tx struct {
+	db       *DB
+	writable bool
+	ops      []operation
+	undo     []operation
+}
+
+// Get returns the value of key, or nil if it does not exist. The value must
+// not be modified.
+func (tx *Tx) Get(key string) []byte {
+	value, _ := tx.db.tree.get(key)
+	return value
+}
+
+// Put sets the value of key
+func (tx *Tx) Put(key string, value []byte) error {
+	if !tx.writable {
+		return ErrorReadOnly
+	}
+
+	value = append([]byte{}, value...)
+	tx.write(operation{kind: opPut, key: key, value: value})
+	return nil
+}
+
+// Delete removes key, if it exists
+func (tx *Tx) Delete(key string) error {
+	if !tx.writable {
+		return ErrorReadOnly
+	}
+
+	tx.write(operation{kind: opDelete, key: key})
+	return nil
+}
+
+func (tx *Tx) write(op operation) {
+	previous, existed := tx.db.apply(op)
+	if existed {
+		tx.undo = append(tx.undo, operation{kind: opPut, key: op.key, value: previous})
+	} else {
+		tx.undo = append(tx.undo, operation{kind: opDelete, key: op.key})
+	}
+	tx.ops = append(tx.ops, op)
+}
+
+func (tx *Tx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.db.apply(tx.undo[i])
+	}
+}
+
+// Scan calls fn with every key starting with prefix and its value, in key
+// order, until fn returns false. fn must not write to the transaction.
+func (tx *Tx) Scan(prefix string, fn func(key string, value []byte) bool) {
+	tx.db.tree.ascend(prefix, func(item item) bool {
+		if !strings.HasPrefix(item.key, prefix) {
+			return false
+		}
+		return fn(item.key, item.value)
+	})
+}
+
+type operation struct {
+	kind  byte
+	key   string
+	value []byte
+}
+
+var errorChecksum = errors.New("kv record checksum mismatch")
+
+func operationSize(key string, value []byte) int64 {
+	return int64(1 + 2*binary.MaxVarintLen64 + len(key) + len(value))
+}
+
+// encodeRecord writes the operations of a transaction as the length of the
+// operations, their CRC-32 and the operations, each as its kind, the length
+// and bytes of its key, and for puts the length and bytes of its value
+func encodeRecord(ops []operation) []byte {
+	record := make([]byte, recordHeaderSize)
+	for _, op := range ops {
+		record = append(record, op.kind)
+		record = appendUvarint(record, uint64(len(op.key)))
+		record = append(record, op.key...)
+		if op.kind == opPut {
+			record = appendUvarint(record, uint64(len(op.value)))
+			record = append(record, op.value...)
+		}
+	}
+
+	body := record[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+
+	return record
+}
+
+func appendUvarint(buffer []byte, value uint64) []byte {
+	var encoded [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(encoded[:], value)
+	return append(buffer, encoded[:n]...)
+}
+
+// readRecord reads the next record of the log, out of the remaining bytes,
+// and returns its operations and its size. It fails with io.ErrUnexpectedEOF
+// or errorChecksum if the record was not fully written.
+func readRecord(reader io.Reader, remaining int64) ([]operation, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	length := int64(binary.LittleEndian.Uint32(header[0:4]))
+	if recordHeaderSize+length > remaining {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, recordHeaderSize + length, errorChecksum
+	}
+
+	ops, err := decodeOperations(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ops, int64(recordHeaderSize + len(body)), nil
+}
+
+func decodeOperations(body []byte) ([]operation, error) {
+	ops := []operation{}
+
+	for len(body) > 0 {
+		op := operation{kind: body[0]}
+		body = body[1:]
+		if op.kind != opPut && op.kind != opDelete {
+			return nil, fmt.Errorf("unknown kv operation %v", op.kind)
+		}
+
+		key, rest, err := readBytes(body)
+		if err != nil {
+			return nil, err
+		}
+		op.key = string(key)
+		body = rest
+
+		if op.kind == opPut {
+			op.value, body, err = readBytes(body)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+func readBytes(buffer []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(buffer)
+	if n <= 0 || uint64(len(buffer)-n) < length {
+		return nil, nil, errors.New("invalid kv operation")
+	}
+
+	end := n + int(length)
+	return buffer[n:end:end], buffer[end:], nil
+}
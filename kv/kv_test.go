@@ -0,0 +1,187 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/filelock"
+)
+
+func openTestDB(t *testing.T) (*DB, string) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := Open(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return db, filePath
+}
+
+func TestUpdate_1(t *testing.T) {
+	db, filePath := openTestDB(t)
+
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("a", []byte("1"))
+		tx.Put("b", []byte("2"))
+		return tx.Put("c", []byte("3"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	failure := errors.New("failure")
+	err = db.Update(func(tx *Tx) error {
+		tx.Put("a", []byte("10"))
+		tx.Delete("b")
+		tx.Put("d", []byte("4"))
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("expected error: %v, got error: %v", failure, err)
+	}
+
+	db.Update(func(tx *Tx) error {
+		return tx.Delete("c")
+	})
+	db.Close()
+
+	db, err = Open(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	db.View(func(tx *Tx) error {
+		values := map[string]string{}
+		tx.Scan("", func(key string, value []byte) bool {
+			values[key] = string(value)
+			return true
+		})
+		if fmt.Sprint(values) != "map[a:1 b:2]" {
+			t.Fatalf("expected the writes of the failed transaction to be rolled back, got %v", values)
+		}
+		return nil
+	})
+}
+
+func TestUpdate_2(t *testing.T) {
+	db, filePath := openTestDB(t)
+	db.Update(func(tx *Tx) error {
+		return tx.Put("a", []byte("1"))
+	})
+	db.Update(func(tx *Tx) error {
+		tx.Put("a", []byte("2"))
+		return tx.Put("b", []byte("2"))
+	})
+	db.file.Close()
+	db.file = nil
+
+	// A crash in the middle of a write leaves half a transaction at the end
+	info, _ := os.Stat(filePath)
+	os.Truncate(filePath, info.Size()-3)
+
+	db, err := Open(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db.View(func(tx *Tx) error {
+		if value := tx.Get("a"); string(value) != "1" || tx.Get("b") != nil {
+			t.Fatalf("expected only the first transaction, got a=%s b=%s", value, tx.Get("b"))
+		}
+		return nil
+	})
+
+	db.Update(func(tx *Tx) error {
+		return tx.Put("c", []byte("3"))
+	})
+	db.Close()
+
+	db, err = Open(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error reopening the store: %v", err)
+	}
+	defer db.Close()
+	if db.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %v", db.Len())
+	}
+}
+
+func TestView_1(t *testing.T) {
+	db, _ := openTestDB(t)
+	defer db.Close()
+
+	db.Update(func(tx *Tx) error {
+		for _, key := range []string{"city/1", "trip/1", "trip/2", "trip_origin/1/1"} {
+			tx.Put(key, []byte(key))
+		}
+		return nil
+	})
+
+	err := db.View(func(tx *Tx) error {
+		keys := []string{}
+		tx.Scan("trip/", func(key string, value []byte) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if fmt.Sprint(keys) != "[trip/1 trip/2]" {
+			t.Fatalf("expected the keys with prefix trip/, got %v", keys)
+		}
+
+		return tx.Put("trip/3", nil)
+	})
+	if err != ErrorReadOnly {
+		t.Fatalf("expected error: %v, got error: %v", ErrorReadOnly, err)
+	}
+}
+
+func TestCompact_1(t *testing.T) {
+	db, filePath := openTestDB(t)
+
+	for i := 0; i < 100; i++ {
+		db.Update(func(tx *Tx) error {
+			return tx.Put(fmt.Sprint(i%10), []byte(fmt.Sprint(i)))
+		})
+	}
+	before := db.size
+	db.Close()
+
+	info, _ := os.Stat(filePath)
+	if info.Size() >= before/5 {
+		t.Fatalf("expected the log of %v bytes to be compacted, got %v bytes", before, info.Size())
+	}
+
+	db, err := Open(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	db.View(func(tx *Tx) error {
+		if value := tx.Get("3"); string(value) != "93" || db.Len() != 10 {
+			t.Fatalf("expected the last values after compaction, got %s and %v keys", value, db.Len())
+		}
+		return nil
+	})
+
+	if err := db.Check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestOpen_1(t *testing.T) {
+	db, filePath := openTestDB(t)
+
+	if _, err := Open(filePath); !errors.Is(err, filelock.ErrorLocked) {
+		t.Fatalf("expected error: %v, got error: %v", filelock.ErrorLocked, err)
+	}
+
+	db.Close()
+	db, err := Open(filePath)
+	if err != nil {
+		t.Fatalf("expected the store to open once closed, got error: %v", err)
+	}
+	db.Close()
+}
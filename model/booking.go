@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Seats are not stored with trips yet, so every departure has the same capacity
+const DefaultSeats = 50
+
+const (
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+)
+
+// Booking is a seat for a passenger on a dated departure of a trip
+type Booking struct {
+	Id          int32     `json:"id"`
+	CustomerId  int32     `json:"customerId"`
+	TripId      int32     `json:"tripId"`
+	Departure   time.Time `json:"departure"`
+	PassengerId int32     `json:"passengerId"`
+	// Copied from the passenger, so the booking is not changed by later edits of the profile
+	PassengerName string     `json:"passengerName"`
+	Seat          int        `json:"seat"`
+	Price         float64    `json:"price"`
+	PromoCode     string     `json:"promoCode,omitempty"`
+	Status        string     `json:"status"`
+	Ticket        string     `json:"ticket,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// DepartureKey identifies a dated departure of a trip
+type DepartureKey struct {
+	TripId int32
+	// YYYY-MM-DD in the schedule time zone
+	Date string
+}
+
+// NewDepartureKey returns the key of the departure of a trip at the given time, in the time zone of departure
+func NewDepartureKey(tripId int32, departure time.Time) DepartureKey {
+	return DepartureKey{TripId: tripId, Date: departure.Format("2006-01-02")}
+}
+
+func (booking Booking) DepartureKey() DepartureKey {
+	return NewDepartureKey(booking.TripId, booking.Departure)
+}
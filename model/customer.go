@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+const (
+	DiscountNone        = ""
+	DiscountYouth       = "youth"
+	DiscountSenior      = "senior"
+	DiscountLargeFamily = "large_family"
+	DiscountDisability  = "disability"
+)
+
+type Customer struct {
+	Id    int32  `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	// Never serialized, see service.HashPassword
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Session authenticates the requests of a customer. Only a hash of the token
+// given to the customer is stored.
+type Session struct {
+	TokenHash  string
+	CustomerId int32
+	ExpiresAt  time.Time
+}
+
+// Passenger is a traveller profile saved by a customer to speed up bookings
+type Passenger struct {
+	Id             int32  `json:"id"`
+	CustomerId     int32  `json:"customerId"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	DocumentType   string `json:"documentType,omitempty"`
+	DocumentNumber string `json:"documentNumber,omitempty"`
+	// YYYY-MM-DD, used for age based fares
+	BirthDate        string `json:"birthDate,omitempty"`
+	DiscountCategory string `json:"discountCategory,omitempty"`
+}
+
+// AgeOn returns the age of the passenger on a date, 0 if the birth date is unknown
+func (passenger Passenger) AgeOn(date time.Time) int {
+	birthDate, err := time.Parse("2006-01-02", passenger.BirthDate)
+	if err != nil {
+		return 0
+	}
+
+	age := date.Year() - birthDate.Year()
+	if date.Month() < birthDate.Month() || (date.Month() == birthDate.Month() && date.Day() < birthDate.Day()) {
+		age--
+	}
+	if age < 0 {
+		return 0
+	}
+
+	return age
+}
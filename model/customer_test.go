@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgeOn_1(t *testing.T) {
+	passenger := Passenger{BirthDate: "1990-05-01"}
+
+	tests := []struct {
+		date     time.Time
+		expected int
+	}{
+		{date: time.Date(2026, 4, 30, 0, 0, 0, 0, time.UTC), expected: 35},
+		{date: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), expected: 36},
+		{date: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), expected: 0},
+	}
+
+	for _, test := range tests {
+		result := passenger.AgeOn(test.date)
+		if result != test.expected {
+			t.Fatalf("expected %v on %v, got %v", test.expected, test.date, result)
+		}
+	}
+
+	if age := (Passenger{}).AgeOn(time.Now()); age != 0 {
+		t.Fatalf("expected unknown age to be 0, got %v", age)
+	}
+}
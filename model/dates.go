@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Departure and travel times are not stored with trips yet, so every trip is
+// assumed to leave at the same time and take the same time to arrive
+const DefaultDepartureTime = 8 * time.Hour
+const DefaultTravelTime = time.Hour
+
+var weekdayNames = map[string]time.Weekday{
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+	"Sun": time.Sunday,
+}
+
+// ParseDates returns the weekdays in a trip dates string such as "Mon Tue Fri"
+func ParseDates(dates string) ([]time.Weekday, error) {
+	result := []time.Weekday{}
+
+	for _, name := range strings.Fields(dates) {
+		weekday, ok := weekdayNames[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday: %v", name)
+		}
+
+		result = append(result, weekday)
+	}
+
+	return result, nil
+}
+
+// RunsOn reports whether the trip departs on the given weekday
+func (trip Trip) RunsOn(weekday time.Weekday) bool {
+	weekdays, err := ParseDates(trip.Dates)
+	if err != nil {
+		return false
+	}
+
+	for _, tripWeekday := range weekdays {
+		if tripWeekday == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatDates returns a trip dates string for a set of weekdays, starting on monday
+func FormatDates(weekdays []time.Weekday) string {
+	names := []string{}
+
+	for _, name := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		for _, weekday := range weekdays {
+			if weekdayNames[name] == weekday {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDates_1(t *testing.T) {
+	weekdays, err := ParseDates("Mon Wed Sun")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []time.Weekday{time.Monday, time.Wednesday, time.Sunday}
+	if !reflect.DeepEqual(weekdays, expected) {
+		t.Fatalf("expected %v, got %v", expected, weekdays)
+	}
+}
+
+func TestParseDates_2(t *testing.T) {
+	_, err := ParseDates("Mon Xyz")
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestRunsOn_1(t *testing.T) {
+	trip := Trip{Dates: "Sat Sun"}
+
+	if !trip.RunsOn(time.Sunday) {
+		t.Fatalf("expected trip to run on %v", time.Sunday)
+	}
+	if trip.RunsOn(time.Monday) {
+		t.Fatalf("expected trip not to run on %v", time.Monday)
+	}
+}
+
+func TestFormatDates_1(t *testing.T) {
+	dates := FormatDates([]time.Weekday{time.Sunday, time.Monday, time.Friday, time.Monday})
+	if dates != "Mon Fri Sun" {
+		t.Fatalf("expected %v, got %v", "Mon Fri Sun", dates)
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+const (
+	DepartureScheduled  = "scheduled"
+	DisruptionCancelled = "cancelled"
+	DisruptionDelayed   = "delayed"
+)
+
+// Disruption marks a dated departure of a trip as cancelled or delayed
+type Disruption struct {
+	TripId int32 `json:"tripId"`
+	// YYYY-MM-DD in the schedule time zone
+	Date   string `json:"date"`
+	Status string `json:"status"`
+	// Only set for delays
+	DelayMinutes int       `json:"delayMinutes,omitempty"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// Start of the day after the departure, when the disruption is no longer shown
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (disruption Disruption) DepartureKey() DepartureKey {
+	return DepartureKey{TripId: disruption.TripId, Date: disruption.Date}
+}
+
+// Departure is a dated departure of a trip, scheduled or disrupted
+type Departure struct {
+	TripId     int32       `json:"tripId"`
+	Date       string      `json:"date"`
+	Departure  time.Time   `json:"departure"`
+	Status     string      `json:"status"`
+	Disruption *Disruption `json:"disruption,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// HasCoordinates reports whether the location of the city is known. No city
+// lies at 0,0, so it is used as the zero value.
+func (city City) HasCoordinates() bool {
+	return city.Latitude != 0 || city.Longitude != 0
+}
+
+// DistanceKm returns the great-circle distance between two points, using the haversine formula
+func DistanceKm(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	deltaLatitude := toRadians(latitude2 - latitude1)
+	deltaLongitude := toRadians(longitude2 - longitude1)
+
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(toRadians(latitude1))*math.Cos(toRadians(latitude2))*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// DistanceTo returns the great-circle distance between two cities
+func (city City) DistanceTo(other City) float64 {
+	return DistanceKm(city.Latitude, city.Longitude, other.Latitude, other.Longitude)
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm_1(t *testing.T) {
+	madrid := City{Latitude: 40.4168, Longitude: -3.7038}
+	barcelona := City{Latitude: 41.3874, Longitude: 2.1686}
+
+	distance := madrid.DistanceTo(barcelona)
+	if math.Abs(distance-505) > 5 {
+		t.Fatalf("expected distance to be about %v km, got %v", 505, distance)
+	}
+
+	if math.Abs(distance-barcelona.DistanceTo(madrid)) > 1e-9 {
+		t.Fatalf("expected distance to be symmetric, got %v and %v", distance, barcelona.DistanceTo(madrid))
+	}
+}
+
+func TestDistanceKm_2(t *testing.T) {
+	distance := DistanceKm(37.3891, -5.9845, 37.3891, -5.9845)
+	if distance != 0 {
+		t.Fatalf("expected distance to be %v, got %v", 0, distance)
+	}
+}
+
+func TestHasCoordinates_1(t *testing.T) {
+	if (City{Name: "Madrid"}).HasCoordinates() {
+		t.Fatalf("expected city without coordinates")
+	}
+	if !(City{Name: "Madrid", Latitude: 40.4168, Longitude: -3.7038}).HasCoordinates() {
+		t.Fatalf("expected city with coordinates")
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+const LedgerRefund = "refund"
+
+// LedgerEntry records money owed to or by a customer for a booking
+type LedgerEntry struct {
+	Id         int32   `json:"id"`
+	BookingId  int32   `json:"bookingId"`
+	CustomerId int32   `json:"customerId"`
+	Type       string  `json:"type"`
+	Amount     float64 `json:"amount"`
+	// Why this amount, such as the refund policy that applied
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -1,11 +1,52 @@
 package model
 
+import (
+	"sort"
+	"time"
+)
+
 type Trip struct {
-	Id            int32   `json:"id"`
-	OriginId      int32   `json:"originId"`
-	DestinationId int32   `json:"destinationId"`
-	Dates         string  `json:"dates"`
-	Price         float64 `json:"price"`
+	Id            int32     `json:"id"`
+	OriginId      int32     `json:"originId"`
+	DestinationId int32     `json:"destinationId"`
+	Dates         string    `json:"dates"`
+	Price         float64   `json:"price"`
+	Version       int32     `json:"version"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TripQuery selects trips by city, a page at a time. Zero fields select
+// everything: any origin, any destination, from the first trip, with no limit.
+type TripQuery struct {
+	OriginId      int32
+	DestinationId int32
+	// Only trips with a greater id are returned, so the next page starts after the last trip of the previous one
+	AfterId int32
+	Limit   int
+}
+
+// Matches tells whether the trip is selected by the query, ignoring its limit
+func (query TripQuery) Matches(trip Trip) bool {
+	return (query.OriginId == 0 || trip.OriginId == query.OriginId) &&
+		(query.DestinationId == 0 || trip.DestinationId == query.DestinationId) &&
+		trip.Id > query.AfterId
+}
+
+// Apply returns the trips selected by the query, sorted by id
+func (query TripQuery) Apply(trips []Trip) []Trip {
+	result := []Trip{}
+	for _, trip := range trips {
+		if query.Matches(trip) {
+			result = append(result, trip)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+
+	return result
 }
 
 type TripPretty struct {
@@ -14,9 +55,25 @@ type TripPretty struct {
 	Destination string  `json:"destination"`
 	Dates       string  `json:"dates"`
 	Price       float64 `json:"price"`
+	// Great-circle distance between origin and destination, if both have coordinates
+	DistanceKm *float64 `json:"distanceKm,omitempty"`
+	// Upcoming departures that have been cancelled or delayed
+	Disruptions []Disruption `json:"disruptions,omitempty"`
 }
 
 type City struct {
-	Id   int32
-	Name string
-}
\ No newline at end of file
+	Id        int32   `json:"id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	// ISO 3166-1 alpha-2 country code
+	Country string `json:"country,omitempty"`
+	// IANA time zone name, such as Europe/Madrid
+	Timezone string `json:"timezone,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+type CityNearby struct {
+	City
+	DistanceKm float64 `json:"distanceKm"`
+}
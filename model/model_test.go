@@ -0,0 +1,37 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTripQueryApply_1(t *testing.T) {
+	trips := []Trip{
+		{Id: 3, OriginId: 1, DestinationId: 2},
+		{Id: 1, OriginId: 1, DestinationId: 3},
+		{Id: 2, OriginId: 2, DestinationId: 3},
+		{Id: 4, OriginId: 1, DestinationId: 3},
+	}
+
+	tests := []struct {
+		query    TripQuery
+		expected []int32
+	}{
+		{query: TripQuery{}, expected: []int32{1, 2, 3, 4}},
+		{query: TripQuery{OriginId: 1}, expected: []int32{1, 3, 4}},
+		{query: TripQuery{DestinationId: 3}, expected: []int32{1, 2, 4}},
+		{query: TripQuery{OriginId: 1, DestinationId: 3, Limit: 1}, expected: []int32{1}},
+		{query: TripQuery{OriginId: 1, AfterId: 1, Limit: 1}, expected: []int32{3}},
+		{query: TripQuery{AfterId: 4}, expected: []int32{}},
+	}
+
+	for _, test := range tests {
+		ids := []int32{}
+		for _, trip := range test.query.Apply(trips) {
+			ids = append(ids, trip.Id)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Fatalf("expected %v for %+v, got %v", test.expected, test.query, ids)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// PromoCode takes a percentage or a fixed amount off the fare of the trips it applies to
+type PromoCode struct {
+	Code        string  `json:"code"`
+	Description string  `json:"description,omitempty"`
+	Percent     float64 `json:"percent,omitempty"`
+	Amount      float64 `json:"amount,omitempty"`
+	// Validity window, either end can be left open
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	// Usage caps, 0 means unlimited
+	MaxUses            int `json:"maxUses,omitempty"`
+	MaxUsesPerCustomer int `json:"maxUsesPerCustomer,omitempty"`
+	// Restrictions, the code applies to every trip if empty
+	Routes   []PromoRoute `json:"routes,omitempty"`
+	Weekdays string       `json:"weekdays,omitempty"`
+	// Times the code has been redeemed
+	Uses int `json:"uses"`
+}
+
+// PromoRedemption is how many times a customer, by email, has redeemed a code
+type PromoRedemption struct {
+	Code     string `json:"code"`
+	Customer string `json:"customer"`
+	Uses     int    `json:"uses"`
+}
+
+type PromoRoute struct {
+	OriginId      int32 `json:"originId"`
+	DestinationId int32 `json:"destinationId"`
+}
+
+// Discount returns how much is taken off a fare, never more than the fare itself
+func (promoCode PromoCode) Discount(price float64) float64 {
+	discount := price*promoCode.Percent/100 + promoCode.Amount
+	if discount > price {
+		discount = price
+	}
+
+	return math.Round(discount*100) / 100
+}
+
+// IsValidAt reports whether t is within the validity window of the code
+func (promoCode PromoCode) IsValidAt(t time.Time) bool {
+	if promoCode.ValidFrom != nil && t.Before(*promoCode.ValidFrom) {
+		return false
+	}
+	if promoCode.ValidUntil != nil && t.After(*promoCode.ValidUntil) {
+		return false
+	}
+
+	return true
+}
+
+// AppliesTo reports whether the route and weekday restrictions of the code allow a departure of trip
+func (promoCode PromoCode) AppliesTo(trip Trip, departure time.Time) bool {
+	if len(promoCode.Routes) > 0 {
+		found := false
+		for _, route := range promoCode.Routes {
+			if route.OriginId == trip.OriginId && route.DestinationId == trip.DestinationId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if promoCode.Weekdays != "" {
+		return Trip{Dates: promoCode.Weekdays}.RunsOn(departure.Weekday())
+	}
+
+	return true
+}
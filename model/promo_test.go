@@ -0,0 +1,65 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiscount_1(t *testing.T) {
+	tests := []struct {
+		promoCode PromoCode
+		price     float64
+		expected  float64
+	}{
+		{promoCode: PromoCode{Percent: 10}, price: 40.55, expected: 4.06},
+		{promoCode: PromoCode{Amount: 5}, price: 40.55, expected: 5},
+		{promoCode: PromoCode{Amount: 50}, price: 40.55, expected: 40.55},
+	}
+
+	for _, test := range tests {
+		result := test.promoCode.Discount(test.price)
+		if result != test.expected {
+			t.Fatalf("expected %v, got %v", test.expected, result)
+		}
+	}
+}
+
+func TestIsValidAt_1(t *testing.T) {
+	from := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 8, 31, 23, 59, 59, 0, time.UTC)
+	promoCode := PromoCode{ValidFrom: &from, ValidUntil: &until}
+
+	if promoCode.IsValidAt(from.Add(-time.Second)) {
+		t.Fatalf("expected code not to be valid before validFrom")
+	}
+	if !promoCode.IsValidAt(from) || !promoCode.IsValidAt(until) {
+		t.Fatalf("expected code to be valid within its window")
+	}
+	if promoCode.IsValidAt(until.Add(time.Second)) {
+		t.Fatalf("expected code not to be valid after validUntil")
+	}
+}
+
+func TestAppliesTo_1(t *testing.T) {
+	trip := Trip{OriginId: 1, DestinationId: 2}
+	// Saturday
+	departure := time.Date(2026, 3, 7, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		promoCode PromoCode
+		expected  bool
+	}{
+		{promoCode: PromoCode{}, expected: true},
+		{promoCode: PromoCode{Routes: []PromoRoute{{OriginId: 1, DestinationId: 2}}}, expected: true},
+		{promoCode: PromoCode{Routes: []PromoRoute{{OriginId: 2, DestinationId: 1}}}, expected: false},
+		{promoCode: PromoCode{Weekdays: "Sat Sun"}, expected: true},
+		{promoCode: PromoCode{Weekdays: "Mon"}, expected: false},
+	}
+
+	for _, test := range tests {
+		result := test.promoCode.AppliesTo(trip, departure)
+		if result != test.expected {
+			t.Fatalf("expected %v for %v, got %v", test.expected, test.promoCode, result)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	// Failed deliveries are retried until they run out of attempts
+	DeliveryFailed = "failed"
+	// Dead deliveries ran out of attempts and are only retried on request
+	DeliveryDead = "dead"
+)
+
+// Webhook subscribes a partner URL to domain events
+type Webhook struct {
+	Id  int32  `json:"id"`
+	Url string `json:"url"`
+	// Event types delivered, every type if empty
+	EventTypes []string `json:"eventTypes,omitempty"`
+	// Key of the HMAC-SHA256 signature of deliveries, only shown when the
+	// webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Wants reports whether events of eventType are delivered to the webhook
+func (webhook Webhook) Wants(eventType string) bool {
+	if len(webhook.EventTypes) == 0 {
+		return true
+	}
+
+	for _, wanted := range webhook.EventTypes {
+		if wanted == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookDelivery is the delivery of an event to a webhook, with the outcome
+// of its last attempt
+type WebhookDelivery struct {
+	Id        int64           `json:"id"`
+	WebhookId int32           `json:"webhookId"`
+	EventId   int64           `json:"eventId"`
+	EventType string          `json:"eventType"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// Response code and error of the last attempt
+	StatusCode    int        `json:"statusCode,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+}
@@ -0,0 +1,194 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type engine struct {
+	filePath string
+	config   Config
+	modTime  time.Time
+	lock     sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewEngine returns a pricing engine with the rules in the config file at
+// filePath. An empty filePath, or a file that does not exist, means no rules,
+// so every quote is the base price of the trip.
+func NewEngine(filePath string) (*engine, error) {
+	engine := &engine{filePath: filePath, stop: make(chan struct{})}
+
+	_, err := engine.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+func NewEngineWithConfig(config Config) (*engine, error) {
+	err := ValidateConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &engine{config: config, stop: make(chan struct{})}, nil
+}
+
+// Quote computes the fare of a request with the current rules
+func (engine *engine) Quote(request Request) Quote {
+	engine.lock.RLock()
+	config := engine.config
+	engine.lock.RUnlock()
+
+	return Evaluate(config, request)
+}
+
+// Refund computes the refund of a booking of trip cancelled at cancelTime with the current policies
+func (engine *engine) Refund(trip model.Trip, price float64, departure time.Time, cancelTime time.Time) Refund {
+	config := engine.Config()
+
+	return EvaluateRefund(RefundPolicyFor(config, trip), price, departure, cancelTime)
+}
+
+func (engine *engine) Config() Config {
+	engine.lock.RLock()
+	defer engine.lock.RUnlock()
+
+	return engine.config
+}
+
+// Reload reads the config file again if it changed since it was last loaded.
+// An invalid file is rejected and the previous rules are kept.
+func (engine *engine) Reload() (bool, error) {
+	if engine.filePath == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(engine.filePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not read pricing config file: %w", err)
+	}
+
+	engine.lock.RLock()
+	unchanged := info.ModTime().Equal(engine.modTime)
+	engine.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := ioutil.ReadFile(engine.filePath)
+	if err != nil {
+		return false, fmt.Errorf("could not read pricing config file: %w", err)
+	}
+
+	var config Config
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return false, fmt.Errorf("invalid pricing config file: %w", err)
+	}
+
+	err = ValidateConfig(config)
+	if err != nil {
+		return false, fmt.Errorf("invalid pricing config file: %w", err)
+	}
+
+	engine.lock.Lock()
+	engine.config = config
+	engine.modTime = info.ModTime()
+	engine.lock.Unlock()
+
+	return true, nil
+}
+
+// Watch reloads the config file every interval until the engine is closed
+func (engine *engine) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-engine.stop:
+				return
+			case <-ticker.C:
+				reloaded, err := engine.Reload()
+				if err != nil {
+					log.Printf("could not reload pricing rules, keeping the previous ones: %v", err)
+				} else if reloaded {
+					log.Printf("Reloaded pricing rules from %v", engine.filePath)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops watching the config file
+func (engine *engine) Close() error {
+	engine.stopOnce.Do(func() { close(engine.stop) })
+	return nil
+}
+
+func ValidateConfig(config Config) error {
+	if config.MinimumPrice < 0 {
+		return fmt.Errorf("minimumPrice can not be negative")
+	}
+
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %v: missing name", i+1)
+		}
+
+		switch rule.Type {
+		case RuleWeekday:
+			weekdays, err := model.ParseDates(rule.Weekdays)
+			if err != nil || len(weekdays) == 0 {
+				return fmt.Errorf("rule %v: invalid weekdays: %v", rule.Name, rule.Weekdays)
+			}
+		case RuleAdvancePurchase:
+			if rule.MinDays == nil && rule.MaxDays == nil {
+				return fmt.Errorf("rule %v: minDays or maxDays required", rule.Name)
+			}
+		case RuleLoadFactor:
+			if rule.MinLoad == nil && rule.MaxLoad == nil {
+				return fmt.Errorf("rule %v: minLoad or maxLoad required", rule.Name)
+			}
+		case RulePassengerAge:
+			if rule.MinAge == nil && rule.MaxAge == nil {
+				return fmt.Errorf("rule %v: minAge or maxAge required", rule.Name)
+			}
+		default:
+			return fmt.Errorf("rule %v: unknown type: %v", rule.Name, rule.Type)
+		}
+
+		if rule.Adjustment.Percent <= -100 {
+			return fmt.Errorf("rule %v: percent must be greater than -100", rule.Name)
+		}
+	}
+
+	for i, policy := range config.RefundPolicies {
+		if policy.Name == "" {
+			return fmt.Errorf("refund policy %v: missing name", i+1)
+		}
+
+		err := validateRefundPolicy(policy)
+		if err != nil {
+			return fmt.Errorf("refund policy %v: %w", policy.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package pricing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const testConfig = `{
+	"minimumPrice": 5,
+	"rules": [
+		{"name": "senior", "type": "passenger_age", "minAge": 65, "adjustment": {"percent": -50}}
+	]
+}`
+
+func writeConfig(t *testing.T, filePath string, content string, modTime time.Time) {
+	err := ioutil.WriteFile(filePath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = os.Chtimes(filePath, modTime, modTime)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewEngine_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pricing.json")
+	writeConfig(t, filePath, testConfig, time.Now())
+
+	engine, err := NewEngine(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	quote := engine.Quote(Request{Trip: model.Trip{Price: 40}, PassengerAge: 70})
+	if quote.Price != 20 {
+		t.Fatalf("expected price %v, got %v", 20, quote.Price)
+	}
+}
+
+func TestNewEngine_2(t *testing.T) {
+	engine, err := NewEngine(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	quote := engine.Quote(Request{Trip: model.Trip{Price: 40}, PassengerAge: 70})
+	if quote.Price != 40 {
+		t.Fatalf("expected price %v, got %v", 40, quote.Price)
+	}
+}
+
+func TestNewEngine_3(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pricing.json")
+	writeConfig(t, filePath, `{"rules": [{"name": "x", "type": "moon_phase"}]}`, time.Now())
+
+	_, err := NewEngine(filePath)
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestReload_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pricing.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, filePath, testConfig, start)
+
+	engine, err := NewEngine(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded, err := engine.Reload()
+	if err != nil || reloaded {
+		t.Fatalf("expected unchanged file not to be reloaded, got %v %v", reloaded, err)
+	}
+
+	writeConfig(t, filePath, `{"rules": []}`, start.Add(time.Minute))
+
+	reloaded, err = engine.Reload()
+	if err != nil || !reloaded {
+		t.Fatalf("expected changed file to be reloaded, got %v %v", reloaded, err)
+	}
+
+	quote := engine.Quote(Request{Trip: model.Trip{Price: 40}, PassengerAge: 70})
+	if quote.Price != 40 {
+		t.Fatalf("expected price %v, got %v", 40, quote.Price)
+	}
+}
+
+func TestReload_2(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pricing.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, filePath, testConfig, start)
+
+	engine, err := NewEngine(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writeConfig(t, filePath, `{"rules": [`, start.Add(time.Minute))
+
+	_, err = engine.Reload()
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	quote := engine.Quote(Request{Trip: model.Trip{Price: 40}, PassengerAge: 70})
+	if quote.Price != 20 {
+		t.Fatalf("expected previous rules to be kept, got price %v", quote.Price)
+	}
+}
+
+func TestWatch_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pricing.json")
+	start := time.Now().Add(-time.Hour)
+	writeConfig(t, filePath, testConfig, start)
+
+	engine, err := NewEngine(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	engine.Watch(10 * time.Millisecond)
+	defer engine.Close()
+
+	writeConfig(t, filePath, `{"rules": []}`, start.Add(time.Minute))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(engine.Config().Rules) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected rules to be reloaded, got %v", engine.Config())
+}
@@ -0,0 +1,177 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const (
+	RuleWeekday         = "weekday"
+	RuleAdvancePurchase = "advance_purchase"
+	RuleLoadFactor      = "load_factor"
+	RulePassengerAge    = "passenger_age"
+)
+
+// Adjustment changes the running price of a quote, by a percentage of it or by a fixed amount
+type Adjustment struct {
+	Percent float64 `json:"percent,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+// Rule adjusts the price when its conditions are met. Only the conditions of its type are used.
+type Rule struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Adjustment Adjustment `json:"adjustment"`
+	// weekday
+	Weekdays string `json:"weekdays,omitempty"`
+	// advance_purchase, in days before departure
+	MinDays *int `json:"minDays,omitempty"`
+	MaxDays *int `json:"maxDays,omitempty"`
+	// load_factor, as the share of seats already sold, from 0 to 1. The upper bound is exclusive.
+	MinLoad *float64 `json:"minLoad,omitempty"`
+	MaxLoad *float64 `json:"maxLoad,omitempty"`
+	// passenger_age, both bounds inclusive
+	MinAge *int `json:"minAge,omitempty"`
+	MaxAge *int `json:"maxAge,omitempty"`
+	// Stop evaluating the following rules when this one fires
+	Final bool `json:"final,omitempty"`
+}
+
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// Quotes are never below this price
+	MinimumPrice float64 `json:"minimumPrice"`
+	// Cancellation refunds, DefaultRefundPolicy if none applies
+	RefundPolicies []RefundPolicy `json:"refundPolicies,omitempty"`
+}
+
+// Request is everything a fare depends on for a concrete departure of a trip
+type Request struct {
+	Trip         model.Trip
+	Departure    time.Time
+	PurchaseTime time.Time
+	LoadFactor   float64
+	// Age of the passenger, 0 if unknown
+	PassengerAge int
+}
+
+type Step struct {
+	Rule        string  `json:"rule"`
+	Fired       bool    `json:"fired"`
+	Reason      string  `json:"reason"`
+	PriceBefore float64 `json:"priceBefore"`
+	PriceAfter  float64 `json:"priceAfter"`
+}
+
+type Quote struct {
+	BasePrice float64 `json:"basePrice"`
+	Price     float64 `json:"price"`
+	Steps     []Step  `json:"steps"`
+}
+
+// Evaluate computes the fare of a request, applying every rule in order to the running price
+func Evaluate(config Config, request Request) Quote {
+	quote := Quote{BasePrice: request.Trip.Price, Steps: []Step{}}
+	price := request.Trip.Price
+
+	for _, rule := range config.Rules {
+		fired, reason := rule.matches(request)
+		step := Step{Rule: rule.Name, Fired: fired, Reason: reason, PriceBefore: round(price)}
+
+		if fired {
+			price = price*(1+rule.Adjustment.Percent/100) + rule.Adjustment.Amount
+		}
+
+		step.PriceAfter = round(price)
+		quote.Steps = append(quote.Steps, step)
+
+		if fired && rule.Final {
+			break
+		}
+	}
+
+	if price < config.MinimumPrice {
+		price = config.MinimumPrice
+	}
+	if price < 0 {
+		price = 0
+	}
+
+	quote.Price = round(price)
+	return quote
+}
+
+func (rule Rule) matches(request Request) (bool, string) {
+	switch rule.Type {
+	case RuleWeekday:
+		weekday := request.Departure.Weekday()
+		trip := model.Trip{Dates: rule.Weekdays}
+		if trip.RunsOn(weekday) {
+			return true, fmt.Sprintf("departure on %v", weekday)
+		}
+		return false, fmt.Sprintf("departure on %v, not in %v", weekday, rule.Weekdays)
+
+	case RuleAdvancePurchase:
+		days := int(request.Departure.Sub(request.PurchaseTime).Hours() / 24)
+		if inRange(days, rule.MinDays, rule.MaxDays) {
+			return true, fmt.Sprintf("purchased %v days before departure", days)
+		}
+		return false, fmt.Sprintf("purchased %v days before departure, outside %v", days, intRange(rule.MinDays, rule.MaxDays))
+
+	case RuleLoadFactor:
+		load := request.LoadFactor
+		matches := (rule.MinLoad == nil || load >= *rule.MinLoad) && (rule.MaxLoad == nil || load < *rule.MaxLoad)
+		if matches {
+			return true, fmt.Sprintf("load factor %.2f", load)
+		}
+		return false, fmt.Sprintf("load factor %.2f, outside %v", load, floatRange(rule.MinLoad, rule.MaxLoad))
+
+	case RulePassengerAge:
+		if request.PassengerAge == 0 {
+			return false, "passenger age unknown"
+		}
+		if inRange(request.PassengerAge, rule.MinAge, rule.MaxAge) {
+			return true, fmt.Sprintf("passenger aged %v", request.PassengerAge)
+		}
+		return false, fmt.Sprintf("passenger aged %v, outside %v", request.PassengerAge, intRange(rule.MinAge, rule.MaxAge))
+	}
+
+	return false, fmt.Sprintf("unknown rule type: %v", rule.Type)
+}
+
+func inRange(value int, min *int, max *int) bool {
+	return (min == nil || value >= *min) && (max == nil || value <= *max)
+}
+
+func intRange(min *int, max *int) string {
+	lower, upper := "any", "any"
+	if min != nil {
+		lower = strconv.Itoa(*min)
+	}
+	if max != nil {
+		upper = strconv.Itoa(*max)
+	}
+
+	return fmt.Sprintf("[%v, %v]", lower, upper)
+}
+
+func floatRange(min *float64, max *float64) string {
+	lower, upper := "any", "any"
+	if min != nil {
+		lower = strconv.FormatFloat(*min, 'f', -1, 64)
+	}
+	if max != nil {
+		upper = strconv.FormatFloat(*max, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("[%v, %v)", lower, upper)
+}
+
+func round(price float64) float64 {
+	return math.Round(price*100) / 100
+}
@@ -0,0 +1,196 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+var weekendSurcharge = Rule{Name: "weekend", Type: RuleWeekday, Weekdays: "Sat Sun", Adjustment: Adjustment{Percent: 20}}
+var earlyBird = Rule{Name: "early bird", Type: RuleAdvancePurchase, MinDays: intPointer(30), Adjustment: Adjustment{Percent: -25}}
+var lastMinute = Rule{Name: "last minute", Type: RuleAdvancePurchase, MaxDays: intPointer(1), Adjustment: Adjustment{Amount: 5}}
+var highLoad = Rule{Name: "high load", Type: RuleLoadFactor, MinLoad: floatPointer(0.8), Adjustment: Adjustment{Percent: 50}}
+var lowLoad = Rule{Name: "low load", Type: RuleLoadFactor, MaxLoad: floatPointer(0.2), Adjustment: Adjustment{Percent: -10}}
+var youth = Rule{Name: "youth", Type: RulePassengerAge, MaxAge: intPointer(25), Adjustment: Adjustment{Percent: -10}, Final: true}
+var senior = Rule{Name: "senior", Type: RulePassengerAge, MinAge: intPointer(65), Adjustment: Adjustment{Percent: -30}}
+
+// Monday
+var testPurchaseTime = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		request  Request
+		expected float64
+		fired    []string
+	}{
+		{
+			name:     "no rules",
+			config:   Config{},
+			request:  Request{Trip: model.Trip{Price: 40}, Departure: testPurchaseTime.AddDate(0, 0, 5)},
+			expected: 40,
+			fired:    []string{},
+		},
+		{
+			name:     "weekend surcharge on saturday",
+			config:   Config{Rules: []Rule{weekendSurcharge}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 5)},
+			expected: 48,
+			fired:    []string{"weekend"},
+		},
+		{
+			name:     "no weekend surcharge on friday",
+			config:   Config{Rules: []Rule{weekendSurcharge}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 4)},
+			expected: 40,
+			fired:    []string{},
+		},
+		{
+			name:     "early bird discount",
+			config:   Config{Rules: []Rule{earlyBird, lastMinute}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 45)},
+			expected: 30,
+			fired:    []string{"early bird"},
+		},
+		{
+			name:     "early bird boundary",
+			config:   Config{Rules: []Rule{earlyBird}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 30)},
+			expected: 30,
+			fired:    []string{"early bird"},
+		},
+		{
+			name:     "last minute fixed surcharge",
+			config:   Config{Rules: []Rule{earlyBird, lastMinute}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.Add(20 * time.Hour)},
+			expected: 45,
+			fired:    []string{"last minute"},
+		},
+		{
+			name:     "high load band",
+			config:   Config{Rules: []Rule{lowLoad, highLoad}},
+			request:  Request{Trip: model.Trip{Price: 40}, LoadFactor: 0.9},
+			expected: 60,
+			fired:    []string{"high load"},
+		},
+		{
+			name:     "load band upper bound is exclusive",
+			config:   Config{Rules: []Rule{lowLoad, highLoad}},
+			request:  Request{Trip: model.Trip{Price: 40}, LoadFactor: 0.2},
+			expected: 40,
+			fired:    []string{},
+		},
+		{
+			name:     "unknown age gets no fare",
+			config:   Config{Rules: []Rule{youth, senior}},
+			request:  Request{Trip: model.Trip{Price: 40}},
+			expected: 40,
+			fired:    []string{},
+		},
+		{
+			name:     "senior fare",
+			config:   Config{Rules: []Rule{youth, senior}},
+			request:  Request{Trip: model.Trip{Price: 40}, PassengerAge: 70},
+			expected: 28,
+			fired:    []string{"senior"},
+		},
+		{
+			name:     "rules apply in order to the running price",
+			config:   Config{Rules: []Rule{weekendSurcharge, highLoad, senior}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 6), LoadFactor: 0.85, PassengerAge: 80},
+			expected: 50.4,
+			fired:    []string{"weekend", "high load", "senior"},
+		},
+		{
+			name:     "final rule stops evaluation",
+			config:   Config{Rules: []Rule{youth, weekendSurcharge}},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 6), PassengerAge: 20},
+			expected: 36,
+			fired:    []string{"youth"},
+		},
+		{
+			name:     "minimum price",
+			config:   Config{Rules: []Rule{earlyBird, senior}, MinimumPrice: 25},
+			request:  Request{Trip: model.Trip{Price: 40}, PurchaseTime: testPurchaseTime, Departure: testPurchaseTime.AddDate(0, 0, 60), PassengerAge: 70},
+			expected: 25,
+			fired:    []string{"early bird", "senior"},
+		},
+		{
+			name:     "rounded to cents",
+			config:   Config{Rules: []Rule{senior}},
+			request:  Request{Trip: model.Trip{Price: 33.33}, PassengerAge: 70},
+			expected: 23.33,
+			fired:    []string{"senior"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quote := Evaluate(test.config, test.request)
+
+			if quote.Price != test.expected {
+				t.Fatalf("expected price %v, got %v", test.expected, quote.Price)
+			}
+			if quote.BasePrice != test.request.Trip.Price {
+				t.Fatalf("expected base price %v, got %v", test.request.Trip.Price, quote.BasePrice)
+			}
+
+			fired := []string{}
+			for _, step := range quote.Steps {
+				if step.Fired {
+					fired = append(fired, step.Rule)
+				}
+			}
+			if len(fired) != len(test.fired) {
+				t.Fatalf("expected fired rules %v, got %v", test.fired, fired)
+			}
+			for i := range fired {
+				if fired[i] != test.fired[i] {
+					t.Fatalf("expected fired rules %v, got %v", test.fired, fired)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{name: "weekday", rule: weekendSurcharge, valid: true},
+		{name: "advance purchase", rule: earlyBird, valid: true},
+		{name: "load factor", rule: highLoad, valid: true},
+		{name: "passenger age", rule: senior, valid: true},
+		{name: "missing name", rule: Rule{Type: RuleWeekday, Weekdays: "Sat"}, valid: false},
+		{name: "unknown type", rule: Rule{Name: "x", Type: "moon_phase"}, valid: false},
+		{name: "invalid weekdays", rule: Rule{Name: "x", Type: RuleWeekday, Weekdays: "Caturday"}, valid: false},
+		{name: "unbounded advance purchase", rule: Rule{Name: "x", Type: RuleAdvancePurchase}, valid: false},
+		{name: "unbounded load factor", rule: Rule{Name: "x", Type: RuleLoadFactor}, valid: false},
+		{name: "unbounded age", rule: Rule{Name: "x", Type: RulePassengerAge}, valid: false},
+		{name: "free and beyond", rule: Rule{Name: "x", Type: RulePassengerAge, MaxAge: intPointer(3), Adjustment: Adjustment{Percent: -100}}, valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateConfig(Config{Rules: []Rule{test.rule}})
+			if test.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("expected error, got %v", err)
+			}
+		})
+	}
+}
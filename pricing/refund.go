@@ -0,0 +1,122 @@
+package pricing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// RefundTier refunds a percentage of the price when cancelling at least MinHoursBefore hours before departure
+type RefundTier struct {
+	MinHoursBefore float64 `json:"minHoursBefore"`
+	Percent        float64 `json:"percent"`
+}
+
+// RefundPolicy sets how much of the price is refunded depending on how long
+// before departure a booking is cancelled. A policy with trip ids applies to
+// those trips, one with an origin and destination to the trips of that route,
+// and one with neither to every other trip.
+type RefundPolicy struct {
+	Name          string       `json:"name"`
+	TripIds       []int32      `json:"tripIds,omitempty"`
+	OriginId      int32        `json:"originId,omitempty"`
+	DestinationId int32        `json:"destinationId,omitempty"`
+	Tiers         []RefundTier `json:"tiers"`
+}
+
+// DefaultRefundPolicy is used for trips that no configured policy applies to
+var DefaultRefundPolicy = RefundPolicy{
+	Name: "default",
+	Tiers: []RefundTier{
+		{MinHoursBefore: 48, Percent: 100},
+		{MinHoursBefore: 0, Percent: 50},
+	},
+}
+
+type Refund struct {
+	Policy      string  `json:"policy"`
+	HoursBefore float64 `json:"hoursBefore"`
+	Percent     float64 `json:"percent"`
+	Amount      float64 `json:"amount"`
+}
+
+// RefundPolicyFor returns the policy of a trip. Policies for the trip come
+// first, then policies for its route and last the general ones, each in the
+// order they are configured.
+func RefundPolicyFor(config Config, trip model.Trip) RefundPolicy {
+	var route, general *RefundPolicy
+
+	for i, policy := range config.RefundPolicies {
+		switch {
+		case len(policy.TripIds) > 0:
+			for _, tripId := range policy.TripIds {
+				if tripId == trip.Id {
+					return policy
+				}
+			}
+		case policy.OriginId != 0:
+			if route == nil && policy.OriginId == trip.OriginId && policy.DestinationId == trip.DestinationId {
+				route = &config.RefundPolicies[i]
+			}
+		default:
+			if general == nil {
+				general = &config.RefundPolicies[i]
+			}
+		}
+	}
+
+	if route != nil {
+		return *route
+	}
+	if general != nil {
+		return *general
+	}
+
+	return DefaultRefundPolicy
+}
+
+// EvaluateRefund computes the refund of a booking of price cancelled at
+// cancelTime. The tier with the highest MinHoursBefore that has not passed
+// yet applies, and nothing is refunded once the bus has departed.
+func EvaluateRefund(policy RefundPolicy, price float64, departure time.Time, cancelTime time.Time) Refund {
+	hoursBefore := departure.Sub(cancelTime).Hours()
+	refund := Refund{Policy: policy.Name, HoursBefore: round(hoursBefore)}
+	if hoursBefore <= 0 {
+		return refund
+	}
+
+	var applied *RefundTier
+	for i, tier := range policy.Tiers {
+		if hoursBefore >= tier.MinHoursBefore && (applied == nil || tier.MinHoursBefore > applied.MinHoursBefore) {
+			applied = &policy.Tiers[i]
+		}
+	}
+
+	if applied != nil {
+		refund.Percent = applied.Percent
+		refund.Amount = round(price * applied.Percent / 100)
+	}
+
+	return refund
+}
+
+func validateRefundPolicy(policy RefundPolicy) error {
+	if len(policy.TripIds) > 0 && (policy.OriginId != 0 || policy.DestinationId != 0) {
+		return fmt.Errorf("tripIds and a route can not be combined")
+	}
+	if (policy.OriginId == 0) != (policy.DestinationId == 0) {
+		return fmt.Errorf("a route needs both originId and destinationId")
+	}
+
+	for _, tier := range policy.Tiers {
+		if tier.MinHoursBefore < 0 {
+			return fmt.Errorf("minHoursBefore can not be negative")
+		}
+		if tier.Percent < 0 || tier.Percent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var testDeparture = time.Date(2026, 3, 7, 8, 0, 0, 0, time.UTC)
+
+func TestEvaluateRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancelTime time.Time
+		percent    float64
+		amount     float64
+	}{
+		{name: "a week before", cancelTime: testDeparture.AddDate(0, 0, -7), percent: 100, amount: 30.5},
+		{name: "exactly 48 hours before", cancelTime: testDeparture.Add(-48 * time.Hour), percent: 100, amount: 30.5},
+		{name: "a day before", cancelTime: testDeparture.Add(-24 * time.Hour), percent: 50, amount: 15.25},
+		{name: "a minute before", cancelTime: testDeparture.Add(-time.Minute), percent: 50, amount: 15.25},
+		{name: "at departure", cancelTime: testDeparture, percent: 0, amount: 0},
+		{name: "after departure", cancelTime: testDeparture.Add(time.Hour), percent: 0, amount: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			refund := EvaluateRefund(DefaultRefundPolicy, 30.5, testDeparture, test.cancelTime)
+			if refund.Percent != test.percent || refund.Amount != test.amount {
+				t.Fatalf("expected %v%% (%v), got %v%% (%v)", test.percent, test.amount, refund.Percent, refund.Amount)
+			}
+			if refund.Policy != "default" {
+				t.Fatalf("expected %v, got %v", "default", refund.Policy)
+			}
+		})
+	}
+}
+
+func TestRefundPolicyFor(t *testing.T) {
+	nonRefundable := RefundPolicy{Name: "non refundable", TripIds: []int32{3}}
+	route := RefundPolicy{Name: "route", OriginId: 1, DestinationId: 2, Tiers: []RefundTier{{MinHoursBefore: 24, Percent: 80}}}
+	general := RefundPolicy{Name: "general", Tiers: []RefundTier{{MinHoursBefore: 72, Percent: 100}}}
+	config := Config{RefundPolicies: []RefundPolicy{general, route, nonRefundable}}
+
+	tests := []struct {
+		name     string
+		config   Config
+		trip     model.Trip
+		expected string
+	}{
+		{name: "trip policy first", config: config, trip: model.Trip{Id: 3, OriginId: 1, DestinationId: 2}, expected: "non refundable"},
+		{name: "then route policy", config: config, trip: model.Trip{Id: 4, OriginId: 1, DestinationId: 2}, expected: "route"},
+		{name: "route is directional", config: config, trip: model.Trip{Id: 5, OriginId: 2, DestinationId: 1}, expected: "general"},
+		{name: "default without policies", config: Config{}, trip: model.Trip{Id: 3}, expected: "default"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := RefundPolicyFor(test.config, test.trip)
+			if policy.Name != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, policy.Name)
+			}
+		})
+	}
+
+	refund := EvaluateRefund(nonRefundable, 30, testDeparture, testDeparture.AddDate(0, 0, -30))
+	if refund.Amount != 0 {
+		t.Fatalf("expected %v, got %v", 0, refund.Amount)
+	}
+}
+
+func TestValidateRefundPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RefundPolicy
+		valid  bool
+	}{
+		{name: "default", policy: DefaultRefundPolicy, valid: true},
+		{name: "non refundable", policy: RefundPolicy{Name: "x", TripIds: []int32{1}}, valid: true},
+		{name: "missing name", policy: RefundPolicy{Tiers: []RefundTier{{Percent: 100}}}, valid: false},
+		{name: "trips and route", policy: RefundPolicy{Name: "x", TripIds: []int32{1}, OriginId: 1, DestinationId: 2}, valid: false},
+		{name: "half a route", policy: RefundPolicy{Name: "x", OriginId: 1}, valid: false},
+		{name: "after departure", policy: RefundPolicy{Name: "x", Tiers: []RefundTier{{MinHoursBefore: -2, Percent: 10}}}, valid: false},
+		{name: "more than paid", policy: RefundPolicy{Name: "x", Tiers: []RefundTier{{Percent: 120}}}, valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateConfig(Config{RefundPolicies: []RefundPolicy{test.policy}})
+			if test.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("expected error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/pricing"
+	"github.com/gbandres98/pack-and-go/ticket"
+)
+
+var ErrorDepartureNotAvailable = errors.New("departure not available")
+var ErrorTicketRevoked = errors.New("ticket no longer valid")
+
+type bookingDB interface {
+	AddBooking(model.Booking, int) (model.Booking, error)
+	UpdateBooking(model.Booking) (model.Booking, error)
+	DeleteBooking(int32) error
+	CancelBooking(int32, time.Time, func(model.Booking) model.LedgerEntry) (model.Booking, model.LedgerEntry, error)
+	GetLedgerEntries(int32) []model.LedgerEntry
+	GetBookingById(int32) (model.Booking, error)
+	GetBookingsByCustomer(int32) []model.Booking
+	GetBookingsByDeparture(model.DepartureKey) []model.Booking
+	SeatsTaken(model.DepartureKey) int
+}
+
+type pricingEngine interface {
+	Quote(pricing.Request) pricing.Quote
+	Refund(model.Trip, float64, time.Time, time.Time) pricing.Refund
+}
+
+type promoRedeemer interface {
+	RedeemPromoCode(string, string, model.Trip, time.Time) (model.PromoCode, error)
+	ReleasePromoCode(string, string) error
+}
+
+type bookingService struct {
+	tripDB        tripDB
+	bookingDB     bookingDB
+	customerDB    customerDB
+	disruptionDB  disruptionDB
+	pricingEngine pricingEngine
+	promoRedeemer promoRedeemer
+	ticketKey     ed25519.PrivateKey
+	auditor       auditor
+	now           func() time.Time
+}
+
+// AffectedBooking is a booking on a departure, with how to contact its customer
+type AffectedBooking struct {
+	model.Booking
+	CustomerName  string `json:"customerName"`
+	CustomerEmail string `json:"customerEmail"`
+}
+
+type BookingRequest struct {
+	TripId      int32
+	Departure   time.Time
+	PassengerId int32
+	PromoCode   string
+}
+
+func NewBookingService(tripDB tripDB, bookingDB bookingDB, customerDB customerDB, disruptionDB disruptionDB, pricingEngine pricingEngine, promoRedeemer promoRedeemer, ticketKey ed25519.PrivateKey, auditor auditor) *bookingService {
+	return &bookingService{tripDB, bookingDB, customerDB, disruptionDB, pricingEngine, promoRedeemer, ticketKey, auditor, time.Now}
+}
+
+// Book takes a seat on a departure for one of the passengers of customer, at
+// the current fare with the discount of a promo code, and issues its ticket
+func (bookingService *bookingService) Book(ctx context.Context, customer model.Customer, request BookingRequest) (model.Booking, error) {
+	now := bookingService.now()
+
+	trip, err := bookingService.tripDB.GetTripById(request.TripId)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if !trip.RunsOn(request.Departure.Weekday()) {
+		return model.Booking{}, fmt.Errorf("%w: trip %v does not run on %v", ErrorDepartureNotAvailable, trip.Id, request.Departure.Weekday())
+	}
+	if !request.Departure.After(now) {
+		return model.Booking{}, fmt.Errorf("%w: departure is in the past", ErrorDepartureNotAvailable)
+	}
+
+	disruption, err := bookingService.disruptionDB.GetDisruption(model.NewDepartureKey(trip.Id, request.Departure))
+	if err == nil && disruption.Status == model.DisruptionCancelled {
+		return model.Booking{}, fmt.Errorf("%w: departure cancelled: %v", ErrorDepartureNotAvailable, disruption.Reason)
+	}
+
+	passenger, err := bookingService.customerDB.GetPassengerById(request.PassengerId)
+	if err == nil && passenger.CustomerId != customer.Id {
+		err = db.ErrorPassengerNotFound
+	}
+	if err != nil {
+		return model.Booking{}, err
+	}
+
+	quote := bookingService.pricingEngine.Quote(pricing.Request{
+		Trip:         trip,
+		Departure:    request.Departure,
+		PurchaseTime: now,
+		LoadFactor:   bookingService.LoadFactor(trip, request.Departure),
+		PassengerAge: passenger.AgeOn(request.Departure),
+	})
+
+	booking, err := bookingService.bookingDB.AddBooking(model.Booking{
+		CustomerId:    customer.Id,
+		TripId:        trip.Id,
+		Departure:     request.Departure,
+		PassengerId:   passenger.Id,
+		PassengerName: strings.TrimSpace(passenger.FirstName + " " + passenger.LastName),
+		Price:         quote.Price,
+	}, model.DefaultSeats)
+	if err != nil {
+		return model.Booking{}, err
+	}
+
+	// The seat is taken before the code is redeemed, so a full departure does not use up a redemption
+	if request.PromoCode != "" {
+		promoCode, err := bookingService.promoRedeemer.RedeemPromoCode(request.PromoCode, customer.Email, trip, request.Departure)
+		if err != nil {
+			bookingService.bookingDB.DeleteBooking(booking.Id)
+			return model.Booking{}, err
+		}
+
+		booking.PromoCode = promoCode.Code
+		booking.Price = math.Round((booking.Price-promoCode.Discount(booking.Price))*100) / 100
+	}
+
+	// rollback frees the seat and gives back the redemption of the promo code, if any
+	rollback := func() {
+		bookingService.bookingDB.DeleteBooking(booking.Id)
+		if booking.PromoCode != "" {
+			if err := bookingService.promoRedeemer.ReleasePromoCode(booking.PromoCode, customer.Email); err != nil {
+				log.Printf("could not release promo code %v of booking %v: %v", booking.PromoCode, booking.Id, err)
+			}
+		}
+	}
+
+	booking.Ticket, err = ticket.Issue(ticket.Ticket{
+		BookingId: booking.Id,
+		TripId:    booking.TripId,
+		Departure: booking.Departure,
+		Seat:      booking.Seat,
+		Passenger: booking.PassengerName,
+		ExpiresAt: booking.Departure.Add(model.DefaultTravelTime),
+	}, bookingService.ticketKey)
+	if err != nil {
+		rollback()
+		return model.Booking{}, fmt.Errorf("could not issue ticket: %w", err)
+	}
+
+	updated, err := bookingService.bookingDB.UpdateBooking(booking)
+	if err != nil {
+		rollback()
+		return model.Booking{}, err
+	}
+	booking = updated
+
+	bookingService.auditor.Record(ctx, audit.EntityBooking, strconv.Itoa(int(booking.Id)), audit.ActionCreate, nil, auditedBooking(booking))
+	return booking, nil
+}
+
+// GetBooking returns a booking of customer. Bookings of other customers are reported as not found.
+func (bookingService *bookingService) GetBooking(customerId int32, id int32) (model.Booking, error) {
+	booking, err := bookingService.bookingDB.GetBookingById(id)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if booking.CustomerId != customerId {
+		return model.Booking{}, db.ErrorBookingNotFound
+	}
+
+	return booking, nil
+}
+
+func (bookingService *bookingService) GetBookings(customerId int32) []model.Booking {
+	return bookingService.bookingDB.GetBookingsByCustomer(customerId)
+}
+
+// GetDepartureBookings returns the confirmed bookings of a departure, so
+// their customers can be told about disruptions
+func (bookingService *bookingService) GetDepartureBookings(tripId int32, departure time.Time) ([]AffectedBooking, error) {
+	result := []AffectedBooking{}
+
+	for _, booking := range bookingService.bookingDB.GetBookingsByDeparture(model.NewDepartureKey(tripId, departure)) {
+		if booking.Status != model.BookingConfirmed {
+			continue
+		}
+
+		customer, err := bookingService.customerDB.GetCustomerById(booking.CustomerId)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, AffectedBooking{Booking: booking, CustomerName: customer.Name, CustomerEmail: customer.Email})
+	}
+
+	return result, nil
+}
+
+// RefundQuote returns what cancelling a booking of customer now would refund, without cancelling it
+func (bookingService *bookingService) RefundQuote(customerId int32, id int32) (pricing.Refund, error) {
+	booking, err := bookingService.GetBooking(customerId, id)
+	if err != nil {
+		return pricing.Refund{}, err
+	}
+	if booking.Status == model.BookingCancelled {
+		return pricing.Refund{}, db.ErrorBookingCancelled
+	}
+
+	return bookingService.refund(booking, bookingService.trip(booking), bookingService.now()), nil
+}
+
+// Cancel cancels a booking of customer, releasing its seat and recording its
+// refund in the ledger. Its ticket is no longer valid afterwards.
+func (bookingService *bookingService) Cancel(ctx context.Context, customerId int32, id int32) (model.Booking, model.LedgerEntry, error) {
+	booking, err := bookingService.GetBooking(customerId, id)
+	if err != nil {
+		return model.Booking{}, model.LedgerEntry{}, err
+	}
+
+	trip := bookingService.trip(booking)
+	now := bookingService.now()
+
+	cancelled, entry, err := bookingService.bookingDB.CancelBooking(booking.Id, now, func(booking model.Booking) model.LedgerEntry {
+		refund := bookingService.refund(booking, trip, now)
+
+		return model.LedgerEntry{
+			Type:        model.LedgerRefund,
+			Amount:      refund.Amount,
+			Description: fmt.Sprintf("%v%% refund under policy %v, cancelled %.2f hours before departure", refund.Percent, refund.Policy, refund.HoursBefore),
+		}
+	})
+	if err != nil {
+		return model.Booking{}, model.LedgerEntry{}, err
+	}
+
+	bookingService.auditor.Record(ctx, audit.EntityBooking, strconv.Itoa(int(cancelled.Id)), audit.ActionCancel, auditedBooking(booking), auditedBooking(cancelled))
+	return cancelled, entry, nil
+}
+
+func (bookingService *bookingService) GetLedger(customerId int32) []model.LedgerEntry {
+	return bookingService.bookingDB.GetLedgerEntries(customerId)
+}
+
+// auditedBooking returns the booking as recorded in the audit log, without
+// its ticket, which would let anyone reading the log board with it
+func auditedBooking(booking model.Booking) model.Booking {
+	booking.Ticket = ""
+	return booking
+}
+
+func (bookingService *bookingService) refund(booking model.Booking, trip model.Trip, now time.Time) pricing.Refund {
+	return bookingService.pricingEngine.Refund(trip, booking.Price, booking.Departure, now)
+}
+
+// trip returns the trip of a booking, or a trip with only its id if it no longer exists
+func (bookingService *bookingService) trip(booking model.Booking) model.Trip {
+	trip, err := bookingService.tripDB.GetTripById(booking.TripId)
+	if err != nil {
+		return model.Trip{Id: booking.TripId}
+	}
+
+	return trip
+}
+
+// LoadFactor returns the share of seats already booked on a departure
+func (bookingService *bookingService) LoadFactor(trip model.Trip, departure time.Time) float64 {
+	taken := bookingService.bookingDB.SeatsTaken(model.NewDepartureKey(trip.Id, departure))
+	return float64(taken) / model.DefaultSeats
+}
+
+// VerifyTicket checks the signature and expiry of a ticket code, and that its
+// booking has not been cancelled since it was issued
+func (bookingService *bookingService) VerifyTicket(code string) (ticket.Ticket, error) {
+	verified, err := ticket.Verify(code, bookingService.PublicKey(), bookingService.now())
+	if err != nil {
+		return verified, err
+	}
+
+	booking, err := bookingService.bookingDB.GetBookingById(verified.BookingId)
+	if err == db.ErrorBookingNotFound {
+		return verified, ErrorTicketRevoked
+	}
+	if err != nil {
+		return verified, err
+	}
+	if booking.Status != model.BookingConfirmed || booking.Ticket != strings.TrimSpace(code) {
+		return verified, ErrorTicketRevoked
+	}
+
+	return verified, nil
+}
+
+func (bookingService *bookingService) PublicKey() ed25519.PublicKey {
+	return bookingService.ticketKey.Public().(ed25519.PublicKey)
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/pricing"
+	"github.com/gbandres98/pack-and-go/ticket"
+)
+
+var testBookingCustomer = model.Customer{Id: 1, Email: "alice@example.com"}
+
+var testTicketKey = ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+
+type testBooking struct {
+	bookingService *bookingService
+	bookingDB      bookingDB
+	promoService   *promoService
+	passenger      model.Passenger
+}
+
+func newTestBookingService(t *testing.T) testBooking {
+	customerDB := db.NewCustomerDB()
+	passenger := customerDB.AddPassenger(model.Passenger{CustomerId: testBookingCustomer.Id, FirstName: "Alice", LastName: "Smith", BirthDate: "1950-01-01"})
+
+	pricingEngine, err := pricing.NewEngineWithConfig(pricing.Config{Rules: []pricing.Rule{
+		{Name: "senior", Type: pricing.RulePassengerAge, MinAge: intPointer(65), Adjustment: pricing.Adjustment{Percent: -50}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	promoService := newTestPromoService(model.PromoCode{Code: "ONCE", Amount: 5, MaxUses: 1})
+	bookingDB := db.NewBookingDB()
+
+	bookingService := NewBookingService(db.NewMemoryDB(), bookingDB, customerDB, newMockDisruptionDB(), pricingEngine, promoService, testTicketKey, &mockAuditor{})
+	bookingService.now = func() time.Time { return testPromoNow }
+
+	return testBooking{bookingService, bookingDB, promoService, passenger}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestBook_1(t *testing.T) {
+	test := newTestBookingService(t)
+
+	booking, err := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if booking.Seat != 1 || booking.PassengerName != "Alice Smith" || booking.CustomerId != testBookingCustomer.Id {
+		t.Fatalf("expected seat 1 for Alice Smith, got %v", booking)
+	}
+	if booking.Price != 20.27 {
+		t.Fatalf("expected senior fare %v, got %v", 20.27, booking.Price)
+	}
+
+	verified, err := ticket.Verify(booking.Ticket, testTicketKey.Public().(ed25519.PublicKey), testPromoNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if verified.BookingId != booking.Id || verified.Seat != 1 || !verified.Departure.Equal(testPromoDeparture) {
+		t.Fatalf("expected ticket for booking %v, got %v", booking.Id, verified)
+	}
+}
+
+func TestBook_2(t *testing.T) {
+	test := newTestBookingService(t)
+
+	booking, err := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id, PromoCode: "once"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if booking.PromoCode != "ONCE" || booking.Price != 15.27 {
+		t.Fatalf("expected 5 off with ONCE, got %v", booking)
+	}
+
+	_, err = test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id, PromoCode: "ONCE"})
+	if err != db.ErrorPromoCodeExhausted {
+		t.Fatalf("expected %v, got %v", db.ErrorPromoCodeExhausted, err)
+	}
+
+	if taken := test.bookingDB.SeatsTaken(model.NewDepartureKey(2, testPromoDeparture)); taken != 1 {
+		t.Fatalf("expected the seat of the failed booking to be released, got %v seats taken", taken)
+	}
+}
+
+func TestBook_3(t *testing.T) {
+	test := newTestBookingService(t)
+
+	tests := []struct {
+		customer model.Customer
+		request  BookingRequest
+		expected error
+	}{
+		{customer: testBookingCustomer, request: BookingRequest{TripId: 4, Departure: testPromoDeparture, PassengerId: test.passenger.Id}, expected: db.ErrorTripNotFound},
+		{customer: testBookingCustomer, request: BookingRequest{TripId: 1, Departure: testPromoDeparture, PassengerId: test.passenger.Id}, expected: ErrorDepartureNotAvailable},
+		{customer: testBookingCustomer, request: BookingRequest{TripId: 2, Departure: testPromoNow.AddDate(0, 0, -2), PassengerId: test.passenger.Id}, expected: ErrorDepartureNotAvailable},
+		{customer: model.Customer{Id: 2}, request: BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id}, expected: db.ErrorPassengerNotFound},
+	}
+
+	for _, test := range tests {
+		_, err := newTestBookingService(t).bookingService.Book(context.Background(), test.customer, test.request)
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("expected %v, got %v", test.expected, err)
+		}
+	}
+}
+
+type failingBookingDB struct {
+	bookingDB
+}
+
+func (failingBookingDB *failingBookingDB) UpdateBooking(booking model.Booking) (model.Booking, error) {
+	return model.Booking{}, errors.New("test error")
+}
+
+func TestBook_5(t *testing.T) {
+	test := newTestBookingService(t)
+	test.bookingService.bookingDB = &failingBookingDB{test.bookingDB}
+
+	_, err := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id, PromoCode: "ONCE"})
+	if err == nil {
+		t.Fatalf("expected the booking to fail")
+	}
+
+	// A booking that could not be saved neither holds the seat nor uses up the code
+	if taken := test.bookingDB.SeatsTaken(model.NewDepartureKey(2, testPromoDeparture)); taken != 0 {
+		t.Fatalf("expected the seat of the failed booking to be released, got %v seats taken", taken)
+	}
+	if promoCode, _ := test.promoService.GetPromoCode("ONCE"); promoCode.Uses != 0 {
+		t.Fatalf("expected the redemption of the failed booking to be released, got %v uses", promoCode.Uses)
+	}
+}
+
+func TestVerifyTicket_1(t *testing.T) {
+	test := newTestBookingService(t)
+	booking, _ := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+
+	_, err := test.bookingService.VerifyTicket(booking.Ticket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	test.bookingDB.DeleteBooking(booking.Id)
+	_, err = test.bookingService.VerifyTicket(booking.Ticket)
+	if err != ErrorTicketRevoked {
+		t.Fatalf("expected %v, got %v", ErrorTicketRevoked, err)
+	}
+
+	test.bookingService.now = func() time.Time { return testPromoDeparture.Add(2 * time.Hour) }
+	_, err = test.bookingService.VerifyTicket(booking.Ticket)
+	if err != ticket.ErrorExpired {
+		t.Fatalf("expected %v, got %v", ticket.ErrorExpired, err)
+	}
+}
+
+func TestLoadFactor_1(t *testing.T) {
+	test := newTestBookingService(t)
+	trip := model.Trip{Id: 2}
+
+	for i := 0; i < 5; i++ {
+		test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	}
+
+	loadFactor := test.bookingService.LoadFactor(trip, testPromoDeparture)
+	if loadFactor != 5.0/model.DefaultSeats {
+		t.Fatalf("expected %v, got %v", 5.0/model.DefaultSeats, loadFactor)
+	}
+}
+
+func TestCancel_1(t *testing.T) {
+	test := newTestBookingService(t)
+	booking, _ := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+
+	_, _, err := test.bookingService.Cancel(context.Background(), 2, booking.Id)
+	if err != db.ErrorBookingNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorBookingNotFound, err)
+	}
+
+	cancelled, entry, err := test.bookingService.Cancel(context.Background(), testBookingCustomer.Id, booking.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cancelled.Status != model.BookingCancelled {
+		t.Fatalf("expected %v, got %v", model.BookingCancelled, cancelled.Status)
+	}
+	if entry.Type != model.LedgerRefund || entry.Amount != booking.Price {
+		t.Fatalf("expected full refund of %v, got %v", booking.Price, entry)
+	}
+
+	_, err = test.bookingService.VerifyTicket(booking.Ticket)
+	if err != ErrorTicketRevoked {
+		t.Fatalf("expected %v, got %v", ErrorTicketRevoked, err)
+	}
+
+	_, _, err = test.bookingService.Cancel(context.Background(), testBookingCustomer.Id, booking.Id)
+	if err != db.ErrorBookingCancelled {
+		t.Fatalf("expected %v, got %v", db.ErrorBookingCancelled, err)
+	}
+
+	recorded := test.bookingService.auditor.(*mockAuditor).recorded
+	if len(recorded) != 2 || recorded[0].action != audit.ActionCreate || recorded[1].action != audit.ActionCancel {
+		t.Fatalf("expected the booking and its cancellation to be audited, got %v", recorded)
+	}
+	if recorded[1].before.(model.Booking).Status == model.BookingCancelled || recorded[1].after.(model.Booking).Status != model.BookingCancelled {
+		t.Fatalf("expected the booking before and after its cancellation, got %v", recorded[1])
+	}
+	if recorded[0].after.(model.Booking).Ticket != "" || recorded[1].before.(model.Booking).Ticket != "" {
+		t.Fatalf("expected tickets to be left out of the audit log, got %v", recorded)
+	}
+}
+
+func TestCancel_2(t *testing.T) {
+	test := newTestBookingService(t)
+	booking, _ := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	test.bookingService.now = func() time.Time { return testPromoDeparture.Add(-24 * time.Hour) }
+
+	refund, err := test.bookingService.RefundQuote(testBookingCustomer.Id, booking.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if refund.Percent != 50 || refund.Amount != 10.14 || refund.Policy != "default" {
+		t.Fatalf("expected 50%% refund of %v under the default policy, got %v", 10.14, refund)
+	}
+
+	_, entry, err := test.bookingService.Cancel(context.Background(), testBookingCustomer.Id, booking.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Amount != refund.Amount {
+		t.Fatalf("expected %v, got %v", refund.Amount, entry.Amount)
+	}
+
+	ledger := test.bookingService.GetLedger(testBookingCustomer.Id)
+	if len(ledger) != 1 || ledger[0] != entry {
+		t.Fatalf("expected ledger %v, got %v", []model.LedgerEntry{entry}, ledger)
+	}
+	if taken := test.bookingDB.SeatsTaken(booking.DepartureKey()); taken != 0 {
+		t.Fatalf("expected the seat to be released, got %v seats taken", taken)
+	}
+}
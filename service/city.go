@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var ErrorCityExists = errors.New("city already exists")
+
+type writableCityDB interface {
+	cityDB
+	AddCity(model.City) (model.City, error)
+	UpdateCity(model.City) (model.City, error)
+}
+
+type cityService struct {
+	cityDB  writableCityDB
+	auditor auditor
+}
+
+func NewCityService(cityDB writableCityDB, auditor auditor) *cityService {
+	return &cityService{cityDB, auditor}
+}
+
+func (cityService *cityService) GetAllCities() ([]model.City, error) {
+	return cityService.cityDB.GetAllCities()
+}
+
+func (cityService *cityService) GetCityById(id int32) (model.City, error) {
+	return cityService.cityDB.GetCityById(id)
+}
+
+// GetCityByName finds a city by name, ignoring case and surrounding spaces
+func (cityService *cityService) GetCityByName(name string) (model.City, bool, error) {
+	cities, err := cityService.cityDB.GetAllCities()
+	if err != nil {
+		return model.City{}, false, err
+	}
+
+	for _, city := range cities {
+		if strings.EqualFold(strings.TrimSpace(city.Name), strings.TrimSpace(name)) {
+			return city, true, nil
+		}
+	}
+
+	return model.City{}, false, nil
+}
+
+func (cityService *cityService) AddCity(ctx context.Context, city model.City) (model.City, error) {
+	city.Id = 0
+	city, err := cityService.validateCity(city)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	city, err = cityService.cityDB.AddCity(city)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	cityService.auditor.Record(ctx, audit.EntityCity, strconv.Itoa(int(city.Id)), audit.ActionCreate, nil, city)
+	return city, nil
+}
+
+// UpdateCity replaces the city with the id of city, such as to rename it.
+// Its trips keep referencing it by id.
+func (cityService *cityService) UpdateCity(ctx context.Context, city model.City) (model.City, error) {
+	before, err := cityService.cityDB.GetCityById(city.Id)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	city, err = cityService.validateCity(city)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	city, err = cityService.cityDB.UpdateCity(city)
+	if err != nil {
+		return model.City{}, err
+	}
+
+	cityService.auditor.Record(ctx, audit.EntityCity, strconv.Itoa(int(city.Id)), audit.ActionUpdate, before, city)
+	return city, nil
+}
+
+// validateCity trims the name of the city and checks it is not the name of
+// another city. No field can span lines, as the file store keeps a city per line.
+func (cityService *cityService) validateCity(city model.City) (model.City, error) {
+	city.Name = strings.TrimSpace(city.Name)
+
+	if city.Name == "" {
+		return model.City{}, fmt.Errorf("city name can not be empty")
+	}
+	fields := []struct{ name, value string }{
+		{"name", city.Name},
+		{"country", city.Country},
+		{"timezone", city.Timezone},
+		{"address", city.Address},
+	}
+	for _, field := range fields {
+		if strings.ContainsAny(field.value, "\r\n") {
+			return model.City{}, fmt.Errorf("invalid city %v: %q", field.name, field.value)
+		}
+	}
+	if city.Timezone != "" {
+		if _, err := time.LoadLocation(city.Timezone); err != nil || city.Timezone == "Local" {
+			return model.City{}, fmt.Errorf("invalid city timezone, expected an IANA time zone name such as Europe/Madrid: %q", city.Timezone)
+		}
+	}
+	if city.Latitude < -90 || city.Latitude > 90 || city.Longitude < -180 || city.Longitude > 180 {
+		return model.City{}, fmt.Errorf("invalid city coordinates: %v,%v", city.Latitude, city.Longitude)
+	}
+
+	existing, exists, err := cityService.GetCityByName(city.Name)
+	if err != nil {
+		return model.City{}, err
+	}
+	if exists && existing.Id != city.Id {
+		return model.City{}, fmt.Errorf("%w: %v", ErrorCityExists, city.Name)
+	}
+
+	return city, nil
+}
+
+// GetCitiesNear returns the cities with known coordinates within radiusKm of a
+// point, closest first
+func (cityService *cityService) GetCitiesNear(latitude float64, longitude float64, radiusKm float64) ([]model.CityNearby, error) {
+	cities, err := cityService.cityDB.GetAllCities()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []model.CityNearby{}
+	for _, city := range cities {
+		if !city.HasCoordinates() {
+			continue
+		}
+
+		distance := model.DistanceKm(latitude, longitude, city.Latitude, city.Longitude)
+		if distance <= radiusKm {
+			result = append(result, model.CityNearby{City: city, DistanceKm: distance})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DistanceKm < result[j].DistanceKm })
+
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestGetAllCities_1(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	cities, err := cityService.GetAllCities()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cities, testCities) {
+		t.Fatalf("expected %v, got %v", testCities, cities)
+	}
+}
+
+func TestGetCityById_1(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	city, err := cityService.GetCityById(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(city, testCities[1]) {
+		t.Fatalf("expected %v, got %v", testCities[1], city)
+	}
+}
+
+func TestGetCityById_2(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	_, err := cityService.GetCityById(3)
+	if err != db.ErrorCityNotFound {
+		t.Fatalf("expected error: %v, got error: %v", db.ErrorCityNotFound, err)
+	}
+}
+
+func TestGetCityByName_1(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	city, found, err := cityService.GetCityByName(" madrid ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found || city.Id != 2 {
+		t.Fatalf("expected to find %v, got %v", testCities[1], city)
+	}
+}
+
+func TestAddCity_1(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	city, err := cityService.AddCity(context.Background(), model.City{Name: " Valencia "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if city.Id != 3 || city.Name != "Valencia" {
+		t.Fatalf("expected city %v with id %v, got %v", "Valencia", 3, city)
+	}
+}
+
+func TestAddCity_2(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	_, err := cityService.AddCity(context.Background(), model.City{Name: "SEVILLA"})
+	if !errors.Is(err, ErrorCityExists) {
+		t.Fatalf("expected error: %v, got error: %v", ErrorCityExists, err)
+	}
+}
+
+func TestAddCity_3(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	for _, name := range []string{"", "  ", "Valencia\nMadrid"} {
+		_, err := cityService.AddCity(context.Background(), model.City{Name: name})
+		if err == nil {
+			t.Fatalf("expected error for name %q, got %v", name, err)
+		}
+	}
+
+	// A line break in any field would add a city to the file store
+	invalidCities := []model.City{
+		{Name: "Valencia", Address: "Calle de Xàtiva 24\nMadrid"},
+		{Name: "Valencia", Country: "ES\r"},
+		{Name: "Valencia", Timezone: "Europe/Madrid\n"},
+		{Name: "Valencia", Timezone: "Europe/Valencia"},
+		{Name: "Valencia", Timezone: "Local"},
+	}
+	for _, city := range invalidCities {
+		_, err := cityService.AddCity(context.Background(), city)
+		if err == nil {
+			t.Fatalf("expected error for city %+v, got %v", city, err)
+		}
+	}
+
+	city, err := cityService.AddCity(context.Background(), model.City{Name: "Valencia", Country: "ES", Timezone: "Europe/Madrid", Address: "Calle de Xàtiva 24"})
+	if err != nil || city.Timezone != "Europe/Madrid" {
+		t.Fatalf("expected city with time zone %v, got %v %v", "Europe/Madrid", city, err)
+	}
+}
+
+func TestGetCitiesNear_1(t *testing.T) {
+	cityService := NewCityService(&mockGeoCityDB{}, &mockAuditor{})
+
+	// Toledo
+	cities, err := cityService.GetCitiesNear(39.8628, -4.0273, 500)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cities) != 2 || cities[0].Name != "Madrid" || cities[1].Name != "Sevilla" {
+		t.Fatalf("expected Madrid and Sevilla closest first, got %v", cities)
+	}
+	if cities[0].DistanceKm > cities[1].DistanceKm {
+		t.Fatalf("expected cities to be sorted by distance, got %v", cities)
+	}
+}
+
+func TestGetCitiesNear_2(t *testing.T) {
+	cityService := NewCityService(&mockGeoCityDB{}, &mockAuditor{})
+
+	cities, err := cityService.GetCitiesNear(0, 0, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cities) != 0 {
+		t.Fatalf("expected no cities, got %v", cities)
+	}
+}
+
+func TestAddCity_4(t *testing.T) {
+	cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+	_, err := cityService.AddCity(context.Background(), model.City{Name: "Atlantis", Latitude: 95})
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestUpdateCity_1(t *testing.T) {
+	cityDB := &mockCityDB{}
+	cityService := NewCityService(cityDB, &mockAuditor{})
+
+	city, err := cityService.UpdateCity(context.Background(), model.City{Id: 2, Name: " Madrid de los Austrias "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if city.Id != 2 || city.Name != "Madrid de los Austrias" || len(cityDB.updatedCities) != 1 {
+		t.Fatalf("expected city 2 to be renamed, got %v", city)
+	}
+}
+
+func TestUpdateCity_2(t *testing.T) {
+	tests := []struct {
+		city     model.City
+		expected error
+	}{
+		{city: model.City{Id: 2, Name: "madrid"}},
+		{city: model.City{Id: 2, Name: "Sevilla"}, expected: ErrorCityExists},
+		{city: model.City{Id: 3, Name: "Valencia"}, expected: db.ErrorCityNotFound},
+	}
+
+	for _, test := range tests {
+		cityService := NewCityService(&mockCityDB{}, &mockAuditor{})
+
+		_, err := cityService.UpdateCity(context.Background(), test.city)
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("expected error: %v, got error: %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const minPasswordLength = 8
+const sessionTokenSize = 32
+
+// ErrorInvalidCredentials does not tell apart unknown emails from wrong passwords
+var ErrorInvalidCredentials = errors.New("invalid email or password")
+
+type customerDB interface {
+	AddCustomer(model.Customer) (model.Customer, error)
+	GetCustomerById(int32) (model.Customer, error)
+	GetCustomerByEmail(string) (model.Customer, error)
+	AddSession(model.Session) error
+	GetSession(string) (model.Session, error)
+	DeleteSession(string) error
+	GetPassengers(int32) []model.Passenger
+	GetPassengerById(int32) (model.Passenger, error)
+	AddPassenger(model.Passenger) model.Passenger
+	UpdatePassenger(model.Passenger) (model.Passenger, error)
+	DeletePassenger(int32) error
+}
+
+type customerService struct {
+	customerDB
+	sessionTTL time.Duration
+	// Compared against on logins with unknown emails, so they take as long as wrong passwords
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+	now                   func() time.Time
+}
+
+func NewCustomerService(customerDB customerDB, sessionTTL time.Duration) *customerService {
+	return &customerService{customerDB: customerDB, sessionTTL: sessionTTL, now: time.Now}
+}
+
+// Register creates a customer with a hash of password
+func (customerService *customerService) Register(customer model.Customer, password string) (model.Customer, error) {
+	customer.Email = strings.ToLower(strings.TrimSpace(customer.Email))
+	customer.Name = strings.TrimSpace(customer.Name)
+
+	at := strings.Index(customer.Email, "@")
+	if at < 1 || at == len(customer.Email)-1 || strings.ContainsAny(customer.Email, " \r\n") {
+		return model.Customer{}, fmt.Errorf("invalid email: %v", customer.Email)
+	}
+	if len(password) < minPasswordLength {
+		return model.Customer{}, fmt.Errorf("password must be at least %v characters long", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return model.Customer{}, fmt.Errorf("password can not be longer than %v bytes", maxPasswordLength)
+	}
+
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return model.Customer{}, err
+	}
+	customer.PasswordHash = passwordHash
+
+	return customerService.customerDB.AddCustomer(customer)
+}
+
+// Login checks the credentials of a customer and starts a session, returning
+// its token. Only a hash of the token is stored.
+func (customerService *customerService) Login(email string, password string) (string, model.Session, error) {
+	customer, err := customerService.customerDB.GetCustomerByEmail(strings.TrimSpace(email))
+	if err == db.ErrorCustomerNotFound {
+		customerService.dummyPasswordHashOnce.Do(func() {
+			customerService.dummyPasswordHash, _ = HashPassword("")
+		})
+		CheckPassword(password, customerService.dummyPasswordHash)
+		return "", model.Session{}, ErrorInvalidCredentials
+	}
+	if err != nil {
+		return "", model.Session{}, err
+	}
+
+	ok, err := CheckPassword(password, customer.PasswordHash)
+	if err != nil {
+		return "", model.Session{}, err
+	}
+	if !ok {
+		return "", model.Session{}, ErrorInvalidCredentials
+	}
+
+	tokenBytes := make([]byte, sessionTokenSize)
+	_, err = rand.Read(tokenBytes)
+	if err != nil {
+		return "", model.Session{}, fmt.Errorf("could not generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	session := model.Session{
+		TokenHash:  hashToken(token),
+		CustomerId: customer.Id,
+		ExpiresAt:  customerService.now().Add(customerService.sessionTTL).UTC(),
+	}
+
+	err = customerService.customerDB.AddSession(session)
+	if err != nil {
+		return "", model.Session{}, err
+	}
+
+	return token, session, nil
+}
+
+func (customerService *customerService) Logout(token string) error {
+	return customerService.customerDB.DeleteSession(hashToken(token))
+}
+
+// Authenticate returns the customer of the session with the given token,
+// failing with db.ErrorSessionNotFound if it does not exist or has expired
+func (customerService *customerService) Authenticate(token string) (model.Customer, error) {
+	session, err := customerService.customerDB.GetSession(hashToken(token))
+	if err != nil {
+		return model.Customer{}, err
+	}
+
+	return customerService.customerDB.GetCustomerById(session.CustomerId)
+}
+
+// GetPassenger returns a passenger saved by the customer. Passengers of other
+// customers are reported as not found.
+func (customerService *customerService) GetPassenger(customerId int32, id int32) (model.Passenger, error) {
+	passenger, err := customerService.customerDB.GetPassengerById(id)
+	if err != nil {
+		return model.Passenger{}, err
+	}
+	if passenger.CustomerId != customerId {
+		return model.Passenger{}, db.ErrorPassengerNotFound
+	}
+
+	return passenger, nil
+}
+
+func (customerService *customerService) AddPassenger(customerId int32, passenger model.Passenger) (model.Passenger, error) {
+	passenger.CustomerId = customerId
+
+	err := validatePassenger(passenger)
+	if err != nil {
+		return model.Passenger{}, err
+	}
+
+	return customerService.customerDB.AddPassenger(passenger), nil
+}
+
+func (customerService *customerService) UpdatePassenger(customerId int32, id int32, passenger model.Passenger) (model.Passenger, error) {
+	_, err := customerService.GetPassenger(customerId, id)
+	if err != nil {
+		return model.Passenger{}, err
+	}
+
+	passenger.Id = id
+	passenger.CustomerId = customerId
+
+	err = validatePassenger(passenger)
+	if err != nil {
+		return model.Passenger{}, err
+	}
+
+	return customerService.customerDB.UpdatePassenger(passenger)
+}
+
+func (customerService *customerService) DeletePassenger(customerId int32, id int32) error {
+	_, err := customerService.GetPassenger(customerId, id)
+	if err != nil {
+		return err
+	}
+
+	return customerService.customerDB.DeletePassenger(id)
+}
+
+func validatePassenger(passenger model.Passenger) error {
+	if strings.TrimSpace(passenger.FirstName) == "" || strings.TrimSpace(passenger.LastName) == "" {
+		return fmt.Errorf("first and last name can not be empty")
+	}
+
+	if passenger.DocumentNumber != "" && passenger.DocumentType == "" {
+		return fmt.Errorf("document type is required with a document number")
+	}
+
+	if passenger.BirthDate != "" {
+		if _, err := time.Parse("2006-01-02", passenger.BirthDate); err != nil {
+			return fmt.Errorf("invalid birth date, expected YYYY-MM-DD: %v", passenger.BirthDate)
+		}
+	}
+
+	switch passenger.DiscountCategory {
+	case model.DiscountNone, model.DiscountYouth, model.DiscountSenior, model.DiscountLargeFamily, model.DiscountDisability:
+	default:
+		return fmt.Errorf("invalid discount category: %v", passenger.DiscountCategory)
+	}
+
+	return nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func newTestCustomerService(t *testing.T) (*customerService, model.Customer) {
+	customerService := NewCustomerService(db.NewCustomerDB(), time.Hour)
+
+	customer, err := customerService.Register(model.Customer{Email: " Alice@Example.com", Name: "Alice"}, "correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return customerService, customer
+}
+
+func TestRegister_1(t *testing.T) {
+	customerService, customer := newTestCustomerService(t)
+
+	if customer.Id != 1 || customer.Email != "alice@example.com" {
+		t.Fatalf("expected customer 1 with normalized email, got %v", customer)
+	}
+	if customer.PasswordHash == "" || customer.PasswordHash == "correct horse" {
+		t.Fatalf("expected password to be hashed, got %v", customer.PasswordHash)
+	}
+
+	_, err := customerService.Register(model.Customer{Email: "ALICE@example.com"}, "another password")
+	if err != db.ErrorCustomerExists {
+		t.Fatalf("expected %v, got %v", db.ErrorCustomerExists, err)
+	}
+}
+
+func TestRegister_2(t *testing.T) {
+	customerService := NewCustomerService(db.NewCustomerDB(), time.Hour)
+
+	tests := []struct {
+		email    string
+		password string
+	}{
+		{email: "alice", password: "correct horse"},
+		{email: "@example.com", password: "correct horse"},
+		{email: "alice@", password: "correct horse"},
+		{email: "alice@example.com", password: "short"},
+	}
+
+	for _, test := range tests {
+		_, err := customerService.Register(model.Customer{Email: test.email}, test.password)
+		if err == nil {
+			t.Fatalf("expected error for %v, got %v", test, err)
+		}
+	}
+}
+
+func TestLogin_1(t *testing.T) {
+	customerService, customer := newTestCustomerService(t)
+
+	token, session, err := customerService.Login("alice@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" || session.TokenHash == token || session.CustomerId != customer.Id {
+		t.Fatalf("expected a session for customer %v storing a hash of the token, got %v", customer.Id, session)
+	}
+
+	authenticated, err := customerService.Authenticate(token)
+	if err != nil || authenticated.Id != customer.Id {
+		t.Fatalf("expected token to authenticate customer %v, got %v %v", customer.Id, authenticated, err)
+	}
+
+	err = customerService.Logout(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = customerService.Authenticate(token)
+	if err != db.ErrorSessionNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorSessionNotFound, err)
+	}
+}
+
+func TestLogin_2(t *testing.T) {
+	customerService, _ := newTestCustomerService(t)
+
+	_, _, err := customerService.Login("alice@example.com", "wrong horse")
+	if err != ErrorInvalidCredentials {
+		t.Fatalf("expected %v, got %v", ErrorInvalidCredentials, err)
+	}
+
+	_, _, err = customerService.Login("bob@example.com", "correct horse")
+	if err != ErrorInvalidCredentials {
+		t.Fatalf("expected %v, got %v", ErrorInvalidCredentials, err)
+	}
+}
+
+func TestAuthenticate_1(t *testing.T) {
+	customerService, _ := newTestCustomerService(t)
+	customerService.sessionTTL = -time.Second
+
+	token, _, err := customerService.Login("alice@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = customerService.Authenticate(token)
+	if err != db.ErrorSessionNotFound {
+		t.Fatalf("expected expired session to fail with %v, got %v", db.ErrorSessionNotFound, err)
+	}
+}
+
+func TestAddPassenger_1(t *testing.T) {
+	customerService, customer := newTestCustomerService(t)
+
+	passenger, err := customerService.AddPassenger(customer.Id, model.Passenger{FirstName: "Alice", LastName: "Smith", DocumentType: "passport", DocumentNumber: "X1234567", BirthDate: "1990-05-01", DiscountCategory: model.DiscountYouth})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if passenger.CustomerId != customer.Id {
+		t.Fatalf("expected passenger to belong to customer %v, got %v", customer.Id, passenger.CustomerId)
+	}
+
+	invalidPassengers := []model.Passenger{
+		{FirstName: "Alice"},
+		{FirstName: "Alice", LastName: "Smith", DocumentNumber: "X1234567"},
+		{FirstName: "Alice", LastName: "Smith", BirthDate: "01/05/1990"},
+		{FirstName: "Alice", LastName: "Smith", DiscountCategory: "vip"},
+	}
+	for _, invalidPassenger := range invalidPassengers {
+		_, err := customerService.AddPassenger(customer.Id, invalidPassenger)
+		if err == nil {
+			t.Fatalf("expected error for %v, got %v", invalidPassenger, err)
+		}
+	}
+}
+
+func TestGetPassenger_1(t *testing.T) {
+	customerService, customer := newTestCustomerService(t)
+	passenger, _ := customerService.AddPassenger(customer.Id, model.Passenger{FirstName: "Alice", LastName: "Smith"})
+
+	_, err := customerService.GetPassenger(customer.Id+1, passenger.Id)
+	if err != db.ErrorPassengerNotFound {
+		t.Fatalf("expected passengers of other customers to be %v, got %v", db.ErrorPassengerNotFound, err)
+	}
+
+	_, err = customerService.UpdatePassenger(customer.Id+1, passenger.Id, model.Passenger{FirstName: "Mallory", LastName: "Smith"})
+	if err != db.ErrorPassengerNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorPassengerNotFound, err)
+	}
+
+	err = customerService.DeletePassenger(customer.Id+1, passenger.Id)
+	if err != db.ErrorPassengerNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorPassengerNotFound, err)
+	}
+
+	err = customerService.DeletePassenger(customer.Id, passenger.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type disruptionDB interface {
+	SetDisruption(model.Disruption) model.Disruption
+	GetDisruption(model.DepartureKey) (model.Disruption, error)
+	GetDisruptions() []model.Disruption
+	GetDisruptionsByTrip(int32) []model.Disruption
+	DeleteDisruption(model.DepartureKey) error
+}
+
+// PostDisruption marks the departure of a trip at departure as cancelled or
+// delayed, replacing its previous disruption if any. The disruption expires
+// once the day of the departure is over.
+func (tripService *tripService) PostDisruption(tripId int32, departure time.Time, disruption model.Disruption) (model.Disruption, error) {
+	trip, err := tripService.tripDB.GetTripById(tripId)
+	if err != nil {
+		return model.Disruption{}, err
+	}
+	if !trip.RunsOn(departure.Weekday()) {
+		return model.Disruption{}, fmt.Errorf("%w: trip %v does not run on %v", ErrorDepartureNotAvailable, trip.Id, departure.Weekday())
+	}
+
+	now := tripService.now()
+	expiresAt := endOfDay(departure)
+	if !now.Before(expiresAt) {
+		return model.Disruption{}, fmt.Errorf("%w: departure is over", ErrorDepartureNotAvailable)
+	}
+
+	disruption.Reason = strings.TrimSpace(disruption.Reason)
+	err = validateDisruption(disruption)
+	if err != nil {
+		return model.Disruption{}, err
+	}
+
+	key := model.NewDepartureKey(trip.Id, departure)
+	disruption.TripId = key.TripId
+	disruption.Date = key.Date
+	disruption.CreatedAt = now
+	disruption.ExpiresAt = expiresAt
+
+	disruption = tripService.disruptionDB.SetDisruption(disruption)
+	tripService.publisher.Publish(events.DisruptionPosted, disruption)
+
+	return disruption, nil
+}
+
+// ClearDisruption puts a disrupted departure back on schedule
+func (tripService *tripService) ClearDisruption(tripId int32, departure time.Time) error {
+	key := model.NewDepartureKey(tripId, departure)
+	err := tripService.disruptionDB.DeleteDisruption(key)
+	if err != nil {
+		return err
+	}
+
+	tripService.publisher.Publish(events.DisruptionCleared, model.Departure{TripId: tripId, Date: key.Date, Departure: departure, Status: model.DepartureScheduled})
+	return nil
+}
+
+// GetAllDisruptions returns the disruptions of every trip that have not expired
+func (tripService *tripService) GetAllDisruptions() []model.Disruption {
+	return tripService.disruptionDB.GetDisruptions()
+}
+
+func (tripService *tripService) GetDisruptions(tripId int32) []model.Disruption {
+	return tripService.disruptionDB.GetDisruptionsByTrip(tripId)
+}
+
+// GetDeparture returns the departure of a trip on the day of departure, with
+// its disruption, failing with ErrorDepartureNotAvailable if the trip does not
+// run that day
+func (tripService *tripService) GetDeparture(tripId int32, departure time.Time) (model.Departure, error) {
+	trip, err := tripService.tripDB.GetTripById(tripId)
+	if err != nil {
+		return model.Departure{}, err
+	}
+	if !trip.RunsOn(departure.Weekday()) {
+		return model.Departure{}, fmt.Errorf("%w: trip %v does not run on %v", ErrorDepartureNotAvailable, trip.Id, departure.Weekday())
+	}
+
+	return tripService.departure(trip, departure), nil
+}
+
+// GetDepartures returns the departures of a trip in the given number of days
+// starting at the departure time of from
+func (tripService *tripService) GetDepartures(tripId int32, from time.Time, days int) ([]model.Departure, error) {
+	trip, err := tripService.tripDB.GetTripById(tripId)
+	if err != nil {
+		return nil, err
+	}
+
+	departures := []model.Departure{}
+	for i := 0; i < days; i++ {
+		departure := from.AddDate(0, 0, i)
+		if trip.RunsOn(departure.Weekday()) {
+			departures = append(departures, tripService.departure(trip, departure))
+		}
+	}
+
+	return departures, nil
+}
+
+func (tripService *tripService) departure(trip model.Trip, departure time.Time) model.Departure {
+	key := model.NewDepartureKey(trip.Id, departure)
+	result := model.Departure{TripId: trip.Id, Date: key.Date, Departure: departure, Status: model.DepartureScheduled}
+
+	disruption, err := tripService.disruptionDB.GetDisruption(key)
+	if err == nil {
+		result.Status = disruption.Status
+		result.Disruption = &disruption
+	}
+
+	return result
+}
+
+func validateDisruption(disruption model.Disruption) error {
+	switch disruption.Status {
+	case model.DisruptionCancelled:
+		if disruption.DelayMinutes != 0 {
+			return fmt.Errorf("delayMinutes is only allowed for delays")
+		}
+	case model.DisruptionDelayed:
+		if disruption.DelayMinutes <= 0 {
+			return fmt.Errorf("delays need a positive delayMinutes")
+		}
+	default:
+		return fmt.Errorf("invalid status: %q, expected %v or %v", disruption.Status, model.DisruptionCancelled, model.DisruptionDelayed)
+	}
+
+	if disruption.Reason == "" {
+		return fmt.Errorf("missing reason")
+	}
+
+	return nil
+}
+
+// endOfDay returns the start of the day after t, in the time zone of t
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// mockDisruptionDB never expires disruptions, as the test departures are in the past
+type mockDisruptionDB struct {
+	disruptions map[model.DepartureKey]model.Disruption
+}
+
+func newMockDisruptionDB() *mockDisruptionDB {
+	return &mockDisruptionDB{map[model.DepartureKey]model.Disruption{}}
+}
+
+func (mockDisruptionDB *mockDisruptionDB) SetDisruption(disruption model.Disruption) model.Disruption {
+	mockDisruptionDB.disruptions[disruption.DepartureKey()] = disruption
+	return disruption
+}
+
+func (mockDisruptionDB *mockDisruptionDB) GetDisruption(key model.DepartureKey) (model.Disruption, error) {
+	disruption, ok := mockDisruptionDB.disruptions[key]
+	if !ok {
+		return model.Disruption{}, db.ErrorDisruptionNotFound
+	}
+
+	return disruption, nil
+}
+
+func (mockDisruptionDB *mockDisruptionDB) GetDisruptions() []model.Disruption {
+	result := []model.Disruption{}
+	for _, disruption := range mockDisruptionDB.disruptions {
+		result = append(result, disruption)
+	}
+
+	return result
+}
+
+func (mockDisruptionDB *mockDisruptionDB) GetDisruptionsByTrip(tripId int32) []model.Disruption {
+	result := []model.Disruption{}
+	for _, disruption := range mockDisruptionDB.disruptions {
+		if disruption.TripId == tripId {
+			result = append(result, disruption)
+		}
+	}
+
+	return result
+}
+
+func (mockDisruptionDB *mockDisruptionDB) DeleteDisruption(key model.DepartureKey) error {
+	if _, ok := mockDisruptionDB.disruptions[key]; !ok {
+		return db.ErrorDisruptionNotFound
+	}
+
+	delete(mockDisruptionDB.disruptions, key)
+	return nil
+}
+
+func newTestDisruptionService() *tripService {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, newMockDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+	tripService.now = func() time.Time { return testPromoNow }
+	return tripService
+}
+
+func TestPostDisruption_1(t *testing.T) {
+	tripService := newTestDisruptionService()
+
+	disruption, err := tripService.PostDisruption(2, testPromoDeparture, model.Disruption{Status: model.DisruptionCancelled, Reason: " Snow "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if disruption.TripId != 2 || disruption.Date != "2026-03-07" || disruption.Reason != "Snow" {
+		t.Fatalf("expected trip 2 cancelled on 2026-03-07 for snow, got %v", disruption)
+	}
+	if expected := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC); !disruption.ExpiresAt.Equal(expected) {
+		t.Fatalf("expected %v, got %v", expected, disruption.ExpiresAt)
+	}
+
+	departure, err := tripService.GetDeparture(2, testPromoDeparture)
+	if err != nil || departure.Status != model.DisruptionCancelled || departure.Disruption == nil {
+		t.Fatalf("expected cancelled departure, got %v %v", departure, err)
+	}
+
+	tripPretty, _ := tripService.GetTripPretty(testTrips[1])
+	if len(tripPretty.Disruptions) != 1 {
+		t.Fatalf("expected the disruption to be shown with the trip, got %v", tripPretty.Disruptions)
+	}
+
+	err = tripService.ClearDisruption(2, testPromoDeparture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	departure, _ = tripService.GetDeparture(2, testPromoDeparture)
+	if departure.Status != model.DepartureScheduled {
+		t.Fatalf("expected %v, got %v", model.DepartureScheduled, departure.Status)
+	}
+}
+
+func TestPostDisruption_2(t *testing.T) {
+	tests := []struct {
+		tripId     int32
+		departure  time.Time
+		disruption model.Disruption
+		expected   error
+	}{
+		{tripId: 3, departure: testPromoDeparture, disruption: model.Disruption{Status: model.DisruptionCancelled, Reason: "Strike"}, expected: db.ErrorTripNotFound},
+		{tripId: 1, departure: testPromoDeparture, disruption: model.Disruption{Status: model.DisruptionCancelled, Reason: "Strike"}, expected: ErrorDepartureNotAvailable},
+		{tripId: 2, departure: testPromoNow.AddDate(0, 0, -2), disruption: model.Disruption{Status: model.DisruptionCancelled, Reason: "Strike"}, expected: ErrorDepartureNotAvailable},
+	}
+
+	for _, test := range tests {
+		_, err := newTestDisruptionService().PostDisruption(test.tripId, test.departure, test.disruption)
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("expected %v, got %v", test.expected, err)
+		}
+	}
+
+	invalid := []model.Disruption{
+		{Status: "late", Reason: "Traffic"},
+		{Status: model.DisruptionDelayed, Reason: "Traffic"},
+		{Status: model.DisruptionCancelled, DelayMinutes: 10, Reason: "Traffic"},
+		{Status: model.DisruptionDelayed, DelayMinutes: 10, Reason: " "},
+	}
+
+	for _, disruption := range invalid {
+		_, err := newTestDisruptionService().PostDisruption(2, testPromoDeparture, disruption)
+		if err == nil {
+			t.Fatalf("expected error for %v, got %v", disruption, err)
+		}
+	}
+}
+
+func TestGetDepartures_1(t *testing.T) {
+	tripService := newTestDisruptionService()
+	tripService.PostDisruption(2, testPromoDeparture.AddDate(0, 0, 1), model.Disruption{Status: model.DisruptionDelayed, DelayMinutes: 45, Reason: "Roadworks"})
+
+	departures, err := tripService.GetDepartures(2, testPromoDeparture.AddDate(0, 0, -5), 14)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"2026-03-07 scheduled", "2026-03-08 delayed", "2026-03-14 scheduled", "2026-03-15 scheduled"}
+	if len(departures) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, departures)
+	}
+	for i, departure := range departures {
+		if departure.Date+" "+departure.Status != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], departure)
+		}
+	}
+}
+
+func TestGetDepartureBookings_1(t *testing.T) {
+	test := newTestBookingService(t)
+	test.bookingService.customerDB.AddCustomer(model.Customer{Email: "alice@example.com", Name: "Alice"})
+
+	booking, _ := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	cancelled, _ := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	test.bookingService.Cancel(context.Background(), testBookingCustomer.Id, cancelled.Id)
+
+	affected, err := test.bookingService.GetDepartureBookings(2, testPromoDeparture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(affected) != 1 || affected[0].Id != booking.Id || affected[0].CustomerEmail != "alice@example.com" {
+		t.Fatalf("expected booking %v of alice@example.com, got %v", booking.Id, affected)
+	}
+}
+
+func TestBook_4(t *testing.T) {
+	test := newTestBookingService(t)
+	test.bookingService.disruptionDB.SetDisruption(model.Disruption{TripId: 2, Date: "2026-03-07", Status: model.DisruptionCancelled, Reason: "Strike", ExpiresAt: testPromoDeparture.AddDate(0, 0, 1)})
+
+	_, err := test.bookingService.Book(context.Background(), testBookingCustomer, BookingRequest{TripId: 2, Departure: testPromoDeparture, PassengerId: test.passenger.Id})
+	if !errors.Is(err, ErrorDepartureNotAvailable) {
+		t.Fatalf("expected %v, got %v", ErrorDepartureNotAvailable, err)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+type ImportRow struct {
+	Trip model.Trip
+	// Error is set when the row could not be parsed into a trip
+	Error error
+}
+
+type ImportResult struct {
+	Trip  model.Trip
+	Error error
+	Saved bool
+}
+
+// ImportTrips validates every row with the same rules as AddTrip. By default
+// the import is all-or-nothing, and no trip is saved if any row is invalid or
+// can not be saved, deleting those saved before it. In partial mode valid rows
+// are saved and invalid ones are reported.
+func (tripService *tripService) ImportTrips(ctx context.Context, rows []ImportRow, partial bool) ([]ImportResult, bool) {
+	results := make([]ImportResult, len(rows))
+	valid := true
+
+	for i, row := range rows {
+		results[i].Trip = row.Trip
+		results[i].Error = row.Error
+
+		if results[i].Error == nil {
+			results[i].Error = tripService.validateTrip(row.Trip)
+		}
+
+		if results[i].Error != nil {
+			valid = false
+		}
+	}
+
+	if !valid && !partial {
+		return results, false
+	}
+
+	for i := range results {
+		if results[i].Error != nil {
+			continue
+		}
+
+		trip, err := tripService.tripDB.AddTrip(results[i].Trip)
+		if err != nil {
+			results[i].Error = err
+			if !partial {
+				tripService.rollbackImport(results[:i], rows)
+				return results, false
+			}
+			continue
+		}
+
+		results[i].Trip = trip
+		results[i].Saved = true
+	}
+
+	// Trips are only announced once the import is kept
+	for _, result := range results {
+		if result.Saved {
+			tripService.created(ctx, result.Trip)
+		}
+	}
+
+	return results, true
+}
+
+// rollbackImport deletes the trips saved by an all-or-nothing import that
+// failed, giving their rows back the trip they were read as
+func (tripService *tripService) rollbackImport(results []ImportResult, rows []ImportRow) {
+	for i := range results {
+		if !results[i].Saved {
+			continue
+		}
+
+		_, err := tripService.tripDB.DeleteTrip(results[i].Trip.Id, results[i].Trip.Version)
+		if err != nil {
+			log.Printf("could not delete trip %v of a failed import: %v", results[i].Trip.Id, err)
+			continue
+		}
+
+		results[i].Trip = rows[i].Trip
+		results[i].Saved = false
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Longest password bcrypt hashes, it ignores the bytes after it
+const maxPasswordLength = 72
+
+// Cost of new password hashes. Hashes keep the cost they were made with, so
+// it can be raised later without invalidating existing hashes.
+var passwordCost = bcrypt.DefaultCost
+
+var errorInvalidPasswordHash = errors.New("invalid password hash")
+
+// HashPassword returns a salted bcrypt hash of password
+func HashPassword(password string) (string, error) {
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("password can not be longer than %v bytes", maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("could not hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash made by HashPassword
+func CheckPassword(password string, passwordHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errorInvalidPasswordHash, err)
+	}
+
+	// Longer passwords were never hashed, only their first bytes would be compared
+	return len(password) <= maxPasswordLength, nil
+}
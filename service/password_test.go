@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword_1(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(hash, "correct horse") || !strings.HasPrefix(hash, "$2a$") {
+		t.Fatalf("expected a bcrypt hash, got %v", hash)
+	}
+
+	otherHash, _ := HashPassword("correct horse")
+	if hash == otherHash {
+		t.Fatalf("expected hashes of the same password to be salted differently")
+	}
+
+	ok, err := CheckPassword("correct horse", hash)
+	if err != nil || !ok {
+		t.Fatalf("expected password to match, got %v %v", ok, err)
+	}
+
+	ok, err = CheckPassword("correct horse battery", hash)
+	if err != nil || ok {
+		t.Fatalf("expected password not to match, got %v %v", ok, err)
+	}
+}
+
+func TestHashPassword_2(t *testing.T) {
+	password := strings.Repeat("a", maxPasswordLength)
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := HashPassword(password + "a"); err == nil {
+		t.Fatalf("expected passwords longer than %v bytes to be refused", maxPasswordLength)
+	}
+	if ok, err := CheckPassword(password+"a", hash); err != nil || ok {
+		t.Fatalf("expected a longer password not to match, got %v %v", ok, err)
+	}
+}
+
+func TestCheckPassword_1(t *testing.T) {
+	for _, hash := range []string{"", "plaintext", "pbkdf2-sha256$1$c2FsdA$aGFzaA", "$2a$10$short"} {
+		_, err := CheckPassword("password", hash)
+		if !errors.Is(err, errorInvalidPasswordHash) {
+			t.Fatalf("expected error: %v for %v, got error: %v", errorInvalidPasswordHash, hash, err)
+		}
+	}
+}
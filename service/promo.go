@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// ErrorPromoCodeNotApplicable is wrapped by the errors explaining why a code can not be used for a departure
+var ErrorPromoCodeNotApplicable = errors.New("promo code not applicable")
+
+var promoCodeRegexp = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type promoDB interface {
+	GetAllPromoCodes() []model.PromoCode
+	GetPromoCode(string) (model.PromoCode, error)
+	AddPromoCode(model.PromoCode) (model.PromoCode, error)
+	UpdatePromoCode(model.PromoCode) (model.PromoCode, error)
+	DeletePromoCode(string) error
+	GetPromoCodeUses(string, string) int
+	RedeemPromoCode(string, string, func(model.PromoCode) error) (model.PromoCode, error)
+	ReleasePromoCode(string, string) error
+}
+
+type promoService struct {
+	promoDB
+	now func() time.Time
+}
+
+func NewPromoService(promoDB promoDB) *promoService {
+	return &promoService{promoDB, time.Now}
+}
+
+func (promoService *promoService) AddPromoCode(promoCode model.PromoCode) (model.PromoCode, error) {
+	promoCode.Code = strings.ToUpper(strings.TrimSpace(promoCode.Code))
+
+	err := validatePromoCode(promoCode)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+
+	return promoService.promoDB.AddPromoCode(promoCode)
+}
+
+func (promoService *promoService) UpdatePromoCode(code string, promoCode model.PromoCode) (model.PromoCode, error) {
+	promoCode.Code = strings.ToUpper(code)
+
+	err := validatePromoCode(promoCode)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+
+	return promoService.promoDB.UpdatePromoCode(promoCode)
+}
+
+// CheckPromoCode returns the code if it can be applied to a departure of trip
+// by customer, without redeeming it. An empty customer skips the per customer cap.
+func (promoService *promoService) CheckPromoCode(code string, customer string, trip model.Trip, departure time.Time) (model.PromoCode, error) {
+	promoCode, err := promoService.promoDB.GetPromoCode(code)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+
+	err = promoService.checkApplicable(promoCode, trip, departure)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+
+	if promoCode.MaxUses > 0 && promoCode.Uses >= promoCode.MaxUses {
+		return model.PromoCode{}, db.ErrorPromoCodeExhausted
+	}
+	if customer != "" && promoCode.MaxUsesPerCustomer > 0 && promoService.promoDB.GetPromoCodeUses(code, customer) >= promoCode.MaxUsesPerCustomer {
+		return model.PromoCode{}, db.ErrorPromoCodeCustomerLimit
+	}
+
+	return promoCode, nil
+}
+
+// RedeemPromoCode counts a use of the code by customer for a departure of trip,
+// failing if it is not applicable or its usage caps have been reached
+func (promoService *promoService) RedeemPromoCode(code string, customer string, trip model.Trip, departure time.Time) (model.PromoCode, error) {
+	if customer == "" {
+		return model.PromoCode{}, fmt.Errorf("customer can not be empty")
+	}
+
+	return promoService.promoDB.RedeemPromoCode(code, customer, func(promoCode model.PromoCode) error {
+		return promoService.checkApplicable(promoCode, trip, departure)
+	})
+}
+
+func (promoService *promoService) checkApplicable(promoCode model.PromoCode, trip model.Trip, departure time.Time) error {
+	if !promoCode.IsValidAt(promoService.now()) {
+		return fmt.Errorf("%w: %v is not valid at this time", ErrorPromoCodeNotApplicable, promoCode.Code)
+	}
+	if !promoCode.AppliesTo(trip, departure) {
+		return fmt.Errorf("%w: %v does not apply to trip %v on %v", ErrorPromoCodeNotApplicable, promoCode.Code, trip.Id, departure.Weekday())
+	}
+
+	return nil
+}
+
+func validatePromoCode(promoCode model.PromoCode) error {
+	if !promoCodeRegexp.MatchString(promoCode.Code) {
+		return fmt.Errorf("invalid code, expected 3 to 32 letters, digits, - or _: %v", promoCode.Code)
+	}
+
+	if promoCode.Percent < 0 || promoCode.Percent > 100 || promoCode.Amount < 0 {
+		return fmt.Errorf("invalid discount, percent must be between 0 and 100 and amount can not be negative")
+	}
+	if (promoCode.Percent == 0) == (promoCode.Amount == 0) {
+		return fmt.Errorf("invalid discount, expected either percent or amount")
+	}
+
+	if promoCode.ValidFrom != nil && promoCode.ValidUntil != nil && promoCode.ValidUntil.Before(*promoCode.ValidFrom) {
+		return fmt.Errorf("invalid validity window, validUntil is before validFrom")
+	}
+
+	if promoCode.MaxUses < 0 || promoCode.MaxUsesPerCustomer < 0 {
+		return fmt.Errorf("invalid usage caps, they can not be negative")
+	}
+
+	if promoCode.Weekdays != "" {
+		if !datesRegexp.MatchString(promoCode.Weekdays) {
+			return fmt.Errorf("invalid weekdays format: %v", promoCode.Weekdays)
+		}
+		if _, err := model.ParseDates(promoCode.Weekdays); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range promoCode.Routes {
+		if route.OriginId == route.DestinationId {
+			return fmt.Errorf("invalid route, origin and destination are the same: %v", route.OriginId)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+var testPromoNow = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+// Saturday
+var testPromoDeparture = time.Date(2026, 3, 7, 8, 0, 0, 0, time.UTC)
+
+var testPromoTrip = model.Trip{Id: 2, OriginId: 2, DestinationId: 1, Dates: "Sat Sun", Price: 40.55}
+
+func newTestPromoService(promoCodes ...model.PromoCode) *promoService {
+	promoService := NewPromoService(db.NewPromoDB())
+	promoService.now = func() time.Time { return testPromoNow }
+
+	for _, promoCode := range promoCodes {
+		promoService.AddPromoCode(promoCode)
+	}
+
+	return promoService
+}
+
+func TestAddPromoCode_1(t *testing.T) {
+	promoService := newTestPromoService()
+
+	promoCode, err := promoService.AddPromoCode(model.PromoCode{Code: " summer10 ", Percent: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if promoCode.Code != "SUMMER10" {
+		t.Fatalf("expected %v, got %v", "SUMMER10", promoCode.Code)
+	}
+}
+
+func TestAddPromoCode_2(t *testing.T) {
+	promoService := newTestPromoService()
+	from := testPromoNow
+	until := testPromoNow.Add(-time.Hour)
+
+	invalidPromoCodes := []model.PromoCode{
+		{Code: "X", Percent: 10},
+		{Code: "SUMMER 10", Percent: 10},
+		{Code: "SUMMER10"},
+		{Code: "SUMMER10", Percent: 10, Amount: 5},
+		{Code: "SUMMER10", Percent: 110},
+		{Code: "SUMMER10", Amount: -5},
+		{Code: "SUMMER10", Percent: 10, ValidFrom: &from, ValidUntil: &until},
+		{Code: "SUMMER10", Percent: 10, MaxUses: -1},
+		{Code: "SUMMER10", Percent: 10, Weekdays: "Caturday"},
+		{Code: "SUMMER10", Percent: 10, Routes: []model.PromoRoute{{OriginId: 1, DestinationId: 1}}},
+	}
+
+	for _, promoCode := range invalidPromoCodes {
+		_, err := promoService.AddPromoCode(promoCode)
+		if err == nil {
+			t.Fatalf("expected error for %v, got %v", promoCode, err)
+		}
+	}
+}
+
+func TestCheckPromoCode_1(t *testing.T) {
+	promoService := newTestPromoService(model.PromoCode{Code: "WEEKEND", Amount: 5, Weekdays: "Sat Sun"})
+
+	promoCode, err := promoService.CheckPromoCode("weekend", "alice", testPromoTrip, testPromoDeparture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if promoCode.Uses != 0 {
+		t.Fatalf("expected check not to redeem the code, got %v uses", promoCode.Uses)
+	}
+
+	_, err = promoService.CheckPromoCode("WEEKEND", "alice", testPromoTrip, testPromoDeparture.AddDate(0, 0, 2))
+	if !errors.Is(err, ErrorPromoCodeNotApplicable) {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotApplicable, err)
+	}
+}
+
+func TestCheckPromoCode_2(t *testing.T) {
+	expired := testPromoNow.Add(-time.Hour)
+	promoService := newTestPromoService(model.PromoCode{Code: "WINTER", Percent: 10, ValidUntil: &expired})
+
+	_, err := promoService.CheckPromoCode("WINTER", "", testPromoTrip, testPromoDeparture)
+	if !errors.Is(err, ErrorPromoCodeNotApplicable) {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotApplicable, err)
+	}
+}
+
+func TestCheckPromoCode_3(t *testing.T) {
+	promoService := newTestPromoService(model.PromoCode{Code: "ONCE", Percent: 10, MaxUsesPerCustomer: 1})
+
+	_, err := promoService.RedeemPromoCode("ONCE", "alice", testPromoTrip, testPromoDeparture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = promoService.CheckPromoCode("ONCE", "alice", testPromoTrip, testPromoDeparture)
+	if err != db.ErrorPromoCodeCustomerLimit {
+		t.Fatalf("expected %v, got %v", db.ErrorPromoCodeCustomerLimit, err)
+	}
+
+	_, err = promoService.CheckPromoCode("ONCE", "bob", testPromoTrip, testPromoDeparture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRedeemPromoCode_1(t *testing.T) {
+	promoService := newTestPromoService(model.PromoCode{Code: "SEVILLA", Percent: 10, Routes: []model.PromoRoute{{OriginId: 1, DestinationId: 2}}})
+
+	_, err := promoService.RedeemPromoCode("SEVILLA", "alice", testPromoTrip, testPromoDeparture)
+	if !errors.Is(err, ErrorPromoCodeNotApplicable) {
+		t.Fatalf("expected %v, got %v", ErrorPromoCodeNotApplicable, err)
+	}
+
+	_, err = promoService.RedeemPromoCode("SEVILLA", "", testPromoTrip, testPromoDeparture)
+	if err == nil {
+		t.Fatalf("expected error for empty customer, got %v", err)
+	}
+}
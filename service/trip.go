@@ -1,26 +1,72 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/gbandres98/pack-and-go/audit"
+	"github.com/gbandres98/pack-and-go/events"
 	"github.com/gbandres98/pack-and-go/model"
 )
 
 type cityDB interface {
+	GetAllCities() ([]model.City, error)
 	GetCityById(int32) (model.City, error)
 }
 
 type tripDB interface {
-	GetAllTrips() []model.Trip
+	GetAllTrips() ([]model.Trip, error)
 	GetTripById(int32) (model.Trip, error)
-	AddTrip(model.Trip) model.Trip
+	AddTrip(model.Trip) (model.Trip, error)
+	UpdateTrip(model.Trip, int32) (model.Trip, error)
+	DeleteTrip(int32, int32) (model.Trip, error)
+}
+
+// tripHistoryDB is implemented by trip databases that keep every change, so
+// trips can be seen as they were at any time
+type tripHistoryDB interface {
+	GetAllTripsAsOf(time.Time) ([]model.Trip, error)
+	GetTripByIdAsOf(int32, time.Time) (model.Trip, error)
+}
+
+// tripIndexDB is implemented by trip databases with indexes of the trips by
+// origin and destination, so finding the trips of a city does not read them all
+type tripIndexDB interface {
+	GetTripsByOrigin(int32) ([]model.Trip, error)
+	GetTripsByDestination(int32) ([]model.Trip, error)
+}
+
+// tripQueryDB is implemented by trip databases that filter and page trips themselves
+type tripQueryDB interface {
+	QueryTrips(model.TripQuery) ([]model.Trip, error)
+}
+
+var ErrorHistoryNotKept = errors.New("trip history is not kept, start the server with a trip log")
+
+// eventPublisher sends domain events to whoever listens to them, such as open event streams
+type eventPublisher interface {
+	Publish(string, interface{})
+}
+
+// auditor records who changed an entity and how. before is nil for
+// creations and after is nil for deletions.
+type auditor interface {
+	Record(ctx context.Context, entity string, entityId string, action string, before interface{}, after interface{})
 }
 
 type tripService struct {
 	cityDB
 	tripDB
+	disruptionDB disruptionDB
+	publisher    eventPublisher
+	auditor      auditor
+	now          func() time.Time
 }
 
 var datesRegexp *regexp.Regexp
@@ -33,11 +79,11 @@ func init() {
 	}
 }
 
-func NewTripService(cityDB cityDB, tripDB tripDB) *tripService {
-	return &tripService{cityDB, tripDB}
+func NewTripService(cityDB cityDB, tripDB tripDB, disruptionDB disruptionDB, publisher eventPublisher, auditor auditor) *tripService {
+	return &tripService{cityDB, tripDB, disruptionDB, publisher, auditor, time.Now}
 }
 
-func (tripService *tripService) GetAllTrips() []model.Trip {
+func (tripService *tripService) GetAllTrips() ([]model.Trip, error) {
 	return tripService.tripDB.GetAllTrips()
 }
 
@@ -45,22 +91,141 @@ func (tripService *tripService) GetTripById(id int32) (model.Trip, error) {
 	return tripService.tripDB.GetTripById(id)
 }
 
-func (tripService *tripService) AddTrip(trip model.Trip) (model.Trip, error) {
-	if !datesRegexp.MatchString(trip.Dates) {
-		return model.Trip{}, fmt.Errorf("invalid dates format: %v", trip.Dates)
+// FindTrips returns a page of the trips selected by the query, sorted by id
+func (tripService *tripService) FindTrips(query model.TripQuery) ([]model.Trip, error) {
+	if queryDB, ok := tripService.tripDB.(tripQueryDB); ok {
+		return queryDB.QueryTrips(query)
+	}
+
+	var trips []model.Trip
+	var err error
+
+	indexDB, ok := tripService.tripDB.(tripIndexDB)
+	switch {
+	case ok && query.OriginId != 0:
+		trips, err = indexDB.GetTripsByOrigin(query.OriginId)
+	case ok && query.DestinationId != 0:
+		trips, err = indexDB.GetTripsByDestination(query.DestinationId)
+	default:
+		trips, err = tripService.tripDB.GetAllTrips()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Apply(trips), nil
+}
+
+// GetAllTripsAsOf returns the trips as they were at the given time
+func (tripService *tripService) GetAllTripsAsOf(asOf time.Time) ([]model.Trip, error) {
+	historyDB, ok := tripService.tripDB.(tripHistoryDB)
+	if !ok {
+		return nil, ErrorHistoryNotKept
+	}
+
+	return historyDB.GetAllTripsAsOf(asOf)
+}
+
+// GetTripByIdAsOf returns a trip as it was at the given time
+func (tripService *tripService) GetTripByIdAsOf(id int32, asOf time.Time) (model.Trip, error) {
+	historyDB, ok := tripService.tripDB.(tripHistoryDB)
+	if !ok {
+		return model.Trip{}, ErrorHistoryNotKept
+	}
+
+	return historyDB.GetTripByIdAsOf(id, asOf)
+}
+
+func (tripService *tripService) AddTrip(ctx context.Context, trip model.Trip) (model.Trip, error) {
+	err := tripService.validateTrip(trip)
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	trip, err = tripService.tripDB.AddTrip(trip)
+	if err != nil {
+		return model.Trip{}, err
+	}
+	tripService.created(ctx, trip)
+
+	return trip, nil
+}
+
+func (tripService *tripService) created(ctx context.Context, trip model.Trip) {
+	tripService.auditor.Record(ctx, audit.EntityTrip, tripEntityId(trip), audit.ActionCreate, nil, trip)
+	tripService.publisher.Publish(events.TripCreated, trip)
+}
+
+// UpdateTrip replaces the trip with the given id, failing with
+// db.ErrorVersionMismatch if it has been modified since expectedVersion
+func (tripService *tripService) UpdateTrip(ctx context.Context, id int32, trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	err := tripService.validateTrip(trip)
+	if err != nil {
+		return model.Trip{}, err
 	}
 
-	_, err := tripService.cityDB.GetCityById(trip.OriginId)
+	// If the trip changes after it is read, the update fails with a version mismatch
+	before, err := tripService.tripDB.GetTripById(id)
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	trip.Id = id
+	trip, err = tripService.tripDB.UpdateTrip(trip, expectedVersion)
+	if err != nil {
+		return model.Trip{}, err
+	}
+
+	tripService.auditor.Record(ctx, audit.EntityTrip, tripEntityId(trip), audit.ActionUpdate, before, trip)
+	tripService.publisher.Publish(events.TripUpdated, trip)
+	return trip, nil
+}
+
+// DeleteTrip removes the trip with the given id, failing with
+// db.ErrorVersionMismatch if it has been modified since expectedVersion.
+// Its bookings are kept.
+func (tripService *tripService) DeleteTrip(ctx context.Context, id int32, expectedVersion int32) error {
+	trip, err := tripService.tripDB.DeleteTrip(id, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	tripService.auditor.Record(ctx, audit.EntityTrip, tripEntityId(trip), audit.ActionDelete, trip, nil)
+	tripService.publisher.Publish(events.TripDeleted, trip)
+	return nil
+}
+
+func tripEntityId(trip model.Trip) string {
+	return strconv.Itoa(int(trip.Id))
+}
+
+// ValidateDates checks that dates are weekdays separated by single spaces, such as "Mon Tue Fri"
+func ValidateDates(dates string) error {
+	if !datesRegexp.MatchString(dates) {
+		return fmt.Errorf("invalid dates format: %v", dates)
+	}
+
+	_, err := model.ParseDates(dates)
+	return err
+}
+
+func (tripService *tripService) validateTrip(trip model.Trip) error {
+	err := ValidateDates(trip.Dates)
+	if err != nil {
+		return err
+	}
+
+	_, err = tripService.cityDB.GetCityById(trip.OriginId)
 	if (err != nil) {
-		return model.Trip{}, fmt.Errorf("could not find origin city with id: %v", trip.OriginId)
+		return fmt.Errorf("could not find origin city with id: %v", trip.OriginId)
 	}
 
 	_, err = tripService.cityDB.GetCityById(trip.DestinationId)
 	if (err != nil) {
-		return model.Trip{}, fmt.Errorf("could not find destination city with id: %v", trip.DestinationId)
+		return fmt.Errorf("could not find destination city with id: %v", trip.DestinationId)
 	}
 
-	return tripService.tripDB.AddTrip(trip), nil
+	return nil
 }
 
 func (tripService *tripService) GetTripPretty(trip model.Trip) (model.TripPretty, error) {
@@ -82,5 +247,14 @@ func (tripService *tripService) GetTripPretty(trip model.Trip) (model.TripPretty
 		Price: trip.Price,
 	}
 
+	if originCity.HasCoordinates() && destinationCity.HasCoordinates() {
+		distance := math.Round(originCity.DistanceTo(destinationCity)*10) / 10
+		tripPretty.DistanceKm = &distance
+	}
+
+	if disruptions := tripService.GetDisruptions(trip.Id); len(disruptions) > 0 {
+		tripPretty.Disruptions = disruptions
+	}
+
 	return tripPretty, nil
 }
\ No newline at end of file
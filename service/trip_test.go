@@ -1,10 +1,16 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/gbandres98/pack-and-go/audit"
 	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
 	"github.com/gbandres98/pack-and-go/model"
 )
 
@@ -13,14 +19,93 @@ var testCities = []model.City{
 	{Id: 2, Name: "Madrid"},
 }
 
+var testGeoCities = []model.City{
+	{Id: 1, Name: "Sevilla", Latitude: 37.3891, Longitude: -5.9845},
+	{Id: 2, Name: "Madrid", Latitude: 40.4168, Longitude: -3.7038},
+	{Id: 3, Name: "Barcelona", Latitude: 41.3874, Longitude: 2.1686},
+	{Id: 4, Name: "Nowhere"},
+}
+
+type mockGeoCityDB struct{}
+
+func (mockGeoCityDB *mockGeoCityDB) GetAllCities() ([]model.City, error) {
+	return testGeoCities, nil
+}
+
+func (mockGeoCityDB *mockGeoCityDB) GetCityById(id int32) (model.City, error) {
+	if id > 0 && id < 5 {
+		return testGeoCities[id-1], nil
+	}
+
+	return model.City{}, db.ErrorCityNotFound
+}
+
+func (mockGeoCityDB *mockGeoCityDB) AddCity(city model.City) (model.City, error) {
+	city.Id = 5
+	return city, nil
+}
+
+func (mockGeoCityDB *mockGeoCityDB) UpdateCity(city model.City) (model.City, error) {
+	return city, nil
+}
+
 var testTrips = []model.Trip{
 	{Id: 1, OriginId: 1, DestinationId: 2, Dates: "Mon Tue Wed Fri", Price: 40.55},
 	{Id: 2, OriginId: 2, DestinationId: 1, Dates: "Sat Sun", Price: 40.55},
 }
 
-type mockCityDB struct{}
+type mockCityDB struct{
+	addedCities []model.City
+	updatedCities []model.City
+}
+
+type mockTripDB struct{
+	failAddTrip bool
+}
+
+type publishedEvent struct {
+	eventType string
+	data      interface{}
+}
+
+type mockPublisher struct {
+	published []publishedEvent
+}
 
-type mockTripDB struct{}
+func (mockPublisher *mockPublisher) Publish(eventType string, data interface{}) {
+	mockPublisher.published = append(mockPublisher.published, publishedEvent{eventType, data})
+}
+
+type auditedChange struct {
+	entity   string
+	entityId string
+	action   string
+	before   interface{}
+	after    interface{}
+}
+
+type mockAuditor struct {
+	recorded []auditedChange
+}
+
+func (mockAuditor *mockAuditor) Record(ctx context.Context, entity string, entityId string, action string, before interface{}, after interface{}) {
+	mockAuditor.recorded = append(mockAuditor.recorded, auditedChange{entity, entityId, action, before, after})
+}
+
+func (mockCityDB *mockCityDB) GetAllCities() ([]model.City, error) {
+	return append(append([]model.City{}, testCities...), mockCityDB.addedCities...), nil
+}
+
+func (mockCityDB *mockCityDB) AddCity(city model.City) (model.City, error) {
+	city.Id = int32(len(testCities) + len(mockCityDB.addedCities) + 1)
+	mockCityDB.addedCities = append(mockCityDB.addedCities, city)
+	return city, nil
+}
+
+func (mockCityDB *mockCityDB) UpdateCity(city model.City) (model.City, error) {
+	mockCityDB.updatedCities = append(mockCityDB.updatedCities, city)
+	return city, nil
+}
 
 func (mockCityDB *mockCityDB) GetCityById(id int32) (model.City, error) {
 	if (id < 3) {
@@ -30,8 +115,8 @@ func (mockCityDB *mockCityDB) GetCityById(id int32) (model.City, error) {
 	return model.City{}, db.ErrorCityNotFound
 }
 
-func (mockTripDB *mockTripDB) GetAllTrips() []model.Trip {
-	return testTrips
+func (mockTripDB *mockTripDB) GetAllTrips() ([]model.Trip, error) {
+	return testTrips, nil
 }
 
 func (mockTripDB *mockTripDB) GetTripById(id int32) (model.Trip, error) {
@@ -42,22 +127,49 @@ func (mockTripDB *mockTripDB) GetTripById(id int32) (model.Trip, error) {
 	return model.Trip{}, db.ErrorTripNotFound
 }
 
-func (mockTripDB *mockTripDB) AddTrip(trip model.Trip) model.Trip {
+func (mockTripDB *mockTripDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	if mockTripDB.failAddTrip {
+		return model.Trip{}, errors.New("test error")
+	}
+
 	trip.Id = 3
-	return trip
+	return trip, nil
+}
+
+func (mockTripDB *mockTripDB) DeleteTrip(id int32, expectedVersion int32) (model.Trip, error) {
+	if id > 2 {
+		return model.Trip{}, db.ErrorTripNotFound
+	}
+	if expectedVersion != 0 && expectedVersion != 1 {
+		return model.Trip{}, db.ErrorVersionMismatch
+	}
+
+	return testTrips[id-1], nil
+}
+
+func (mockTripDB *mockTripDB) UpdateTrip(trip model.Trip, expectedVersion int32) (model.Trip, error) {
+	if (trip.Id > 2) {
+		return model.Trip{}, db.ErrorTripNotFound
+	}
+	if expectedVersion != 0 && expectedVersion != 1 {
+		return model.Trip{}, db.ErrorVersionMismatch
+	}
+
+	trip.Version = 2
+	return trip, nil
 }
 
 func TestGetAllTrips_1(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
-	trips := tripService.GetAllTrips()
+	trips, _ := tripService.GetAllTrips()
 	if !reflect.DeepEqual(trips, testTrips) {
 		t.Fatalf("expected %v, got %v", testTrips, trips)
 	}
 }
 
 func TestGetTripById_1(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	trip, err := tripService.GetTripById(1)
 	if err != nil {
@@ -69,7 +181,7 @@ func TestGetTripById_1(t *testing.T) {
 }
 
 func TestGetTripById_2(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	_, err := tripService.GetTripById(3)
 	if err != db.ErrorTripNotFound {
@@ -78,11 +190,11 @@ func TestGetTripById_2(t *testing.T) {
 }
 
 func TestAddTrip_1(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	newTrip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
 
-	savedTrip, err := tripService.AddTrip(newTrip)
+	savedTrip, err := tripService.AddTrip(context.Background(), newTrip)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -92,51 +204,122 @@ func TestAddTrip_1(t *testing.T) {
 }
 
 func TestAddTrip_2(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	newTrip := model.Trip{OriginId: 3, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
 
-	_, err := tripService.AddTrip(newTrip)
+	_, err := tripService.AddTrip(context.Background(), newTrip)
 	if err == nil {
 		t.Fatalf("expected error, got %v", err)
 	}
 }
 
 func TestAddTrip_3(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	newTrip := model.Trip{OriginId: 1, DestinationId: 3, Dates: "Mon Tue", Price: 40.21}
 
-	_, err := tripService.AddTrip(newTrip)
+	_, err := tripService.AddTrip(context.Background(), newTrip)
 	if err == nil {
 		t.Fatalf("expected error, got %v", err)
 	}
 }
 
 func TestAddTrip_4(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	newTrip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "MonTue", Price: 40.21}
 
-	_, err := tripService.AddTrip(newTrip)
+	_, err := tripService.AddTrip(context.Background(), newTrip)
 	if err == nil {
 		t.Fatalf("expected error, got %v", err)
 	}
 }
 
 func TestAddTrip_5(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	newTrip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "mon Tue", Price: 40.21}
 
-	_, err := tripService.AddTrip(newTrip)
+	_, err := tripService.AddTrip(context.Background(), newTrip)
 	if err == nil {
 		t.Fatalf("expected error, got %v", err)
 	}
 }
 
+func TestAddTrip_6(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	newTrip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Xyz", Price: 40.21}
+
+	_, err := tripService.AddTrip(context.Background(), newTrip)
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestAddTrip_7(t *testing.T) {
+	publisher := &mockPublisher{}
+	auditor := &mockAuditor{}
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{failAddTrip: true}, db.NewDisruptionDB(), publisher, auditor)
+
+	newTrip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
+
+	_, err := tripService.AddTrip(context.Background(), newTrip)
+	if err == nil || len(publisher.published) != 0 || len(auditor.recorded) != 0 {
+		t.Fatalf("expected the store error without events or audit records, got %v %v %v", err, publisher.published, auditor.recorded)
+	}
+}
+
+func TestUpdateTrip_1(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{OriginId: 2, DestinationId: 1, Dates: "Sat", Price: 12.5}
+
+	updatedTrip, err := tripService.UpdateTrip(context.Background(), 1, trip, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updatedTrip.Id != 1 || updatedTrip.Version != 2 {
+		t.Fatalf("expected updated trip to have id %v and version %v, got %v", 1, 2, updatedTrip)
+	}
+}
+
+func TestUpdateTrip_2(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{OriginId: 2, DestinationId: 1, Dates: "Sat", Price: 12.5}
+
+	_, err := tripService.UpdateTrip(context.Background(), 1, trip, 5)
+	if err != db.ErrorVersionMismatch {
+		t.Fatalf("expected error: %v, got error: %v", db.ErrorVersionMismatch, err)
+	}
+}
+
+func TestUpdateTrip_3(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{OriginId: 2, DestinationId: 3, Dates: "Sat", Price: 12.5}
+
+	_, err := tripService.UpdateTrip(context.Background(), 1, trip, 1)
+	if err == nil {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestUpdateTrip_4(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{OriginId: 2, DestinationId: 1, Dates: "Sat", Price: 12.5}
+
+	_, err := tripService.UpdateTrip(context.Background(), 3, trip, 1)
+	if err != db.ErrorTripNotFound {
+		t.Fatalf("expected error: %v, got error: %v", db.ErrorTripNotFound, err)
+	}
+}
+
 func TestGetTripPretty_1(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	trip := model.Trip{Id: 3, OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
 	expected := model.TripPretty{Id: 3, Origin: "Sevilla", Destination: "Madrid", Dates: "Mon Tue", Price: 40.21}
@@ -151,7 +334,7 @@ func TestGetTripPretty_1(t *testing.T) {
 }
 
 func TestGetTripPretty_2(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	trip := model.Trip{Id: 3, OriginId: 3, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
 
@@ -162,7 +345,7 @@ func TestGetTripPretty_2(t *testing.T) {
 }
 
 func TestGetTripPretty_3(t *testing.T) {
-	tripService := NewTripService(&mockCityDB{}, &mockTripDB{})
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
 
 	trip := model.Trip{Id: 3, OriginId: 2, DestinationId: 3, Dates: "Mon Tue", Price: 40.21}
 
@@ -170,4 +353,276 @@ func TestGetTripPretty_3(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected error, got %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestImportTrips_1(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	rows := []ImportRow{
+		{Trip: model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}},
+		{Trip: model.Trip{OriginId: 2, DestinationId: 1, Dates: "Sat", Price: 12}},
+	}
+
+	results, committed := tripService.ImportTrips(context.Background(), rows, false)
+	if !committed {
+		t.Fatalf("expected import to be committed, got %v", results)
+	}
+	for _, result := range results {
+		if !result.Saved || result.Error != nil || result.Trip.Id != 3 {
+			t.Fatalf("expected every row to be saved, got %v", results)
+		}
+	}
+}
+
+func TestImportTrips_2(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	rows := []ImportRow{
+		{Trip: model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}},
+		{Trip: model.Trip{OriginId: 2, DestinationId: 3, Dates: "Sat", Price: 12}},
+	}
+
+	results, committed := tripService.ImportTrips(context.Background(), rows, false)
+	if committed {
+		t.Fatalf("expected import not to be committed, got %v", results)
+	}
+	if results[0].Saved || results[0].Error != nil {
+		t.Fatalf("expected valid row not to be saved, got %v", results[0])
+	}
+	if results[1].Saved || results[1].Error == nil {
+		t.Fatalf("expected invalid row to be reported, got %v", results[1])
+	}
+}
+
+func TestImportTrips_3(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	rows := []ImportRow{
+		{Trip: model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}},
+		{Trip: model.Trip{OriginId: 2, DestinationId: 1, Dates: "sat", Price: 12}},
+		{Error: errors.New("test error")},
+	}
+
+	results, committed := tripService.ImportTrips(context.Background(), rows, true)
+	if !committed {
+		t.Fatalf("expected partial import to be committed, got %v", results)
+	}
+	if !results[0].Saved {
+		t.Fatalf("expected valid row to be saved, got %v", results[0])
+	}
+	if results[1].Saved || results[1].Error == nil || results[2].Saved || results[2].Error == nil {
+		t.Fatalf("expected invalid rows to be reported, got %v", results)
+	}
+}
+
+// failingTripDB fails to save trips once it has saved failAfter of them
+type failingTripDB struct {
+	db.TripStore
+	failAfter int
+	added     int
+}
+
+func (failingTripDB *failingTripDB) AddTrip(trip model.Trip) (model.Trip, error) {
+	if failingTripDB.added == failingTripDB.failAfter {
+		return model.Trip{}, errors.New("test error")
+	}
+
+	failingTripDB.added++
+	return failingTripDB.TripStore.AddTrip(trip)
+}
+
+func TestImportTrips_4(t *testing.T) {
+	tripDB := &failingTripDB{TripStore: db.NewMemoryDB(), failAfter: 2}
+	publisher := &mockPublisher{}
+	tripService := NewTripService(&mockCityDB{}, tripDB, db.NewDisruptionDB(), publisher, &mockAuditor{})
+	before, _ := tripDB.GetAllTrips()
+
+	rows := []ImportRow{
+		{Trip: model.Trip{OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}},
+		{Trip: model.Trip{OriginId: 2, DestinationId: 1, Dates: "Sat", Price: 12}},
+		{Trip: model.Trip{OriginId: 1, DestinationId: 2, Dates: "Sun", Price: 15}},
+	}
+
+	results, committed := tripService.ImportTrips(context.Background(), rows, false)
+	if committed {
+		t.Fatalf("expected import not to be committed, got %v", results)
+	}
+	if results[0].Saved || results[1].Saved || results[0].Trip.Id != 0 || results[2].Error == nil {
+		t.Fatalf("expected the store error to be reported and no row to be saved, got %v", results)
+	}
+
+	after, _ := tripDB.GetAllTrips()
+	if len(after) != len(before) {
+		t.Fatalf("expected the trips saved before the failure to be deleted, got %v trips instead of %v", len(after), len(before))
+	}
+	if len(publisher.published) != 0 {
+		t.Fatalf("expected no trip of a failed import to be published, got %v", publisher.published)
+	}
+}
+
+
+func TestGetTripPretty_4(t *testing.T) {
+	tripService := NewTripService(&mockGeoCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{Id: 3, OriginId: 1, DestinationId: 2, Dates: "Mon Tue", Price: 40.21}
+
+	tripPretty, err := tripService.GetTripPretty(trip)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tripPretty.DistanceKm == nil || *tripPretty.DistanceKm < 380 || *tripPretty.DistanceKm > 400 {
+		t.Fatalf("expected distance between Sevilla and Madrid to be about 390 km, got %v", tripPretty.DistanceKm)
+	}
+}
+
+func TestGetTripPretty_5(t *testing.T) {
+	tripService := NewTripService(&mockGeoCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+	trip := model.Trip{Id: 3, OriginId: 1, DestinationId: 4, Dates: "Mon Tue", Price: 40.21}
+
+	tripPretty, err := tripService.GetTripPretty(trip)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tripPretty.DistanceKm != nil {
+		t.Fatalf("expected no distance for cities without coordinates, got %v", *tripPretty.DistanceKm)
+	}
+}
+func TestDeleteTrip_1(t *testing.T) {
+	publisher := &mockPublisher{}
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, db.NewDisruptionDB(), publisher, &mockAuditor{})
+
+	err := tripService.DeleteTrip(context.Background(), 1, 3)
+	if err != db.ErrorVersionMismatch {
+		t.Fatalf("expected error: %v, got error: %v", db.ErrorVersionMismatch, err)
+	}
+
+	err = tripService.DeleteTrip(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(publisher.published) != 1 || publisher.published[0].eventType != events.TripDeleted {
+		t.Fatalf("expected a single %v event, got %v", events.TripDeleted, publisher.published)
+	}
+	if trip := publisher.published[0].data.(model.Trip); trip.Id != 1 {
+		t.Fatalf("expected the deleted trip in the event, got %v", trip)
+	}
+}
+
+func TestTripEvents_1(t *testing.T) {
+	publisher := &mockPublisher{}
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, newMockDisruptionDB(), publisher, &mockAuditor{})
+	tripService.now = func() time.Time { return testPromoNow }
+
+	trip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "Sat Sun", Price: 12.5}
+	tripService.AddTrip(context.Background(), trip)
+	tripService.AddTrip(context.Background(), model.Trip{OriginId: 9, DestinationId: 2, Dates: "Sat", Price: 1})
+	tripService.UpdateTrip(context.Background(), 2, trip, 1)
+	tripService.UpdateTrip(context.Background(), 2, trip, 7)
+	tripService.ImportTrips(context.Background(), []ImportRow{{Trip: trip}, {Trip: model.Trip{Dates: "Caturday"}}}, true)
+	tripService.PostDisruption(2, testPromoDeparture, model.Disruption{Status: model.DisruptionCancelled, Reason: "Strike"})
+	tripService.ClearDisruption(2, testPromoDeparture)
+	tripService.ClearDisruption(2, testPromoDeparture)
+
+	expected := []string{events.TripCreated, events.TripUpdated, events.TripCreated, events.DisruptionPosted, events.DisruptionCleared}
+	if len(publisher.published) != len(expected) {
+		t.Fatalf("expected only successful writes to publish %v, got %v", expected, publisher.published)
+	}
+	for i, event := range publisher.published {
+		if event.eventType != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, publisher.published)
+		}
+	}
+}
+
+func TestTripAudit_1(t *testing.T) {
+	auditor := &mockAuditor{}
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, newMockDisruptionDB(), &mockPublisher{}, auditor)
+
+	trip := model.Trip{OriginId: 1, DestinationId: 2, Dates: "Sat Sun", Price: 12.5}
+	tripService.AddTrip(context.Background(), trip)
+	tripService.AddTrip(context.Background(), model.Trip{OriginId: 9, DestinationId: 2, Dates: "Sat", Price: 1})
+	tripService.UpdateTrip(context.Background(), 2, trip, 1)
+	tripService.UpdateTrip(context.Background(), 2, trip, 7)
+	tripService.DeleteTrip(context.Background(), 1, 1)
+
+	expected := []string{audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete}
+	if len(auditor.recorded) != len(expected) {
+		t.Fatalf("expected only successful writes to be audited as %v, got %v", expected, auditor.recorded)
+	}
+	for i, change := range auditor.recorded {
+		if change.entity != audit.EntityTrip || change.action != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, auditor.recorded)
+		}
+	}
+
+	update := auditor.recorded[1]
+	if update.entityId != "2" || update.before.(model.Trip).Id != 2 || update.after.(model.Trip).Price != 12.5 {
+		t.Fatalf("expected trip 2 before and after its update, got %v", update)
+	}
+	if deletion := auditor.recorded[2]; deletion.before.(model.Trip).Id != 1 || deletion.after != nil {
+		t.Fatalf("expected trip 1 before its deletion and nothing after, got %v", deletion)
+	}
+}
+
+func TestFindTrips_1(t *testing.T) {
+	kvDB, err := db.NewKVDB(filepath.Join(t.TempDir(), "packandgo.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer kvDB.Close()
+
+	tests := []struct {
+		query    model.TripQuery
+		expected []int32
+	}{
+		{query: model.TripQuery{OriginId: 1, DestinationId: 2}, expected: []int32{1}},
+		{query: model.TripQuery{OriginId: 2}, expected: []int32{2}},
+		{query: model.TripQuery{DestinationId: 6}, expected: []int32{3}},
+		{query: model.TripQuery{OriginId: 1, DestinationId: 6}, expected: []int32{}},
+		{query: model.TripQuery{}, expected: []int32{1, 2, 3}},
+		{query: model.TripQuery{AfterId: 1, Limit: 1}, expected: []int32{2}},
+	}
+
+	// The memory database is filtered trip by trip, the kv store through its indexes
+	for _, tripDB := range []tripDB{db.NewMemoryDB(), kvDB} {
+		tripService := NewTripService(&mockCityDB{}, tripDB, newMockDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+
+		for _, test := range tests {
+			trips, err := tripService.FindTrips(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ids := []int32{}
+			for _, trip := range trips {
+				ids = append(ids, trip.Id)
+			}
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Fatalf("expected trips %v for %+v, got %v", test.expected, test.query, ids)
+			}
+		}
+	}
+}
+
+func TestGetAllTripsAsOf_1(t *testing.T) {
+	tripService := NewTripService(&mockCityDB{}, &mockTripDB{}, newMockDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+	if _, err := tripService.GetAllTripsAsOf(time.Now()); err != ErrorHistoryNotKept {
+		t.Fatalf("expected error: %v, got error: %v", ErrorHistoryNotKept, err)
+	}
+
+	tripLogDB, _ := db.NewTripLogDB("")
+	tripService = NewTripService(&mockCityDB{}, tripLogDB, newMockDisruptionDB(), &mockPublisher{}, &mockAuditor{})
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	trip, _ := tripService.AddTrip(context.Background(), model.Trip{OriginId: 1, DestinationId: 2, Dates: "Sat", Price: 1})
+
+	trips, err := tripService.GetAllTripsAsOf(before)
+	if err != nil || len(trips) != 3 {
+		t.Fatalf("expected the 3 trips before the new one, got %v %v", trips, err)
+	}
+	if _, err := tripService.GetTripByIdAsOf(trip.Id, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+const webhookSecretSize = 32
+const minWebhookSecretLength = 16
+
+// ErrorDeliveryNotDead is returned when retrying a delivery that has not run out of attempts
+var ErrorDeliveryNotDead = errors.New("only dead-lettered deliveries can be retried")
+
+type webhookDB interface {
+	GetWebhooks() []model.Webhook
+	GetWebhook(int32) (model.Webhook, error)
+	AddWebhook(model.Webhook) model.Webhook
+	UpdateWebhook(model.Webhook) (model.Webhook, error)
+	DeleteWebhook(int32) error
+	GetDelivery(int64) (model.WebhookDelivery, error)
+	GetDeliveries(int32) []model.WebhookDelivery
+	GetDeadLetters() []model.WebhookDelivery
+}
+
+type webhookDispatcher interface {
+	Redeliver(model.WebhookDelivery) (model.WebhookDelivery, error)
+	// CheckHost fails for hosts that deliveries are not allowed to reach
+	CheckHost(string) error
+}
+
+type webhookService struct {
+	webhookDB
+	webhookDispatcher
+	now func() time.Time
+}
+
+func NewWebhookService(webhookDB webhookDB, webhookDispatcher webhookDispatcher) *webhookService {
+	return &webhookService{webhookDB, webhookDispatcher, time.Now}
+}
+
+// GetWebhooks returns every webhook without its secret
+func (webhookService *webhookService) GetWebhooks() []model.Webhook {
+	webhooks := webhookService.webhookDB.GetWebhooks()
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks
+}
+
+// GetWebhook returns a webhook without its secret
+func (webhookService *webhookService) GetWebhook(id int32) (model.Webhook, error) {
+	webhook, err := webhookService.webhookDB.GetWebhook(id)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+// AddWebhook subscribes a URL to events, generating its secret if it has
+// none. The secret is only returned here.
+func (webhookService *webhookService) AddWebhook(webhook model.Webhook) (model.Webhook, error) {
+	webhook, err := webhookService.normalizeWebhook(webhook)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = generateWebhookSecret()
+		if err != nil {
+			return model.Webhook{}, err
+		}
+	}
+	webhook.CreatedAt = webhookService.now().UTC()
+
+	return webhookService.webhookDB.AddWebhook(webhook), nil
+}
+
+// UpdateWebhook replaces the URL and event types of a webhook, and its secret
+// if a new one is given
+func (webhookService *webhookService) UpdateWebhook(id int32, webhook model.Webhook) (model.Webhook, error) {
+	webhook.Id = id
+	webhook, err := webhookService.normalizeWebhook(webhook)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	webhook, err = webhookService.webhookDB.UpdateWebhook(webhook)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first
+func (webhookService *webhookService) GetDeliveries(webhookId int32) ([]model.WebhookDelivery, error) {
+	_, err := webhookService.webhookDB.GetWebhook(webhookId)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhookService.webhookDB.GetDeliveries(webhookId), nil
+}
+
+// RetryDelivery queues a dead-lettered delivery again with a fresh set of attempts
+func (webhookService *webhookService) RetryDelivery(id int64) (model.WebhookDelivery, error) {
+	delivery, err := webhookService.webhookDB.GetDelivery(id)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	if delivery.Status != model.DeliveryDead {
+		return model.WebhookDelivery{}, fmt.Errorf("%w: delivery %v is %v", ErrorDeliveryNotDead, id, delivery.Status)
+	}
+
+	return webhookService.webhookDispatcher.Redeliver(delivery)
+}
+
+// normalizeWebhook trims and validates a webhook, refusing hosts that
+// resolve to addresses of the network of the server
+func (webhookService *webhookService) normalizeWebhook(webhook model.Webhook) (model.Webhook, error) {
+	webhook.Url = strings.TrimSpace(webhook.Url)
+	parsedUrl, err := url.Parse(webhook.Url)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Hostname() == "" {
+		return model.Webhook{}, fmt.Errorf("invalid url, expected an absolute http or https URL: %v", webhook.Url)
+	}
+	if err := webhookService.webhookDispatcher.CheckHost(parsedUrl.Hostname()); err != nil {
+		return model.Webhook{}, err
+	}
+
+	eventTypes := []string{}
+	seen := map[string]bool{}
+	for _, eventType := range webhook.EventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !knownEventType(eventType) {
+			return model.Webhook{}, fmt.Errorf("invalid event type: %q, expected one of %v", eventType, strings.Join(events.Types, ", "))
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	webhook.EventTypes = eventTypes
+
+	if webhook.Secret != "" && len(webhook.Secret) < minWebhookSecretLength {
+		return model.Webhook{}, fmt.Errorf("invalid secret, expected at least %v characters", minWebhookSecretLength)
+	}
+
+	return webhook, nil
+}
+
+func knownEventType(eventType string) bool {
+	for _, known := range events.Types {
+		if eventType == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+func generateWebhookSecret() (string, error) {
+	secretBytes := make([]byte, webhookSecretSize)
+	_, err := rand.Read(secretBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secretBytes), nil
+}
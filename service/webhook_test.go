@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+	"github.com/gbandres98/pack-and-go/webhook"
+)
+
+type mockWebhookDispatcher struct {
+	redelivered []model.WebhookDelivery
+	checked     []string
+}
+
+func (mockWebhookDispatcher *mockWebhookDispatcher) Redeliver(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	mockWebhookDispatcher.redelivered = append(mockWebhookDispatcher.redelivered, delivery)
+	return delivery, nil
+}
+
+func (mockWebhookDispatcher *mockWebhookDispatcher) CheckHost(host string) error {
+	mockWebhookDispatcher.checked = append(mockWebhookDispatcher.checked, host)
+	if host == "169.254.169.254" {
+		return webhook.ErrorPrivateAddress
+	}
+
+	return nil
+}
+
+func TestAddWebhook_1(t *testing.T) {
+	webhookService := NewWebhookService(db.NewWebhookDB(), &mockWebhookDispatcher{})
+
+	webhook, err := webhookService.AddWebhook(model.Webhook{Url: " https://partner.example.com/hook ", EventTypes: []string{events.TripCreated, events.TripCreated}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if webhook.Url != "https://partner.example.com/hook" || len(webhook.EventTypes) != 1 || webhook.CreatedAt.IsZero() {
+		t.Fatalf("expected the webhook to be normalized, got %v", webhook)
+	}
+	if len(webhook.Secret) != 2*webhookSecretSize {
+		t.Fatalf("expected a generated secret, got %q", webhook.Secret)
+	}
+
+	saved, _ := webhookService.GetWebhook(webhook.Id)
+	if saved.Secret != "" || webhookService.GetWebhooks()[0].Secret != "" {
+		t.Fatalf("expected the secret to only be shown on creation")
+	}
+}
+
+func TestAddWebhook_2(t *testing.T) {
+	webhookService := NewWebhookService(db.NewWebhookDB(), &mockWebhookDispatcher{})
+
+	invalidWebhooks := []model.Webhook{
+		{Url: ""},
+		{Url: "partner.example.com/hook"},
+		{Url: "ftp://partner.example.com/hook"},
+		{Url: "https:///hook"},
+		{Url: "https://partner.example.com/hook", EventTypes: []string{"trip.renamed"}},
+		{Url: "https://partner.example.com/hook", Secret: "short"},
+		{Url: "http://169.254.169.254/latest/meta-data"},
+	}
+
+	for _, webhook := range invalidWebhooks {
+		_, err := webhookService.AddWebhook(webhook)
+		if err == nil {
+			t.Fatalf("%v: expected an error", webhook)
+		}
+	}
+}
+
+func TestRetryDelivery_1(t *testing.T) {
+	webhookDB := db.NewWebhookDB()
+	dispatcher := &mockWebhookDispatcher{}
+	webhookService := NewWebhookService(webhookDB, dispatcher)
+
+	webhook, _ := webhookService.AddWebhook(model.Webhook{Url: "https://partner.example.com/hook"})
+	dead := webhookDB.SaveDelivery(model.WebhookDelivery{WebhookId: webhook.Id, Status: model.DeliveryDead, Attempts: 6})
+	succeeded := webhookDB.SaveDelivery(model.WebhookDelivery{WebhookId: webhook.Id, Status: model.DeliverySucceeded, Attempts: 1})
+
+	delivery, err := webhookService.RetryDelivery(dead.Id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if delivery.Status != model.DeliveryPending || len(dispatcher.redelivered) != 1 {
+		t.Fatalf("expected delivery %v to be queued again, got %v", dead.Id, delivery)
+	}
+
+	_, err = webhookService.RetryDelivery(succeeded.Id)
+	if !errors.Is(err, ErrorDeliveryNotDead) {
+		t.Fatalf("expected %v, got %v", ErrorDeliveryNotDead, err)
+	}
+	_, err = webhookService.RetryDelivery(99)
+	if err != db.ErrorDeliveryNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorDeliveryNotFound, err)
+	}
+	_, err = webhookService.GetDeliveries(99)
+	if err != db.ErrorWebhookNotFound {
+		t.Fatalf("expected %v, got %v", db.ErrorWebhookNotFound, err)
+	}
+}
@@ -0,0 +1,576 @@
+package sqlmem
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenValue
+	tokenPlaceholder
+	tokenSymbol
+)
+
+// token is a lower case word, a literal value, a placeholder with the index
+// of its argument, or a symbol
+type token struct {
+	kind  tokenKind
+	text  string
+	value driver.Value
+	index int
+}
+
+func tokenize(query string) ([]token, error) {
+	tokens := []token{}
+	placeholders := 0
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := strings.ToLower(string(runes[start:i]))
+			if word == "null" {
+				tokens = append(tokens, token{kind: tokenValue, text: word})
+			} else {
+				tokens = append(tokens, token{kind: tokenWord, text: word})
+			}
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			if strings.Contains(text, ".") {
+				value, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: tokenValue, text: text, value: value})
+			} else {
+				value, err := strconv.ParseInt(text, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: tokenValue, text: text, value: value})
+			}
+
+		case r == '\'':
+			text := strings.Builder{}
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string in %q", query)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						text.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenValue, text: text.String(), value: text.String()})
+
+		case r == '?':
+			tokens = append(tokens, token{kind: tokenPlaceholder, text: "?", index: placeholders})
+			placeholders++
+			i++
+
+		case r == '$':
+			start := i
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			index, err := strconv.Atoi(string(runes[start+1 : i]))
+			if err != nil || index < 1 {
+				return nil, fmt.Errorf("invalid placeholder %v", string(runes[start:i]))
+			}
+			tokens = append(tokens, token{kind: tokenPlaceholder, text: string(runes[start:i]), index: index - 1})
+
+		default:
+			symbol := string(r)
+			if i+1 < len(runes) {
+				switch string(runes[i : i+2]) {
+				case "<=", ">=", "<>", "!=":
+					symbol = string(runes[i : i+2])
+				}
+			}
+			if !strings.Contains("(),;*=<>!+", symbol[:1]) {
+				return nil, fmt.Errorf("unexpected %q in %q", symbol, query)
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol})
+			i += len(symbol)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd}), nil
+}
+
+type parser struct {
+	query  string
+	tokens []token
+	pos    int
+}
+
+func parse(query string) (statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &parser{query: query, tokens: tokens}
+	statement, err := parser.statement()
+	if err != nil {
+		return nil, fmt.Errorf("%v in %q", err, query)
+	}
+
+	parser.acceptSymbol(";")
+	if parser.peek().kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %q in %q", parser.peek().text, query)
+	}
+
+	return statement, nil
+}
+
+func (parser *parser) peek() token {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *parser) next() token {
+	token := parser.tokens[parser.pos]
+	if token.kind != tokenEnd {
+		parser.pos++
+	}
+	return token
+}
+
+func (parser *parser) acceptWord(words ...string) bool {
+	for i, word := range words {
+		token := parser.tokens[parser.pos+i]
+		if token.kind != tokenWord || token.text != word {
+			return false
+		}
+	}
+
+	parser.pos += len(words)
+	return true
+}
+
+func (parser *parser) expectWord(words ...string) error {
+	if !parser.acceptWord(words...) {
+		return fmt.Errorf("expected %v, got %q", strings.ToUpper(strings.Join(words, " ")), parser.peek().text)
+	}
+
+	return nil
+}
+
+func (parser *parser) acceptSymbol(symbol string) bool {
+	token := parser.peek()
+	if token.kind == tokenSymbol && token.text == symbol {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+func (parser *parser) expectSymbol(symbol string) error {
+	if !parser.acceptSymbol(symbol) {
+		return fmt.Errorf("expected %q, got %q", symbol, parser.peek().text)
+	}
+
+	return nil
+}
+
+func (parser *parser) name() (string, error) {
+	token := parser.next()
+	if token.kind != tokenWord {
+		return "", fmt.Errorf("expected a name, got %q", token.text)
+	}
+
+	return token.text, nil
+}
+
+// names parses a list of names in parentheses
+func (parser *parser) names() ([]string, error) {
+	if err := parser.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for {
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+
+		if parser.acceptSymbol(")") {
+			return names, nil
+		}
+		if err := parser.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (parser *parser) expression() (expression, error) {
+	token := parser.next()
+
+	switch token.kind {
+	case tokenPlaceholder:
+		return expression{placeholder: token.index}, nil
+	case tokenValue:
+		return expression{placeholder: -1, value: token.value}, nil
+	}
+
+	return expression{}, fmt.Errorf("expected a value, got %q", token.text)
+}
+
+func (parser *parser) statement() (statement, error) {
+	switch {
+	case parser.acceptWord("create", "table"):
+		return parser.createTable()
+	case parser.acceptWord("drop", "table"):
+		statement := dropTable{}
+		statement.ifExists = parser.acceptWord("if", "exists")
+		var err error
+		statement.table, err = parser.name()
+		return statement, err
+	case parser.acceptWord("create", "index"):
+		return parser.createIndex()
+	case parser.acceptWord("drop", "index"):
+		statement := dropIndex{}
+		statement.ifExists = parser.acceptWord("if", "exists")
+		var err error
+		statement.index, err = parser.name()
+		return statement, err
+	case parser.acceptWord("insert", "into"):
+		return parser.insert()
+	case parser.acceptWord("select"):
+		return parser.selectRows()
+	case parser.acceptWord("update"):
+		return parser.update()
+	case parser.acceptWord("delete", "from"):
+		statement := deleteRows{}
+		var err error
+		statement.table, err = parser.name()
+		if err != nil {
+			return nil, err
+		}
+		statement.where, err = parser.where()
+		return statement, err
+	}
+
+	return nil, fmt.Errorf("unsupported statement starting with %q", parser.peek().text)
+}
+
+// createTable parses the column names, skipping their types and constraints.
+// Table constraints such as PRIMARY KEY (id) are skipped too.
+func (parser *parser) createTable() (statement, error) {
+	statement := createTable{primaryKey: -1}
+	statement.ifNotExists = parser.acceptWord("if", "not", "exists")
+
+	var err error
+	statement.table, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		token := parser.peek()
+		isConstraint := token.kind == tokenWord && (token.text == "primary" || token.text == "unique" || token.text == "constraint" || token.text == "foreign")
+		if !isConstraint {
+			column, err := parser.name()
+			if err != nil {
+				return nil, err
+			}
+			statement.columns = append(statement.columns, column)
+		}
+
+		depth := 0
+		for {
+			token := parser.peek()
+			if token.kind == tokenEnd {
+				return nil, fmt.Errorf("unterminated column list")
+			}
+			if depth == 0 && token.kind == tokenSymbol && (token.text == "," || token.text == ")") {
+				break
+			}
+			if !isConstraint && parser.acceptWord("primary", "key") {
+				statement.primaryKey = len(statement.columns) - 1
+				continue
+			}
+			switch {
+			case parser.acceptSymbol("("):
+				depth++
+			case parser.acceptSymbol(")"):
+				depth--
+			default:
+				parser.next()
+			}
+		}
+
+		if parser.acceptSymbol(")") {
+			return statement, nil
+		}
+		parser.next()
+	}
+}
+
+func (parser *parser) createIndex() (statement, error) {
+	statement := createIndex{}
+	statement.ifNotExists = parser.acceptWord("if", "not", "exists")
+
+	var err error
+	statement.index, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.expectWord("on"); err != nil {
+		return nil, err
+	}
+	statement.table, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	_, err = parser.names()
+
+	return statement, err
+}
+
+func (parser *parser) insert() (statement, error) {
+	statement := insert{}
+
+	var err error
+	statement.table, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek().kind == tokenSymbol && parser.peek().text == "(" {
+		statement.columns, err = parser.names()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := parser.expectWord("values"); err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := parser.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		values := []expression{}
+		for {
+			value, err := parser.expression()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if parser.acceptSymbol(")") {
+				break
+			}
+			if err := parser.expectSymbol(","); err != nil {
+				return nil, err
+			}
+		}
+		statement.rows = append(statement.rows, values)
+
+		if !parser.acceptSymbol(",") {
+			return statement, nil
+		}
+	}
+}
+
+func (parser *parser) selectRows() (statement, error) {
+	statement := selectRows{}
+
+	for {
+		item := selectItem{}
+		switch {
+		case parser.acceptSymbol("*"):
+			item.all = true
+		case parser.acceptWord("count"):
+			if err := parser.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			if err := parser.expectSymbol("*"); err != nil {
+				return nil, err
+			}
+			if err := parser.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			item.function = "count"
+		case parser.acceptWord("max"):
+			if err := parser.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			column, err := parser.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := parser.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			item.function, item.column = "max", column
+		default:
+			column, err := parser.name()
+			if err != nil {
+				return nil, err
+			}
+			item.column = column
+		}
+		statement.items = append(statement.items, item)
+
+		if !parser.acceptSymbol(",") {
+			break
+		}
+	}
+
+	if err := parser.expectWord("from"); err != nil {
+		return nil, err
+	}
+
+	var err error
+	statement.table, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	statement.where, err = parser.where()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.acceptWord("order", "by") {
+		statement.orderBy, err = parser.name()
+		if err != nil {
+			return nil, err
+		}
+		if parser.acceptWord("desc") {
+			statement.descending = true
+		} else {
+			parser.acceptWord("asc")
+		}
+	}
+
+	if parser.acceptWord("limit") {
+		limit, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		statement.limit = &limit
+	}
+	if parser.acceptWord("offset") {
+		offset, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		statement.offset = &offset
+	}
+
+	return statement, nil
+}
+
+func (parser *parser) update() (statement, error) {
+	statement := update{}
+
+	var err error
+	statement.table, err = parser.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.expectWord("set"); err != nil {
+		return nil, err
+	}
+
+	for {
+		column, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := parser.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		// column = column + value is the only arithmetic there is
+		increment := parser.acceptWord(column)
+		if increment {
+			if err := parser.expectSymbol("+"); err != nil {
+				return nil, err
+			}
+		}
+		value, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		statement.sets = append(statement.sets, assignment{column: column, value: value, increment: increment})
+
+		if !parser.acceptSymbol(",") {
+			break
+		}
+	}
+
+	statement.where, err = parser.where()
+	return statement, err
+}
+
+// where parses an optional WHERE of comparisons joined by AND
+func (parser *parser) where() ([]condition, error) {
+	if !parser.acceptWord("where") {
+		return nil, nil
+	}
+
+	conditions := []condition{}
+	for {
+		column, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+
+		operator := parser.next()
+		if operator.kind != tokenSymbol || !strings.Contains(" = <> != < <= > >= ", " "+operator.text+" ") {
+			return nil, fmt.Errorf("expected a comparison, got %q", operator.text)
+		}
+
+		value, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition{column, operator.text, value})
+
+		if !parser.acceptWord("and") {
+			return conditions, nil
+		}
+	}
+}
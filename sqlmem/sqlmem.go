@@ -0,0 +1,280 @@
+// Package sqlmem is an in-memory database/sql driver, registered as "sqlmem",
+// that understands the small subset of SQL used by the SQL store and its
+// migrations, so they can be tested without a database server. Databases are
+// named by their data source name and live as long as the process.
+//
+// Supported statements are CREATE TABLE, DROP TABLE, CREATE INDEX and DROP
+// INDEX, which only records the index, INSERT INTO ... VALUES, SELECT of
+// columns, COUNT(*) or MAX(column), UPDATE, which can add to a column with
+// SET column = column + value, and DELETE. Conditions are
+// comparisons of a column with a value joined by AND, and selects can have an
+// ORDER BY of one column, LIMIT and OFFSET. Values are ? or $1 placeholders,
+// numbers, 'strings' and NULL. Transactions see their own writes and run one
+// at a time.
+package sqlmem
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+func init() {
+	sql.Register("sqlmem", &memDriver{})
+}
+
+var (
+	databasesLock sync.Mutex
+	databases     = map[string]*database{}
+)
+
+type memDriver struct{}
+
+type database struct {
+	tables  map[string]*table
+	indexes map[string]string
+	lock    sync.Mutex
+}
+
+type table struct {
+	columns    []string
+	primaryKey int
+	rows       [][]driver.Value
+}
+
+func (memDriver *memDriver) Open(name string) (driver.Conn, error) {
+	databasesLock.Lock()
+	defer databasesLock.Unlock()
+
+	database, ok := databases[name]
+	if !ok {
+		database = newDatabase()
+		databases[name] = database
+	}
+
+	return &conn{database: database}, nil
+}
+
+func newDatabase() *database {
+	return &database{tables: map[string]*table{}, indexes: map[string]string{}}
+}
+
+// copyTables returns a copy of the tables, kept to restore them if a transaction is rolled back
+func (database *database) copyTables() map[string]*table {
+	tables := map[string]*table{}
+	for name, original := range database.tables {
+		copied := &table{columns: original.columns, primaryKey: original.primaryKey}
+		for _, row := range original.rows {
+			copied.rows = append(copied.rows, append([]driver.Value{}, row...))
+		}
+		tables[name] = copied
+	}
+
+	return tables
+}
+
+func (table *table) columnIndex(name string) (int, error) {
+	for i, column := range table.columns {
+		if column == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no such column: %v", name)
+}
+
+type conn struct {
+	database *database
+	tx       *tx
+}
+
+func (conn *conn) Prepare(query string) (driver.Stmt, error) {
+	statement, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{conn: conn, statement: statement}, nil
+}
+
+func (conn *conn) Close() error {
+	if conn.tx != nil {
+		return conn.tx.Rollback()
+	}
+
+	return nil
+}
+
+// Begin locks the database until the transaction ends, so transactions never see each other's writes
+func (conn *conn) Begin() (driver.Tx, error) {
+	if conn.tx != nil {
+		return nil, errors.New("transaction already in progress")
+	}
+
+	conn.database.lock.Lock()
+	conn.tx = &tx{conn: conn, tables: conn.database.copyTables()}
+	return conn.tx, nil
+}
+
+type tx struct {
+	conn   *conn
+	tables map[string]*table
+}
+
+func (tx *tx) Commit() error {
+	tx.conn.tx = nil
+	tx.conn.database.lock.Unlock()
+	return nil
+}
+
+func (tx *tx) Rollback() error {
+	tx.conn.database.tables = tx.tables
+	tx.conn.tx = nil
+	tx.conn.database.lock.Unlock()
+	return nil
+}
+
+type stmt struct {
+	conn      *conn
+	statement statement
+}
+
+func (stmt *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, as $1 placeholders can be repeated
+func (stmt *stmt) NumInput() int {
+	return -1
+}
+
+func (stmt *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := stmt.run(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(result.affected), nil
+}
+
+func (stmt *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	result, err := stmt.run(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (stmt *stmt) run(args []driver.Value) (result, error) {
+	if stmt.conn.tx == nil {
+		stmt.conn.database.lock.Lock()
+		defer stmt.conn.database.lock.Unlock()
+	}
+
+	return stmt.statement.run(stmt.conn.database, args)
+}
+
+type result struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (rows *rows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *rows) Close() error {
+	return nil
+}
+
+func (rows *rows) Next(dest []driver.Value) error {
+	if len(rows.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, rows.rows[0])
+	rows.rows = rows.rows[1:]
+	return nil
+}
+
+// compare orders two values, numbers by value and anything else by its text.
+// NULL is smaller than anything else.
+func compare(a driver.Value, b driver.Value) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	aNumber, aIsNumber := toFloat(a)
+	bNumber, bIsNumber := toFloat(b)
+	if aIsNumber && bIsNumber {
+		switch {
+		case aNumber < bNumber:
+			return -1
+		case aNumber > bNumber:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	aText, bText := toText(a), toText(b)
+	switch {
+	case aText < bText:
+		return -1
+	case aText > bText:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toFloat(value driver.Value) (float64, bool) {
+	switch number := value.(type) {
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	case bool:
+		if number {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func toText(value driver.Value) string {
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func sortRows(rows [][]driver.Value, column int, descending bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if descending {
+			return compare(rows[i][column], rows[j][column]) > 0
+		}
+		return compare(rows[i][column], rows[j][column]) < 0
+	})
+}
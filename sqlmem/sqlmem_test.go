@@ -0,0 +1,163 @@
+package sqlmem
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	sqlDB, err := sql.Open("sqlmem", t.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	_, err = sqlDB.Exec(`CREATE TABLE trips (
+		id INTEGER PRIMARY KEY,
+		origin_id INTEGER NOT NULL,
+		dates VARCHAR(255) NOT NULL,
+		price DOUBLE PRECISION
+	)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = sqlDB.Exec("INSERT INTO trips (id, origin_id, dates, price) VALUES (1, 1, 'Mon', 10.5), (2, 2, 'Tue', 20), (3, 1, 'It''s Sat', NULL)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return sqlDB
+}
+
+func selectIds(t *testing.T, sqlDB *sql.DB, query string, args ...interface{}) []int64 {
+	rows, err := sqlDB.Query(query, args...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestQuery_1(t *testing.T) {
+	sqlDB := openTestDB(t)
+
+	tests := []struct {
+		query    string
+		args     []interface{}
+		expected []int64
+	}{
+		{query: "SELECT id FROM trips ORDER BY id", expected: []int64{1, 2, 3}},
+		{query: "SELECT id FROM trips WHERE origin_id = ? ORDER BY id DESC", args: []interface{}{1}, expected: []int64{3, 1}},
+		{query: "SELECT id FROM trips WHERE origin_id = $2 AND id > $1 ORDER BY id", args: []interface{}{1, 1}, expected: []int64{3}},
+		{query: "SELECT id FROM trips WHERE price >= 10 ORDER BY id", expected: []int64{1, 2}},
+		{query: "SELECT id FROM trips WHERE dates = 'It''s Sat'", expected: []int64{3}},
+		{query: "SELECT id FROM trips ORDER BY id LIMIT ? OFFSET ?", args: []interface{}{1, 1}, expected: []int64{2}},
+		{query: "SELECT COUNT(*) FROM trips WHERE id <> 2", expected: []int64{2}},
+		{query: "SELECT MAX(id) FROM trips", expected: []int64{3}},
+	}
+
+	for _, test := range tests {
+		if ids := selectIds(t, sqlDB, test.query, test.args...); !reflect.DeepEqual(ids, test.expected) {
+			t.Fatalf("expected %v for %v, got %v", test.expected, test.query, ids)
+		}
+	}
+
+	var dates string
+	var price sql.NullFloat64
+	err := sqlDB.QueryRow("SELECT dates, price FROM trips WHERE id = ?", 3).Scan(&dates, &price)
+	if err != nil || dates != "It's Sat" || price.Valid {
+		t.Fatalf("expected It's Sat without a price, got %v %v %v", dates, price, err)
+	}
+}
+
+func TestExec_1(t *testing.T) {
+	sqlDB := openTestDB(t)
+
+	result, err := sqlDB.Exec("UPDATE trips SET price = ?, dates = ? WHERE origin_id = ?", 15.5, "Sun", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 2 {
+		t.Fatalf("expected 2 rows updated, got %v", affected)
+	}
+
+	sqlDB.Exec("UPDATE trips SET origin_id = origin_id + 1 WHERE id = ?", 2)
+	if ids := selectIds(t, sqlDB, "SELECT id FROM trips WHERE origin_id = 3"); !reflect.DeepEqual(ids, []int64{2}) {
+		t.Fatalf("expected the origin of trip 2 to be incremented, got %v", ids)
+	}
+
+	result, _ = sqlDB.Exec("DELETE FROM trips WHERE id = ?", 2)
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Fatalf("expected 1 row deleted, got %v", affected)
+	}
+	if ids := selectIds(t, sqlDB, "SELECT id FROM trips WHERE price = 15.5"); !reflect.DeepEqual(ids, []int64{1, 3}) {
+		t.Fatalf("expected trips 1 and 3 to be updated, got %v", ids)
+	}
+
+	if _, err := sqlDB.Exec("INSERT INTO trips (id, origin_id, dates) VALUES (?, ?, ?)", 1, 1, "Mon"); err == nil {
+		t.Fatalf("expected a duplicate primary key to fail")
+	}
+	if _, err := sqlDB.Exec("SELECT id FROM trips GROUP BY origin_id"); err == nil {
+		t.Fatalf("expected an unsupported statement to fail")
+	}
+}
+
+func TestTx_1(t *testing.T) {
+	sqlDB := openTestDB(t)
+
+	tx, _ := sqlDB.Begin()
+	tx.Exec("DELETE FROM trips WHERE id = 1")
+	if ids := selectIdsTx(t, tx); !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Fatalf("expected the transaction to see its own delete, got %v", ids)
+	}
+	tx.Rollback()
+
+	if ids := selectIds(t, sqlDB, "SELECT id FROM trips ORDER BY id"); !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Fatalf("expected the delete to be rolled back, got %v", ids)
+	}
+
+	tx, _ = sqlDB.Begin()
+	tx.Exec("DELETE FROM trips WHERE id = 1")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := selectIds(t, sqlDB, "SELECT id FROM trips ORDER BY id"); !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Fatalf("expected the delete to be committed, got %v", ids)
+	}
+
+	if _, err := sqlDB.Exec("DROP TABLE trips"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := sqlDB.Query("SELECT id FROM trips"); err == nil {
+		t.Fatalf("expected a dropped table to be missing, got %v", err)
+	}
+}
+
+func selectIdsTx(t *testing.T, tx *sql.Tx) []int64 {
+	rows, err := tx.Query("SELECT id FROM trips ORDER BY id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+
+	return ids
+}
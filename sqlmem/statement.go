@@ -0,0 +1,483 @@
+package sqlmem
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+type statement interface {
+	run(database *database, args []driver.Value) (result, error)
+}
+
+// expression is the argument of a placeholder, or a literal value if placeholder is -1
+type expression struct {
+	placeholder int
+	value       driver.Value
+}
+
+func (expression expression) evaluate(args []driver.Value) (driver.Value, error) {
+	if expression.placeholder < 0 {
+		return expression.value, nil
+	}
+	if expression.placeholder >= len(args) {
+		return nil, fmt.Errorf("missing argument %v", expression.placeholder+1)
+	}
+
+	return args[expression.placeholder], nil
+}
+
+type condition struct {
+	column   string
+	operator string
+	value    expression
+}
+
+// assignment sets a column to a value, or adds the value to it if increment is set
+type assignment struct {
+	column    string
+	value     expression
+	increment bool
+}
+
+// matcher tells whether a row satisfies every condition of a WHERE
+type matcher func(row []driver.Value) bool
+
+func newMatcher(table *table, conditions []condition, args []driver.Value) (matcher, error) {
+	columns := []int{}
+	values := []driver.Value{}
+
+	for _, condition := range conditions {
+		column, err := table.columnIndex(condition.column)
+		if err != nil {
+			return nil, err
+		}
+		value, err := condition.value.evaluate(args)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		values = append(values, value)
+	}
+
+	return func(row []driver.Value) bool {
+		for i, condition := range conditions {
+			// Comparisons with NULL are never true, as in SQL
+			if row[columns[i]] == nil || values[i] == nil {
+				return false
+			}
+
+			order := compare(row[columns[i]], values[i])
+			switch condition.operator {
+			case "=":
+				if order != 0 {
+					return false
+				}
+			case "<>", "!=":
+				if order == 0 {
+					return false
+				}
+			case "<":
+				if order >= 0 {
+					return false
+				}
+			case "<=":
+				if order > 0 {
+					return false
+				}
+			case ">":
+				if order <= 0 {
+					return false
+				}
+			case ">=":
+				if order < 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}, nil
+}
+
+func getTable(database *database, name string) (*table, error) {
+	table, ok := database.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("no such table: %v", name)
+	}
+
+	return table, nil
+}
+
+type createTable struct {
+	table       string
+	ifNotExists bool
+	columns     []string
+	// index of the PRIMARY KEY column, whose values have to be unique, or -1
+	primaryKey int
+}
+
+func (statement createTable) run(database *database, args []driver.Value) (result, error) {
+	if _, ok := database.tables[statement.table]; ok {
+		if statement.ifNotExists {
+			return result{}, nil
+		}
+		return result{}, fmt.Errorf("table %v already exists", statement.table)
+	}
+
+	database.tables[statement.table] = &table{columns: statement.columns, primaryKey: statement.primaryKey}
+	return result{}, nil
+}
+
+type dropTable struct {
+	table    string
+	ifExists bool
+}
+
+func (statement dropTable) run(database *database, args []driver.Value) (result, error) {
+	if _, ok := database.tables[statement.table]; !ok {
+		if statement.ifExists {
+			return result{}, nil
+		}
+		return result{}, fmt.Errorf("no such table: %v", statement.table)
+	}
+
+	delete(database.tables, statement.table)
+	for index, table := range database.indexes {
+		if table == statement.table {
+			delete(database.indexes, index)
+		}
+	}
+
+	return result{}, nil
+}
+
+type createIndex struct {
+	index       string
+	table       string
+	ifNotExists bool
+}
+
+func (statement createIndex) run(database *database, args []driver.Value) (result, error) {
+	if _, err := getTable(database, statement.table); err != nil {
+		return result{}, err
+	}
+	if _, ok := database.indexes[statement.index]; ok {
+		if statement.ifNotExists {
+			return result{}, nil
+		}
+		return result{}, fmt.Errorf("index %v already exists", statement.index)
+	}
+
+	database.indexes[statement.index] = statement.table
+	return result{}, nil
+}
+
+type dropIndex struct {
+	index    string
+	ifExists bool
+}
+
+func (statement dropIndex) run(database *database, args []driver.Value) (result, error) {
+	if _, ok := database.indexes[statement.index]; !ok && !statement.ifExists {
+		return result{}, fmt.Errorf("no such index: %v", statement.index)
+	}
+
+	delete(database.indexes, statement.index)
+	return result{}, nil
+}
+
+type insert struct {
+	table   string
+	columns []string
+	rows    [][]expression
+}
+
+func (statement insert) run(database *database, args []driver.Value) (result, error) {
+	table, err := getTable(database, statement.table)
+	if err != nil {
+		return result{}, err
+	}
+
+	columns := statement.columns
+	if columns == nil {
+		columns = table.columns
+	}
+	indexes := []int{}
+	for _, column := range columns {
+		index, err := table.columnIndex(column)
+		if err != nil {
+			return result{}, err
+		}
+		indexes = append(indexes, index)
+	}
+
+	newRows := [][]driver.Value{}
+	for _, values := range statement.rows {
+		if len(values) != len(columns) {
+			return result{}, fmt.Errorf("%v values for %v columns", len(values), len(columns))
+		}
+
+		row := make([]driver.Value, len(table.columns))
+		for i, value := range values {
+			row[indexes[i]], err = value.evaluate(args)
+			if err != nil {
+				return result{}, err
+			}
+		}
+
+		if table.primaryKey >= 0 {
+			for _, existing := range append(table.rows, newRows...) {
+				if compare(existing[table.primaryKey], row[table.primaryKey]) == 0 {
+					return result{}, fmt.Errorf("UNIQUE constraint failed: %v.%v", statement.table, table.columns[table.primaryKey])
+				}
+			}
+		}
+		newRows = append(newRows, row)
+	}
+
+	table.rows = append(table.rows, newRows...)
+	return result{affected: int64(len(newRows))}, nil
+}
+
+type selectItem struct {
+	all      bool
+	column   string
+	function string
+}
+
+type selectRows struct {
+	items      []selectItem
+	table      string
+	where      []condition
+	orderBy    string
+	descending bool
+	limit      *expression
+	offset     *expression
+}
+
+func (statement selectRows) run(database *database, args []driver.Value) (result, error) {
+	table, err := getTable(database, statement.table)
+	if err != nil {
+		return result{}, err
+	}
+
+	matches, err := newMatcher(table, statement.where, args)
+	if err != nil {
+		return result{}, err
+	}
+	rows := [][]driver.Value{}
+	for _, row := range table.rows {
+		if matches(row) {
+			rows = append(rows, append([]driver.Value{}, row...))
+		}
+	}
+
+	if statement.items[0].function != "" {
+		return statement.aggregate(table, rows)
+	}
+
+	if statement.orderBy != "" {
+		column, err := table.columnIndex(statement.orderBy)
+		if err != nil {
+			return result{}, err
+		}
+		sortRows(rows, column, statement.descending)
+	}
+
+	if statement.offset != nil {
+		offset, err := evaluateCount(*statement.offset, args)
+		if err != nil {
+			return result{}, err
+		}
+		if offset > len(rows) {
+			offset = len(rows)
+		}
+		rows = rows[offset:]
+	}
+	if statement.limit != nil {
+		limit, err := evaluateCount(*statement.limit, args)
+		if err != nil {
+			return result{}, err
+		}
+		if limit < len(rows) {
+			rows = rows[:limit]
+		}
+	}
+
+	columns := []string{}
+	indexes := []int{}
+	for _, item := range statement.items {
+		if item.all {
+			for i, column := range table.columns {
+				columns = append(columns, column)
+				indexes = append(indexes, i)
+			}
+			continue
+		}
+
+		index, err := table.columnIndex(item.column)
+		if err != nil {
+			return result{}, err
+		}
+		columns = append(columns, item.column)
+		indexes = append(indexes, index)
+	}
+
+	projected := [][]driver.Value{}
+	for _, row := range rows {
+		values := make([]driver.Value, len(indexes))
+		for i, index := range indexes {
+			values[i] = row[index]
+		}
+		projected = append(projected, values)
+	}
+
+	return result{columns: columns, rows: projected}, nil
+}
+
+// aggregate returns a single row with COUNT(*) or MAX(column) of the matching
+// rows. MAX of no rows is NULL.
+func (statement selectRows) aggregate(table *table, rows [][]driver.Value) (result, error) {
+	columns := []string{}
+	values := []driver.Value{}
+
+	for _, item := range statement.items {
+		switch item.function {
+		case "count":
+			columns = append(columns, "count")
+			values = append(values, int64(len(rows)))
+		case "max":
+			index, err := table.columnIndex(item.column)
+			if err != nil {
+				return result{}, err
+			}
+			var max driver.Value
+			for _, row := range rows {
+				if row[index] != nil && (max == nil || compare(row[index], max) > 0) {
+					max = row[index]
+				}
+			}
+			columns = append(columns, "max")
+			values = append(values, max)
+		default:
+			return result{}, fmt.Errorf("columns can not be selected along with aggregates")
+		}
+	}
+
+	return result{columns: columns, rows: [][]driver.Value{values}}, nil
+}
+
+func evaluateCount(expression expression, args []driver.Value) (int, error) {
+	value, err := expression.evaluate(args)
+	if err != nil {
+		return 0, err
+	}
+
+	count, ok := value.(int64)
+	if !ok || count < 0 {
+		return 0, fmt.Errorf("expected a count, got %v", value)
+	}
+
+	return int(count), nil
+}
+
+type update struct {
+	table string
+	sets  []assignment
+	where []condition
+}
+
+func (statement update) run(database *database, args []driver.Value) (result, error) {
+	table, err := getTable(database, statement.table)
+	if err != nil {
+		return result{}, err
+	}
+
+	matches, err := newMatcher(table, statement.where, args)
+	if err != nil {
+		return result{}, err
+	}
+
+	indexes := []int{}
+	values := []driver.Value{}
+	for _, set := range statement.sets {
+		index, err := table.columnIndex(set.column)
+		if err != nil {
+			return result{}, err
+		}
+		value, err := set.value.evaluate(args)
+		if err != nil {
+			return result{}, err
+		}
+		indexes = append(indexes, index)
+		values = append(values, value)
+	}
+
+	affected := int64(0)
+	for _, row := range table.rows {
+		if !matches(row) {
+			continue
+		}
+		for i, index := range indexes {
+			if !statement.sets[i].increment {
+				row[index] = values[i]
+				continue
+			}
+			row[index], err = add(row[index], values[i])
+			if err != nil {
+				return result{}, err
+			}
+		}
+		affected++
+	}
+
+	return result{affected: affected}, nil
+}
+
+// add returns the sum of two numbers, an integer if both are. NULL plus anything is NULL.
+func add(a driver.Value, b driver.Value) (driver.Value, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+
+	aInteger, aIsInteger := a.(int64)
+	bInteger, bIsInteger := b.(int64)
+	if aIsInteger && bIsInteger {
+		return aInteger + bInteger, nil
+	}
+
+	aNumber, aIsNumber := toFloat(a)
+	bNumber, bIsNumber := toFloat(b)
+	if !aIsNumber || !bIsNumber {
+		return nil, fmt.Errorf("can not add %v and %v", a, b)
+	}
+
+	return aNumber + bNumber, nil
+}
+
+type deleteRows struct {
+	table string
+	where []condition
+}
+
+func (statement deleteRows) run(database *database, args []driver.Value) (result, error) {
+	table, err := getTable(database, statement.table)
+	if err != nil {
+		return result{}, err
+	}
+
+	matches, err := newMatcher(table, statement.where, args)
+	if err != nil {
+		return result{}, err
+	}
+
+	kept := [][]driver.Value{}
+	for _, row := range table.rows {
+		if !matches(row) {
+			kept = append(kept, row)
+		}
+	}
+
+	affected := int64(len(table.rows) - len(kept))
+	table.rows = kept
+	return result{affected: affected}, nil
+}
@@ -0,0 +1,55 @@
+package ticket
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// LoadOrCreateKey reads the Ed25519 private key seed stored in base64 at
+// filePath, generating and saving a new one if the file does not exist
+func LoadOrCreateKey(filePath string) (ed25519.PrivateKey, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate ticket key: %w", err)
+		}
+
+		seed := base64.StdEncoding.EncodeToString(privateKey.Seed())
+		err = ioutil.WriteFile(filePath, []byte(seed+"\n"), 0600)
+		if err != nil {
+			return nil, fmt.Errorf("could not save ticket key: %w", err)
+		}
+
+		return privateKey, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read ticket key: %w", err)
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid ticket key in %v, expected a base64 Ed25519 seed", filePath)
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func EncodePublicKey(publicKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// DecodePublicKey parses a public key encoded by EncodePublicKey
+func DecodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key, expected a base64 Ed25519 public key")
+	}
+
+	return ed25519.PublicKey(publicKey), nil
+}
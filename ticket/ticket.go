@@ -0,0 +1,148 @@
+package ticket
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Prefix of every ticket code, followed by the version of the payload format
+const Prefix = "PG1:"
+
+const payloadVersion = 1
+const maxPassengerLength = 64
+
+var ErrorMalformed = errors.New("malformed ticket code")
+var ErrorSignature = errors.New("invalid ticket signature")
+var ErrorExpired = errors.New("ticket has expired")
+
+// Base32 only uses characters of the QR code alphanumeric mode, which packs
+// them denser than bytes
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type Ticket struct {
+	BookingId int32     `json:"bookingId"`
+	TripId    int32     `json:"tripId"`
+	Departure time.Time `json:"departure"`
+	Seat      int       `json:"seat"`
+	Passenger string    `json:"passenger"`
+	// The ticket is rejected after this time
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Issue signs a ticket with privateKey, returning its code
+func Issue(ticket Ticket, privateKey ed25519.PrivateKey) (string, error) {
+	payload, err := marshal(ticket)
+	if err != nil {
+		return "", err
+	}
+
+	signature := ed25519.Sign(privateKey, payload)
+	return Prefix + encoding.EncodeToString(append(payload, signature...)), nil
+}
+
+// Verify checks the signature of a ticket code against publicKey and that it
+// has not expired at now, returning the ticket it holds
+func Verify(code string, publicKey ed25519.PublicKey, now time.Time) (Ticket, error) {
+	code = strings.TrimSpace(code)
+	if !strings.HasPrefix(code, Prefix) {
+		return Ticket{}, ErrorMalformed
+	}
+
+	data, err := encoding.DecodeString(strings.TrimPrefix(code, Prefix))
+	if err != nil || len(data) <= ed25519.SignatureSize {
+		return Ticket{}, ErrorMalformed
+	}
+
+	payload := data[:len(data)-ed25519.SignatureSize]
+	signature := data[len(data)-ed25519.SignatureSize:]
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, payload, signature) {
+		return Ticket{}, ErrorSignature
+	}
+
+	ticket, err := unmarshal(payload)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	if !now.Before(ticket.ExpiresAt) {
+		return ticket, ErrorExpired
+	}
+
+	return ticket, nil
+}
+
+// The payload is version, booking id, trip id, departure and expiry as unix
+// seconds, seat and passenger name, big endian
+func marshal(ticket Ticket) ([]byte, error) {
+	if ticket.Seat < 0 || ticket.Seat > 0xffff {
+		return nil, fmt.Errorf("invalid seat: %v", ticket.Seat)
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte(payloadVersion)
+	binary.Write(&buffer, binary.BigEndian, ticket.BookingId)
+	binary.Write(&buffer, binary.BigEndian, ticket.TripId)
+	binary.Write(&buffer, binary.BigEndian, ticket.Departure.Unix())
+	binary.Write(&buffer, binary.BigEndian, ticket.ExpiresAt.Unix())
+	binary.Write(&buffer, binary.BigEndian, uint16(ticket.Seat))
+	passenger := truncate(ticket.Passenger, maxPassengerLength)
+	buffer.WriteByte(byte(len(passenger)))
+	buffer.WriteString(passenger)
+
+	return buffer.Bytes(), nil
+}
+
+func unmarshal(payload []byte) (Ticket, error) {
+	reader := bytes.NewReader(payload)
+
+	version, err := reader.ReadByte()
+	if err != nil || version != payloadVersion {
+		return Ticket{}, ErrorMalformed
+	}
+
+	var fields struct {
+		BookingId int32
+		TripId    int32
+		Departure int64
+		ExpiresAt int64
+		Seat      uint16
+		Length    uint8
+	}
+	if err := binary.Read(reader, binary.BigEndian, &fields); err != nil {
+		return Ticket{}, ErrorMalformed
+	}
+
+	passenger := make([]byte, fields.Length)
+	if n, _ := reader.Read(passenger); n != int(fields.Length) || reader.Len() != 0 {
+		return Ticket{}, ErrorMalformed
+	}
+
+	return Ticket{
+		BookingId: fields.BookingId,
+		TripId:    fields.TripId,
+		Departure: time.Unix(fields.Departure, 0).UTC(),
+		Seat:      int(fields.Seat),
+		Passenger: string(passenger),
+		ExpiresAt: time.Unix(fields.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// truncate shortens s to at most length bytes without splitting a character
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	for length > 0 && !utf8.RuneStart(s[length]) {
+		length--
+	}
+
+	return s[:length]
+}
@@ -0,0 +1,135 @@
+package ticket
+
+import (
+	"crypto/ed25519"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSeed = []byte("0123456789abcdef0123456789abcdef")
+
+var testTicket = Ticket{
+	BookingId: 12,
+	TripId:    1,
+	Departure: time.Date(2026, 3, 7, 7, 0, 0, 0, time.UTC),
+	Seat:      23,
+	Passenger: "Alice Smith",
+	ExpiresAt: time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC),
+}
+
+func TestIssue_1(t *testing.T) {
+	privateKey := ed25519.NewKeyFromSeed(testSeed)
+
+	code, err := Issue(testTicket, privateKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(code, Prefix) {
+		t.Fatalf("expected code to start with %v, got %v", Prefix, code)
+	}
+	if strings.Trim(code[len(Prefix):], "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567") != "" {
+		t.Fatalf("expected code to only use base32 characters, got %v", code)
+	}
+
+	ticket, err := Verify(code, privateKey.Public().(ed25519.PublicKey), testTicket.Departure)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ticket != testTicket {
+		t.Fatalf("expected %v, got %v", testTicket, ticket)
+	}
+}
+
+func TestVerify_1(t *testing.T) {
+	privateKey := ed25519.NewKeyFromSeed(testSeed)
+	code, _ := Issue(testTicket, privateKey)
+
+	_, err := Verify(code, privateKey.Public().(ed25519.PublicKey), testTicket.ExpiresAt)
+	if err != ErrorExpired {
+		t.Fatalf("expected %v, got %v", ErrorExpired, err)
+	}
+}
+
+func TestVerify_2(t *testing.T) {
+	privateKey := ed25519.NewKeyFromSeed(testSeed)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	code, _ := Issue(testTicket, privateKey)
+
+	// Change every character of the code in turn
+	for i := len(Prefix); i < len(code); i++ {
+		replacement := "A"
+		if code[i] == 'A' {
+			replacement = "B"
+		}
+		tampered := code[:i] + replacement + code[i+1:]
+
+		_, err := Verify(tampered, publicKey, testTicket.Departure)
+		if err == nil {
+			t.Fatalf("expected tampered code %v to be rejected", tampered)
+		}
+	}
+}
+
+func TestVerify_3(t *testing.T) {
+	code, _ := Issue(testTicket, ed25519.NewKeyFromSeed(testSeed))
+	otherKey := ed25519.NewKeyFromSeed([]byte("fedcba9876543210fedcba9876543210"))
+
+	_, err := Verify(code, otherKey.Public().(ed25519.PublicKey), testTicket.Departure)
+	if err != ErrorSignature {
+		t.Fatalf("expected %v, got %v", ErrorSignature, err)
+	}
+}
+
+func TestVerify_4(t *testing.T) {
+	publicKey := ed25519.NewKeyFromSeed(testSeed).Public().(ed25519.PublicKey)
+
+	for _, code := range []string{"", "PG1:", "PG1:not base32!", "XX1:AAAA", "PG1:AAAAAAAA"} {
+		_, err := Verify(code, publicKey, testTicket.Departure)
+		if err != ErrorMalformed {
+			t.Fatalf("expected %v for %q, got %v", ErrorMalformed, code, err)
+		}
+	}
+}
+
+func TestIssue_2(t *testing.T) {
+	privateKey := ed25519.NewKeyFromSeed(testSeed)
+	ticket := testTicket
+	ticket.Passenger = strings.Repeat("é", 40)
+
+	code, err := Issue(ticket, privateKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := Verify(code, privateKey.Public().(ed25519.PublicKey), testTicket.Departure)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Passenger != strings.Repeat("é", 32) {
+		t.Fatalf("expected passenger to be truncated to %v bytes, got %v", maxPassengerLength, result.Passenger)
+	}
+}
+
+func TestLoadOrCreateKey_1(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "ticket.key")
+
+	privateKey, err := LoadOrCreateKey(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loadedKey, err := LoadOrCreateKey(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !privateKey.Equal(loadedKey) {
+		t.Fatalf("expected the saved key to be loaded")
+	}
+
+	publicKey, err := DecodePublicKey(EncodePublicKey(privateKey.Public().(ed25519.PublicKey)))
+	if err != nil || !publicKey.Equal(privateKey.Public()) {
+		t.Fatalf("expected public key to be decoded, got %v %v", publicKey, err)
+	}
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrorPrivateAddress is returned for webhook hosts that are not on the
+// public internet, so webhooks can not reach the services of the network of
+// the server, such as cloud metadata endpoints
+var ErrorPrivateAddress = errors.New("webhook host is not a public address")
+
+// Shared address space of carrier-grade NAT and "this network", neither
+// reachable on the public internet
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("0.0.0.0/8"),
+}
+
+// CheckHost fails with ErrorPrivateAddress if host resolves to any address
+// that is not public, unless private addresses are allowed
+func (dispatcher *dispatcher) CheckHost(host string) error {
+	if dispatcher.config.AllowPrivateAddresses {
+		return nil
+	}
+
+	ips, err := dispatcher.lookupIP(host)
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook host %v: %w", host, err)
+	}
+
+	for _, ip := range ips {
+		if !publicAddress(ip) {
+			return fmt.Errorf("%w: %v resolves to %v", ErrorPrivateAddress, host, ip)
+		}
+	}
+
+	return nil
+}
+
+// checkDialedAddress refuses connections to addresses that are not public,
+// checked once the host has been resolved, so a host can not resolve to a
+// public address when registered and to a private one when delivered to
+func checkDialedAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !publicAddress(ip) {
+		return fmt.Errorf("%w: %v", ErrorPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// publicAddress tells whether ip is a unicast address of the public internet,
+// which loopback, private, link-local and multicast addresses are not
+func publicAddress(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return network
+}
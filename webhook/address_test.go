@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+func TestCheckHost_1(t *testing.T) {
+	dispatcher := &dispatcher{lookupIP: func(host string) ([]net.IP, error) {
+		if host == "rebound.example.com" {
+			return []net.IP{net.ParseIP("93.184.216.34"), net.ParseIP("10.0.0.5")}, nil
+		}
+		return []net.IP{net.ParseIP(host)}, nil
+	}}
+
+	tests := []struct {
+		host     string
+		expected error
+	}{
+		{host: "93.184.216.34", expected: nil},
+		{host: "2606:2800:220:1:248:1893:25c8:1946", expected: nil},
+		{host: "127.0.0.1", expected: ErrorPrivateAddress},
+		{host: "::1", expected: ErrorPrivateAddress},
+		{host: "10.1.2.3", expected: ErrorPrivateAddress},
+		{host: "172.16.0.1", expected: ErrorPrivateAddress},
+		{host: "192.168.1.1", expected: ErrorPrivateAddress},
+		{host: "169.254.169.254", expected: ErrorPrivateAddress},
+		{host: "100.64.0.1", expected: ErrorPrivateAddress},
+		{host: "0.0.0.0", expected: ErrorPrivateAddress},
+		{host: "fd00::1", expected: ErrorPrivateAddress},
+		{host: "::ffff:127.0.0.1", expected: ErrorPrivateAddress},
+		// Every address of a host is checked
+		{host: "rebound.example.com", expected: ErrorPrivateAddress},
+	}
+
+	for _, test := range tests {
+		if err := dispatcher.CheckHost(test.host); !errors.Is(err, test.expected) {
+			t.Fatalf("%v: expected error: %v, got error: %v", test.host, test.expected, err)
+		}
+	}
+
+	dispatcher.config.AllowPrivateAddresses = true
+	if err := dispatcher.CheckHost("169.254.169.254"); err != nil {
+		t.Fatalf("expected private addresses to be allowed, got error: %v", err)
+	}
+}
+
+func TestCheckHost_2(t *testing.T) {
+	receiver := newReceiver()
+	defer receiver.Close()
+
+	hub := events.NewHub(10)
+	webhookDB := db.NewWebhookDB()
+	webhook := webhookDB.AddWebhook(model.Webhook{Url: receiver.URL, Secret: "secret"})
+	config := testConfig
+	config.AllowPrivateAddresses = false
+	config.MaxAttempts = 1
+	dispatcher := NewDispatcher(webhookDB, hub, config)
+	defer dispatcher.Close()
+
+	// Webhooks saved before their host resolved to a private address are not delivered to either
+	hub.Publish(events.TripCreated, map[string]int{"id": 1})
+
+	waitFor(t, "the delivery to be dead-lettered", func() bool {
+		deliveries := webhookDB.GetDeliveries(webhook.Id)
+		return len(deliveries) == 1 && deliveries[0].Status == model.DeliveryDead
+	})
+
+	delivery := webhookDB.GetDeliveries(webhook.Id)[0]
+	if !strings.Contains(delivery.Error, ErrorPrivateAddress.Error()) || receiver.count() != 0 {
+		t.Fatalf("expected the delivery to the loopback address to be refused, got %v after %v requests", delivery, receiver.count())
+	}
+}
@@ -0,0 +1,333 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Bytes of a partner response read before the connection is reused
+const maxResponseBody = 64 << 10
+
+var ErrorQueueFull = errors.New("webhook delivery queue is full")
+
+type Config struct {
+	// Deliveries attempted at the same time
+	Workers int
+	// Deliveries waiting for a worker. Once full, new events wait in the
+	// event hub buffer instead.
+	QueueSize int
+	// Attempts of a delivery before it is dead-lettered
+	MaxAttempts int
+	// Wait before the first retry, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Time partners have to respond to a delivery
+	Timeout time.Duration
+	// Webhooks are refused, and deliveries fail, for hosts that resolve to
+	// loopback, private or link-local addresses unless set, such as for
+	// partners on the same network as the server
+	AllowPrivateAddresses bool
+}
+
+var DefaultConfig = Config{
+	Workers:     4,
+	QueueSize:   256,
+	MaxAttempts: 6,
+	Backoff:     10 * time.Second,
+	MaxBackoff:  10 * time.Minute,
+	Timeout:     5 * time.Second,
+}
+
+type store interface {
+	GetWebhooks() []model.Webhook
+	GetWebhook(int32) (model.Webhook, error)
+	SaveDelivery(model.WebhookDelivery) model.WebhookDelivery
+}
+
+type eventSource interface {
+	Subscribe(int64) *events.Subscription
+}
+
+type dispatcher struct {
+	store
+	config   Config
+	client   *http.Client
+	jobs     chan model.WebhookDelivery
+	ctx      context.Context
+	cancel   context.CancelFunc
+	running  sync.WaitGroup
+	now      func() time.Time
+	lookupIP func(string) ([]net.IP, error)
+}
+
+// NewDispatcher delivers every event published to source to the webhooks
+// that want it, on a pool of workers so slow partners never hold up the
+// requests that publish events. Zero config fields take their default value.
+func NewDispatcher(store store, source eventSource, config Config) *dispatcher {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	dispatcher := &dispatcher{
+		store:  store,
+		config: config,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: newTransport(config),
+			// Redirects are failed deliveries, partners have to update their URL
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		jobs:     make(chan model.WebhookDelivery, config.QueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		now:      time.Now,
+		lookupIP: net.LookupIP,
+	}
+
+	// Subscribe before returning, so no event published afterwards is missed
+	subscription := source.Subscribe(0)
+
+	dispatcher.running.Add(config.Workers + 1)
+	go dispatcher.dispatch(source, subscription)
+	for i := 0; i < config.Workers; i++ {
+		go dispatcher.work()
+	}
+
+	return dispatcher
+}
+
+func (config Config) withDefaults() Config {
+	if config.Workers <= 0 {
+		config.Workers = DefaultConfig.Workers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultConfig.QueueSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultConfig.Backoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+
+	return config
+}
+
+// newTransport returns the transport of deliveries, which checks the address
+// of every connection unless private addresses are allowed. Proxies are not
+// used, as they would be the address checked.
+func newTransport(config Config) *http.Transport {
+	dialer := &net.Dialer{Timeout: config.Timeout, KeepAlive: 30 * time.Second}
+	if !config.AllowPrivateAddresses {
+		dialer.Control = checkDialedAddress
+	}
+
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// Redeliver queues a delivery again with a fresh set of attempts, failing
+// with ErrorQueueFull instead of waiting for a worker
+func (dispatcher *dispatcher) Redeliver(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	previous := delivery
+
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = nil
+	delivery = dispatcher.store.SaveDelivery(delivery)
+
+	select {
+	case dispatcher.jobs <- delivery:
+		return delivery, nil
+	default:
+		dispatcher.store.SaveDelivery(previous)
+		return previous, ErrorQueueFull
+	}
+}
+
+// Close stops delivering, abandoning pending retries
+func (dispatcher *dispatcher) Close() error {
+	dispatcher.cancel()
+	dispatcher.running.Wait()
+	return nil
+}
+
+func (dispatcher *dispatcher) dispatch(source eventSource, subscription *events.Subscription) {
+	defer dispatcher.running.Done()
+
+	var lastId int64
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if !subscription.Dropped() {
+					return
+				}
+
+				// The queue was full for too long, resume from the hub buffer
+				subscription = source.Subscribe(lastId)
+				if !subscription.Complete {
+					log.Printf("some webhook deliveries of events after %v were lost, they are no longer buffered", lastId)
+				}
+				for _, event := range subscription.Replay {
+					dispatcher.enqueueEvent(event)
+					lastId = event.Id
+				}
+				continue
+			}
+
+			dispatcher.enqueueEvent(event)
+			lastId = event.Id
+		case <-dispatcher.ctx.Done():
+			subscription.Close()
+			return
+		}
+	}
+}
+
+func (dispatcher *dispatcher) enqueueEvent(event events.Event) {
+	payload, _ := json.Marshal(event)
+
+	for _, webhook := range dispatcher.store.GetWebhooks() {
+		if !webhook.Wants(event.Type) {
+			continue
+		}
+
+		delivery := dispatcher.store.SaveDelivery(model.WebhookDelivery{
+			WebhookId: webhook.Id,
+			EventId:   event.Id,
+			EventType: event.Type,
+			Payload:   payload,
+			Status:    model.DeliveryPending,
+			CreatedAt: dispatcher.now(),
+		})
+		dispatcher.enqueue(delivery)
+	}
+}
+
+func (dispatcher *dispatcher) enqueue(delivery model.WebhookDelivery) {
+	select {
+	case dispatcher.jobs <- delivery:
+	case <-dispatcher.ctx.Done():
+	}
+}
+
+func (dispatcher *dispatcher) work() {
+	defer dispatcher.running.Done()
+
+	for {
+		select {
+		case delivery := <-dispatcher.jobs:
+			dispatcher.deliver(delivery)
+		case <-dispatcher.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver attempts a delivery, scheduling a retry if it fails and it has
+// attempts left, or dead-lettering it otherwise
+func (dispatcher *dispatcher) deliver(delivery model.WebhookDelivery) {
+	webhook, err := dispatcher.store.GetWebhook(delivery.WebhookId)
+	if err != nil {
+		// Deleted since the delivery was queued
+		return
+	}
+
+	now := dispatcher.now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+	delivery.Error = ""
+
+	delivery.StatusCode, err = dispatcher.post(webhook, delivery, now)
+	if err == nil {
+		delivery.Status = model.DeliverySucceeded
+		dispatcher.store.SaveDelivery(delivery)
+		return
+	}
+	if dispatcher.ctx.Err() != nil {
+		return
+	}
+
+	delivery.Error = err.Error()
+	if delivery.Attempts >= dispatcher.config.MaxAttempts {
+		delivery.Status = model.DeliveryDead
+		dispatcher.store.SaveDelivery(delivery)
+		log.Printf("webhook delivery %v to %v dead-lettered after %v attempts: %v", delivery.Id, webhook.Url, delivery.Attempts, err)
+		return
+	}
+
+	backoff := dispatcher.backoff(delivery.Attempts)
+	nextAttemptAt := now.Add(backoff)
+	delivery.Status = model.DeliveryFailed
+	delivery.NextAttemptAt = &nextAttemptAt
+	delivery = dispatcher.store.SaveDelivery(delivery)
+
+	time.AfterFunc(backoff, func() { dispatcher.enqueue(delivery) })
+}
+
+// backoff returns the wait after a number of failed attempts
+func (dispatcher *dispatcher) backoff(attempts int) time.Duration {
+	backoff := dispatcher.config.Backoff
+	for i := 1; i < attempts && backoff < dispatcher.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > dispatcher.config.MaxBackoff {
+		return dispatcher.config.MaxBackoff
+	}
+	return backoff
+}
+
+// post sends a delivery, failing unless the partner responds with a 2xx
+func (dispatcher *dispatcher) post(webhook model.Webhook, delivery model.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(dispatcher.ctx, http.MethodPost, webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PackAndGo-Webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := dispatcher.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, maxResponseBody))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response: %v", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
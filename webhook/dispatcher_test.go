@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/pack-and-go/db"
+	"github.com/gbandres98/pack-and-go/events"
+	"github.com/gbandres98/pack-and-go/model"
+)
+
+// Receivers listen on the loopback address
+var testConfig = Config{Workers: 2, QueueSize: 4, MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, Timeout: time.Second, AllowPrivateAddresses: true}
+
+type receiver struct {
+	*httptest.Server
+	// Status codes of the responses, 200 once they run out
+	statusCodes []int
+	received    []*http.Request
+	bodies      [][]byte
+	lock        sync.Mutex
+}
+
+func newReceiver(statusCodes ...int) *receiver {
+	receiver := &receiver{statusCodes: statusCodes}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		receiver.lock.Lock()
+		defer receiver.lock.Unlock()
+
+		receiver.received = append(receiver.received, req)
+		receiver.bodies = append(receiver.bodies, body)
+		if len(receiver.statusCodes) > 0 {
+			w.WriteHeader(receiver.statusCodes[0])
+			receiver.statusCodes = receiver.statusCodes[1:]
+		}
+	}))
+
+	return receiver
+}
+
+func (receiver *receiver) count() int {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+
+	return len(receiver.received)
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", description)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeliver_1(t *testing.T) {
+	receiver := newReceiver()
+	defer receiver.Close()
+
+	hub := events.NewHub(10)
+	webhookDB := db.NewWebhookDB()
+	webhook := webhookDB.AddWebhook(model.Webhook{Url: receiver.URL, Secret: "secret", EventTypes: []string{events.TripCreated}})
+	dispatcher := NewDispatcher(webhookDB, hub, testConfig)
+	defer dispatcher.Close()
+
+	hub.Publish(events.TripUpdated, map[string]int{"id": 1})
+	hub.Publish(events.TripCreated, map[string]int{"id": 2})
+
+	waitFor(t, "the delivery to succeed", func() bool {
+		deliveries := webhookDB.GetDeliveries(webhook.Id)
+		return len(deliveries) == 1 && deliveries[0].Status == model.DeliverySucceeded
+	})
+
+	if receiver.count() != 1 {
+		t.Fatalf("expected only the %v event to be delivered, got %v deliveries", events.TripCreated, receiver.count())
+	}
+
+	req, body := receiver.received[0], receiver.bodies[0]
+	timestamp, _ := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if !Verify("secret", timestamp, body, req.Header.Get(HeaderSignature)) {
+		t.Fatalf("expected a valid signature, got %v", req.Header.Get(HeaderSignature))
+	}
+	if Verify("other secret", timestamp, body, req.Header.Get(HeaderSignature)) || Verify("secret", timestamp+1, body, req.Header.Get(HeaderSignature)) {
+		t.Fatalf("expected the signature to depend on the secret and timestamp")
+	}
+	if req.Header.Get(HeaderEvent) != events.TripCreated {
+		t.Fatalf("expected event header %v, got %v", events.TripCreated, req.Header.Get(HeaderEvent))
+	}
+
+	var event events.Event
+	json.Unmarshal(body, &event)
+	if event.Id != 2 || event.Type != events.TripCreated || string(event.Data) != `{"id":2}` {
+		t.Fatalf("expected event 2 to be delivered, got %v", event)
+	}
+}
+
+func TestDeliver_2(t *testing.T) {
+	receiver := newReceiver(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	defer receiver.Close()
+
+	hub := events.NewHub(10)
+	webhookDB := db.NewWebhookDB()
+	webhook := webhookDB.AddWebhook(model.Webhook{Url: receiver.URL, Secret: "secret"})
+	dispatcher := NewDispatcher(webhookDB, hub, testConfig)
+	defer dispatcher.Close()
+
+	hub.Publish(events.TripDeleted, map[string]int{"id": 1})
+
+	waitFor(t, "the delivery to succeed", func() bool {
+		deliveries := webhookDB.GetDeliveries(webhook.Id)
+		return len(deliveries) == 1 && deliveries[0].Status == model.DeliverySucceeded
+	})
+
+	delivery := webhookDB.GetDeliveries(webhook.Id)[0]
+	if delivery.Attempts != 3 || delivery.StatusCode != http.StatusOK || delivery.Error != "" {
+		t.Fatalf("expected success on the third attempt, got %v", delivery)
+	}
+}
+
+func TestDeliver_3(t *testing.T) {
+	receiver := newReceiver(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer receiver.Close()
+
+	hub := events.NewHub(10)
+	webhookDB := db.NewWebhookDB()
+	webhookDB.AddWebhook(model.Webhook{Url: receiver.URL, Secret: "secret"})
+	dispatcher := NewDispatcher(webhookDB, hub, testConfig)
+	defer dispatcher.Close()
+
+	hub.Publish(events.TripDeleted, map[string]int{"id": 1})
+
+	waitFor(t, "the delivery to be dead-lettered", func() bool {
+		return len(webhookDB.GetDeadLetters()) == 1
+	})
+
+	dead := webhookDB.GetDeadLetters()[0]
+	if dead.Attempts != testConfig.MaxAttempts || dead.StatusCode != http.StatusInternalServerError || dead.Error == "" {
+		t.Fatalf("expected %v failed attempts, got %v", testConfig.MaxAttempts, dead)
+	}
+
+	_, err := dispatcher.Redeliver(dead)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, "the redelivery to succeed", func() bool {
+		delivery, _ := webhookDB.GetDelivery(dead.Id)
+		return delivery.Status == model.DeliverySucceeded
+	})
+	if receiver.count() != testConfig.MaxAttempts+1 {
+		t.Fatalf("expected %v attempts, got %v", testConfig.MaxAttempts+1, receiver.count())
+	}
+}
+
+func TestDeliver_4(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1000)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		received <- struct{}{}
+	}))
+	defer slow.Close()
+
+	hub := events.NewHub(1000)
+	webhookDB := db.NewWebhookDB()
+	webhookDB.AddWebhook(model.Webhook{Url: slow.URL, Secret: "secret"})
+	dispatcher := NewDispatcher(webhookDB, hub, Config{Workers: 1, QueueSize: 1, Timeout: 5 * time.Second, AllowPrivateAddresses: true})
+	defer dispatcher.Close()
+
+	start := time.Now()
+	const published = 200
+	for i := 0; i < published; i++ {
+		hub.Publish(events.TripCreated, i)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected publishing not to wait for the partner, took %v", elapsed)
+	}
+
+	close(release)
+	for i := 0; i < published; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %v deliveries, got %v", published, i)
+		}
+	}
+}
+
+func TestBackoff_1(t *testing.T) {
+	dispatcher := &dispatcher{config: Config{Backoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: time.Second},
+		{attempts: 2, expected: 2 * time.Second},
+		{attempts: 4, expected: 8 * time.Second},
+		{attempts: 5, expected: 10 * time.Second},
+		{attempts: 100, expected: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		if backoff := dispatcher.backoff(test.attempts); backoff != test.expected {
+			t.Fatalf("%v attempts: expected %v, got %v", test.attempts, test.expected, backoff)
+		}
+	}
+}
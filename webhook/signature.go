@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	HeaderEvent    = "X-PackAndGo-Event"
+	HeaderDelivery = "X-PackAndGo-Delivery"
+	// Seconds since the epoch when the delivery was attempted
+	HeaderTimestamp = "X-PackAndGo-Timestamp"
+	HeaderSignature = "X-PackAndGo-Signature"
+)
+
+// Sign returns the signature sent in HeaderSignature for a delivery body sent
+// at timestamp: the hex HMAC-SHA256 of "timestamp.body" keyed with the secret
+// of the webhook, prefixed by "sha256=". Signing the timestamp lets receivers
+// reject replays of old deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of a delivery body sent at timestamp
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}